@@ -14,20 +14,33 @@
 package cluster
 
 import (
+	"context"
 	"fmt"
+	"time"
 
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/audit"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/logger"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/policy"
 	providerconfigv1 "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+	pricingsvc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/pricing"
 
-	"github.com/golang/glog"
+	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
 	client "sigs.k8s.io/cluster-api/pkg/client/clientset_generated/clientset/typed/cluster/v1alpha1"
+	controllerError "sigs.k8s.io/cluster-api/pkg/controller/error"
 )
 
-type ec2Svc interface {
-	ReconcileNetwork(string, *providerconfigv1.Network) error
-}
+// networkRequeueAfter is how long to wait before checking again on network resources,
+// like NAT gateways, that are still being provisioned.
+const networkRequeueAfter = 30 * time.Second
+
+// instancesRequeueAfter is how long to wait before checking again on instances that are
+// still stopping, to start them again when the cluster is resumed.
+const instancesRequeueAfter = 30 * time.Second
 
 type codec interface {
 	DecodeFromProviderConfig(clusterv1.ProviderConfig, runtime.Object) error
@@ -39,32 +52,109 @@ type codec interface {
 type Actuator struct {
 	codec          codec
 	clustersGetter client.ClustersGetter
-	ec2            ec2Svc
+	ec2            services.EC2Interface
+	ec2For         func(clusterName string) services.EC2Interface
+	pricing        services.PricingInterface
+	resourceGroups services.ResourceGroupsInterface
+	fileSystems    services.FileSystemInterface
+	hostedZones    services.PrivateHostedZoneInterface
+	nodeRoles      services.NodeRolesInterface
+	loadBalancers  services.LoadBalancersInterface
+	policy         policy.Checker
+	log            logr.Logger
+	now            func() time.Time
+
+	reconcileTimeout time.Duration
 }
 
 // ActuatorParams holds parameter information for Actuator
 type ActuatorParams struct {
 	Codec          codec
 	ClustersGetter client.ClustersGetter
-	EC2Service     ec2Svc
+	EC2Service     services.EC2Interface
+	// EC2ServiceFor returns the EC2 service to use for a cluster, like one whose API calls are
+	// rate limited per cluster. If nil, EC2Service is used for all clusters.
+	EC2ServiceFor func(clusterName string) services.EC2Interface
+	// PricingService estimates the cost of the resources of clusters. If nil, no cost is estimated.
+	PricingService services.PricingInterface
+	// ResourceGroupsService manages a resource group per cluster. If nil, no resource groups are managed.
+	ResourceGroupsService services.ResourceGroupsInterface
+	// FileSystemService manages the EFS file systems of clusters that ask for one. If nil, no file
+	// systems are managed.
+	FileSystemService services.FileSystemInterface
+	// PrivateHostedZoneService manages the Route 53 private hosted zones of clusters that ask for
+	// one. If nil, no hosted zones are managed.
+	PrivateHostedZoneService services.PrivateHostedZoneInterface
+	// NodeRolesService manages the IAM roles of the nodes of clusters that ask for them. If nil, no
+	// roles are managed.
+	NodeRolesService services.NodeRolesInterface
+	// LoadBalancersService deletes the load balancers left in the vpc of clusters, like the ones of
+	// services of type LoadBalancer, and takes hibernated instances out of them. If nil, they are
+	// left behind and keep the vpc from being deleted, and hibernated instances stay registered.
+	LoadBalancersService services.LoadBalancersInterface
+	// Policy checks clusters before anything is created for them. If nil, every cluster is reconciled.
+	Policy policy.Checker
+	// Logger is the base logger for the actuator. If nil, a default text logger is used.
+	Logger logr.Logger
+	// Clock returns the current time, which pause windows are evaluated at. If nil, time.Now is used.
+	Clock func() time.Time
+	// ReconcileTimeout is how long the AWS calls of a single reconcile may take before they are
+	// cancelled. If zero, they aren't.
+	ReconcileTimeout time.Duration
 }
 
 // NewActuator creates a new Actuator
 func NewActuator(params ActuatorParams) (*Actuator, error) {
+	log := params.Logger
+	if log == nil {
+		log = logger.Default()
+	}
+
+	now := params.Clock
+	if now == nil {
+		now = time.Now
+	}
+
 	return &Actuator{
-		codec:          params.Codec,
-		clustersGetter: params.ClustersGetter,
-		ec2:            params.EC2Service,
+		codec:            params.Codec,
+		clustersGetter:   params.ClustersGetter,
+		ec2:              params.EC2Service,
+		ec2For:           params.EC2ServiceFor,
+		pricing:          params.PricingService,
+		resourceGroups:   params.ResourceGroupsService,
+		fileSystems:      params.FileSystemService,
+		hostedZones:      params.PrivateHostedZoneService,
+		nodeRoles:        params.NodeRolesService,
+		loadBalancers:    params.LoadBalancersService,
+		policy:           params.Policy,
+		log:              log.WithName("cluster-actuator"),
+		now:              now,
+		reconcileTimeout: params.ReconcileTimeout,
 	}, nil
 }
 
 // Reconcile reconciles a cluster and is invoked by the Cluster Controller
 func (a *Actuator) Reconcile(cluster *clusterv1.Cluster) (reterr error) {
-	glog.Infof("Reconciling cluster %v.", cluster.Name)
+	log := a.log.WithValues("cluster", cluster.Name, "namespace", cluster.Namespace)
+	log.Info("Reconciling cluster")
+	a = a.forCluster(cluster.Name)
+	ctx, cancel := a.reconcileContext(cluster.Name)
+	defer cancel()
 
 	// Get a cluster api client for the namespace of the cluster.
 	clusterClient := a.clustersGetter.Clusters(cluster.Namespace)
 
+	// Load provider config.
+	config, err := a.loadProviderConfig(cluster)
+	if err != nil {
+		return errors.Errorf("failed to load cluster provider config: %v", err)
+	}
+
+	additionalTags, err := ec2svc.RenderTags(config.AdditionalTags, ec2svc.TagTemplateData{ClusterName: cluster.Name})
+	if err != nil {
+		return errors.Errorf("invalid additional tags: %v", err)
+	}
+
 	// Load provider status.
 	status, err := a.loadProviderStatus(cluster)
 	if err != nil {
@@ -76,27 +166,351 @@ func (a *Actuator) Reconcile(cluster *clusterv1.Cluster) (reterr error) {
 	defer func() {
 		// TODO(vincepri): remove this after moving to tag-discovery based approach.
 		if err := a.storeProviderStatus(clusterClient, cluster, status); err != nil {
-			glog.Errorf("failed to store provider status for cluster %q: %v", cluster.Name, err)
+			log.Error(err, "failed to store provider status")
 		}
 	}()
 
-	if err := a.ec2.ReconcileNetwork(cluster.Name, &status.Network); err != nil {
+	// Nothing but the hibernation of the nodes is reconciled in a pause window, the control plane
+	// keeps running. Check again once it ends.
+	now := a.now()
+	paused, until, err := pausedUntil(config.PauseWindows, now)
+	if err != nil {
+		return errors.Errorf("invalid pause windows: %v", err)
+	}
+	if paused {
+		log.Info("Cluster is paused", "until", until)
+		if err := a.hibernate(ctx, cluster.Name, false, status); err != nil {
+			return errors.Errorf("unable to hibernate instances: %v", err)
+		}
+		return &controllerError.RequeueAfterError{RequeueAfter: until.Sub(now)}
+	}
+
+	// Nothing is created for a cluster violating the policy, the violation is kept in the status.
+	status.PolicyViolation = ""
+	if a.policy != nil {
+		err := a.policy.Check(policy.Resource{Kind: policy.KindCluster, ClusterName: cluster.Name, Tags: additionalTags, Cluster: config})
+		if err != nil {
+			status.PolicyViolation = err.Error()
+			return errors.Wrap(err, "cluster violates the policy")
+		}
+	}
+
+	// Pods and services need address ranges of their own, nothing is created while they overlap.
+	if err := a.ec2.ValidateClusterNetwork(&config.Network, &status.Network, &cluster.Spec.ClusterNetwork); err != nil {
+		return errors.Errorf("invalid cluster network: %v", err)
+	}
+
+	// The steps of the network are recorded in the network status.
+	timer := ec2svc.NewStepTimer(&status.ProvisioningSteps)
+
+	err = timer.Time("network", func() error {
+		return a.ec2.ReconcileNetwork(ctx, cluster.Name, &config.Network, additionalTags, &status.Network)
+	})
+	reconcileNatGatewayCondition(status, now)
+	if err != nil {
+		if ec2svc.IsNotReady(err) {
+			// The pending state has been recorded in the status, check again later
+			// instead of blocking a worker until the resources are available.
+			log.Info("Network is not ready yet, requeuing", "reason", err, "requeue-after", networkRequeueAfter)
+			return &controllerError.RequeueAfterError{RequeueAfter: networkRequeueAfter}
+		}
 		return errors.Errorf("unable to reconcile network: %v", err)
 	}
 
+	if a.resourceGroups != nil {
+		err := timer.Time("resourceGroup", func() error {
+			return a.resourceGroups.ReconcileResourceGroup(ctx, cluster.Name, additionalTags)
+		})
+		if err != nil {
+			return errors.Errorf("unable to reconcile resource group: %v", err)
+		}
+	}
+
+	err = timer.Time("fileSystem", func() error {
+		return a.reconcileFileSystem(ctx, cluster.Name, config, additionalTags, status)
+	})
+	if err != nil {
+		if ec2svc.IsNotReady(err) {
+			log.Info("File system is not ready yet, requeuing", "reason", err, "requeue-after", networkRequeueAfter)
+			return &controllerError.RequeueAfterError{RequeueAfter: networkRequeueAfter}
+		}
+		return errors.Errorf("unable to reconcile file system: %v", err)
+	}
+
+	err = timer.Time("privateHostedZone", func() error {
+		return a.reconcilePrivateHostedZone(ctx, cluster.Name, config, additionalTags, status)
+	})
+	if err != nil {
+		return errors.Errorf("unable to reconcile private hosted zone: %v", err)
+	}
+
+	if config.NodeRoles {
+		if a.nodeRoles == nil {
+			return errors.New("unable to reconcile node roles: node roles are not enabled in the cluster controller")
+		}
+		if err := timer.Time("nodeRoles", func() error { return a.nodeRoles.ReconcileNodeRoles(ctx, cluster.Name, config.ECRRepositories) }); err != nil {
+			return errors.Errorf("unable to reconcile node roles: %v", err)
+		}
+	}
+
+	if err := a.reconcileHibernation(ctx, cluster.Name, config, status); err != nil {
+		if ec2svc.IsNotReady(err) {
+			log.Info("Instances are not ready yet, requeuing", "reason", err, "requeue-after", instancesRequeueAfter)
+			return &controllerError.RequeueAfterError{RequeueAfter: instancesRequeueAfter}
+		}
+		return errors.Errorf("unable to reconcile hibernation: %v", err)
+	}
+
+	// The estimate is informational, failing to get it doesn't hold up the cluster.
+	if err := a.reconcileCost(ctx, cluster, status); err != nil {
+		log.Error(err, "unable to estimate cluster cost")
+	}
+
+	return nil
+}
+
+// reconcileCost estimates the cost of the resources of the cluster, records it in the status
+// and publishes it as a metric.
+func (a *Actuator) reconcileCost(ctx context.Context, cluster *clusterv1.Cluster, status *providerconfigv1.AWSClusterProviderStatus) error {
+	if a.pricing == nil {
+		return nil
+	}
+
+	resources, err := a.ec2.DescribeClusterResources(ctx, cluster.Name)
+	if err != nil {
+		return err
+	}
+
+	hourly, err := a.pricing.EstimateHourlyCost(ctx, resources)
+	if err != nil {
+		return err
+	}
+
+	status.Cost = &providerconfigv1.CostEstimate{
+		Hourly:  fmt.Sprintf("%.4f", hourly),
+		Monthly: fmt.Sprintf("%.2f", hourly*pricingsvc.HoursPerMonth),
+	}
+	estimatedHourlyCost.WithLabelValues(cluster.Namespace, cluster.Name).Set(hourly)
+	return nil
+}
+
+// reconcileFileSystem creates the file system of a cluster that asks for one, together with the
+// security group of its mount targets. A file system that is no longer asked for is kept until the
+// cluster is deleted, so that its data isn't lost.
+func (a *Actuator) reconcileFileSystem(ctx context.Context, clusterName string, config *providerconfigv1.AWSClusterProviderConfig, additionalTags map[string]string, status *providerconfigv1.AWSClusterProviderStatus) error {
+	if config.FileSystem == nil {
+		return nil
+	}
+	if a.fileSystems == nil {
+		return errors.New("file systems are not enabled in the cluster controller")
+	}
+
+	sgID, err := a.ec2.ReconcileFileSystemSecurityGroup(ctx, clusterName, additionalTags, &status.Network)
+	if err != nil {
+		return err
+	}
+
+	if status.FileSystem == nil {
+		status.FileSystem = &providerconfigv1.FileSystem{}
+	}
+	return a.fileSystems.ReconcileFileSystem(ctx, clusterName, config.FileSystem, additionalTags, &status.Network, sgID, status.FileSystem)
+}
+
+// reconcilePrivateHostedZone creates the private hosted zone of a cluster that asks for one,
+// attached to the vpc of the cluster. Like the file system, a zone that is no longer asked for is
+// kept until the cluster is deleted, so that the records added to it aren't lost.
+func (a *Actuator) reconcilePrivateHostedZone(ctx context.Context, clusterName string, config *providerconfigv1.AWSClusterProviderConfig, additionalTags map[string]string, status *providerconfigv1.AWSClusterProviderStatus) error {
+	if config.PrivateHostedZone == nil {
+		return nil
+	}
+	if a.hostedZones == nil {
+		return errors.New("private hosted zones are not enabled in the cluster controller")
+	}
+
+	if status.PrivateHostedZone == nil {
+		status.PrivateHostedZone = &providerconfigv1.HostedZone{}
+	}
+	return a.hostedZones.ReconcilePrivateHostedZone(ctx, clusterName, config.PrivateHostedZone, additionalTags, &status.Network.VPC, status.PrivateHostedZone)
+}
+
+// reconcileHibernation stops the instances of a cluster that is hibernated and starts them again
+// once the cluster is resumed.
+func (a *Actuator) reconcileHibernation(ctx context.Context, clusterName string, config *providerconfigv1.AWSClusterProviderConfig, status *providerconfigv1.AWSClusterProviderStatus) error {
+	switch {
+	case config.Hibernate:
+		return a.hibernate(ctx, clusterName, true, status)
+	case status.Hibernated:
+		if err := a.ec2.ResumeInstances(ctx, clusterName); err != nil {
+			return err
+		}
+		if a.loadBalancers != nil && len(status.HibernatedRegistrations) > 0 {
+			if err := a.loadBalancers.RegisterInstances(ctx, clusterName, status.HibernatedRegistrations); err != nil {
+				return err
+			}
+		}
+		status.HibernatedRegistrations = nil
+		status.Hibernated = false
+	}
 	return nil
 }
 
+// hibernate takes the running instances of the cluster out of its load balancers and stops them,
+// the ones of the control plane only if controlPlane is true.
+func (a *Actuator) hibernate(ctx context.Context, clusterName string, controlPlane bool, status *providerconfigv1.AWSClusterProviderStatus) error {
+	// Instances stopped before a failure are started again on resume.
+	status.Hibernated = true
+
+	ids, err := a.ec2.InstancesToHibernate(ctx, clusterName, controlPlane)
+	if err != nil {
+		return err
+	}
+
+	if a.loadBalancers != nil {
+		// Registrations removed before a failure are restored on resume.
+		registrations, err := a.loadBalancers.DeregisterInstances(ctx, clusterName, &status.Network.VPC, ids)
+		status.HibernatedRegistrations = append(status.HibernatedRegistrations, registrations...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return a.ec2.HibernateInstances(ctx, clusterName, ids)
+}
+
 // Delete deletes a cluster and is invoked by the Cluster Controller
 func (a *Actuator) Delete(cluster *clusterv1.Cluster) error {
-	glog.Infof("Deleting cluster %v.", cluster.Name)
-	return fmt.Errorf("TODO: Not yet implemented")
+	log := a.log.WithValues("cluster", cluster.Name, "namespace", cluster.Namespace)
+	log.Info("Deleting cluster")
+	a = a.forCluster(cluster.Name)
+	ctx, cancel := a.reconcileContext(cluster.Name)
+	defer cancel()
+
+	if _, ok := cluster.Annotations[providerconfigv1.PivotedAnnotation]; ok {
+		log.Info("Cluster was moved to another management cluster, keeping its resources")
+		return nil
+	}
+
+	config, err := a.loadProviderConfig(cluster)
+	if err != nil {
+		return errors.Errorf("failed to load cluster provider config: %v", err)
+	}
+
+	if config.DeletionProtection {
+		log.Info("Cluster has deletion protection, refusing to delete it")
+		return errors.Errorf("cluster %q has deletion protection, unset deletionProtection to delete it", cluster.Name)
+	}
+
+	// Load provider status.
+	status, err := a.loadProviderStatus(cluster)
+	if err != nil {
+		return errors.Errorf("failed to load cluster provider status: %v", err)
+	}
+
+	// Instances of warm pools don't belong to a machine.
+	if err := a.ec2.DeleteWarmPools(ctx, cluster.Name); err != nil {
+		return errors.Errorf("unable to delete warm pools: %v", err)
+	}
+
+	// Launch templates are shared by the machines of a machine set and outlive them.
+	if err := a.ec2.DeleteLaunchTemplates(ctx, cluster.Name); err != nil {
+		return errors.Errorf("unable to delete launch templates: %v", err)
+	}
+
+	// Mount targets keep the security group of the file system, and with it the vpc, in use.
+	if a.fileSystems != nil {
+		if err := a.fileSystems.DeleteFileSystem(ctx, cluster.Name); err != nil {
+			if ec2svc.IsNotReady(err) {
+				log.Info("File system is still being deleted, requeuing", "reason", err, "requeue-after", networkRequeueAfter)
+				return &controllerError.RequeueAfterError{RequeueAfter: networkRequeueAfter}
+			}
+			return errors.Errorf("unable to delete file system: %v", err)
+		}
+	}
+
+	// The zone is attached to the vpc, its records may point at addresses in it.
+	if a.hostedZones != nil {
+		if err := a.hostedZones.DeletePrivateHostedZone(ctx, cluster.Name, config.PrivateHostedZone, &status.Network.VPC, status.PrivateHostedZone); err != nil {
+			return errors.Errorf("unable to delete private hosted zone: %v", err)
+		}
+	}
+
+	// Load balancers of services keep their subnets and security groups, and with them the vpc, in use.
+	if a.loadBalancers != nil {
+		if err := a.loadBalancers.DeleteLoadBalancers(ctx, cluster.Name, &status.Network.VPC); err != nil {
+			if ec2svc.IsNotReady(err) {
+				log.Info("Load balancers are still being deleted, requeuing", "reason", err, "requeue-after", networkRequeueAfter)
+				return &controllerError.RequeueAfterError{RequeueAfter: networkRequeueAfter}
+			}
+			return errors.Errorf("unable to delete load balancers: %v", err)
+		}
+	}
+
+	if config.DeepClean {
+		if err := a.ec2.DeleteWorkloadResources(ctx, cluster.Name, &status.Network); err != nil {
+			if ec2svc.IsNotReady(err) {
+				log.Info("Workload resources are still in use, requeuing", "reason", err, "requeue-after", instancesRequeueAfter)
+				return &controllerError.RequeueAfterError{RequeueAfter: instancesRequeueAfter}
+			}
+			return errors.Errorf("unable to delete workload resources: %v", err)
+		}
+	}
+
+	if err := a.ec2.DeleteNetwork(ctx, cluster.Name, &status.Network); err != nil {
+		if ec2svc.IsNotReady(err) {
+			log.Info("Network is still being deleted, requeuing", "reason", err, "requeue-after", networkRequeueAfter)
+			return &controllerError.RequeueAfterError{RequeueAfter: networkRequeueAfter}
+		}
+		return errors.Errorf("unable to delete network: %v", err)
+	}
+
+	// The instances using the roles are gone with the network.
+	if a.nodeRoles != nil {
+		if err := a.nodeRoles.DeleteNodeRoles(ctx, cluster.Name); err != nil {
+			return errors.Errorf("unable to delete node roles: %v", err)
+		}
+	}
+
+	if a.resourceGroups != nil {
+		if err := a.resourceGroups.DeleteResourceGroup(ctx, cluster.Name); err != nil {
+			return errors.Errorf("unable to delete resource group: %v", err)
+		}
+	}
+
+	estimatedHourlyCost.DeleteLabelValues(cluster.Namespace, cluster.Name)
+	return nil
+}
+
+// reconcileContext returns the context of a single reconcile of the cluster, the AWS calls of the
+// reconcile are cancelled once the reconcile timeout passed so that a hanging call fails the
+// reconcile, and are audited for the cluster.
+func (a *Actuator) reconcileContext(clusterName string) (context.Context, context.CancelFunc) {
+	ctx := audit.WithCluster(context.Background(), clusterName)
+	if a.reconcileTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, a.reconcileTimeout)
+}
+
+// forCluster returns a copy of the actuator that uses the EC2 service of the cluster.
+func (a *Actuator) forCluster(clusterName string) *Actuator {
+	if a.ec2For == nil {
+		return a
+	}
+	c := *a
+	c.ec2 = a.ec2For(clusterName)
+	return &c
 }
 
 func (a *Actuator) loadProviderConfig(cluster *clusterv1.Cluster) (*providerconfigv1.AWSClusterProviderConfig, error) {
 	providerConfig := &providerconfigv1.AWSClusterProviderConfig{}
-	err := a.codec.DecodeFromProviderConfig(cluster.Spec.ProviderConfig, providerConfig)
-	return providerConfig, err
+	if err := a.codec.DecodeFromProviderConfig(cluster.Spec.ProviderConfig, providerConfig); err != nil {
+		return nil, err
+	}
+
+	if err := providerConfig.ApplyPreset(); err != nil {
+		return nil, errors.Wrap(err, "invalid cluster preset")
+	}
+	return providerConfig, nil
 }
 
 func (a *Actuator) loadProviderStatus(cluster *clusterv1.Cluster) (*providerconfigv1.AWSClusterProviderStatus, error) {
@@ -14,19 +14,28 @@
 package cluster_test
 
 import (
+	"context"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	providerconfig "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
 	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
 	clientv1 "sigs.k8s.io/cluster-api/pkg/client/clientset_generated/clientset/typed/cluster/v1alpha1"
+	controllerError "sigs.k8s.io/cluster-api/pkg/controller/error"
 
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/actuators/cluster"
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/actuators/cluster/mock_clusteriface"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/policy"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services"
 	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/mock_ec2iface"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/mock_services"
 )
 
 type clusterGetter struct {
@@ -51,7 +60,7 @@ func TestReconcile(t *testing.T) {
 
 	gomock.InOrder(
 		me.EXPECT().
-			DescribeVpcs(&ec2.DescribeVpcsInput{
+			DescribeVpcsWithContext(gomock.Any(), &ec2.DescribeVpcsInput{
 				Filters: []*ec2.Filter{&ec2.Filter{
 					Name:   aws.String("tag-key"),
 					Values: aws.StringSlice([]string{"kubernetes.io/cluster/"}),
@@ -61,7 +70,7 @@ func TestReconcile(t *testing.T) {
 				Vpcs: []*ec2.Vpc{},
 			}, nil),
 		me.EXPECT().
-			CreateVpc(&ec2.CreateVpcInput{
+			CreateVpcWithContext(gomock.Any(), &ec2.CreateVpcInput{
 				CidrBlock: aws.String("10.0.0.0/16"),
 			}).
 			Return(&ec2.CreateVpcOutput{
@@ -70,13 +79,19 @@ func TestReconcile(t *testing.T) {
 					CidrBlock: aws.String("10.0.0.0/16"),
 				},
 			}, nil),
+		// The created vpc is described until it's visible.
 		me.EXPECT().
-			WaitUntilVpcAvailable(&ec2.DescribeVpcsInput{
-				VpcIds: []*string{aws.String("1234")},
+			DescribeVpcsWithContext(gomock.Any(), &ec2.DescribeVpcsInput{
+				VpcIds: aws.StringSlice([]string{"1234"}),
 			}).
-			Return(nil),
+			Return(&ec2.DescribeVpcsOutput{
+				Vpcs: []*ec2.Vpc{&ec2.Vpc{
+					VpcId:     aws.String("1234"),
+					CidrBlock: aws.String("10.0.0.0/16"),
+				}},
+			}, nil),
 		me.EXPECT().
-			CreateTags(&ec2.CreateTagsInput{
+			CreateTagsWithContext(gomock.Any(), &ec2.CreateTagsInput{
 				Resources: aws.StringSlice([]string{"1234"}),
 				Tags: []*ec2.Tag{&ec2.Tag{
 					Key:   aws.String("kubernetes.io/cluster/"),
@@ -85,7 +100,7 @@ func TestReconcile(t *testing.T) {
 			}).
 			Return(&ec2.CreateTagsOutput{}, nil),
 		me.EXPECT().
-			DescribeSubnets(&ec2.DescribeSubnetsInput{
+			DescribeSubnetsWithContext(gomock.Any(), &ec2.DescribeSubnetsInput{
 				Filters: []*ec2.Filter{
 					&ec2.Filter{
 						Name: aws.String("vpc-id"),
@@ -93,6 +108,10 @@ func TestReconcile(t *testing.T) {
 							aws.String("1234"),
 						},
 					},
+					&ec2.Filter{
+						Name:   aws.String("tag-key"),
+						Values: aws.StringSlice([]string{"kubernetes.io/cluster/"}),
+					},
 				},
 			}).
 			Return(&ec2.DescribeSubnetsOutput{
@@ -103,6 +122,7 @@ func TestReconcile(t *testing.T) {
 						AvailabilityZone:    aws.String("antarctica"),
 						CidrBlock:           aws.String("10.0.0.0/24"),
 						MapPublicIpOnLaunch: aws.Bool(false),
+						Tags:                []*ec2.Tag{{Key: aws.String("kubernetes.io/cluster/"), Value: aws.String("owned")}},
 					},
 					&ec2.Subnet{
 						SubnetId:            aws.String("ice"),
@@ -110,11 +130,12 @@ func TestReconcile(t *testing.T) {
 						AvailabilityZone:    aws.String("antarctica"),
 						CidrBlock:           aws.String("10.0.1.0/24"),
 						MapPublicIpOnLaunch: aws.Bool(true),
+						Tags:                []*ec2.Tag{{Key: aws.String("kubernetes.io/cluster/"), Value: aws.String("owned")}},
 					},
 				},
 			}, nil),
 		me.EXPECT().
-			DescribeAvailabilityZones(&ec2.DescribeAvailabilityZonesInput{
+			DescribeAvailabilityZonesWithContext(gomock.Any(), &ec2.DescribeAvailabilityZonesInput{
 				Filters: []*ec2.Filter{
 					&ec2.Filter{
 						Name:   aws.String("state"),
@@ -128,29 +149,58 @@ func TestReconcile(t *testing.T) {
 				},
 			}, nil),
 		me.EXPECT().
-			DescribeInternetGateways(&ec2.DescribeInternetGatewaysInput{
+			DescribeInternetGatewaysWithContext(gomock.Any(), &ec2.DescribeInternetGatewaysInput{
 				Filters: []*ec2.Filter{
 					&ec2.Filter{
 						Name:   aws.String("attachment.vpc-id"),
 						Values: []*string{aws.String("1234")},
 					},
+					&ec2.Filter{
+						Name:   aws.String("tag-key"),
+						Values: aws.StringSlice([]string{"kubernetes.io/cluster/"}),
+					},
 				},
 			}).
 			Return(&ec2.DescribeInternetGatewaysOutput{
 				InternetGateways: []*ec2.InternetGateway{
 					&ec2.InternetGateway{
 						InternetGatewayId: aws.String("carrot"),
+						Tags:              []*ec2.Tag{{Key: aws.String("kubernetes.io/cluster/"), Value: aws.String("owned")}},
 					},
 				},
 			}, nil),
 		me.EXPECT().
-			DescribeNatGatewaysPages(gomock.Any(), gomock.Any()).
+			DescribeNatGatewaysPagesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(nil),
 		me.EXPECT().
-			AllocateAddress(&ec2.AllocateAddressInput{Domain: aws.String("vpc")}).
+			DescribeAddressesWithContext(gomock.Any(), &ec2.DescribeAddressesInput{
+				Filters: []*ec2.Filter{
+					&ec2.Filter{
+						Name:   aws.String("domain"),
+						Values: []*string{aws.String("vpc")},
+					},
+					&ec2.Filter{
+						Name:   aws.String("tag:kubernetes.io/cluster/"),
+						Values: []*string{aws.String("owned")},
+					},
+				},
+			}).
+			Return(&ec2.DescribeAddressesOutput{}, nil),
+		me.EXPECT().
+			AllocateAddressWithContext(gomock.Any(), &ec2.AllocateAddressInput{Domain: aws.String("vpc")}).
 			Return(&ec2.AllocateAddressOutput{AllocationId: aws.String("scarf")}, nil),
 		me.EXPECT().
-			CreateNatGateway(&ec2.CreateNatGatewayInput{
+			CreateTagsWithContext(gomock.Any(), &ec2.CreateTagsInput{
+				Resources: aws.StringSlice([]string{"scarf"}),
+				Tags: []*ec2.Tag{&ec2.Tag{
+					Key:   aws.String("kubernetes.io/cluster/"),
+					Value: aws.String("owned"),
+				}},
+			}).
+			Return(&ec2.CreateTagsOutput{}, nil),
+		me.EXPECT().
+			CreateNatGatewayWithContext(gomock.Any(), &ec2.CreateNatGatewayInput{
+				ClientToken:  aws.String(ec2svc.ClientToken("", "nat-gateway", "ice")),
 				AllocationId: aws.String("scarf"),
 				SubnetId:     aws.String("ice"),
 			}).
@@ -160,10 +210,16 @@ func TestReconcile(t *testing.T) {
 				},
 			}, nil),
 		me.EXPECT().
-			WaitUntilNatGatewayAvailable(&ec2.DescribeNatGatewaysInput{NatGatewayIds: []*string{aws.String("nat-ice1")}}).
-			Return(nil),
+			CreateTagsWithContext(gomock.Any(), &ec2.CreateTagsInput{
+				Resources: aws.StringSlice([]string{"nat-ice1"}),
+				Tags: []*ec2.Tag{&ec2.Tag{
+					Key:   aws.String("kubernetes.io/cluster/"),
+					Value: aws.String("owned"),
+				}},
+			}).
+			Return(&ec2.CreateTagsOutput{}, nil),
 		me.EXPECT().
-			DescribeRouteTables(&ec2.DescribeRouteTablesInput{
+			DescribeRouteTablesWithContext(gomock.Any(), &ec2.DescribeRouteTablesInput{
 				Filters: []*ec2.Filter{
 					&ec2.Filter{
 						Name: aws.String("vpc-id"),
@@ -171,33 +227,69 @@ func TestReconcile(t *testing.T) {
 							aws.String("1234"),
 						},
 					},
+					&ec2.Filter{
+						Name:   aws.String("tag-key"),
+						Values: aws.StringSlice([]string{"kubernetes.io/cluster/"}),
+					},
 				},
 			}).Return(&ec2.DescribeRouteTablesOutput{}, nil),
 		me.EXPECT().
-			CreateRouteTable(&ec2.CreateRouteTableInput{VpcId: aws.String("1234")}).
+			CreateRouteTableWithContext(gomock.Any(), &ec2.CreateRouteTableInput{VpcId: aws.String("1234")}).
 			Return(&ec2.CreateRouteTableOutput{RouteTable: &ec2.RouteTable{RouteTableId: aws.String("rt-1")}}, nil),
 		me.EXPECT().
-			CreateRoute(&ec2.CreateRouteInput{
+			DescribeRouteTablesWithContext(gomock.Any(), &ec2.DescribeRouteTablesInput{
+				RouteTableIds: aws.StringSlice([]string{"rt-1"}),
+			}).
+			Return(&ec2.DescribeRouteTablesOutput{
+				RouteTables: []*ec2.RouteTable{&ec2.RouteTable{RouteTableId: aws.String("rt-1")}},
+			}, nil),
+		me.EXPECT().
+			CreateTagsWithContext(gomock.Any(), &ec2.CreateTagsInput{
+				Resources: aws.StringSlice([]string{"rt-1"}),
+				Tags: []*ec2.Tag{&ec2.Tag{
+					Key:   aws.String("kubernetes.io/cluster/"),
+					Value: aws.String("owned"),
+				}},
+			}).
+			Return(&ec2.CreateTagsOutput{}, nil),
+		me.EXPECT().
+			CreateRouteWithContext(gomock.Any(), &ec2.CreateRouteInput{
 				RouteTableId:         aws.String("rt-1"),
 				DestinationCidrBlock: aws.String("0.0.0.0/0"),
 				NatGatewayId:         aws.String("nat-ice1"),
 			}).
 			Return(&ec2.CreateRouteOutput{}, nil),
 		me.EXPECT().
-			AssociateRouteTable(&ec2.AssociateRouteTableInput{RouteTableId: aws.String("rt-1"), SubnetId: aws.String("snow")}).
+			AssociateRouteTableWithContext(gomock.Any(), &ec2.AssociateRouteTableInput{RouteTableId: aws.String("rt-1"), SubnetId: aws.String("snow")}).
 			Return(&ec2.AssociateRouteTableOutput{}, nil),
 		me.EXPECT().
-			CreateRouteTable(&ec2.CreateRouteTableInput{VpcId: aws.String("1234")}).
+			CreateRouteTableWithContext(gomock.Any(), &ec2.CreateRouteTableInput{VpcId: aws.String("1234")}).
 			Return(&ec2.CreateRouteTableOutput{RouteTable: &ec2.RouteTable{RouteTableId: aws.String("rt-2")}}, nil),
 		me.EXPECT().
-			CreateRoute(&ec2.CreateRouteInput{
+			DescribeRouteTablesWithContext(gomock.Any(), &ec2.DescribeRouteTablesInput{
+				RouteTableIds: aws.StringSlice([]string{"rt-2"}),
+			}).
+			Return(&ec2.DescribeRouteTablesOutput{
+				RouteTables: []*ec2.RouteTable{&ec2.RouteTable{RouteTableId: aws.String("rt-2")}},
+			}, nil),
+		me.EXPECT().
+			CreateTagsWithContext(gomock.Any(), &ec2.CreateTagsInput{
+				Resources: aws.StringSlice([]string{"rt-2"}),
+				Tags: []*ec2.Tag{&ec2.Tag{
+					Key:   aws.String("kubernetes.io/cluster/"),
+					Value: aws.String("owned"),
+				}},
+			}).
+			Return(&ec2.CreateTagsOutput{}, nil),
+		me.EXPECT().
+			CreateRouteWithContext(gomock.Any(), &ec2.CreateRouteInput{
 				RouteTableId:         aws.String("rt-2"),
 				DestinationCidrBlock: aws.String("0.0.0.0/0"),
 				GatewayId:            aws.String("carrot"),
 			}).
 			Return(&ec2.CreateRouteOutput{}, nil),
 		me.EXPECT().
-			AssociateRouteTable(&ec2.AssociateRouteTableInput{RouteTableId: aws.String("rt-2"), SubnetId: aws.String("ice")}).
+			AssociateRouteTableWithContext(gomock.Any(), &ec2.AssociateRouteTableInput{RouteTableId: aws.String("rt-2"), SubnetId: aws.String("ice")}).
 			Return(&ec2.AssociateRouteTableOutput{}, nil),
 	)
 
@@ -206,10 +298,8 @@ func TestReconcile(t *testing.T) {
 		t.Fatalf("failed to create codec: %v", err)
 	}
 	ap := cluster.ActuatorParams{
-		Codec: c,
-		EC2Service: &ec2svc.Service{
-			EC2: me,
-		},
+		Codec:          c,
+		EC2Service:     ec2svc.NewService(me),
 		ClustersGetter: cg,
 	}
 
@@ -222,3 +312,827 @@ func TestReconcile(t *testing.T) {
 		t.Fatalf("failed to reconcile cluster: %v", err)
 	}
 }
+
+func TestReconcileNetworkError(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	cg := &clusterGetter{
+		ci: mock_clusteriface.NewMockClusterInterface(mockCtrl),
+	}
+	ms := mock_services.NewMockEC2Interface(mockCtrl)
+	defer mockCtrl.Finish()
+
+	// The provider status is stored even if the network reconciliation fails.
+	cg.ci.EXPECT().
+		UpdateStatus(gomock.AssignableToTypeOf(&clusterv1.Cluster{})).
+		Return(&clusterv1.Cluster{}, nil)
+
+	ms.EXPECT().
+		ValidateClusterNetwork(&providerconfig.NetworkSpec{}, gomock.AssignableToTypeOf(&providerconfig.Network{}), &clusterv1.ClusterNetworkingConfig{}).
+		Return(nil)
+	ms.EXPECT().
+		ReconcileNetwork(gomock.Any(), "test", &providerconfig.NetworkSpec{}, map[string]string{}, gomock.AssignableToTypeOf(&providerconfig.Network{})).
+		Return(errors.New("boom"))
+
+	c, err := providerconfig.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create codec: %v", err)
+	}
+	ap := cluster.ActuatorParams{
+		Codec:          c,
+		EC2Service:     ms,
+		ClustersGetter: cg,
+	}
+
+	a, err := cluster.NewActuator(ap)
+	if err != nil {
+		t.Fatalf("could not create an actuator: %v", err)
+	}
+
+	if err := a.Reconcile(&clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}}); err == nil {
+		t.Fatalf("expected reconcile to fail")
+	}
+}
+
+func TestReconcileNetworkNotReady(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	cg := &clusterGetter{
+		ci: mock_clusteriface.NewMockClusterInterface(mockCtrl),
+	}
+	ms := mock_services.NewMockEC2Interface(mockCtrl)
+	defer mockCtrl.Finish()
+
+	c, err := providerconfig.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create codec: %v", err)
+	}
+
+	status := &providerconfig.AWSClusterProviderStatus{}
+	cg.ci.EXPECT().
+		UpdateStatus(gomock.AssignableToTypeOf(&clusterv1.Cluster{})).
+		Do(func(cluster *clusterv1.Cluster) {
+			if err := c.DecodeProviderStatus(cluster.Status.ProviderStatus, status); err != nil {
+				t.Fatalf("failed to decode provider status: %v", err)
+			}
+		}).
+		Return(&clusterv1.Cluster{}, nil)
+
+	ms.EXPECT().
+		ValidateClusterNetwork(&providerconfig.NetworkSpec{}, gomock.AssignableToTypeOf(&providerconfig.Network{}), &clusterv1.ClusterNetworkingConfig{}).
+		Return(nil)
+	ms.EXPECT().
+		ReconcileNetwork(gomock.Any(), "test", &providerconfig.NetworkSpec{}, map[string]string{}, gomock.AssignableToTypeOf(&providerconfig.Network{})).
+		Return(ec2svc.NewNotReady(errors.New("nat gateways are pending")))
+
+	ap := cluster.ActuatorParams{
+		Codec:          c,
+		EC2Service:     ms,
+		ClustersGetter: cg,
+	}
+
+	a, err := cluster.NewActuator(ap)
+	if err != nil {
+		t.Fatalf("could not create an actuator: %v", err)
+	}
+
+	err = a.Reconcile(&clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}})
+	if requeue, ok := err.(*controllerError.RequeueAfterError); !ok || requeue.RequeueAfter <= 0 {
+		t.Fatalf("expected a requeue error, got: %v", err)
+	}
+
+	// The network is recorded as a step in progress.
+	steps := status.ProvisioningSteps
+	if len(steps) != 1 || steps[0].Name != "network" || steps[0].StartTime.IsZero() || steps[0].CompletionTime != nil || steps[0].Reconciles != 1 {
+		t.Fatalf("expected the network step to be in progress, got: %+v", steps)
+	}
+}
+
+func TestReconcileHibernation(t *testing.T) {
+	registrations := []providerconfig.LoadBalancerRegistration{
+		{InstanceID: "i-1", LoadBalancerName: "service-a"},
+		{InstanceID: "i-1", TargetGroupARN: "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/service-b", Port: 30080},
+	}
+
+	testCases := []struct {
+		name          string
+		hibernate     bool
+		hibernated    bool
+		registrations []providerconfig.LoadBalancerRegistration
+		expect        func(ms *mock_services.MockEC2InterfaceMockRecorder, ml *mock_services.MockLoadBalancersInterfaceMockRecorder)
+		check         func(t *testing.T, err error, status *providerconfig.AWSClusterProviderStatus)
+	}{
+		{
+			name:      "hibernate",
+			hibernate: true,
+			expect: func(ms *mock_services.MockEC2InterfaceMockRecorder, ml *mock_services.MockLoadBalancersInterfaceMockRecorder) {
+				gomock.InOrder(
+					ms.InstancesToHibernate(gomock.Any(), "test", true).Return([]string{"i-1"}, nil),
+					ml.DeregisterInstances(gomock.Any(), "test", gomock.AssignableToTypeOf(&providerconfig.VPC{}), []string{"i-1"}).Return(registrations, nil),
+					ms.HibernateInstances(gomock.Any(), "test", []string{"i-1"}).Return(nil),
+				)
+			},
+			check: func(t *testing.T, err error, status *providerconfig.AWSClusterProviderStatus) {
+				if err != nil || !status.Hibernated {
+					t.Fatalf("expected the cluster to be hibernated, got: %v, %+v", err, status)
+				}
+				if !reflect.DeepEqual(status.HibernatedRegistrations, registrations) {
+					t.Fatalf("expected the load balancer registrations to be kept, got: %+v", status.HibernatedRegistrations)
+				}
+			},
+		},
+		{
+			name:          "resume",
+			hibernated:    true,
+			registrations: registrations,
+			expect: func(ms *mock_services.MockEC2InterfaceMockRecorder, ml *mock_services.MockLoadBalancersInterfaceMockRecorder) {
+				gomock.InOrder(
+					ms.ResumeInstances(gomock.Any(), "test").Return(nil),
+					ml.RegisterInstances(gomock.Any(), "test", registrations).Return(nil),
+				)
+			},
+			check: func(t *testing.T, err error, status *providerconfig.AWSClusterProviderStatus) {
+				if err != nil || status.Hibernated || len(status.HibernatedRegistrations) != 0 {
+					t.Fatalf("expected the cluster to be resumed, got: %v, %+v", err, status)
+				}
+			},
+		},
+		{
+			name:          "resume while stopping",
+			hibernated:    true,
+			registrations: registrations,
+			expect: func(ms *mock_services.MockEC2InterfaceMockRecorder, ml *mock_services.MockLoadBalancersInterfaceMockRecorder) {
+				ms.ResumeInstances(gomock.Any(), "test").Return(ec2svc.NewNotReady(errors.New("instances are still stopping")))
+			},
+			check: func(t *testing.T, err error, status *providerconfig.AWSClusterProviderStatus) {
+				if requeue, ok := err.(*controllerError.RequeueAfterError); !ok || requeue.RequeueAfter <= 0 {
+					t.Fatalf("expected a requeue error, got: %v", err)
+				}
+				if !status.Hibernated || !reflect.DeepEqual(status.HibernatedRegistrations, registrations) {
+					t.Fatalf("expected the cluster to stay hibernated until resumed, got: %+v", status)
+				}
+			},
+		},
+		{
+			name: "not hibernated",
+			expect: func(ms *mock_services.MockEC2InterfaceMockRecorder, ml *mock_services.MockLoadBalancersInterfaceMockRecorder) {
+			},
+			check: func(t *testing.T, err error, status *providerconfig.AWSClusterProviderStatus) {
+				if err != nil || status.Hibernated {
+					t.Fatalf("expected nothing to happen, got: %v, %+v", err, status)
+				}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			c, err := providerconfig.NewCodec()
+			if err != nil {
+				t.Fatalf("failed to create codec: %v", err)
+			}
+
+			providerConfig, err := c.EncodeToProviderConfig(&providerconfig.AWSClusterProviderConfig{Hibernate: tc.hibernate})
+			if err != nil {
+				t.Fatalf("failed to encode provider config: %v", err)
+			}
+			providerStatus, err := c.EncodeProviderStatus(&providerconfig.AWSClusterProviderStatus{Hibernated: tc.hibernated, HibernatedRegistrations: tc.registrations})
+			if err != nil {
+				t.Fatalf("failed to encode provider status: %v", err)
+			}
+
+			status := &providerconfig.AWSClusterProviderStatus{}
+			cg := &clusterGetter{
+				ci: mock_clusteriface.NewMockClusterInterface(mockCtrl),
+			}
+			cg.ci.EXPECT().
+				UpdateStatus(gomock.AssignableToTypeOf(&clusterv1.Cluster{})).
+				Do(func(cluster *clusterv1.Cluster) {
+					if err := c.DecodeProviderStatus(cluster.Status.ProviderStatus, status); err != nil {
+						t.Fatalf("failed to decode provider status: %v", err)
+					}
+				}).
+				Return(&clusterv1.Cluster{}, nil)
+
+			ms := mock_services.NewMockEC2Interface(mockCtrl)
+			ms.EXPECT().
+				ValidateClusterNetwork(&providerconfig.NetworkSpec{}, gomock.AssignableToTypeOf(&providerconfig.Network{}), &clusterv1.ClusterNetworkingConfig{}).
+				Return(nil)
+			ms.EXPECT().
+				ReconcileNetwork(gomock.Any(), "test", &providerconfig.NetworkSpec{}, map[string]string{}, gomock.AssignableToTypeOf(&providerconfig.Network{})).
+				Return(nil)
+			ml := mock_services.NewMockLoadBalancersInterface(mockCtrl)
+			tc.expect(ms.EXPECT(), ml.EXPECT())
+
+			a, err := cluster.NewActuator(cluster.ActuatorParams{
+				Codec:                c,
+				EC2Service:           ms,
+				LoadBalancersService: ml,
+				ClustersGetter:       cg,
+			})
+			if err != nil {
+				t.Fatalf("could not create an actuator: %v", err)
+			}
+
+			err = a.Reconcile(&clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test"},
+				Spec:       clusterv1.ClusterSpec{ProviderConfig: *providerConfig},
+				Status:     clusterv1.ClusterStatus{ProviderStatus: providerStatus},
+			})
+			tc.check(t, err, status)
+		})
+	}
+}
+
+func TestReconcileCost(t *testing.T) {
+	testCases := []struct {
+		name   string
+		expect func(ms *mock_services.MockEC2InterfaceMockRecorder, mp *mock_services.MockPricingInterfaceMockRecorder)
+		cost   *providerconfig.CostEstimate
+	}{
+		{
+			name: "estimated",
+			expect: func(ms *mock_services.MockEC2InterfaceMockRecorder, mp *mock_services.MockPricingInterfaceMockRecorder) {
+				resources := &ec2svc.ClusterResources{}
+				ms.DescribeClusterResources(gomock.Any(), "test").Return(resources, nil)
+				mp.EstimateHourlyCost(gomock.Any(), resources).Return(0.1235, nil)
+			},
+			cost: &providerconfig.CostEstimate{Hourly: "0.1235", Monthly: "90.16"},
+		},
+		{
+			// The cluster is reconciled anyway.
+			name: "pricing api failure",
+			expect: func(ms *mock_services.MockEC2InterfaceMockRecorder, mp *mock_services.MockPricingInterfaceMockRecorder) {
+				ms.DescribeClusterResources(gomock.Any(), "test").Return(&ec2svc.ClusterResources{}, nil)
+				mp.EstimateHourlyCost(gomock.Any(), gomock.Any()).Return(0.0, errors.New("access denied"))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			c, err := providerconfig.NewCodec()
+			if err != nil {
+				t.Fatalf("failed to create codec: %v", err)
+			}
+			providerConfig, err := c.EncodeToProviderConfig(&providerconfig.AWSClusterProviderConfig{})
+			if err != nil {
+				t.Fatalf("failed to encode provider config: %v", err)
+			}
+
+			status := &providerconfig.AWSClusterProviderStatus{}
+			cg := &clusterGetter{
+				ci: mock_clusteriface.NewMockClusterInterface(mockCtrl),
+			}
+			cg.ci.EXPECT().
+				UpdateStatus(gomock.AssignableToTypeOf(&clusterv1.Cluster{})).
+				Do(func(cluster *clusterv1.Cluster) {
+					if err := c.DecodeProviderStatus(cluster.Status.ProviderStatus, status); err != nil {
+						t.Fatalf("failed to decode provider status: %v", err)
+					}
+				}).
+				Return(&clusterv1.Cluster{}, nil)
+
+			ms := mock_services.NewMockEC2Interface(mockCtrl)
+			ms.EXPECT().
+				ValidateClusterNetwork(&providerconfig.NetworkSpec{}, gomock.AssignableToTypeOf(&providerconfig.Network{}), &clusterv1.ClusterNetworkingConfig{}).
+				Return(nil)
+			ms.EXPECT().
+				ReconcileNetwork(gomock.Any(), "test", &providerconfig.NetworkSpec{}, map[string]string{}, gomock.AssignableToTypeOf(&providerconfig.Network{})).
+				Return(nil)
+			mp := mock_services.NewMockPricingInterface(mockCtrl)
+			tc.expect(ms.EXPECT(), mp.EXPECT())
+
+			a, err := cluster.NewActuator(cluster.ActuatorParams{
+				Codec:          c,
+				EC2Service:     ms,
+				PricingService: mp,
+				ClustersGetter: cg,
+			})
+			if err != nil {
+				t.Fatalf("could not create an actuator: %v", err)
+			}
+
+			err = a.Reconcile(&clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test"},
+				Spec:       clusterv1.ClusterSpec{ProviderConfig: *providerConfig},
+			})
+			if err != nil {
+				t.Fatalf("failed to reconcile cluster: %v", err)
+			}
+			if !reflect.DeepEqual(status.Cost, tc.cost) {
+				t.Fatalf("expected cost %+v, got: %+v", tc.cost, status.Cost)
+			}
+		})
+	}
+}
+
+func TestReconcileFileSystem(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	c, err := providerconfig.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create codec: %v", err)
+	}
+	spec := &providerconfig.FileSystemSpec{Encrypted: true}
+	providerConfig, err := c.EncodeToProviderConfig(&providerconfig.AWSClusterProviderConfig{FileSystem: spec})
+	if err != nil {
+		t.Fatalf("failed to encode provider config: %v", err)
+	}
+
+	status := &providerconfig.AWSClusterProviderStatus{}
+	cg := &clusterGetter{
+		ci: mock_clusteriface.NewMockClusterInterface(mockCtrl),
+	}
+	cg.ci.EXPECT().
+		UpdateStatus(gomock.AssignableToTypeOf(&clusterv1.Cluster{})).
+		Do(func(cluster *clusterv1.Cluster) {
+			if err := c.DecodeProviderStatus(cluster.Status.ProviderStatus, status); err != nil {
+				t.Fatalf("failed to decode provider status: %v", err)
+			}
+		}).
+		Return(&clusterv1.Cluster{}, nil)
+
+	ms := mock_services.NewMockEC2Interface(mockCtrl)
+	ms.EXPECT().
+		ValidateClusterNetwork(&providerconfig.NetworkSpec{}, gomock.AssignableToTypeOf(&providerconfig.Network{}), &clusterv1.ClusterNetworkingConfig{}).
+		Return(nil)
+	ms.EXPECT().
+		ReconcileNetwork(gomock.Any(), "test", &providerconfig.NetworkSpec{}, map[string]string{}, gomock.AssignableToTypeOf(&providerconfig.Network{})).
+		Return(nil)
+	ms.EXPECT().
+		ReconcileFileSystemSecurityGroup(gomock.Any(), "test", map[string]string{}, gomock.AssignableToTypeOf(&providerconfig.Network{})).
+		Return("sg-1", nil)
+
+	// The file system is recorded while its mount targets are still being created.
+	mf := mock_services.NewMockFileSystemInterface(mockCtrl)
+	mf.EXPECT().
+		ReconcileFileSystem(gomock.Any(), "test", spec, map[string]string{}, gomock.AssignableToTypeOf(&providerconfig.Network{}), "sg-1", gomock.AssignableToTypeOf(&providerconfig.FileSystem{})).
+		Do(func(_ context.Context, _ string, _ *providerconfig.FileSystemSpec, _ map[string]string, _ *providerconfig.Network, sgID string, fs *providerconfig.FileSystem) {
+			fs.ID, fs.SecurityGroupID = "fs-1", sgID
+		}).
+		Return(ec2svc.NewNotReady(errors.New("mount targets are still being created")))
+
+	a, err := cluster.NewActuator(cluster.ActuatorParams{
+		Codec:             c,
+		EC2Service:        ms,
+		FileSystemService: mf,
+		ClustersGetter:    cg,
+	})
+	if err != nil {
+		t.Fatalf("could not create an actuator: %v", err)
+	}
+
+	err = a.Reconcile(&clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec:       clusterv1.ClusterSpec{ProviderConfig: *providerConfig},
+	})
+	if requeue, ok := err.(*controllerError.RequeueAfterError); !ok || requeue.RequeueAfter <= 0 {
+		t.Fatalf("expected a requeue error, got: %v", err)
+	}
+	if !reflect.DeepEqual(status.FileSystem, &providerconfig.FileSystem{ID: "fs-1", SecurityGroupID: "sg-1"}) {
+		t.Fatalf("unexpected file system status: %+v", status.FileSystem)
+	}
+}
+
+func TestReconcilePolicyViolation(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	c, err := providerconfig.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create codec: %v", err)
+	}
+	providerConfig, err := c.EncodeToProviderConfig(&providerconfig.AWSClusterProviderConfig{
+		AdditionalTags: map[string]string{"team": "ml"},
+	})
+	if err != nil {
+		t.Fatalf("failed to encode provider config: %v", err)
+	}
+
+	status := &providerconfig.AWSClusterProviderStatus{}
+	cg := &clusterGetter{
+		ci: mock_clusteriface.NewMockClusterInterface(mockCtrl),
+	}
+	cg.ci.EXPECT().
+		UpdateStatus(gomock.AssignableToTypeOf(&clusterv1.Cluster{})).
+		Do(func(cluster *clusterv1.Cluster) {
+			if err := c.DecodeProviderStatus(cluster.Status.ProviderStatus, status); err != nil {
+				t.Fatalf("failed to decode provider status: %v", err)
+			}
+		}).
+		Return(&clusterv1.Cluster{}, nil)
+
+	// Nothing is created for the cluster.
+	ms := mock_services.NewMockEC2Interface(mockCtrl)
+
+	a, err := cluster.NewActuator(cluster.ActuatorParams{
+		Codec:          c,
+		EC2Service:     ms,
+		ClustersGetter: cg,
+		Policy:         &policy.Static{RequiredTags: []string{"cost-center"}},
+	})
+	if err != nil {
+		t.Fatalf("could not create an actuator: %v", err)
+	}
+
+	err = a.Reconcile(&clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec:       clusterv1.ClusterSpec{ProviderConfig: *providerConfig},
+	})
+	if !policy.IsViolation(err) {
+		t.Fatalf("expected a policy violation, got: %v", err)
+	}
+	if status.PolicyViolation == "" {
+		t.Fatalf("expected the policy violation to be recorded in the status")
+	}
+}
+
+func TestReconcileNodeRoles(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	c, err := providerconfig.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create codec: %v", err)
+	}
+	providerConfig, err := c.EncodeToProviderConfig(&providerconfig.AWSClusterProviderConfig{
+		NodeRoles:       true,
+		ECRRepositories: []string{"arn:aws:ecr:eu-west-1:123456789012:repository/app"},
+	})
+	if err != nil {
+		t.Fatalf("failed to encode provider config: %v", err)
+	}
+
+	cg := &clusterGetter{
+		ci: mock_clusteriface.NewMockClusterInterface(mockCtrl),
+	}
+	cg.ci.EXPECT().
+		UpdateStatus(gomock.AssignableToTypeOf(&clusterv1.Cluster{})).
+		Return(&clusterv1.Cluster{}, nil)
+
+	ms := mock_services.NewMockEC2Interface(mockCtrl)
+	ms.EXPECT().
+		ValidateClusterNetwork(&providerconfig.NetworkSpec{}, gomock.AssignableToTypeOf(&providerconfig.Network{}), &clusterv1.ClusterNetworkingConfig{}).
+		Return(nil)
+	ms.EXPECT().
+		ReconcileNetwork(gomock.Any(), "test", &providerconfig.NetworkSpec{}, map[string]string{}, gomock.AssignableToTypeOf(&providerconfig.Network{})).
+		Return(nil)
+
+	mr := mock_services.NewMockNodeRolesInterface(mockCtrl)
+	mr.EXPECT().
+		ReconcileNodeRoles(gomock.Any(), "test", []string{"arn:aws:ecr:eu-west-1:123456789012:repository/app"}).
+		Return(nil)
+
+	a, err := cluster.NewActuator(cluster.ActuatorParams{
+		Codec:            c,
+		EC2Service:       ms,
+		NodeRolesService: mr,
+		ClustersGetter:   cg,
+	})
+	if err != nil {
+		t.Fatalf("could not create an actuator: %v", err)
+	}
+
+	if err := a.Reconcile(&clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec:       clusterv1.ClusterSpec{ProviderConfig: *providerConfig},
+	}); err != nil {
+		t.Fatalf("failed to reconcile cluster: %v", err)
+	}
+}
+
+func TestReconcilePaused(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	c, err := providerconfig.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create codec: %v", err)
+	}
+	providerConfig, err := c.EncodeToProviderConfig(&providerconfig.AWSClusterProviderConfig{
+		PauseWindows: []providerconfig.PauseWindow{{Start: "0 20 * * *", End: "0 7 * * *"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to encode provider config: %v", err)
+	}
+
+	cg := &clusterGetter{
+		ci: mock_clusteriface.NewMockClusterInterface(mockCtrl),
+	}
+	cg.ci.EXPECT().
+		UpdateStatus(gomock.AssignableToTypeOf(&clusterv1.Cluster{})).
+		Return(&clusterv1.Cluster{}, nil)
+
+	// The network isn't reconciled while the cluster is paused.
+	ms := mock_services.NewMockEC2Interface(mockCtrl)
+	ms.EXPECT().
+		InstancesToHibernate(gomock.Any(), "test", false).
+		Return([]string{"i-1"}, nil)
+	ms.EXPECT().
+		HibernateInstances(gomock.Any(), "test", []string{"i-1"}).
+		Return(nil)
+
+	a, err := cluster.NewActuator(cluster.ActuatorParams{
+		Codec:          c,
+		EC2Service:     ms,
+		ClustersGetter: cg,
+		Clock:          func() time.Time { return time.Date(2018, 10, 1, 23, 0, 0, 0, time.UTC) },
+	})
+	if err != nil {
+		t.Fatalf("could not create an actuator: %v", err)
+	}
+
+	err = a.Reconcile(&clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec:       clusterv1.ClusterSpec{ProviderConfig: *providerConfig},
+	})
+	if requeue, ok := err.(*controllerError.RequeueAfterError); !ok || requeue.RequeueAfter != 8*time.Hour {
+		t.Fatalf("expected a requeue at the end of the pause window, got: %v", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	testCases := []struct {
+		name      string
+		deleteErr error
+		check     func(t *testing.T, err error)
+	}{
+		{
+			name: "network deleted",
+			check: func(t *testing.T, err error) {
+				if err != nil {
+					t.Fatalf("failed to delete cluster: %v", err)
+				}
+			},
+		},
+		{
+			name:      "network still being deleted",
+			deleteErr: ec2svc.NewNotReady(errors.New("nat gateways are still being deleted")),
+			check: func(t *testing.T, err error) {
+				if requeue, ok := err.(*controllerError.RequeueAfterError); !ok || requeue.RequeueAfter <= 0 {
+					t.Fatalf("expected a requeue error, got: %v", err)
+				}
+			},
+		},
+		{
+			name:      "network deletion fails",
+			deleteErr: errors.New("boom"),
+			check: func(t *testing.T, err error) {
+				if _, ok := err.(*controllerError.RequeueAfterError); err == nil || ok {
+					t.Fatalf("expected delete to fail, got: %v", err)
+				}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			ms := mock_services.NewMockEC2Interface(mockCtrl)
+			ms.EXPECT().
+				DeleteWarmPools(gomock.Any(), "test").
+				Return(nil)
+			ms.EXPECT().
+				DeleteLaunchTemplates(gomock.Any(), "test").
+				Return(nil)
+			ms.EXPECT().
+				DeleteNetwork(gomock.Any(), "test", gomock.AssignableToTypeOf(&providerconfig.Network{})).
+				Return(tc.deleteErr)
+
+			c, err := providerconfig.NewCodec()
+			if err != nil {
+				t.Fatalf("failed to create codec: %v", err)
+			}
+			ap := cluster.ActuatorParams{
+				Codec:      c,
+				EC2Service: ms,
+				ClustersGetter: &clusterGetter{
+					ci: mock_clusteriface.NewMockClusterInterface(mockCtrl),
+				},
+			}
+
+			a, err := cluster.NewActuator(ap)
+			if err != nil {
+				t.Fatalf("could not create an actuator: %v", err)
+			}
+
+			tc.check(t, a.Delete(&clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}}))
+		})
+	}
+}
+
+func TestDeleteLoadBalancers(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ms := mock_services.NewMockEC2Interface(mockCtrl)
+	ms.EXPECT().
+		DeleteWarmPools(gomock.Any(), "test").
+		Return(nil).
+		Times(2)
+	ms.EXPECT().
+		DeleteLaunchTemplates(gomock.Any(), "test").
+		Return(nil).
+		Times(2)
+
+	lbs := mock_services.NewMockLoadBalancersInterface(mockCtrl)
+	deleting := lbs.EXPECT().
+		DeleteLoadBalancers(gomock.Any(), "test", gomock.AssignableToTypeOf(&providerconfig.VPC{})).
+		Return(ec2svc.NewNotReady(errors.New("load balancers are still being deleted")))
+	deleted := lbs.EXPECT().
+		DeleteLoadBalancers(gomock.Any(), "test", gomock.AssignableToTypeOf(&providerconfig.VPC{})).
+		Return(nil).
+		After(deleting)
+
+	// The network is only deleted once the load balancers are gone.
+	ms.EXPECT().
+		DeleteNetwork(gomock.Any(), "test", gomock.AssignableToTypeOf(&providerconfig.Network{})).
+		Return(nil).
+		After(deleted)
+
+	c, err := providerconfig.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create codec: %v", err)
+	}
+
+	a, err := cluster.NewActuator(cluster.ActuatorParams{
+		Codec:                c,
+		EC2Service:           ms,
+		LoadBalancersService: lbs,
+		ClustersGetter: &clusterGetter{
+			ci: mock_clusteriface.NewMockClusterInterface(mockCtrl),
+		},
+	})
+	if err != nil {
+		t.Fatalf("could not create an actuator: %v", err)
+	}
+
+	err = a.Delete(&clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}})
+	if requeue, ok := err.(*controllerError.RequeueAfterError); !ok || requeue.RequeueAfter <= 0 {
+		t.Fatalf("expected a requeue error while load balancers are being deleted, got: %v", err)
+	}
+
+	if err := a.Delete(&clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}}); err != nil {
+		t.Fatalf("failed to delete cluster: %v", err)
+	}
+}
+
+func TestDeleteDeepClean(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	c, err := providerconfig.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create codec: %v", err)
+	}
+	providerConfig, err := c.EncodeToProviderConfig(&providerconfig.AWSClusterProviderConfig{DeepClean: true})
+	if err != nil {
+		t.Fatalf("failed to encode provider config: %v", err)
+	}
+
+	ms := mock_services.NewMockEC2Interface(mockCtrl)
+	ms.EXPECT().
+		DeleteWarmPools(gomock.Any(), "test").
+		Return(nil)
+	ms.EXPECT().
+		DeleteLaunchTemplates(gomock.Any(), "test").
+		Return(nil)
+	// The network isn't deleted while volumes of the cluster are still attached.
+	ms.EXPECT().
+		DeleteWorkloadResources(gomock.Any(), "test", gomock.AssignableToTypeOf(&providerconfig.Network{})).
+		Return(ec2svc.NewNotReady(errors.New("volumes are still attached")))
+
+	a, err := cluster.NewActuator(cluster.ActuatorParams{
+		Codec:      c,
+		EC2Service: ms,
+		ClustersGetter: &clusterGetter{
+			ci: mock_clusteriface.NewMockClusterInterface(mockCtrl),
+		},
+	})
+	if err != nil {
+		t.Fatalf("could not create an actuator: %v", err)
+	}
+
+	err = a.Delete(&clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec:       clusterv1.ClusterSpec{ProviderConfig: *providerConfig},
+	})
+	if requeue, ok := err.(*controllerError.RequeueAfterError); !ok || requeue.RequeueAfter <= 0 {
+		t.Fatalf("expected a requeue error while volumes are attached, got: %v", err)
+	}
+}
+
+func TestDeleteProtected(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	c, err := providerconfig.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create codec: %v", err)
+	}
+	providerConfig, err := c.EncodeToProviderConfig(&providerconfig.AWSClusterProviderConfig{DeletionProtection: true})
+	if err != nil {
+		t.Fatalf("failed to encode provider config: %v", err)
+	}
+
+	// Nothing is deleted, the mock fails on any call.
+	a, err := cluster.NewActuator(cluster.ActuatorParams{
+		Codec:      c,
+		EC2Service: mock_services.NewMockEC2Interface(mockCtrl),
+		ClustersGetter: &clusterGetter{
+			ci: mock_clusteriface.NewMockClusterInterface(mockCtrl),
+		},
+	})
+	if err != nil {
+		t.Fatalf("could not create an actuator: %v", err)
+	}
+
+	err = a.Delete(&clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec:       clusterv1.ClusterSpec{ProviderConfig: *providerConfig},
+	})
+	if _, ok := err.(*controllerError.RequeueAfterError); err == nil || ok {
+		t.Fatalf("expected delete to be refused, got: %v", err)
+	}
+}
+
+func TestDeletePivoted(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	c, err := providerconfig.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create codec: %v", err)
+	}
+
+	// Nothing is deleted, the mock fails on any call.
+	a, err := cluster.NewActuator(cluster.ActuatorParams{
+		Codec:      c,
+		EC2Service: mock_services.NewMockEC2Interface(mockCtrl),
+		ClustersGetter: &clusterGetter{
+			ci: mock_clusteriface.NewMockClusterInterface(mockCtrl),
+		},
+	})
+	if err != nil {
+		t.Fatalf("could not create an actuator: %v", err)
+	}
+
+	if err := a.Delete(&clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Annotations: map[string]string{providerconfig.PivotedAnnotation: "true"}},
+	}); err != nil {
+		t.Fatalf("failed to delete cluster: %v", err)
+	}
+}
+
+func TestDeleteEC2ServiceFor(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ms := mock_services.NewMockEC2Interface(mockCtrl)
+	ms.EXPECT().
+		DeleteWarmPools(gomock.Any(), "test").
+		Return(nil)
+	ms.EXPECT().
+		DeleteLaunchTemplates(gomock.Any(), "test").
+		Return(nil)
+	ms.EXPECT().
+		DeleteNetwork(gomock.Any(), "test", gomock.AssignableToTypeOf(&providerconfig.Network{})).
+		Return(nil)
+
+	c, err := providerconfig.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create codec: %v", err)
+	}
+
+	var clusters []string
+	a, err := cluster.NewActuator(cluster.ActuatorParams{
+		Codec: c,
+		// The shared service must not be used when there is one per cluster.
+		EC2Service: mock_services.NewMockEC2Interface(mockCtrl),
+		EC2ServiceFor: func(clusterName string) services.EC2Interface {
+			clusters = append(clusters, clusterName)
+			return ms
+		},
+		ClustersGetter: &clusterGetter{
+			ci: mock_clusteriface.NewMockClusterInterface(mockCtrl),
+		},
+	})
+	if err != nil {
+		t.Fatalf("could not create an actuator: %v", err)
+	}
+
+	if err := a.Delete(&clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}}); err != nil {
+		t.Fatalf("failed to delete cluster: %v", err)
+	}
+	if len(clusters) != 1 || clusters[0] != "test" {
+		t.Fatalf("expected the EC2 service of cluster test to be used, got: %v", clusters)
+	}
+}
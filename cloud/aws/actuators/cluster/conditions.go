@@ -0,0 +1,111 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	providerconfigv1 "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+)
+
+// natGatewayCreationTime is how long NAT gateways typically take to become available.
+const natGatewayCreationTime = 5 * time.Minute
+
+// natGatewayStateAvailable is the state of NAT gateways that can be routed through.
+const natGatewayStateAvailable = "available"
+
+// reconcileNatGatewayCondition sets the NatGatewaysAvailable condition from the NAT gateways in
+// the network status, so that users watching the cluster know it's waiting for them rather than
+// stuck. Clusters without NAT gateways don't have the condition.
+func reconcileNatGatewayCondition(status *providerconfigv1.AWSClusterProviderStatus, now time.Time) {
+	var gateways []string
+	var eta time.Time
+	available := true
+	for _, sn := range status.Network.Subnets.FilterPublic() {
+		if sn.NatGatewayID == nil {
+			continue
+		}
+		state := "unknown"
+		if sn.NatGatewayState != nil {
+			state = *sn.NatGatewayState
+		}
+		gateways = append(gateways, fmt.Sprintf("%s (%s)", *sn.NatGatewayID, state))
+		if state == natGatewayStateAvailable {
+			continue
+		}
+		available = false
+		if sn.NatGatewayCreateTime != nil && sn.NatGatewayCreateTime.Add(natGatewayCreationTime).After(eta) {
+			eta = sn.NatGatewayCreateTime.Add(natGatewayCreationTime)
+		}
+	}
+
+	if len(gateways) == 0 {
+		removeCondition(status, providerconfigv1.NatGatewaysAvailable)
+		return
+	}
+
+	condition := providerconfigv1.AWSClusterProviderCondition{Type: providerconfigv1.NatGatewaysAvailable}
+	if available {
+		condition.Status = corev1.ConditionTrue
+	} else {
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = "NatGatewaysPending"
+		condition.Message = "Waiting for the NAT gateways " + strings.Join(gateways, ", ") + " to become available."
+		switch {
+		case eta.IsZero():
+		case now.Before(eta):
+			condition.Message += fmt.Sprintf(" They are expected to be available by %s.", eta.UTC().Format(time.RFC3339))
+		default:
+			condition.Message += fmt.Sprintf(" They are taking longer than the usual %s.", natGatewayCreationTime)
+		}
+	}
+	setCondition(status, condition, metav1.NewTime(now))
+}
+
+// setCondition adds or updates the condition of its type in the status and returns it.
+// The transition time is only changed if the status of the condition changed.
+func setCondition(status *providerconfigv1.AWSClusterProviderStatus, condition providerconfigv1.AWSClusterProviderCondition, now metav1.Time) *providerconfigv1.AWSClusterProviderCondition {
+	condition.LastProbeTime = now
+	condition.LastTransitionTime = now
+
+	for i := range status.Conditions {
+		c := &status.Conditions[i]
+		if c.Type != condition.Type {
+			continue
+		}
+		if c.Status == condition.Status {
+			condition.LastTransitionTime = c.LastTransitionTime
+		}
+		*c = condition
+		return c
+	}
+
+	status.Conditions = append(status.Conditions, condition)
+	return &status.Conditions[len(status.Conditions)-1]
+}
+
+// removeCondition removes the condition of the given type from the status.
+func removeCondition(status *providerconfigv1.AWSClusterProviderStatus, conditionType providerconfigv1.AWSClusterProviderConditionType) {
+	conditions := status.Conditions[:0]
+	for _, c := range status.Conditions {
+		if c.Type != conditionType {
+			conditions = append(conditions, c)
+		}
+	}
+	status.Conditions = conditions
+}
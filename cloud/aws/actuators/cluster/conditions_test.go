@@ -0,0 +1,75 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	providerconfigv1 "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+)
+
+func TestReconcileNatGatewayCondition(t *testing.T) {
+	created := metav1.NewTime(time.Date(2018, 10, 1, 8, 0, 0, 0, time.UTC))
+	status := &providerconfigv1.AWSClusterProviderStatus{
+		Network: providerconfigv1.Network{
+			Subnets: providerconfigv1.Subnets{
+				{ID: "subnet-private", AvailabilityZone: "us-east-1a"},
+				{ID: "subnet-public-a", IsPublic: true, NatGatewayID: aws.String("nat-a"), NatGatewayState: aws.String("pending"), NatGatewayCreateTime: &created},
+				{ID: "subnet-public-b", IsPublic: true, NatGatewayID: aws.String("nat-b"), NatGatewayState: aws.String("available")},
+			},
+		},
+	}
+
+	// Pending gateways are listed with when they're expected to be available.
+	pendingSince := created.Add(time.Minute)
+	reconcileNatGatewayCondition(status, pendingSince)
+	if len(status.Conditions) != 1 {
+		t.Fatalf("expected a single condition, got: %+v", status.Conditions)
+	}
+	c := status.Conditions[0]
+	if c.Type != providerconfigv1.NatGatewaysAvailable || c.Status != corev1.ConditionFalse || c.Reason != "NatGatewaysPending" {
+		t.Fatalf("unexpected condition: %+v", c)
+	}
+	for _, s := range []string{"nat-a (pending)", "nat-b (available)", "by 2018-10-01T08:05:00Z"} {
+		if !strings.Contains(c.Message, s) {
+			t.Fatalf("expected the message to contain %q, got: %q", s, c.Message)
+		}
+	}
+
+	// Gateways that take longer than usual are reported as such.
+	reconcileNatGatewayCondition(status, created.Add(10*time.Minute))
+	if c := status.Conditions[0]; !strings.Contains(c.Message, "longer than the usual") || !c.LastTransitionTime.Time.Equal(pendingSince) {
+		t.Fatalf("expected a late gateway without transition, got: %+v", c)
+	}
+
+	// The condition turns true once all gateways are available.
+	status.Network.Subnets[1].NatGatewayState = aws.String("available")
+	availableSince := created.Add(11 * time.Minute)
+	reconcileNatGatewayCondition(status, availableSince)
+	if c := status.Conditions[0]; c.Status != corev1.ConditionTrue || c.Reason != "" || c.Message != "" || !c.LastTransitionTime.Time.Equal(availableSince) {
+		t.Fatalf("expected the gateways to be available, got: %+v", c)
+	}
+
+	// Networks without NAT gateways don't have the condition.
+	status.Network.Subnets = status.Network.Subnets[:1]
+	reconcileNatGatewayCondition(status, availableSince)
+	if len(status.Conditions) != 0 {
+		t.Fatalf("expected no conditions, got: %+v", status.Conditions)
+	}
+}
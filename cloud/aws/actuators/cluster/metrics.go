@@ -0,0 +1,28 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// estimatedHourlyCost is the estimated cost of the AWS resources of each cluster.
+var estimatedHourlyCost = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "aws_cluster_estimated_hourly_cost_dollars",
+	Help: "Estimated on-demand cost per hour of the AWS resources of the cluster in US dollars.",
+}, []string{"namespace", "cluster"})
+
+func init() {
+	prometheus.MustRegister(estimatedHourlyCost)
+}
@@ -0,0 +1,205 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	providerconfigv1 "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+)
+
+// scheduleHorizon is how far pause windows are searched for the firing times of their schedules.
+// Schedules that fire less often than that are not supported.
+const scheduleHorizon = 31 * 24 * time.Hour
+
+// cronSchedule is a parsed cron expression with the fields minute, hour, day of month, month and
+// day of week. The fields are bit sets of the matching values.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// If both the day of month and the day of week are restricted, either has to match.
+	domStar, dowStar bool
+}
+
+// parseCron parses a cron expression of five fields. Fields are *, values, ranges and lists of
+// them, optionally with a step, e.g. "0 20 * * 1-5" or "*/15 8-18 * * *".
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, errors.Errorf("invalid cron expression %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	var err error
+	c := &cronSchedule{
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, errors.Wrapf(err, "invalid minute in cron expression %q", expr)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, errors.Wrapf(err, "invalid hour in cron expression %q", expr)
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, errors.Wrapf(err, "invalid day of month in cron expression %q", expr)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, errors.Wrapf(err, "invalid month in cron expression %q", expr)
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, errors.Wrapf(err, "invalid day of week in cron expression %q", expr)
+	}
+
+	// Both 0 and 7 are Sunday.
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+
+	return c, nil
+}
+
+// parseCronField returns the bit set of the values of a field between min and max.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, errors.Errorf("invalid step %q", part[i+1:])
+			}
+			step = n
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, errors.Errorf("invalid value %q", bounds[0])
+			}
+			if hi, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, errors.Errorf("invalid value %q", bounds[1])
+			}
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, errors.Errorf("invalid value %q", part)
+			}
+			lo = n
+			// A single value only extends to the maximum with a step, e.g. 5/10.
+			if step == 1 {
+				hi = n
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return 0, errors.Errorf("range %d-%d is not within %d-%d", lo, hi, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// matches returns true if the schedule fires in the minute of t.
+func (c *cronSchedule) matches(t time.Time) bool {
+	if c.minute&(1<<uint(t.Minute())) == 0 || c.hour&(1<<uint(t.Hour())) == 0 || c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// last returns the latest time the schedule fired at or before t, within the schedule horizon.
+func (c *cronSchedule) last(t time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute)
+	for end := t.Add(-scheduleHorizon); !t.Before(end); t = t.Add(-time.Minute) {
+		if c.matches(t) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// next returns the earliest time the schedule fires after t, within the schedule horizon.
+func (c *cronSchedule) next(t time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for end := t.Add(scheduleHorizon); !t.After(end); t = t.Add(time.Minute) {
+		if c.matches(t) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// pausedUntil returns whether the time is within one of the pause windows and, if so, when the
+// last of the windows it's in ends.
+func pausedUntil(windows []providerconfigv1.PauseWindow, now time.Time) (bool, time.Time, error) {
+	var paused bool
+	var until time.Time
+
+	for _, w := range windows {
+		loc := time.UTC
+		if w.TimeZone != "" {
+			var err error
+			if loc, err = time.LoadLocation(w.TimeZone); err != nil {
+				return false, time.Time{}, errors.Wrapf(err, "invalid time zone %q of pause window", w.TimeZone)
+			}
+		}
+
+		start, err := parseCron(w.Start)
+		if err != nil {
+			return false, time.Time{}, errors.Wrap(err, "invalid start of pause window")
+		}
+		end, err := parseCron(w.End)
+		if err != nil {
+			return false, time.Time{}, errors.Wrap(err, "invalid end of pause window")
+		}
+
+		local := now.In(loc)
+		lastStart, started := start.last(local)
+		if !started {
+			continue
+		}
+		// A window that starts and ends in the same minute is over.
+		if lastEnd, ended := end.last(local); ended && !lastEnd.Before(lastStart) {
+			continue
+		}
+
+		nextEnd, ok := end.next(local)
+		if !ok {
+			return false, time.Time{}, errors.Errorf("pause window starting at %q doesn't end within %v", w.Start, scheduleHorizon)
+		}
+
+		paused = true
+		if nextEnd.After(until) {
+			until = nextEnd
+		}
+	}
+
+	return paused, until, nil
+}
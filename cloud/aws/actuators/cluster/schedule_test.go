@@ -0,0 +1,132 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	providerconfigv1 "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+)
+
+func TestParseCron(t *testing.T) {
+	testCases := []struct {
+		expr    string
+		matches []string
+		misses  []string
+		invalid bool
+	}{
+		{
+			expr:    "0 20 * * 1-5",
+			matches: []string{"2018-10-01T20:00:00Z", "2018-10-05T20:00:00Z"},
+			misses:  []string{"2018-10-01T20:01:00Z", "2018-10-06T20:00:00Z", "2018-10-01T21:00:00Z"},
+		},
+		{
+			expr:    "*/15 8-18/2 * * *",
+			matches: []string{"2018-10-01T08:45:00Z", "2018-10-07T18:00:00Z"},
+			misses:  []string{"2018-10-01T09:00:00Z", "2018-10-01T08:10:00Z"},
+		},
+		{
+			// Day of month and day of week are or'ed if both are restricted.
+			expr:    "0 0 1 * 0",
+			matches: []string{"2018-10-01T00:00:00Z", "2018-10-07T00:00:00Z"},
+			misses:  []string{"2018-10-02T00:00:00Z"},
+		},
+		{
+			// 7 is Sunday.
+			expr:    "30 6 * 1,3,10 7",
+			matches: []string{"2018-10-07T06:30:00Z"},
+			misses:  []string{"2018-11-04T06:30:00Z"},
+		},
+		{expr: "0 20 * *", invalid: true},
+		{expr: "60 * * * *", invalid: true},
+		{expr: "0 5-2 * * *", invalid: true},
+		{expr: "*/0 * * * *", invalid: true},
+		{expr: "0 0 0 * *", invalid: true},
+		{expr: "a * * * *", invalid: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			c, err := parseCron(tc.expr)
+			if tc.invalid {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+
+			for _, ts := range tc.matches {
+				if !c.matches(mustParseTime(t, ts)) {
+					t.Fatalf("expected %s to match", ts)
+				}
+			}
+			for _, ts := range tc.misses {
+				if c.matches(mustParseTime(t, ts)) {
+					t.Fatalf("expected %s not to match", ts)
+				}
+			}
+		})
+	}
+}
+
+func TestPausedUntil(t *testing.T) {
+	// Paused over night on weekdays and over the weekend, in Berlin time (UTC+2 in October 2018).
+	windows := []providerconfigv1.PauseWindow{
+		{Start: "0 20 * * 1-5", End: "0 7 * * 1-5", TimeZone: "Europe/Berlin"},
+		{Start: "0 20 * * 5", End: "0 7 * * 1", TimeZone: "Europe/Berlin"},
+	}
+
+	testCases := []struct {
+		now    string
+		paused bool
+		until  string
+	}{
+		{now: "2018-10-01T12:00:00Z"},
+		{now: "2018-10-01T18:30:00Z", paused: true, until: "2018-10-02T05:00:00Z"},
+		{now: "2018-10-02T05:00:00Z"},
+		{now: "2018-10-06T12:00:00Z", paused: true, until: "2018-10-08T05:00:00Z"},
+		{now: "2018-10-05T19:00:00Z", paused: true, until: "2018-10-08T05:00:00Z"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.now, func(t *testing.T) {
+			paused, until, err := pausedUntil(windows, mustParseTime(t, tc.now))
+			if err != nil {
+				t.Fatalf("failed to evaluate pause windows: %v", err)
+			}
+			if paused != tc.paused {
+				t.Fatalf("expected paused to be %v, got: %v", tc.paused, paused)
+			}
+			if paused && !until.Equal(mustParseTime(t, tc.until)) {
+				t.Fatalf("expected to be paused until %s, got: %s", tc.until, until.UTC())
+			}
+		})
+	}
+
+	if _, _, err := pausedUntil([]providerconfigv1.PauseWindow{{Start: "0 20 * * *", End: "0 7 * * *", TimeZone: "Nowhere/Special"}}, time.Now()); err == nil {
+		t.Fatalf("expected an invalid time zone to be rejected")
+	}
+}
+
+func mustParseTime(t *testing.T, s string) time.Time {
+	ts, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatalf("invalid time %q: %v", s, err)
+	}
+	return ts
+}
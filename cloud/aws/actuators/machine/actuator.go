@@ -15,26 +15,28 @@ package machine
 
 // should not need to import the ec2 sdk here
 import (
+	"context"
 	"fmt"
+	"time"
 
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/audit"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/logger"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/policy"
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services"
 	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+	iamsvc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/iam"
 
-	"github.com/golang/glog"
+	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
 	client "sigs.k8s.io/cluster-api/pkg/client/clientset_generated/clientset/typed/cluster/v1alpha1"
 )
 
-// ec2Svc are the functions from the ec2 service, not the client, this actuator needs.
-// This should never need to import the ec2 sdk.
-type ec2Svc interface {
-	CreateInstance(*clusterv1.Machine) (*ec2svc.Instance, error)
-	InstanceIfExists(*string) (*ec2svc.Instance, error)
-	TerminateInstance(*string) error
-}
-
 // codec are the functions off the generated codec that this actuator uses.
 type codec interface {
 	DecodeFromProviderConfig(clusterv1.ProviderConfig, runtime.Object) error
@@ -47,8 +49,41 @@ type Actuator struct {
 	codec codec
 
 	// Services
-	ec2            ec2Svc
-	machinesGetter client.MachinesGetter
+	ec2              services.EC2Interface
+	ec2For           func(clusterName string) services.EC2Interface
+	instanceProfiles services.InstanceProfilesInterface
+	instanceShutdown services.InstanceShutdownInterface
+	machinesGetter   client.MachinesGetter
+
+	recorder record.EventRecorder
+
+	log logr.Logger
+	now func() time.Time
+
+	reconcileTimeout time.Duration
+	defaults         Defaults
+	policy           policy.Checker
+
+	requireChangeApproval bool
+}
+
+// Defaults are the defaults of machine provider configs, applied to the fields they don't set,
+// so that organization wide settings don't have to be repeated in every machine.
+type Defaults struct {
+	// InstanceType is the instance type of machines without one.
+	InstanceType string
+	// RootDeviceSize is the root volume size in GiB of machines without one.
+	RootDeviceSize int64
+}
+
+// apply sets the fields of the config that aren't set to the defaults.
+func (d Defaults) apply(config *v1alpha1.AWSMachineProviderConfig) {
+	if config.InstanceType == "" {
+		config.InstanceType = d.InstanceType
+	}
+	if config.RootDeviceSize == 0 {
+		config.RootDeviceSize = d.RootDeviceSize
+	}
 }
 
 // ActuatorParams holds parameter information for Actuator
@@ -61,24 +96,91 @@ type ActuatorParams struct {
 	// ClusterService is the interface to cluster-api.
 	MachinesGetter client.MachinesGetter
 	// EC2Service is the interface to ec2.
-	EC2Service ec2Svc
+	EC2Service services.EC2Interface
+	// EC2ServiceFor returns the EC2 service to use for a cluster, like one whose API calls are
+	// rate limited per cluster. If nil, EC2Service is used for all clusters.
+	EC2ServiceFor func(clusterName string) services.EC2Interface
+	// InstanceProfilesService checks that the instance profiles of instances exist before they
+	// are launched. If nil, they aren't checked.
+	InstanceProfilesService services.InstanceProfilesInterface
+	// InstanceShutdownService shuts down the operating system of the instances of deleted machines
+	// with a graceful shutdown timeout before they are terminated. If nil, instances are
+	// terminated right away.
+	InstanceShutdownService services.InstanceShutdownInterface
+
+	// EventRecorder records the events of machines, like their changes that apply once their
+	// instance is replaced. If nil, no events are recorded.
+	EventRecorder record.EventRecorder
+
+	// Logger is the base logger for the actuator. If nil, a default text logger is used.
+	Logger logr.Logger
+
+	// Clock returns the current time, which node join timeouts are checked at. If nil, time.Now is used.
+	Clock func() time.Time
+	// ReconcileTimeout is how long the AWS calls of a single reconcile may take before they are
+	// cancelled. If zero, they aren't.
+	ReconcileTimeout time.Duration
+
+	// Defaults are applied to the provider configs of the machines.
+	Defaults Defaults
+
+	// Policy checks instances before they are launched. If nil, every instance is launched.
+	Policy policy.Checker
+
+	// RequireChangeApproval keeps the instances of machines whose config changed since they were
+	// launched, in fields that only take effect on a new instance, from being replaced after the
+	// node join timeout or the impaired instance timeout until the changes are approved with the
+	// approve changes annotation.
+	RequireChangeApproval bool
 }
 
 // NewActuator returns an actuator.
 func NewActuator(params ActuatorParams) (*Actuator, error) {
+	log := params.Logger
+	if log == nil {
+		log = logger.Default()
+	}
+
+	now := params.Clock
+	if now == nil {
+		now = time.Now
+	}
+
 	return &Actuator{
-		codec:          params.Codec,
-		ec2:            params.EC2Service,
-		machinesGetter: params.MachinesGetter,
+		codec:            params.Codec,
+		ec2:              params.EC2Service,
+		ec2For:           params.EC2ServiceFor,
+		instanceProfiles: params.InstanceProfilesService,
+		instanceShutdown: params.InstanceShutdownService,
+		machinesGetter:   params.MachinesGetter,
+		recorder:         params.EventRecorder,
+		log:              log.WithName("machine-actuator"),
+		now:              now,
+		reconcileTimeout: params.ReconcileTimeout,
+		defaults:         params.Defaults,
+		policy:           params.Policy,
+
+		requireChangeApproval: params.RequireChangeApproval,
 	}, nil
 }
 
 // Create creates a machine and is invoked by the machine controller.
 func (a *Actuator) Create(cluster *clusterv1.Cluster, machine *clusterv1.Machine) error {
+	log := a.machineLogger(cluster, machine)
+	log.Info("Creating machine")
+	a = a.forCluster(cluster.Name)
+	ctx, cancel := a.reconcileContext(cluster.Name)
+	defer cancel()
+
 	// will need this machine config in a bit
-	_, err := a.machineProviderConfig(machine.Spec.ProviderConfig)
+	config, err := a.machineProviderConfig(cluster, machine)
+	if err != nil {
+		log.Error(err, "Failed to decode the machine provider config")
+		return err
+	}
+
+	tags, err := a.instanceTags(cluster, machine, config)
 	if err != nil {
-		glog.Errorf("Failed to decode the machine provider config: %v", err)
 		return err
 	}
 
@@ -88,33 +190,102 @@ func (a *Actuator) Create(cluster *clusterv1.Cluster, machine *clusterv1.Machine
 		return err
 	}
 
+	// Adopted instances exist already, only instances about to be launched are checked.
+	if config.InstanceID == nil {
+		if err := a.checkPolicy(cluster, machine, config, tags, status); err != nil {
+			return err
+		}
+	}
+
 	// does the instance exist with a valid status? we're good
 	// otherwise create it and move on.
-	_, err = a.ec2.InstanceIfExists(status.InstanceID)
+	_, err = a.ec2.InstanceIfExists(ctx, status.InstanceID)
 	if err != nil {
 		return err
 	}
 
-	i, err := a.ec2.CreateInstance(machine)
-	if err != nil {
-		return err
+	var i *ec2svc.Instance
+	if config.InstanceID != nil {
+		i, err = a.ec2.AdoptInstance(ctx, cluster.Name, *config.InstanceID, tags)
+		if err != nil {
+			return errors.Wrap(err, "failed to adopt instance")
+		}
+
+		log.Info("Machine adopted", "instance-id", i.ID, "instance-state", i.State)
+		status.LaunchSpec = nil
+	} else {
+		// The token changes with the instance the new one replaces, if any, so that a retry
+		// after a timeout returns the instance of the first request instead of running another.
+		var replaced string
+		if status.InstanceID != nil {
+			replaced = *status.InstanceID
+		}
+		if err := a.waitForInstanceProfile(ctx, log, config); err != nil {
+			return err
+		}
+
+		// The changes the replaced instance was reported with take effect now.
+		spec := launchSpec(config)
+		if status.LaunchSpec != nil {
+			if changes, err := launchSpecChanges(status.LaunchSpec, spec); err == nil && len(changes) > 0 {
+				log.Info("Launching instance with changes", "replaced-instance-id", replaced, "changes", changes)
+			}
+		}
+
+		token := ec2svc.ClientToken(string(machine.UID), "instance", replaced)
+		i, err = a.ec2.CreateInstance(ctx, cluster.Name, token, tags, machine, config)
+		if err != nil {
+			return a.createFailed(log, machine, status, err)
+		}
+
+		log.Info("Machine created", "instance-id", i.ID, "instance-state", i.State)
+		status.LaunchSpec = spec
+		if i.SubnetID != "" {
+			log.Info("Instance placed", "subnet-id", i.SubnetID, "availability-zone", i.AvailabilityZone)
+			status.SubnetID = &i.SubnetID
+			status.AvailabilityZone = &i.AvailabilityZone
+		}
 	}
 
 	status.InstanceID = &i.ID
 	status.InstanceState = &i.State
-	return a.updateStatus(machine, status)
+	removeCondition(status, v1alpha1.PendingChanges)
+	if i.LaunchTemplate != nil {
+		status.LaunchTemplateID = &i.LaunchTemplate.ID
+		status.LaunchTemplateVersion = &i.LaunchTemplate.Version
+	}
+	clearCreateError(machine)
+	if err := a.updateStatus(machine, status); err != nil {
+		return err
+	}
+
+	// Replace the instance taken from the warm pool, if any.
+	if err := a.ec2.ReconcileWarmPool(ctx, cluster.Name, machine, config); err != nil {
+		return errors.Wrap(err, "failed to reconcile warm pool")
+	}
+
+	return nil
 }
 
 // Delete deletes a machine and is invoked by the Machine Controller
 func (a *Actuator) Delete(cluster *clusterv1.Cluster, machine *clusterv1.Machine) error {
-	glog.Infof("Deleting machine %v for cluster %v.", machine.Name, cluster.Name)
+	log := a.machineLogger(cluster, machine)
+	log.Info("Deleting machine")
+	a = a.forCluster(cluster.Name)
+	ctx, cancel := a.reconcileContext(cluster.Name)
+	defer cancel()
+
+	if _, ok := machine.Annotations[v1alpha1.PivotedAnnotation]; ok {
+		log.Info("Machine was moved to another management cluster, keeping its instance")
+		return nil
+	}
 
 	status, err := a.machineProviderStatus(machine)
 	if err != nil {
 		return errors.Wrap(err, "failed to get machine provider status")
 	}
 
-	instance, err := a.ec2.InstanceIfExists(status.InstanceID)
+	instance, err := a.ec2.InstanceIfExists(ctx, status.InstanceID)
 	if err != nil {
 		return errors.Wrap(err, "failed to get instance")
 	}
@@ -130,9 +301,20 @@ func (a *Actuator) Delete(cluster *clusterv1.Cluster, machine *clusterv1.Machine
 	// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/ec2-instance-lifecycle.html
 	switch instance.State {
 	case ec2svc.InstanceStateShuttingDown, ec2svc.InstanceStateTerminated:
+		log.V(2).Info("Instance is already terminating", "instance-id", instance.ID, "instance-state", instance.State)
 		return nil
 	default:
-		err = a.ec2.TerminateInstance(status.InstanceID)
+		if a.instanceShutdown != nil {
+			config, err := a.machineProviderConfig(cluster, machine)
+			if err != nil {
+				// A broken config mustn't keep the machine from being deleted.
+				log.Error(err, "Failed to decode the machine provider config, terminating instance without shutting it down")
+			} else if ok, err := a.shutdownGracefully(ctx, log, machine, config, status, instance); !ok {
+				return err
+			}
+		}
+
+		err = a.ec2.TerminateInstance(ctx, status.InstanceID)
 		if err != nil {
 			return errors.Wrap(err, "failed to terminate instance")
 		}
@@ -143,12 +325,20 @@ func (a *Actuator) Delete(cluster *clusterv1.Cluster, machine *clusterv1.Machine
 
 // Update updates a machine and is invoked by the Machine Controller
 func (a *Actuator) Update(cluster *clusterv1.Cluster, machine *clusterv1.Machine) error {
-	glog.Infof("Updating machine %v for cluster %v.", machine.Name, cluster.Name)
+	log := a.machineLogger(cluster, machine)
+	log.Info("Updating machine")
+	a = a.forCluster(cluster.Name)
+	ctx, cancel := a.reconcileContext(cluster.Name)
+	defer cancel()
 
-	// Handling of machine config changes is not yet implemented.
+	// Handling of most machine config changes is not yet implemented.
 	// We should check which pieces of configuration have been updated, throw
 	// errors if an attempt is made to modify any immutable state, otherwise
 	// go ahead and modify what we can.
+	config, err := a.machineProviderConfig(cluster, machine)
+	if err != nil {
+		return errors.Wrap(err, "failed to decode the machine provider config")
+	}
 
 	// Get the new status from the provided machine object.
 	status, err := a.machineProviderStatus(machine)
@@ -156,6 +346,77 @@ func (a *Actuator) Update(cluster *clusterv1.Cluster, machine *clusterv1.Machine
 		return errors.Wrap(err, "failed to get machine status")
 	}
 
+	instance, err := a.ec2.InstanceIfExists(ctx, status.InstanceID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance")
+	}
+
+	// Additional tags are always safe to update.
+	if instance != nil {
+		tags, err := a.instanceTags(cluster, machine, config)
+		if err != nil {
+			return err
+		}
+
+		err = a.ec2.ReconcileInstanceTags(ctx, instance, tags)
+		if ec2svc.IsConflict(errors.Cause(err)) {
+			// Another management cluster manages the instance, fighting over it would only
+			// undo each other's changes.
+			setCondition(status, v1alpha1.AWSMachineProviderCondition{
+				Type:    v1alpha1.ManagerConflict,
+				Status:  corev1.ConditionTrue,
+				Reason:  "ManagedElsewhere",
+				Message: err.Error(),
+			}, metav1.NewTime(a.now()))
+			if err := a.updateStatus(machine, status); err != nil {
+				return errors.Wrap(err, "failed to update machine status")
+			}
+			return errors.Wrap(err, "failed to reconcile instance tags")
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to reconcile instance tags")
+		}
+		removeCondition(status, v1alpha1.ManagerConflict)
+
+		// Root volumes are grown in place, so that disk pressure doesn't need a new machine.
+		if err := a.ec2.ReconcileRootVolume(ctx, instance.ID, config.RootDeviceSize); err != nil {
+			return errors.Wrap(err, "failed to reconcile root volume")
+		}
+
+		if err := a.reconcilePendingChanges(log, machine, config, status); err != nil {
+			return errors.Wrap(err, "failed to reconcile pending changes")
+		}
+
+		// Diagnostics are best effort, they don't hold up the machine.
+		if err := a.collectDiagnostics(ctx, log, machine, config, instance, status); err != nil {
+			log.Error(err, "Failed to collect diagnostics")
+		}
+
+		if err := a.remediateNodeJoin(ctx, log, machine, config, instance, status); err != nil {
+			return err
+		}
+
+		if err := a.reconcileHealth(ctx, log, config, instance, status); err != nil {
+			return err
+		}
+	}
+
+	// Instances of the warm pool are launched for the machine, the policy of the machine applies.
+	if config.WarmPoolSize > 0 {
+		tags, err := a.instanceTags(cluster, machine, config)
+		if err != nil {
+			return err
+		}
+		if err := a.checkPolicy(cluster, machine, config, tags, status); err != nil {
+			return err
+		}
+	}
+
+	// Instances of the warm pool are stopped once they are running.
+	if err := a.ec2.ReconcileWarmPool(ctx, cluster.Name, machine, config); err != nil {
+		return errors.Wrap(err, "failed to reconcile warm pool")
+	}
+
 	err = a.updateStatus(machine, status)
 	if err != nil {
 		return errors.Wrap(err, "failed to update machine status")
@@ -166,13 +427,16 @@ func (a *Actuator) Update(cluster *clusterv1.Cluster, machine *clusterv1.Machine
 
 // Exists test for the existence of a machine and is invoked by the Machine Controller
 func (a *Actuator) Exists(cluster *clusterv1.Cluster, machine *clusterv1.Machine) (bool, error) {
-	glog.Infof("Checking if machine %v for cluster %v exists.", machine.Name, cluster.Name)
+	a.machineLogger(cluster, machine).V(2).Info("Checking if machine exists")
+	a = a.forCluster(cluster.Name)
+	ctx, cancel := a.reconcileContext(cluster.Name)
+	defer cancel()
 	status, err := a.machineProviderStatus(machine)
 	if err != nil {
 		return false, err
 	}
 
-	instance, err := a.ec2.InstanceIfExists(status.InstanceID)
+	instance, err := a.ec2.InstanceIfExists(ctx, status.InstanceID)
 	if err != nil {
 		return false, err
 	}
@@ -180,18 +444,169 @@ func (a *Actuator) Exists(cluster *clusterv1.Cluster, machine *clusterv1.Machine
 		return false, nil
 	}
 	// TODO update status here
+	// Instances of hibernated clusters are stopped, but still exist.
 	switch instance.State {
-	case ec2svc.InstanceStateRunning, ec2svc.InstanceStatePending:
+	case ec2svc.InstanceStateRunning, ec2svc.InstanceStatePending, ec2svc.InstanceStateStopping, ec2svc.InstanceStateStopped:
 		return true, nil
 	default:
 		return false, nil
 	}
 }
 
-func (a *Actuator) machineProviderConfig(providerConfig clusterv1.ProviderConfig) (*v1alpha1.AWSMachineProviderConfig, error) {
+// reconcileContext returns the context of a single reconcile of the cluster, the AWS calls of the
+// reconcile are cancelled once the reconcile timeout passed so that a hanging call fails the
+// reconcile, and are audited for the cluster.
+func (a *Actuator) reconcileContext(clusterName string) (context.Context, context.CancelFunc) {
+	ctx := audit.WithCluster(context.Background(), clusterName)
+	if a.reconcileTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, a.reconcileTimeout)
+}
+
+// forCluster returns a copy of the actuator that uses the EC2 service of the cluster.
+func (a *Actuator) forCluster(clusterName string) *Actuator {
+	if a.ec2For == nil {
+		return a
+	}
+	c := *a
+	c.ec2 = a.ec2For(clusterName)
+	return &c
+}
+
+// machineLogger returns a logger carrying the cluster and machine as context.
+func (a *Actuator) machineLogger(cluster *clusterv1.Cluster, machine *clusterv1.Machine) logr.Logger {
+	return a.log.WithValues("cluster", cluster.Name, "machine", machine.Name, "namespace", machine.Namespace)
+}
+
+// instanceTags returns the additional tags of the cluster and the machine, rendered for the machine.
+// Tags of the machine override the ones of the cluster. The role of the machine is always tagged, so
+// that instances created before it was are tagged on their next update.
+func (a *Actuator) instanceTags(cluster *clusterv1.Cluster, machine *clusterv1.Machine, config *v1alpha1.AWSMachineProviderConfig) (map[string]string, error) {
+	clusterConfig, err := a.clusterProviderConfig(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string)
+	for k, v := range clusterConfig.AdditionalTags {
+		tags[k] = v
+	}
+	for k, v := range config.AdditionalTags {
+		tags[k] = v
+	}
+
+	rendered, err := ec2svc.RenderTags(tags, ec2svc.TagTemplateData{ClusterName: cluster.Name, MachineName: machine.Name})
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid additional tags")
+	}
+
+	rendered[ec2svc.TagNameRole] = ec2svc.RoleNode
+	if machine.Spec.Versions.ControlPlane != "" {
+		rendered[ec2svc.TagNameRole] = ec2svc.RoleControlPlane
+	}
+	return rendered, nil
+}
+
+// checkPolicy checks the instance of the machine against the policy before it's launched. A
+// violation is recorded as a condition in the status of the machine and returned.
+func (a *Actuator) checkPolicy(cluster *clusterv1.Cluster, machine *clusterv1.Machine, config *v1alpha1.AWSMachineProviderConfig, tags map[string]string, status *v1alpha1.AWSMachineProviderStatus) error {
+	if a.policy == nil {
+		return nil
+	}
+
+	clusterConfig, err := a.clusterProviderConfig(cluster)
+	if err != nil {
+		return err
+	}
+
+	err = a.policy.Check(policy.Resource{
+		Kind:         policy.KindInstance,
+		ClusterName:  cluster.Name,
+		Tags:         tags,
+		InstanceType: config.InstanceType,
+		Cluster:      clusterConfig,
+		Machine:      config,
+	})
+	if err == nil {
+		removeCondition(status, v1alpha1.PolicyViolation)
+		return nil
+	}
+
+	setCondition(status, v1alpha1.AWSMachineProviderCondition{
+		Type:    v1alpha1.PolicyViolation,
+		Status:  corev1.ConditionTrue,
+		Reason:  "Denied",
+		Message: err.Error(),
+	}, metav1.NewTime(a.now()))
+	if err := a.updateStatus(machine, status); err != nil {
+		return errors.Wrap(err, "failed to update machine status")
+	}
+	return errors.Wrap(err, "machine violates the policy")
+}
+
+// waitForInstanceProfile waits for the instance profile of the machine, if any, to exist before
+// its instance is launched, so that the instance isn't launched while IAM is still creating it,
+// like the instance profiles of node roles on the first reconciles of a cluster.
+func (a *Actuator) waitForInstanceProfile(ctx context.Context, log logr.Logger, config *v1alpha1.AWSMachineProviderConfig) error {
+	profile := config.IAMInstanceProfile
+	if a.instanceProfiles == nil || profile == nil {
+		return nil
+	}
+
+	var ref string
+	switch {
+	case profile.ARN != nil:
+		ref = *profile.ARN
+	case profile.ID != nil:
+		ref = *profile.ID
+	default:
+		return nil
+	}
+
+	if err := a.instanceProfiles.WaitForInstanceProfile(ctx, ref); err != nil {
+		log.Info("Instance profile isn't available, requeuing", "instance-profile", ref, "reason", err)
+		return errors.Wrap(err, "failed to check instance profile")
+	}
+	return nil
+}
+
+// machineProviderConfig returns the provider config of the machine. Fields it doesn't set are
+// set by the preset of the cluster, then by the defaults of the actuator.
+func (a *Actuator) machineProviderConfig(cluster *clusterv1.Cluster, machine *clusterv1.Machine) (*v1alpha1.AWSMachineProviderConfig, error) {
 	machineProviderCfg := &v1alpha1.AWSMachineProviderConfig{}
-	err := a.codec.DecodeFromProviderConfig(providerConfig, machineProviderCfg)
-	return machineProviderCfg, err
+	if err := a.codec.DecodeFromProviderConfig(machine.Spec.ProviderConfig, machineProviderCfg); err != nil {
+		return nil, err
+	}
+
+	clusterConfig, err := a.clusterProviderConfig(cluster)
+	if err != nil {
+		return nil, err
+	}
+	if err := machineProviderCfg.ApplyClusterPreset(clusterConfig); err != nil {
+		return nil, errors.Wrap(err, "invalid cluster preset")
+	}
+
+	// Machines of clusters with node roles get the instance profile of their kind.
+	if clusterConfig.NodeRoles && machineProviderCfg.IAMInstanceProfile == nil {
+		name := iamsvc.InstanceProfileName(cluster.Name, machine.Spec.Versions.ControlPlane != "")
+		machineProviderCfg.IAMInstanceProfile = &v1alpha1.AWSResourceReference{ID: &name}
+	}
+
+	if clusterConfig.FIPS {
+		machineProviderCfg.RootVolumeEncrypted = true
+	}
+
+	a.defaults.apply(machineProviderCfg)
+	return machineProviderCfg, nil
+}
+
+func (a *Actuator) clusterProviderConfig(cluster *clusterv1.Cluster) (*v1alpha1.AWSClusterProviderConfig, error) {
+	clusterConfig := &v1alpha1.AWSClusterProviderConfig{}
+	if err := a.codec.DecodeFromProviderConfig(cluster.Spec.ProviderConfig, clusterConfig); err != nil {
+		return nil, errors.Wrap(err, "failed to decode the cluster provider config")
+	}
+	return clusterConfig, nil
 }
 
 func (a *Actuator) machineProviderStatus(machine *clusterv1.Machine) (*v1alpha1.AWSMachineProviderStatus, error) {
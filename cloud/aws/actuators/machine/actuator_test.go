@@ -14,23 +14,91 @@
 package machine_test
 
 import (
-	"errors"
+	"context"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/cluster-api/pkg/apis/cluster/common"
 	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
 	clientv1 "sigs.k8s.io/cluster-api/pkg/client/clientset_generated/clientset/typed/cluster/v1alpha1"
+	controllerError "sigs.k8s.io/cluster-api/pkg/controller/error"
 
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/actuators/machine"
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/actuators/machine/mock_machineiface"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/policy"
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
 	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/fake"
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/mock_ec2iface"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/mock_services"
 )
 
+// nodeTags are the tags of the instances of node machines of a cluster without a name.
+var nodeTags = []*ec2.Tag{
+	{Key: aws.String("kubernetes.io/cluster/"), Value: aws.String("owned")},
+	{Key: aws.String("sigs.k8s.io/cluster-api-provider-aws/managed-tags"), Value: aws.String("sigs.k8s.io/cluster-api-provider-aws/role")},
+	{Key: aws.String("sigs.k8s.io/cluster-api-provider-aws/role"), Value: aws.String("node")},
+}
+
+// clusterTagSpecifications are the tag specifications for instances of node machines of a cluster
+// without a name.
+var clusterTagSpecifications = []*ec2.TagSpecification{
+	{
+		ResourceType: aws.String("instance"),
+		Tags:         nodeTags,
+	},
+	{
+		ResourceType: aws.String("volume"),
+		Tags:         nodeTags,
+	},
+}
+
+// expectLaunchTemplate expects the launch template of a machine without a name to be created.
+func expectLaunchTemplate(me *mock_ec2iface.MockEC2API, id string) {
+	me.EXPECT().
+		DescribeLaunchTemplatesWithContext(gomock.Any(), gomock.AssignableToTypeOf(&ec2.DescribeLaunchTemplatesInput{})).
+		Return(&ec2.DescribeLaunchTemplatesOutput{}, nil)
+	me.EXPECT().
+		CreateLaunchTemplateWithContext(gomock.Any(), gomock.AssignableToTypeOf(&ec2.CreateLaunchTemplateInput{})).
+		Return(&ec2.CreateLaunchTemplateOutput{
+			LaunchTemplate: &ec2.LaunchTemplate{
+				LaunchTemplateId:    aws.String(id),
+				LatestVersionNumber: aws.Int64(1),
+			},
+		}, nil)
+	me.EXPECT().
+		CreateTagsWithContext(gomock.Any(), &ec2.CreateTagsInput{
+			Resources: aws.StringSlice([]string{id}),
+			Tags:      []*ec2.Tag{{Key: aws.String("kubernetes.io/cluster/"), Value: aws.String("owned")}},
+		}).
+		Return(&ec2.CreateTagsOutput{}, nil)
+}
+
+// runInstancesInput is the input to run an instance from the first version of a launch template.
+func runInstancesInput(launchTemplateID string) *ec2.RunInstancesInput {
+	return &ec2.RunInstancesInput{
+		// The machines of these tests have neither a uid nor an instance to replace.
+		ClientToken: aws.String(ec2svc.ClientToken("", "instance", "")),
+		LaunchTemplate: &ec2.LaunchTemplateSpecification{
+			LaunchTemplateId: aws.String(launchTemplateID),
+			Version:          aws.String("1"),
+		},
+		MinCount:          aws.Int64(1),
+		MaxCount:          aws.Int64(1),
+		TagSpecifications: clusterTagSpecifications,
+	}
+}
+
 type machinesGetter struct {
 	mi *mock_machineiface.MockMachineInterface
 }
@@ -52,7 +120,7 @@ func TestCreate(t *testing.T) {
 		UpdateStatus(&clusterv1.Machine{
 			Status: clusterv1.MachineStatus{
 				ProviderStatus: &runtime.RawExtension{
-					Raw: []byte(`{"kind":"AWSMachineProviderStatus","apiVersion":"awsproviderconfig/v1alpha1","instanceID":"1234","instanceState":"running"}
+					Raw: []byte(`{"kind":"AWSMachineProviderStatus","apiVersion":"awsproviderconfig/v1alpha1","instanceID":"1234","instanceState":"running","launchTemplateID":"lt-1","launchTemplateVersion":1,"launchSpec":{"ami":{},"instanceType":""}}
 `),
 				},
 			},
@@ -60,13 +128,9 @@ func TestCreate(t *testing.T) {
 		Return(&clusterv1.Machine{}, nil)
 
 	// ec2 calls
+	expectLaunchTemplate(me, "lt-1")
 	me.EXPECT().
-		DescribeInstances(&ec2.DescribeInstancesInput{
-			InstanceIds: []*string{nil},
-		}).
-		Return(nil, ec2svc.NewNotFound(errors.New("")))
-	me.EXPECT().
-		RunInstances(&ec2.RunInstancesInput{}).
+		RunInstancesWithContext(gomock.Any(), runInstancesInput("lt-1")).
 		Return(&ec2.Reservation{
 			Instances: []*ec2.Instance{
 				&ec2.Instance{
@@ -85,9 +149,7 @@ func TestCreate(t *testing.T) {
 	ap := machine.ActuatorParams{
 		Codec:          codec,
 		MachinesGetter: mg,
-		EC2Service: &ec2svc.Service{
-			EC2: me,
-		},
+		EC2Service:     ec2svc.NewService(me),
 	}
 	actuator, err := machine.NewActuator(ap)
 	if err != nil {
@@ -99,6 +161,430 @@ func TestCreate(t *testing.T) {
 	}
 }
 
+func TestCreateAdopted(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mg := &machinesGetter{
+		mi: mock_machineiface.NewMockMachineInterface(mockCtrl),
+	}
+	me := mock_ec2iface.NewMockEC2API(mockCtrl)
+	defer mockCtrl.Finish()
+
+	codec, err := v1alpha1.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create a codec: %v", err)
+	}
+
+	providerConfig, err := codec.EncodeToProviderConfig(&v1alpha1.AWSMachineProviderConfig{InstanceID: aws.String("i-adopted")})
+	if err != nil {
+		t.Fatalf("failed to encode the provider config: %v", err)
+	}
+
+	mg.mi.EXPECT().
+		UpdateStatus(gomock.AssignableToTypeOf(&clusterv1.Machine{})).
+		Return(&clusterv1.Machine{}, nil)
+
+	// The instance is tagged instead of being created.
+	me.EXPECT().
+		DescribeInstancesWithContext(gomock.Any(), &ec2.DescribeInstancesInput{
+			InstanceIds: aws.StringSlice([]string{"i-adopted"}),
+		}).
+		Return(&ec2.DescribeInstancesOutput{
+			Reservations: []*ec2.Reservation{{
+				Instances: []*ec2.Instance{{
+					State:      &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+					InstanceId: aws.String("i-adopted"),
+				}},
+			}},
+		}, nil)
+	me.EXPECT().
+		CreateTagsWithContext(gomock.Any(), &ec2.CreateTagsInput{
+			Resources: aws.StringSlice([]string{"i-adopted"}),
+			Tags:      nodeTags,
+		}).
+		Return(&ec2.CreateTagsOutput{}, nil)
+
+	ap := machine.ActuatorParams{
+		Codec:          codec,
+		MachinesGetter: mg,
+		EC2Service:     ec2svc.NewService(me),
+	}
+	actuator, err := machine.NewActuator(ap)
+	if err != nil {
+		t.Fatalf("failed to create an actuator: %v", err)
+	}
+
+	m := &clusterv1.Machine{Spec: clusterv1.MachineSpec{ProviderConfig: *providerConfig}}
+	if err := actuator.Create(&clusterv1.Cluster{}, m); err != nil {
+		t.Fatalf("failed to create machine: %v", err)
+	}
+}
+
+func TestCreateDefaults(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mg := &machinesGetter{
+		mi: mock_machineiface.NewMockMachineInterface(mockCtrl),
+	}
+	defer mockCtrl.Finish()
+
+	codec, err := v1alpha1.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create a codec: %v", err)
+	}
+
+	providerConfig, err := codec.EncodeToProviderConfig(&v1alpha1.AWSMachineProviderConfig{
+		AMI: v1alpha1.AWSResourceReference{ID: aws.String("ami-1")},
+	})
+	if err != nil {
+		t.Fatalf("failed to encode the provider config: %v", err)
+	}
+
+	mg.mi.EXPECT().
+		UpdateStatus(gomock.AssignableToTypeOf(&clusterv1.Machine{})).
+		Return(&clusterv1.Machine{}, nil)
+
+	f := fake.New()
+	actuator, err := machine.NewActuator(machine.ActuatorParams{
+		Codec:          codec,
+		MachinesGetter: mg,
+		EC2Service:     ec2svc.NewService(f),
+		Defaults:       machine.Defaults{InstanceType: "m5.large", RootDeviceSize: 40},
+	})
+	if err != nil {
+		t.Fatalf("failed to create an actuator: %v", err)
+	}
+
+	// The machine replaces an earlier instance, which is looked up first.
+	replaced, err := f.RunInstancesWithContext(context.TODO(), &ec2.RunInstancesInput{ImageId: aws.String("ami-1"), MinCount: aws.Int64(1), MaxCount: aws.Int64(1)})
+	if err != nil {
+		t.Fatalf("failed to run instance: %v", err)
+	}
+	providerStatus, err := codec.EncodeProviderStatus(&v1alpha1.AWSMachineProviderStatus{InstanceID: replaced.Instances[0].InstanceId})
+	if err != nil {
+		t.Fatalf("failed to encode provider status: %v", err)
+	}
+
+	m := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine-1"},
+		Spec:       clusterv1.MachineSpec{ProviderConfig: *providerConfig},
+		Status:     clusterv1.MachineStatus{ProviderStatus: providerStatus},
+	}
+	if err := actuator.Create(&clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}}, m); err != nil {
+		t.Fatalf("failed to create machine: %v", err)
+	}
+
+	out, err := f.DescribeLaunchTemplateVersionsWithContext(context.TODO(), &ec2.DescribeLaunchTemplateVersionsInput{
+		LaunchTemplateName: aws.String("test-machine-1"),
+		Versions:           aws.StringSlice([]string{"$Latest"}),
+	})
+	if err != nil {
+		t.Fatalf("failed to describe launch template versions: %v", err)
+	}
+	data := out.LaunchTemplateVersions[0].LaunchTemplateData
+	if aws.StringValue(data.InstanceType) != "m5.large" || len(data.BlockDeviceMappings) != 1 || aws.Int64Value(data.BlockDeviceMappings[0].Ebs.VolumeSize) != 40 {
+		t.Fatalf("expected the defaults to be applied to the launch template, got: %v", data)
+	}
+}
+
+func TestCreateNodeRoles(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mg := &machinesGetter{
+		mi: mock_machineiface.NewMockMachineInterface(mockCtrl),
+	}
+	defer mockCtrl.Finish()
+
+	codec, err := v1alpha1.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create a codec: %v", err)
+	}
+
+	clusterConfig, err := codec.EncodeToProviderConfig(&v1alpha1.AWSClusterProviderConfig{NodeRoles: true})
+	if err != nil {
+		t.Fatalf("failed to encode the cluster provider config: %v", err)
+	}
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec:       clusterv1.ClusterSpec{ProviderConfig: *clusterConfig},
+	}
+
+	testCases := []struct {
+		name     string
+		versions clusterv1.MachineVersionInfo
+		profile  *v1alpha1.AWSResourceReference
+		expected string
+	}{
+		{name: "control-plane", versions: clusterv1.MachineVersionInfo{Kubelet: "v1.11.2", ControlPlane: "v1.11.2"}, expected: "test-control-plane"},
+		{name: "node", versions: clusterv1.MachineVersionInfo{Kubelet: "v1.11.2"}, expected: "test-nodes"},
+		{name: "own-profile", versions: clusterv1.MachineVersionInfo{Kubelet: "v1.11.2"}, profile: &v1alpha1.AWSResourceReference{ID: aws.String("custom")}, expected: "custom"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerConfig, err := codec.EncodeToProviderConfig(&v1alpha1.AWSMachineProviderConfig{
+				AMI:                v1alpha1.AWSResourceReference{ID: aws.String("ami-1")},
+				IAMInstanceProfile: tc.profile,
+			})
+			if err != nil {
+				t.Fatalf("failed to encode the provider config: %v", err)
+			}
+
+			mg.mi.EXPECT().
+				UpdateStatus(gomock.AssignableToTypeOf(&clusterv1.Machine{})).
+				Return(&clusterv1.Machine{}, nil)
+
+			f := fake.New()
+			actuator, err := machine.NewActuator(machine.ActuatorParams{
+				Codec:          codec,
+				MachinesGetter: mg,
+				EC2Service:     ec2svc.NewService(f),
+			})
+			if err != nil {
+				t.Fatalf("failed to create an actuator: %v", err)
+			}
+
+			m := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Name: tc.name},
+				Spec:       clusterv1.MachineSpec{ProviderConfig: *providerConfig, Versions: tc.versions},
+			}
+			if err := actuator.Create(cluster, m); err != nil {
+				t.Fatalf("failed to create machine: %v", err)
+			}
+
+			out, err := f.DescribeLaunchTemplateVersionsWithContext(context.TODO(), &ec2.DescribeLaunchTemplateVersionsInput{
+				LaunchTemplateName: aws.String("test-" + tc.name),
+				Versions:           aws.StringSlice([]string{"$Latest"}),
+			})
+			if err != nil {
+				t.Fatalf("failed to describe launch template versions: %v", err)
+			}
+			profile := out.LaunchTemplateVersions[0].LaunchTemplateData.IamInstanceProfile
+			if profile == nil || aws.StringValue(profile.Name) != tc.expected {
+				t.Fatalf("expected instance profile %q, got: %v", tc.expected, profile)
+			}
+		})
+	}
+}
+
+func TestCreateRoleTag(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mg := &machinesGetter{
+		mi: mock_machineiface.NewMockMachineInterface(mockCtrl),
+	}
+	defer mockCtrl.Finish()
+
+	codec, err := v1alpha1.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create a codec: %v", err)
+	}
+	providerConfig, err := codec.EncodeToProviderConfig(&v1alpha1.AWSMachineProviderConfig{
+		AMI: v1alpha1.AWSResourceReference{ID: aws.String("ami-1")},
+	})
+	if err != nil {
+		t.Fatalf("failed to encode the provider config: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		versions clusterv1.MachineVersionInfo
+		expected string
+	}{
+		{name: "control-plane", versions: clusterv1.MachineVersionInfo{Kubelet: "v1.11.2", ControlPlane: "v1.11.2"}, expected: ec2svc.RoleControlPlane},
+		{name: "node", versions: clusterv1.MachineVersionInfo{Kubelet: "v1.11.2"}, expected: ec2svc.RoleNode},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mg.mi.EXPECT().
+				UpdateStatus(gomock.AssignableToTypeOf(&clusterv1.Machine{})).
+				Return(&clusterv1.Machine{}, nil)
+
+			f := fake.New()
+			actuator, err := machine.NewActuator(machine.ActuatorParams{
+				Codec:          codec,
+				MachinesGetter: mg,
+				EC2Service:     ec2svc.NewService(f),
+			})
+			if err != nil {
+				t.Fatalf("failed to create an actuator: %v", err)
+			}
+
+			m := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Name: tc.name},
+				Spec:       clusterv1.MachineSpec{ProviderConfig: *providerConfig, Versions: tc.versions},
+			}
+			if err := actuator.Create(&clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}}, m); err != nil {
+				t.Fatalf("failed to create machine: %v", err)
+			}
+
+			out, err := f.DescribeInstancesWithContext(context.TODO(), &ec2.DescribeInstancesInput{})
+			if err != nil {
+				t.Fatalf("failed to describe instances: %v", err)
+			}
+			if len(out.Reservations) != 1 || len(out.Reservations[0].Instances) != 1 {
+				t.Fatalf("expected a single instance, got: %v", out.Reservations)
+			}
+			for _, tag := range out.Reservations[0].Instances[0].Tags {
+				if aws.StringValue(tag.Key) == ec2svc.TagNameRole {
+					if aws.StringValue(tag.Value) != tc.expected {
+						t.Fatalf("expected role %q, got: %q", tc.expected, aws.StringValue(tag.Value))
+					}
+					return
+				}
+			}
+			t.Fatalf("expected the instance to be tagged with its role, got: %v", out.Reservations[0].Instances[0].Tags)
+		})
+	}
+}
+
+func TestCreateMissingInstanceProfile(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mp := mock_services.NewMockInstanceProfilesInterface(mockCtrl)
+	defer mockCtrl.Finish()
+
+	codec, err := v1alpha1.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create a codec: %v", err)
+	}
+
+	providerConfig, err := codec.EncodeToProviderConfig(&v1alpha1.AWSMachineProviderConfig{
+		AMI:                v1alpha1.AWSResourceReference{ID: aws.String("ami-1")},
+		IAMInstanceProfile: &v1alpha1.AWSResourceReference{ARN: aws.String("arn:aws:iam::123456789012:instance-profile/custom")},
+	})
+	if err != nil {
+		t.Fatalf("failed to encode the provider config: %v", err)
+	}
+
+	mp.EXPECT().
+		WaitForInstanceProfile(gomock.Any(), "arn:aws:iam::123456789012:instance-profile/custom").
+		Return(ec2svc.NewNotFound(errors.New(`instance profile "custom" doesn't exist`)))
+
+	f := fake.New()
+	actuator, err := machine.NewActuator(machine.ActuatorParams{
+		Codec:                   codec,
+		EC2Service:              ec2svc.NewService(f),
+		InstanceProfilesService: mp,
+	})
+	if err != nil {
+		t.Fatalf("failed to create an actuator: %v", err)
+	}
+
+	m := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine-1"},
+		Spec:       clusterv1.MachineSpec{ProviderConfig: *providerConfig},
+	}
+	if err := actuator.Create(&clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}}, m); !ec2svc.IsNotFound(errors.Cause(err)) {
+		t.Fatalf("expected the instance profile not to be found, got: %v", err)
+	}
+
+	out, err := f.DescribeInstancesWithContext(context.TODO(), &ec2.DescribeInstancesInput{})
+	if err != nil {
+		t.Fatalf("failed to describe instances: %v", err)
+	}
+	if len(out.Reservations) != 0 {
+		t.Fatalf("expected no instance to be launched, got: %v", out.Reservations)
+	}
+}
+
+func TestCreatePolicyViolation(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mg := &machinesGetter{
+		mi: mock_machineiface.NewMockMachineInterface(mockCtrl),
+	}
+	defer mockCtrl.Finish()
+
+	codec, err := v1alpha1.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create a codec: %v", err)
+	}
+
+	providerConfig, err := codec.EncodeToProviderConfig(&v1alpha1.AWSMachineProviderConfig{
+		AMI:            v1alpha1.AWSResourceReference{ID: aws.String("ami-1")},
+		InstanceType:   "p3.2xlarge",
+		AdditionalTags: map[string]string{"cost-center": "ml"},
+	})
+	if err != nil {
+		t.Fatalf("failed to encode the provider config: %v", err)
+	}
+
+	mg.mi.EXPECT().
+		UpdateStatus(gomock.AssignableToTypeOf(&clusterv1.Machine{})).
+		Return(&clusterv1.Machine{}, nil)
+
+	f := fake.New()
+	actuator, err := machine.NewActuator(machine.ActuatorParams{
+		Codec:          codec,
+		MachinesGetter: mg,
+		EC2Service:     ec2svc.NewService(f),
+		Policy:         &policy.Static{RequiredTags: []string{"cost-center"}, DeniedInstanceFamilies: []string{"p3"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create an actuator: %v", err)
+	}
+
+	m := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine-1"},
+		Spec:       clusterv1.MachineSpec{ProviderConfig: *providerConfig},
+	}
+	if err := actuator.Create(&clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}}, m); !policy.IsViolation(err) {
+		t.Fatalf("expected a policy violation, got: %v", err)
+	}
+
+	status := &v1alpha1.AWSMachineProviderStatus{}
+	if err := codec.DecodeProviderStatus(m.Status.ProviderStatus, status); err != nil {
+		t.Fatalf("failed to decode provider status: %v", err)
+	}
+	if len(status.Conditions) != 1 || status.Conditions[0].Type != v1alpha1.PolicyViolation || status.Conditions[0].Status != corev1.ConditionTrue {
+		t.Fatalf("expected a policy violation condition, got: %+v", status.Conditions)
+	}
+
+	out, err := f.DescribeInstancesWithContext(context.TODO(), &ec2.DescribeInstancesInput{})
+	if err != nil {
+		t.Fatalf("failed to describe instances: %v", err)
+	}
+	if len(out.Reservations) != 0 {
+		t.Fatalf("expected no instance to be launched, got: %v", out.Reservations)
+	}
+}
+
+func TestCreateInvalidAMI(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mg := &machinesGetter{
+		mi: mock_machineiface.NewMockMachineInterface(mockCtrl),
+	}
+	me := mock_ec2iface.NewMockEC2API(mockCtrl)
+	defer mockCtrl.Finish()
+
+	mg.mi.EXPECT().
+		UpdateStatus(gomock.AssignableToTypeOf(&clusterv1.Machine{})).
+		Return(&clusterv1.Machine{}, nil)
+
+	expectLaunchTemplate(me, "lt-1")
+	me.EXPECT().
+		RunInstancesWithContext(gomock.Any(), runInstancesInput("lt-1")).
+		Return(nil, awserr.New("InvalidAMIID.NotFound", "The image id '[ami-1]' does not exist", nil))
+
+	codec, err := v1alpha1.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create a codec: %v", err)
+	}
+	actuator, err := machine.NewActuator(machine.ActuatorParams{
+		Codec:          codec,
+		MachinesGetter: mg,
+		EC2Service:     ec2svc.NewService(me),
+	})
+	if err != nil {
+		t.Fatalf("failed to create an actuator: %v", err)
+	}
+
+	m := &clusterv1.Machine{}
+	err = actuator.Create(&clusterv1.Cluster{}, m)
+	if _, ok := err.(*controllerError.RequeueAfterError); !ok {
+		t.Fatalf("expected the machine to be requeued, got: %v", err)
+	}
+	if m.Status.ErrorReason == nil || *m.Status.ErrorReason != common.InvalidConfigurationMachineError || !strings.Contains(aws.StringValue(m.Status.ErrorMessage), "InvalidAMIID.NotFound") {
+		t.Fatalf("expected the machine to have an invalid configuration error, got: %v, %v", m.Status.ErrorReason, aws.StringValue(m.Status.ErrorMessage))
+	}
+}
+
 func TestDelete(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	mg := &machinesGetter{
@@ -112,40 +598,34 @@ func TestDelete(t *testing.T) {
 		UpdateStatus(&clusterv1.Machine{
 			Status: clusterv1.MachineStatus{
 				ProviderStatus: &runtime.RawExtension{
-					Raw: []byte(`{"kind":"AWSMachineProviderStatus","apiVersion":"awsproviderconfig/v1alpha1","instanceID":"2345","instanceState":"running"}
+					Raw: []byte(`{"kind":"AWSMachineProviderStatus","apiVersion":"awsproviderconfig/v1alpha1","instanceID":"2345","instanceState":"running","launchTemplateID":"lt-1","launchTemplateVersion":1,"launchSpec":{"ami":{},"instanceType":""}}
 `),
 				},
 			},
 		}).
 		Return(&clusterv1.Machine{}, nil)
-	gomock.InOrder(
-		// ec2 calls
-		me.EXPECT().
-			DescribeInstances(&ec2.DescribeInstancesInput{
-				InstanceIds: []*string{nil},
-			}).
-			Return(nil, ec2svc.NewNotFound(errors.New(""))),
-		me.EXPECT().
-			DescribeInstances(&ec2.DescribeInstancesInput{
-				InstanceIds: []*string{aws.String("2345")},
-			}).
-			Return(&ec2.DescribeInstancesOutput{
-				Reservations: []*ec2.Reservation{
-					&ec2.Reservation{
-						Instances: []*ec2.Instance{
-							&ec2.Instance{
-								State: &ec2.InstanceState{
-									Name: aws.String(ec2.InstanceStateNameRunning),
-								},
-								InstanceId: aws.String("2345"),
+	// ec2 calls
+	me.EXPECT().
+		DescribeInstancesWithContext(gomock.Any(), &ec2.DescribeInstancesInput{
+			InstanceIds: []*string{aws.String("2345")},
+		}).
+		Return(&ec2.DescribeInstancesOutput{
+			Reservations: []*ec2.Reservation{
+				&ec2.Reservation{
+					Instances: []*ec2.Instance{
+						&ec2.Instance{
+							State: &ec2.InstanceState{
+								Name: aws.String(ec2.InstanceStateNameRunning),
 							},
+							InstanceId: aws.String("2345"),
 						},
 					},
 				},
-			}, nil),
-	)
+			},
+		}, nil)
+	expectLaunchTemplate(me, "lt-1")
 	me.EXPECT().
-		RunInstances(&ec2.RunInstancesInput{}).
+		RunInstancesWithContext(gomock.Any(), runInstancesInput("lt-1")).
 		Return(&ec2.Reservation{
 			Instances: []*ec2.Instance{
 				&ec2.Instance{
@@ -157,7 +637,7 @@ func TestDelete(t *testing.T) {
 			},
 		}, nil)
 	me.EXPECT().
-		TerminateInstances(&ec2.TerminateInstancesInput{
+		TerminateInstancesWithContext(gomock.Any(), &ec2.TerminateInstancesInput{
 			InstanceIds: []*string{aws.String("2345")},
 		}).
 		Return(nil, nil)
@@ -170,9 +650,7 @@ func TestDelete(t *testing.T) {
 	ap := machine.ActuatorParams{
 		Codec:          codec,
 		MachinesGetter: mg,
-		EC2Service: &ec2svc.Service{
-			EC2: me,
-		},
+		EC2Service:     ec2svc.NewService(me),
 	}
 
 	actuator, err := machine.NewActuator(ap)
@@ -188,7 +666,7 @@ func TestDelete(t *testing.T) {
 	testMachine := &clusterv1.Machine{
 		Status: clusterv1.MachineStatus{
 			ProviderStatus: &runtime.RawExtension{
-				Raw: []byte(`{"kind":"AWSMachineProviderStatus","apiVersion":"awsproviderconfig/v1alpha1","instanceID":"2345","instanceState":"running"}
+				Raw: []byte(`{"kind":"AWSMachineProviderStatus","apiVersion":"awsproviderconfig/v1alpha1","instanceID":"2345","instanceState":"running","launchTemplateID":"lt-1","launchTemplateVersion":1}
 `),
 			},
 		},
@@ -208,12 +686,7 @@ func TestDeleteNotExisting(t *testing.T) {
 	me := mock_ec2iface.NewMockEC2API(mockCtrl)
 	defer mockCtrl.Finish()
 
-	// ec2 calls
-	me.EXPECT().
-		DescribeInstances(&ec2.DescribeInstancesInput{
-			InstanceIds: []*string{nil},
-		}).
-		Return(nil, ec2svc.NewNotFound(errors.New("")))
+	// No ec2 calls, the machine has no instance.
 
 	codec, err := v1alpha1.NewCodec()
 	if err != nil {
@@ -223,9 +696,7 @@ func TestDeleteNotExisting(t *testing.T) {
 	ap := machine.ActuatorParams{
 		Codec:          codec,
 		MachinesGetter: mg,
-		EC2Service: &ec2svc.Service{
-			EC2: me,
-		},
+		EC2Service:     ec2svc.NewService(me),
 	}
 
 	actuator, err := machine.NewActuator(ap)
@@ -243,6 +714,154 @@ func TestDeleteNotExisting(t *testing.T) {
 	}
 }
 
+func TestDeleteGracefulShutdown(t *testing.T) {
+	testCases := []struct {
+		name        string
+		shutdownErr error
+		stopped     bool
+		elapsed     time.Duration
+		waits       bool
+	}{
+		{name: "shutting down", elapsed: time.Minute, waits: true},
+		{name: "stopped", stopped: true, elapsed: time.Minute},
+		{name: "timed out", elapsed: 3 * time.Minute},
+		{name: "not managed by ssm", shutdownErr: ec2svc.NewNotFound(errors.New("not managed")), elapsed: time.Minute},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			codec, err := v1alpha1.NewCodec()
+			if err != nil {
+				t.Fatalf("failed to create a codec: %v", err)
+			}
+
+			f := fake.New()
+			s := ec2svc.NewService(f)
+			config := &v1alpha1.AWSMachineProviderConfig{
+				AMI:                     v1alpha1.AWSResourceReference{ID: aws.String("ami-1")},
+				GracefulShutdownTimeout: &metav1.Duration{Duration: 2 * time.Minute},
+			}
+			instance, err := s.CreateInstance(context.TODO(), "test", "", nil, &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-1"}}, config)
+			if err != nil {
+				t.Fatalf("failed to create instance: %v", err)
+			}
+
+			providerConfig, err := codec.EncodeToProviderConfig(config)
+			if err != nil {
+				t.Fatalf("failed to encode provider config: %v", err)
+			}
+			providerStatus, err := codec.EncodeProviderStatus(&v1alpha1.AWSMachineProviderStatus{InstanceID: &instance.ID})
+			if err != nil {
+				t.Fatalf("failed to encode provider status: %v", err)
+			}
+
+			mg := &machinesGetter{
+				mi: mock_machineiface.NewMockMachineInterface(mockCtrl),
+			}
+			mg.mi.EXPECT().
+				UpdateStatus(gomock.AssignableToTypeOf(&clusterv1.Machine{})).
+				Return(&clusterv1.Machine{}, nil).
+				AnyTimes()
+
+			// The shutdown is only sent once.
+			ms := mock_services.NewMockInstanceShutdownInterface(mockCtrl)
+			ms.EXPECT().
+				ShutdownInstance(gomock.Any(), instance.ID).
+				Return(tc.shutdownErr)
+
+			now := time.Now()
+			actuator, err := machine.NewActuator(machine.ActuatorParams{
+				Codec:                   codec,
+				MachinesGetter:          mg,
+				EC2Service:              s,
+				InstanceShutdownService: ms,
+				Clock:                   func() time.Time { return now },
+			})
+			if err != nil {
+				t.Fatalf("failed to create an actuator: %v", err)
+			}
+
+			cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+			m := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Name: "machine-1"},
+				Spec:       clusterv1.MachineSpec{ProviderConfig: *providerConfig},
+				Status:     clusterv1.MachineStatus{ProviderStatus: providerStatus},
+			}
+
+			if tc.shutdownErr == nil {
+				err := actuator.Delete(cluster, m)
+				if _, ok := err.(*controllerError.RequeueAfterError); !ok {
+					t.Fatalf("expected a requeue while the instance shuts down, got: %v", err)
+				}
+			}
+
+			if tc.stopped {
+				if _, err := f.StopInstancesWithContext(context.TODO(), &ec2.StopInstancesInput{InstanceIds: []*string{&instance.ID}}); err != nil {
+					t.Fatalf("failed to stop instance: %v", err)
+				}
+			}
+			now = now.Add(tc.elapsed)
+			err = actuator.Delete(cluster, m)
+			if tc.waits {
+				if _, ok := err.(*controllerError.RequeueAfterError); !ok {
+					t.Fatalf("expected a requeue within the graceful shutdown timeout, got: %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("failed to delete machine: %v", err)
+			}
+
+			if exists, err := actuator.Exists(cluster, m); err != nil || exists != tc.waits {
+				t.Fatalf("expected the instance to exist: %v, exists: %v, err: %v", tc.waits, exists, err)
+			}
+		})
+	}
+}
+
+func TestReconcileTimeout(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var deadline time.Time
+	me := mock_ec2iface.NewMockEC2API(mockCtrl)
+	me.EXPECT().
+		DescribeInstancesWithContext(gomock.Any(), gomock.Any()).
+		Do(func(ctx aws.Context, _ *ec2.DescribeInstancesInput) {
+			deadline, _ = ctx.Deadline()
+		}).
+		Return(&ec2.DescribeInstancesOutput{}, nil)
+
+	codec, err := v1alpha1.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create a codec: %v", err)
+	}
+
+	actuator, err := machine.NewActuator(machine.ActuatorParams{
+		Codec:            codec,
+		EC2Service:       ec2svc.NewService(me),
+		ReconcileTimeout: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("failed to create an actuator: %v", err)
+	}
+
+	m := &clusterv1.Machine{
+		Status: clusterv1.MachineStatus{
+			ProviderStatus: &runtime.RawExtension{
+				Raw: []byte(`{"kind":"AWSMachineProviderStatus","apiVersion":"awsproviderconfig/v1alpha1","instanceID":"1234"}`),
+			},
+		},
+	}
+	if _, err := actuator.Exists(&clusterv1.Cluster{}, m); err != nil {
+		t.Fatalf("failed to check if machine exists: %v", err)
+	}
+	if deadline.IsZero() || time.Until(deadline) > time.Minute {
+		t.Fatalf("expected the AWS call to be made with the reconcile deadline, got: %v", deadline)
+	}
+}
+
 func TestUpdate(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	mg := &machinesGetter{
@@ -270,9 +889,7 @@ func TestUpdate(t *testing.T) {
 	ap := machine.ActuatorParams{
 		Codec:          codec,
 		MachinesGetter: mg,
-		EC2Service: &ec2svc.Service{
-			EC2: me,
-		},
+		EC2Service:     ec2svc.NewService(me),
 	}
 
 	actuator, err := machine.NewActuator(ap)
@@ -288,3 +905,558 @@ func TestUpdate(t *testing.T) {
 		t.Fatalf("failed to delete machine: %v", err)
 	}
 }
+
+func TestUpdateDiagnostics(t *testing.T) {
+	testCases := []struct {
+		name      string
+		timeout   *metav1.Duration
+		nodeRef   *corev1.ObjectReference
+		elapsed   time.Duration
+		collected bool
+	}{
+		{
+			name:      "node join timed out",
+			timeout:   &metav1.Duration{Duration: 10 * time.Minute},
+			elapsed:   time.Hour,
+			collected: true,
+		},
+		{
+			name:    "within node join timeout",
+			timeout: &metav1.Duration{Duration: 10 * time.Minute},
+			elapsed: time.Minute,
+		},
+		{
+			name:    "node joined",
+			timeout: &metav1.Duration{Duration: 10 * time.Minute},
+			nodeRef: &corev1.ObjectReference{Kind: "Node", Name: "node-1"},
+			elapsed: time.Hour,
+		},
+		{
+			name:    "no node join timeout",
+			elapsed: time.Hour,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			codec, err := v1alpha1.NewCodec()
+			if err != nil {
+				t.Fatalf("failed to create a codec: %v", err)
+			}
+
+			f := fake.New()
+			s := ec2svc.NewService(f)
+			config := &v1alpha1.AWSMachineProviderConfig{
+				AMI:             v1alpha1.AWSResourceReference{ID: aws.String("ami-1")},
+				NodeJoinTimeout: tc.timeout,
+			}
+			instance, err := s.CreateInstance(context.TODO(), "test", "", nil, &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-1"}}, config)
+			if err != nil {
+				t.Fatalf("failed to create instance: %v", err)
+			}
+			f.SetConsoleOutput(instance.ID, "[   12.345678] cloud-init: kubeadm join failed\n")
+
+			providerConfig, err := codec.EncodeToProviderConfig(config)
+			if err != nil {
+				t.Fatalf("failed to encode provider config: %v", err)
+			}
+			providerStatus, err := codec.EncodeProviderStatus(&v1alpha1.AWSMachineProviderStatus{InstanceID: &instance.ID})
+			if err != nil {
+				t.Fatalf("failed to encode provider status: %v", err)
+			}
+			clusterConfig, err := codec.EncodeToProviderConfig(&v1alpha1.AWSClusterProviderConfig{})
+			if err != nil {
+				t.Fatalf("failed to encode cluster provider config: %v", err)
+			}
+
+			status := &v1alpha1.AWSMachineProviderStatus{}
+			mg := &machinesGetter{
+				mi: mock_machineiface.NewMockMachineInterface(mockCtrl),
+			}
+			mg.mi.EXPECT().
+				UpdateStatus(gomock.AssignableToTypeOf(&clusterv1.Machine{})).
+				Do(func(m *clusterv1.Machine) {
+					if err := codec.DecodeProviderStatus(m.Status.ProviderStatus, status); err != nil {
+						t.Fatalf("failed to decode provider status: %v", err)
+					}
+				}).
+				Return(&clusterv1.Machine{}, nil)
+
+			actuator, err := machine.NewActuator(machine.ActuatorParams{
+				Codec:          codec,
+				MachinesGetter: mg,
+				EC2Service:     s,
+				Clock:          func() time.Time { return time.Now().Add(tc.elapsed) },
+			})
+			if err != nil {
+				t.Fatalf("failed to create an actuator: %v", err)
+			}
+
+			err = actuator.Update(
+				&clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}, Spec: clusterv1.ClusterSpec{ProviderConfig: *clusterConfig}},
+				&clusterv1.Machine{
+					ObjectMeta: metav1.ObjectMeta{Name: "machine-1"},
+					Spec:       clusterv1.MachineSpec{ProviderConfig: *providerConfig},
+					Status:     clusterv1.MachineStatus{NodeRef: tc.nodeRef, ProviderStatus: providerStatus},
+				},
+			)
+			if err != nil {
+				t.Fatalf("failed to update machine: %v", err)
+			}
+
+			if !tc.collected {
+				if status.Diagnostics != nil {
+					t.Fatalf("expected no diagnostics, got: %+v", status.Diagnostics)
+				}
+				return
+			}
+			if status.Diagnostics == nil || status.Diagnostics.InstanceID != instance.ID || !strings.Contains(status.Diagnostics.ConsoleOutput, "kubeadm join failed") {
+				t.Fatalf("expected the console output of instance %q, got: %+v", instance.ID, status.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestUpdateNodeJoinRemediation(t *testing.T) {
+	testCases := []struct {
+		name          string
+		retries       int
+		statusRetries int
+		terminated    bool
+		failed        bool
+	}{
+		{
+			name:          "instance is replaced",
+			retries:       2,
+			statusRetries: 1,
+			terminated:    true,
+		},
+		{
+			name:          "retries are used up",
+			retries:       2,
+			statusRetries: 2,
+			failed:        true,
+		},
+		{
+			name: "remediation is disabled",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			codec, err := v1alpha1.NewCodec()
+			if err != nil {
+				t.Fatalf("failed to create a codec: %v", err)
+			}
+
+			s := ec2svc.NewService(fake.New())
+			config := &v1alpha1.AWSMachineProviderConfig{
+				AMI:             v1alpha1.AWSResourceReference{ID: aws.String("ami-1")},
+				NodeJoinTimeout: &metav1.Duration{Duration: 10 * time.Minute},
+				NodeJoinRetries: tc.retries,
+			}
+			instance, err := s.CreateInstance(context.TODO(), "test", "", nil, &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-1"}}, config)
+			if err != nil {
+				t.Fatalf("failed to create instance: %v", err)
+			}
+
+			providerConfig, err := codec.EncodeToProviderConfig(config)
+			if err != nil {
+				t.Fatalf("failed to encode provider config: %v", err)
+			}
+			providerStatus, err := codec.EncodeProviderStatus(&v1alpha1.AWSMachineProviderStatus{InstanceID: &instance.ID, NodeJoinRetries: tc.statusRetries})
+			if err != nil {
+				t.Fatalf("failed to encode provider status: %v", err)
+			}
+			clusterConfig, err := codec.EncodeToProviderConfig(&v1alpha1.AWSClusterProviderConfig{})
+			if err != nil {
+				t.Fatalf("failed to encode cluster provider config: %v", err)
+			}
+
+			var updated *clusterv1.Machine
+			mg := &machinesGetter{
+				mi: mock_machineiface.NewMockMachineInterface(mockCtrl),
+			}
+			mg.mi.EXPECT().
+				UpdateStatus(gomock.AssignableToTypeOf(&clusterv1.Machine{})).
+				Do(func(m *clusterv1.Machine) { updated = m }).
+				Return(&clusterv1.Machine{}, nil)
+
+			actuator, err := machine.NewActuator(machine.ActuatorParams{
+				Codec:          codec,
+				MachinesGetter: mg,
+				EC2Service:     s,
+				Clock:          func() time.Time { return time.Now().Add(time.Hour) },
+			})
+			if err != nil {
+				t.Fatalf("failed to create an actuator: %v", err)
+			}
+
+			m := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Name: "machine-1"},
+				Spec:       clusterv1.MachineSpec{ProviderConfig: *providerConfig},
+				Status:     clusterv1.MachineStatus{ProviderStatus: providerStatus},
+			}
+			if err := actuator.Update(&clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}, Spec: clusterv1.ClusterSpec{ProviderConfig: *clusterConfig}}, m); err != nil {
+				t.Fatalf("failed to update machine: %v", err)
+			}
+
+			exists, err := actuator.Exists(&clusterv1.Cluster{}, m)
+			if err != nil {
+				t.Fatalf("failed to check if machine exists: %v", err)
+			}
+			if exists == tc.terminated {
+				t.Fatalf("expected the instance to be terminated: %v, machine exists: %v", tc.terminated, exists)
+			}
+
+			status := &v1alpha1.AWSMachineProviderStatus{}
+			if err := codec.DecodeProviderStatus(updated.Status.ProviderStatus, status); err != nil {
+				t.Fatalf("failed to decode provider status: %v", err)
+			}
+			if tc.terminated && status.NodeJoinRetries != tc.statusRetries+1 {
+				t.Fatalf("expected %d node join retries, got: %d", tc.statusRetries+1, status.NodeJoinRetries)
+			}
+			if failed := updated.Status.ErrorReason != nil; failed != tc.failed {
+				t.Fatalf("expected the machine to have failed: %v, got error reason: %v", tc.failed, updated.Status.ErrorReason)
+			}
+		})
+	}
+}
+
+func TestUpdateInstanceHealth(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	codec, err := v1alpha1.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create a codec: %v", err)
+	}
+
+	f := fake.New()
+	s := ec2svc.NewService(f)
+	config := &v1alpha1.AWSMachineProviderConfig{
+		AMI:                     v1alpha1.AWSResourceReference{ID: aws.String("ami-1")},
+		ImpairedInstanceTimeout: &metav1.Duration{Duration: 10 * time.Minute},
+	}
+	instance, err := s.CreateInstance(context.TODO(), "test", "", nil, &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-1"}}, config)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+
+	providerConfig, err := codec.EncodeToProviderConfig(config)
+	if err != nil {
+		t.Fatalf("failed to encode provider config: %v", err)
+	}
+	providerStatus, err := codec.EncodeProviderStatus(&v1alpha1.AWSMachineProviderStatus{InstanceID: &instance.ID})
+	if err != nil {
+		t.Fatalf("failed to encode provider status: %v", err)
+	}
+	clusterConfig, err := codec.EncodeToProviderConfig(&v1alpha1.AWSClusterProviderConfig{})
+	if err != nil {
+		t.Fatalf("failed to encode cluster provider config: %v", err)
+	}
+
+	mg := &machinesGetter{
+		mi: mock_machineiface.NewMockMachineInterface(mockCtrl),
+	}
+	mg.mi.EXPECT().
+		UpdateStatus(gomock.AssignableToTypeOf(&clusterv1.Machine{})).
+		Return(&clusterv1.Machine{}, nil).
+		AnyTimes()
+
+	now := time.Now()
+	actuator, err := machine.NewActuator(machine.ActuatorParams{
+		Codec:          codec,
+		MachinesGetter: mg,
+		EC2Service:     s,
+		Clock:          func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatalf("failed to create an actuator: %v", err)
+	}
+
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}, Spec: clusterv1.ClusterSpec{ProviderConfig: *clusterConfig}}
+	m := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine-1"},
+		Spec:       clusterv1.MachineSpec{ProviderConfig: *providerConfig},
+		Status:     clusterv1.MachineStatus{ProviderStatus: providerStatus},
+	}
+	healthy := func() *v1alpha1.AWSMachineProviderCondition {
+		status := &v1alpha1.AWSMachineProviderStatus{}
+		if err := codec.DecodeProviderStatus(m.Status.ProviderStatus, status); err != nil {
+			t.Fatalf("failed to decode provider status: %v", err)
+		}
+		for i := range status.Conditions {
+			if status.Conditions[i].Type == v1alpha1.InstanceHealthy {
+				return &status.Conditions[i]
+			}
+		}
+		return nil
+	}
+
+	if err := actuator.Update(cluster, m); err != nil {
+		t.Fatalf("failed to update machine: %v", err)
+	}
+	if c := healthy(); c == nil || c.Status != corev1.ConditionTrue {
+		t.Fatalf("expected the instance to be healthy, got: %+v", c)
+	}
+
+	f.SetStatusChecks(instance.ID, ec2.SummaryStatusImpaired, ec2.SummaryStatusOk)
+	now = now.Add(time.Minute)
+	if err := actuator.Update(cluster, m); err != nil {
+		t.Fatalf("failed to update machine: %v", err)
+	}
+	if c := healthy(); c == nil || c.Status != corev1.ConditionFalse || c.Reason != "SystemStatusImpaired" {
+		t.Fatalf("expected the instance to be impaired, got: %+v", c)
+	}
+	if exists, err := actuator.Exists(cluster, m); err != nil || !exists {
+		t.Fatalf("expected the instance not to be replaced within the impaired instance timeout, exists: %v, err: %v", exists, err)
+	}
+
+	now = now.Add(time.Hour)
+	if err := actuator.Update(cluster, m); err != nil {
+		t.Fatalf("failed to update machine: %v", err)
+	}
+	if exists, err := actuator.Exists(cluster, m); err != nil || exists {
+		t.Fatalf("expected the impaired instance to be terminated, exists: %v, err: %v", exists, err)
+	}
+	if c := healthy(); c != nil {
+		t.Fatalf("expected the condition of the terminated instance to be removed, got: %+v", c)
+	}
+}
+
+func TestUpdatePendingChanges(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	codec, err := v1alpha1.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create a codec: %v", err)
+	}
+
+	f := fake.New()
+	s := ec2svc.NewService(f)
+	launched := &v1alpha1.AWSMachineProviderConfig{
+		AMI:                     v1alpha1.AWSResourceReference{ID: aws.String("ami-1")},
+		InstanceType:            "m5.large",
+		ImpairedInstanceTimeout: &metav1.Duration{Duration: 10 * time.Minute},
+	}
+	instance, err := s.CreateInstance(context.TODO(), "test", "", nil, &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-1"}}, launched)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	f.SetStatusChecks(instance.ID, ec2.SummaryStatusImpaired, ec2.SummaryStatusOk)
+
+	config := launched.DeepCopy()
+	config.InstanceType = "m5.xlarge"
+	config.AdditionalTags = map[string]string{"team": "ml"}
+	providerConfig, err := codec.EncodeToProviderConfig(config)
+	if err != nil {
+		t.Fatalf("failed to encode provider config: %v", err)
+	}
+	providerStatus, err := codec.EncodeProviderStatus(&v1alpha1.AWSMachineProviderStatus{
+		InstanceID: &instance.ID,
+		LaunchSpec: &v1alpha1.MachineLaunchSpec{AMI: launched.AMI, InstanceType: launched.InstanceType},
+	})
+	if err != nil {
+		t.Fatalf("failed to encode provider status: %v", err)
+	}
+	clusterConfig, err := codec.EncodeToProviderConfig(&v1alpha1.AWSClusterProviderConfig{})
+	if err != nil {
+		t.Fatalf("failed to encode cluster provider config: %v", err)
+	}
+
+	mg := &machinesGetter{
+		mi: mock_machineiface.NewMockMachineInterface(mockCtrl),
+	}
+	mg.mi.EXPECT().
+		UpdateStatus(gomock.AssignableToTypeOf(&clusterv1.Machine{})).
+		Return(&clusterv1.Machine{}, nil).
+		AnyTimes()
+
+	now := time.Now()
+	recorder := record.NewFakeRecorder(10)
+	actuator, err := machine.NewActuator(machine.ActuatorParams{
+		Codec:                 codec,
+		MachinesGetter:        mg,
+		EC2Service:            s,
+		EventRecorder:         recorder,
+		Clock:                 func() time.Time { return now },
+		RequireChangeApproval: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create an actuator: %v", err)
+	}
+
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}, Spec: clusterv1.ClusterSpec{ProviderConfig: *clusterConfig}}
+	m := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine-1"},
+		Spec:       clusterv1.MachineSpec{ProviderConfig: *providerConfig},
+		Status:     clusterv1.MachineStatus{ProviderStatus: providerStatus},
+	}
+	pending := func() *v1alpha1.AWSMachineProviderCondition {
+		status := &v1alpha1.AWSMachineProviderStatus{}
+		if err := codec.DecodeProviderStatus(m.Status.ProviderStatus, status); err != nil {
+			t.Fatalf("failed to decode provider status: %v", err)
+		}
+		for i := range status.Conditions {
+			if status.Conditions[i].Type == v1alpha1.PendingChanges {
+				return &status.Conditions[i]
+			}
+		}
+		return nil
+	}
+	events := func() []string {
+		var res []string
+		for {
+			select {
+			case e := <-recorder.Events:
+				res = append(res, e)
+			default:
+				return res
+			}
+		}
+	}
+
+	// The impaired instance is kept while the change of its instance type isn't approved,
+	// additional tags are applied in place and aren't reported.
+	for i := 0; i < 2; i++ {
+		if err := actuator.Update(cluster, m); err != nil {
+			t.Fatalf("failed to update machine: %v", err)
+		}
+		now = now.Add(time.Hour)
+	}
+	c := pending()
+	if c == nil || c.Reason != "ApprovalRequired" || !strings.Contains(c.Message, `instanceType: "m5.large" → "m5.xlarge"`) || strings.Contains(c.Message, "team") {
+		t.Fatalf("expected the instance type change to need approval, got: %+v", c)
+	}
+	if exists, err := actuator.Exists(cluster, m); err != nil || !exists {
+		t.Fatalf("expected the instance not to be replaced without approval, exists: %v, err: %v", exists, err)
+	}
+
+	digest := c.Message[strings.Index(c.Message, v1alpha1.ApproveChangesAnnotation+"=")+len(v1alpha1.ApproveChangesAnnotation)+1:]
+	digest = strings.TrimSuffix(digest, " annotation.")
+
+	// A single event is recorded for the changes, however often they are reconciled.
+	if e := events(); len(e) != 1 || !strings.HasPrefix(e[0], "Warning PendingChanges ") || !strings.Contains(e[0], `instanceType: "m5.large" → "m5.xlarge"`) || !strings.Contains(e[0], "Digest: "+digest+".") {
+		t.Fatalf("expected a warning event with the changes and their digest, got: %q", e)
+	}
+	m.Annotations = map[string]string{v1alpha1.ApproveChangesAnnotation: digest}
+
+	if err := actuator.Update(cluster, m); err != nil {
+		t.Fatalf("failed to update machine: %v", err)
+	}
+	if c := pending(); c == nil || c.Reason != "ChangedSinceLaunch" {
+		t.Fatalf("expected the changes to be approved, got: %+v", c)
+	}
+	if e := events(); len(e) != 0 {
+		t.Fatalf("expected no event for the approval of the same changes, got: %q", e)
+	}
+	if exists, err := actuator.Exists(cluster, m); err != nil || exists {
+		t.Fatalf("expected the impaired instance to be replaced once the changes are approved, exists: %v, err: %v", exists, err)
+	}
+}
+
+func TestUpdateManagerConflict(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	codec, err := v1alpha1.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create a codec: %v", err)
+	}
+
+	f := fake.New()
+	s := ec2svc.NewService(f).WithManager("management-a")
+	config := &v1alpha1.AWSMachineProviderConfig{
+		AMI:                     v1alpha1.AWSResourceReference{ID: aws.String("ami-1")},
+		ImpairedInstanceTimeout: &metav1.Duration{Duration: 10 * time.Minute},
+	}
+	instance, err := s.CreateInstance(context.TODO(), "test", "", nil, &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-1"}}, config)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	f.SetStatusChecks(instance.ID, ec2.SummaryStatusImpaired, ec2.SummaryStatusOk)
+
+	providerConfig, err := codec.EncodeToProviderConfig(config)
+	if err != nil {
+		t.Fatalf("failed to encode provider config: %v", err)
+	}
+	providerStatus, err := codec.EncodeProviderStatus(&v1alpha1.AWSMachineProviderStatus{InstanceID: &instance.ID})
+	if err != nil {
+		t.Fatalf("failed to encode provider status: %v", err)
+	}
+	clusterConfig, err := codec.EncodeToProviderConfig(&v1alpha1.AWSClusterProviderConfig{})
+	if err != nil {
+		t.Fatalf("failed to encode cluster provider config: %v", err)
+	}
+
+	mg := &machinesGetter{
+		mi: mock_machineiface.NewMockMachineInterface(mockCtrl),
+	}
+	mg.mi.EXPECT().
+		UpdateStatus(gomock.AssignableToTypeOf(&clusterv1.Machine{})).
+		Return(&clusterv1.Machine{}, nil).
+		AnyTimes()
+
+	now := time.Now()
+	newActuator := func(svc *ec2svc.Service) *machine.Actuator {
+		actuator, err := machine.NewActuator(machine.ActuatorParams{
+			Codec:          codec,
+			MachinesGetter: mg,
+			EC2Service:     svc,
+			Clock:          func() time.Time { return now },
+		})
+		if err != nil {
+			t.Fatalf("failed to create an actuator: %v", err)
+		}
+		return actuator
+	}
+
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}, Spec: clusterv1.ClusterSpec{ProviderConfig: *clusterConfig}}
+	m := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine-1"},
+		Spec:       clusterv1.MachineSpec{ProviderConfig: *providerConfig},
+		Status:     clusterv1.MachineStatus{ProviderStatus: providerStatus},
+	}
+	conflict := func() *v1alpha1.AWSMachineProviderCondition {
+		status := &v1alpha1.AWSMachineProviderStatus{}
+		if err := codec.DecodeProviderStatus(m.Status.ProviderStatus, status); err != nil {
+			t.Fatalf("failed to decode provider status: %v", err)
+		}
+		for i := range status.Conditions {
+			if status.Conditions[i].Type == v1alpha1.ManagerConflict {
+				return &status.Conditions[i]
+			}
+		}
+		return nil
+	}
+
+	// The machine controller of another management cluster leaves the impaired instance alone.
+	other := newActuator(ec2svc.NewService(f).WithManager("management-b"))
+	for i := 0; i < 2; i++ {
+		if err := other.Update(cluster, m); !ec2svc.IsConflict(errors.Cause(err)) {
+			t.Fatalf("expected a conflict, got: %v", err)
+		}
+		now = now.Add(time.Hour)
+	}
+	if c := conflict(); c == nil || c.Status != corev1.ConditionTrue {
+		t.Fatalf("expected a manager conflict, got: %+v", c)
+	}
+	if exists, err := other.Exists(cluster, m); err != nil || !exists {
+		t.Fatalf("expected the instance not to be terminated, exists: %v, err: %v", exists, err)
+	}
+
+	if err := newActuator(s).Update(cluster, m); err != nil {
+		t.Fatalf("failed to update machine: %v", err)
+	}
+	if c := conflict(); c != nil {
+		t.Fatalf("expected the manager conflict to be removed, got: %+v", c)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
+)
+
+const (
+	// unsetValue is shown for fields that aren't set on one side of a change.
+	unsetValue = "(unset)"
+
+	// reasonApprovalRequired is the reason of the PendingChanges condition while the changes
+	// keep the instance from being replaced.
+	reasonApprovalRequired = "ApprovalRequired"
+
+	// eventReasonPendingChanges is the reason of the event recorded when the pending changes of a
+	// machine change.
+	eventReasonPendingChanges = "PendingChanges"
+)
+
+// launchSpec returns the part of the config an instance is launched with.
+func launchSpec(config *v1alpha1.AWSMachineProviderConfig) *v1alpha1.MachineLaunchSpec {
+	spec := &v1alpha1.MachineLaunchSpec{
+		AMI:                               config.AMI,
+		InstanceType:                      config.InstanceType,
+		RootVolumeType:                    config.RootVolumeType,
+		RootVolumeIOPS:                    config.RootVolumeIOPS,
+		RootVolumeEncrypted:               config.RootVolumeEncrypted,
+		IAMInstanceProfile:                config.IAMInstanceProfile,
+		PublicIP:                          config.PublicIP,
+		AdditionalSecurityGroups:          config.AdditionalSecurityGroups,
+		Subnet:                            config.Subnet,
+		InstanceInitiatedShutdownBehavior: config.InstanceInitiatedShutdownBehavior,
+	}
+	return spec.DeepCopy()
+}
+
+// launchSpecChanges returns the fields that differ between the spec an instance was launched with
+// and the current one, like `instanceType: "m5.large" → "p3.2xlarge"`, sorted by field.
+func launchSpecChanges(launched *v1alpha1.MachineLaunchSpec, current *v1alpha1.MachineLaunchSpec) ([]string, error) {
+	before, err := flattenFields(launched)
+	if err != nil {
+		return nil, err
+	}
+	after, err := flattenFields(current)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]bool)
+	for field := range before {
+		fields[field] = true
+	}
+	for field := range after {
+		fields[field] = true
+	}
+
+	var changes []string
+	for field := range fields {
+		b, ok := before[field]
+		if !ok {
+			b = unsetValue
+		}
+		a, ok := after[field]
+		if !ok {
+			a = unsetValue
+		}
+		if b != a {
+			changes = append(changes, fmt.Sprintf("%s: %s → %s", field, b, a))
+		}
+	}
+	sort.Strings(changes)
+	return changes, nil
+}
+
+// flattenFields returns the JSON encoded values of the spec by their path, like ami.id or
+// additionalSecurityGroups[0].id.
+func flattenFields(spec *v1alpha1.MachineLaunchSpec) (map[string]string, error) {
+	raw, err := json.Marshal(spec)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode launch spec")
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, errors.Wrap(err, "failed to decode launch spec")
+	}
+
+	fields := make(map[string]string)
+	flatten("", v, fields)
+	return fields, nil
+}
+
+func flatten(path string, v interface{}, fields map[string]string) {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, e := range x {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			flatten(p, e, fields)
+		}
+	case []interface{}:
+		for i, e := range x {
+			flatten(fmt.Sprintf("%s[%d]", path, i), e, fields)
+		}
+	case nil:
+	default:
+		raw, _ := json.Marshal(x)
+		fields[path] = string(raw)
+	}
+}
+
+// changesDigest returns the digest of the changes, which approves them as the value of the
+// approve changes annotation.
+func changesDigest(changes []string) string {
+	return ec2svc.ClientToken(changes...)[:12]
+}
+
+// reconcilePendingChanges sets the PendingChanges condition of a machine whose config changed
+// since its instance was launched, in fields that only take effect on a new instance. If the
+// actuator requires the approval of changes, the condition keeps the instance from being
+// replaced until the changes are approved, see replacementHeld. A warning event with the changes
+// and their digest is recorded whenever the digest changes.
+func (a *Actuator) reconcilePendingChanges(log logr.Logger, machine *clusterv1.Machine, config *v1alpha1.AWSMachineProviderConfig, status *v1alpha1.AWSMachineProviderStatus) error {
+	if status.LaunchSpec == nil {
+		removeCondition(status, v1alpha1.PendingChanges)
+		return nil
+	}
+
+	changes, err := launchSpecChanges(status.LaunchSpec, launchSpec(config))
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		removeCondition(status, v1alpha1.PendingChanges)
+		return nil
+	}
+
+	digest := changesDigest(changes)
+	condition := v1alpha1.AWSMachineProviderCondition{
+		Type:    v1alpha1.PendingChanges,
+		Status:  corev1.ConditionTrue,
+		Reason:  "ChangedSinceLaunch",
+		Message: fmt.Sprintf("The changes apply once the instance is replaced: %s. Digest: %s.", strings.Join(changes, "; "), digest),
+	}
+	if a.requireChangeApproval && machine.Annotations[v1alpha1.ApproveChangesAnnotation] != digest {
+		condition.Reason = reasonApprovalRequired
+		condition.Message += fmt.Sprintf(" The instance isn't replaced until the changes are approved with the %s=%s annotation.", v1alpha1.ApproveChangesAnnotation, digest)
+	}
+
+	previous := findCondition(status, v1alpha1.PendingChanges)
+	if previous == nil || previous.Message != condition.Message {
+		log.Info("Machine has changes that apply once the instance is replaced", "changes", changes, "digest", digest, "reason", condition.Reason)
+	}
+	// The event is only recorded for new changes, not when the same changes are approved.
+	if a.recorder != nil && (previous == nil || !strings.Contains(previous.Message, "Digest: "+digest+".")) {
+		a.recorder.Event(machine, corev1.EventTypeWarning, eventReasonPendingChanges, condition.Message)
+	}
+	setCondition(status, condition, metav1.NewTime(a.now()))
+	return nil
+}
+
+// replacementHeld returns whether the pending changes of the machine, which aren't approved yet,
+// keep its instance from being replaced.
+func replacementHeld(status *v1alpha1.AWSMachineProviderStatus) bool {
+	c := findCondition(status, v1alpha1.PendingChanges)
+	return c != nil && c.Reason == reasonApprovalRequired
+}
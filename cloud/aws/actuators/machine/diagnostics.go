@@ -0,0 +1,58 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machine
+
+import (
+	"context"
+
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
+)
+
+// consoleOutputTailBytes is how much of the end of the console output is kept in the machine status.
+const consoleOutputTailBytes = 4096
+
+// collectDiagnostics records the end of the console output of the instance in the status if the
+// machine didn't get a node within the node join timeout. It's collected once per instance.
+func (a *Actuator) collectDiagnostics(ctx context.Context, log logr.Logger, machine *clusterv1.Machine, config *v1alpha1.AWSMachineProviderConfig, instance *ec2svc.Instance, status *v1alpha1.AWSMachineProviderStatus) error {
+	if status.Diagnostics != nil && status.Diagnostics.InstanceID == instance.ID {
+		return nil
+	}
+	if !a.nodeJoinTimedOut(machine, config, instance) {
+		return nil
+	}
+
+	output, err := a.ec2.ConsoleOutput(ctx, instance.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to collect diagnostics")
+	}
+	if len(output) > consoleOutputTailBytes {
+		output = output[len(output)-consoleOutputTailBytes:]
+	}
+
+	status.Diagnostics = &v1alpha1.MachineDiagnostics{
+		InstanceID:     instance.ID,
+		CollectionTime: metav1.NewTime(a.now()),
+		ConsoleOutput:  output,
+	}
+
+	log.Info("Machine didn't get a node in time, collected diagnostics", "instance-id", instance.ID,
+		"node-join-timeout", config.NodeJoinTimeout.Duration, "console-output", output)
+	return nil
+}
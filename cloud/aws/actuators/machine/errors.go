@@ -0,0 +1,76 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machine
+
+import (
+	"time"
+
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api/pkg/apis/cluster/common"
+	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
+	controllerError "sigs.k8s.io/cluster-api/pkg/controller/error"
+)
+
+// capacityRequeueAfter is how long to wait before launching an instance again after AWS
+// lacked the capacity for it or the account reached its limits.
+const capacityRequeueAfter = 5 * time.Minute
+
+// terminalRequeueAfter is how long to wait before launching an instance again after AWS
+// rejected its config or the permissions of the controller. It only succeeds once the user
+// changed them.
+const terminalRequeueAfter = 15 * time.Minute
+
+// createFailed handles an instance that failed to launch by the class of the error. Errors
+// that need the user to act are set as the error of the machine, until an instance is launched.
+func (a *Actuator) createFailed(log logr.Logger, machine *clusterv1.Machine, status *v1alpha1.AWSMachineProviderStatus, err error) error {
+	var reason common.MachineStatusError
+	switch ec2svc.ClassifyError(err) {
+	case ec2svc.ErrorClassCapacity:
+		log.Info("Insufficient capacity to launch instance, requeuing", "reason", err, "requeue-after", capacityRequeueAfter)
+		return &controllerError.RequeueAfterError{RequeueAfter: capacityRequeueAfter}
+	case ec2svc.ErrorClassInvalidConfiguration:
+		reason = common.InvalidConfigurationMachineError
+	case ec2svc.ErrorClassUnauthorized:
+		reason = common.CreateMachineError
+	default:
+		return err
+	}
+
+	machine.Status.ErrorReason = &reason
+	message := err.Error()
+	machine.Status.ErrorMessage = &message
+	if err := a.updateStatus(machine, status); err != nil {
+		return errors.Wrap(err, "failed to update machine status")
+	}
+
+	log.Info("Instance can't be launched until the machine or the permissions change, requeuing", "reason", err, "requeue-after", terminalRequeueAfter)
+	return &controllerError.RequeueAfterError{RequeueAfter: terminalRequeueAfter}
+}
+
+// clearCreateError clears the error of the machine set by createFailed once an instance is
+// launched.
+func clearCreateError(machine *clusterv1.Machine) {
+	if machine.Status.ErrorReason == nil {
+		return
+	}
+	switch *machine.Status.ErrorReason {
+	case common.InvalidConfigurationMachineError, common.CreateMachineError:
+		machine.Status.ErrorReason = nil
+		machine.Status.ErrorMessage = nil
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machine
+
+import (
+	"context"
+
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// reconcileHealth sets the InstanceHealthy condition from the status checks of a running instance,
+// for machine health checks to act on. Instances that are impaired for longer than the impaired
+// instance timeout are terminated, the machine controller then creates a new one.
+func (a *Actuator) reconcileHealth(ctx context.Context, log logr.Logger, config *v1alpha1.AWSMachineProviderConfig, instance *ec2svc.Instance, status *v1alpha1.AWSMachineProviderStatus) error {
+	if instance.State != ec2svc.InstanceStateRunning {
+		return nil
+	}
+
+	checks, err := a.ec2.InstanceStatusChecks(ctx, instance.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance status checks")
+	}
+	if checks == nil {
+		return nil
+	}
+
+	condition := v1alpha1.AWSMachineProviderCondition{Type: v1alpha1.InstanceHealthy}
+	switch {
+	case checks.System == ec2.SummaryStatusImpaired:
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = "SystemStatusImpaired"
+		condition.Message = "The status checks of the AWS systems the instance runs on fail."
+	case checks.Instance == ec2.SummaryStatusImpaired:
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = "InstanceStatusImpaired"
+		condition.Message = "The reachability status checks of the instance fail."
+	case checks.OK():
+		condition.Status = corev1.ConditionTrue
+	default:
+		condition.Status = corev1.ConditionUnknown
+		condition.Reason = "StatusChecksPending"
+		condition.Message = "The status checks of the instance are " + checks.System + "/" + checks.Instance + "."
+	}
+	c := setCondition(status, condition, metav1.NewTime(a.now()))
+
+	if c.Status != corev1.ConditionFalse || config.ImpairedInstanceTimeout == nil || config.InstanceID != nil {
+		return nil
+	}
+	if a.now().Sub(c.LastTransitionTime.Time) < config.ImpairedInstanceTimeout.Duration {
+		return nil
+	}
+
+	if replacementHeld(status) {
+		log.Info("Impaired instance isn't replaced until the pending changes of the machine are approved", "instance-id", instance.ID, "reason", c.Reason)
+		return nil
+	}
+
+	if err := a.ec2.TerminateInstance(ctx, &instance.ID); err != nil {
+		return errors.Wrapf(err, "failed to terminate impaired instance %q", instance.ID)
+	}
+	instance.State = ec2svc.InstanceStateShuttingDown
+	status.InstanceState = aws.String(instance.State)
+	removeCondition(status, v1alpha1.InstanceHealthy)
+
+	log.Info("Terminated impaired instance to be replaced", "instance-id", instance.ID, "reason", c.Reason,
+		"impaired-instance-timeout", config.ImpairedInstanceTimeout.Duration)
+	return nil
+}
+
+// setCondition adds or updates the condition of its type in the status and returns it.
+// The transition time is only changed if the status of the condition changed.
+func setCondition(status *v1alpha1.AWSMachineProviderStatus, condition v1alpha1.AWSMachineProviderCondition, now metav1.Time) *v1alpha1.AWSMachineProviderCondition {
+	condition.LastProbeTime = now
+	condition.LastTransitionTime = now
+
+	for i := range status.Conditions {
+		c := &status.Conditions[i]
+		if c.Type != condition.Type {
+			continue
+		}
+		if c.Status == condition.Status {
+			condition.LastTransitionTime = c.LastTransitionTime
+		}
+		*c = condition
+		return c
+	}
+
+	status.Conditions = append(status.Conditions, condition)
+	return &status.Conditions[len(status.Conditions)-1]
+}
+
+// removeCondition removes the condition of the given type from the status.
+func removeCondition(status *v1alpha1.AWSMachineProviderStatus, conditionType v1alpha1.AWSMachineProviderConditionType) {
+	conditions := status.Conditions[:0]
+	for _, c := range status.Conditions {
+		if c.Type != conditionType {
+			conditions = append(conditions, c)
+		}
+	}
+	status.Conditions = conditions
+}
+
+// findCondition returns the condition of the given type, or nil if the status has none.
+func findCondition(status *v1alpha1.AWSMachineProviderStatus, conditionType v1alpha1.AWSMachineProviderConditionType) *v1alpha1.AWSMachineProviderCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == conditionType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
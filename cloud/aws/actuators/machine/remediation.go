@@ -0,0 +1,75 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machine
+
+import (
+	"context"
+	"fmt"
+
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api/pkg/apis/cluster/common"
+	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
+)
+
+// nodeJoinTimedOut returns whether the instance has been running for longer than the node join
+// timeout, without the machine getting a node.
+func (a *Actuator) nodeJoinTimedOut(machine *clusterv1.Machine, config *v1alpha1.AWSMachineProviderConfig, instance *ec2svc.Instance) bool {
+	if config.NodeJoinTimeout == nil || machine.Status.NodeRef != nil || instance.State != ec2svc.InstanceStateRunning {
+		return false
+	}
+	return !instance.LaunchTime.IsZero() && a.now().Sub(instance.LaunchTime) >= config.NodeJoinTimeout.Duration
+}
+
+// remediateNodeJoin terminates the instance of a machine that didn't get a node within the node
+// join timeout, the machine controller then creates a new one as the machine no longer exists.
+// Once the retries are used up the machine is marked as failed instead.
+func (a *Actuator) remediateNodeJoin(ctx context.Context, log logr.Logger, machine *clusterv1.Machine, config *v1alpha1.AWSMachineProviderConfig, instance *ec2svc.Instance, status *v1alpha1.AWSMachineProviderStatus) error {
+	if config.NodeJoinRetries <= 0 || config.InstanceID != nil || machine.Status.ErrorReason != nil {
+		return nil
+	}
+	if !a.nodeJoinTimedOut(machine, config, instance) {
+		return nil
+	}
+
+	if status.NodeJoinRetries >= config.NodeJoinRetries {
+		reason := common.CreateMachineError
+		machine.Status.ErrorReason = &reason
+		message := fmt.Sprintf("machine didn't get a node within %s after %d retries", config.NodeJoinTimeout.Duration, status.NodeJoinRetries)
+		machine.Status.ErrorMessage = &message
+
+		log.Info("Machine didn't get a node in time, giving up", "instance-id", instance.ID, "node-join-retries", status.NodeJoinRetries)
+		return nil
+	}
+
+	if replacementHeld(status) {
+		log.Info("Machine didn't get a node in time, the instance isn't replaced until the pending changes of the machine are approved", "instance-id", instance.ID)
+		return nil
+	}
+
+	if err := a.ec2.TerminateInstance(ctx, &instance.ID); err != nil {
+		return errors.Wrapf(err, "failed to terminate instance %q", instance.ID)
+	}
+	instance.State = ec2svc.InstanceStateShuttingDown
+	status.InstanceState = aws.String(instance.State)
+	status.NodeJoinRetries++
+
+	log.Info("Machine didn't get a node in time, terminated instance to be replaced", "instance-id", instance.ID,
+		"node-join-timeout", config.NodeJoinTimeout.Duration, "node-join-retries", status.NodeJoinRetries)
+	return nil
+}
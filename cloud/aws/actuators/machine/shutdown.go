@@ -0,0 +1,78 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machine
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
+	controllerError "sigs.k8s.io/cluster-api/pkg/controller/error"
+)
+
+// shutdownRequeueAfter is how often a deleted machine is checked while the operating system of
+// its instance shuts down.
+const shutdownRequeueAfter = 10 * time.Second
+
+// shutdownGracefully shuts down the operating system of the instance of a deleted machine with a
+// graceful shutdown timeout, so that its workloads are stopped and its logs are flushed before
+// it's terminated. It returns true once the instance may be terminated: it stopped, the timeout
+// passed, or it can't be shut down through SSM. Otherwise a requeue error is returned.
+func (a *Actuator) shutdownGracefully(ctx context.Context, log logr.Logger, machine *clusterv1.Machine, config *v1alpha1.AWSMachineProviderConfig, status *v1alpha1.AWSMachineProviderStatus, instance *ec2svc.Instance) (bool, error) {
+	if a.instanceShutdown == nil || config.GracefulShutdownTimeout == nil || instance.State == ec2svc.InstanceStateStopped {
+		return true, nil
+	}
+
+	if status.ShutdownRequestTime == nil {
+		// Only running instances have an operating system to shut down.
+		if instance.State != ec2svc.InstanceStateRunning {
+			return true, nil
+		}
+
+		err := a.instanceShutdown.ShutdownInstance(ctx, instance.ID)
+		if ec2svc.IsNotFound(err) {
+			log.Info("Instance can't be shut down through SSM, terminating it right away", "instance-id", instance.ID, "reason", err)
+			return true, nil
+		}
+		if err != nil {
+			return false, errors.Wrap(err, "failed to shut down instance")
+		}
+
+		now := metav1.NewTime(a.now())
+		status.ShutdownRequestTime = &now
+		if err := a.updateStatus(machine, status); err != nil {
+			return false, errors.Wrap(err, "failed to update machine status")
+		}
+		log.Info("Shutting down instance before terminating it", "instance-id", instance.ID, "timeout", config.GracefulShutdownTimeout.Duration)
+	}
+
+	remaining := config.GracefulShutdownTimeout.Duration - a.now().Sub(status.ShutdownRequestTime.Time)
+	if remaining <= 0 {
+		log.Info("Instance didn't shut down in time, terminating it", "instance-id", instance.ID, "instance-state", instance.State)
+		return true, nil
+	}
+
+	requeueAfter := shutdownRequeueAfter
+	if remaining < requeueAfter {
+		requeueAfter = remaining
+	}
+	log.V(2).Info("Waiting for instance to shut down", "instance-id", instance.ID, "instance-state", instance.State, "requeue-after", requeueAfter)
+	return false, &controllerError.RequeueAfterError{RequeueAfter: requeueAfter}
+}
@@ -0,0 +1,225 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit records every mutating AWS API call made by the controllers.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/go-logr/logr"
+)
+
+// readOnlyPrefixes are the operation name prefixes of AWS API calls that never mutate state.
+var readOnlyPrefixes = []string{"Describe", "List", "Get", "Lookup", "Search"}
+
+// sensitiveFields are the parameter names whose values are never recorded.
+var sensitiveFields = map[string]bool{
+	"UserData":        true,
+	"Password":        true,
+	"SecretAccessKey": true,
+	"SessionToken":    true,
+	"PrivateKey":      true,
+}
+
+// Entry is a single audited AWS API call.
+type Entry struct {
+	Time      time.Time
+	Service   string
+	Operation string
+	RequestID string
+
+	// Cluster is the name of the cluster the call was made for, empty if it's unknown.
+	Cluster string
+
+	// Params is a summary of the request parameters.
+	Params map[string]string
+
+	// Error is the error returned by the call, empty on success.
+	Error string
+}
+
+// Sink stores audit entries.
+type Sink interface {
+	Record(Entry)
+}
+
+// LogSink is a Sink that writes entries to a dedicated logger.
+type LogSink struct {
+	log logr.Logger
+}
+
+// NewLogSink returns a new LogSink writing to the given logger.
+func NewLogSink(log logr.Logger) *LogSink {
+	return &LogSink{log: log}
+}
+
+// Record implements Sink.
+func (s *LogSink) Record(e Entry) {
+	kvs := []interface{}{
+		"cluster", e.Cluster,
+		"service", e.Service,
+		"operation", e.Operation,
+		"request-id", e.RequestID,
+		"params", e.Params,
+	}
+	if e.Error != "" {
+		kvs = append(kvs, "result", "error", "error", e.Error)
+	} else {
+		kvs = append(kvs, "result", "success")
+	}
+	s.log.Info("AWS API call", kvs...)
+}
+
+type clusterKey struct{}
+
+// WithCluster returns a copy of the context that records the calls made with it for the cluster.
+func WithCluster(ctx context.Context, cluster string) context.Context {
+	return context.WithValue(ctx, clusterKey{}, cluster)
+}
+
+// ClusterFrom returns the name of the cluster the calls made with the context are for, or an
+// empty string if it's unknown.
+func ClusterFrom(ctx context.Context) string {
+	cluster, _ := ctx.Value(clusterKey{}).(string)
+	return cluster
+}
+
+// Handler returns an SDK handler that records every mutating AWS API call in the sink.
+// It should be pushed onto the Complete handler list of a session so that the final
+// result of the call, after retries, is recorded.
+func Handler(sink Sink) request.NamedHandler {
+	return request.NamedHandler{
+		Name: "awsprovider.audit.Handler",
+		Fn: func(r *request.Request) {
+			if r.Operation == nil || !IsMutating(r.Operation.Name) {
+				return
+			}
+
+			e := Entry{
+				Time:      time.Now(),
+				Service:   r.ClientInfo.ServiceName,
+				Operation: r.Operation.Name,
+				RequestID: r.RequestID,
+				Cluster:   ClusterFrom(r.Context()),
+				Params:    Summarize(r.Params),
+			}
+			if r.Error != nil {
+				e.Error = r.Error.Error()
+			}
+			sink.Record(e)
+		},
+	}
+}
+
+// IsMutating returns true if the AWS API operation can change the state of a resource.
+func IsMutating(operation string) bool {
+	for _, p := range readOnlyPrefixes {
+		if strings.HasPrefix(operation, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// Summarize returns the fields of an SDK input struct as strings. Nested structures, slices and
+// maps are serialized in full, sensitive fields are redacted at any depth.
+func Summarize(params interface{}) map[string]string {
+	res := make(map[string]string)
+
+	v := reflect.ValueOf(params)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return res
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return res
+	}
+
+	summarizeFields(v, func(name, value string) {
+		res[name] = value
+	})
+	return res
+}
+
+// summarizeFields calls fn with the name and summary of every set exported field of the struct.
+func summarizeFields(v reflect.Value, fn func(name, value string)) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.PkgPath != "" {
+			// Unexported.
+			continue
+		}
+
+		value := v.Field(i)
+		if isEmpty(value) {
+			continue
+		}
+
+		if sensitiveFields[field.Name] {
+			fn(field.Name, "<redacted>")
+			continue
+		}
+
+		fn(field.Name, summarizeValue(value))
+	}
+}
+
+func summarizeValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return "<nil>"
+		}
+		return summarizeValue(v.Elem())
+	case reflect.Struct:
+		if t, ok := v.Interface().(time.Time); ok {
+			return t.UTC().Format(time.RFC3339)
+		}
+		fields := []string{}
+		summarizeFields(v, func(name, value string) {
+			fields = append(fields, name+":"+value)
+		})
+		return "{" + strings.Join(fields, ",") + "}"
+	case reflect.Slice:
+		items := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, summarizeValue(v.Index(i)))
+		}
+		return "[" + strings.Join(items, ",") + "]"
+	case reflect.Map:
+		items := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			items = append(items, fmt.Sprint(k.Interface())+":"+summarizeValue(v.MapIndex(k)))
+		}
+		sort.Strings(items)
+		return "{" + strings.Join(items, ",") + "}"
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return v.IsNil()
+	}
+	return false
+}
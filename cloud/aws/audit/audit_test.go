@@ -0,0 +1,146 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+type fakeSink struct {
+	entries []Entry
+}
+
+func (f *fakeSink) Record(e Entry) {
+	f.entries = append(f.entries, e)
+}
+
+func TestSummarize(t *testing.T) {
+	input := &ec2.RunInstancesInput{
+		ImageId:      aws.String("ami-1"),
+		InstanceType: aws.String("m5.large"),
+		MaxCount:     aws.Int64(1),
+		UserData:     aws.String("c2VjcmV0"),
+		SecurityGroupIds: []*string{
+			aws.String("sg-1"),
+			aws.String("sg-2"),
+		},
+		Placement: &ec2.Placement{AvailabilityZone: aws.String("us-east-1a")},
+		TagSpecifications: []*ec2.TagSpecification{
+			{
+				ResourceType: aws.String("instance"),
+				Tags: []*ec2.Tag{
+					{Key: aws.String("Name"), Value: aws.String("test-node")},
+				},
+			},
+		},
+	}
+
+	expected := map[string]string{
+		"ImageId":           "ami-1",
+		"InstanceType":      "m5.large",
+		"MaxCount":          "1",
+		"UserData":          "<redacted>",
+		"SecurityGroupIds":  "[sg-1,sg-2]",
+		"Placement":         "{AvailabilityZone:us-east-1a}",
+		"TagSpecifications": "[{ResourceType:instance,Tags:[{Key:Name,Value:test-node}]}]",
+	}
+
+	if got := Summarize(input); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestSummarizeRedactsNestedFields(t *testing.T) {
+	input := &ec2.CreateLaunchTemplateInput{
+		LaunchTemplateName: aws.String("test-node"),
+		LaunchTemplateData: &ec2.RequestLaunchTemplateData{
+			ImageId:  aws.String("ami-1"),
+			UserData: aws.String("c2VjcmV0"),
+		},
+	}
+
+	expected := map[string]string{
+		"LaunchTemplateName": "test-node",
+		"LaunchTemplateData": "{ImageId:ami-1,UserData:<redacted>}",
+	}
+
+	if got := Summarize(input); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	testCases := []struct {
+		name      string
+		operation string
+		err       error
+		expected  []Entry
+	}{
+		{
+			name:      "read only call is not recorded",
+			operation: "DescribeVpcs",
+		},
+		{
+			name:      "mutating call is recorded",
+			operation: "CreateVpc",
+			expected: []Entry{
+				{Service: "ec2", Operation: "CreateVpc", RequestID: "req-1", Cluster: "test", Params: map[string]string{"CidrBlock": "10.0.0.0/16"}},
+			},
+		},
+		{
+			name:      "failed mutating call is recorded with its error",
+			operation: "DeleteVpc",
+			err:       errors.New("DependencyViolation"),
+			expected: []Entry{
+				{Service: "ec2", Operation: "DeleteVpc", RequestID: "req-1", Cluster: "test", Params: map[string]string{"CidrBlock": "10.0.0.0/16"}, Error: "DependencyViolation"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sink := &fakeSink{}
+			r := &request.Request{
+				Operation:   &request.Operation{Name: tc.operation},
+				Params:      &ec2.CreateVpcInput{CidrBlock: aws.String("10.0.0.0/16")},
+				HTTPRequest: &http.Request{},
+				RequestID:   "req-1",
+				Error:       tc.err,
+			}
+			r.ClientInfo.ServiceName = "ec2"
+			r.SetContext(WithCluster(context.Background(), "test"))
+
+			Handler(sink).Fn(r)
+
+			for i := range sink.entries {
+				sink.entries[i].Time = sink.entries[i].Time.UTC()
+				if sink.entries[i].Time.IsZero() {
+					t.Fatalf("expected entry time to be set")
+				}
+				tc.expected[i].Time = sink.entries[i].Time
+			}
+			if !reflect.DeepEqual(sink.entries, tc.expected) {
+				t.Fatalf("expected %+v, got %+v", tc.expected, sink.entries)
+			}
+		})
+	}
+}
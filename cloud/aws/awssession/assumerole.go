@@ -0,0 +1,41 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awssession
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// AssumedRoleSessionName is the name of the sessions of the roles assumed with AssumeRole.
+const AssumedRoleSessionName = "cluster-api-provider-aws"
+
+// AssumeRole returns a copy of the session whose credentials are the ones of the role, assumed with
+// the credentials of the session, like a role of a shared account. The external id is only set if
+// not empty. The credentials are refreshed before they expire.
+func AssumeRole(sess *session.Session, roleARN, externalID string) *session.Session {
+	return assumeRole(sess, sts.New(sess), roleARN, externalID)
+}
+
+func assumeRole(sess *session.Session, client stscreds.AssumeRoler, roleARN, externalID string) *session.Session {
+	creds := stscreds.NewCredentialsWithClient(client, roleARN, func(p *stscreds.AssumeRoleProvider) {
+		p.RoleSessionName = AssumedRoleSessionName
+		if externalID != "" {
+			p.ExternalID = aws.String(externalID)
+		}
+	})
+	return sess.Copy(aws.NewConfig().WithCredentials(creds))
+}
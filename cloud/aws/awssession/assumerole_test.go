@@ -0,0 +1,87 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awssession
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// fakeAssumeRoler returns credentials named after the role they were assumed for.
+type fakeAssumeRoler struct {
+	inputs []*sts.AssumeRoleInput
+}
+
+func (f *fakeAssumeRoler) AssumeRole(in *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+	f.inputs = append(f.inputs, in)
+	return &sts.AssumeRoleOutput{
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String("key-" + aws.StringValue(in.RoleArn)),
+			SecretAccessKey: aws.String("secret"),
+			SessionToken:    aws.String("session"),
+			Expiration:      aws.Time(time.Now().Add(time.Hour)),
+		},
+	}, nil
+}
+
+func TestAssumeRole(t *testing.T) {
+	sess, err := session.NewSession(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithCredentials(credentials.NewStaticCredentials("key", "secret", "")))
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	roleARN := "arn:aws:iam::210987654321:role/dns"
+	f := &fakeAssumeRoler{}
+	assumed := assumeRole(sess, f, roleARN, "external")
+
+	creds, err := assumed.Config.Credentials.Get()
+	if err != nil {
+		t.Fatalf("failed to retrieve credentials: %v", err)
+	}
+	if creds.AccessKeyID != "key-"+roleARN {
+		t.Fatalf("expected the credentials of the assumed role, got: %+v", creds)
+	}
+	if aws.StringValue(assumed.Config.Region) != "us-east-1" {
+		t.Fatalf("expected the region of the session, got: %q", aws.StringValue(assumed.Config.Region))
+	}
+	in := f.inputs[0]
+	if aws.StringValue(in.RoleArn) != roleARN || aws.StringValue(in.ExternalId) != "external" || aws.StringValue(in.RoleSessionName) != AssumedRoleSessionName {
+		t.Fatalf("unexpected assume role input: %v", in)
+	}
+
+	// The session the role was assumed with keeps its own credentials.
+	creds, err = sess.Config.Credentials.Get()
+	if err != nil {
+		t.Fatalf("failed to retrieve credentials: %v", err)
+	}
+	if creds.AccessKeyID != "key" {
+		t.Fatalf("expected the credentials of the session to be kept, got: %+v", creds)
+	}
+
+	// No external id is sent if none is set.
+	f = &fakeAssumeRoler{}
+	if _, err := assumeRole(sess, f, roleARN, "").Config.Credentials.Get(); err != nil {
+		t.Fatalf("failed to retrieve credentials: %v", err)
+	}
+	if in := f.inputs[0]; in.ExternalId != nil {
+		t.Fatalf("expected no external id, got: %q", aws.StringValue(in.ExternalId))
+	}
+}
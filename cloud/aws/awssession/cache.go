@@ -0,0 +1,92 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awssession
+
+import (
+	"container/list"
+	"os"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// Key identifies the clients of a cluster. Clients of different regions or roles are never
+// shared, so that credentials of one account aren't used for another.
+type Key struct {
+	Region  string
+	RoleARN string
+	Cluster string
+}
+
+// KeyFor returns the key of the clients of the cluster created with the session.
+func KeyFor(sess *session.Session, clusterName string) Key {
+	return Key{
+		Region:  aws.StringValue(sess.Config.Region),
+		RoleARN: os.Getenv(EnvRoleARN),
+		Cluster: clusterName,
+	}
+}
+
+// Cache keeps the clients created for the most recently used keys, so that they aren't
+// created again on every reconcile. Once it's full, the least recently used client is dropped.
+// All methods are safe for concurrent use.
+type Cache struct {
+	size int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[Key]*list.Element
+}
+
+type cacheEntry struct {
+	key    Key
+	client interface{}
+}
+
+// NewCache returns a cache of up to size clients.
+func NewCache(size int) *Cache {
+	return &Cache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[Key]*list.Element),
+	}
+}
+
+// Get returns the client of the key, created with create if it isn't cached.
+func (c *Cache) Get(key Key, create func() interface{}) interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*cacheEntry).client
+	}
+
+	client := create()
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, client: client})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+	return client
+}
+
+// Len returns the number of cached clients.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
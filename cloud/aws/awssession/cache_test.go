@@ -0,0 +1,52 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awssession
+
+import (
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	c := NewCache(2)
+	created := 0
+	get := func(key Key) interface{} {
+		return c.Get(key, func() interface{} {
+			created++
+			return created
+		})
+	}
+
+	a := Key{Region: "eu-west-1", Cluster: "a"}
+	b := Key{Region: "eu-west-1", Cluster: "b"}
+	otherRegion := Key{Region: "us-east-1", Cluster: "a"}
+
+	if get(a) != 1 || get(a) != 1 {
+		t.Fatalf("expected the client of a to be created once")
+	}
+	if get(otherRegion) != 2 {
+		t.Fatalf("expected clients of another region not to be shared")
+	}
+
+	// a was used more recently than otherRegion, which is dropped.
+	get(a)
+	if get(b) != 3 || c.Len() != 2 {
+		t.Fatalf("expected the cache to be bounded, got %d clients", c.Len())
+	}
+	if get(a) != 1 {
+		t.Fatalf("expected the most recently used client to be kept")
+	}
+	if get(otherRegion) != 4 {
+		t.Fatalf("expected the least recently used client to be dropped")
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awssession
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/pkg/errors"
+)
+
+// FileProviderName is the provider name of credentials read from a credentials file.
+const FileProviderName = "FileProvider"
+
+// FileProvider retrieves credentials from a shared credentials file, like one mounted from a
+// Secret. Unlike the SDK's shared credentials provider, the credentials expire when the file
+// changes, so rotated keys are used without restarting the controller.
+type FileProvider struct {
+	// Filename is the credentials file.
+	Filename string
+	// Profile is the profile of the credentials in the file. If empty, AWS_PROFILE or default is
+	// used.
+	Profile string
+
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+// Retrieve implements credentials.Provider.
+func (p *FileProvider) Retrieve() (credentials.Value, error) {
+	info, err := os.Stat(p.Filename)
+	if err != nil {
+		return credentials.Value{ProviderName: FileProviderName}, errors.Wrap(err, "failed to read credentials file")
+	}
+
+	creds, err := (&credentials.SharedCredentialsProvider{Filename: p.Filename, Profile: p.Profile}).Retrieve()
+	if err != nil {
+		return credentials.Value{ProviderName: FileProviderName}, errors.Wrapf(err, "failed to read credentials file %q", p.Filename)
+	}
+
+	p.mu.Lock()
+	p.modTime = info.ModTime()
+	p.mu.Unlock()
+
+	creds.ProviderName = FileProviderName
+	return creds, nil
+}
+
+// IsExpired implements credentials.Provider. The credentials are expired once the file was
+// modified or replaced since they were read.
+func (p *FileProvider) IsExpired() bool {
+	info, err := os.Stat(p.Filename)
+	if err != nil {
+		// Keep using the credentials while a Secret update swaps the file.
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !info.ModTime().Equal(p.modTime)
+}
+
+// authErrorCodes are the error codes of calls made with credentials that were revoked or have
+// expired.
+var authErrorCodes = map[string]bool{
+	"AuthFailure":                 true,
+	"ExpiredToken":                true,
+	"ExpiredTokenException":       true,
+	"InvalidAccessKeyId":          true,
+	"InvalidClientTokenId":        true,
+	"UnrecognizedClientException": true,
+}
+
+// ExpireOnAuthErrorHandler expires the credentials of requests failing because of them, so that
+// the next request retrieves new ones, like keys rotated out of band.
+var ExpireOnAuthErrorHandler = request.NamedHandler{
+	Name: "awssession.ExpireOnAuthErrorHandler",
+	Fn: func(r *request.Request) {
+		aerr, ok := r.Error.(awserr.Error)
+		if !ok || !authErrorCodes[aerr.Code()] || r.Config.Credentials == nil {
+			return
+		}
+		r.Config.Credentials.Expire()
+	},
+}
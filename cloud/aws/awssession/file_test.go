@@ -0,0 +1,100 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awssession
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+func writeCredentials(t *testing.T, filename, key string, modTime time.Time) {
+	data := fmt.Sprintf("[default]\naws_access_key_id = %s\naws_secret_access_key = secret\n", key)
+	if err := ioutil.WriteFile(filename, []byte(data), 0600); err != nil {
+		t.Fatalf("failed to write credentials: %v", err)
+	}
+	if err := os.Chtimes(filename, modTime, modTime); err != nil {
+		t.Fatalf("failed to set modification time: %v", err)
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "credentials")
+	now := time.Now()
+	writeCredentials(t, filename, "key-1", now)
+
+	creds := credentials.NewCredentials(&FileProvider{Filename: filename, Profile: "default"})
+	v, err := creds.Get()
+	if err != nil {
+		t.Fatalf("failed to retrieve credentials: %v", err)
+	}
+	if v.AccessKeyID != "key-1" || v.ProviderName != FileProviderName {
+		t.Fatalf("unexpected credentials: %+v", v)
+	}
+	if creds.IsExpired() {
+		t.Fatalf("expected the credentials to be valid until the file changes")
+	}
+
+	// Rotated keys are used once the file changes.
+	writeCredentials(t, filename, "key-2", now.Add(time.Minute))
+	v, err = creds.Get()
+	if err != nil {
+		t.Fatalf("failed to retrieve credentials: %v", err)
+	}
+	if v.AccessKeyID != "key-2" {
+		t.Fatalf("expected the rotated credentials, got: %+v", v)
+	}
+}
+
+func TestExpireOnAuthErrorHandler(t *testing.T) {
+	testCases := []struct {
+		name    string
+		err     error
+		expired bool
+	}{
+		{name: "auth failure", err: awserr.New("AuthFailure", "", nil), expired: true},
+		{name: "expired token", err: awserr.New("ExpiredToken", "", nil), expired: true},
+		{name: "other error", err: awserr.New("InvalidVpcID.NotFound", "", nil)},
+		{name: "no error"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			creds := credentials.NewStaticCredentials("key", "secret", "")
+			if _, err := creds.Get(); err != nil {
+				t.Fatalf("failed to retrieve credentials: %v", err)
+			}
+
+			r := &request.Request{Config: aws.Config{Credentials: creds}, Error: tc.err}
+			ExpireOnAuthErrorHandler.Fn(r)
+			if creds.IsExpired() != tc.expired {
+				t.Fatalf("expected expired %v, got %v", tc.expired, creds.IsExpired())
+			}
+		})
+	}
+}
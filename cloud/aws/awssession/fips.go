@@ -0,0 +1,63 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awssession
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+)
+
+// fipsServices are the endpoint ids of the services called by the controllers that have FIPS
+// endpoints in the US and Canada regions.
+var fipsServices = map[string]bool{
+	"ec2":                  true,
+	"elasticfilesystem":    true,
+	"elasticloadbalancing": true,
+	"iam":                  true,
+	"resource-groups":      true,
+	"s3":                   true,
+	"sts":                  true,
+}
+
+// hasFIPSEndpoints returns whether the region has FIPS endpoints. The default endpoints of the
+// GovCloud regions are FIPS endpoints already.
+func hasFIPSEndpoints(region string) bool {
+	return (strings.HasPrefix(region, "us-") || strings.HasPrefix(region, "ca-")) && !strings.HasPrefix(region, "us-gov-")
+}
+
+// FIPSResolver resolves the FIPS endpoint of the services that have one in the region, and the
+// endpoint of the given resolver otherwise.
+func FIPSResolver(resolver endpoints.Resolver) endpoints.Resolver {
+	return endpoints.ResolverFunc(func(service, region string, opts ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+		if !fipsServices[service] || !hasFIPSEndpoints(region) {
+			return resolver.EndpointFor(service, region, opts...)
+		}
+
+		// IAM is a global service, signed for us-east-1.
+		if service == "iam" {
+			return endpoints.ResolvedEndpoint{
+				URL:           "https://iam-fips.amazonaws.com",
+				SigningRegion: "us-east-1",
+				SigningMethod: "v4",
+			}, nil
+		}
+		return endpoints.ResolvedEndpoint{
+			URL:           fmt.Sprintf("https://%s-fips.%s.amazonaws.com", service, region),
+			SigningRegion: region,
+			SigningMethod: "v4",
+		}, nil
+	})
+}
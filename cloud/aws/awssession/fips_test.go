@@ -0,0 +1,48 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awssession
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+)
+
+func TestFIPSResolver(t *testing.T) {
+	r := FIPSResolver(endpoints.DefaultResolver())
+
+	testCases := []struct {
+		service, region string
+		url             string
+		signingRegion   string
+	}{
+		{service: "ec2", region: "us-east-1", url: "https://ec2-fips.us-east-1.amazonaws.com", signingRegion: "us-east-1"},
+		{service: "elasticloadbalancing", region: "ca-central-1", url: "https://elasticloadbalancing-fips.ca-central-1.amazonaws.com", signingRegion: "ca-central-1"},
+		{service: "iam", region: "us-west-2", url: "https://iam-fips.amazonaws.com", signingRegion: "us-east-1"},
+		// Regions without FIPS endpoints and services without them use the default endpoints.
+		{service: "ec2", region: "eu-west-1", url: "https://ec2.eu-west-1.amazonaws.com", signingRegion: "eu-west-1"},
+		{service: "ec2", region: "us-gov-west-1", url: "https://ec2.us-gov-west-1.amazonaws.com", signingRegion: "us-gov-west-1"},
+		{service: "api.pricing", region: "us-east-1", url: "https://api.pricing.us-east-1.amazonaws.com", signingRegion: "us-east-1"},
+	}
+
+	for _, tc := range testCases {
+		e, err := r.EndpointFor(tc.service, tc.region)
+		if err != nil {
+			t.Fatalf("failed to resolve endpoint of %s in %s: %v", tc.service, tc.region, err)
+		}
+		if e.URL != tc.url || e.SigningRegion != tc.signingRegion {
+			t.Fatalf("expected endpoint %s signed for %s for %s in %s, got: %+v", tc.url, tc.signingRegion, tc.service, tc.region, e)
+		}
+	}
+}
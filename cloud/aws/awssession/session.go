@@ -0,0 +1,129 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package awssession builds the AWS session of the controllers from their environment.
+package awssession
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/pkg/errors"
+)
+
+const (
+	// EnvWebIdentityTokenFile is the file of the web identity token, set for IAM roles for
+	// service accounts.
+	EnvWebIdentityTokenFile = "AWS_WEB_IDENTITY_TOKEN_FILE"
+	// EnvRoleARN is the role assumed with the web identity token.
+	EnvRoleARN = "AWS_ROLE_ARN"
+	// EnvRoleSessionName is the name of the sessions of the assumed role.
+	EnvRoleSessionName = "AWS_ROLE_SESSION_NAME"
+	// EnvSTSRegionalEndpoints is regional to use the STS endpoint of the region of the session.
+	EnvSTSRegionalEndpoints = "AWS_STS_REGIONAL_ENDPOINTS"
+)
+
+// Options configure the session.
+type Options struct {
+	// DefaultRegion is the region of the session if the environment configures none.
+	DefaultRegion string
+	// STSRegionalEndpoint makes STS calls go to the endpoint of the region of the session instead
+	// of the global one. It's also set by AWS_STS_REGIONAL_ENDPOINTS=regional.
+	STSRegionalEndpoint bool
+	// CredentialsFile is a shared credentials file the credentials are read from instead of the
+	// environment. They are read again whenever it changes.
+	CredentialsFile string
+	// FIPSEndpoints makes the calls to the services that have FIPS endpoints in the region of the
+	// session go to them.
+	FIPSEndpoints bool
+}
+
+// New returns a session configured by the environment, like the default SDK session. If a web
+// identity token file and a role are set, as for IAM roles for service accounts on EKS, its
+// credentials are the ones of the role assumed with the token, otherwise the ones of the
+// credentials file if one is set. Credentials rejected by AWS are
+// retrieved again by the next request.
+func New(opts Options) (*session.Session, error) {
+	sess, err := newSession(opts)
+	if err != nil {
+		return nil, err
+	}
+	sess.Handlers.Complete.PushBackNamed(ExpireOnAuthErrorHandler)
+	return sess, nil
+}
+
+func newSession(opts Options) (*session.Session, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create aws session")
+	}
+	if aws.StringValue(sess.Config.Region) == "" && opts.DefaultRegion != "" {
+		sess.Config.Region = aws.String(opts.DefaultRegion)
+	}
+	if opts.FIPSEndpoints {
+		sess.Config.EndpointResolver = FIPSResolver(sess.Config.EndpointResolver)
+	}
+
+	tokenFile, roleARN := os.Getenv(EnvWebIdentityTokenFile), os.Getenv(EnvRoleARN)
+	if tokenFile == "" && roleARN == "" {
+		if opts.CredentialsFile != "" {
+			sess.Config.Credentials = credentials.NewCredentials(&FileProvider{Filename: opts.CredentialsFile})
+		}
+		return sess, nil
+	}
+	if opts.CredentialsFile != "" {
+		return nil, errors.New("a credentials file can't be used to assume a role with a web identity")
+	}
+	if tokenFile == "" || roleARN == "" {
+		return nil, errors.Errorf("both %s and %s must be set to assume a role with a web identity", EnvWebIdentityTokenFile, EnvRoleARN)
+	}
+
+	// The web identity token is the only credential of the call assuming the role.
+	stsConfig := aws.NewConfig().WithCredentials(credentials.AnonymousCredentials)
+	// The FIPS endpoints of STS are regional already.
+	fips := opts.FIPSEndpoints && hasFIPSEndpoints(aws.StringValue(sess.Config.Region))
+	if !fips && (opts.STSRegionalEndpoint || strings.EqualFold(os.Getenv(EnvSTSRegionalEndpoints), "regional")) {
+		endpoint, err := stsRegionalEndpoint(aws.StringValue(sess.Config.Region))
+		if err != nil {
+			return nil, err
+		}
+		stsConfig = stsConfig.WithEndpoint(endpoint)
+	}
+
+	provider := &WebIdentityProvider{
+		STS:         sts.New(sess, stsConfig),
+		TokenFile:   tokenFile,
+		RoleARN:     roleARN,
+		SessionName: os.Getenv(EnvRoleSessionName),
+	}
+	sess.Config.Credentials = credentials.NewCredentials(provider)
+	return sess, nil
+}
+
+// stsRegionalEndpoint returns the STS endpoint of the region.
+func stsRegionalEndpoint(region string) (string, error) {
+	if region == "" {
+		return "", errors.New("failed to use a regional sts endpoint: the session has no region")
+	}
+
+	suffix := "amazonaws.com"
+	if strings.HasPrefix(region, "cn-") {
+		suffix = "amazonaws.com.cn"
+	}
+	return fmt.Sprintf("https://sts.%s.%s", region, suffix), nil
+}
@@ -0,0 +1,86 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awssession
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/pkg/errors"
+)
+
+// WebIdentityProviderName is the provider name of credentials of a role assumed with a web identity.
+const WebIdentityProviderName = "WebIdentityProvider"
+
+// expiryWindow is how long before they expire credentials are refreshed, so that calls in
+// flight don't fail.
+const expiryWindow = 5 * time.Minute
+
+// WebIdentityProvider retrieves the credentials of a role by assuming it with a web identity
+// token, like the projected service account token of IAM roles for service accounts. The token
+// file is read on every retrieval, since it's rotated while the controller runs.
+type WebIdentityProvider struct {
+	credentials.Expiry
+
+	// STS assumes the role, its calls don't need credentials.
+	STS stsiface.STSAPI
+	// TokenFile is the file of the web identity token.
+	TokenFile string
+	// RoleARN is the role to assume.
+	RoleARN string
+	// SessionName is the name of the session of the assumed role. If empty, one is generated.
+	SessionName string
+	// Duration is how long the credentials are valid. If zero, the default of STS is used.
+	Duration time.Duration
+}
+
+// Retrieve implements credentials.Provider.
+func (p *WebIdentityProvider) Retrieve() (credentials.Value, error) {
+	token, err := ioutil.ReadFile(p.TokenFile)
+	if err != nil {
+		return credentials.Value{ProviderName: WebIdentityProviderName}, errors.Wrap(err, "failed to read web identity token")
+	}
+
+	name := p.SessionName
+	if name == "" {
+		name = fmt.Sprintf("cluster-api-provider-aws-%d", time.Now().UnixNano())
+	}
+	input := &sts.AssumeRoleWithWebIdentityInput{
+		RoleArn:          aws.String(p.RoleARN),
+		RoleSessionName:  aws.String(name),
+		WebIdentityToken: aws.String(strings.TrimSpace(string(token))),
+	}
+	if p.Duration != 0 {
+		input.DurationSeconds = aws.Int64(int64(p.Duration / time.Second))
+	}
+
+	out, err := p.STS.AssumeRoleWithWebIdentity(input)
+	if err != nil {
+		return credentials.Value{ProviderName: WebIdentityProviderName}, errors.Wrapf(err, "failed to assume role %q with web identity", p.RoleARN)
+	}
+
+	p.SetExpiration(aws.TimeValue(out.Credentials.Expiration), expiryWindow)
+	return credentials.Value{
+		AccessKeyID:     aws.StringValue(out.Credentials.AccessKeyId),
+		SecretAccessKey: aws.StringValue(out.Credentials.SecretAccessKey),
+		SessionToken:    aws.StringValue(out.Credentials.SessionToken),
+		ProviderName:    WebIdentityProviderName,
+	}, nil
+}
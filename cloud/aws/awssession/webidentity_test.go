@@ -0,0 +1,106 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awssession
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// fakeSTS returns credentials named after the token they were assumed with.
+type fakeSTS struct {
+	stsiface.STSAPI
+
+	inputs []*sts.AssumeRoleWithWebIdentityInput
+}
+
+func (f *fakeSTS) AssumeRoleWithWebIdentity(in *sts.AssumeRoleWithWebIdentityInput) (*sts.AssumeRoleWithWebIdentityOutput, error) {
+	f.inputs = append(f.inputs, in)
+	return &sts.AssumeRoleWithWebIdentityOutput{
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String("key-" + aws.StringValue(in.WebIdentityToken)),
+			SecretAccessKey: aws.String("secret"),
+			SessionToken:    aws.String("session"),
+			Expiration:      aws.Time(time.Now().Add(time.Hour)),
+		},
+	}, nil
+}
+
+func TestWebIdentityProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webidentity")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("token-1\n"), 0600); err != nil {
+		t.Fatalf("failed to write token: %v", err)
+	}
+
+	f := &fakeSTS{}
+	p := &WebIdentityProvider{STS: f, TokenFile: tokenFile, RoleARN: "arn:aws:iam::123456789012:role/capa"}
+
+	creds, err := p.Retrieve()
+	if err != nil {
+		t.Fatalf("failed to retrieve credentials: %v", err)
+	}
+	if creds.AccessKeyID != "key-token-1" || creds.ProviderName != WebIdentityProviderName {
+		t.Fatalf("unexpected credentials: %+v", creds)
+	}
+	if p.IsExpired() {
+		t.Fatalf("expected the credentials to be valid until they expire")
+	}
+	if in := f.inputs[0]; aws.StringValue(in.RoleArn) != p.RoleARN || aws.StringValue(in.RoleSessionName) == "" {
+		t.Fatalf("unexpected assume role input: %v", in)
+	}
+
+	// A rotated token is used for the next credentials.
+	if err := ioutil.WriteFile(tokenFile, []byte("token-2"), 0600); err != nil {
+		t.Fatalf("failed to write token: %v", err)
+	}
+	creds, err = p.Retrieve()
+	if err != nil {
+		t.Fatalf("failed to retrieve credentials: %v", err)
+	}
+	if creds.AccessKeyID != "key-token-2" {
+		t.Fatalf("expected credentials of the rotated token, got: %+v", creds)
+	}
+}
+
+func TestSTSRegionalEndpoint(t *testing.T) {
+	testCases := []struct {
+		region      string
+		expected    string
+		expectedErr bool
+	}{
+		{region: "eu-west-1", expected: "https://sts.eu-west-1.amazonaws.com"},
+		{region: "cn-north-1", expected: "https://sts.cn-north-1.amazonaws.com.cn"},
+		{region: "", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		endpoint, err := stsRegionalEndpoint(tc.region)
+		if tc.expectedErr != (err != nil) || endpoint != tc.expected {
+			t.Fatalf("region %q: expected %q, got %q, %v", tc.region, tc.expected, endpoint, err)
+		}
+	}
+}
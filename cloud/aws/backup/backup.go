@@ -0,0 +1,186 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package backup takes backups of the cluster-api objects of a management cluster, whose
+// provider configs and statuses hold the ids and tags of the AWS resources of the clusters, so
+// that another management cluster can adopt the resources after the objects are restored, and
+// moves the objects between management clusters.
+package backup
+
+import (
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
+	client "sigs.k8s.io/cluster-api/pkg/client/clientset_generated/clientset/typed/cluster/v1alpha1"
+)
+
+// Backup holds the cluster-api objects of a management cluster.
+type Backup struct {
+	// Time is when the backup was taken.
+	Time metav1.Time `json:"time"`
+
+	Clusters           []clusterv1.Cluster           `json:"clusters,omitempty"`
+	MachineDeployments []clusterv1.MachineDeployment `json:"machineDeployments,omitempty"`
+	MachineSets        []clusterv1.MachineSet        `json:"machineSets,omitempty"`
+	Machines           []clusterv1.Machine           `json:"machines,omitempty"`
+}
+
+// Take lists the cluster-api objects in the namespace, or in all namespaces if empty.
+func Take(c client.ClusterV1alpha1Interface, namespace string) (*Backup, error) {
+	b := &Backup{Time: metav1.Now()}
+
+	clusters, err := c.Clusters(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list clusters")
+	}
+	b.Clusters = clusters.Items
+
+	deployments, err := c.MachineDeployments(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list machine deployments")
+	}
+	b.MachineDeployments = deployments.Items
+
+	sets, err := c.MachineSets(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list machine sets")
+	}
+	b.MachineSets = sets.Items
+
+	machines, err := c.Machines(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list machines")
+	}
+	b.Machines = machines.Items
+
+	return b, nil
+}
+
+// Restore creates the objects of the backup, with their statuses, that don't exist yet.
+// Objects that were being deleted when the backup was taken aren't restored. The provider
+// config of every restored machine adopts the instance in its status, so that no instance is
+// created for a machine that already has one.
+func Restore(c client.ClusterV1alpha1Interface, codec *v1alpha1.AWSProviderConfigCodec, b *Backup) error {
+	for i := range b.Clusters {
+		cluster := b.Clusters[i].DeepCopy()
+		if cluster.DeletionTimestamp != nil {
+			continue
+		}
+		status := cluster.Status
+		resetObjectMeta(&cluster.ObjectMeta)
+
+		created, err := c.Clusters(cluster.Namespace).Create(cluster)
+		if apierrors.IsAlreadyExists(err) {
+			continue
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to create cluster %s/%s", cluster.Namespace, cluster.Name)
+		}
+		created.Status = status
+		if _, err := c.Clusters(created.Namespace).UpdateStatus(created); err != nil {
+			return errors.Wrapf(err, "failed to restore the status of cluster %s/%s", created.Namespace, created.Name)
+		}
+	}
+
+	for i := range b.MachineDeployments {
+		deployment := b.MachineDeployments[i].DeepCopy()
+		if deployment.DeletionTimestamp != nil {
+			continue
+		}
+		resetObjectMeta(&deployment.ObjectMeta)
+
+		_, err := c.MachineDeployments(deployment.Namespace).Create(deployment)
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return errors.Wrapf(err, "failed to create machine deployment %s/%s", deployment.Namespace, deployment.Name)
+		}
+	}
+
+	for i := range b.MachineSets {
+		set := b.MachineSets[i].DeepCopy()
+		if set.DeletionTimestamp != nil {
+			continue
+		}
+		resetObjectMeta(&set.ObjectMeta)
+
+		_, err := c.MachineSets(set.Namespace).Create(set)
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return errors.Wrapf(err, "failed to create machine set %s/%s", set.Namespace, set.Name)
+		}
+	}
+
+	for i := range b.Machines {
+		machine := b.Machines[i].DeepCopy()
+		if machine.DeletionTimestamp != nil {
+			continue
+		}
+		if err := AdoptInstance(codec, machine); err != nil {
+			return err
+		}
+		status := machine.Status
+		resetObjectMeta(&machine.ObjectMeta)
+
+		created, err := c.Machines(machine.Namespace).Create(machine)
+		if apierrors.IsAlreadyExists(err) {
+			continue
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to create machine %s/%s", machine.Namespace, machine.Name)
+		}
+		created.Status = status
+		if _, err := c.Machines(created.Namespace).UpdateStatus(created); err != nil {
+			return errors.Wrapf(err, "failed to restore the status of machine %s/%s", created.Namespace, created.Name)
+		}
+	}
+
+	return nil
+}
+
+// AdoptInstance sets the instance id of the provider config of the machine to the instance in
+// its provider status, so that the machine actuator adopts the instance.
+func AdoptInstance(codec *v1alpha1.AWSProviderConfigCodec, machine *clusterv1.Machine) error {
+	status := &v1alpha1.AWSMachineProviderStatus{}
+	if err := codec.DecodeProviderStatus(machine.Status.ProviderStatus, status); err != nil {
+		return errors.Wrapf(err, "failed to decode the provider status of machine %s/%s", machine.Namespace, machine.Name)
+	}
+	if status.InstanceID == nil || machine.Spec.ProviderConfig.Value == nil {
+		return nil
+	}
+
+	config := &v1alpha1.AWSMachineProviderConfig{}
+	if err := codec.DecodeFromProviderConfig(machine.Spec.ProviderConfig, config); err != nil {
+		return errors.Wrapf(err, "failed to decode the provider config of machine %s/%s", machine.Namespace, machine.Name)
+	}
+	config.InstanceID = status.InstanceID
+
+	providerConfig, err := codec.EncodeToProviderConfig(config)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode the provider config of machine %s/%s", machine.Namespace, machine.Name)
+	}
+	machine.Spec.ProviderConfig.Value = providerConfig.Value
+	return nil
+}
+
+// resetObjectMeta clears the fields set by the api server of the management cluster the object
+// was taken from. Owner references are dropped as the uids of the owners change, the machine
+// sets and deployments adopt their machines and sets again by their selectors. Objects taken
+// from a management cluster while they were being pivoted away from it aren't pivoted in the
+// restored one.
+func resetObjectMeta(meta *metav1.ObjectMeta) {
+	delete(meta.Annotations, v1alpha1.PivotedAnnotation)
+	meta.UID = ""
+	meta.ResourceVersion = ""
+	meta.SelfLink = ""
+	meta.Generation = 0
+	meta.CreationTimestamp = metav1.Time{}
+	meta.OwnerReferences = nil
+}
@@ -0,0 +1,127 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
+)
+
+// fakeS3 keeps objects in memory.
+type fakeS3 struct {
+	s3iface.S3API
+
+	objects map[string][]byte
+	puts    []*s3.PutObjectInput
+}
+
+func (f *fakeS3) PutObjectWithContext(_ aws.Context, in *s3.PutObjectInput, _ ...request.Option) (*s3.PutObjectOutput, error) {
+	data, err := ioutil.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.objects[*in.Bucket+"/"+*in.Key] = data
+	f.puts = append(f.puts, in)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) GetObjectWithContext(_ aws.Context, in *s3.GetObjectInput, _ ...request.Option) (*s3.GetObjectOutput, error) {
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(f.objects[*in.Bucket+"/"+*in.Key]))}, nil
+}
+
+func TestStore(t *testing.T) {
+	f := &fakeS3{objects: make(map[string][]byte)}
+	s := NewStore(f, "backups", "mgmt/backup.json").WithKMSKeyID("alias/backups")
+
+	b := &Backup{Clusters: []clusterv1.Cluster{{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}}}
+	if err := s.Put(context.TODO(), b); err != nil {
+		t.Fatalf("failed to put backup: %v", err)
+	}
+	if in := f.puts[0]; aws.StringValue(in.ServerSideEncryption) != s3.ServerSideEncryptionAwsKms || aws.StringValue(in.SSEKMSKeyId) != "alias/backups" {
+		t.Fatalf("expected the backup to be encrypted with the kms key, got: %v", in)
+	}
+
+	got, err := s.Get(context.TODO())
+	if err != nil {
+		t.Fatalf("failed to get backup: %v", err)
+	}
+	if len(got.Clusters) != 1 || got.Clusters[0].Name != "test" {
+		t.Fatalf("expected the stored backup, got: %v", got)
+	}
+}
+
+func TestAdoptInstance(t *testing.T) {
+	codec, err := v1alpha1.NewCodec()
+	if err != nil {
+		t.Fatalf("failed to create a codec: %v", err)
+	}
+
+	providerConfig, err := codec.EncodeToProviderConfig(&v1alpha1.AWSMachineProviderConfig{InstanceType: "t2.medium"})
+	if err != nil {
+		t.Fatalf("failed to encode provider config: %v", err)
+	}
+	providerStatus, err := codec.EncodeProviderStatus(&v1alpha1.AWSMachineProviderStatus{InstanceID: aws.String("i-1234")})
+	if err != nil {
+		t.Fatalf("failed to encode provider status: %v", err)
+	}
+
+	machine := &clusterv1.Machine{
+		Spec:   clusterv1.MachineSpec{ProviderConfig: *providerConfig},
+		Status: clusterv1.MachineStatus{ProviderStatus: providerStatus},
+	}
+	if err := AdoptInstance(codec, machine); err != nil {
+		t.Fatalf("failed to adopt instance: %v", err)
+	}
+
+	config := &v1alpha1.AWSMachineProviderConfig{}
+	if err := codec.DecodeFromProviderConfig(machine.Spec.ProviderConfig, config); err != nil {
+		t.Fatalf("failed to decode provider config: %v", err)
+	}
+	if aws.StringValue(config.InstanceID) != "i-1234" || config.InstanceType != "t2.medium" {
+		t.Fatalf("expected the instance of the status to be adopted, got: %+v", config)
+	}
+
+	// Machines without an instance create one.
+	machine = &clusterv1.Machine{Spec: clusterv1.MachineSpec{ProviderConfig: *providerConfig}}
+	if err := AdoptInstance(codec, machine); err != nil {
+		t.Fatalf("failed to adopt instance: %v", err)
+	}
+	if machine.Spec.ProviderConfig.Value != providerConfig.Value {
+		t.Fatalf("expected the provider config of a machine without an instance to be kept")
+	}
+}
+
+func TestResetObjectMetaPivoted(t *testing.T) {
+	meta := metav1.ObjectMeta{Name: "test", UID: "1234", ResourceVersion: "5", Finalizers: []string{"cluster.cluster.k8s.io"}}
+	markPivoted(&meta)
+	if _, ok := meta.Annotations[v1alpha1.PivotedAnnotation]; !ok || len(meta.Finalizers) != 0 {
+		t.Fatalf("expected the object to be marked as pivoted without finalizers, got: %+v", meta)
+	}
+
+	// Objects taken while being pivoted away aren't pivoted once restored.
+	resetObjectMeta(&meta)
+	if _, ok := meta.Annotations[v1alpha1.PivotedAnnotation]; ok || meta.UID != "" || meta.ResourceVersion != "" {
+		t.Fatalf("expected the object to be restored as new and not pivoted, got: %+v", meta)
+	}
+}
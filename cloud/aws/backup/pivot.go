@@ -0,0 +1,115 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	client "sigs.k8s.io/cluster-api/pkg/client/clientset_generated/clientset/typed/cluster/v1alpha1"
+)
+
+// Pivot moves the cluster-api objects in the namespace, or in all namespaces if empty, from one
+// management cluster to another, like from a bootstrap cluster to the cluster it created.
+// The objects are restored in the target cluster, whose controllers adopt their AWS resources.
+// They are then marked as pivoted and deleted from the source cluster without their finalizers,
+// so that the controllers of the source cluster keep their AWS resources. Pivoting again after
+// a failure continues where it stopped.
+func Pivot(from, to client.ClusterV1alpha1Interface, codec *v1alpha1.AWSProviderConfigCodec, namespace string) error {
+	b, err := Take(from, namespace)
+	if err != nil {
+		return err
+	}
+
+	if err := Restore(to, codec, b); err != nil {
+		return err
+	}
+
+	// Children go first, so that the machine sets don't replace the machines deleted under them.
+	for i := range b.MachineDeployments {
+		deployment := b.MachineDeployments[i].DeepCopy()
+		if deployment.DeletionTimestamp != nil {
+			continue
+		}
+		markPivoted(&deployment.ObjectMeta)
+		if _, err := from.MachineDeployments(deployment.Namespace).Update(deployment); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to mark machine deployment %s/%s as pivoted", deployment.Namespace, deployment.Name)
+		}
+		if err := from.MachineDeployments(deployment.Namespace).Delete(deployment.Name, orphan()); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete machine deployment %s/%s", deployment.Namespace, deployment.Name)
+		}
+	}
+
+	for i := range b.MachineSets {
+		set := b.MachineSets[i].DeepCopy()
+		if set.DeletionTimestamp != nil {
+			continue
+		}
+		markPivoted(&set.ObjectMeta)
+		if _, err := from.MachineSets(set.Namespace).Update(set); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to mark machine set %s/%s as pivoted", set.Namespace, set.Name)
+		}
+		if err := from.MachineSets(set.Namespace).Delete(set.Name, orphan()); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete machine set %s/%s", set.Namespace, set.Name)
+		}
+	}
+
+	for i := range b.Machines {
+		machine := b.Machines[i].DeepCopy()
+		if machine.DeletionTimestamp != nil {
+			continue
+		}
+		markPivoted(&machine.ObjectMeta)
+		if _, err := from.Machines(machine.Namespace).Update(machine); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to mark machine %s/%s as pivoted", machine.Namespace, machine.Name)
+		}
+		if err := from.Machines(machine.Namespace).Delete(machine.Name, orphan()); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete machine %s/%s", machine.Namespace, machine.Name)
+		}
+	}
+
+	for i := range b.Clusters {
+		cluster := b.Clusters[i].DeepCopy()
+		if cluster.DeletionTimestamp != nil {
+			continue
+		}
+		markPivoted(&cluster.ObjectMeta)
+		if _, err := from.Clusters(cluster.Namespace).Update(cluster); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to mark cluster %s/%s as pivoted", cluster.Namespace, cluster.Name)
+		}
+		if err := from.Clusters(cluster.Namespace).Delete(cluster.Name, orphan()); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete cluster %s/%s", cluster.Namespace, cluster.Name)
+		}
+	}
+
+	return nil
+}
+
+// markPivoted sets the pivoted annotation, which keeps the actuators from deleting the AWS
+// resources of the object, and drops its finalizers, so that it is deleted right away.
+func markPivoted(meta *metav1.ObjectMeta) {
+	if meta.Annotations == nil {
+		meta.Annotations = make(map[string]string)
+	}
+	meta.Annotations[v1alpha1.PivotedAnnotation] = "true"
+	meta.Finalizers = nil
+}
+
+// orphan keeps the garbage collector from deleting the dependents of the deleted object, which
+// are moved on their own.
+func orphan() *metav1.DeleteOptions {
+	propagation := metav1.DeletePropagationOrphan
+	return &metav1.DeleteOptions{PropagationPolicy: &propagation}
+}
@@ -0,0 +1,131 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/logger"
+	client "sigs.k8s.io/cluster-api/pkg/client/clientset_generated/clientset/typed/cluster/v1alpha1"
+)
+
+// Store keeps the latest backup as an object in an S3 bucket, encrypted at rest. Enabling the
+// versioning of the bucket keeps the previous backups.
+type Store struct {
+	S3     s3iface.S3API
+	Bucket string
+	Key    string
+
+	// KMSKeyID is the KMS key the backup is encrypted with. If empty, it is encrypted with the
+	// keys managed by S3.
+	KMSKeyID string
+
+	log logr.Logger
+}
+
+// NewStore returns a new store of the backup at the key in the bucket.
+func NewStore(s3API s3iface.S3API, bucket, key string) *Store {
+	return &Store{
+		S3:     s3API,
+		Bucket: bucket,
+		Key:    key,
+		log:    logger.Default(),
+	}
+}
+
+// WithKMSKeyID returns a copy of the store that encrypts the backup with the given KMS key.
+func (s *Store) WithKMSKeyID(id string) *Store {
+	c := *s
+	c.KMSKeyID = id
+	return &c
+}
+
+// WithLogger returns a copy of the store that logs to the given logger.
+func (s *Store) WithLogger(log logr.Logger) *Store {
+	c := *s
+	c.log = log
+	return &c
+}
+
+// Put replaces the backup in the bucket.
+func (s *Store) Put(ctx context.Context, b *Backup) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode backup")
+	}
+
+	in := &s3.PutObjectInput{
+		Bucket:               aws.String(s.Bucket),
+		Key:                  aws.String(s.Key),
+		Body:                 bytes.NewReader(data),
+		ContentType:          aws.String("application/json"),
+		ServerSideEncryption: aws.String(s3.ServerSideEncryptionAes256),
+	}
+	if s.KMSKeyID != "" {
+		in.ServerSideEncryption = aws.String(s3.ServerSideEncryptionAwsKms)
+		in.SSEKMSKeyId = aws.String(s.KMSKeyID)
+	}
+
+	if _, err := s.S3.PutObjectWithContext(ctx, in); err != nil {
+		return errors.Wrapf(err, "failed to put backup to s3://%s/%s", s.Bucket, s.Key)
+	}
+	return nil
+}
+
+// Get returns the backup in the bucket.
+func (s *Store) Get(ctx context.Context) (*Backup, error) {
+	out, err := s.S3.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.Key),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get backup from s3://%s/%s", s.Bucket, s.Key)
+	}
+	defer out.Body.Close()
+
+	b := &Backup{}
+	if err := json.NewDecoder(out.Body).Decode(b); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode backup from s3://%s/%s", s.Bucket, s.Key)
+	}
+	return b, nil
+}
+
+// Run takes a backup of the cluster-api objects in all namespaces every interval and puts it
+// into the bucket until stop is closed. Failures are logged and retried on the next interval.
+func (s *Store) Run(c client.ClusterV1alpha1Interface, interval time.Duration, stop <-chan struct{}) {
+	wait.Until(func() {
+		b, err := Take(c, "")
+		if err != nil {
+			s.log.Error(err, "Failed to take backup")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		defer cancel()
+		if err := s.Put(ctx, b); err != nil {
+			s.log.Error(err, "Failed to store backup")
+			return
+		}
+		s.log.V(2).Info("Stored backup", "bucket", s.Bucket, "key", s.Key, "clusters", len(b.Clusters), "machines", len(b.Machines))
+	}, interval, stop)
+}
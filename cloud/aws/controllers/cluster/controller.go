@@ -17,12 +17,24 @@ limitations under the License.
 package cluster
 
 import (
+	"net"
+	"net/http"
 	"os"
 
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/efs"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/aws/aws-sdk-go/service/resourcegroups"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/golang/glog"
 	"github.com/kubernetes-incubator/apiserver-builder/pkg/controller"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -40,9 +52,22 @@ import (
 	"sigs.k8s.io/cluster-api/pkg/controller/sharedinformers"
 
 	clusteractuator "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/actuators/cluster"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/audit"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/awssession"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/backup"
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/controllers/cluster/options"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/logger"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/policy"
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/ratelimit"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services"
 	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+	efssvc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/efs"
+	elbsvc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/elb"
+	iamsvc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/iam"
+	pricingsvc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/pricing"
+	resourcegroupssvc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/resourcegroups"
+	route53svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/route53"
 )
 
 const (
@@ -50,6 +75,11 @@ const (
 )
 
 func Start(server *options.Server, shutdown <-chan struct{}) {
+	log, err := logger.New(logger.Format(server.LogFormat))
+	if err != nil {
+		glog.Fatalf("Could not create logger: %v", err)
+	}
+
 	config, err := controller.GetConfig(server.CommonConfig.Kubeconfig)
 	if err != nil {
 		glog.Fatalf("Could not create Config for talking to the apiserver: %v", err)
@@ -65,16 +95,117 @@ func Start(server *options.Server, shutdown <-chan struct{}) {
 	}
 
 	// Requires setting environment variables:
-	// AWS_REGION=us-west-2,
+	// AWS_REGION=us-west-2, unless --default-region is set
 	// AWS_ACCESS_KEY_ID=
 	// AWS_SECRET_ACCESS_KEY=
-	sess := session.Must(session.NewSession())
+	// unless --credentials-file is set,
+	// or, for IAM roles for service accounts, AWS_WEB_IDENTITY_TOKEN_FILE= and AWS_ROLE_ARN=
+	if _, _, err := net.ParseCIDR(server.DefaultVPCCIDR); err != nil {
+		glog.Fatalf("Invalid default VPC CIDR: %v", err)
+	}
+
+	sess, err := awssession.New(awssession.Options{
+		DefaultRegion:       server.DefaultRegion,
+		STSRegionalEndpoint: server.STSRegionalEndpoint,
+		FIPSEndpoints:       server.FIPSEndpoints,
+		CredentialsFile:     server.CredentialsFile,
+	})
+	if err != nil {
+		glog.Fatalf("Could not create aws session: %v", err)
+	}
+	sess.Handlers.Complete.PushBackNamed(logger.AWSRequestHandler(log.WithName("aws")))
+	if server.AuditLog {
+		sess.Handlers.Complete.PushBackNamed(audit.Handler(audit.NewLogSink(log.WithName("audit"))))
+	}
+
+	locations := &policy.Locations{
+		AllowedRegions: server.AllowedRegions,
+		DeniedRegions:  server.DeniedRegions,
+		AllowedZones:   server.AllowedAvailabilityZones,
+		DeniedZones:    server.DeniedAvailabilityZones,
+	}
+	if err := locations.CheckRegion(aws.StringValue(sess.Config.Region)); err != nil {
+		glog.Fatalf("Refusing to run in region: %v", err)
+	}
+
 	ec2client := ec2.New(sess)
 
 	params := clusteractuator.ActuatorParams{
-		Codec:          codec,
-		ClustersGetter: clients.ClusterV1alpha1(),
-		EC2Service:     ec2svc.NewService(ec2client),
+		Codec:            codec,
+		ClustersGetter:   clients.ClusterV1alpha1(),
+		EC2Service:       ec2svc.NewService(ec2client).WithLogger(log.WithName("ec2")).WithManager(server.ManagerName).WithConcurrency(server.ReconcileConcurrency).WithDefaultVPCCIDR(server.DefaultVPCCIDR).WithLocations(locations),
+		Logger:           log,
+		ReconcileTimeout: server.ReconcileTimeout,
+	}
+
+	if server.AWSAPIQPS > 0 {
+		limiters := ratelimit.New(server.AWSAPIQPS, server.AWSAPIBurst)
+		clients := awssession.NewCache(server.AWSClientCacheSize)
+		params.EC2ServiceFor = func(clusterName string) services.EC2Interface {
+			return clients.Get(awssession.KeyFor(sess, clusterName), func() interface{} {
+				client := ec2.New(sess)
+				client.Handlers.Sign.PushFrontNamed(limiters.Handler(clusterName))
+				return ec2svc.NewService(client).WithLogger(log.WithName("ec2")).WithManager(server.ManagerName).WithConcurrency(server.ReconcileConcurrency).WithDefaultVPCCIDR(server.DefaultVPCCIDR).WithLocations(locations)
+			}).(services.EC2Interface)
+		}
+	}
+
+	if server.EstimateCost {
+		// The Pricing API is only served from a few regions, prices are looked up for the region of the session.
+		client := pricing.New(sess, aws.NewConfig().WithRegion(endpoints.UsEast1RegionID))
+		svc, err := pricingsvc.NewService(client, aws.StringValue(sess.Config.Region))
+		if err != nil {
+			glog.Fatalf("Could not create pricing service: %v", err)
+		}
+		params.PricingService = svc
+	}
+
+	if server.ResourceGroups {
+		params.ResourceGroupsService = resourcegroupssvc.NewService(resourcegroups.New(sess)).WithLogger(log.WithName("resourcegroups"))
+	}
+
+	if server.FileSystems {
+		params.FileSystemService = efssvc.NewService(efs.New(sess)).WithLogger(log.WithName("efs"))
+	}
+
+	if server.PrivateHostedZones {
+		route53Sess := sess
+		if server.Route53RoleARN != "" {
+			route53Sess = awssession.AssumeRole(sess, server.Route53RoleARN, server.Route53ExternalID)
+		}
+		params.PrivateHostedZoneService = route53svc.NewService(route53.New(route53Sess), aws.StringValue(sess.Config.Region)).WithLogger(log.WithName("route53"))
+	}
+
+	if server.NodeRoles {
+		params.NodeRolesService = iamsvc.NewService(iam.New(sess)).WithLogger(log.WithName("iam")).WithManager(server.ManagerName)
+	}
+
+	if server.DeleteLoadBalancers {
+		params.LoadBalancersService = elbsvc.NewService(elb.New(sess), elbv2.New(sess)).WithLogger(log.WithName("elb"))
+	}
+
+	var checkers policy.All
+	if len(server.RequiredTags) > 0 {
+		checkers = append(checkers, &policy.Static{RequiredTags: server.RequiredTags})
+	}
+	if server.PolicyWebhookURL != "" {
+		checkers = append(checkers, &policy.Webhook{URL: server.PolicyWebhookURL})
+	}
+	if len(checkers) > 0 {
+		params.Policy = checkers
+	}
+
+	if server.MetricsBindAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		go func() {
+			glog.Fatalf("Could not serve metrics: %v", http.ListenAndServe(server.MetricsBindAddress, mux))
+		}()
+	}
+
+	if server.BackupBucket != "" {
+		store := backup.NewStore(s3.New(sess), server.BackupBucket, server.BackupKey).WithKMSKeyID(server.BackupKMSKeyID).WithLogger(log.WithName("backup"))
+		go store.Run(clients.ClusterV1alpha1(), server.BackupInterval, shutdown)
 	}
 
 	actuator, err := clusteractuator.NewActuator(params)
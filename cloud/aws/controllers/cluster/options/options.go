@@ -17,16 +17,173 @@ limitations under the License.
 package options
 
 import (
+	"time"
+
+	"github.com/spf13/pflag"
 	"sigs.k8s.io/cluster-api/pkg/controller/config"
+
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/logger"
 )
 
 type Server struct {
 	CommonConfig *config.Configuration
+
+	// LogFormat is the output format of the controller logs.
+	LogFormat string
+
+	// AuditLog enables the audit log of mutating AWS API calls.
+	AuditLog bool
+
+	// ReconcileTimeout is how long the AWS calls of a single reconcile may take before they
+	// are cancelled and the reconcile fails.
+	ReconcileTimeout time.Duration
+
+	// ReconcileConcurrency is the maximum number of independent AWS resources
+	// reconciled at once for a single cluster.
+	ReconcileConcurrency int
+
+	// EstimateCost enables the cost estimation of clusters with the AWS Pricing API.
+	EstimateCost bool
+
+	// ResourceGroups enables an AWS Resource Group per cluster of the resources tagged for it.
+	ResourceGroups bool
+
+	// FileSystems enables an EFS file system for the clusters that ask for one.
+	FileSystems bool
+
+	// PrivateHostedZones enables a Route 53 private hosted zone for the clusters that ask for one.
+	PrivateHostedZones bool
+
+	// Route53RoleARN is the role the Route 53 calls are made with, like a role of the account the
+	// hosted zones are shared from. If empty, they are made with the credentials of the controller.
+	Route53RoleARN string
+
+	// Route53ExternalID is the external id the Route 53 role is assumed with, if it requires one.
+	Route53ExternalID string
+
+	// NodeRoles enables the IAM roles of the nodes of the clusters that ask for them.
+	NodeRoles bool
+
+	// DeleteLoadBalancers enables the deletion of the load balancers left in the vpc of deleted
+	// clusters, like the ones of services of type LoadBalancer. Hibernated instances are taken out
+	// of them as well.
+	DeleteLoadBalancers bool
+
+	// BackupBucket is the S3 bucket the cluster-api objects are backed up to. If empty, they
+	// aren't backed up.
+	BackupBucket string
+
+	// BackupKey is the key of the backup in the bucket.
+	BackupKey string
+
+	// BackupKMSKeyID is the KMS key the backup is encrypted with. If empty, it is encrypted with
+	// the keys managed by S3.
+	BackupKMSKeyID string
+
+	// BackupInterval is how often the backup is taken.
+	BackupInterval time.Duration
+
+	// MetricsBindAddress is the address the metrics are served on. If empty, they aren't served.
+	MetricsBindAddress string
+
+	// AWSAPIQPS is the rate of AWS API calls per second allowed for each cluster. If zero,
+	// calls aren't rate limited.
+	AWSAPIQPS float32
+
+	// AWSAPIBurst is the number of AWS API calls a cluster may make at once above its rate.
+	AWSAPIBurst int
+
+	// AWSClientCacheSize is the number of clusters whose AWS clients are kept between reconciles.
+	AWSClientCacheSize int
+
+	// ManagerName is the name of the management cluster, which owned resources are tagged with.
+	// Resources tagged for another management cluster aren't modified. If empty, resources
+	// aren't tagged and every resource is modified.
+	ManagerName string
+
+	// DefaultRegion is the AWS region used if none is configured in the environment.
+	DefaultRegion string
+
+	// STSRegionalEndpoint makes the STS calls assuming the role of a web identity go to the
+	// endpoint of the region instead of the global one.
+	STSRegionalEndpoint bool
+
+	// FIPSEndpoints makes the AWS calls go to the FIPS endpoints of the services that have them.
+	FIPSEndpoints bool
+
+	// CredentialsFile is a shared credentials file, like one mounted from a Secret, read again
+	// whenever it changes.
+	CredentialsFile string
+
+	// DefaultVPCCIDR is the cidr block of the VPCs created for clusters that don't set one.
+	DefaultVPCCIDR string
+
+	// PolicyWebhookURL is the URL of a webhook that decides whether resources may be created.
+	PolicyWebhookURL string
+
+	// RequiredTags are the tag keys the additional tags of every cluster must have.
+	RequiredTags []string
+
+	// AllowedRegions and DeniedRegions restrict the regions the controller may run in.
+	AllowedRegions []string
+	DeniedRegions  []string
+
+	// AllowedAvailabilityZones and DeniedAvailabilityZones restrict the availability zones
+	// the subnets of clusters may be created in.
+	AllowedAvailabilityZones []string
+	DeniedAvailabilityZones  []string
 }
 
 func NewServer() *Server {
 	s := Server{
-		CommonConfig: &config.ControllerConfig,
+		CommonConfig:         &config.ControllerConfig,
+		LogFormat:            string(logger.FormatText),
+		ReconcileTimeout:     5 * time.Minute,
+		AuditLog:             true,
+		DeleteLoadBalancers:  true,
+		BackupKey:            "cluster-api-provider-aws/backup.json",
+		BackupInterval:       15 * time.Minute,
+		ReconcileConcurrency: 5,
+		AWSAPIQPS:            10,
+		AWSAPIBurst:          50,
+		AWSClientCacheSize:   256,
+		DefaultVPCCIDR:       "10.0.0.0/16",
 	}
 	return &s
 }
+
+// AddFlags adds the server specific flags to the flag set.
+func (s *Server) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&s.LogFormat, "log-format", s.LogFormat, "Log output format, one of: text, json")
+	fs.BoolVar(&s.AuditLog, "audit-log", s.AuditLog, "Log every mutating AWS API call to the audit logger")
+	fs.BoolVar(&s.EstimateCost, "estimate-cost", s.EstimateCost, "Estimate the cost of the AWS resources of clusters with the AWS Pricing API, which requires the pricing:GetProducts permission")
+	fs.BoolVar(&s.ResourceGroups, "resource-groups", s.ResourceGroups, "Create an AWS Resource Group per cluster of the resources tagged for it, which requires the resource-groups permissions")
+	fs.BoolVar(&s.FileSystems, "file-systems", s.FileSystems, "Create an EFS file system for the clusters that ask for one, which requires the elasticfilesystem and ec2 security group permissions")
+	fs.BoolVar(&s.PrivateHostedZones, "private-hosted-zones", s.PrivateHostedZones, "Create a Route 53 private hosted zone attached to the vpc for the clusters that ask for one, which requires the route53 permissions on hosted zones")
+	fs.StringVar(&s.Route53RoleARN, "route53-role-arn", s.Route53RoleARN, "Role the Route 53 calls are made with instead of the credentials of the controller, like a role of a shared networking account holding the hosted zones, which requires the sts:AssumeRole permission on it. The vpc of a cluster must be owned by that account, like a vpc shared from it, for its hosted zone to be created there")
+	fs.StringVar(&s.Route53ExternalID, "route53-external-id", s.Route53ExternalID, "External id the Route 53 role is assumed with, if its trust policy requires one")
+	fs.BoolVar(&s.NodeRoles, "node-roles", s.NodeRoles, "Create IAM roles and instance profiles for the control plane and the other nodes of the clusters that ask for them, which requires the iam permissions on roles and instance profiles under the /cluster-api-provider-aws/ path")
+	fs.BoolVar(&s.DeleteLoadBalancers, "delete-load-balancers", s.DeleteLoadBalancers, "Delete the load balancers owned by a cluster in its vpc, like the ones of services of type LoadBalancer, before its network is deleted, which requires the elasticloadbalancing permissions. Load balancers that aren't owned by the cluster are reported as keeping its vpc from being deleted. The instances of hibernated clusters are deregistered from their load balancers while they're stopped")
+	fs.StringVar(&s.BackupBucket, "backup-bucket", s.BackupBucket, "S3 bucket the clusters and machines, with the ids of their AWS resources, are backed up to, so that another management cluster can adopt the resources with cluster-restore, which requires the s3:PutObject permission on the key. Enable the versioning of the bucket to keep previous backups. Nothing is backed up if empty")
+	fs.StringVar(&s.BackupKey, "backup-key", s.BackupKey, "Key of the backup in the backup bucket, unique per management cluster")
+	fs.StringVar(&s.BackupKMSKeyID, "backup-kms-key-id", s.BackupKMSKeyID, "KMS key the backup is encrypted with, which requires the kms:GenerateDataKey permission on it. The backup is encrypted with the keys managed by S3 if empty")
+	fs.DurationVar(&s.BackupInterval, "backup-interval", s.BackupInterval, "How often the backup is taken")
+	fs.StringVar(&s.MetricsBindAddress, "metrics-bind-address", s.MetricsBindAddress, "Address to serve Prometheus metrics on, e.g. :8080. Metrics aren't served if empty")
+	fs.IntVar(&s.ReconcileConcurrency, "reconcile-concurrency", s.ReconcileConcurrency, "Maximum number of independent AWS resources, e.g. the NAT gateways of different availability zones, reconciled at once per cluster")
+	fs.Float32Var(&s.AWSAPIQPS, "aws-api-qps-per-cluster", s.AWSAPIQPS, "AWS API calls per second allowed for each cluster, so that a single cluster can't use up the API quota of the account. Calls aren't rate limited if zero")
+	fs.IntVar(&s.AWSAPIBurst, "aws-api-burst-per-cluster", s.AWSAPIBurst, "AWS API calls a cluster may make at once above its rate")
+	fs.IntVar(&s.AWSClientCacheSize, "aws-client-cache-size", s.AWSClientCacheSize, "Clusters whose rate limited AWS clients are kept between reconciles. The clients of the least recently reconciled cluster are dropped once there are more")
+	fs.StringVar(&s.ManagerName, "manager-name", s.ManagerName, "Name of the management cluster, unique per AWS account, which the AWS resources it owns are tagged with. Resources tagged for another management cluster aren't modified, so that management clusters managing clusters of the same name don't fight over them")
+	fs.DurationVar(&s.ReconcileTimeout, "reconcile-timeout", s.ReconcileTimeout, "How long the AWS calls of a single reconcile may take before they are cancelled and the reconcile fails. They are never cancelled if zero")
+	fs.StringVar(&s.DefaultRegion, "default-region", s.DefaultRegion, "AWS region used if none is configured in the environment, like with AWS_REGION")
+	fs.BoolVar(&s.STSRegionalEndpoint, "sts-regional-endpoint", s.STSRegionalEndpoint, "Assume the role of the web identity token, set with AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN like for IAM roles for service accounts, through the STS endpoint of the region instead of the global one")
+	fs.BoolVar(&s.FIPSEndpoints, "fips-endpoints", s.FIPSEndpoints, "Call the FIPS endpoints of the AWS services that have them in the region, which are the US and Canada regions. The default endpoints of the GovCloud regions are FIPS endpoints already")
+	fs.StringVar(&s.CredentialsFile, "credentials-file", s.CredentialsFile, "Shared credentials file the AWS credentials are read from instead of the environment, like one mounted from a Secret. Rotated credentials are used once the file changes, without a restart")
+	fs.StringVar(&s.DefaultVPCCIDR, "default-vpc-cidr", s.DefaultVPCCIDR, "CIDR block of the VPCs created for clusters that don't set one. The default subnets are carved out of it")
+	fs.StringSliceVar(&s.RequiredTags, "required-tags", s.RequiredTags, "Tag keys the additional tags of every cluster must set. Nothing is created for clusters missing one")
+	fs.StringSliceVar(&s.AllowedRegions, "allowed-regions", s.AllowedRegions, "Regions the controller may create resources in. The controller refuses to start in any other region. All regions are allowed if empty")
+	fs.StringSliceVar(&s.DeniedRegions, "denied-regions", s.DeniedRegions, "Regions the controller refuses to start in, even if they are allowed")
+	fs.StringSliceVar(&s.AllowedAvailabilityZones, "allowed-availability-zones", s.AllowedAvailabilityZones, "Availability zones, or Local Zones, the subnets of clusters may be created in, the default subnets are spread over them only. All zones are allowed if empty")
+	fs.StringSliceVar(&s.DeniedAvailabilityZones, "denied-availability-zones", s.DeniedAvailabilityZones, "Availability zones, or Local Zones, no subnets of clusters are created in, even if they are allowed")
+	fs.StringVar(&s.PolicyWebhookURL, "policy-webhook-url", s.PolicyWebhookURL, "URL the clusters are POSTed to for a decision before they are created, like the rule of an Open Policy Agent. Nothing is checked if empty")
+}
@@ -19,8 +19,10 @@ package machine
 import (
 	"os"
 
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/golang/glog"
 	"github.com/kubernetes-incubator/apiserver-builder/pkg/controller"
 	corev1 "k8s.io/api/core/v1"
@@ -40,16 +42,29 @@ import (
 	"sigs.k8s.io/cluster-api/pkg/controller/sharedinformers"
 
 	machineactuator "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/actuators/machine"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/audit"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/awssession"
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/controllers/machine/options"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/logger"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/policy"
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/ratelimit"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services"
 	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+	iamsvc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/iam"
+	ssmsvc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ssm"
 )
 
 const (
 	controllerName = "aws-machine-controller"
 )
 
-func Start(server *options.Server, shutdown <-chan struct{}) {
+func Start(server *options.Server, recorder record.EventRecorder, shutdown <-chan struct{}) {
+	log, err := logger.New(logger.Format(server.LogFormat))
+	if err != nil {
+		glog.Fatalf("Could not create logger: %v", err)
+	}
+
 	config, err := controller.GetConfig(server.CommonConfig.Kubeconfig)
 	if err != nil {
 		glog.Fatalf("Could not create Config for talking to the apiserver: %v", err)
@@ -66,19 +81,80 @@ func Start(server *options.Server, shutdown <-chan struct{}) {
 	}
 
 	// Requires setting environment variables:
-	// AWS_REGION=us-west-2,
+	// AWS_REGION=us-west-2, unless --default-region is set
 	// AWS_ACCESS_KEY_ID=
 	// AWS_SECRET_ACCESS_KEY=
-	sess := session.Must(session.NewSession())
+	// unless --credentials-file is set,
+	// or, for IAM roles for service accounts, AWS_WEB_IDENTITY_TOKEN_FILE= and AWS_ROLE_ARN=
+	sess, err := awssession.New(awssession.Options{
+		DefaultRegion:       server.DefaultRegion,
+		STSRegionalEndpoint: server.STSRegionalEndpoint,
+		FIPSEndpoints:       server.FIPSEndpoints,
+		CredentialsFile:     server.CredentialsFile,
+	})
+	if err != nil {
+		glog.Fatalf("Could not create aws session: %v", err)
+	}
+	sess.Handlers.Complete.PushBackNamed(logger.AWSRequestHandler(log.WithName("aws")))
+	if server.AuditLog {
+		sess.Handlers.Complete.PushBackNamed(audit.Handler(audit.NewLogSink(log.WithName("audit"))))
+	}
+
+	locations := &policy.Locations{
+		AllowedRegions: server.AllowedRegions,
+		DeniedRegions:  server.DeniedRegions,
+		AllowedZones:   server.AllowedAvailabilityZones,
+		DeniedZones:    server.DeniedAvailabilityZones,
+	}
+	if err := locations.CheckRegion(aws.StringValue(sess.Config.Region)); err != nil {
+		glog.Fatalf("Refusing to run in region: %v", err)
+	}
+
 	ec2client := ec2.New(sess)
 
 	params := machineactuator.ActuatorParams{
-		MachinesGetter: client.ClusterV1alpha1(),
-		EC2Service:     ec2svc.NewService(ec2client),
-		Codec:          codec,
+		MachinesGetter:          client.ClusterV1alpha1(),
+		EC2Service:              ec2svc.NewService(ec2client).WithLogger(log.WithName("ec2")).WithManager(server.ManagerName).WithLocations(locations),
+		InstanceProfilesService: iamsvc.NewService(iam.New(sess)).WithLogger(log.WithName("iam")),
+		Codec:                   codec,
+		EventRecorder:           recorder,
+		Logger:                  log,
+		ReconcileTimeout:        server.ReconcileTimeout,
+		RequireChangeApproval:   server.RequireChangeApproval,
+		Defaults: machineactuator.Defaults{
+			InstanceType:   server.DefaultInstanceType,
+			RootDeviceSize: server.DefaultRootDeviceSize,
+		},
 		//		ClusterClient: client.ClusterV1alpha1().Clusters(corev1.NamespaceDefault),
 	}
 
+	var checkers policy.All
+	if len(server.RequiredTags) > 0 || len(server.DeniedInstanceFamilies) > 0 {
+		checkers = append(checkers, &policy.Static{RequiredTags: server.RequiredTags, DeniedInstanceFamilies: server.DeniedInstanceFamilies})
+	}
+	if server.PolicyWebhookURL != "" {
+		checkers = append(checkers, &policy.Webhook{URL: server.PolicyWebhookURL})
+	}
+	if len(checkers) > 0 {
+		params.Policy = checkers
+	}
+
+	if server.GracefulShutdown {
+		params.InstanceShutdownService = ssmsvc.NewService(ssm.New(sess)).WithLogger(log.WithName("ssm"))
+	}
+
+	if server.AWSAPIQPS > 0 {
+		limiters := ratelimit.New(server.AWSAPIQPS, server.AWSAPIBurst)
+		clients := awssession.NewCache(server.AWSClientCacheSize)
+		params.EC2ServiceFor = func(clusterName string) services.EC2Interface {
+			return clients.Get(awssession.KeyFor(sess, clusterName), func() interface{} {
+				client := ec2.New(sess)
+				client.Handlers.Sign.PushFrontNamed(limiters.Handler(clusterName))
+				return ec2svc.NewService(client).WithLogger(log.WithName("ec2")).WithManager(server.ManagerName).WithLocations(locations)
+			}).(services.EC2Interface)
+		}
+	}
+
 	actuator, err := machineactuator.NewActuator(params)
 	if err != nil {
 		glog.Fatalf("Could not create aws machine actuator: %v", err)
@@ -114,7 +190,7 @@ func Run(server *options.Server) error {
 
 	// run function will block and never return.
 	run := func(stop <-chan struct{}) {
-		Start(server, stop)
+		Start(server, recorder, stop)
 	}
 
 	leaderElectConfig := config.GetLeaderElectionConfig()
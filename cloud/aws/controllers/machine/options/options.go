@@ -17,16 +17,124 @@ limitations under the License.
 package options
 
 import (
+	"time"
+
+	"github.com/spf13/pflag"
 	"sigs.k8s.io/cluster-api/pkg/controller/config"
+
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/logger"
 )
 
 type Server struct {
 	CommonConfig *config.Configuration
+
+	// LogFormat is the output format of the controller logs.
+	LogFormat string
+
+	// AuditLog enables the audit log of mutating AWS API calls.
+	AuditLog bool
+
+	// ReconcileTimeout is how long the AWS calls of a single reconcile may take before they
+	// are cancelled and the reconcile fails.
+	ReconcileTimeout time.Duration
+
+	// AWSAPIQPS is the rate of AWS API calls per second allowed for each cluster. If zero,
+	// calls aren't rate limited.
+	AWSAPIQPS float32
+
+	// AWSAPIBurst is the number of AWS API calls a cluster may make at once above its rate.
+	AWSAPIBurst int
+
+	// AWSClientCacheSize is the number of clusters whose AWS clients are kept between reconciles.
+	AWSClientCacheSize int
+
+	// ManagerName is the name of the management cluster, which owned resources are tagged with.
+	// Resources tagged for another management cluster aren't modified. If empty, resources
+	// aren't tagged and every resource is modified.
+	ManagerName string
+
+	// DefaultRegion is the AWS region used if none is configured in the environment.
+	DefaultRegion string
+
+	// STSRegionalEndpoint makes the STS calls assuming the role of a web identity go to the
+	// endpoint of the region instead of the global one.
+	STSRegionalEndpoint bool
+
+	// FIPSEndpoints makes the AWS calls go to the FIPS endpoints of the services that have them.
+	FIPSEndpoints bool
+
+	// CredentialsFile is a shared credentials file, like one mounted from a Secret, read again
+	// whenever it changes.
+	CredentialsFile string
+
+	// DefaultInstanceType is the instance type of machines that don't set one.
+	DefaultInstanceType string
+
+	// DefaultRootDeviceSize is the root volume size in GiB of machines that don't set one.
+	// If zero, root volumes have the size of the AMI's.
+	DefaultRootDeviceSize int64
+
+	// PolicyWebhookURL is the URL of a webhook that decides whether resources may be created.
+	PolicyWebhookURL string
+
+	// RequiredTags are the tag keys the additional tags of every instance must have.
+	RequiredTags []string
+
+	// DeniedInstanceFamilies are the instance families, like p3, no instance may have.
+	DeniedInstanceFamilies []string
+
+	// RequireChangeApproval keeps instances from being replaced with unapproved changes.
+	RequireChangeApproval bool
+
+	// GracefulShutdown shuts down the operating system of instances through SSM before they are
+	// terminated, for machines with a graceful shutdown timeout.
+	GracefulShutdown bool
+
+	// AllowedRegions and DeniedRegions restrict the regions the controller may run in.
+	AllowedRegions []string
+	DeniedRegions  []string
+
+	// AllowedAvailabilityZones and DeniedAvailabilityZones restrict the availability zones
+	// instances may be launched in.
+	AllowedAvailabilityZones []string
+	DeniedAvailabilityZones  []string
 }
 
 func NewServer() *Server {
 	s := Server{
-		CommonConfig: &config.ControllerConfig,
+		CommonConfig:       &config.ControllerConfig,
+		LogFormat:          string(logger.FormatText),
+		ReconcileTimeout:   5 * time.Minute,
+		AuditLog:           true,
+		AWSAPIQPS:          10,
+		AWSAPIBurst:        50,
+		AWSClientCacheSize: 256,
 	}
 	return &s
 }
+
+// AddFlags adds the server specific flags to the flag set.
+func (s *Server) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&s.LogFormat, "log-format", s.LogFormat, "Log output format, one of: text, json")
+	fs.BoolVar(&s.AuditLog, "audit-log", s.AuditLog, "Log every mutating AWS API call to the audit logger")
+	fs.Float32Var(&s.AWSAPIQPS, "aws-api-qps-per-cluster", s.AWSAPIQPS, "AWS API calls per second allowed for each cluster, so that a single cluster can't use up the API quota of the account. Calls aren't rate limited if zero")
+	fs.IntVar(&s.AWSAPIBurst, "aws-api-burst-per-cluster", s.AWSAPIBurst, "AWS API calls a cluster may make at once above its rate")
+	fs.IntVar(&s.AWSClientCacheSize, "aws-client-cache-size", s.AWSClientCacheSize, "Clusters whose rate limited AWS clients are kept between reconciles. The clients of the least recently reconciled cluster are dropped once there are more")
+	fs.StringVar(&s.ManagerName, "manager-name", s.ManagerName, "Name of the management cluster, unique per AWS account, which the AWS resources it owns are tagged with. Resources tagged for another management cluster aren't modified, so that management clusters managing clusters of the same name don't fight over them")
+	fs.DurationVar(&s.ReconcileTimeout, "reconcile-timeout", s.ReconcileTimeout, "How long the AWS calls of a single reconcile may take before they are cancelled and the reconcile fails. They are never cancelled if zero")
+	fs.StringVar(&s.DefaultRegion, "default-region", s.DefaultRegion, "AWS region used if none is configured in the environment, like with AWS_REGION")
+	fs.BoolVar(&s.STSRegionalEndpoint, "sts-regional-endpoint", s.STSRegionalEndpoint, "Assume the role of the web identity token, set with AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN like for IAM roles for service accounts, through the STS endpoint of the region instead of the global one")
+	fs.BoolVar(&s.FIPSEndpoints, "fips-endpoints", s.FIPSEndpoints, "Call the FIPS endpoints of the AWS services that have them in the region, which are the US and Canada regions. The default endpoints of the GovCloud regions are FIPS endpoints already")
+	fs.StringVar(&s.CredentialsFile, "credentials-file", s.CredentialsFile, "Shared credentials file the AWS credentials are read from instead of the environment, like one mounted from a Secret. Rotated credentials are used once the file changes, without a restart")
+	fs.StringVar(&s.DefaultInstanceType, "default-instance-type", s.DefaultInstanceType, "Instance type of machines that don't set one")
+	fs.Int64Var(&s.DefaultRootDeviceSize, "default-root-device-size", s.DefaultRootDeviceSize, "Root volume size in GiB of machines that don't set one. Root volumes have the size of the AMI's if zero")
+	fs.StringSliceVar(&s.RequiredTags, "required-tags", s.RequiredTags, "Tag keys the additional tags of the cluster and the machine must set. No instance is launched for machines missing one")
+	fs.StringSliceVar(&s.DeniedInstanceFamilies, "denied-instance-families", s.DeniedInstanceFamilies, "Instance families, like p3 of p3.2xlarge, no instance is launched with")
+	fs.StringSliceVar(&s.AllowedRegions, "allowed-regions", s.AllowedRegions, "Regions the controller may create resources in. The controller refuses to start in any other region. All regions are allowed if empty")
+	fs.StringSliceVar(&s.DeniedRegions, "denied-regions", s.DeniedRegions, "Regions the controller refuses to start in, even if they are allowed")
+	fs.StringSliceVar(&s.AllowedAvailabilityZones, "allowed-availability-zones", s.AllowedAvailabilityZones, "Availability zones, or Local Zones, instances may be launched in. Subnets of machines in other zones are skipped. All zones are allowed if empty")
+	fs.StringSliceVar(&s.DeniedAvailabilityZones, "denied-availability-zones", s.DeniedAvailabilityZones, "Availability zones, or Local Zones, no instances are launched in, even if they are allowed")
+	fs.BoolVar(&s.RequireChangeApproval, "require-change-approval", s.RequireChangeApproval, "Don't replace the instances of machines that didn't get a node in time or are impaired while their provider config has changes that only take effect on a new instance, like the instance type, until the changes are approved with the awsproviderconfig/approve-changes annotation. The changes and their digest are in the PendingChanges condition of the machine")
+	fs.BoolVar(&s.GracefulShutdown, "graceful-shutdown", s.GracefulShutdown, "Shut down the operating system of the instances of deleted machines with a gracefulShutdownTimeout through SSM Run Command, and wait for them to stop until the timeout before terminating them. Instances need the SSM agent and an instance profile allowing it, others are terminated right away")
+	fs.StringVar(&s.PolicyWebhookURL, "policy-webhook-url", s.PolicyWebhookURL, "URL the instances are POSTed to for a decision before they are created, like the rule of an Open Policy Agent. Nothing is checked if empty")
+}
@@ -0,0 +1,273 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package export renders the AWS resources managed for a cluster as declarative documents,
+// e.g. to keep a record of them for audits or disaster recovery.
+package export
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+)
+
+const templateFormatVersion = "2010-09-09"
+
+// Template is an AWS CloudFormation template.
+type Template struct {
+	AWSTemplateFormatVersion string              `json:"AWSTemplateFormatVersion"`
+	Description              string              `json:"Description,omitempty"`
+	Resources                map[string]Resource `json:"Resources"`
+}
+
+// Resource is a resource of a CloudFormation template.
+type Resource struct {
+	Type       string                 `json:"Type"`
+	Properties map[string]interface{} `json:"Properties"`
+
+	// Metadata records the id of the existing resource and its lifecycle for the cluster.
+	Metadata map[string]string `json:"Metadata,omitempty"`
+}
+
+// CloudFormation renders the resources of a cluster as a CloudFormation template.
+// References between resources of the template use Ref and Fn::GetAtt, resources outside of
+// the template, like the main route table of a vpc, are referred to by their id.
+func CloudFormation(clusterName string, r *ec2svc.ClusterResources) *Template {
+	b := &builder{
+		clusterName: clusterName,
+		logicalIDs:  make(map[string]string),
+		template: &Template{
+			AWSTemplateFormatVersion: templateFormatVersion,
+			Description:              fmt.Sprintf("AWS resources of cluster %q", clusterName),
+			Resources:                make(map[string]Resource),
+		},
+	}
+
+	// Register all logical ids first, so that references can be resolved in any order.
+	for _, v := range r.VPCs {
+		b.register("VPC", *v.VpcId)
+	}
+	for _, sn := range r.Subnets {
+		b.register("Subnet", *sn.SubnetId)
+	}
+	for _, ig := range r.InternetGateways {
+		b.register("InternetGateway", *ig.InternetGatewayId)
+	}
+	for _, addr := range r.Addresses {
+		b.register("EIP", *addr.AllocationId)
+	}
+	for _, ng := range r.NatGateways {
+		b.register("NatGateway", *ng.NatGatewayId)
+	}
+	for _, rt := range r.RouteTables {
+		b.register("RouteTable", *rt.RouteTableId)
+	}
+	for _, i := range r.Instances {
+		b.register("Instance", *i.InstanceId)
+	}
+
+	for _, v := range r.VPCs {
+		b.add(*v.VpcId, "AWS::EC2::VPC", v.Tags, map[string]interface{}{
+			"CidrBlock": aws.StringValue(v.CidrBlock),
+		})
+	}
+
+	for _, sn := range r.Subnets {
+		b.add(*sn.SubnetId, "AWS::EC2::Subnet", sn.Tags, map[string]interface{}{
+			"VpcId":               b.ref(aws.StringValue(sn.VpcId)),
+			"CidrBlock":           aws.StringValue(sn.CidrBlock),
+			"AvailabilityZone":    aws.StringValue(sn.AvailabilityZone),
+			"MapPublicIpOnLaunch": aws.BoolValue(sn.MapPublicIpOnLaunch),
+		})
+	}
+
+	for _, ig := range r.InternetGateways {
+		b.add(*ig.InternetGatewayId, "AWS::EC2::InternetGateway", ig.Tags, map[string]interface{}{})
+		for _, att := range ig.Attachments {
+			b.template.Resources[b.logicalIDs[*ig.InternetGatewayId]+"Attachment"+suffix(aws.StringValue(att.VpcId))] = Resource{
+				Type: "AWS::EC2::VPCGatewayAttachment",
+				Properties: map[string]interface{}{
+					"InternetGatewayId": b.ref(*ig.InternetGatewayId),
+					"VpcId":             b.ref(aws.StringValue(att.VpcId)),
+				},
+			}
+		}
+	}
+
+	for _, addr := range r.Addresses {
+		b.add(*addr.AllocationId, "AWS::EC2::EIP", addr.Tags, map[string]interface{}{
+			"Domain": aws.StringValue(addr.Domain),
+		})
+	}
+
+	for _, ng := range r.NatGateways {
+		props := map[string]interface{}{
+			"SubnetId": b.ref(aws.StringValue(ng.SubnetId)),
+		}
+		for _, addr := range ng.NatGatewayAddresses {
+			props["AllocationId"] = b.attr(aws.StringValue(addr.AllocationId), "AllocationId")
+		}
+		b.add(*ng.NatGatewayId, "AWS::EC2::NatGateway", ng.Tags, props)
+	}
+
+	for _, rt := range r.RouteTables {
+		b.add(*rt.RouteTableId, "AWS::EC2::RouteTable", rt.Tags, map[string]interface{}{
+			"VpcId": b.ref(aws.StringValue(rt.VpcId)),
+		})
+
+		for i, route := range rt.Routes {
+			if aws.StringValue(route.GatewayId) == "local" {
+				// The local route is part of every route table.
+				continue
+			}
+
+			props := map[string]interface{}{
+				"RouteTableId": b.ref(*rt.RouteTableId),
+			}
+			if route.DestinationIpv6CidrBlock != nil {
+				props["DestinationIpv6CidrBlock"] = *route.DestinationIpv6CidrBlock
+			} else {
+				props["DestinationCidrBlock"] = aws.StringValue(route.DestinationCidrBlock)
+			}
+			if route.EgressOnlyInternetGatewayId != nil {
+				// Egress-only internet gateways can't be tagged, and aren't part of the template.
+				props["EgressOnlyInternetGatewayId"] = *route.EgressOnlyInternetGatewayId
+			}
+			if route.GatewayId != nil {
+				props["GatewayId"] = b.ref(*route.GatewayId)
+			}
+			if route.NatGatewayId != nil {
+				props["NatGatewayId"] = b.ref(*route.NatGatewayId)
+			}
+			b.template.Resources[fmt.Sprintf("%sRoute%d", b.logicalIDs[*rt.RouteTableId], i)] = Resource{
+				Type:       "AWS::EC2::Route",
+				Properties: props,
+			}
+		}
+
+		for _, as := range rt.Associations {
+			if aws.BoolValue(as.Main) || as.SubnetId == nil {
+				continue
+			}
+
+			b.template.Resources[b.logicalIDs[*rt.RouteTableId]+"Association"+suffix(*as.SubnetId)] = Resource{
+				Type: "AWS::EC2::SubnetRouteTableAssociation",
+				Properties: map[string]interface{}{
+					"RouteTableId": b.ref(*rt.RouteTableId),
+					"SubnetId":     b.ref(*as.SubnetId),
+				},
+			}
+		}
+	}
+
+	for _, i := range r.Instances {
+		props := map[string]interface{}{
+			"ImageId":      aws.StringValue(i.ImageId),
+			"InstanceType": aws.StringValue(i.InstanceType),
+		}
+		if i.SubnetId != nil {
+			props["SubnetId"] = b.ref(*i.SubnetId)
+		}
+		if i.KeyName != nil {
+			props["KeyName"] = *i.KeyName
+		}
+		b.add(*i.InstanceId, "AWS::EC2::Instance", i.Tags, props)
+	}
+
+	return b.template
+}
+
+type builder struct {
+	clusterName string
+	template    *Template
+
+	// logicalIDs are the logical ids of the resources in the template by their resource id.
+	logicalIDs map[string]string
+}
+
+// register assigns a logical id to a resource, derived from its kind and resource id.
+func (b *builder) register(kind string, id string) {
+	b.logicalIDs[id] = kind + suffix(id)
+}
+
+// add adds a resource to the template.
+func (b *builder) add(id string, resourceType string, tags []*ec2.Tag, props map[string]interface{}) {
+	if t := cfnTags(tags); len(t) > 0 {
+		props["Tags"] = t
+	}
+
+	lifecycle := ""
+	for _, t := range tags {
+		if aws.StringValue(t.Key) == ec2svc.TagNameKubernetesClusterPrefix+b.clusterName {
+			lifecycle = aws.StringValue(t.Value)
+		}
+	}
+
+	b.template.Resources[b.logicalIDs[id]] = Resource{
+		Type:       resourceType,
+		Properties: props,
+		Metadata: map[string]string{
+			"PhysicalResourceId": id,
+			"Lifecycle":          lifecycle,
+		},
+	}
+}
+
+// ref returns a reference to the resource, or its id if the resource isn't part of the template.
+func (b *builder) ref(id string) interface{} {
+	if lid, ok := b.logicalIDs[id]; ok {
+		return map[string]string{"Ref": lid}
+	}
+	return id
+}
+
+// attr returns an attribute of the resource, or its id if the resource isn't part of the template.
+func (b *builder) attr(id string, name string) interface{} {
+	if lid, ok := b.logicalIDs[id]; ok {
+		return map[string][]string{"Fn::GetAtt": {lid, name}}
+	}
+	return id
+}
+
+// cfnTags converts EC2 tags to CloudFormation tags, in key order.
+// Tags reserved by AWS can't be set in templates and are left out.
+func cfnTags(tags []*ec2.Tag) []map[string]string {
+	res := make([]map[string]string, 0, len(tags))
+	for _, t := range tags {
+		if strings.HasPrefix(aws.StringValue(t.Key), "aws:") {
+			continue
+		}
+		res = append(res, map[string]string{"Key": aws.StringValue(t.Key), "Value": aws.StringValue(t.Value)})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i]["Key"] < res[j]["Key"] })
+	return res
+}
+
+// suffix returns the alphanumeric part of a resource id without its prefix,
+// e.g. "0a1b2c" for "subnet-0a1b2c".
+func suffix(id string) string {
+	if i := strings.Index(id, "-"); i >= 0 {
+		id = id[i+1:]
+	}
+
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, id)
+}
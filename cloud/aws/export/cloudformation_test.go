@@ -0,0 +1,130 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/export"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/fake"
+	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
+)
+
+func TestCloudFormation(t *testing.T) {
+	s := ec2svc.NewService(fake.New())
+
+	network := &v1alpha1.Network{}
+	for i := 0; ; i++ {
+		err := s.ReconcileNetwork(context.TODO(), "test-cluster", &v1alpha1.NetworkSpec{}, map[string]string{"owner": "team-a"}, network)
+		if err == nil {
+			break
+		}
+		if !ec2svc.IsNotReady(err) || i > 5 {
+			t.Fatalf("failed to reconcile network: %v", err)
+		}
+	}
+
+	if _, err := s.CreateInstance(context.TODO(), "test-cluster", "", nil, &clusterv1.Machine{}, &v1alpha1.AWSMachineProviderConfig{}); err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+
+	// Resources of other clusters are not exported.
+	if _, err := s.CreateInstance(context.TODO(), "other-cluster", "", nil, &clusterv1.Machine{}, &v1alpha1.AWSMachineProviderConfig{}); err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+
+	resources, err := s.DescribeClusterResources(context.TODO(), "test-cluster")
+	if err != nil {
+		t.Fatalf("failed to describe cluster resources: %v", err)
+	}
+
+	tmpl := export.CloudFormation("test-cluster", resources)
+
+	types := make(map[string]int)
+	for _, r := range tmpl.Resources {
+		types[r.Type]++
+	}
+
+	expected := map[string]int{
+		"AWS::EC2::VPC":                         1,
+		"AWS::EC2::Subnet":                      2,
+		"AWS::EC2::InternetGateway":             1,
+		"AWS::EC2::VPCGatewayAttachment":        1,
+		"AWS::EC2::EIP":                         1,
+		"AWS::EC2::NatGateway":                  1,
+		"AWS::EC2::RouteTable":                  2,
+		"AWS::EC2::Route":                       2,
+		"AWS::EC2::SubnetRouteTableAssociation": 2,
+		"AWS::EC2::Instance":                    1,
+	}
+	for typ, n := range expected {
+		if types[typ] != n {
+			t.Errorf("expected %d resources of type %s, got %d", n, typ, types[typ])
+		}
+	}
+
+	vpc := tmpl.Resources["VPC"+suffix(network.VPC.ID)]
+	if vpc.Metadata["PhysicalResourceId"] != network.VPC.ID || vpc.Metadata["Lifecycle"] != "owned" {
+		t.Errorf("expected the vpc to record its id and lifecycle, got: %v", vpc.Metadata)
+	}
+
+	// All references point to resources of the template.
+	raw, err := json.Marshal(tmpl)
+	if err != nil {
+		t.Fatalf("failed to marshal template: %v", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("failed to unmarshal template: %v", err)
+	}
+	checkRefs(t, doc, tmpl.Resources)
+}
+
+// checkRefs verifies that all Ref and Fn::GetAtt values refer to resources in the template.
+func checkRefs(t *testing.T, v interface{}, resources map[string]export.Resource) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["Ref"].(string); ok {
+			if _, ok := resources[ref]; !ok {
+				t.Errorf("reference to unknown resource %q", ref)
+			}
+		}
+		if attr, ok := v["Fn::GetAtt"].([]interface{}); ok {
+			if _, ok := resources[attr[0].(string)]; !ok {
+				t.Errorf("attribute of unknown resource %q", attr[0])
+			}
+		}
+		for _, e := range v {
+			checkRefs(t, e, resources)
+		}
+	case []interface{}:
+		for _, e := range v {
+			checkRefs(t, e, resources)
+		}
+	}
+}
+
+func suffix(id string) string {
+	for i := range id {
+		if id[i] == '-' {
+			return id[i+1:]
+		}
+	}
+	return id
+}
@@ -0,0 +1,201 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iampolicy
+
+// controllerReadActions are the read only calls of the controllers. EC2 doesn't support
+// resource-level permissions for them, and the instance profiles of machines may have any path.
+var controllerReadActions = []string{
+	"ec2:DescribeAddresses",
+	"ec2:DescribeAvailabilityZones",
+	"ec2:DescribeEgressOnlyInternetGateways",
+	"ec2:DescribeImages",
+	"ec2:DescribeInstanceStatus",
+	"ec2:DescribeInstances",
+	"ec2:DescribeInternetGateways",
+	"ec2:DescribeLaunchTemplateVersions",
+	"ec2:DescribeLaunchTemplates",
+	"ec2:DescribeNatGateways",
+	"ec2:DescribeNetworkInterfaces",
+	"ec2:DescribeRouteTables",
+	"ec2:DescribeSecurityGroups",
+	"ec2:DescribeSubnets",
+	"ec2:DescribeVolumes",
+	"ec2:DescribeVolumesModifications",
+	"ec2:DescribeVpcAttribute",
+	"ec2:DescribeVpcEndpoints",
+	"ec2:DescribeVpcs",
+	"elasticfilesystem:DescribeFileSystems",
+	"elasticfilesystem:DescribeMountTargets",
+	"elasticfilesystem:DescribeTags",
+	"elasticloadbalancing:DescribeLoadBalancers",
+	"elasticloadbalancing:DescribeTags",
+	"elasticloadbalancing:DescribeTargetGroups",
+	"elasticloadbalancing:DescribeTargetHealth",
+	"iam:GetInstanceProfile",
+	"pricing:GetProducts",
+	"route53:ListHostedZonesByName",
+}
+
+// controllerCreateActions create resources that are only tagged once they exist, so that they
+// can't be scoped to the cluster by tags.
+var controllerCreateActions = []string{
+	"ec2:AllocateAddress",
+	"ec2:CreateEgressOnlyInternetGateway",
+	"ec2:CreateInternetGateway",
+	"ec2:CreateLaunchTemplate",
+	"ec2:CreateNatGateway",
+	"ec2:CreateRouteTable",
+	"ec2:CreateSecurityGroup",
+	"ec2:CreateSubnet",
+	"ec2:CreateVpc",
+	"elasticfilesystem:CreateFileSystem",
+	"elasticfilesystem:CreateMountTarget",
+	"route53:CreateHostedZone",
+}
+
+// controllerTaggedActions change or delete resources of a cluster.
+var controllerTaggedActions = []string{
+	"ec2:AssociateRouteTable",
+	"ec2:AssociateVpcCidrBlock",
+	"ec2:AttachInternetGateway",
+	"ec2:AuthorizeSecurityGroupIngress",
+	"ec2:CreateLaunchTemplateVersion",
+	"ec2:CreateRoute",
+	"ec2:DeleteEgressOnlyInternetGateway",
+	"ec2:DeleteInternetGateway",
+	"ec2:DeleteLaunchTemplate",
+	"ec2:DeleteNatGateway",
+	"ec2:DeleteNetworkInterface",
+	"ec2:DeleteRouteTable",
+	"ec2:DeleteSecurityGroup",
+	"ec2:DeleteSubnet",
+	"ec2:DeleteTags",
+	"ec2:DeleteVolume",
+	"ec2:DeleteVpc",
+	"ec2:DetachInternetGateway",
+	"ec2:DisassociateRouteTable",
+	"ec2:GetConsoleOutput",
+	"ec2:ModifySubnetAttribute",
+	"ec2:ModifyVolume",
+	"ec2:ModifyVpcAttribute",
+	"ec2:ReleaseAddress",
+	"ec2:ReplaceRoute",
+	"ec2:RevokeSecurityGroupIngress",
+	"ec2:StartInstances",
+	"ec2:StopInstances",
+	"ec2:TerminateInstances",
+	"elasticfilesystem:DeleteFileSystem",
+	"elasticfilesystem:DeleteMountTarget",
+	"elasticloadbalancing:DeleteLoadBalancer",
+	"elasticloadbalancing:DeleteTargetGroup",
+	"elasticloadbalancing:DeregisterInstancesFromLoadBalancer",
+	"elasticloadbalancing:DeregisterTargets",
+	"elasticloadbalancing:RegisterInstancesWithLoadBalancer",
+	"elasticloadbalancing:RegisterTargets",
+	"ssm:SendCommand",
+}
+
+// runInstancesResources are the resources instances are launched with, besides the instances
+// and volumes created.
+var runInstancesResources = []string{
+	"arn:aws:ec2:*::image/*",
+	"arn:aws:ec2:*:*:key-pair/*",
+	"arn:aws:ec2:*:*:launch-template/*",
+	"arn:aws:ec2:*:*:network-interface/*",
+	"arn:aws:ec2:*:*:security-group/*",
+	"arn:aws:ec2:*:*:subnet/*",
+}
+
+// shutdownDocument is the SSM document the instances of deleted machines are shut down with.
+// The instances the commands are sent to are scoped to the clusters by tags.
+const shutdownDocument = "arn:aws:ssm:*::document/AWS-RunShellScript"
+
+// resourceGroupActions manage the resource group of a cluster, which is named after it.
+var resourceGroupActions = []string{
+	"resource-groups:CreateGroup",
+	"resource-groups:DeleteGroup",
+	"resource-groups:GetGroup",
+	"resource-groups:GetGroupQuery",
+	"resource-groups:GetTags",
+	"resource-groups:UpdateGroupQuery",
+}
+
+// privateHostedZoneActions manage the private hosted zones of clusters. Route 53 doesn't support
+// conditions on tags, and the ids of the zones are only known once they exist.
+var privateHostedZoneActions = []string{
+	"route53:AssociateVPCWithHostedZone",
+	"route53:ChangeResourceRecordSets",
+	"route53:ChangeTagsForResource",
+	"route53:DeleteHostedZone",
+	"route53:GetHostedZone",
+	"route53:ListResourceRecordSets",
+	"route53:ListTagsForResource",
+}
+
+// nodeRoleActions manage the roles and instance profiles of the nodes of clusters, which are
+// kept under the path of the provider.
+var nodeRoleActions = []string{
+	"iam:AddRoleToInstanceProfile",
+	"iam:CreateInstanceProfile",
+	"iam:CreateRole",
+	"iam:DeleteInstanceProfile",
+	"iam:DeleteRole",
+	"iam:DeleteRolePolicy",
+	"iam:GetRole",
+	"iam:GetRolePolicy",
+	"iam:ListRolePolicies",
+	"iam:PassRole",
+	"iam:PutRolePolicy",
+	"iam:RemoveRoleFromInstanceProfile",
+}
+
+// nodeRoleResources are the roles and instance profiles of the nodes of clusters.
+var nodeRoleResources = []string{
+	"arn:aws:iam::*:instance-profile/cluster-api-provider-aws/*",
+	"arn:aws:iam::*:role/cluster-api-provider-aws/*",
+}
+
+// Controller returns the policy of controllers managing the clusters. Resources can only be
+// changed or deleted if they are tagged for one of the clusters, and instances and volumes are
+// only launched tagged as owned by one of them. Resources that are tagged once they exist can
+// be created in any vpc, as EC2 can't check tags they don't have yet.
+func Controller(clusterNames []string) *Document {
+	doc := &Document{
+		Version: Version,
+		Statement: []Statement{
+			allow("Read", controllerReadActions, "*"),
+			allow("Create", controllerCreateActions, "*"),
+			allow("RunInstancesResources", []string{"ec2:RunInstances"}, runInstancesResources...),
+			allow("ShutdownDocument", []string{"ssm:SendCommand"}, shutdownDocument),
+			allow("PrivateHostedZones", privateHostedZoneActions, "arn:aws:route53:::hostedzone/*"),
+			allow("NodeRoles", nodeRoleActions, nodeRoleResources...),
+		},
+	}
+
+	for i, name := range clusterNames {
+		run := allow(sid("RunInstances", i), []string{"ec2:RunInstances"}, "arn:aws:ec2:*:*:instance/*", "arn:aws:ec2:*:*:volume/*")
+		run.Condition = ownedBy(name)
+
+		// New resources are tagged with the cluster tag, tagged resources may get other tags.
+		tagNew := allow(sid("TagNew", i), []string{"ec2:CreateTags", "elasticfilesystem:CreateTags"}, "*")
+		tagNew.Condition = taggedFor("aws:RequestTag", name)
+		tagged := allow(sid("Tagged", i), append([]string{"ec2:CreateTags", "elasticfilesystem:CreateTags"}, controllerTaggedActions...), "*")
+		tagged.Condition = taggedFor("aws:ResourceTag", name)
+
+		group := allow(sid("ResourceGroup", i), resourceGroupActions, "arn:aws:resource-groups:*:*:group/"+name)
+
+		doc.Statement = append(doc.Statement, run, tagNew, tagged, group)
+	}
+	return doc
+}
@@ -0,0 +1,133 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iampolicy
+
+// cloudProviderReadActions are the read only calls of the Kubernetes AWS cloud provider.
+var cloudProviderReadActions = []string{
+	"ec2:DescribeInstances",
+	"ec2:DescribeRegions",
+	"ec2:DescribeRouteTables",
+	"ec2:DescribeSecurityGroups",
+	"ec2:DescribeSubnets",
+	"ec2:DescribeVolumes",
+	"ec2:DescribeVpcs",
+	"elasticloadbalancing:DescribeLoadBalancerAttributes",
+	"elasticloadbalancing:DescribeLoadBalancerPolicies",
+	"elasticloadbalancing:DescribeLoadBalancers",
+	"elasticloadbalancing:DescribeListeners",
+	"elasticloadbalancing:DescribeTargetGroups",
+	"elasticloadbalancing:DescribeTargetHealth",
+}
+
+// cloudProviderCreateActions create the load balancers of services and the volumes of
+// persistent volumes, tagged for the cluster.
+var cloudProviderCreateActions = []string{
+	"ec2:CreateVolume",
+	"elasticloadbalancing:CreateLoadBalancer",
+	"elasticloadbalancing:CreateTargetGroup",
+}
+
+// cloudProviderTaggedActions change or delete resources of the cluster.
+var cloudProviderTaggedActions = []string{
+	"ec2:AttachVolume",
+	"ec2:AuthorizeSecurityGroupIngress",
+	"ec2:CreateRoute",
+	"ec2:DeleteRoute",
+	"ec2:DeleteSecurityGroup",
+	"ec2:DeleteVolume",
+	"ec2:DetachVolume",
+	"ec2:ModifyInstanceAttribute",
+	"ec2:RevokeSecurityGroupIngress",
+	"elasticloadbalancing:AddTags",
+	"elasticloadbalancing:ApplySecurityGroupsToLoadBalancer",
+	"elasticloadbalancing:AttachLoadBalancerToSubnets",
+	"elasticloadbalancing:ConfigureHealthCheck",
+	"elasticloadbalancing:CreateListener",
+	"elasticloadbalancing:CreateLoadBalancerListeners",
+	"elasticloadbalancing:CreateLoadBalancerPolicy",
+	"elasticloadbalancing:DeleteListener",
+	"elasticloadbalancing:DeleteLoadBalancer",
+	"elasticloadbalancing:DeleteLoadBalancerListeners",
+	"elasticloadbalancing:DeleteTargetGroup",
+	"elasticloadbalancing:DeregisterInstancesFromLoadBalancer",
+	"elasticloadbalancing:DeregisterTargets",
+	"elasticloadbalancing:DetachLoadBalancerFromSubnets",
+	"elasticloadbalancing:ModifyListener",
+	"elasticloadbalancing:ModifyLoadBalancerAttributes",
+	"elasticloadbalancing:ModifyTargetGroup",
+	"elasticloadbalancing:RegisterInstancesWithLoadBalancer",
+	"elasticloadbalancing:RegisterTargets",
+	"elasticloadbalancing:SetLoadBalancerPoliciesForBackendServer",
+	"elasticloadbalancing:SetLoadBalancerPoliciesOfListener",
+}
+
+// nodeReadActions are the calls of the kubelet and the CNI plugin on every node.
+var nodeReadActions = []string{
+	"ec2:DescribeInstances",
+	"ec2:DescribeRegions",
+	"ecr:GetAuthorizationToken",
+}
+
+// ecrPullActions are the calls of the kubelet to pull images from ECR repositories.
+var ecrPullActions = []string{
+	"ecr:BatchCheckLayerAvailability",
+	"ecr:BatchGetImage",
+	"ecr:DescribeRepositories",
+	"ecr:GetDownloadUrlForLayer",
+	"ecr:GetRepositoryPolicy",
+	"ecr:ListImages",
+}
+
+// ControlPlane returns the policy of the control plane nodes of the cluster, which run the
+// Kubernetes AWS cloud provider. It may only change or delete resources tagged for the cluster
+// and creates them tagged for it.
+func ControlPlane(clusterName string) *Document {
+	create := allow("Create", cloudProviderCreateActions, "*")
+	create.Condition = ownedBy(clusterName)
+
+	// Security groups of load balancers are only tagged once they exist.
+	createSecurityGroup := allow("CreateSecurityGroup", []string{"ec2:CreateSecurityGroup"}, "*")
+
+	tagNew := allow("TagNew", []string{"ec2:CreateTags"}, "*")
+	tagNew.Condition = taggedFor("aws:RequestTag", clusterName)
+	tagged := allow("Tagged", append([]string{"ec2:CreateTags"}, cloudProviderTaggedActions...), "*")
+	tagged.Condition = taggedFor("aws:ResourceTag", clusterName)
+
+	return &Document{
+		Version: Version,
+		Statement: []Statement{
+			allow("Read", cloudProviderReadActions, "*"),
+			create,
+			createSecurityGroup,
+			tagNew,
+			tagged,
+		},
+	}
+}
+
+// Node returns the policy of the worker nodes of a cluster, which only look up instances and
+// pull images from the given ECR repositories, or from every repository if none are given.
+// ecr:GetAuthorizationToken isn't bound to a repository and always allowed.
+func Node(ecrRepositories []string) *Document {
+	if len(ecrRepositories) == 0 {
+		ecrRepositories = []string{"*"}
+	}
+	return &Document{
+		Version: Version,
+		Statement: []Statement{
+			allow("Read", nodeReadActions, "*"),
+			allow("PullImages", ecrPullActions, ecrRepositories...),
+		},
+	}
+}
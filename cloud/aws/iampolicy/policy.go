@@ -0,0 +1,69 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package iampolicy generates the IAM policies of the controllers and of the nodes of clusters.
+// Every action that supports it is scoped to the resources tagged for the cluster, with
+// aws:ResourceTag conditions for existing resources and aws:RequestTag conditions for the
+// requests tagging them.
+package iampolicy
+
+import (
+	"fmt"
+
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+)
+
+// Version is the version of the policy language of the documents.
+const Version = "2012-10-17"
+
+// Document is an IAM policy document.
+type Document struct {
+	Version   string      `json:"Version"`
+	Statement []Statement `json:"Statement"`
+}
+
+// Statement is a statement of an IAM policy document.
+type Statement struct {
+	Sid    string `json:"Sid,omitempty"`
+	Effect string `json:"Effect"`
+	// Principal is only set in the trust policies of roles, which have no resources.
+	Principal map[string]string `json:"Principal,omitempty"`
+	Action    []string          `json:"Action"`
+	Resource  []string          `json:"Resource,omitempty"`
+	Condition Conditions        `json:"Condition,omitempty"`
+}
+
+// Conditions are the conditions of a statement, by operator and then condition key.
+type Conditions map[string]map[string]string
+
+// allow returns a statement allowing the actions on the resources.
+func allow(sid string, actions []string, resources ...string) Statement {
+	return Statement{Sid: sid, Effect: "Allow", Action: actions, Resource: resources}
+}
+
+// taggedFor returns conditions requiring the tag of the cluster to be set on the resource, or
+// in the request if key is aws:RequestTag. Resources shared with the cluster carry the tag too.
+func taggedFor(key, clusterName string) Conditions {
+	return Conditions{"Null": {fmt.Sprintf("%s/%s%s", key, ec2svc.TagNameKubernetesClusterPrefix, clusterName): "false"}}
+}
+
+// ownedBy returns conditions requiring the request to tag the resource as owned by the cluster.
+func ownedBy(clusterName string) Conditions {
+	return Conditions{"StringEquals": {"aws:RequestTag/" + ec2svc.TagNameKubernetesClusterPrefix + clusterName: ec2svc.ResourceLifecycleOwned}}
+}
+
+// sid returns a statement id unique per cluster. Statement ids may only contain alphanumeric
+// characters, so the index of the cluster is used instead of its name.
+func sid(name string, i int) string {
+	return fmt.Sprintf("%sCluster%d", name, i)
+}
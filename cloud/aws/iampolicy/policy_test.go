@@ -0,0 +1,103 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iampolicy
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// findStatement returns the statement with the given id.
+func findStatement(t *testing.T, doc *Document, sid string) Statement {
+	for _, s := range doc.Statement {
+		if s.Sid == sid {
+			return s
+		}
+	}
+	t.Fatalf("statement %q not found", sid)
+	return Statement{}
+}
+
+func TestController(t *testing.T) {
+	doc := Controller([]string{"a", "b"})
+
+	sids := make(map[string]bool)
+	for _, s := range doc.Statement {
+		if sids[s.Sid] {
+			t.Fatalf("duplicate statement id %q", s.Sid)
+		}
+		sids[s.Sid] = true
+
+		// Only reads and creates of untagged resources are allowed without conditions.
+		if s.Condition != nil || s.Resource[0] != "*" {
+			continue
+		}
+		for _, action := range s.Action {
+			if strings.Contains(action, ":Delete") || strings.Contains(action, ":Terminate") || strings.Contains(action, ":CreateTags") {
+				t.Fatalf("statement %q allows %s on every resource", s.Sid, action)
+			}
+		}
+	}
+
+	tagged := findStatement(t, doc, "TaggedCluster1")
+	if tagged.Condition["Null"]["aws:ResourceTag/kubernetes.io/cluster/b"] != "false" {
+		t.Fatalf("expected the actions to be scoped to resources tagged for cluster b, got %v", tagged.Condition)
+	}
+
+	run := findStatement(t, doc, "RunInstancesCluster0")
+	if run.Condition["StringEquals"]["aws:RequestTag/kubernetes.io/cluster/a"] != "owned" {
+		t.Fatalf("expected instances to be launched owned by cluster a, got %v", run.Condition)
+	}
+
+	group := findStatement(t, doc, "ResourceGroupCluster0")
+	if group.Resource[0] != "arn:aws:resource-groups:*:*:group/a" {
+		t.Fatalf("expected the resource group actions to be scoped to the group of cluster a, got %v", group.Resource)
+	}
+}
+
+func TestControlPlane(t *testing.T) {
+	doc := ControlPlane("a")
+
+	create := findStatement(t, doc, "Create")
+	if create.Condition["StringEquals"]["aws:RequestTag/kubernetes.io/cluster/a"] != "owned" {
+		t.Fatalf("expected load balancers and volumes to be created owned by the cluster, got %v", create.Condition)
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to marshal policy: %v", err)
+	}
+	if !strings.Contains(string(data), `"Version":"2012-10-17"`) {
+		t.Fatalf("unexpected policy: %s", data)
+	}
+}
+
+func TestNode(t *testing.T) {
+	pull := findStatement(t, Node(nil), "PullImages")
+	if len(pull.Resource) != 1 || pull.Resource[0] != "*" {
+		t.Fatalf("expected images to be pulled from every repository, got %v", pull.Resource)
+	}
+
+	repository := "arn:aws:ecr:eu-west-1:123456789012:repository/app"
+	doc := Node([]string{repository})
+	pull = findStatement(t, doc, "PullImages")
+	if len(pull.Resource) != 1 || pull.Resource[0] != repository {
+		t.Fatalf("expected images to only be pulled from %q, got %v", repository, pull.Resource)
+	}
+	read := findStatement(t, doc, "Read")
+	if read.Resource[0] != "*" {
+		t.Fatalf("expected the read actions to not be restricted, got %v", read.Resource)
+	}
+}
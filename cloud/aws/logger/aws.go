@@ -0,0 +1,39 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/go-logr/logr"
+)
+
+// AWSRequestHandler returns an SDK handler that logs every completed AWS API call
+// together with its request ID, so that log lines can be correlated with CloudTrail
+// and AWS support cases.
+func AWSRequestHandler(log logr.Logger) request.NamedHandler {
+	return request.NamedHandler{
+		Name: "awsprovider.logger.AWSRequestHandler",
+		Fn: func(r *request.Request) {
+			kvs := []interface{}{
+				"service", r.ClientInfo.ServiceName,
+				"operation", r.Operation.Name,
+				"request-id", r.RequestID,
+			}
+			if r.Error != nil {
+				kvs = append(kvs, "error", r.Error)
+			}
+			log.V(4).Info("AWS request completed", kvs...)
+		},
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// Format is the output format of a logger.
+type Format string
+
+const (
+	// FormatText writes human readable key=value lines through glog.
+	FormatText Format = "text"
+
+	// FormatJSON writes one JSON object per line to stderr.
+	FormatJSON Format = "json"
+)
+
+var _ logr.Logger = &logger{}
+
+// logger implements logr.Logger. Verbosity is always controlled by the glog -v flag,
+// so both formats honour the same V() levels.
+type logger struct {
+	format Format
+	level  int
+	name   string
+	values []interface{}
+
+	mu  *sync.Mutex
+	out io.Writer
+}
+
+// New returns a new logger for the given format.
+func New(format Format) (logr.Logger, error) {
+	switch format {
+	case FormatText, FormatJSON:
+	default:
+		return nil, errors.Errorf("unknown log format %q, must be one of %q or %q", format, FormatText, FormatJSON)
+	}
+
+	return &logger{
+		format: format,
+		mu:     &sync.Mutex{},
+		out:    os.Stderr,
+	}, nil
+}
+
+// Default returns a text logger.
+func Default() logr.Logger {
+	log, _ := New(FormatText)
+	return log
+}
+
+// Info logs a non-error message with the given key/value pairs as context.
+func (l *logger) Info(msg string, keysAndValues ...interface{}) {
+	if !l.Enabled() {
+		return
+	}
+
+	if l.format == FormatJSON {
+		l.writeJSON("info", nil, msg, keysAndValues)
+		return
+	}
+
+	glog.InfoDepth(1, l.text(msg, keysAndValues))
+}
+
+// Enabled tests whether this logger is enabled at its verbosity level.
+func (l *logger) Enabled() bool {
+	return bool(glog.V(glog.Level(l.level)))
+}
+
+// Error logs an error, with the given message and key/value pairs as context.
+func (l *logger) Error(err error, msg string, keysAndValues ...interface{}) {
+	if l.format == FormatJSON {
+		l.writeJSON("error", err, msg, keysAndValues)
+		return
+	}
+
+	glog.ErrorDepth(1, l.text(msg, append(keysAndValues, "error", err)))
+}
+
+// V returns a logger for a specific verbosity level, relative to this logger.
+func (l *logger) V(level int) logr.InfoLogger {
+	c := l.clone()
+	c.level += level
+	return c
+}
+
+// WithValues adds some key/value pairs of context to a logger.
+func (l *logger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	c := l.clone()
+	c.values = append(c.values, keysAndValues...)
+	return c
+}
+
+// WithName adds a new element to the logger's name.
+func (l *logger) WithName(name string) logr.Logger {
+	c := l.clone()
+	if c.name == "" {
+		c.name = name
+	} else {
+		c.name = c.name + "." + name
+	}
+	return c
+}
+
+func (l *logger) clone() *logger {
+	c := *l
+	c.values = make([]interface{}, len(l.values), len(l.values)+2)
+	copy(c.values, l.values)
+	return &c
+}
+
+func (l *logger) text(msg string, keysAndValues []interface{}) string {
+	var buf bytes.Buffer
+	if l.name != "" {
+		buf.WriteString(l.name)
+		buf.WriteString(" ")
+	}
+	fmt.Fprintf(&buf, "%q", msg)
+
+	kvs := append(l.values[:len(l.values):len(l.values)], keysAndValues...)
+	for i := 0; i < len(kvs); i += 2 {
+		k, v := kvs[i], interface{}("<missing>")
+		if i+1 < len(kvs) {
+			v = kvs[i+1]
+		}
+		fmt.Fprintf(&buf, " %v=%q", k, fmt.Sprint(toValue(v)))
+	}
+
+	return buf.String()
+}
+
+func (l *logger) writeJSON(severity string, err error, msg string, keysAndValues []interface{}) {
+	entry := map[string]interface{}{
+		"ts":       time.Now().UTC().Format(time.RFC3339Nano),
+		"severity": severity,
+		"msg":      msg,
+	}
+	if l.level > 0 {
+		entry["v"] = l.level
+	}
+	if l.name != "" {
+		entry["logger"] = l.name
+	}
+	if err != nil {
+		entry["error"] = err.Error()
+	}
+
+	kvs := append(l.values[:len(l.values):len(l.values)], keysAndValues...)
+	for i := 0; i < len(kvs); i += 2 {
+		k, v := fmt.Sprint(kvs[i]), interface{}("<missing>")
+		if i+1 < len(kvs) {
+			v = kvs[i+1]
+		}
+		entry[k] = toValue(v)
+	}
+
+	b, merr := json.Marshal(entry)
+	if merr != nil {
+		// Fall back to string values for everything that can't be marshaled.
+		for k, v := range entry {
+			entry[k] = fmt.Sprint(v)
+		}
+		b, _ = json.Marshal(entry)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(append(b, '\n'))
+}
+
+// toValue dereferences common pointer types so that log output doesn't contain addresses.
+func toValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case error:
+		return t.Error()
+	case *string:
+		if t == nil {
+			return nil
+		}
+		return *t
+	case fmt.Stringer:
+		return t.String()
+	}
+	return v
+}
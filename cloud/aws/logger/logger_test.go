@@ -0,0 +1,72 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestNewUnknownFormat(t *testing.T) {
+	if _, err := New(Format("xml")); err == nil {
+		t.Fatalf("expected an error for an unknown format")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+
+	log, err := New(FormatJSON)
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	log.(*logger).out = &buf
+
+	log.WithName("cluster-actuator").
+		WithValues("cluster", "test-cluster").
+		Error(errors.New("boom"), "Reconcile failed", "vpc-id", aws.String("vpc-1"))
+
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to unmarshal log line %q: %v", buf.String(), err)
+	}
+
+	expected := map[string]interface{}{
+		"severity": "error",
+		"logger":   "cluster-actuator",
+		"msg":      "Reconcile failed",
+		"error":    "boom",
+		"cluster":  "test-cluster",
+		"vpc-id":   "vpc-1",
+	}
+	for k, v := range expected {
+		if entry[k] != v {
+			t.Errorf("expected %q to be %q, got %q", k, v, entry[k])
+		}
+	}
+}
+
+func TestWithValuesDoesNotShareState(t *testing.T) {
+	base := Default().WithValues("cluster", "a")
+	base.WithValues("machine", "m1")
+	other := base.WithValues("machine", "m2")
+
+	if got := other.(*logger).text("msg", nil); got != `"msg" cluster="a" machine="m2"` {
+		t.Fatalf("unexpected text output: %s", got)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Locations restricts the regions and availability zones resources are created in, like to keep
+// data in the regions it may be stored in. Nil or empty lists don't restrict anything, a
+// location that is both allowed and denied is denied.
+type Locations struct {
+	// AllowedRegions are the only regions resources may be created in.
+	AllowedRegions []string
+	// DeniedRegions are the regions no resources may be created in.
+	DeniedRegions []string
+	// AllowedZones are the only availability zones, or Local Zones, resources may be created in.
+	AllowedZones []string
+	// DeniedZones are the availability zones, or Local Zones, no resources may be created in.
+	DeniedZones []string
+}
+
+// CheckRegion returns a violation if resources may not be created in the region.
+func (l *Locations) CheckRegion(region string) error {
+	if l == nil {
+		return nil
+	}
+	return check("region", region, l.AllowedRegions, l.DeniedRegions)
+}
+
+// CheckZone returns a violation if resources may not be created in the availability zone.
+func (l *Locations) CheckZone(zone string) error {
+	if l == nil {
+		return nil
+	}
+	return check("availability zone", zone, l.AllowedZones, l.DeniedZones)
+}
+
+func check(kind string, name string, allowed []string, denied []string) error {
+	if contains(denied, name) {
+		return NewViolation(errors.Errorf("%s %q is denied", kind, name))
+	}
+	if len(allowed) > 0 && !contains(allowed, name) {
+		return NewViolation(errors.Errorf("%s %q is not allowed", kind, name))
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"testing"
+)
+
+func TestLocations(t *testing.T) {
+	l := &Locations{
+		AllowedRegions: []string{"eu-central-1", "eu-west-1"},
+		DeniedRegions:  []string{"eu-west-1"},
+		DeniedZones:    []string{"eu-central-1c"},
+	}
+
+	testCases := []struct {
+		name      string
+		check     func() error
+		violation bool
+	}{
+		{name: "allowed region", check: func() error { return l.CheckRegion("eu-central-1") }},
+		{name: "region not allowed", check: func() error { return l.CheckRegion("us-east-1") }, violation: true},
+		{name: "allowed and denied region", check: func() error { return l.CheckRegion("eu-west-1") }, violation: true},
+		{name: "zone without allowed zones", check: func() error { return l.CheckZone("eu-central-1a") }},
+		{name: "denied zone", check: func() error { return l.CheckZone("eu-central-1c") }, violation: true},
+		{name: "no locations", check: func() error { return (*Locations)(nil).CheckZone("us-east-1a") }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.check()
+			if tc.violation != IsViolation(err) || (!tc.violation && err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
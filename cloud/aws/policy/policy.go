@@ -0,0 +1,117 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package policy checks the resources of clusters and machines against the policy of an
+// organization before they are created, like tags every resource must have.
+package policy
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+)
+
+// Kind is the kind of a checked resource.
+type Kind string
+
+const (
+	// KindCluster is the network and the other resources of a cluster.
+	KindCluster Kind = "cluster"
+	// KindInstance is the instance of a machine.
+	KindInstance Kind = "instance"
+)
+
+// Resource describes a resource about to be created.
+type Resource struct {
+	// Kind is the kind of the resource.
+	Kind Kind
+	// ClusterName is the name of the cluster the resource belongs to.
+	ClusterName string
+	// Tags are the additional tags the resource is created with.
+	Tags map[string]string
+	// InstanceType is the instance type of instances.
+	InstanceType string
+	// Cluster is the provider config of the cluster.
+	Cluster *v1alpha1.AWSClusterProviderConfig
+	// Machine is the provider config of the machine of instances.
+	Machine *v1alpha1.AWSMachineProviderConfig
+}
+
+// Checker checks resources before they are created. It returns a violation error if a resource
+// must not be created.
+type Checker interface {
+	Check(r Resource) error
+}
+
+// violation is the error of a resource violating the policy.
+type violation struct {
+	error
+}
+
+// NewViolation returns an error for a resource violating the policy.
+func NewViolation(err error) error {
+	return &violation{error: err}
+}
+
+// IsViolation returns true if the cause of the error is a policy violation.
+func IsViolation(err error) bool {
+	_, ok := errors.Cause(err).(*violation)
+	return ok
+}
+
+// All is a checker requiring every one of its checkers to pass.
+type All []Checker
+
+// Check implements Checker.
+func (a All) Check(r Resource) error {
+	for _, c := range a {
+		if err := c.Check(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Static is a checker requiring tags on every resource and denying instance families.
+type Static struct {
+	// RequiredTags are the tag keys every resource must have with a non-empty value.
+	RequiredTags []string
+	// DeniedInstanceFamilies are the instance families, like p3 of p3.2xlarge, instances must not have.
+	DeniedInstanceFamilies []string
+}
+
+// Check implements Checker.
+func (s *Static) Check(r Resource) error {
+	var missing []string
+	for _, key := range s.RequiredTags {
+		if r.Tags[key] == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return NewViolation(errors.Errorf("%s is missing the required tags %s", r.Kind, strings.Join(missing, ", ")))
+	}
+
+	if r.Kind == KindInstance {
+		family := strings.SplitN(r.InstanceType, ".", 2)[0]
+		for _, denied := range s.DeniedInstanceFamilies {
+			if family == denied {
+				return NewViolation(errors.Errorf("instance type %q is of the denied instance family %q", r.InstanceType, denied))
+			}
+		}
+	}
+	return nil
+}
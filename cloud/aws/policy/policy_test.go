@@ -0,0 +1,67 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestStaticCheck(t *testing.T) {
+	checker := &Static{
+		RequiredTags:           []string{"cost-center", "team"},
+		DeniedInstanceFamilies: []string{"p3", "x1e"},
+	}
+	tags := map[string]string{"cost-center": "platform", "team": "ml"}
+
+	testCases := []struct {
+		name      string
+		resource  Resource
+		violation bool
+	}{
+		{
+			name:     "cluster with tags",
+			resource: Resource{Kind: KindCluster, Tags: tags},
+		},
+		{
+			name:      "cluster missing a tag",
+			resource:  Resource{Kind: KindCluster, Tags: map[string]string{"team": "ml"}},
+			violation: true,
+		},
+		{
+			name:      "empty tag",
+			resource:  Resource{Kind: KindInstance, Tags: map[string]string{"cost-center": "", "team": "ml"}, InstanceType: "m5.large"},
+			violation: true,
+		},
+		{
+			name:     "allowed instance family",
+			resource: Resource{Kind: KindInstance, Tags: tags, InstanceType: "p2.xlarge"},
+		},
+		{
+			name:      "denied instance family",
+			resource:  Resource{Kind: KindInstance, Tags: tags, InstanceType: "p3.2xlarge"},
+			violation: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checker.Check(tc.resource)
+			if tc.violation != IsViolation(errors.Wrap(err, "wrapped")) || (!tc.violation && err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+)
+
+// webhookTimeout is how long a webhook may take to decide.
+const webhookTimeout = 10 * time.Second
+
+// Webhook is a checker that lets an HTTP endpoint decide, so that platform teams can add checks
+// of their own without changing the provider. The request and response bodies follow the data
+// API of the Open Policy Agent, whose rule for a decision can be used as the URL directly, e.g.
+// http://opa:8181/v1/data/capa/decision.
+type Webhook struct {
+	// URL is where the resources are POSTed to.
+	URL string
+	// Client makes the requests. If nil, a client with a timeout of 10 seconds is used.
+	Client *http.Client
+}
+
+// WebhookInput describes the resource to the webhook.
+type WebhookInput struct {
+	Kind         Kind                               `json:"kind"`
+	ClusterName  string                             `json:"clusterName"`
+	Tags         map[string]string                  `json:"tags,omitempty"`
+	InstanceType string                             `json:"instanceType,omitempty"`
+	Cluster      *v1alpha1.AWSClusterProviderConfig `json:"cluster,omitempty"`
+	Machine      *v1alpha1.AWSMachineProviderConfig `json:"machine,omitempty"`
+}
+
+// WebhookDecision is the decision of the webhook about a resource.
+type WebhookDecision struct {
+	// Allowed is whether the resource may be created.
+	Allowed bool `json:"allowed"`
+	// Reason is why the resource may not be created.
+	Reason string `json:"reason,omitempty"`
+}
+
+type webhookRequest struct {
+	Input WebhookInput `json:"input"`
+}
+
+type webhookResponse struct {
+	// Result is nil if the webhook has no decision, e.g. the rule of an OPA URL is undefined.
+	Result *WebhookDecision `json:"result"`
+}
+
+// Check implements Checker. Resources the webhook has no decision for are denied, errors
+// calling it aren't violations and are returned as they are.
+func (w *Webhook) Check(r Resource) error {
+	body, err := json.Marshal(webhookRequest{Input: WebhookInput{
+		Kind:         r.Kind,
+		ClusterName:  r.ClusterName,
+		Tags:         r.Tags,
+		InstanceType: r.InstanceType,
+		Cluster:      r.Cluster,
+		Machine:      r.Machine,
+	}})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode policy webhook request")
+	}
+
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: webhookTimeout}
+	}
+	resp, err := client.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to call policy webhook")
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read policy webhook response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("policy webhook returned %s: %s", resp.Status, bytes.TrimSpace(raw))
+	}
+
+	out := &webhookResponse{}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return errors.Wrap(err, "failed to decode policy webhook response")
+	}
+	if out.Result == nil {
+		return NewViolation(errors.Errorf("policy webhook has no decision for %s", r.Kind))
+	}
+	if !out.Result.Allowed {
+		reason := out.Result.Reason
+		if reason == "" {
+			reason = "no reason given"
+		}
+		return NewViolation(errors.Errorf("%s denied by policy webhook: %s", r.Kind, reason))
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+)
+
+func TestWebhookCheck(t *testing.T) {
+	// The webhook only allows m5.large instances and has no decision without an instance type.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		in := &webhookRequest{}
+		if err := json.NewDecoder(req.Body).Decode(in); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+
+		switch in.Input.InstanceType {
+		case "":
+			w.Write([]byte(`{}`))
+		case "m5.large":
+			w.Write([]byte(`{"result": {"allowed": true}}`))
+		case "error":
+			http.Error(w, "rule failed", http.StatusInternalServerError)
+		default:
+			w.Write([]byte(`{"result": {"allowed": false, "reason": "only m5 instances"}}`))
+		}
+	}))
+	defer server.Close()
+
+	machine := &v1alpha1.AWSMachineProviderConfig{InstanceType: "m5.large"}
+	testCases := []struct {
+		name         string
+		instanceType string
+		violation    bool
+		expectedErr  bool
+	}{
+		{name: "allowed", instanceType: "m5.large"},
+		{name: "denied", instanceType: "p3.2xlarge", violation: true, expectedErr: true},
+		{name: "no decision", violation: true, expectedErr: true},
+		{name: "webhook error", instanceType: "error", expectedErr: true},
+	}
+
+	webhook := &Webhook{URL: server.URL}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := webhook.Check(Resource{Kind: KindInstance, ClusterName: "test", InstanceType: tc.instanceType, Machine: machine})
+			if tc.expectedErr != (err != nil) || tc.violation != IsViolation(err) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"fmt"
+)
+
+const (
+	// PresetDevSingleAZCheap is a network in a single availability zone, whose subnets share a
+	// route table per tier, with small machines.
+	PresetDevSingleAZCheap = "dev-single-az-cheap"
+
+	// PresetProdHAPrivate is a network spread over three availability zones, whose private
+	// subnets route through the NAT gateway of their own zone, with DNS hostnames and larger
+	// machines.
+	PresetProdHAPrivate = "prod-ha-private"
+)
+
+// clusterPreset holds the defaults a preset expands into.
+// +k8s:deepcopy-gen=false
+type clusterPreset struct {
+	network        NetworkSpec
+	instanceType   string
+	rootDeviceSize int64
+}
+
+var presets = map[string]clusterPreset{
+	PresetDevSingleAZCheap: {
+		network: NetworkSpec{
+			AvailabilityZoneCount: 1,
+			RouteTableStrategy:    RouteTableStrategyTier,
+		},
+		instanceType:   "t3.medium",
+		rootDeviceSize: 20,
+	},
+	PresetProdHAPrivate: {
+		network: NetworkSpec{
+			AvailabilityZoneCount: 3,
+			RouteTableStrategy:    RouteTableStrategyZone,
+			EnableDNSHostnames:    boolPtr(true),
+			EnableDNSSupport:      boolPtr(true),
+		},
+		instanceType:   "m5.large",
+		rootDeviceSize: 100,
+	},
+}
+
+// preset returns the preset of the config, if any.
+func (c *AWSClusterProviderConfig) preset() (*clusterPreset, error) {
+	if c.Preset == "" {
+		return nil, nil
+	}
+
+	p, ok := presets[c.Preset]
+	if !ok {
+		return nil, fmt.Errorf("unknown cluster preset %q", c.Preset)
+	}
+	return &p, nil
+}
+
+// ApplyPreset sets the network fields of the config that aren't set to the ones of its preset.
+func (c *AWSClusterProviderConfig) ApplyPreset() error {
+	p, err := c.preset()
+	if err != nil || p == nil {
+		return err
+	}
+
+	n := &c.Network
+	if n.AvailabilityZoneCount == 0 {
+		n.AvailabilityZoneCount = p.network.AvailabilityZoneCount
+	}
+	if n.RouteTableStrategy == "" {
+		n.RouteTableStrategy = p.network.RouteTableStrategy
+	}
+	if n.EnableDNSHostnames == nil && p.network.EnableDNSHostnames != nil {
+		n.EnableDNSHostnames = boolPtr(*p.network.EnableDNSHostnames)
+	}
+	if n.EnableDNSSupport == nil && p.network.EnableDNSSupport != nil {
+		n.EnableDNSSupport = boolPtr(*p.network.EnableDNSSupport)
+	}
+	return nil
+}
+
+// ApplyClusterPreset sets the fields of the config that aren't set to the machine defaults of
+// the preset of the cluster.
+func (c *AWSMachineProviderConfig) ApplyClusterPreset(cluster *AWSClusterProviderConfig) error {
+	p, err := cluster.preset()
+	if err != nil || p == nil {
+		return err
+	}
+
+	if c.InstanceType == "" {
+		c.InstanceType = p.instanceType
+	}
+	if c.RootDeviceSize == 0 {
+		c.RootDeviceSize = p.rootDeviceSize
+	}
+	return nil
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -0,0 +1,83 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"testing"
+)
+
+func TestApplyPreset(t *testing.T) {
+	testCases := []struct {
+		name          string
+		config        *AWSClusterProviderConfig
+		machine       *AWSMachineProviderConfig
+		expectedZones int
+		expectedRTs   RouteTableStrategy
+		expectedType  string
+		expectedSize  int64
+		expectedErr   bool
+	}{
+		{
+			name:    "no preset",
+			config:  &AWSClusterProviderConfig{},
+			machine: &AWSMachineProviderConfig{},
+		},
+		{
+			name:          "preset",
+			config:        &AWSClusterProviderConfig{Preset: PresetProdHAPrivate},
+			machine:       &AWSMachineProviderConfig{},
+			expectedZones: 3,
+			expectedRTs:   RouteTableStrategyZone,
+			expectedType:  "m5.large",
+			expectedSize:  100,
+		},
+		{
+			name: "fields override the preset",
+			config: &AWSClusterProviderConfig{
+				Preset:  PresetProdHAPrivate,
+				Network: NetworkSpec{AvailabilityZoneCount: 2, RouteTableStrategy: RouteTableStrategyTier},
+			},
+			machine:       &AWSMachineProviderConfig{InstanceType: "m5.2xlarge", RootDeviceSize: 200},
+			expectedZones: 2,
+			expectedRTs:   RouteTableStrategyTier,
+			expectedType:  "m5.2xlarge",
+			expectedSize:  200,
+		},
+		{
+			name:        "unknown preset",
+			config:      &AWSClusterProviderConfig{Preset: "prod"},
+			machine:     &AWSMachineProviderConfig{},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			machineErr := tc.machine.ApplyClusterPreset(tc.config)
+			if err := tc.config.ApplyPreset(); (err != nil) != tc.expectedErr || (machineErr != nil) != tc.expectedErr {
+				t.Fatalf("unexpected errors: %v, %v", err, machineErr)
+			}
+			if tc.expectedErr {
+				return
+			}
+
+			if n := tc.config.Network; n.AvailabilityZoneCount != tc.expectedZones || n.RouteTableStrategy != tc.expectedRTs {
+				t.Fatalf("unexpected network: %+v", n)
+			}
+			if tc.machine.InstanceType != tc.expectedType || tc.machine.RootDeviceSize != tc.expectedSize {
+				t.Fatalf("unexpected machine: %+v", tc.machine)
+			}
+		})
+	}
+}
@@ -20,6 +20,15 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// PivotedAnnotation is set on the clusters and machines that were moved to another management
+// cluster. Their AWS resources aren't deleted when they are deleted from this one.
+const PivotedAnnotation = GroupName + "/pivoted"
+
+// ApproveChangesAnnotation approves the pending changes of a machine, whose digest is its value,
+// when the machine controller requires the approval of changes before it replaces an instance.
+// The digest is in the message of the PendingChanges condition of the machine.
+const ApproveChangesAnnotation = GroupName + "/approve-changes"
+
 // AWSMachineProviderConfig is the type that will be embedded in a Machine.Spec.ProviderConfig field
 // for an AWS instance. It is used by the AWS machine actuator to create a single machine instance,
 // using the RunInstances call (https://docs.aws.amazon.com/AWSEC2/latest/APIReference/API_RunInstances.html)
@@ -35,10 +44,34 @@ type AWSMachineProviderConfig struct {
 	// InstanceType is the type of instance to create. Example: m4.xlarge
 	InstanceType string `json:"instanceType"`
 
-	// AdditionalTags is the set of tags to add to an instance, in addition to the ones
-	// added by default by the actuator. These tags are additive. The actuator will ensure
-	// these tags are present, but will not remove any other tags that may exist on the
-	// instance.
+	// RootDeviceSize is the size of the root volume of the instance in GiB.
+	// If zero, the root volume has the size of the AMI's. Increasing it grows the root volume of
+	// the running instance, its file system is grown by the instance, e.g. by cloud-init on the
+	// next boot. Root volumes are never shrunk.
+	// +optional
+	RootDeviceSize int64 `json:"rootDeviceSize,omitempty"`
+
+	// RootVolumeType is the EBS volume type of the root volume of new instances, one of standard,
+	// gp2, gp3 or io1. Defaults to gp3, whose throughput is the baseline of 125 MiB/s.
+	// +optional
+	RootVolumeType string `json:"rootVolumeType,omitempty"`
+
+	// RootVolumeIOPS is the provisioned IOPS of the root volume of new instances. It's required for
+	// io1 volumes and optional for gp3 volumes, which get 3000 IOPS if zero. Other types don't take it.
+	// +optional
+	RootVolumeIOPS int64 `json:"rootVolumeIOPS,omitempty"`
+
+	// RootVolumeEncrypted encrypts the root volume of new instances with the default EBS key of
+	// the account. It requires the AMI to be referenced by id.
+	// +optional
+	RootVolumeEncrypted bool `json:"rootVolumeEncrypted,omitempty"`
+
+	// AdditionalTags is the set of tags to add to an instance and its volumes, in addition to
+	// the ones added by default by the actuator and the additional tags of the cluster, which
+	// they override. These tags are additive. The actuator will ensure these tags are present,
+	// and removes tags it added before once they are removed from this set, but will not remove
+	// any other tags that may exist on the instance.
+	// Values are text templates, see AWSClusterProviderConfig.AdditionalTags.
 	// +optional
 	AdditionalTags map[string]string `json:"additionalTags,omitempty"`
 
@@ -62,8 +95,52 @@ type AWSMachineProviderConfig struct {
 
 	// Subnet is a reference to the subnet to use for this instance. If not specified,
 	// the cluster subnet will be used.
+	// Filters may match subnets in several availability zones. The instance is launched in
+	// the next zone when a zone lacks the capacity for it or doesn't support its instance type.
 	// +optional
 	Subnet *AWSResourceReference `json:"subnet,omitempty"`
+
+	// InstanceInitiatedShutdownBehavior is what happens to new instances when their operating
+	// system shuts down, one of stop or terminate. Defaults to stop.
+	// +optional
+	InstanceInitiatedShutdownBehavior string `json:"instanceInitiatedShutdownBehavior,omitempty"`
+
+	// WarmPoolSize is the number of stopped instances kept for the machine set of the machine.
+	// New machines of the machine set start one of them instead of running a new instance.
+	// Instances of the pool are replaced when the launch template changes.
+	// Only used for machines of a machine set.
+	// +optional
+	WarmPoolSize int32 `json:"warmPoolSize,omitempty"`
+
+	// InstanceID is the id of an existing instance to adopt instead of creating a new one.
+	// The instance is tagged as owned by the cluster and terminated when the machine is deleted.
+	// +optional
+	InstanceID *string `json:"instanceID,omitempty"`
+
+	// NodeJoinTimeout is how long the instance may run before the machine has to have a node.
+	// Diagnostics are collected from instances of machines that don't have one in time.
+	// +optional
+	NodeJoinTimeout *metav1.Duration `json:"nodeJoinTimeout,omitempty"`
+
+	// NodeJoinRetries is how often the instance of a machine that doesn't have a node
+	// within the node join timeout is replaced, before the machine is marked as failed.
+	// Instances aren't replaced if it's zero, nor are adopted instances.
+	// +optional
+	NodeJoinRetries int `json:"nodeJoinRetries,omitempty"`
+
+	// ImpairedInstanceTimeout is how long the status checks of an instance may fail before
+	// the instance is replaced. Instances aren't replaced if it's not set, which leaves
+	// remediation to a machine health check, nor are adopted instances.
+	// +optional
+	ImpairedInstanceTimeout *metav1.Duration `json:"impairedInstanceTimeout,omitempty"`
+
+	// GracefulShutdownTimeout is how long the operating system of the instance may take to shut
+	// down when the machine is deleted, before the instance is terminated. The shutdown is sent
+	// through SSM Run Command, which needs the SSM agent on the instance and an instance profile
+	// allowing it. Instances are terminated right away if it's not set, if the controller doesn't
+	// shut down instances, or if the instance isn't managed by SSM.
+	// +optional
+	GracefulShutdownTimeout *metav1.Duration `json:"gracefulShutdownTimeout,omitempty"`
 }
 
 // AWSResourceReference is a reference to a specific AWS resource by ID, ARN, or filters.
@@ -82,7 +159,7 @@ type AWSResourceReference struct {
 	// They are applied according to the rules defined by the AWS API:
 	// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/Using_Filtering.html
 	// +optional
-	Filters []Filter `json:"filters"`
+	Filters []Filter `json:"filters,omitempty"`
 }
 
 // Filter is a filter used to identify an AWS resource
@@ -97,8 +174,247 @@ type Filter struct {
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 type AWSClusterProviderConfig struct {
 	metav1.TypeMeta `json:",inline"`
+
+	// AdditionalTags is the set of tags to add to every resource created for the cluster,
+	// including the instances of its machines, in addition to the ones added by default by
+	// the actuator. These tags are additive. The actuator will ensure these tags are present,
+	// and removes tags it added before once they are removed from this set, but will not remove
+	// any other tags that may exist on the resources.
+	// Values are text templates that can refer to {{ .ClusterName }} and, on instances and
+	// volumes, {{ .MachineName }}.
+	// +optional
+	AdditionalTags map[string]string `json:"additionalTags,omitempty"`
+
+	// Preset is the name of a well-known cluster topology, one of "dev-single-az-cheap" and
+	// "prod-ha-private", that sets the network fields of the cluster and the machine fields of
+	// its machines that aren't set.
+	// +optional
+	Preset string `json:"preset,omitempty"`
+
+	// Network is the configuration of the cluster network.
+	// +optional
+	Network NetworkSpec `json:"network,omitempty"`
+
+	// Hibernate stops the instances of the machines of the cluster, keeping their volumes,
+	// and starts them again once it's unset. While they're stopped, they're deregistered from
+	// the load balancers owned by the cluster. The network is kept as it is.
+	// +optional
+	Hibernate bool `json:"hibernate,omitempty"`
+
+	// PauseWindows are recurring windows, e.g. outside of office hours, in which the network isn't
+	// reconciled and the nodes of the cluster are hibernated, see Hibernate. The control plane keeps
+	// running, so that the cluster stays reachable. The cluster is resumed once the windows end.
+	// +optional
+	PauseWindows []PauseWindow `json:"pauseWindows,omitempty"`
+
+	// FileSystem is an EFS file system shared by the instances of the cluster, with a mount
+	// target in each zone of its private subnets. Once created, the file system is kept until
+	// the cluster is deleted, even if this is unset, so its data isn't lost.
+	// +optional
+	FileSystem *FileSystemSpec `json:"fileSystem,omitempty"`
+
+	// PrivateHostedZone is a Route 53 private hosted zone attached to the vpc of the cluster, for
+	// the internal service discovery of the cluster. Its id is recorded in the status. Once
+	// created, the zone is kept until the cluster is deleted, even if this is unset. The records
+	// in the zone are deleted with it.
+	// +optional
+	PrivateHostedZone *PrivateHostedZoneSpec `json:"privateHostedZone,omitempty"`
+
+	// NodeRoles creates an IAM role and instance profile for the control plane machines of the
+	// cluster, with the permissions of the Kubernetes AWS cloud provider, and one for the other
+	// machines, which only look up instances and pull images from ECR. Machines that don't set
+	// an IAM instance profile get the one of their kind. Both are deleted with the cluster.
+	// +optional
+	NodeRoles bool `json:"nodeRoles,omitempty"`
+
+	// ECRRepositories are the ARNs of the ECR repositories the node roles may pull images from,
+	// like arn:aws:ecr:eu-west-1:123456789012:repository/app or wildcards of them. If empty,
+	// they may pull from every repository. Only used with NodeRoles.
+	// +optional
+	ECRRepositories []string `json:"ecrRepositories,omitempty"`
+
+	// DeepClean deletes the resources created from within the cluster, like by the Kubernetes AWS
+	// cloud provider or the EBS CSI driver, when the cluster is deleted: the volumes tagged for
+	// the cluster, including the ones of retained persistent volumes, and the detached network
+	// interfaces tagged for it in its vpc. Their data is lost.
+	// +optional
+	DeepClean bool `json:"deepClean,omitempty"`
+
+	// DeletionProtection keeps the resources of the cluster from being deleted when the cluster
+	// is, until it is unset again. The cluster stays in deletion until then.
+	// +optional
+	DeletionProtection bool `json:"deletionProtection,omitempty"`
+
+	// FIPS encrypts the root volumes of all machines of the cluster, which requires their AMIs,
+	// like FIPS validated ones, to be referenced by id. The AWS clients of the controllers are
+	// shared by all clusters, they use FIPS endpoints with --fips-endpoints.
+	// +optional
+	FIPS bool `json:"fips,omitempty"`
+}
+
+// FileSystemSpec is the configuration of the EFS file system of a cluster.
+// Its fields can't be changed once the file system is created.
+type FileSystemSpec struct {
+	// PerformanceMode is the EFS performance mode, "generalPurpose" or "maxIO".
+	// Defaults to "generalPurpose".
+	// +optional
+	PerformanceMode string `json:"performanceMode,omitempty"`
+
+	// Encrypted encrypts the file system at rest with the default EFS key.
+	// +optional
+	Encrypted bool `json:"encrypted,omitempty"`
 }
 
+// PrivateHostedZoneSpec is the configuration of the private hosted zone of a cluster.
+// Its fields can't be changed once the zone is created.
+type PrivateHostedZoneSpec struct {
+	// Name is the domain name of the zone. Defaults to the name of the cluster followed by .internal.
+	// +optional
+	Name string `json:"name,omitempty"`
+}
+
+// PauseWindow is a recurring window in which a cluster is paused.
+type PauseWindow struct {
+	// Start is the cron expression of the times the window starts, made of the fields minute,
+	// hour, day of month, month and day of week, e.g. "0 20 * * 1-5". Schedules must fire at
+	// least once a month.
+	Start string `json:"start"`
+
+	// End is the cron expression of the times the window ends, e.g. "0 7 * * 1-5".
+	End string `json:"end"`
+
+	// TimeZone is the IANA time zone, e.g. Europe/Berlin, of the schedules. Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// NetworkSpec encapsulates the configuration of the cluster network.
+type NetworkSpec struct {
+	// VPCID is the id of an existing VPC to create the cluster in, e.g. the VPC of another cluster.
+	// The VPC and the network resources found in it are shared with the clusters already using
+	// them and are only deleted together with the last cluster that uses them.
+	// If not set, a new VPC is created and owned by the cluster.
+	// +optional
+	VPCID string `json:"vpcID,omitempty"`
+
+	// SubnetIDs are the ids of the existing subnets in the VPC to adopt.
+	// Only used together with Adopt.
+	// +optional
+	SubnetIDs []string `json:"subnetIDs,omitempty"`
+
+	// Adopt brings the existing network given by VPCID and SubnetIDs under the management of
+	// the cluster, e.g. to migrate a cluster that was built by hand. The network is validated
+	// to be complete, so that nothing needs to be created: there must be at least one public and
+	// one private subnet, an internet gateway attached to the VPC, a NAT gateway in every
+	// public subnet and a route table associated with every subnet.
+	// The adopted resources are then tagged as owned by the cluster and deleted together with it.
+	// Resources already used by other clusters can't be adopted.
+	// +optional
+	Adopt bool `json:"adopt,omitempty"`
+
+	// InternetGatewayID is the id of an existing internet gateway attached to the VPC given by
+	// VPCID to use for the public subnets. The gateway is shared with the cluster and never
+	// detached or deleted by the provider, even if the network is adopted.
+	// +optional
+	InternetGatewayID string `json:"internetGatewayID,omitempty"`
+
+	// NatGatewayAllocationIDs are the allocation ids of existing Elastic IP addresses to use for
+	// new NAT gateways, e.g. to keep the egress IPs of the cluster stable. Addresses that are
+	// already associated are skipped, and new addresses are allocated once all of them are in use.
+	// The given addresses are never released by the provider.
+	// +optional
+	NatGatewayAllocationIDs []string `json:"natGatewayAllocationIDs,omitempty"`
+
+	// EnableIPv6 requests an Amazon provided IPv6 CIDR block for a new VPC.
+	// Private subnets of a VPC with an IPv6 CIDR block reach the internet over IPv6
+	// through an egress-only internet gateway.
+	// +optional
+	EnableIPv6 bool `json:"enableIPv6,omitempty"`
+
+	// SecondaryCIDRBlocks are additional IPv4 CIDR blocks of a VPC owned by the cluster, e.g. to
+	// make room for more subnets. Blocks added to the spec of a running cluster are associated
+	// with its VPC, blocks removed from the spec stay associated.
+	// +optional
+	SecondaryCIDRBlocks []string `json:"secondaryCIDRBlocks,omitempty"`
+
+	// EnableDNSHostnames sets whether instances in a VPC owned by the cluster get DNS hostnames.
+	// If not set, the attribute of the VPC isn't changed.
+	// +optional
+	EnableDNSHostnames *bool `json:"enableDNSHostnames,omitempty"`
+
+	// EnableDNSSupport sets whether the Amazon DNS server resolves names in a VPC owned by the
+	// cluster. If not set, the attribute of the VPC isn't changed.
+	// +optional
+	EnableDNSSupport *bool `json:"enableDNSSupport,omitempty"`
+
+	// AvailabilityZoneCount is the number of availability zones the default subnets are spread
+	// over, with a private and a public subnet in each, if the network status has no subnets.
+	// Defaults to one.
+	// +optional
+	AvailabilityZoneCount int `json:"availabilityZoneCount,omitempty"`
+
+	// PrivateSubnetPrefixLength and PublicSubnetPrefixLength are the prefix lengths of the default
+	// private and public subnets, between 16 and 28, e.g. 20 for private and 24 for public
+	// subnets to leave more room for pods than for load balancers. If only one is set, the other
+	// defaults to 24. If neither is set, the default subnets are the first /24 blocks of the VPC,
+	// or smaller ones if it has no room for them.
+	// +optional
+	PrivateSubnetPrefixLength int `json:"privateSubnetPrefixLength,omitempty"`
+	// +optional
+	PublicSubnetPrefixLength int `json:"publicSubnetPrefixLength,omitempty"`
+
+	// RemovedAvailabilityZones are the zones to take a running cluster out of. Once no instances
+	// are left in the subnets of a zone, the subnets are deleted together with their NAT gateways,
+	// route tables and the addresses of the gateways. Private subnets of the remaining zones that
+	// routed through a NAT gateway of a removed zone are routed through one of their own zone.
+	// New zones are added by adding their subnets to the network status.
+	// +optional
+	RemovedAvailabilityZones []string `json:"removedAvailabilityZones,omitempty"`
+
+	// RouteTableStrategy defines how subnets share route tables, e.g. to stay below the limit
+	// of route tables per VPC in large clusters. Defaults to a route table per subnet.
+	// The strategy applies to subnets that don't have a route table yet, existing associations
+	// are kept.
+	// +optional
+	RouteTableStrategy RouteTableStrategy `json:"routeTableStrategy,omitempty"`
+
+	// ReservedCIDRs are CIDR blocks that the VPC and subnets must not overlap with,
+	// e.g. the ranges of a data center or of peered VPCs.
+	// +optional
+	ReservedCIDRs []string `json:"reservedCIDRs,omitempty"`
+
+	// Isolated creates a network without a path to the internet, for environments that must
+	// prove that clusters can't reach it. No internet, egress-only internet or NAT gateways are
+	// created, subnets must be private, and reconciling fails if the VPC is attached to an
+	// internet gateway, has NAT gateways, or a subnet routes to any of them.
+	// +optional
+	Isolated bool `json:"isolated,omitempty"`
+
+	// RequiredVPCEndpoints are the service names of the VPC endpoints that must exist in the VPC
+	// of an isolated network, e.g. "com.amazonaws.us-east-1.s3" for an artifact mirror in S3.
+	// The endpoints aren't created by the provider, so this is usually combined with an existing
+	// VPC given by VPCID.
+	// +optional
+	RequiredVPCEndpoints []string `json:"requiredVPCEndpoints,omitempty"`
+}
+
+// RouteTableStrategy is a valid value for NetworkSpec.RouteTableStrategy.
+type RouteTableStrategy string
+
+// Valid route table strategies.
+const (
+	// RouteTableStrategySubnet creates a route table for every subnet.
+	RouteTableStrategySubnet RouteTableStrategy = "subnet"
+
+	// RouteTableStrategyZone shares a route table between the subnets of a tier, public or
+	// private, in the same availability zone.
+	RouteTableStrategyZone RouteTableStrategy = "zone"
+
+	// RouteTableStrategyTier shares a route table between all subnets of a tier. Private subnets
+	// in all availability zones route through the NAT gateway of a single zone.
+	RouteTableStrategyTier RouteTableStrategy = "tier"
+)
+
 // AWSMachineProviderStatus is the type that will be embedded in a Machine.Status.ProviderStatus field.
 // It containsk AWS-specific status information.
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -113,10 +429,79 @@ type AWSMachineProviderStatus struct {
 	// +optional
 	InstanceState *string `json:"instanceState,omitempty"`
 
+	// LaunchTemplateID is the id of the launch template the instance was run from.
+	// Machines of the same machine set share a launch template.
+	// +optional
+	LaunchTemplateID *string `json:"launchTemplateID,omitempty"`
+
+	// LaunchTemplateVersion is the version of the launch template the instance was run from.
+	// A new version is created when the machine provider config changes.
+	// +optional
+	LaunchTemplateVersion *int64 `json:"launchTemplateVersion,omitempty"`
+
+	// SubnetID is the subnet the instance was launched in, if the machine references subnets.
+	// +optional
+	SubnetID *string `json:"subnetID,omitempty"`
+
+	// AvailabilityZone is the availability zone the instance was launched in, if the machine
+	// references subnets.
+	// +optional
+	AvailabilityZone *string `json:"availabilityZone,omitempty"`
+
 	// Conditions is a set of conditions associated with the Machine to indicate
 	// errors or other status
 	// +optional
 	Conditions []AWSMachineProviderCondition `json:"conditions,omitempty"`
+
+	// Diagnostics were collected from the instance because the machine didn't
+	// get a node within the node join timeout.
+	// +optional
+	Diagnostics *MachineDiagnostics `json:"diagnostics,omitempty"`
+
+	// NodeJoinRetries is how often the instance of the machine was replaced because
+	// the machine didn't have a node within the node join timeout.
+	// +optional
+	NodeJoinRetries int `json:"nodeJoinRetries,omitempty"`
+
+	// LaunchSpec is the part of the machine provider config the instance was launched with that
+	// only takes effect on a new instance. Changes of the machine are reported against it.
+	// Not set for adopted instances.
+	// +optional
+	LaunchSpec *MachineLaunchSpec `json:"launchSpec,omitempty"`
+
+	// ShutdownRequestTime is when the operating system of the instance was asked to shut down
+	// because the machine is being deleted. The instance is terminated once it stopped or the
+	// graceful shutdown timeout passed.
+	// +optional
+	ShutdownRequestTime *metav1.Time `json:"shutdownRequestTime,omitempty"`
+}
+
+// MachineLaunchSpec are the fields of a machine provider config that an instance is launched
+// with and that aren't changed on a running instance, see AWSMachineProviderConfig.
+type MachineLaunchSpec struct {
+	AMI                               AWSResourceReference   `json:"ami"`
+	InstanceType                      string                 `json:"instanceType"`
+	RootVolumeType                    string                 `json:"rootVolumeType,omitempty"`
+	RootVolumeIOPS                    int64                  `json:"rootVolumeIOPS,omitempty"`
+	RootVolumeEncrypted               bool                   `json:"rootVolumeEncrypted,omitempty"`
+	IAMInstanceProfile                *AWSResourceReference  `json:"iamInstanceProfile,omitempty"`
+	PublicIP                          *bool                  `json:"publicIP,omitempty"`
+	AdditionalSecurityGroups          []AWSResourceReference `json:"additionalSecurityGroups,omitempty"`
+	Subnet                            *AWSResourceReference  `json:"subnet,omitempty"`
+	InstanceInitiatedShutdownBehavior string                 `json:"instanceInitiatedShutdownBehavior,omitempty"`
+}
+
+// MachineDiagnostics are collected from an instance to debug why it didn't join the cluster.
+type MachineDiagnostics struct {
+	// InstanceID is the id of the instance the diagnostics were collected from.
+	InstanceID string `json:"instanceID"`
+
+	// CollectionTime is when the diagnostics were collected.
+	CollectionTime metav1.Time `json:"collectionTime"`
+
+	// ConsoleOutput is the end of the console output of the instance.
+	// +optional
+	ConsoleOutput string `json:"consoleOutput,omitempty"`
 }
 
 // AWSMachineProviderConditionType is a valid value for AWSMachineProviderCondition.Type
@@ -127,6 +512,25 @@ const (
 	// MachineCreated indicates whether the machine has been created or not. If not,
 	// it should include a reason and message for the failure.
 	MachineCreated AWSMachineProviderConditionType = "MachineCreated"
+
+	// InstanceHealthy indicates whether the status checks of the instance pass. It's false
+	// with the reason SystemStatusImpaired or InstanceStatusImpaired if they fail, and
+	// unknown while they are initializing.
+	InstanceHealthy AWSMachineProviderConditionType = "InstanceHealthy"
+
+	// ManagerConflict indicates that the instance is tagged for another management cluster than
+	// the one of the machine controller. The instance is left alone while the condition is true.
+	ManagerConflict AWSMachineProviderConditionType = "ManagerConflict"
+
+	// PolicyViolation indicates that the machine violates the policy of the machine controller,
+	// e.g. misses a required tag. No instance is launched for the machine while the condition is true.
+	PolicyViolation AWSMachineProviderConditionType = "PolicyViolation"
+
+	// PendingChanges indicates that the machine provider config changed since the instance was
+	// launched in fields that only take effect on a new instance, like the instance type. The
+	// message lists the changes, which apply once the instance is replaced. The reason is
+	// ApprovalRequired while the changes keep the instance from being replaced.
+	PendingChanges AWSMachineProviderConditionType = "PendingChanges"
 )
 
 // AWSMachineProviderCondition is a condition in a AWSMachineProviderStatus
@@ -154,6 +558,141 @@ type AWSClusterProviderStatus struct {
 	metav1.TypeMeta `json:",inline"`
 
 	Network Network `json:"network"`
+
+	// Hibernated is true if the instances of the cluster were stopped for hibernation
+	// and have not been started again yet.
+	// +optional
+	Hibernated bool `json:"hibernated,omitempty"`
+
+	// HibernatedRegistrations are the registrations of the hibernated instances with the load
+	// balancers of the cluster, which are removed while the instances are stopped and restored
+	// once they're running again.
+	// +optional
+	HibernatedRegistrations []LoadBalancerRegistration `json:"hibernatedRegistrations,omitempty"`
+
+	// Cost is the estimated cost of the AWS resources of the cluster.
+	// +optional
+	Cost *CostEstimate `json:"cost,omitempty"`
+
+	// FileSystem is the EFS file system of the cluster, if one was created.
+	// +optional
+	FileSystem *FileSystem `json:"fileSystem,omitempty"`
+
+	// PrivateHostedZone is the Route 53 private hosted zone of the cluster, if one was created.
+	// +optional
+	PrivateHostedZone *HostedZone `json:"privateHostedZone,omitempty"`
+
+	// PolicyViolation is why the cluster violates the policy of the cluster controller.
+	// Nothing is created for the cluster while it's set.
+	// +optional
+	PolicyViolation string `json:"policyViolation,omitempty"`
+
+	// ProvisioningSteps are the steps of provisioning the cluster, like its network and file
+	// system, and how long they took. The steps of the network are in the network status.
+	// +optional
+	ProvisioningSteps []ProvisioningStep `json:"provisioningSteps,omitempty"`
+
+	// Conditions is a set of conditions associated with the Cluster to indicate
+	// why it isn't ready yet
+	// +optional
+	Conditions []AWSClusterProviderCondition `json:"conditions,omitempty"`
+}
+
+// AWSClusterProviderConditionType is a valid value for AWSClusterProviderCondition.Type
+type AWSClusterProviderConditionType string
+
+// Valid conditions for an AWS cluster
+const (
+	// NatGatewaysAvailable indicates whether the NAT gateways of the cluster are available.
+	// It's false with the reason NatGatewaysPending while they are being created, which takes
+	// minutes, and its message lists their ids, states and when they're expected to be available.
+	NatGatewaysAvailable AWSClusterProviderConditionType = "NatGatewaysAvailable"
+)
+
+// AWSClusterProviderCondition is a condition in a AWSClusterProviderStatus
+type AWSClusterProviderCondition struct {
+	// Type is the type of the condition.
+	Type AWSClusterProviderConditionType `json:"type"`
+	// Status is the status of the condition.
+	Status corev1.ConditionStatus `json:"status"`
+	// LastProbeTime is the last time we probed the condition.
+	// +optional
+	LastProbeTime metav1.Time `json:"lastProbeTime"`
+	// LastTransitionTime is the last time the condition transitioned from one status to another.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+	// Reason is a unique, one-word, CamelCase reason for the condition's last transition.
+	// +optional
+	Reason string `json:"reason"`
+	// Message is a human-readable message indicating details about last transition.
+	// +optional
+	Message string `json:"message"`
+}
+
+// ProvisioningStep is a step of provisioning a cluster, which may take several reconciles, e.g.
+// while NAT gateways are pending. Its times are only set once, so that operators can see where
+// the provisioning time of a cluster went.
+type ProvisioningStep struct {
+	// Name is the name of the step, e.g. natGateways.
+	Name string `json:"name"`
+
+	// StartTime is when the step was first reconciled.
+	StartTime metav1.Time `json:"startTime"`
+
+	// CompletionTime is when the step first completed. It isn't set while the step is in progress.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Reconciles is the number of reconciles of the step until it completed.
+	Reconciles int `json:"reconciles"`
+}
+
+// HostedZone is a Route 53 hosted zone of a cluster.
+type HostedZone struct {
+	// ID is the id of the zone, e.g. Z1D633PJN98FT9.
+	ID string `json:"id"`
+
+	// Name is the domain name of the zone, e.g. test.internal.
+	Name string `json:"name"`
+}
+
+// LoadBalancerRegistration is the registration of an instance with a classic load balancer or
+// with a target group of an application or network load balancer.
+type LoadBalancerRegistration struct {
+	// InstanceID is the id of the registered instance.
+	InstanceID string `json:"instanceID"`
+
+	// LoadBalancerName is the name of the classic load balancer, if it's registered with one.
+	// +optional
+	LoadBalancerName string `json:"loadBalancerName,omitempty"`
+
+	// TargetGroupARN is the arn of the target group, if it's registered with one.
+	// +optional
+	TargetGroupARN string `json:"targetGroupARN,omitempty"`
+
+	// Port is the port the instance is registered on in the target group.
+	// +optional
+	Port int64 `json:"port,omitempty"`
+}
+
+// FileSystem is the EFS file system of a cluster.
+type FileSystem struct {
+	// ID is the id of the file system, which volumes of the EFS CSI driver refer to.
+	ID string `json:"id"`
+
+	// SecurityGroupID is the id of the security group of the mount targets, which allows NFS
+	// traffic from the subnets of the cluster.
+	SecurityGroupID string `json:"securityGroupID,omitempty"`
+}
+
+// CostEstimate is an approximate on-demand cost of AWS resources in US dollars, based on the
+// public prices of the region. Data transfer and requests aren't included.
+type CostEstimate struct {
+	// Hourly is the estimated cost per hour.
+	Hourly string `json:"hourly"`
+
+	// Monthly is the estimated cost per month of 730 hours.
+	Monthly string `json:"monthly"`
 }
 
 // Network encapsulates AWS networking resources.
@@ -165,8 +704,18 @@ type Network struct {
 	// InternetGatewayID is the id of the internet gateway associated with the VPC.
 	InternetGatewayID *string `json:"internetGatewayId"`
 
+	// EgressOnlyInternetGatewayID is the id of the egress-only internet gateway attached to the VPC,
+	// if the VPC has an IPv6 CIDR block.
+	// +optional
+	EgressOnlyInternetGatewayID *string `json:"egressOnlyInternetGatewayId,omitempty"`
+
 	// Subnets includes all the subnets defined inside the VPC.
 	Subnets Subnets `json:"subnets"`
+
+	// ProvisioningSteps are the steps of provisioning the network, like its VPC, subnets and NAT
+	// gateways, and how long they took.
+	// +optional
+	ProvisioningSteps []ProvisioningStep `json:"provisioningSteps,omitempty"`
 }
 
 // VPC defines an AWS vpc.
@@ -174,6 +723,22 @@ type VPC struct {
 	ID string `json:"id"`
 
 	CidrBlock string `json:"cidrBlock"`
+
+	// IPv6CidrBlock is the IPv6 CIDR block associated with the VPC, if any.
+	// +optional
+	IPv6CidrBlock string `json:"ipv6CidrBlock,omitempty"`
+
+	// SecondaryCidrBlocks are the additional IPv4 CIDR blocks associated with the VPC.
+	// +optional
+	SecondaryCidrBlocks []string `json:"secondaryCidrBlocks,omitempty"`
+
+	// State is the state of the VPC as reported by AWS, e.g. pending or available.
+	// +optional
+	State string `json:"state,omitempty"`
+
+	// Tags is the set of tags of the VPC.
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
 }
 
 // String returns a string representation of the VPC.
@@ -185,12 +750,40 @@ func (v *VPC) String() string {
 type Subnet struct {
 	ID string `json:"id"`
 
-	VpcID            string  `json:"vpcId"`
-	AvailabilityZone string  `json:"availabilityZone"`
-	CidrBlock        string  `json:"cidrBlock"`
-	IsPublic         bool    `json:"public"`
-	RouteTableID     *string `json:"routeTableId"`
-	NatGatewayID     *string `json:"natGatewayId"`
+	VpcID string `json:"vpcId"`
+
+	// AvailabilityZone is the availability zone of the subnet, or a Local Zone, e.g. us-west-2-lax-1a.
+	// Local Zones have no NAT gateways, their private subnets route through a NAT gateway of the region.
+	AvailabilityZone string `json:"availabilityZone"`
+
+	CidrBlock    string  `json:"cidrBlock"`
+	IsPublic     bool    `json:"public"`
+	RouteTableID *string `json:"routeTableId"`
+	NatGatewayID *string `json:"natGatewayId"`
+
+	// SharedRouteTableID is the id of an existing route table in the VPC to associate with the
+	// subnet instead of one created by the provider, e.g. because route tables are managed
+	// centrally. The provider never changes the routes or tags of the table and never deletes it.
+	// +optional
+	SharedRouteTableID *string `json:"sharedRouteTableId,omitempty"`
+
+	// NatGatewayState is the state of the NAT gateway in a public subnet as reported by AWS,
+	// e.g. pending or available.
+	// +optional
+	NatGatewayState *string `json:"natGatewayState,omitempty"`
+
+	// NatGatewayCreateTime is when the NAT gateway in a public subnet was created.
+	// +optional
+	NatGatewayCreateTime *metav1.Time `json:"natGatewayCreateTime,omitempty"`
+
+	// NatGatewayAllocationID is the allocation id of the Elastic IP address of the NAT gateway
+	// in a public subnet.
+	// +optional
+	NatGatewayAllocationID *string `json:"natGatewayAllocationId,omitempty"`
+
+	// Tags is the set of tags of the subnet.
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
 }
 
 // String returns a string representation of the subnet.
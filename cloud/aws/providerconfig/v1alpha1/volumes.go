@@ -0,0 +1,89 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"fmt"
+)
+
+const (
+	// VolumeTypeStandard is a magnetic volume.
+	VolumeTypeStandard = "standard"
+	// VolumeTypeGP2 is a general purpose SSD volume whose IOPS grow with its size.
+	VolumeTypeGP2 = "gp2"
+	// VolumeTypeGP3 is a general purpose SSD volume with IOPS independent of its size.
+	VolumeTypeGP3 = "gp3"
+	// VolumeTypeIO1 is a provisioned IOPS SSD volume.
+	VolumeTypeIO1 = "io1"
+
+	// DefaultRootVolumeType is the type of root volumes of machines that don't set one.
+	DefaultRootVolumeType = VolumeTypeGP3
+)
+
+// volumeIOPSLimits are the bounds of the provisioned IOPS of volume types that take them.
+// +k8s:deepcopy-gen=false
+type volumeIOPSLimits struct {
+	min, max int64
+	// perGiB is the maximum ratio of IOPS to the size of the volume.
+	perGiB int64
+	// required is whether the IOPS must be set.
+	required bool
+}
+
+var iopsLimits = map[string]volumeIOPSLimits{
+	VolumeTypeStandard: {},
+	VolumeTypeGP2:      {},
+	VolumeTypeGP3:      {min: 3000, max: 16000, perGiB: 500},
+	VolumeTypeIO1:      {min: 100, max: 64000, perGiB: 50, required: true},
+}
+
+// RootVolumeTypeOrDefault returns the root volume type of the config, or the default one.
+func (c *AWSMachineProviderConfig) RootVolumeTypeOrDefault() string {
+	if c.RootVolumeType == "" {
+		return DefaultRootVolumeType
+	}
+	return c.RootVolumeType
+}
+
+// ValidateRootVolume returns an error if EC2 would reject the root volume of the config, so that
+// it isn't found out only once an instance is launched. The ratio of IOPS to size is only
+// checked when the size is set, the size of the AMI's root volume isn't known here.
+func (c *AWSMachineProviderConfig) ValidateRootVolume() error {
+	volumeType := c.RootVolumeTypeOrDefault()
+	limits, ok := iopsLimits[volumeType]
+	if !ok {
+		return fmt.Errorf("root volume type %q is not one of standard, gp2, gp3 or io1", volumeType)
+	}
+
+	if limits.max == 0 {
+		if c.RootVolumeIOPS != 0 {
+			return fmt.Errorf("root volumes of type %q don't take iops", volumeType)
+		}
+		return nil
+	}
+
+	if c.RootVolumeIOPS == 0 {
+		if limits.required {
+			return fmt.Errorf("root volumes of type %q require iops", volumeType)
+		}
+		return nil
+	}
+	if c.RootVolumeIOPS < limits.min || c.RootVolumeIOPS > limits.max {
+		return fmt.Errorf("root volume iops %d of type %q are not between %d and %d", c.RootVolumeIOPS, volumeType, limits.min, limits.max)
+	}
+	if c.RootDeviceSize != 0 && c.RootVolumeIOPS > limits.perGiB*c.RootDeviceSize {
+		return fmt.Errorf("root volume iops %d of type %q exceed %d per GiB of its %d GiB", c.RootVolumeIOPS, volumeType, limits.perGiB, c.RootDeviceSize)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"testing"
+)
+
+func TestValidateRootVolume(t *testing.T) {
+	testCases := []struct {
+		name        string
+		config      *AWSMachineProviderConfig
+		expectedErr bool
+	}{
+		{
+			name:   "default",
+			config: &AWSMachineProviderConfig{},
+		},
+		{
+			name:   "gp3 with iops",
+			config: &AWSMachineProviderConfig{RootDeviceSize: 20, RootVolumeIOPS: 6000},
+		},
+		{
+			name:        "gp3 with too few iops",
+			config:      &AWSMachineProviderConfig{RootVolumeIOPS: 1000},
+			expectedErr: true,
+		},
+		{
+			name:        "gp3 with too many iops for its size",
+			config:      &AWSMachineProviderConfig{RootDeviceSize: 8, RootVolumeIOPS: 6000},
+			expectedErr: true,
+		},
+		{
+			name:   "io1",
+			config: &AWSMachineProviderConfig{RootVolumeType: VolumeTypeIO1, RootDeviceSize: 100, RootVolumeIOPS: 5000},
+		},
+		{
+			name:        "io1 without iops",
+			config:      &AWSMachineProviderConfig{RootVolumeType: VolumeTypeIO1},
+			expectedErr: true,
+		},
+		{
+			name:        "io1 with too many iops for its size",
+			config:      &AWSMachineProviderConfig{RootVolumeType: VolumeTypeIO1, RootDeviceSize: 20, RootVolumeIOPS: 5000},
+			expectedErr: true,
+		},
+		{
+			name:   "gp2",
+			config: &AWSMachineProviderConfig{RootVolumeType: VolumeTypeGP2},
+		},
+		{
+			name:        "gp2 with iops",
+			config:      &AWSMachineProviderConfig{RootVolumeType: VolumeTypeGP2, RootVolumeIOPS: 3000},
+			expectedErr: true,
+		},
+		{
+			name:        "unbootable type",
+			config:      &AWSMachineProviderConfig{RootVolumeType: "st1"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.ValidateRootVolume()
+			if tc.expectedErr != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
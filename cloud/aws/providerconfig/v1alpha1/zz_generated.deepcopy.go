@@ -17,13 +17,60 @@
 package v1alpha1
 
 import (
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSClusterProviderCondition) DeepCopyInto(out *AWSClusterProviderCondition) {
+	*out = *in
+	in.LastProbeTime.DeepCopyInto(&out.LastProbeTime)
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSClusterProviderCondition.
+func (in *AWSClusterProviderCondition) DeepCopy() *AWSClusterProviderCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSClusterProviderCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AWSClusterProviderConfig) DeepCopyInto(out *AWSClusterProviderConfig) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
+	if in.AdditionalTags != nil {
+		in, out := &in.AdditionalTags, &out.AdditionalTags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.Network.DeepCopyInto(&out.Network)
+	if in.PauseWindows != nil {
+		in, out := &in.PauseWindows, &out.PauseWindows
+		*out = make([]PauseWindow, len(*in))
+		copy(*out, *in)
+	}
+	if in.FileSystem != nil {
+		in, out := &in.FileSystem, &out.FileSystem
+		*out = new(FileSystemSpec)
+		**out = **in
+	}
+	if in.PrivateHostedZone != nil {
+		in, out := &in.PrivateHostedZone, &out.PrivateHostedZone
+		*out = new(PrivateHostedZoneSpec)
+		**out = **in
+	}
+	if in.ECRRepositories != nil {
+		in, out := &in.ECRRepositories, &out.ECRRepositories
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -50,6 +97,40 @@ func (in *AWSClusterProviderStatus) DeepCopyInto(out *AWSClusterProviderStatus)
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.Network.DeepCopyInto(&out.Network)
+	if in.HibernatedRegistrations != nil {
+		in, out := &in.HibernatedRegistrations, &out.HibernatedRegistrations
+		*out = make([]LoadBalancerRegistration, len(*in))
+		copy(*out, *in)
+	}
+	if in.Cost != nil {
+		in, out := &in.Cost, &out.Cost
+		*out = new(CostEstimate)
+		**out = **in
+	}
+	if in.FileSystem != nil {
+		in, out := &in.FileSystem, &out.FileSystem
+		*out = new(FileSystem)
+		**out = **in
+	}
+	if in.PrivateHostedZone != nil {
+		in, out := &in.PrivateHostedZone, &out.PrivateHostedZone
+		*out = new(HostedZone)
+		**out = **in
+	}
+	if in.ProvisioningSteps != nil {
+		in, out := &in.ProvisioningSteps, &out.ProvisioningSteps
+		*out = make([]ProvisioningStep, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]AWSClusterProviderCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -123,6 +204,26 @@ func (in *AWSMachineProviderConfig) DeepCopyInto(out *AWSMachineProviderConfig)
 		*out = new(AWSResourceReference)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.InstanceID != nil {
+		in, out := &in.InstanceID, &out.InstanceID
+		*out = new(string)
+		**out = **in
+	}
+	if in.NodeJoinTimeout != nil {
+		in, out := &in.NodeJoinTimeout, &out.NodeJoinTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ImpairedInstanceTimeout != nil {
+		in, out := &in.ImpairedInstanceTimeout, &out.ImpairedInstanceTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.GracefulShutdownTimeout != nil {
+		in, out := &in.GracefulShutdownTimeout, &out.GracefulShutdownTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
 	return
 }
 
@@ -158,6 +259,26 @@ func (in *AWSMachineProviderStatus) DeepCopyInto(out *AWSMachineProviderStatus)
 		*out = new(string)
 		**out = **in
 	}
+	if in.LaunchTemplateID != nil {
+		in, out := &in.LaunchTemplateID, &out.LaunchTemplateID
+		*out = new(string)
+		**out = **in
+	}
+	if in.LaunchTemplateVersion != nil {
+		in, out := &in.LaunchTemplateVersion, &out.LaunchTemplateVersion
+		*out = new(int64)
+		**out = **in
+	}
+	if in.SubnetID != nil {
+		in, out := &in.SubnetID, &out.SubnetID
+		*out = new(string)
+		**out = **in
+	}
+	if in.AvailabilityZone != nil {
+		in, out := &in.AvailabilityZone, &out.AvailabilityZone
+		*out = new(string)
+		**out = **in
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]AWSMachineProviderCondition, len(*in))
@@ -165,6 +286,20 @@ func (in *AWSMachineProviderStatus) DeepCopyInto(out *AWSMachineProviderStatus)
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Diagnostics != nil {
+		in, out := &in.Diagnostics, &out.Diagnostics
+		*out = new(MachineDiagnostics)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LaunchSpec != nil {
+		in, out := &in.LaunchSpec, &out.LaunchSpec
+		*out = new(MachineLaunchSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ShutdownRequestTime != nil {
+		in, out := &in.ShutdownRequestTime, &out.ShutdownRequestTime
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -219,6 +354,54 @@ func (in *AWSResourceReference) DeepCopy() *AWSResourceReference {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CostEstimate) DeepCopyInto(out *CostEstimate) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CostEstimate.
+func (in *CostEstimate) DeepCopy() *CostEstimate {
+	if in == nil {
+		return nil
+	}
+	out := new(CostEstimate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileSystem) DeepCopyInto(out *FileSystem) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileSystem.
+func (in *FileSystem) DeepCopy() *FileSystem {
+	if in == nil {
+		return nil
+	}
+	out := new(FileSystem)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileSystemSpec) DeepCopyInto(out *FileSystemSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileSystemSpec.
+func (in *FileSystemSpec) DeepCopy() *FileSystemSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FileSystemSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Filter) DeepCopyInto(out *Filter) {
 	*out = *in
@@ -240,15 +423,108 @@ func (in *Filter) DeepCopy() *Filter {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostedZone) DeepCopyInto(out *HostedZone) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostedZone.
+func (in *HostedZone) DeepCopy() *HostedZone {
+	if in == nil {
+		return nil
+	}
+	out := new(HostedZone)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadBalancerRegistration) DeepCopyInto(out *LoadBalancerRegistration) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerRegistration.
+func (in *LoadBalancerRegistration) DeepCopy() *LoadBalancerRegistration {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadBalancerRegistration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineDiagnostics) DeepCopyInto(out *MachineDiagnostics) {
+	*out = *in
+	in.CollectionTime.DeepCopyInto(&out.CollectionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineDiagnostics.
+func (in *MachineDiagnostics) DeepCopy() *MachineDiagnostics {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineDiagnostics)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineLaunchSpec) DeepCopyInto(out *MachineLaunchSpec) {
+	*out = *in
+	in.AMI.DeepCopyInto(&out.AMI)
+	if in.IAMInstanceProfile != nil {
+		in, out := &in.IAMInstanceProfile, &out.IAMInstanceProfile
+		*out = new(AWSResourceReference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PublicIP != nil {
+		in, out := &in.PublicIP, &out.PublicIP
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AdditionalSecurityGroups != nil {
+		in, out := &in.AdditionalSecurityGroups, &out.AdditionalSecurityGroups
+		*out = make([]AWSResourceReference, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Subnet != nil {
+		in, out := &in.Subnet, &out.Subnet
+		*out = new(AWSResourceReference)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineLaunchSpec.
+func (in *MachineLaunchSpec) DeepCopy() *MachineLaunchSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineLaunchSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Network) DeepCopyInto(out *Network) {
 	*out = *in
-	out.VPC = in.VPC
+	in.VPC.DeepCopyInto(&out.VPC)
 	if in.InternetGatewayID != nil {
 		in, out := &in.InternetGatewayID, &out.InternetGatewayID
 		*out = new(string)
 		**out = **in
 	}
+	if in.EgressOnlyInternetGatewayID != nil {
+		in, out := &in.EgressOnlyInternetGatewayID, &out.EgressOnlyInternetGatewayID
+		*out = new(string)
+		**out = **in
+	}
 	if in.Subnets != nil {
 		in, out := &in.Subnets, &out.Subnets
 		*out = make(Subnets, len(*in))
@@ -260,6 +536,13 @@ func (in *Network) DeepCopyInto(out *Network) {
 			}
 		}
 	}
+	if in.ProvisioningSteps != nil {
+		in, out := &in.ProvisioningSteps, &out.ProvisioningSteps
+		*out = make([]ProvisioningStep, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -273,6 +556,115 @@ func (in *Network) DeepCopy() *Network {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkSpec) DeepCopyInto(out *NetworkSpec) {
+	*out = *in
+	if in.SubnetIDs != nil {
+		in, out := &in.SubnetIDs, &out.SubnetIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NatGatewayAllocationIDs != nil {
+		in, out := &in.NatGatewayAllocationIDs, &out.NatGatewayAllocationIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecondaryCIDRBlocks != nil {
+		in, out := &in.SecondaryCIDRBlocks, &out.SecondaryCIDRBlocks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EnableDNSHostnames != nil {
+		in, out := &in.EnableDNSHostnames, &out.EnableDNSHostnames
+		*out = new(bool)
+		**out = **in
+	}
+	if in.EnableDNSSupport != nil {
+		in, out := &in.EnableDNSSupport, &out.EnableDNSSupport
+		*out = new(bool)
+		**out = **in
+	}
+	if in.RemovedAvailabilityZones != nil {
+		in, out := &in.RemovedAvailabilityZones, &out.RemovedAvailabilityZones
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ReservedCIDRs != nil {
+		in, out := &in.ReservedCIDRs, &out.ReservedCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RequiredVPCEndpoints != nil {
+		in, out := &in.RequiredVPCEndpoints, &out.RequiredVPCEndpoints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkSpec.
+func (in *NetworkSpec) DeepCopy() *NetworkSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PauseWindow) DeepCopyInto(out *PauseWindow) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PauseWindow.
+func (in *PauseWindow) DeepCopy() *PauseWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(PauseWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateHostedZoneSpec) DeepCopyInto(out *PrivateHostedZoneSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateHostedZoneSpec.
+func (in *PrivateHostedZoneSpec) DeepCopy() *PrivateHostedZoneSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateHostedZoneSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProvisioningStep) DeepCopyInto(out *ProvisioningStep) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProvisioningStep.
+func (in *ProvisioningStep) DeepCopy() *ProvisioningStep {
+	if in == nil {
+		return nil
+	}
+	out := new(ProvisioningStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RouteTable) DeepCopyInto(out *RouteTable) {
 	*out = *in
@@ -302,6 +694,32 @@ func (in *Subnet) DeepCopyInto(out *Subnet) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.SharedRouteTableID != nil {
+		in, out := &in.SharedRouteTableID, &out.SharedRouteTableID
+		*out = new(string)
+		**out = **in
+	}
+	if in.NatGatewayState != nil {
+		in, out := &in.NatGatewayState, &out.NatGatewayState
+		*out = new(string)
+		**out = **in
+	}
+	if in.NatGatewayCreateTime != nil {
+		in, out := &in.NatGatewayCreateTime, &out.NatGatewayCreateTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NatGatewayAllocationID != nil {
+		in, out := &in.NatGatewayAllocationID, &out.NatGatewayAllocationID
+		*out = new(string)
+		**out = **in
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -344,6 +762,18 @@ func (in Subnets) DeepCopy() Subnets {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VPC) DeepCopyInto(out *VPC) {
 	*out = *in
+	if in.SecondaryCidrBlocks != nil {
+		in, out := &in.SecondaryCidrBlocks, &out.SecondaryCidrBlocks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
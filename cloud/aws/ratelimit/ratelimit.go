@@ -0,0 +1,70 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit limits the rate of AWS API calls made for each cluster, so that a single
+// cluster can't use up the API request quota of the whole account.
+package ratelimit
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// Limiters hands out a token bucket rate limiter per cluster.
+// All methods are safe for concurrent use.
+type Limiters struct {
+	qps   float32
+	burst int
+
+	mu       sync.Mutex
+	limiters map[string]flowcontrol.RateLimiter
+}
+
+// New returns limiters that allow qps calls per second for each cluster, with bursts of up
+// to burst calls.
+func New(qps float32, burst int) *Limiters {
+	return &Limiters{
+		qps:      qps,
+		burst:    burst,
+		limiters: make(map[string]flowcontrol.RateLimiter),
+	}
+}
+
+// Limiter returns the rate limiter of the cluster.
+func (l *Limiters) Limiter(clusterName string) flowcontrol.RateLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limiter, ok := l.limiters[clusterName]
+	if !ok {
+		limiter = flowcontrol.NewTokenBucketRateLimiter(l.qps, l.burst)
+		l.limiters[clusterName] = limiter
+	}
+	return limiter
+}
+
+// Handler returns an SDK handler that waits for the rate limiter of the cluster before every
+// attempt of a call. It should be pushed onto the front of the Sign handler list of a client
+// used for that cluster only, so that retries are limited too and requests are signed after
+// waiting.
+func (l *Limiters) Handler(clusterName string) request.NamedHandler {
+	limiter := l.Limiter(clusterName)
+	return request.NamedHandler{
+		Name: "awsprovider.ratelimit.Handler",
+		Fn: func(r *request.Request) {
+			limiter.Accept()
+		},
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+func TestLimiters(t *testing.T) {
+	l := New(0.001, 2)
+
+	h := l.Handler("cluster-a")
+	h.Fn(&request.Request{})
+	h.Fn(&request.Request{})
+
+	// The burst of cluster-a is used up, cluster-b still has its own.
+	if l.Limiter("cluster-a").TryAccept() {
+		t.Fatalf("expected the budget of cluster-a to be used up")
+	}
+	if !l.Limiter("cluster-b").TryAccept() {
+		t.Fatalf("expected cluster-b to have its own budget")
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+)
+
+// adoptedResource is an existing resource that is brought under the management of a cluster.
+type adoptedResource struct {
+	id   string
+	tags map[string]string
+}
+
+// adoptNetwork brings the existing network given in the spec under the management of the
+// cluster, see NetworkSpec.Adopt. All resources are validated before any of them is tagged.
+// The vpc is tagged last, so that an interrupted adoption is completed by the next reconcile.
+func (s *Service) adoptNetwork(clusterName string, spec *v1alpha1.NetworkSpec, network *v1alpha1.Network) error {
+	if spec.VPCID == "" || len(spec.SubnetIDs) == 0 {
+		return errors.New("adopting a network requires a vpc id and subnet ids")
+	}
+
+	vpc, err := s.describeVPC(clusterName, spec.VPCID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find vpc %q to adopt", spec.VPCID)
+	}
+
+	subnets, err := s.describeAdoptedSubnets(vpc, spec.SubnetIDs)
+	if err != nil {
+		return err
+	}
+
+	// The adopted subnets replace the default ones.
+	if len(network.Subnets) == 0 {
+		network.Subnets = subnets
+	}
+
+	if _, tagged := s.clusterLifecycle(clusterName, vpc.Tags); tagged {
+		// The network has already been adopted.
+		return nil
+	}
+
+	s.log.V(2).Info("Adopting network", "vpc-id", vpc.ID, "subnet-ids", spec.SubnetIDs)
+
+	// Subnets.
+	var resources []adoptedResource
+	for _, sn := range subnets {
+		resources = append(resources, adoptedResource{id: sn.ID, tags: sn.Tags})
+	}
+
+	// Internet Gateways.
+	igws, err := s.EC2.DescribeInternetGatewaysWithContext(s.ctx, &ec2.DescribeInternetGatewaysInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("attachment.vpc-id"),
+				Values: []*string{aws.String(vpc.ID)},
+			},
+		},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe internet gateways in vpc %q", vpc.ID)
+	}
+	if len(igws.InternetGateways) == 0 {
+		return errors.Errorf("failed to adopt vpc %q: no internet gateway is attached", vpc.ID)
+	}
+	for _, ig := range igws.InternetGateways {
+		// A gateway given in the spec is only shared with the cluster.
+		if *ig.InternetGatewayId == spec.InternetGatewayID {
+			continue
+		}
+		resources = append(resources, adoptedResource{id: *ig.InternetGatewayId, tags: tagsToMap(ig.Tags)})
+	}
+
+	// NAT Gateways and their Elastic IPs.
+	ngs := make(map[string]*ec2.NatGateway)
+	err = s.EC2.DescribeNatGatewaysPagesWithContext(s.ctx, &ec2.DescribeNatGatewaysInput{
+		Filter: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(vpc.ID)},
+			},
+			{
+				Name:   aws.String("state"),
+				Values: aws.StringSlice([]string{ec2.NatGatewayStatePending, ec2.NatGatewayStateAvailable}),
+			},
+		},
+	}, func(page *ec2.DescribeNatGatewaysOutput, lastPage bool) bool {
+		for _, ng := range page.NatGateways {
+			ngs[*ng.SubnetId] = ng
+		}
+		return !lastPage
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe nat gateways in vpc %q", vpc.ID)
+	}
+	var allocationIDs []*string
+	for _, sn := range subnets.FilterPublic() {
+		ng, ok := ngs[sn.ID]
+		if !ok {
+			return errors.Errorf("failed to adopt public subnet %q: no nat gateway found", sn.ID)
+		}
+
+		resources = append(resources, adoptedResource{id: *ng.NatGatewayId, tags: tagsToMap(ng.Tags)})
+		for _, addr := range ng.NatGatewayAddresses {
+			allocationIDs = append(allocationIDs, addr.AllocationId)
+		}
+	}
+
+	addrs, err := s.EC2.DescribeAddressesWithContext(s.ctx, &ec2.DescribeAddressesInput{AllocationIds: allocationIDs})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe addresses of nat gateways in vpc %q", vpc.ID)
+	}
+	for _, addr := range addrs.Addresses {
+		resources = append(resources, adoptedResource{id: *addr.AllocationId, tags: tagsToMap(addr.Tags)})
+	}
+
+	// Routing tables.
+	rts, err := s.EC2.DescribeRouteTablesWithContext(s.ctx, &ec2.DescribeRouteTablesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(vpc.ID)},
+			},
+		},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe route tables in vpc %q", vpc.ID)
+	}
+	subnetRouteTables := make(map[string]*ec2.RouteTable)
+	for _, rt := range rts.RouteTables {
+		for _, as := range rt.Associations {
+			if as.SubnetId != nil {
+				subnetRouteTables[*as.SubnetId] = rt
+			}
+		}
+	}
+	adoptedRouteTables := make(map[string]bool)
+	for _, sn := range subnets {
+		rt, ok := subnetRouteTables[sn.ID]
+		if !ok {
+			return errors.Errorf("failed to adopt subnet %q: no route table is associated", sn.ID)
+		}
+
+		if !adoptedRouteTables[*rt.RouteTableId] {
+			adoptedRouteTables[*rt.RouteTableId] = true
+			resources = append(resources, adoptedResource{id: *rt.RouteTableId, tags: tagsToMap(rt.Tags)})
+		}
+	}
+
+	resources = append(resources, adoptedResource{id: vpc.ID, tags: vpc.Tags})
+
+	// Validate the ownership of all resources before tagging any of them.
+	for _, r := range resources {
+		if err := s.checkAdoptable(clusterName, r.id, r.tags); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range resources {
+		if _, tagged := s.clusterLifecycle(clusterName, r.tags); tagged {
+			continue
+		}
+
+		if err := s.adoptResource(clusterName, r.id); err != nil {
+			return err
+		}
+	}
+
+	network.VPC.ID = vpc.ID
+
+	s.log.V(2).Info("Adopted network", "vpc-id", vpc.ID)
+	return nil
+}
+
+func (s *Service) describeAdoptedSubnets(vpc *v1alpha1.VPC, ids []string) (v1alpha1.Subnets, error) {
+	out, err := s.EC2.DescribeSubnetsWithContext(s.ctx, &ec2.DescribeSubnetsInput{
+		SubnetIds: aws.StringSlice(ids),
+	})
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe subnets %v to adopt", ids)
+	}
+
+	subnets := make(v1alpha1.Subnets, 0, len(out.Subnets))
+	for _, ec2sn := range out.Subnets {
+		sn := subnetFromEC2(ec2sn)
+		if sn.VpcID != vpc.ID {
+			return nil, errors.Errorf("failed to adopt subnet %q: it is not in vpc %q", sn.ID, vpc.ID)
+		}
+		subnets = append(subnets, sn)
+	}
+
+	if len(subnets.FilterPublic()) == 0 || len(subnets.FilterPrivate()) == 0 {
+		return nil, errors.Errorf("failed to adopt subnets %v: at least one public and one private subnet are required", ids)
+	}
+
+	return subnets, nil
+}
+
+// checkAdoptable returns an error if the resource is already used by another cluster.
+func (s *Service) checkAdoptable(clusterName string, resourceID string, tags map[string]string) error {
+	if others := s.otherClusterTags(clusterName, tags); len(others) > 0 {
+		return NewConflict(errors.Errorf("failed to adopt resource %q: it is already used by %s",
+			resourceID, strings.Join(others, ", ")))
+	}
+	return nil
+}
+
+// adoptResource tags an existing resource as owned by the cluster.
+func (s *Service) adoptResource(clusterName string, resourceID string) error {
+	if err := s.createTags(clusterName, resourceID, ResourceLifecycleOwned, nil); err != nil {
+		return errors.Wrapf(err, "failed to tag adopted resource %q", resourceID)
+	}
+
+	s.log.V(2).Info("Adopted resource", "resource-id", resourceID)
+	return nil
+}
@@ -0,0 +1,232 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/fake"
+)
+
+func TestAdoptNetwork(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	spec := buildNetworkByHand(t, f, true)
+	before := countResources(t, f, spec.VPCID)
+
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "test-cluster", spec, map[string]string{"owner": "team-a"}, network)
+
+	if after := countResources(t, f, spec.VPCID); after != before {
+		t.Fatalf("expected adoption not to create resources, before: %v, after: %v", before, after)
+	}
+	checkNetworkTags(t, f, spec.VPCID, map[string]string{
+		"kubernetes.io/cluster/test-cluster": "owned",
+		"owner":                              "team-a",
+	})
+
+	if network.VPC.ID != spec.VPCID || len(network.Subnets) != len(spec.SubnetIDs) {
+		t.Fatalf("expected the status to reflect the adopted network, got: %+v", network)
+	}
+
+	// The adopted network is managed like any other one from now on.
+	reconcileNetworkUntilReady(t, s, "test-cluster", spec, map[string]string{"owner": "team-a"}, network)
+	if after := countResources(t, f, spec.VPCID); after != before {
+		t.Fatalf("expected reconcile to be idempotent, before: %v, after: %v", before, after)
+	}
+
+	deleteNetworkUntilDone(t, s, "test-cluster", network)
+	if _, err := s.describeVPC("test-cluster", spec.VPCID); !IsNotFound(err) {
+		t.Fatalf("expected adopted vpc %q to be deleted, got: %v", spec.VPCID, err)
+	}
+}
+
+func TestAdoptNetworkInvalid(t *testing.T) {
+	testCases := []struct {
+		name  string
+		setup func(t *testing.T, f *fake.EC2) *v1alpha1.NetworkSpec
+	}{
+		{
+			name: "no subnets",
+			setup: func(t *testing.T, f *fake.EC2) *v1alpha1.NetworkSpec {
+				spec := buildNetworkByHand(t, f, true)
+				spec.SubnetIDs = nil
+				return spec
+			},
+		},
+		{
+			name: "only private subnets",
+			setup: func(t *testing.T, f *fake.EC2) *v1alpha1.NetworkSpec {
+				spec := buildNetworkByHand(t, f, true)
+				spec.SubnetIDs = spec.SubnetIDs[:1]
+				return spec
+			},
+		},
+		{
+			name: "no nat gateway",
+			setup: func(t *testing.T, f *fake.EC2) *v1alpha1.NetworkSpec {
+				return buildNetworkByHand(t, f, false)
+			},
+		},
+		{
+			name: "subnet used by another cluster",
+			setup: func(t *testing.T, f *fake.EC2) *v1alpha1.NetworkSpec {
+				spec := buildNetworkByHand(t, f, true)
+				_, err := f.CreateTagsWithContext(context.TODO(), &ec2.CreateTagsInput{
+					Resources: aws.StringSlice(spec.SubnetIDs[:1]),
+					Tags:      []*ec2.Tag{{Key: aws.String("kubernetes.io/cluster/other-cluster"), Value: aws.String("owned")}},
+				})
+				if err != nil {
+					t.Fatalf("failed to tag subnet: %v", err)
+				}
+				return spec
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := fake.New()
+			s := NewService(f)
+
+			spec := tc.setup(t, f)
+			before := countResources(t, f, spec.VPCID)
+
+			if err := s.ReconcileNetwork(context.TODO(), "test-cluster", spec, nil, &v1alpha1.Network{}); err == nil || IsNotReady(err) {
+				t.Fatalf("expected adoption to fail, got: %v", err)
+			}
+
+			// Nothing is created or tagged when the validation fails.
+			if after := countResources(t, f, spec.VPCID); after != before {
+				t.Fatalf("expected no resources to be created, before: %v, after: %v", before, after)
+			}
+			checkNetworkTags(t, f, spec.VPCID, map[string]string{"kubernetes.io/cluster/test-cluster": ""})
+		})
+	}
+}
+
+func TestAdoptInstance(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	run := func() string {
+		out, err := f.RunInstancesWithContext(context.TODO(), &ec2.RunInstancesInput{})
+		if err != nil {
+			t.Fatalf("failed to run instance: %v", err)
+		}
+		return *out.Instances[0].InstanceId
+	}
+
+	id := run()
+	instance, err := s.AdoptInstance(context.TODO(), "test-cluster", id, map[string]string{"owner": "team-a"})
+	if err != nil {
+		t.Fatalf("failed to adopt instance: %v", err)
+	}
+	if instance.Tags["kubernetes.io/cluster/test-cluster"] != "owned" || instance.Tags["owner"] != "team-a" {
+		t.Fatalf("expected the adopted instance to be tagged, got: %v", instance.Tags)
+	}
+
+	// Adopting an instance again is a no-op.
+	if _, err := s.AdoptInstance(context.TODO(), "test-cluster", id, nil); err != nil {
+		t.Fatalf("failed to adopt instance again: %v", err)
+	}
+
+	if _, err := s.AdoptInstance(context.TODO(), "other-cluster", id, nil); !IsConflict(err) {
+		t.Fatalf("expected a conflict adopting an instance of another cluster, got: %v", err)
+	}
+
+	terminated := run()
+	if err := s.TerminateInstance(context.TODO(), aws.String(terminated)); err != nil {
+		t.Fatalf("failed to terminate instance: %v", err)
+	}
+	if _, err := s.AdoptInstance(context.TODO(), "test-cluster", terminated, nil); err == nil {
+		t.Fatalf("expected adopting a terminated instance to fail")
+	}
+}
+
+// buildNetworkByHand creates an untagged network with a private and a public subnet,
+// like one built outside of the provider, and returns the spec to adopt it.
+func buildNetworkByHand(t *testing.T, f *fake.EC2, withNatGateway bool) *v1alpha1.NetworkSpec {
+	vpc, err := f.CreateVpcWithContext(context.TODO(), &ec2.CreateVpcInput{CidrBlock: aws.String("10.10.0.0/16")})
+	if err != nil {
+		t.Fatalf("failed to create vpc: %v", err)
+	}
+	vpcID := vpc.Vpc.VpcId
+
+	ig, err := f.CreateInternetGatewayWithContext(context.TODO(), &ec2.CreateInternetGatewayInput{})
+	if err != nil {
+		t.Fatalf("failed to create internet gateway: %v", err)
+	}
+	if _, err := f.AttachInternetGatewayWithContext(context.TODO(), &ec2.AttachInternetGatewayInput{InternetGatewayId: ig.InternetGateway.InternetGatewayId, VpcId: vpcID}); err != nil {
+		t.Fatalf("failed to attach internet gateway: %v", err)
+	}
+
+	spec := &v1alpha1.NetworkSpec{VPCID: *vpcID, Adopt: true}
+	var natGatewayID *string
+	for _, public := range []bool{true, false} {
+		cidr := "10.10.1.0/24"
+		if !public {
+			cidr = "10.10.2.0/24"
+		}
+
+		sn, err := f.CreateSubnetWithContext(context.TODO(), &ec2.CreateSubnetInput{VpcId: vpcID, CidrBlock: aws.String(cidr), AvailabilityZone: aws.String("us-east-1a")})
+		if err != nil {
+			t.Fatalf("failed to create subnet: %v", err)
+		}
+		subnetID := sn.Subnet.SubnetId
+		// The private subnet is listed first.
+		spec.SubnetIDs = append([]string{*subnetID}, spec.SubnetIDs...)
+
+		route := &ec2.CreateRouteInput{DestinationCidrBlock: aws.String("0.0.0.0/0"), GatewayId: ig.InternetGateway.InternetGatewayId}
+		if public {
+			_, err := f.ModifySubnetAttributeWithContext(context.TODO(), &ec2.ModifySubnetAttributeInput{SubnetId: subnetID, MapPublicIpOnLaunch: &ec2.AttributeBooleanValue{Value: aws.Bool(true)}})
+			if err != nil {
+				t.Fatalf("failed to modify subnet: %v", err)
+			}
+
+			if withNatGateway {
+				addr, err := f.AllocateAddressWithContext(context.TODO(), &ec2.AllocateAddressInput{Domain: aws.String("vpc")})
+				if err != nil {
+					t.Fatalf("failed to allocate address: %v", err)
+				}
+				ng, err := f.CreateNatGatewayWithContext(context.TODO(), &ec2.CreateNatGatewayInput{AllocationId: addr.AllocationId, SubnetId: subnetID})
+				if err != nil {
+					t.Fatalf("failed to create nat gateway: %v", err)
+				}
+				natGatewayID = ng.NatGateway.NatGatewayId
+			}
+		} else if natGatewayID != nil {
+			route = &ec2.CreateRouteInput{DestinationCidrBlock: aws.String("0.0.0.0/0"), NatGatewayId: natGatewayID}
+		}
+
+		rt, err := f.CreateRouteTableWithContext(context.TODO(), &ec2.CreateRouteTableInput{VpcId: vpcID})
+		if err != nil {
+			t.Fatalf("failed to create route table: %v", err)
+		}
+		route.RouteTableId = rt.RouteTable.RouteTableId
+		if _, err := f.CreateRouteWithContext(context.TODO(), route); err != nil {
+			t.Fatalf("failed to create route: %v", err)
+		}
+		if _, err := f.AssociateRouteTableWithContext(context.TODO(), &ec2.AssociateRouteTableInput{RouteTableId: rt.RouteTable.RouteTableId, SubnetId: subnetID}); err != nil {
+			t.Fatalf("failed to associate route table: %v", err)
+		}
+	}
+
+	return spec
+}
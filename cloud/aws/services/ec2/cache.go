@@ -0,0 +1,441 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// describeCache is an EC2API that caches the results of Describe calls.
+// Any mutating call invalidates the whole cache, so it's only meant to live for a
+// single reconcile pass in which the same resources are looked up repeatedly.
+// Mutating methods added to EC2API must be overridden here to invalidate the cache.
+type describeCache struct {
+	EC2API
+
+	mu      sync.Mutex
+	entries map[string]interface{}
+
+	// generation is incremented on every invalidation, so that results of calls
+	// that raced with a mutating call are not cached.
+	generation int
+}
+
+func newDescribeCache(api EC2API) *describeCache {
+	return &describeCache{
+		EC2API:  api,
+		entries: make(map[string]interface{}),
+	}
+}
+
+// withDescribeCache returns a copy of the service whose Describe calls are cached
+// until the next mutating call.
+func (s *Service) withDescribeCache() *Service {
+	c := *s
+	c.EC2 = newDescribeCache(s.EC2)
+	return &c
+}
+
+// get returns a copy of the cached output for the operation and input,
+// and the current generation of the cache.
+func (c *describeCache) get(op string, in interface{}) (interface{}, int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out, ok := c.entries[op+awsutil.Prettify(in)]
+	if !ok {
+		return nil, c.generation, false
+	}
+	return awsutil.CopyOf(out), c.generation, true
+}
+
+// set caches the output unless the cache has been invalidated since the given generation.
+func (c *describeCache) set(op string, in interface{}, out interface{}, generation int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	c.entries[op+awsutil.Prettify(in)] = awsutil.CopyOf(out)
+}
+
+func (c *describeCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.entries = make(map[string]interface{})
+}
+
+func (c *describeCache) DescribeVpcsWithContext(ctx aws.Context, in *ec2.DescribeVpcsInput, opts ...request.Option) (*ec2.DescribeVpcsOutput, error) {
+	cached, gen, ok := c.get("DescribeVpcs", in)
+	if ok {
+		return cached.(*ec2.DescribeVpcsOutput), nil
+	}
+
+	out, err := c.EC2API.DescribeVpcsWithContext(ctx, in, opts...)
+	if err == nil {
+		c.set("DescribeVpcs", in, out, gen)
+	}
+	return out, err
+}
+
+func (c *describeCache) DescribeSubnetsWithContext(ctx aws.Context, in *ec2.DescribeSubnetsInput, opts ...request.Option) (*ec2.DescribeSubnetsOutput, error) {
+	cached, gen, ok := c.get("DescribeSubnets", in)
+	if ok {
+		return cached.(*ec2.DescribeSubnetsOutput), nil
+	}
+
+	out, err := c.EC2API.DescribeSubnetsWithContext(ctx, in, opts...)
+	if err == nil {
+		c.set("DescribeSubnets", in, out, gen)
+	}
+	return out, err
+}
+
+func (c *describeCache) DescribeAvailabilityZonesWithContext(ctx aws.Context, in *ec2.DescribeAvailabilityZonesInput, opts ...request.Option) (*ec2.DescribeAvailabilityZonesOutput, error) {
+	cached, gen, ok := c.get("DescribeAvailabilityZones", in)
+	if ok {
+		return cached.(*ec2.DescribeAvailabilityZonesOutput), nil
+	}
+
+	out, err := c.EC2API.DescribeAvailabilityZonesWithContext(ctx, in, opts...)
+	if err == nil {
+		c.set("DescribeAvailabilityZones", in, out, gen)
+	}
+	return out, err
+}
+
+func (c *describeCache) DescribeInternetGatewaysWithContext(ctx aws.Context, in *ec2.DescribeInternetGatewaysInput, opts ...request.Option) (*ec2.DescribeInternetGatewaysOutput, error) {
+	cached, gen, ok := c.get("DescribeInternetGateways", in)
+	if ok {
+		return cached.(*ec2.DescribeInternetGatewaysOutput), nil
+	}
+
+	out, err := c.EC2API.DescribeInternetGatewaysWithContext(ctx, in, opts...)
+	if err == nil {
+		c.set("DescribeInternetGateways", in, out, gen)
+	}
+	return out, err
+}
+
+func (c *describeCache) DescribeEgressOnlyInternetGatewaysWithContext(ctx aws.Context, in *ec2.DescribeEgressOnlyInternetGatewaysInput, opts ...request.Option) (*ec2.DescribeEgressOnlyInternetGatewaysOutput, error) {
+	cached, gen, ok := c.get("DescribeEgressOnlyInternetGateways", in)
+	if ok {
+		return cached.(*ec2.DescribeEgressOnlyInternetGatewaysOutput), nil
+	}
+
+	out, err := c.EC2API.DescribeEgressOnlyInternetGatewaysWithContext(ctx, in, opts...)
+	if err == nil {
+		c.set("DescribeEgressOnlyInternetGateways", in, out, gen)
+	}
+	return out, err
+}
+
+// natGatewayPages holds the cached pages of a DescribeNatGatewaysPages call.
+type natGatewayPages struct {
+	Pages []*ec2.DescribeNatGatewaysOutput
+}
+
+func (c *describeCache) DescribeNatGatewaysPagesWithContext(ctx aws.Context, in *ec2.DescribeNatGatewaysInput, fn func(*ec2.DescribeNatGatewaysOutput, bool) bool, opts ...request.Option) error {
+	cached, gen, ok := c.get("DescribeNatGatewaysPages", in)
+	if !ok {
+		// Fetch all pages, so that they can be replayed to later callers.
+		pages := &natGatewayPages{}
+		err := c.EC2API.DescribeNatGatewaysPagesWithContext(ctx, in, func(page *ec2.DescribeNatGatewaysOutput, lastPage bool) bool {
+			pages.Pages = append(pages.Pages, page)
+			return true
+		}, opts...)
+		if err != nil {
+			return err
+		}
+
+		c.set("DescribeNatGatewaysPages", in, pages, gen)
+		cached = pages
+	}
+
+	pages := cached.(*natGatewayPages).Pages
+	for i, page := range pages {
+		if !fn(page, i == len(pages)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func (c *describeCache) DescribeRouteTablesWithContext(ctx aws.Context, in *ec2.DescribeRouteTablesInput, opts ...request.Option) (*ec2.DescribeRouteTablesOutput, error) {
+	cached, gen, ok := c.get("DescribeRouteTables", in)
+	if ok {
+		return cached.(*ec2.DescribeRouteTablesOutput), nil
+	}
+
+	out, err := c.EC2API.DescribeRouteTablesWithContext(ctx, in, opts...)
+	if err == nil {
+		c.set("DescribeRouteTables", in, out, gen)
+	}
+	return out, err
+}
+
+func (c *describeCache) DescribeVpcEndpointsWithContext(ctx aws.Context, in *ec2.DescribeVpcEndpointsInput, opts ...request.Option) (*ec2.DescribeVpcEndpointsOutput, error) {
+	cached, gen, ok := c.get("DescribeVpcEndpoints", in)
+	if ok {
+		return cached.(*ec2.DescribeVpcEndpointsOutput), nil
+	}
+
+	out, err := c.EC2API.DescribeVpcEndpointsWithContext(ctx, in, opts...)
+	if err == nil {
+		c.set("DescribeVpcEndpoints", in, out, gen)
+	}
+	return out, err
+}
+
+func (c *describeCache) DescribeInstancesWithContext(ctx aws.Context, in *ec2.DescribeInstancesInput, opts ...request.Option) (*ec2.DescribeInstancesOutput, error) {
+	cached, gen, ok := c.get("DescribeInstances", in)
+	if ok {
+		return cached.(*ec2.DescribeInstancesOutput), nil
+	}
+
+	out, err := c.EC2API.DescribeInstancesWithContext(ctx, in, opts...)
+	if err == nil {
+		c.set("DescribeInstances", in, out, gen)
+	}
+	return out, err
+}
+
+func (c *describeCache) DescribeLaunchTemplatesWithContext(ctx aws.Context, in *ec2.DescribeLaunchTemplatesInput, opts ...request.Option) (*ec2.DescribeLaunchTemplatesOutput, error) {
+	cached, gen, ok := c.get("DescribeLaunchTemplates", in)
+	if ok {
+		return cached.(*ec2.DescribeLaunchTemplatesOutput), nil
+	}
+
+	out, err := c.EC2API.DescribeLaunchTemplatesWithContext(ctx, in, opts...)
+	if err == nil {
+		c.set("DescribeLaunchTemplates", in, out, gen)
+	}
+	return out, err
+}
+
+func (c *describeCache) DescribeLaunchTemplateVersionsWithContext(ctx aws.Context, in *ec2.DescribeLaunchTemplateVersionsInput, opts ...request.Option) (*ec2.DescribeLaunchTemplateVersionsOutput, error) {
+	cached, gen, ok := c.get("DescribeLaunchTemplateVersions", in)
+	if ok {
+		return cached.(*ec2.DescribeLaunchTemplateVersionsOutput), nil
+	}
+
+	out, err := c.EC2API.DescribeLaunchTemplateVersionsWithContext(ctx, in, opts...)
+	if err == nil {
+		c.set("DescribeLaunchTemplateVersions", in, out, gen)
+	}
+	return out, err
+}
+
+func (c *describeCache) CreateVpcWithContext(ctx aws.Context, in *ec2.CreateVpcInput, opts ...request.Option) (*ec2.CreateVpcOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.CreateVpcWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) DeleteVpcWithContext(ctx aws.Context, in *ec2.DeleteVpcInput, opts ...request.Option) (*ec2.DeleteVpcOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.DeleteVpcWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) ModifyVpcAttributeWithContext(ctx aws.Context, in *ec2.ModifyVpcAttributeInput, opts ...request.Option) (*ec2.ModifyVpcAttributeOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.ModifyVpcAttributeWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) AssociateVpcCidrBlockWithContext(ctx aws.Context, in *ec2.AssociateVpcCidrBlockInput, opts ...request.Option) (*ec2.AssociateVpcCidrBlockOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.AssociateVpcCidrBlockWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) CreateSubnetWithContext(ctx aws.Context, in *ec2.CreateSubnetInput, opts ...request.Option) (*ec2.CreateSubnetOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.CreateSubnetWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) DeleteSubnetWithContext(ctx aws.Context, in *ec2.DeleteSubnetInput, opts ...request.Option) (*ec2.DeleteSubnetOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.DeleteSubnetWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) ModifySubnetAttributeWithContext(ctx aws.Context, in *ec2.ModifySubnetAttributeInput, opts ...request.Option) (*ec2.ModifySubnetAttributeOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.ModifySubnetAttributeWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) AttachInternetGatewayWithContext(ctx aws.Context, in *ec2.AttachInternetGatewayInput, opts ...request.Option) (*ec2.AttachInternetGatewayOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.AttachInternetGatewayWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) DetachInternetGatewayWithContext(ctx aws.Context, in *ec2.DetachInternetGatewayInput, opts ...request.Option) (*ec2.DetachInternetGatewayOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.DetachInternetGatewayWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) CreateInternetGatewayWithContext(ctx aws.Context, in *ec2.CreateInternetGatewayInput, opts ...request.Option) (*ec2.CreateInternetGatewayOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.CreateInternetGatewayWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) DeleteInternetGatewayWithContext(ctx aws.Context, in *ec2.DeleteInternetGatewayInput, opts ...request.Option) (*ec2.DeleteInternetGatewayOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.DeleteInternetGatewayWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) CreateEgressOnlyInternetGatewayWithContext(ctx aws.Context, in *ec2.CreateEgressOnlyInternetGatewayInput, opts ...request.Option) (*ec2.CreateEgressOnlyInternetGatewayOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.CreateEgressOnlyInternetGatewayWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) DeleteEgressOnlyInternetGatewayWithContext(ctx aws.Context, in *ec2.DeleteEgressOnlyInternetGatewayInput, opts ...request.Option) (*ec2.DeleteEgressOnlyInternetGatewayOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.DeleteEgressOnlyInternetGatewayWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) CreateNatGatewayWithContext(ctx aws.Context, in *ec2.CreateNatGatewayInput, opts ...request.Option) (*ec2.CreateNatGatewayOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.CreateNatGatewayWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) DeleteNatGatewayWithContext(ctx aws.Context, in *ec2.DeleteNatGatewayInput, opts ...request.Option) (*ec2.DeleteNatGatewayOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.DeleteNatGatewayWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) AllocateAddressWithContext(ctx aws.Context, in *ec2.AllocateAddressInput, opts ...request.Option) (*ec2.AllocateAddressOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.AllocateAddressWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) ReleaseAddressWithContext(ctx aws.Context, in *ec2.ReleaseAddressInput, opts ...request.Option) (*ec2.ReleaseAddressOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.ReleaseAddressWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) AssociateRouteTableWithContext(ctx aws.Context, in *ec2.AssociateRouteTableInput, opts ...request.Option) (*ec2.AssociateRouteTableOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.AssociateRouteTableWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) DisassociateRouteTableWithContext(ctx aws.Context, in *ec2.DisassociateRouteTableInput, opts ...request.Option) (*ec2.DisassociateRouteTableOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.DisassociateRouteTableWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) CreateRouteWithContext(ctx aws.Context, in *ec2.CreateRouteInput, opts ...request.Option) (*ec2.CreateRouteOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.CreateRouteWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) ReplaceRouteWithContext(ctx aws.Context, in *ec2.ReplaceRouteInput, opts ...request.Option) (*ec2.ReplaceRouteOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.ReplaceRouteWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) CreateRouteTableWithContext(ctx aws.Context, in *ec2.CreateRouteTableInput, opts ...request.Option) (*ec2.CreateRouteTableOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.CreateRouteTableWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) DeleteRouteTableWithContext(ctx aws.Context, in *ec2.DeleteRouteTableInput, opts ...request.Option) (*ec2.DeleteRouteTableOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.DeleteRouteTableWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) RunInstancesWithContext(ctx aws.Context, in *ec2.RunInstancesInput, opts ...request.Option) (*ec2.Reservation, error) {
+	defer c.invalidate()
+	return c.EC2API.RunInstancesWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) StartInstancesWithContext(ctx aws.Context, in *ec2.StartInstancesInput, opts ...request.Option) (*ec2.StartInstancesOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.StartInstancesWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) StopInstancesWithContext(ctx aws.Context, in *ec2.StopInstancesInput, opts ...request.Option) (*ec2.StopInstancesOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.StopInstancesWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) TerminateInstancesWithContext(ctx aws.Context, in *ec2.TerminateInstancesInput, opts ...request.Option) (*ec2.TerminateInstancesOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.TerminateInstancesWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) CreateLaunchTemplateWithContext(ctx aws.Context, in *ec2.CreateLaunchTemplateInput, opts ...request.Option) (*ec2.CreateLaunchTemplateOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.CreateLaunchTemplateWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) CreateLaunchTemplateVersionWithContext(ctx aws.Context, in *ec2.CreateLaunchTemplateVersionInput, opts ...request.Option) (*ec2.CreateLaunchTemplateVersionOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.CreateLaunchTemplateVersionWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) DeleteLaunchTemplateWithContext(ctx aws.Context, in *ec2.DeleteLaunchTemplateInput, opts ...request.Option) (*ec2.DeleteLaunchTemplateOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.DeleteLaunchTemplateWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) ModifyVolumeWithContext(ctx aws.Context, in *ec2.ModifyVolumeInput, opts ...request.Option) (*ec2.ModifyVolumeOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.ModifyVolumeWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) DeleteVolumeWithContext(ctx aws.Context, in *ec2.DeleteVolumeInput, opts ...request.Option) (*ec2.DeleteVolumeOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.DeleteVolumeWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) AuthorizeSecurityGroupIngressWithContext(ctx aws.Context, in *ec2.AuthorizeSecurityGroupIngressInput, opts ...request.Option) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.AuthorizeSecurityGroupIngressWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) CreateSecurityGroupWithContext(ctx aws.Context, in *ec2.CreateSecurityGroupInput, opts ...request.Option) (*ec2.CreateSecurityGroupOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.CreateSecurityGroupWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) DeleteSecurityGroupWithContext(ctx aws.Context, in *ec2.DeleteSecurityGroupInput, opts ...request.Option) (*ec2.DeleteSecurityGroupOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.DeleteSecurityGroupWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) RevokeSecurityGroupIngressWithContext(ctx aws.Context, in *ec2.RevokeSecurityGroupIngressInput, opts ...request.Option) (*ec2.RevokeSecurityGroupIngressOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.RevokeSecurityGroupIngressWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) DeleteNetworkInterfaceWithContext(ctx aws.Context, in *ec2.DeleteNetworkInterfaceInput, opts ...request.Option) (*ec2.DeleteNetworkInterfaceOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.DeleteNetworkInterfaceWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) CreateTagsWithContext(ctx aws.Context, in *ec2.CreateTagsInput, opts ...request.Option) (*ec2.CreateTagsOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.CreateTagsWithContext(ctx, in, opts...)
+}
+
+func (c *describeCache) DeleteTagsWithContext(ctx aws.Context, in *ec2.DeleteTagsInput, opts ...request.Option) (*ec2.DeleteTagsOutput, error) {
+	defer c.invalidate()
+	return c.EC2API.DeleteTagsWithContext(ctx, in, opts...)
+}
@@ -0,0 +1,122 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/mock/gomock"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/mock_ec2iface"
+)
+
+func TestDescribeCache(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	m := mock_ec2iface.NewMockEC2API(mockCtrl)
+	input := &ec2.DescribeSubnetsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String("vpc-cache")},
+			},
+		},
+	}
+
+	gomock.InOrder(
+		m.EXPECT().
+			DescribeSubnetsWithContext(gomock.Any(), gomock.Eq(input)).
+			Return(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{{SubnetId: aws.String("subnet-1")}}}, nil).
+			Times(1),
+		m.EXPECT().
+			CreateTagsWithContext(gomock.Any(), gomock.Any()).
+			Return(&ec2.CreateTagsOutput{}, nil),
+		m.EXPECT().
+			DescribeSubnetsWithContext(gomock.Any(), gomock.Eq(input)).
+			Return(&ec2.DescribeSubnetsOutput{}, nil).
+			Times(1),
+	)
+
+	c := newDescribeCache(m)
+
+	for i := 0; i < 3; i++ {
+		out, err := c.DescribeSubnetsWithContext(context.TODO(), input)
+		if err != nil {
+			t.Fatalf("got an unexpected error: %v", err)
+		}
+
+		if len(out.Subnets) != 1 || *out.Subnets[0].SubnetId != "subnet-1" {
+			t.Fatalf("unexpected output: %v", out)
+		}
+
+		// Callers must not be able to modify the cached output.
+		out.Subnets[0].SubnetId = aws.String("modified")
+	}
+
+	// Mutating calls invalidate the cache.
+	if _, err := c.CreateTagsWithContext(context.TODO(), &ec2.CreateTagsInput{}); err != nil {
+		t.Fatalf("got an unexpected error: %v", err)
+	}
+
+	out, err := c.DescribeSubnetsWithContext(context.TODO(), input)
+	if err != nil {
+		t.Fatalf("got an unexpected error: %v", err)
+	}
+	if len(out.Subnets) != 0 {
+		t.Fatalf("expected a fresh result, got: %v", out)
+	}
+}
+
+func TestDescribeCacheNatGatewayPages(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	m := mock_ec2iface.NewMockEC2API(mockCtrl)
+	m.EXPECT().
+		DescribeNatGatewaysPagesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).
+		Do(func(_, _, y interface{}) {
+			fn := y.(func(page *ec2.DescribeNatGatewaysOutput, lastPage bool) bool)
+			if fn(&ec2.DescribeNatGatewaysOutput{NatGateways: []*ec2.NatGateway{{NatGatewayId: aws.String("nat-1")}}}, false) {
+				fn(&ec2.DescribeNatGatewaysOutput{NatGateways: []*ec2.NatGateway{{NatGatewayId: aws.String("nat-2")}}}, true)
+			}
+		}).
+		Return(nil).
+		Times(1)
+
+	c := newDescribeCache(m)
+
+	for i := 0; i < 2; i++ {
+		var ids []string
+		err := c.DescribeNatGatewaysPagesWithContext(context.TODO(), &ec2.DescribeNatGatewaysInput{}, func(page *ec2.DescribeNatGatewaysOutput, lastPage bool) bool {
+			for _, ng := range page.NatGateways {
+				ids = append(ids, *ng.NatGatewayId)
+			}
+			if lastPage != (len(ids) == 2) {
+				t.Fatalf("unexpected last page %v after %v", lastPage, ids)
+			}
+			return true
+		})
+
+		if err != nil {
+			t.Fatalf("got an unexpected error: %v", err)
+		}
+
+		if len(ids) != 2 {
+			t.Fatalf("expected both pages to be returned, got: %v", ids)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"encoding/binary"
+	"net"
+	"sort"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
+)
+
+// ValidateClusterNetwork returns an error if the pod and service CIDR blocks of the cluster
+// network overlap with each other, with the reserved CIDRs of the spec, or with the CIDR blocks
+// of the vpc or subnets of the network, including the defaults used for blocks that aren't set.
+func (s *Service) ValidateClusterNetwork(spec *v1alpha1.NetworkSpec, network *v1alpha1.Network, clusterNetwork *clusterv1.ClusterNetworkingConfig) error {
+	pods, services := clusterNetwork.Pods.CIDRBlocks, clusterNetwork.Services.CIDRBlocks
+
+	if err := validateCIDROverlaps("pod", pods, "service", services); err != nil {
+		return err
+	}
+	if err := validateCIDROverlaps("pod", pods, "reserved", spec.ReservedCIDRs); err != nil {
+		return err
+	}
+	if err := validateCIDROverlaps("service", services, "reserved", spec.ReservedCIDRs); err != nil {
+		return err
+	}
+	if err := validateNetworkCIDRs(spec, network, s.defaultVPCCIDR, "pod", pods); err != nil {
+		return err
+	}
+	return validateNetworkCIDRs(spec, network, s.defaultVPCCIDR, "service", services)
+}
+
+// validateReservedCIDRs returns an error if the CIDR blocks of the vpc or subnets, including the
+// defaults used for blocks that aren't set, overlap with the reserved CIDRs of the spec.
+func validateReservedCIDRs(spec *v1alpha1.NetworkSpec, network *v1alpha1.Network, defaultVPCCIDR string) error {
+	return validateNetworkCIDRs(spec, network, defaultVPCCIDR, "reserved", spec.ReservedCIDRs)
+}
+
+// validateNetworkCIDRs returns an error if the CIDR blocks of the vpc or subnets, including the
+// defaults used for blocks that aren't set, overlap with the given kind of CIDR blocks.
+func validateNetworkCIDRs(spec *v1alpha1.NetworkSpec, network *v1alpha1.Network, defaultVPCCIDR string, kind string, cidrs []string) error {
+	if len(cidrs) == 0 {
+		return nil
+	}
+
+	// The cidr of an existing vpc is only known once it has been described.
+	vpcCidr := network.VPC.CidrBlock
+	if vpcCidr == "" && spec.VPCID == "" {
+		vpcCidr = defaultVPCCIDR
+	}
+
+	vpcCidrs := make([]string, 0, len(spec.SecondaryCIDRBlocks)+1)
+	if vpcCidr != "" {
+		vpcCidrs = append(vpcCidrs, vpcCidr)
+	}
+	vpcCidrs = append(vpcCidrs, spec.SecondaryCIDRBlocks...)
+	if err := validateCIDROverlaps("vpc", vpcCidrs, kind, cidrs); err != nil {
+		return err
+	}
+
+	subnetBlocks := make([]string, 0, len(network.Subnets)+2)
+	for _, sn := range network.Subnets {
+		if sn.CidrBlock != "" {
+			subnetBlocks = append(subnetBlocks, sn.CidrBlock)
+		}
+	}
+	if len(network.Subnets) < 2 && vpcCidr != "" {
+		privateCidrs, publicCidrs, err := subnetCidrs(spec, vpcCidr, availabilityZoneCount(spec))
+		if err != nil {
+			return err
+		}
+		for i := range privateCidrs {
+			if len(network.Subnets.FilterPrivate()) <= i {
+				subnetBlocks = append(subnetBlocks, privateCidrs[i])
+			}
+			if len(network.Subnets.FilterPublic()) <= i && !spec.Isolated {
+				subnetBlocks = append(subnetBlocks, publicCidrs[i])
+			}
+		}
+	}
+	return validateCIDROverlaps("subnet", subnetBlocks, kind, cidrs)
+}
+
+// validateCIDROverlaps returns an error if any of the CIDR blocks of one kind overlaps with one
+// of the other kind, or any of them is invalid.
+func validateCIDROverlaps(kind string, cidrs []string, otherKind string, others []string) error {
+	parse := func(kind string, cidrs []string) ([]*net.IPNet, error) {
+		res := make([]*net.IPNet, 0, len(cidrs))
+		for _, cidr := range cidrs {
+			_, n, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid %s cidr %q", kind, cidr)
+			}
+			res = append(res, n)
+		}
+		return res, nil
+	}
+
+	// The other blocks are parsed first, so that they are reported as invalid even without blocks to check.
+	parsedOthers, err := parse(otherKind, others)
+	if err != nil {
+		return err
+	}
+	ns, err := parse(kind, cidrs)
+	if err != nil {
+		return err
+	}
+
+	for i, n := range ns {
+		for j, o := range parsedOthers {
+			if cidrsOverlap(n, o) {
+				return errors.Errorf("%s cidr %q overlaps with %s cidr %q", kind, cidrs[i], otherKind, others[j])
+			}
+		}
+	}
+	return nil
+}
+
+// defaultSubnetPrefixLength is the prefix length of the default subnets of a tier whose prefix
+// length isn't set, if the one of the other tier is.
+const defaultSubnetPrefixLength = 24
+
+// subnetCidrs returns the cidr blocks of the default private and public subnets of a vpc spread
+// over the given number of zones, with the prefix lengths of the spec if it sets any.
+func subnetCidrs(spec *v1alpha1.NetworkSpec, vpcCidr string, zones int) ([]string, []string, error) {
+	if spec.PrivateSubnetPrefixLength == 0 && spec.PublicSubnetPrefixLength == 0 {
+		return defaultSubnetCidrs(vpcCidr, zones)
+	}
+
+	private, public := spec.PrivateSubnetPrefixLength, spec.PublicSubnetPrefixLength
+	if private == 0 {
+		private = defaultSubnetPrefixLength
+	}
+	if public == 0 {
+		public = defaultSubnetPrefixLength
+	}
+	return tieredSubnetCidrs(vpcCidr, zones, private, public)
+}
+
+// tieredSubnetCidrs returns the cidr blocks of the private and public subnets of a vpc spread
+// over the given number of zones, with the given prefix lengths. The blocks are laid out from
+// the start of the vpc, the larger ones first so that every block is aligned without gaps, and
+// the private ones first among blocks of the same size.
+func tieredSubnetCidrs(vpcCidr string, zones int, privatePrefix, publicPrefix int) ([]string, []string, error) {
+	_, n, err := net.ParseCIDR(vpcCidr)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid vpc cidr %q", vpcCidr)
+	}
+
+	ip := n.IP.To4()
+	if ip == nil {
+		return nil, nil, errors.Errorf("vpc cidr %q is not an ipv4 cidr", vpcCidr)
+	}
+
+	ones, _ := n.Mask.Size()
+	for _, tier := range []struct {
+		name   string
+		prefix int
+	}{{"private", privatePrefix}, {"public", publicPrefix}} {
+		// AWS subnets are between /16 and /28.
+		if tier.prefix < 16 || tier.prefix > 28 {
+			return nil, nil, errors.Errorf("%s subnet prefix length %d is not between 16 and 28", tier.name, tier.prefix)
+		}
+		if tier.prefix < ones {
+			return nil, nil, errors.Errorf("%s subnet prefix length %d is shorter than the one of vpc cidr %q", tier.name, tier.prefix, vpcCidr)
+		}
+	}
+
+	type block struct {
+		public bool
+		zone   int
+		prefix int
+	}
+	blocks := make([]block, 0, 2*zones)
+	for i := 0; i < zones; i++ {
+		blocks = append(blocks, block{zone: i, prefix: privatePrefix})
+	}
+	for i := 0; i < zones; i++ {
+		blocks = append(blocks, block{public: true, zone: i, prefix: publicPrefix})
+	}
+	sort.SliceStable(blocks, func(i, j int) bool { return blocks[i].prefix < blocks[j].prefix })
+
+	private := make([]string, zones)
+	public := make([]string, zones)
+	base := uint64(binary.BigEndian.Uint32(ip))
+	end := base + 1<<uint(32-ones)
+	next := base
+	for _, b := range blocks {
+		size := uint64(1) << uint(32-b.prefix)
+		if next+size > end {
+			return nil, nil, errors.Errorf("vpc cidr %q is too small for %d private /%d and %d public /%d subnets", vpcCidr, zones, privatePrefix, zones, publicPrefix)
+		}
+
+		addr := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(addr, uint32(next))
+		cidr := (&net.IPNet{IP: addr, Mask: net.CIDRMask(b.prefix, 32)}).String()
+		if b.public {
+			public[b.zone] = cidr
+		} else {
+			private[b.zone] = cidr
+		}
+		next += size
+	}
+	return private, public, nil
+}
+
+// validateSubnetLayout returns an error if any of the cidr blocks of new subnets overlaps with
+// one of the subnets of the network, e.g. a subnet added to the status by hand.
+func validateSubnetLayout(network *v1alpha1.Network, cidrs []string) error {
+	existing := make([]string, 0, len(network.Subnets))
+	for _, sn := range network.Subnets {
+		if sn.CidrBlock != "" {
+			existing = append(existing, sn.CidrBlock)
+		}
+	}
+	return validateCIDROverlaps("new subnet", cidrs, "subnet", existing)
+}
+
+// defaultSubnetCidrs returns the cidr blocks of the default private and public subnets of a vpc
+// spread over the given number of zones: its first /24 blocks, or smaller ones if the vpc has
+// no room for them. The subnets of each zone are consecutive blocks.
+func defaultSubnetCidrs(vpcCidr string, zones int) ([]string, []string, error) {
+	_, n, err := net.ParseCIDR(vpcCidr)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid vpc cidr %q", vpcCidr)
+	}
+
+	ip := n.IP.To4()
+	if ip == nil {
+		return nil, nil, errors.Errorf("vpc cidr %q is not an ipv4 cidr", vpcCidr)
+	}
+
+	// The number of bits needed to number the blocks of all subnets.
+	bits := 1
+	for 1<<uint(bits) < 2*zones {
+		bits++
+	}
+
+	ones, _ := n.Mask.Size()
+	prefix := 24
+	if ones+bits > prefix {
+		prefix = ones + bits
+	}
+	// AWS subnets are at least /28.
+	if prefix > 28 {
+		return nil, nil, errors.Errorf("vpc cidr %q is too small for the default subnets", vpcCidr)
+	}
+
+	base := binary.BigEndian.Uint32(ip)
+	block := func(i int) string {
+		b := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(b, base+uint32(i)<<uint(32-prefix))
+		return (&net.IPNet{IP: b, Mask: net.CIDRMask(prefix, 32)}).String()
+	}
+
+	private := make([]string, 0, zones)
+	public := make([]string, 0, zones)
+	for i := 0; i < zones; i++ {
+		private = append(private, block(2*i))
+		public = append(public, block(2*i+1))
+	}
+	return private, public, nil
+}
+
+// availabilityZoneCount returns the number of zones the default subnets are spread over.
+func availabilityZoneCount(spec *v1alpha1.NetworkSpec) int {
+	if spec.AvailabilityZoneCount < 1 {
+		return 1
+	}
+	return spec.AvailabilityZoneCount
+}
+
+// cidrsOverlap returns true if the networks share at least one address.
+// CIDR blocks either contain each other or are disjoint.
+func cidrsOverlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
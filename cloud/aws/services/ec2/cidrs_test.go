@@ -0,0 +1,297 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/fake"
+	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
+)
+
+func TestValidateReservedCIDRs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		spec     *v1alpha1.NetworkSpec
+		network  *v1alpha1.Network
+		expected bool
+	}{
+		{
+			name:     "no reserved cidrs",
+			spec:     &v1alpha1.NetworkSpec{},
+			network:  &v1alpha1.Network{},
+			expected: true,
+		},
+		{
+			name:     "default vpc cidr overlaps",
+			spec:     &v1alpha1.NetworkSpec{ReservedCIDRs: []string{"10.0.0.0/8"}},
+			network:  &v1alpha1.Network{},
+			expected: false,
+		},
+		{
+			name: "disjoint vpc and subnets",
+			spec: &v1alpha1.NetworkSpec{ReservedCIDRs: []string{"10.0.0.0/16", "192.168.0.0/16"}},
+			network: &v1alpha1.Network{
+				VPC: v1alpha1.VPC{CidrBlock: "10.1.0.0/16"},
+				Subnets: v1alpha1.Subnets{
+					{CidrBlock: "10.1.0.0/24"},
+					{CidrBlock: "10.1.1.0/24", IsPublic: true},
+				},
+			},
+			expected: true,
+		},
+		{
+			name: "reserved cidr within the vpc",
+			spec: &v1alpha1.NetworkSpec{ReservedCIDRs: []string{"10.1.128.0/20"}},
+			network: &v1alpha1.Network{
+				VPC: v1alpha1.VPC{CidrBlock: "10.1.0.0/16"},
+				Subnets: v1alpha1.Subnets{
+					{CidrBlock: "10.1.0.0/24"},
+					{CidrBlock: "10.1.1.0/24", IsPublic: true},
+				},
+			},
+			expected: false,
+		},
+		{
+			name: "default subnet cidr overlaps",
+			spec: &v1alpha1.NetworkSpec{ReservedCIDRs: []string{"10.1.1.0/24"}},
+			network: &v1alpha1.Network{
+				VPC:     v1alpha1.VPC{CidrBlock: "10.1.0.0/16"},
+				Subnets: v1alpha1.Subnets{{CidrBlock: "10.1.0.0/24"}},
+			},
+			expected: false,
+		},
+		{
+			name:     "cidr of an existing vpc isn't known yet",
+			spec:     &v1alpha1.NetworkSpec{VPCID: "vpc-existing", ReservedCIDRs: []string{"10.0.0.0/8"}},
+			network:  &v1alpha1.Network{Subnets: v1alpha1.Subnets{{CidrBlock: "172.16.0.0/24"}, {CidrBlock: "172.16.1.0/24", IsPublic: true}}},
+			expected: true,
+		},
+		{
+			name:     "invalid reserved cidr",
+			spec:     &v1alpha1.NetworkSpec{ReservedCIDRs: []string{"10.0.0.0"}},
+			network:  &v1alpha1.Network{},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateReservedCIDRs(tc.spec, tc.network, defaultVpcCidr)
+			if tc.expected && err != nil {
+				t.Fatalf("expected cidrs to be valid, got: %v", err)
+			} else if !tc.expected && err == nil {
+				t.Fatalf("expected cidrs to be invalid")
+			}
+		})
+	}
+}
+
+func TestValidateClusterNetwork(t *testing.T) {
+	clusterNetwork := func(pods, services string) *clusterv1.ClusterNetworkingConfig {
+		return &clusterv1.ClusterNetworkingConfig{
+			Pods:     clusterv1.NetworkRanges{CIDRBlocks: []string{pods}},
+			Services: clusterv1.NetworkRanges{CIDRBlocks: []string{services}},
+		}
+	}
+
+	testCases := []struct {
+		name           string
+		spec           *v1alpha1.NetworkSpec
+		network        *v1alpha1.Network
+		clusterNetwork *clusterv1.ClusterNetworkingConfig
+		expected       bool
+	}{
+		{
+			name:           "no cluster network",
+			spec:           &v1alpha1.NetworkSpec{},
+			network:        &v1alpha1.Network{},
+			clusterNetwork: &clusterv1.ClusterNetworkingConfig{},
+			expected:       true,
+		},
+		{
+			name:           "separate ranges",
+			spec:           &v1alpha1.NetworkSpec{},
+			network:        &v1alpha1.Network{},
+			clusterNetwork: clusterNetwork("192.168.0.0/16", "172.20.0.0/16"),
+			expected:       true,
+		},
+		{
+			name:           "pods overlap with services",
+			spec:           &v1alpha1.NetworkSpec{},
+			network:        &v1alpha1.Network{},
+			clusterNetwork: clusterNetwork("172.16.0.0/12", "172.20.0.0/16"),
+			expected:       false,
+		},
+		{
+			name:           "pods overlap with the default vpc cidr",
+			spec:           &v1alpha1.NetworkSpec{},
+			network:        &v1alpha1.Network{},
+			clusterNetwork: clusterNetwork("10.0.0.0/8", "172.20.0.0/16"),
+			expected:       false,
+		},
+		{
+			name:           "services overlap with a secondary cidr",
+			spec:           &v1alpha1.NetworkSpec{SecondaryCIDRBlocks: []string{"172.20.0.0/16"}},
+			network:        &v1alpha1.Network{},
+			clusterNetwork: clusterNetwork("192.168.0.0/16", "172.20.0.0/16"),
+			expected:       false,
+		},
+		{
+			name:           "services overlap with a subnet of an existing vpc",
+			spec:           &v1alpha1.NetworkSpec{VPCID: "vpc-1"},
+			network:        &v1alpha1.Network{Subnets: v1alpha1.Subnets{{CidrBlock: "172.20.1.0/24"}}},
+			clusterNetwork: clusterNetwork("192.168.0.0/16", "172.20.0.0/16"),
+			expected:       false,
+		},
+		{
+			name:           "pods overlap with a reserved cidr",
+			spec:           &v1alpha1.NetworkSpec{ReservedCIDRs: []string{"192.168.100.0/24"}},
+			network:        &v1alpha1.Network{},
+			clusterNetwork: clusterNetwork("192.168.0.0/16", "172.20.0.0/16"),
+			expected:       false,
+		},
+		{
+			name:           "invalid pod cidr",
+			spec:           &v1alpha1.NetworkSpec{},
+			network:        &v1alpha1.Network{},
+			clusterNetwork: clusterNetwork("192.168.0.0", "172.20.0.0/16"),
+			expected:       false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := NewService(fake.New()).ValidateClusterNetwork(tc.spec, tc.network, tc.clusterNetwork)
+			if tc.expected && err != nil {
+				t.Fatalf("expected cluster network to be valid, got: %v", err)
+			} else if !tc.expected && err == nil {
+				t.Fatalf("expected cluster network to be invalid")
+			}
+		})
+	}
+}
+
+func TestReconcileNetworkReservedCIDRs(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	err := s.ReconcileNetwork(context.TODO(), "test-cluster", &v1alpha1.NetworkSpec{ReservedCIDRs: []string{"10.0.0.0/8"}}, nil, &v1alpha1.Network{})
+	if err == nil || IsNotReady(err) {
+		t.Fatalf("expected the default vpc cidr to be rejected, got: %v", err)
+	}
+
+	out, err := f.DescribeVpcsWithContext(context.TODO(), &ec2.DescribeVpcsInput{})
+	if err != nil {
+		t.Fatalf("failed to describe vpcs: %v", err)
+	}
+	if len(out.Vpcs) != 0 {
+		t.Fatalf("expected no vpc to be created, got: %v", out.Vpcs)
+	}
+
+	// The cidr of an existing vpc is validated as well.
+	vpc, err := f.CreateVpcWithContext(context.TODO(), &ec2.CreateVpcInput{CidrBlock: aws.String(defaultVpcCidr)})
+	if err != nil {
+		t.Fatalf("failed to create vpc: %v", err)
+	}
+	spec := &v1alpha1.NetworkSpec{VPCID: *vpc.Vpc.VpcId, ReservedCIDRs: []string{"10.0.128.0/17"}}
+	network := &v1alpha1.Network{Subnets: v1alpha1.Subnets{{CidrBlock: "10.0.0.0/24"}, {CidrBlock: "10.0.1.0/24", IsPublic: true}}}
+	if err := s.ReconcileNetwork(context.TODO(), "test-cluster", spec, nil, network); err == nil || IsNotReady(err) {
+		t.Fatalf("expected the cidr of the existing vpc to be rejected, got: %v", err)
+	}
+}
+
+func TestTieredSubnetCidrs(t *testing.T) {
+	testCases := []struct {
+		name            string
+		vpcCidr         string
+		zones           int
+		private, public int
+		expectedPrivate []string
+		expectedPublic  []string
+	}{
+		{
+			name:    "larger private subnets",
+			vpcCidr: "10.0.0.0/16", zones: 3, private: 20, public: 24,
+			expectedPrivate: []string{"10.0.0.0/20", "10.0.16.0/20", "10.0.32.0/20"},
+			expectedPublic:  []string{"10.0.48.0/24", "10.0.49.0/24", "10.0.50.0/24"},
+		},
+		{
+			name:    "larger public subnets",
+			vpcCidr: "10.0.0.0/16", zones: 2, private: 26, public: 22,
+			expectedPrivate: []string{"10.0.8.0/26", "10.0.8.64/26"},
+			expectedPublic:  []string{"10.0.0.0/22", "10.0.4.0/22"},
+		},
+		{
+			name:    "equal subnets",
+			vpcCidr: "10.1.0.0/20", zones: 1, private: 24, public: 24,
+			expectedPrivate: []string{"10.1.0.0/24"},
+			expectedPublic:  []string{"10.1.1.0/24"},
+		},
+		{name: "vpc too small", vpcCidr: "10.0.0.0/20", zones: 2, private: 21, public: 24},
+		{name: "subnet larger than vpc", vpcCidr: "10.0.0.0/20", zones: 1, private: 19, public: 24},
+		{name: "subnet too small", vpcCidr: "10.0.0.0/16", zones: 1, private: 20, public: 29},
+	}
+
+	for _, tc := range testCases {
+		private, public, err := tieredSubnetCidrs(tc.vpcCidr, tc.zones, tc.private, tc.public)
+		if tc.expectedPrivate == nil {
+			if err == nil {
+				t.Fatalf("%s: expected an error, got: %q, %q", tc.name, private, public)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: failed to lay out subnets: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(private, tc.expectedPrivate) || !reflect.DeepEqual(public, tc.expectedPublic) {
+			t.Fatalf("%s: expected private %q and public %q subnets, got: %q, %q", tc.name, tc.expectedPrivate, tc.expectedPublic, private, public)
+		}
+	}
+}
+
+func TestReconcileNetworkSubnetPrefixLengths(t *testing.T) {
+	f := fake.New()
+	f.AvailabilityZones = []string{"us-east-1a", "us-east-1b"}
+	s := NewService(f)
+
+	spec := &v1alpha1.NetworkSpec{AvailabilityZoneCount: 2, PrivateSubnetPrefixLength: 20}
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "test-cluster", spec, nil, network)
+
+	expected := map[string]bool{"10.0.0.0/20": false, "10.0.16.0/20": false, "10.0.32.0/24": true, "10.0.33.0/24": true}
+	if len(network.Subnets) != len(expected) {
+		t.Fatalf("expected %d subnets, got: %v", len(expected), network.Subnets)
+	}
+	for _, sn := range network.Subnets {
+		if public, ok := expected[sn.CidrBlock]; !ok || public != sn.IsPublic {
+			t.Fatalf("unexpected subnet %q with cidr %q, public: %t", sn.ID, sn.CidrBlock, sn.IsPublic)
+		}
+	}
+
+	// New subnets must not overlap with the ones in the status.
+	network = &v1alpha1.Network{Subnets: v1alpha1.Subnets{{CidrBlock: "10.0.32.0/24", AvailabilityZone: "us-east-1a"}}}
+	err := s.ReconcileNetwork(context.TODO(), "other-cluster", spec, nil, network)
+	for i := 0; i < 5 && IsNotReady(err); i++ {
+		err = s.ReconcileNetwork(context.TODO(), "other-cluster", spec, nil, network)
+	}
+	if err == nil || IsNotReady(err) {
+		t.Fatalf("expected the overlapping subnet to be rejected, got: %v", err)
+	}
+}
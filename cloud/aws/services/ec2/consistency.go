@@ -0,0 +1,66 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// visibilityTimeout is how long to wait for a created resource to become visible. EC2 is
+// eventually consistent, calls right after a create may not find the resource for a few seconds.
+var visibilityTimeout = 30 * time.Second
+
+// visibilityInitialInterval is how long to wait before calling again the first time, the
+// interval doubles with every call up to visibilityMaxInterval.
+var visibilityInitialInterval = 250 * time.Millisecond
+
+const visibilityMaxInterval = 4 * time.Second
+
+// isNotVisible returns true if the error is caused by a resource that wasn't found, either by
+// AWS, e.g. InvalidRouteTableID.NotFound, or by a describe helper.
+func isNotVisible(err error) bool {
+	return IsNotFound(errors.Cause(err))
+}
+
+// waitUntilVisible calls fn until it no longer fails because the created resource isn't found,
+// backing off between calls, up to visibilityTimeout. fn is typically a describe of the
+// resource by its id, or the call that references it right after its creation, like tagging it.
+// Other errors are returned right away.
+func (s *Service) waitUntilVisible(resourceID string, fn func() error) error {
+	deadline := time.Now().Add(visibilityTimeout)
+	interval := visibilityInitialInterval
+	for {
+		err := fn()
+		if !isNotVisible(err) {
+			return err
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return errors.Wrapf(err, "resource %q still not visible after %s", resourceID, visibilityTimeout)
+		}
+
+		s.log.V(2).Info("Created resource isn't visible yet, calling again", "resource-id", resourceID, "reason", err, "retry-after", interval)
+		select {
+		case <-s.ctx.Done():
+			return errors.Wrapf(s.ctx.Err(), "failed to wait for resource %q", resourceID)
+		case <-time.After(interval):
+		}
+
+		interval *= 2
+		if interval > visibilityMaxInterval {
+			interval = visibilityMaxInterval
+		}
+	}
+}
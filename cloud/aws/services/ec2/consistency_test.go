@@ -0,0 +1,138 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/fake"
+)
+
+// laggingEC2 doesn't find the created vpcs and route tables when they're described by id or
+// tagged the given number of times, like EC2 right after their creation.
+type laggingEC2 struct {
+	*fake.EC2
+
+	mu    sync.Mutex
+	lag   int
+	calls map[string]int
+}
+
+func (f *laggingEC2) visible(id string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[id]++
+	return f.calls[id] > f.lag
+}
+
+func (f *laggingEC2) DescribeVpcsWithContext(ctx aws.Context, in *ec2.DescribeVpcsInput, opts ...request.Option) (*ec2.DescribeVpcsOutput, error) {
+	for _, id := range in.VpcIds {
+		if !f.visible("describe-" + *id) {
+			return nil, awserr.New("InvalidVpcID.NotFound", "The vpc ID '"+*id+"' does not exist", nil)
+		}
+	}
+	return f.EC2.DescribeVpcsWithContext(ctx, in, opts...)
+}
+
+func (f *laggingEC2) DescribeRouteTablesWithContext(ctx aws.Context, in *ec2.DescribeRouteTablesInput, opts ...request.Option) (*ec2.DescribeRouteTablesOutput, error) {
+	for _, id := range in.RouteTableIds {
+		if !f.visible("describe-" + *id) {
+			return nil, awserr.New("InvalidRouteTableID.NotFound", "The routeTable ID '"+*id+"' does not exist", nil)
+		}
+	}
+	return f.EC2.DescribeRouteTablesWithContext(ctx, in, opts...)
+}
+
+func (f *laggingEC2) CreateTagsWithContext(ctx aws.Context, in *ec2.CreateTagsInput, opts ...request.Option) (*ec2.CreateTagsOutput, error) {
+	for _, id := range in.Resources {
+		if !f.visible("tag-" + *id) {
+			return nil, awserr.New("InvalidID.NotFound", "The ID '"+*id+"' does not exist", nil)
+		}
+	}
+	return f.EC2.CreateTagsWithContext(ctx, in, opts...)
+}
+
+func TestReconcileNetworkWaitsUntilVisible(t *testing.T) {
+	defer func(timeout, interval time.Duration) {
+		visibilityTimeout, visibilityInitialInterval = timeout, interval
+	}(visibilityTimeout, visibilityInitialInterval)
+	visibilityTimeout, visibilityInitialInterval = time.Second, time.Millisecond
+
+	f := &laggingEC2{EC2: fake.New(), lag: 2, calls: make(map[string]int)}
+	s := NewService(f)
+
+	// Nothing fails because of created resources that aren't visible yet.
+	network := &v1alpha1.Network{}
+	for i := 0; i < 5; i++ {
+		err := s.ReconcileNetwork(context.TODO(), "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+		if err == nil {
+			break
+		}
+		if !IsNotReady(err) {
+			t.Fatalf("failed to reconcile network: %v", err)
+		}
+	}
+
+	for _, sn := range network.Subnets {
+		if sn.RouteTableID == nil {
+			t.Fatalf("expected subnet %q to be associated with a route table", sn.ID)
+		}
+	}
+
+	out, err := f.EC2.DescribeVpcsWithContext(context.TODO(), &ec2.DescribeVpcsInput{VpcIds: aws.StringSlice([]string{network.VPC.ID})})
+	if err != nil {
+		t.Fatalf("failed to describe vpc: %v", err)
+	}
+	if tags := tagsToMap(out.Vpcs[0].Tags); tags[TagNameKubernetesClusterPrefix+"test-cluster"] != ResourceLifecycleOwned {
+		t.Fatalf("expected the vpc to be tagged, got: %v", tags)
+	}
+}
+
+func TestWaitUntilVisible(t *testing.T) {
+	defer func(timeout, interval time.Duration) {
+		visibilityTimeout, visibilityInitialInterval = timeout, interval
+	}(visibilityTimeout, visibilityInitialInterval)
+	visibilityTimeout, visibilityInitialInterval = 20*time.Millisecond, time.Millisecond
+
+	s := NewService(fake.New())
+
+	// Resources that don't become visible fail once the timeout passed.
+	calls := 0
+	err := s.waitUntilVisible("rtb-1", func() error {
+		calls++
+		return awserr.New("InvalidRouteTableID.NotFound", "The routeTable ID 'rtb-1' does not exist", nil)
+	})
+	if err == nil || calls < 2 {
+		t.Fatalf("expected the call to be retried until the timeout, got %d calls: %v", calls, err)
+	}
+
+	// Other errors are returned right away.
+	calls = 0
+	err = s.waitUntilVisible("rtb-1", func() error {
+		calls++
+		return errors.New("boom")
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("expected the error to be returned right away, got %d calls: %v", calls, err)
+	}
+}
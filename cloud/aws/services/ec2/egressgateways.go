@@ -0,0 +1,128 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+)
+
+// reconcileEgressOnlyInternetGateways makes sure that a vpc with an IPv6 CIDR block has an
+// egress-only internet gateway attached. Egress-only internet gateways can't be tagged,
+// so they are found by their attachment and share the lifecycle of the vpc: a gateway is only
+// created in a vpc owned by the cluster, and it's deleted together with the vpc.
+func (s *Service) reconcileEgressOnlyInternetGateways(clusterName string, in *v1alpha1.Network) error {
+	if in.VPC.IPv6CidrBlock == "" {
+		in.EgressOnlyInternetGatewayID = nil
+		return nil
+	}
+
+	s.log.V(2).Info("Reconciling egress-only internet gateways", "vpc-id", in.VPC.ID)
+
+	eigws, err := s.describeVpcEgressOnlyInternetGateways(&in.VPC)
+	if IsNotFound(err) {
+		if lifecycle, _ := s.clusterLifecycle(clusterName, in.VPC.Tags); lifecycle != ResourceLifecycleOwned {
+			s.log.V(2).Info("Not creating an egress-only internet gateway in a shared vpc", "vpc-id", in.VPC.ID)
+			in.EgressOnlyInternetGatewayID = nil
+			return nil
+		}
+
+		eigw, err := s.createEgressOnlyInternetGateway(&in.VPC)
+		if err != nil {
+			return err
+		}
+		eigws = []*ec2.EgressOnlyInternetGateway{eigw}
+	} else if err != nil {
+		return err
+	}
+
+	in.EgressOnlyInternetGatewayID = eigws[0].EgressOnlyInternetGatewayId
+	s.log.V(2).Info("Working on egress-only internet gateway", "egress-only-internet-gateway-id", in.EgressOnlyInternetGatewayID)
+	return nil
+}
+
+func (s *Service) createEgressOnlyInternetGateway(vpc *v1alpha1.VPC) (*ec2.EgressOnlyInternetGateway, error) {
+	out, err := s.EC2.CreateEgressOnlyInternetGatewayWithContext(s.ctx, &ec2.CreateEgressOnlyInternetGatewayInput{
+		ClientToken: aws.String(ClientToken(vpc.ID, "egress-only-internet-gateway")),
+		VpcId:       aws.String(vpc.ID),
+	})
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create egress-only internet gateway in vpc %q", vpc.ID)
+	}
+
+	s.log.V(2).Info("Created new egress-only internet gateway", "egress-only-internet-gateway-id", out.EgressOnlyInternetGateway.EgressOnlyInternetGatewayId, "vpc-id", vpc.ID)
+	return out.EgressOnlyInternetGateway, nil
+}
+
+// describeVpcEgressOnlyInternetGateways returns the egress-only internet gateways attached to the vpc.
+// The API doesn't support filters, so all gateways of the region are listed.
+func (s *Service) describeVpcEgressOnlyInternetGateways(vpc *v1alpha1.VPC) ([]*ec2.EgressOnlyInternetGateway, error) {
+	var res []*ec2.EgressOnlyInternetGateway
+
+	input := &ec2.DescribeEgressOnlyInternetGatewaysInput{}
+	for {
+		out, err := s.EC2.DescribeEgressOnlyInternetGatewaysWithContext(s.ctx, input)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to describe egress-only internet gateways in vpc %q", vpc.ID)
+		}
+
+		for _, eigw := range out.EgressOnlyInternetGateways {
+			for _, att := range eigw.Attachments {
+				if aws.StringValue(att.VpcId) == vpc.ID && aws.StringValue(att.State) != ec2.AttachmentStatusDetached {
+					res = append(res, eigw)
+					break
+				}
+			}
+		}
+
+		if aws.StringValue(out.NextToken) == "" {
+			break
+		}
+		input = &ec2.DescribeEgressOnlyInternetGatewaysInput{NextToken: out.NextToken}
+	}
+
+	if len(res) == 0 {
+		return nil, NewNotFound(errors.Errorf("no egress-only internet gateways found in vpc %q", vpc.ID))
+	}
+
+	return res, nil
+}
+
+// deleteEgressOnlyInternetGateways deletes the egress-only internet gateways attached to the vpc.
+// It must only be called when the vpc itself is deleted.
+func (s *Service) deleteEgressOnlyInternetGateways(vpc *v1alpha1.VPC) error {
+	eigws, err := s.describeVpcEgressOnlyInternetGateways(vpc)
+	if IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, eigw := range eigws {
+		_, err := s.EC2.DeleteEgressOnlyInternetGatewayWithContext(s.ctx, &ec2.DeleteEgressOnlyInternetGatewayInput{
+			EgressOnlyInternetGatewayId: eigw.EgressOnlyInternetGatewayId,
+		})
+
+		if err != nil {
+			return errors.Wrapf(err, "failed to delete egress-only internet gateway %q", *eigw.EgressOnlyInternetGatewayId)
+		}
+
+		s.log.V(2).Info("Deleted egress-only internet gateway", "egress-only-internet-gateway-id", eigw.EgressOnlyInternetGatewayId, "vpc-id", vpc.ID)
+	}
+
+	return nil
+}
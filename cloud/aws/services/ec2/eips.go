@@ -19,8 +19,8 @@ import (
 	"github.com/pkg/errors"
 )
 
-func (s *Service) allocateAddress() (string, error) {
-	out, err := s.EC2.AllocateAddress(&ec2.AllocateAddressInput{
+func (s *Service) allocateAddress(clusterName string) (string, error) {
+	out, err := s.EC2.AllocateAddressWithContext(s.ctx, &ec2.AllocateAddressInput{
 		Domain: aws.String("vpc"),
 	})
 
@@ -28,5 +28,90 @@ func (s *Service) allocateAddress() (string, error) {
 		return "", errors.Wrapf(err, "failed to create Elastic IP address")
 	}
 
+	// The allocation id is returned even if tagging fails, so that the caller can record the address.
+	if err := s.createTags(clusterName, *out.AllocationId, ResourceLifecycleOwned, nil); err != nil {
+		return *out.AllocationId, errors.Wrapf(err, "failed to tag Elastic IP address %q", *out.AllocationId)
+	}
+
 	return *out.AllocationId, nil
 }
+
+// reserveAddresses returns up to n of the given Elastic IP addresses that are not in use yet.
+// They are tagged as shared with the cluster, so that they are kept when the cluster is deleted.
+func (s *Service) reserveAddresses(clusterName string, allocationIDs []string, n int) ([]string, error) {
+	if len(allocationIDs) == 0 || n == 0 {
+		return nil, nil
+	}
+
+	out, err := s.EC2.DescribeAddressesWithContext(s.ctx, &ec2.DescribeAddressesInput{
+		AllocationIds: aws.StringSlice(allocationIDs),
+	})
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe Elastic IP addresses %v", allocationIDs)
+	}
+
+	addrs := make(map[string]*ec2.Address, len(out.Addresses))
+	for _, addr := range out.Addresses {
+		addrs[*addr.AllocationId] = addr
+	}
+
+	var res []string
+	for _, id := range allocationIDs {
+		addr, ok := addrs[id]
+		if !ok || addr.AssociationId != nil || len(s.otherClusterTags(clusterName, tagsToMap(addr.Tags))) > 0 {
+			continue
+		}
+
+		if _, err := s.reconcileResourceTags(clusterName, id, tagsToMap(addr.Tags)); err != nil {
+			return nil, errors.Wrapf(err, "failed to tag Elastic IP address %q", id)
+		}
+
+		res = append(res, id)
+		if len(res) == n {
+			break
+		}
+	}
+
+	s.log.V(2).Info("Reserved Elastic IP addresses", "allocation-ids", res)
+	return res, nil
+}
+
+func (s *Service) releaseAddresses(clusterName string) error {
+	return s.releaseMatchingAddresses(clusterName, nil)
+}
+
+// releaseMatchingAddresses releases the Elastic IP addresses of the cluster that match the filters.
+func (s *Service) releaseMatchingAddresses(clusterName string, filters []*ec2.Filter) error {
+	out, err := s.EC2.DescribeAddressesWithContext(s.ctx, &ec2.DescribeAddressesInput{
+		Filters: s.addTagFilters(clusterName, append([]*ec2.Filter{
+			{
+				Name:   aws.String("domain"),
+				Values: []*string{aws.String("vpc")},
+			},
+		}, filters...)),
+	})
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe Elastic IP addresses")
+	}
+
+	for _, addr := range out.Addresses {
+		deleted, err := s.releaseResource(clusterName, *addr.AllocationId, tagsToMap(addr.Tags), func() error {
+			_, err := s.EC2.ReleaseAddressWithContext(s.ctx, &ec2.ReleaseAddressInput{
+				AllocationId: addr.AllocationId,
+			})
+			return errors.Wrapf(err, "failed to release Elastic IP address %q", *addr.AllocationId)
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if deleted {
+			s.log.V(2).Info("Released Elastic IP address", "allocation-id", addr.AllocationId)
+		}
+	}
+
+	return nil
+}
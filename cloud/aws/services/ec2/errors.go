@@ -15,8 +15,10 @@ package ec2
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/pkg/errors"
 )
 
 var _ error = &EC2Error{}
@@ -49,8 +51,31 @@ func NewConflict(err error) error {
 	}
 }
 
-// IsNotFound returns true if the error was created by NewNotFound.
+// NewNotReady returns a new error which indicates that the resource exists, but is still being
+// provisioned and can't be used yet. Callers should retry later instead of waiting.
+func NewNotReady(err error) error {
+	return &EC2Error{
+		err:  err,
+		Code: http.StatusAccepted,
+	}
+}
+
+// NewInvalidConfiguration returns a new error which indicates that the config of a resource can't
+// work, like an AMI that doesn't support its instance type. It's classified like the AWS errors
+// of configs AWS rejects.
+func NewInvalidConfiguration(err error) error {
+	return &EC2Error{
+		err:  err,
+		Code: http.StatusUnprocessableEntity,
+	}
+}
+
+// IsNotFound returns true if the error was created by NewNotFound, or is an AWS error whose code
+// reports a missing resource, like InvalidInstanceID.NotFound.
 func IsNotFound(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		return strings.HasSuffix(aerr.Code(), ".NotFound")
+	}
 	return ReasonForError(err) == http.StatusNotFound
 }
 
@@ -59,6 +84,16 @@ func IsConflict(err error) bool {
 	return ReasonForError(err) == http.StatusConflict
 }
 
+// IsNotReady returns true if the error was created by NewNotReady.
+func IsNotReady(err error) bool {
+	return ReasonForError(err) == http.StatusAccepted
+}
+
+// IsInvalidConfiguration returns true if the error was created by NewInvalidConfiguration.
+func IsInvalidConfiguration(err error) bool {
+	return ReasonForError(err) == http.StatusUnprocessableEntity
+}
+
 // IsSDKError returns true if the error is of type awserr.Error.
 func IsSDKError(err error) (ok bool) {
 	_, ok = err.(awserr.Error)
@@ -73,3 +108,73 @@ func ReasonForError(err error) int {
 	}
 	return -1
 }
+
+// ErrorClass is how a failed AWS call is handled.
+type ErrorClass string
+
+const (
+	// ErrorClassRetry errors are transient, like throttling, and retried with backoff.
+	ErrorClassRetry ErrorClass = "Retry"
+
+	// ErrorClassCapacity errors are due to a lack of capacity in the availability zone or to the
+	// limits of the account, and retried after a longer delay.
+	ErrorClassCapacity ErrorClass = "Capacity"
+
+	// ErrorClassInvalidConfiguration errors are due to a config AWS rejects, like an AMI that
+	// doesn't exist, and need the user to change it.
+	ErrorClassInvalidConfiguration ErrorClass = "InvalidConfiguration"
+
+	// ErrorClassUnauthorized errors are due to missing permissions of the controllers, and need
+	// the user to grant them.
+	ErrorClassUnauthorized ErrorClass = "Unauthorized"
+)
+
+// errorClasses are the classes of the error codes of AWS that aren't retried with backoff.
+var errorClasses = map[string]ErrorClass{
+	"InsufficientAddressCapacity":          ErrorClassCapacity,
+	"InsufficientFreeAddressesInSubnet":    ErrorClassCapacity,
+	"InsufficientHostCapacity":             ErrorClassCapacity,
+	"InsufficientInstanceCapacity":         ErrorClassCapacity,
+	"InsufficientReservedInstanceCapacity": ErrorClassCapacity,
+	"InstanceLimitExceeded":                ErrorClassCapacity,
+	"MaxSpotInstanceCountExceeded":         ErrorClassCapacity,
+	"VcpuLimitExceeded":                    ErrorClassCapacity,
+
+	"InvalidAMIID.Malformed":      ErrorClassInvalidConfiguration,
+	"InvalidAMIID.NotFound":       ErrorClassInvalidConfiguration,
+	"InvalidAMIID.Unavailable":    ErrorClassInvalidConfiguration,
+	"InvalidBlockDeviceMapping":   ErrorClassInvalidConfiguration,
+	"InvalidKeyPair.NotFound":     ErrorClassInvalidConfiguration,
+	"InvalidParameterCombination": ErrorClassInvalidConfiguration,
+	"Unsupported":                 ErrorClassInvalidConfiguration,
+
+	"AuthFailure":           ErrorClassUnauthorized,
+	"Blocked":               ErrorClassUnauthorized,
+	"OptInRequired":         ErrorClassUnauthorized,
+	"UnauthorizedOperation": ErrorClassUnauthorized,
+}
+
+// ClassifyError returns the class of the AWS error the error was caused by. Errors that aren't
+// AWS errors, or whose code isn't known, are retried with backoff, apart from the ones created by
+// NewInvalidConfiguration.
+func ClassifyError(err error) ErrorClass {
+	if IsInvalidConfiguration(errors.Cause(err)) {
+		return ErrorClassInvalidConfiguration
+	}
+	if aerr, ok := errors.Cause(err).(awserr.Error); ok {
+		if class, ok := errorClasses[aerr.Code()]; ok {
+			return class
+		}
+	}
+	return ErrorClassRetry
+}
+
+// IsTerminal returns true if the error needs the user to change the config or the permissions
+// before the call can succeed.
+func IsTerminal(err error) bool {
+	switch ClassifyError(err) {
+	case ErrorClassInvalidConfiguration, ErrorClassUnauthorized:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/pkg/errors"
+)
+
+func TestClassifyError(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected ErrorClass
+		terminal bool
+	}{
+		{err: errors.Wrap(awserr.New("InsufficientInstanceCapacity", "no capacity", nil), "failed to run instances"), expected: ErrorClassCapacity},
+		{err: errors.Wrap(awserr.New("InvalidAMIID.NotFound", "no ami", nil), "failed to run instances"), expected: ErrorClassInvalidConfiguration, terminal: true},
+		{err: awserr.New("UnauthorizedOperation", "not allowed", nil), expected: ErrorClassUnauthorized, terminal: true},
+		{err: awserr.New("RequestLimitExceeded", "throttled", nil), expected: ErrorClassRetry},
+		{err: errors.New("failed to find root device"), expected: ErrorClassRetry},
+		{err: errors.Wrap(NewInvalidConfiguration(errors.New("unsupported ami")), "failed to create instance"), expected: ErrorClassInvalidConfiguration, terminal: true},
+	}
+
+	for _, tc := range testCases {
+		if class := ClassifyError(tc.err); class != tc.expected {
+			t.Fatalf("expected %q to be classified as %s, got: %s", tc.err, tc.expected, class)
+		}
+		if IsTerminal(tc.err) != tc.terminal {
+			t.Fatalf("expected %q to be terminal: %t", tc.err, tc.terminal)
+		}
+	}
+}
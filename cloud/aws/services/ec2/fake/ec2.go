@@ -0,0 +1,2207 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fake provides an in-memory implementation of the EC2 API surface used by
+// the ec2 service. Unlike the gomock mocks it tracks state across calls, so tests
+// can exercise whole reconcile loops without spelling out every request.
+package fake
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// EC2 is an in-memory fake of the EC2 API.
+// All methods are safe for concurrent use.
+type EC2 struct {
+	// AvailabilityZones are the zones reported as available in the region.
+	AvailabilityZones []string
+
+	mu               sync.Mutex
+	ids              int
+	vpcs             []*ec2.Vpc
+	subnets          []*ec2.Subnet
+	internetGateways []*ec2.InternetGateway
+	egressGateways   []*ec2.EgressOnlyInternetGateway
+	natGateways      []*ec2.NatGateway
+	addresses        []*ec2.Address
+	routeTables      []*ec2.RouteTable
+	vpcEndpoints     []*ec2.VpcEndpoint
+	instances        []*ec2.Instance
+	launchTemplates  []*ec2.LaunchTemplate
+	securityGroups   []*ec2.SecurityGroup
+	volumes          []*ec2.Volume
+	modifications    map[string]*ec2.VolumeModification
+	ltVersions       map[string][]*ec2.LaunchTemplateVersion
+	tags             map[string]map[string]string
+	consoleOutputs   map[string]string
+	statusChecks     map[string][2]string
+	vpcAttributes    map[string]map[string]bool
+	// clientTokens are the outputs of create requests by their idempotency token.
+	clientTokens map[string]interface{}
+}
+
+// New returns an empty fake with a single availability zone.
+func New() *EC2 {
+	return &EC2{
+		AvailabilityZones: []string{"us-east-1a"},
+		ltVersions:        make(map[string][]*ec2.LaunchTemplateVersion),
+		tags:              make(map[string]map[string]string),
+		consoleOutputs:    make(map[string]string),
+		statusChecks:      make(map[string][2]string),
+		vpcAttributes:     make(map[string]map[string]bool),
+		clientTokens:      make(map[string]interface{}),
+		modifications:     make(map[string]*ec2.VolumeModification),
+	}
+}
+
+// CreateVpcWithContext implements EC2API.
+func (f *EC2) CreateVpcWithContext(_ aws.Context, in *ec2.CreateVpcInput, _ ...request.Option) (*ec2.CreateVpcOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if in.CidrBlock == nil {
+		return nil, missingParameter("CidrBlock")
+	}
+
+	vpc := &ec2.Vpc{
+		VpcId:     aws.String(f.newID("vpc")),
+		CidrBlock: in.CidrBlock,
+		State:     aws.String(ec2.VpcStateAvailable),
+		CidrBlockAssociationSet: []*ec2.VpcCidrBlockAssociation{{
+			AssociationId:  aws.String(f.newID("vpc-cidr-assoc")),
+			CidrBlock:      in.CidrBlock,
+			CidrBlockState: &ec2.VpcCidrBlockState{State: aws.String(ec2.VpcCidrBlockStateCodeAssociated)},
+		}},
+	}
+	// Like AWS, DNS resolution is enabled in new VPCs, DNS hostnames aren't.
+	f.vpcAttributes[*vpc.VpcId] = map[string]bool{ec2.VpcAttributeNameEnableDnsSupport: true}
+	if aws.BoolValue(in.AmazonProvidedIpv6CidrBlock) {
+		vpc.Ipv6CidrBlockAssociationSet = []*ec2.VpcIpv6CidrBlockAssociation{{
+			AssociationId:      aws.String(f.newID("vpc-cidr-assoc")),
+			Ipv6CidrBlock:      aws.String(fmt.Sprintf("2600:1f18:%x::/56", f.ids)),
+			Ipv6CidrBlockState: &ec2.VpcCidrBlockState{State: aws.String(ec2.VpcCidrBlockStateCodeAssociated)},
+		}}
+	}
+	f.vpcs = append(f.vpcs, vpc)
+
+	// VPCs and their IPv6 CIDR blocks are reported as pending once, and available afterwards.
+	out := f.copyVpc(vpc)
+	out.State = aws.String(ec2.VpcStatePending)
+	for _, as := range out.Ipv6CidrBlockAssociationSet {
+		as.Ipv6CidrBlockState.State = aws.String(ec2.VpcCidrBlockStateCodeAssociating)
+	}
+	return &ec2.CreateVpcOutput{Vpc: out}, nil
+}
+
+// DeleteVpcWithContext implements EC2API.
+func (f *EC2) DeleteVpcWithContext(_ aws.Context, in *ec2.DeleteVpcInput, _ ...request.Option) (*ec2.DeleteVpcOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.findVpc(aws.StringValue(in.VpcId))
+	if i < 0 {
+		return nil, notFound("InvalidVpcID.NotFound", aws.StringValue(in.VpcId))
+	}
+
+	inUse := false
+	for _, sn := range f.subnets {
+		inUse = inUse || aws.StringValue(sn.VpcId) == aws.StringValue(in.VpcId)
+	}
+	for _, rt := range f.routeTables {
+		inUse = inUse || aws.StringValue(rt.VpcId) == aws.StringValue(in.VpcId)
+	}
+	for _, ig := range f.internetGateways {
+		for _, att := range ig.Attachments {
+			inUse = inUse || aws.StringValue(att.VpcId) == aws.StringValue(in.VpcId)
+		}
+	}
+	for _, eigw := range f.egressGateways {
+		for _, att := range eigw.Attachments {
+			inUse = inUse || aws.StringValue(att.VpcId) == aws.StringValue(in.VpcId)
+		}
+	}
+	for _, sg := range f.securityGroups {
+		inUse = inUse || aws.StringValue(sg.VpcId) == aws.StringValue(in.VpcId)
+	}
+	if inUse {
+		return nil, dependencyViolation(fmt.Sprintf("The vpc '%s' has dependencies and cannot be deleted.", aws.StringValue(in.VpcId)))
+	}
+
+	f.vpcs = append(f.vpcs[:i], f.vpcs[i+1:]...)
+	delete(f.tags, aws.StringValue(in.VpcId))
+	delete(f.vpcAttributes, aws.StringValue(in.VpcId))
+	return &ec2.DeleteVpcOutput{}, nil
+}
+
+// DescribeVpcsWithContext implements EC2API.
+func (f *EC2) DescribeVpcsWithContext(_ aws.Context, in *ec2.DescribeVpcsInput, _ ...request.Option) (*ec2.DescribeVpcsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := &ec2.DescribeVpcsOutput{Vpcs: []*ec2.Vpc{}}
+	for _, id := range in.VpcIds {
+		if f.findVpc(aws.StringValue(id)) < 0 {
+			return nil, notFound("InvalidVpcID.NotFound", aws.StringValue(id))
+		}
+	}
+
+	for _, vpc := range f.vpcs {
+		if !containsID(in.VpcIds, vpc.VpcId) {
+			continue
+		}
+
+		ok, err := f.match(*vpc.VpcId, in.Filters, map[string][]string{
+			"vpc-id":     {aws.StringValue(vpc.VpcId)},
+			"cidr":       {aws.StringValue(vpc.CidrBlock)},
+			"cidr-block": {aws.StringValue(vpc.CidrBlock)},
+			"state":      {aws.StringValue(vpc.State)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out.Vpcs = append(out.Vpcs, f.copyVpc(vpc))
+		}
+	}
+
+	return out, nil
+}
+
+// DescribeVpcAttributeWithContext implements EC2API.
+// Only the DNS attributes are supported.
+func (f *EC2) DescribeVpcAttributeWithContext(_ aws.Context, in *ec2.DescribeVpcAttributeInput, _ ...request.Option) (*ec2.DescribeVpcAttributeOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.findVpc(aws.StringValue(in.VpcId)) < 0 {
+		return nil, notFound("InvalidVpcID.NotFound", aws.StringValue(in.VpcId))
+	}
+
+	attrs := f.vpcAttributes[aws.StringValue(in.VpcId)]
+	out := &ec2.DescribeVpcAttributeOutput{VpcId: in.VpcId}
+	switch aws.StringValue(in.Attribute) {
+	case ec2.VpcAttributeNameEnableDnsSupport:
+		out.EnableDnsSupport = &ec2.AttributeBooleanValue{Value: aws.Bool(attrs[ec2.VpcAttributeNameEnableDnsSupport])}
+	case ec2.VpcAttributeNameEnableDnsHostnames:
+		out.EnableDnsHostnames = &ec2.AttributeBooleanValue{Value: aws.Bool(attrs[ec2.VpcAttributeNameEnableDnsHostnames])}
+	default:
+		return nil, awserr.New("InvalidParameterValue", fmt.Sprintf("Value (%s) for parameter attribute is invalid", aws.StringValue(in.Attribute)), nil)
+	}
+	return out, nil
+}
+
+// ModifyVpcAttributeWithContext implements EC2API.
+// Only the DNS attributes are supported, one per request like in AWS.
+func (f *EC2) ModifyVpcAttributeWithContext(_ aws.Context, in *ec2.ModifyVpcAttributeInput, _ ...request.Option) (*ec2.ModifyVpcAttributeOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.findVpc(aws.StringValue(in.VpcId)) < 0 {
+		return nil, notFound("InvalidVpcID.NotFound", aws.StringValue(in.VpcId))
+	}
+
+	attrs := f.vpcAttributes[aws.StringValue(in.VpcId)]
+	switch {
+	case in.EnableDnsSupport != nil && in.EnableDnsHostnames == nil:
+		attrs[ec2.VpcAttributeNameEnableDnsSupport] = aws.BoolValue(in.EnableDnsSupport.Value)
+	case in.EnableDnsHostnames != nil && in.EnableDnsSupport == nil:
+		attrs[ec2.VpcAttributeNameEnableDnsHostnames] = aws.BoolValue(in.EnableDnsHostnames.Value)
+	default:
+		return nil, awserr.New("InvalidParameterCombination", "Exactly one attribute must be modified per request", nil)
+	}
+	return &ec2.ModifyVpcAttributeOutput{}, nil
+}
+
+// AssociateVpcCidrBlockWithContext implements EC2API.
+// Only IPv4 CIDR blocks are supported.
+func (f *EC2) AssociateVpcCidrBlockWithContext(_ aws.Context, in *ec2.AssociateVpcCidrBlockInput, _ ...request.Option) (*ec2.AssociateVpcCidrBlockOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.findVpc(aws.StringValue(in.VpcId))
+	if i < 0 {
+		return nil, notFound("InvalidVpcID.NotFound", aws.StringValue(in.VpcId))
+	}
+	if in.CidrBlock == nil {
+		return nil, missingParameter("CidrBlock")
+	}
+
+	vpc := f.vpcs[i]
+	for _, as := range vpc.CidrBlockAssociationSet {
+		if aws.StringValue(as.CidrBlock) == *in.CidrBlock {
+			return nil, awserr.New("InvalidVpc.Range", fmt.Sprintf("The CIDR '%s' conflicts with another CIDR of the vpc", *in.CidrBlock), nil)
+		}
+	}
+
+	as := &ec2.VpcCidrBlockAssociation{
+		AssociationId:  aws.String(f.newID("vpc-cidr-assoc")),
+		CidrBlock:      in.CidrBlock,
+		CidrBlockState: &ec2.VpcCidrBlockState{State: aws.String(ec2.VpcCidrBlockStateCodeAssociated)},
+	}
+	vpc.CidrBlockAssociationSet = append(vpc.CidrBlockAssociationSet, as)
+
+	// CIDR blocks are reported as associating once, and associated afterwards.
+	out := awsutil.CopyOf(as).(*ec2.VpcCidrBlockAssociation)
+	out.CidrBlockState.State = aws.String(ec2.VpcCidrBlockStateCodeAssociating)
+	return &ec2.AssociateVpcCidrBlockOutput{VpcId: in.VpcId, CidrBlockAssociation: out}, nil
+}
+
+// CreateSubnetWithContext implements EC2API.
+func (f *EC2) CreateSubnetWithContext(_ aws.Context, in *ec2.CreateSubnetInput, _ ...request.Option) (*ec2.CreateSubnetOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if in.CidrBlock == nil {
+		return nil, missingParameter("CidrBlock")
+	}
+
+	if f.findVpc(aws.StringValue(in.VpcId)) < 0 {
+		return nil, notFound("InvalidVpcID.NotFound", aws.StringValue(in.VpcId))
+	}
+
+	zone := aws.StringValue(in.AvailabilityZone)
+	if zone == "" && len(f.AvailabilityZones) > 0 {
+		zone = f.AvailabilityZones[0]
+	}
+
+	sn := &ec2.Subnet{
+		SubnetId:            aws.String(f.newID("subnet")),
+		VpcId:               in.VpcId,
+		CidrBlock:           in.CidrBlock,
+		AvailabilityZone:    aws.String(zone),
+		MapPublicIpOnLaunch: aws.Bool(false),
+		State:               aws.String(ec2.SubnetStateAvailable),
+	}
+	f.subnets = append(f.subnets, sn)
+
+	return &ec2.CreateSubnetOutput{Subnet: f.copySubnet(sn)}, nil
+}
+
+// DeleteSubnetWithContext implements EC2API.
+func (f *EC2) DeleteSubnetWithContext(_ aws.Context, in *ec2.DeleteSubnetInput, _ ...request.Option) (*ec2.DeleteSubnetOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.findSubnet(aws.StringValue(in.SubnetId))
+	if i < 0 {
+		return nil, notFound("InvalidSubnetID.NotFound", aws.StringValue(in.SubnetId))
+	}
+
+	for _, ng := range f.natGateways {
+		if aws.StringValue(ng.SubnetId) == aws.StringValue(in.SubnetId) && aws.StringValue(ng.State) != ec2.NatGatewayStateDeleted {
+			return nil, dependencyViolation(fmt.Sprintf("The subnet '%s' has dependencies and cannot be deleted.", aws.StringValue(in.SubnetId)))
+		}
+	}
+
+	f.subnets = append(f.subnets[:i], f.subnets[i+1:]...)
+	delete(f.tags, aws.StringValue(in.SubnetId))
+	return &ec2.DeleteSubnetOutput{}, nil
+}
+
+// DescribeSubnetsWithContext implements EC2API.
+func (f *EC2) DescribeSubnetsWithContext(_ aws.Context, in *ec2.DescribeSubnetsInput, _ ...request.Option) (*ec2.DescribeSubnetsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := &ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{}}
+	for _, id := range in.SubnetIds {
+		if f.findSubnet(aws.StringValue(id)) < 0 {
+			return nil, notFound("InvalidSubnetID.NotFound", aws.StringValue(id))
+		}
+	}
+
+	for _, sn := range f.subnets {
+		if !containsID(in.SubnetIds, sn.SubnetId) {
+			continue
+		}
+
+		ok, err := f.match(*sn.SubnetId, in.Filters, map[string][]string{
+			"subnet-id":         {aws.StringValue(sn.SubnetId)},
+			"vpc-id":            {aws.StringValue(sn.VpcId)},
+			"cidr-block":        {aws.StringValue(sn.CidrBlock)},
+			"availability-zone": {aws.StringValue(sn.AvailabilityZone)},
+			"state":             {aws.StringValue(sn.State)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out.Subnets = append(out.Subnets, f.copySubnet(sn))
+		}
+	}
+
+	return out, nil
+}
+
+// ModifySubnetAttributeWithContext implements EC2API.
+func (f *EC2) ModifySubnetAttributeWithContext(_ aws.Context, in *ec2.ModifySubnetAttributeInput, _ ...request.Option) (*ec2.ModifySubnetAttributeOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.findSubnet(aws.StringValue(in.SubnetId))
+	if i < 0 {
+		return nil, notFound("InvalidSubnetID.NotFound", aws.StringValue(in.SubnetId))
+	}
+
+	if in.MapPublicIpOnLaunch != nil {
+		f.subnets[i].MapPublicIpOnLaunch = aws.Bool(aws.BoolValue(in.MapPublicIpOnLaunch.Value))
+	}
+
+	return &ec2.ModifySubnetAttributeOutput{}, nil
+}
+
+// WaitUntilSubnetAvailableWithContext implements EC2API.
+// Resources are available as soon as they are created.
+func (f *EC2) WaitUntilSubnetAvailableWithContext(ctx aws.Context, in *ec2.DescribeSubnetsInput, _ ...request.WaiterOption) error {
+	_, err := f.DescribeSubnetsWithContext(ctx, in)
+	return err
+}
+
+// DescribeAvailabilityZonesWithContext implements EC2API.
+func (f *EC2) DescribeAvailabilityZonesWithContext(_ aws.Context, in *ec2.DescribeAvailabilityZonesInput, _ ...request.Option) (*ec2.DescribeAvailabilityZonesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := &ec2.DescribeAvailabilityZonesOutput{AvailabilityZones: []*ec2.AvailabilityZone{}}
+	for _, zone := range f.AvailabilityZones {
+		ok, err := f.match(zone, in.Filters, map[string][]string{
+			"zone-name": {zone},
+			"state":     {ec2.AvailabilityZoneStateAvailable},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out.AvailabilityZones = append(out.AvailabilityZones, &ec2.AvailabilityZone{
+				ZoneName: aws.String(zone),
+				State:    aws.String(ec2.AvailabilityZoneStateAvailable),
+			})
+		}
+	}
+
+	return out, nil
+}
+
+// CreateInternetGatewayWithContext implements EC2API.
+func (f *EC2) CreateInternetGatewayWithContext(_ aws.Context, in *ec2.CreateInternetGatewayInput, _ ...request.Option) (*ec2.CreateInternetGatewayOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ig := &ec2.InternetGateway{
+		InternetGatewayId: aws.String(f.newID("igw")),
+	}
+	f.internetGateways = append(f.internetGateways, ig)
+
+	return &ec2.CreateInternetGatewayOutput{InternetGateway: f.copyInternetGateway(ig)}, nil
+}
+
+// AttachInternetGatewayWithContext implements EC2API.
+func (f *EC2) AttachInternetGatewayWithContext(_ aws.Context, in *ec2.AttachInternetGatewayInput, _ ...request.Option) (*ec2.AttachInternetGatewayOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.findInternetGateway(aws.StringValue(in.InternetGatewayId))
+	if i < 0 {
+		return nil, notFound("InvalidInternetGatewayID.NotFound", aws.StringValue(in.InternetGatewayId))
+	}
+
+	if f.findVpc(aws.StringValue(in.VpcId)) < 0 {
+		return nil, notFound("InvalidVpcID.NotFound", aws.StringValue(in.VpcId))
+	}
+
+	ig := f.internetGateways[i]
+	if len(ig.Attachments) > 0 {
+		return nil, awserr.New("Resource.AlreadyAssociated", fmt.Sprintf("resource %s is already attached to network %s", *ig.InternetGatewayId, *ig.Attachments[0].VpcId), nil)
+	}
+
+	ig.Attachments = []*ec2.InternetGatewayAttachment{{
+		VpcId: in.VpcId,
+		State: aws.String(ec2.AttachmentStatusAttached),
+	}}
+
+	return &ec2.AttachInternetGatewayOutput{}, nil
+}
+
+// DetachInternetGatewayWithContext implements EC2API.
+func (f *EC2) DetachInternetGatewayWithContext(_ aws.Context, in *ec2.DetachInternetGatewayInput, _ ...request.Option) (*ec2.DetachInternetGatewayOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.findInternetGateway(aws.StringValue(in.InternetGatewayId))
+	if i < 0 {
+		return nil, notFound("InvalidInternetGatewayID.NotFound", aws.StringValue(in.InternetGatewayId))
+	}
+
+	ig := f.internetGateways[i]
+	if len(ig.Attachments) == 0 || aws.StringValue(ig.Attachments[0].VpcId) != aws.StringValue(in.VpcId) {
+		return nil, awserr.New("Gateway.NotAttached", fmt.Sprintf("resource %s is not attached to network %s", *ig.InternetGatewayId, aws.StringValue(in.VpcId)), nil)
+	}
+
+	// Like AWS, gateways can't be detached while public addresses of NAT gateways are mapped in the vpc.
+	for _, ng := range f.natGateways {
+		if aws.StringValue(ng.VpcId) == aws.StringValue(in.VpcId) && aws.StringValue(ng.State) != ec2.NatGatewayStateDeleted {
+			return nil, dependencyViolation(fmt.Sprintf("Network %s has some mapped public address(es). Please unmap those public address(es) before detaching the gateway.", aws.StringValue(in.VpcId)))
+		}
+	}
+
+	ig.Attachments = nil
+	return &ec2.DetachInternetGatewayOutput{}, nil
+}
+
+// DeleteInternetGatewayWithContext implements EC2API.
+func (f *EC2) DeleteInternetGatewayWithContext(_ aws.Context, in *ec2.DeleteInternetGatewayInput, _ ...request.Option) (*ec2.DeleteInternetGatewayOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.findInternetGateway(aws.StringValue(in.InternetGatewayId))
+	if i < 0 {
+		return nil, notFound("InvalidInternetGatewayID.NotFound", aws.StringValue(in.InternetGatewayId))
+	}
+
+	if len(f.internetGateways[i].Attachments) > 0 {
+		return nil, dependencyViolation(fmt.Sprintf("The internetGateway '%s' has dependencies and cannot be deleted.", aws.StringValue(in.InternetGatewayId)))
+	}
+
+	f.internetGateways = append(f.internetGateways[:i], f.internetGateways[i+1:]...)
+	delete(f.tags, aws.StringValue(in.InternetGatewayId))
+	return &ec2.DeleteInternetGatewayOutput{}, nil
+}
+
+// DescribeInternetGatewaysWithContext implements EC2API.
+func (f *EC2) DescribeInternetGatewaysWithContext(_ aws.Context, in *ec2.DescribeInternetGatewaysInput, _ ...request.Option) (*ec2.DescribeInternetGatewaysOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := &ec2.DescribeInternetGatewaysOutput{InternetGateways: []*ec2.InternetGateway{}}
+	for _, id := range in.InternetGatewayIds {
+		if f.findInternetGateway(aws.StringValue(id)) < 0 {
+			return nil, notFound("InvalidInternetGatewayID.NotFound", aws.StringValue(id))
+		}
+	}
+
+	for _, ig := range f.internetGateways {
+		if !containsID(in.InternetGatewayIds, ig.InternetGatewayId) {
+			continue
+		}
+
+		attrs := map[string][]string{
+			"internet-gateway-id": {aws.StringValue(ig.InternetGatewayId)},
+			"attachment.vpc-id":   {},
+			"attachment.state":    {},
+		}
+		for _, att := range ig.Attachments {
+			attrs["attachment.vpc-id"] = append(attrs["attachment.vpc-id"], aws.StringValue(att.VpcId))
+			attrs["attachment.state"] = append(attrs["attachment.state"], aws.StringValue(att.State))
+		}
+
+		ok, err := f.match(*ig.InternetGatewayId, in.Filters, attrs)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out.InternetGateways = append(out.InternetGateways, f.copyInternetGateway(ig))
+		}
+	}
+
+	return out, nil
+}
+
+// CreateEgressOnlyInternetGatewayWithContext implements EC2API.
+func (f *EC2) CreateEgressOnlyInternetGatewayWithContext(_ aws.Context, in *ec2.CreateEgressOnlyInternetGatewayInput, _ ...request.Option) (*ec2.CreateEgressOnlyInternetGatewayOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.findVpc(aws.StringValue(in.VpcId)) < 0 {
+		return nil, notFound("InvalidVpcID.NotFound", aws.StringValue(in.VpcId))
+	}
+
+	eigw := &ec2.EgressOnlyInternetGateway{
+		EgressOnlyInternetGatewayId: aws.String(f.newID("eigw")),
+		Attachments: []*ec2.InternetGatewayAttachment{{
+			VpcId: in.VpcId,
+			State: aws.String(ec2.AttachmentStatusAttached),
+		}},
+	}
+	f.egressGateways = append(f.egressGateways, eigw)
+
+	return &ec2.CreateEgressOnlyInternetGatewayOutput{
+		EgressOnlyInternetGateway: awsutil.CopyOf(eigw).(*ec2.EgressOnlyInternetGateway),
+	}, nil
+}
+
+// DeleteEgressOnlyInternetGatewayWithContext implements EC2API.
+func (f *EC2) DeleteEgressOnlyInternetGatewayWithContext(_ aws.Context, in *ec2.DeleteEgressOnlyInternetGatewayInput, _ ...request.Option) (*ec2.DeleteEgressOnlyInternetGatewayOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, eigw := range f.egressGateways {
+		if aws.StringValue(eigw.EgressOnlyInternetGatewayId) == aws.StringValue(in.EgressOnlyInternetGatewayId) {
+			f.egressGateways = append(f.egressGateways[:i], f.egressGateways[i+1:]...)
+			return &ec2.DeleteEgressOnlyInternetGatewayOutput{ReturnCode: aws.Bool(true)}, nil
+		}
+	}
+
+	return nil, notFound("InvalidGatewayID.NotFound", aws.StringValue(in.EgressOnlyInternetGatewayId))
+}
+
+// DescribeEgressOnlyInternetGatewaysWithContext implements EC2API.
+// Pages hold MaxResults gateways, or all of them if MaxResults is not set.
+func (f *EC2) DescribeEgressOnlyInternetGatewaysWithContext(_ aws.Context, in *ec2.DescribeEgressOnlyInternetGatewaysInput, _ ...request.Option) (*ec2.DescribeEgressOnlyInternetGatewaysOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var matching []*ec2.EgressOnlyInternetGateway
+	for _, eigw := range f.egressGateways {
+		if containsID(in.EgressOnlyInternetGatewayIds, eigw.EgressOnlyInternetGatewayId) {
+			matching = append(matching, eigw)
+		}
+	}
+
+	start := 0
+	if in.NextToken != nil {
+		if _, err := fmt.Sscanf(*in.NextToken, "%d", &start); err != nil || start > len(matching) {
+			return nil, awserr.New("InvalidNextToken", fmt.Sprintf("The token '%s' is invalid.", *in.NextToken), nil)
+		}
+	}
+	end := len(matching)
+	if in.MaxResults != nil && start+int(*in.MaxResults) < end {
+		end = start + int(*in.MaxResults)
+	}
+
+	out := &ec2.DescribeEgressOnlyInternetGatewaysOutput{EgressOnlyInternetGateways: []*ec2.EgressOnlyInternetGateway{}}
+	for _, eigw := range matching[start:end] {
+		out.EgressOnlyInternetGateways = append(out.EgressOnlyInternetGateways, awsutil.CopyOf(eigw).(*ec2.EgressOnlyInternetGateway))
+	}
+	if end < len(matching) {
+		out.NextToken = aws.String(fmt.Sprintf("%d", end))
+	}
+
+	return out, nil
+}
+
+// AllocateAddressWithContext implements EC2API.
+func (f *EC2) AllocateAddressWithContext(_ aws.Context, in *ec2.AllocateAddressInput, _ ...request.Option) (*ec2.AllocateAddressOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	domain := aws.StringValue(in.Domain)
+	if domain == "" {
+		domain = ec2.DomainTypeStandard
+	}
+
+	addr := &ec2.Address{
+		AllocationId: aws.String(f.newID("eipalloc")),
+		// Addresses are taken from TEST-NET-3 (RFC 5737).
+		PublicIp: aws.String(fmt.Sprintf("203.0.113.%d", len(f.addresses)%254+1)),
+		Domain:   aws.String(domain),
+	}
+	f.addresses = append(f.addresses, addr)
+
+	return &ec2.AllocateAddressOutput{
+		AllocationId: addr.AllocationId,
+		PublicIp:     addr.PublicIp,
+		Domain:       addr.Domain,
+	}, nil
+}
+
+// DescribeAddressesWithContext implements EC2API.
+func (f *EC2) DescribeAddressesWithContext(_ aws.Context, in *ec2.DescribeAddressesInput, _ ...request.Option) (*ec2.DescribeAddressesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, id := range in.AllocationIds {
+		if f.findAddress(aws.StringValue(id)) < 0 {
+			return nil, notFound("InvalidAllocationID.NotFound", aws.StringValue(id))
+		}
+	}
+
+	out := &ec2.DescribeAddressesOutput{Addresses: []*ec2.Address{}}
+	for _, addr := range f.addresses {
+		if !containsID(in.AllocationIds, addr.AllocationId) {
+			continue
+		}
+
+		ok, err := f.match(*addr.AllocationId, in.Filters, map[string][]string{
+			"allocation-id":        {aws.StringValue(addr.AllocationId)},
+			"association-id":       {aws.StringValue(addr.AssociationId)},
+			"domain":               {aws.StringValue(addr.Domain)},
+			"network-interface-id": {aws.StringValue(addr.NetworkInterfaceId)},
+			"public-ip":            {aws.StringValue(addr.PublicIp)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out.Addresses = append(out.Addresses, f.copyAddress(addr))
+		}
+	}
+
+	return out, nil
+}
+
+// ReleaseAddressWithContext implements EC2API.
+func (f *EC2) ReleaseAddressWithContext(_ aws.Context, in *ec2.ReleaseAddressInput, _ ...request.Option) (*ec2.ReleaseAddressOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.findAddress(aws.StringValue(in.AllocationId))
+	if i < 0 {
+		return nil, notFound("InvalidAllocationID.NotFound", aws.StringValue(in.AllocationId))
+	}
+
+	if f.addresses[i].AssociationId != nil {
+		return nil, awserr.New("InvalidIPAddress.InUse", fmt.Sprintf("Address %s is in use.", aws.StringValue(f.addresses[i].PublicIp)), nil)
+	}
+
+	f.addresses = append(f.addresses[:i], f.addresses[i+1:]...)
+	delete(f.tags, aws.StringValue(in.AllocationId))
+	return &ec2.ReleaseAddressOutput{}, nil
+}
+
+// CreateNatGatewayWithContext implements EC2API.
+// Requests with the client token of an earlier request return its output.
+func (f *EC2) CreateNatGatewayWithContext(_ aws.Context, in *ec2.CreateNatGatewayInput, _ ...request.Option) (*ec2.CreateNatGatewayOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if out, ok := f.clientTokens[aws.StringValue(in.ClientToken)].(*ec2.CreateNatGatewayOutput); ok {
+		return out, nil
+	}
+
+	i := f.findSubnet(aws.StringValue(in.SubnetId))
+	if i < 0 {
+		return nil, notFound("InvalidSubnetID.NotFound", aws.StringValue(in.SubnetId))
+	}
+
+	var addr *ec2.Address
+	for _, a := range f.addresses {
+		if aws.StringValue(a.AllocationId) == aws.StringValue(in.AllocationId) {
+			addr = a
+		}
+	}
+	if addr == nil {
+		return nil, notFound("InvalidAllocationID.NotFound", aws.StringValue(in.AllocationId))
+	}
+	if addr.AssociationId != nil {
+		return nil, awserr.New("Resource.AlreadyAssociated", fmt.Sprintf("Elastic IP address [%s] is already associated", aws.StringValue(addr.AllocationId)), nil)
+	}
+	addr.AssociationId = aws.String(f.newID("eipassoc"))
+	addr.NetworkInterfaceId = aws.String(f.newID("eni"))
+
+	ng := &ec2.NatGateway{
+		NatGatewayId: aws.String(f.newID("nat")),
+		SubnetId:     in.SubnetId,
+		VpcId:        f.subnets[i].VpcId,
+		State:        aws.String(ec2.NatGatewayStateAvailable),
+		CreateTime:   aws.Time(time.Now()),
+		NatGatewayAddresses: []*ec2.NatGatewayAddress{{
+			AllocationId: addr.AllocationId,
+			PublicIp:     addr.PublicIp,
+		}},
+	}
+	f.natGateways = append(f.natGateways, ng)
+
+	// NAT gateways are reported as pending once, and available afterwards.
+	out := f.copyNatGateway(ng)
+	out.State = aws.String(ec2.NatGatewayStatePending)
+	res := &ec2.CreateNatGatewayOutput{NatGateway: out}
+	if token := aws.StringValue(in.ClientToken); token != "" {
+		f.clientTokens[token] = res
+	}
+	return res, nil
+}
+
+// DeleteNatGatewayWithContext implements EC2API.
+// Gateways are deleted immediately, their addresses are disassociated.
+func (f *EC2) DeleteNatGatewayWithContext(_ aws.Context, in *ec2.DeleteNatGatewayInput, _ ...request.Option) (*ec2.DeleteNatGatewayOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var ng *ec2.NatGateway
+	for _, n := range f.natGateways {
+		if aws.StringValue(n.NatGatewayId) == aws.StringValue(in.NatGatewayId) {
+			ng = n
+		}
+	}
+	if ng == nil || aws.StringValue(ng.State) == ec2.NatGatewayStateDeleted {
+		return nil, notFound("NatGatewayNotFound", aws.StringValue(in.NatGatewayId))
+	}
+
+	ng.State = aws.String(ec2.NatGatewayStateDeleted)
+	for _, na := range ng.NatGatewayAddresses {
+		if i := f.findAddress(aws.StringValue(na.AllocationId)); i >= 0 {
+			f.addresses[i].AssociationId = nil
+			f.addresses[i].NetworkInterfaceId = nil
+		}
+	}
+
+	return &ec2.DeleteNatGatewayOutput{NatGatewayId: ng.NatGatewayId}, nil
+}
+
+// DescribeNatGatewaysPagesWithContext implements EC2API.
+// All matching gateways are returned in a single page.
+func (f *EC2) DescribeNatGatewaysPagesWithContext(_ aws.Context, in *ec2.DescribeNatGatewaysInput, fn func(*ec2.DescribeNatGatewaysOutput, bool) bool, _ ...request.Option) error {
+	out, err := f.describeNatGateways(in)
+	if err != nil {
+		return err
+	}
+
+	fn(out, true)
+	return nil
+}
+
+func (f *EC2) describeNatGateways(in *ec2.DescribeNatGatewaysInput) (*ec2.DescribeNatGatewaysOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := &ec2.DescribeNatGatewaysOutput{NatGateways: []*ec2.NatGateway{}}
+	for _, ng := range f.natGateways {
+		if !containsID(in.NatGatewayIds, ng.NatGatewayId) {
+			continue
+		}
+
+		ok, err := f.match(*ng.NatGatewayId, in.Filter, map[string][]string{
+			"nat-gateway-id": {aws.StringValue(ng.NatGatewayId)},
+			"subnet-id":      {aws.StringValue(ng.SubnetId)},
+			"vpc-id":         {aws.StringValue(ng.VpcId)},
+			"state":          {aws.StringValue(ng.State)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out.NatGateways = append(out.NatGateways, f.copyNatGateway(ng))
+		}
+	}
+
+	return out, nil
+}
+
+// CreateRouteTableWithContext implements EC2API.
+func (f *EC2) CreateRouteTableWithContext(_ aws.Context, in *ec2.CreateRouteTableInput, _ ...request.Option) (*ec2.CreateRouteTableOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.findVpc(aws.StringValue(in.VpcId))
+	if i < 0 {
+		return nil, notFound("InvalidVpcID.NotFound", aws.StringValue(in.VpcId))
+	}
+
+	rt := &ec2.RouteTable{
+		RouteTableId: aws.String(f.newID("rtb")),
+		VpcId:        in.VpcId,
+		Routes: []*ec2.Route{{
+			DestinationCidrBlock: f.vpcs[i].CidrBlock,
+			GatewayId:            aws.String("local"),
+			State:                aws.String(ec2.RouteStateActive),
+			Origin:               aws.String(ec2.RouteOriginCreateRouteTable),
+		}},
+	}
+	f.routeTables = append(f.routeTables, rt)
+
+	return &ec2.CreateRouteTableOutput{RouteTable: f.copyRouteTable(rt)}, nil
+}
+
+// DeleteRouteTableWithContext implements EC2API.
+func (f *EC2) DeleteRouteTableWithContext(_ aws.Context, in *ec2.DeleteRouteTableInput, _ ...request.Option) (*ec2.DeleteRouteTableOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.findRouteTable(aws.StringValue(in.RouteTableId))
+	if i < 0 {
+		return nil, notFound("InvalidRouteTableID.NotFound", aws.StringValue(in.RouteTableId))
+	}
+
+	if len(f.routeTables[i].Associations) > 0 {
+		return nil, dependencyViolation(fmt.Sprintf("The routeTable '%s' has dependencies and cannot be deleted.", aws.StringValue(in.RouteTableId)))
+	}
+
+	f.routeTables = append(f.routeTables[:i], f.routeTables[i+1:]...)
+	delete(f.tags, aws.StringValue(in.RouteTableId))
+	return &ec2.DeleteRouteTableOutput{}, nil
+}
+
+// CreateRouteWithContext implements EC2API.
+func (f *EC2) CreateRouteWithContext(_ aws.Context, in *ec2.CreateRouteInput, _ ...request.Option) (*ec2.CreateRouteOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.findRouteTable(aws.StringValue(in.RouteTableId))
+	if i < 0 {
+		return nil, notFound("InvalidRouteTableID.NotFound", aws.StringValue(in.RouteTableId))
+	}
+
+	rt := f.routeTables[i]
+	for _, r := range rt.Routes {
+		if in.DestinationCidrBlock != nil && aws.StringValue(r.DestinationCidrBlock) == *in.DestinationCidrBlock {
+			return nil, awserr.New("RouteAlreadyExists", fmt.Sprintf("The route identified by %s already exists.", *in.DestinationCidrBlock), nil)
+		}
+		if in.DestinationIpv6CidrBlock != nil && aws.StringValue(r.DestinationIpv6CidrBlock) == *in.DestinationIpv6CidrBlock {
+			return nil, awserr.New("RouteAlreadyExists", fmt.Sprintf("The route identified by %s already exists.", *in.DestinationIpv6CidrBlock), nil)
+		}
+	}
+
+	rt.Routes = append(rt.Routes, &ec2.Route{
+		DestinationCidrBlock:        in.DestinationCidrBlock,
+		DestinationIpv6CidrBlock:    in.DestinationIpv6CidrBlock,
+		EgressOnlyInternetGatewayId: in.EgressOnlyInternetGatewayId,
+		GatewayId:                   in.GatewayId,
+		InstanceId:                  in.InstanceId,
+		NatGatewayId:                in.NatGatewayId,
+		NetworkInterfaceId:          in.NetworkInterfaceId,
+		VpcPeeringConnectionId:      in.VpcPeeringConnectionId,
+		State:                       aws.String(ec2.RouteStateActive),
+		Origin:                      aws.String(ec2.RouteOriginCreateRoute),
+	})
+
+	return &ec2.CreateRouteOutput{Return: aws.Bool(true)}, nil
+}
+
+// ReplaceRouteWithContext implements EC2API.
+func (f *EC2) ReplaceRouteWithContext(_ aws.Context, in *ec2.ReplaceRouteInput, _ ...request.Option) (*ec2.ReplaceRouteOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.findRouteTable(aws.StringValue(in.RouteTableId))
+	if i < 0 {
+		return nil, notFound("InvalidRouteTableID.NotFound", aws.StringValue(in.RouteTableId))
+	}
+
+	for _, r := range f.routeTables[i].Routes {
+		if (in.DestinationCidrBlock != nil && aws.StringValue(r.DestinationCidrBlock) == *in.DestinationCidrBlock) ||
+			(in.DestinationIpv6CidrBlock != nil && aws.StringValue(r.DestinationIpv6CidrBlock) == *in.DestinationIpv6CidrBlock) {
+			*r = ec2.Route{
+				DestinationCidrBlock:        in.DestinationCidrBlock,
+				DestinationIpv6CidrBlock:    in.DestinationIpv6CidrBlock,
+				EgressOnlyInternetGatewayId: in.EgressOnlyInternetGatewayId,
+				GatewayId:                   in.GatewayId,
+				InstanceId:                  in.InstanceId,
+				NatGatewayId:                in.NatGatewayId,
+				NetworkInterfaceId:          in.NetworkInterfaceId,
+				VpcPeeringConnectionId:      in.VpcPeeringConnectionId,
+				State:                       aws.String(ec2.RouteStateActive),
+				Origin:                      aws.String(ec2.RouteOriginCreateRoute),
+			}
+			return &ec2.ReplaceRouteOutput{}, nil
+		}
+	}
+
+	return nil, notFound("InvalidRoute.NotFound", aws.StringValue(in.DestinationCidrBlock)+aws.StringValue(in.DestinationIpv6CidrBlock))
+}
+
+// AssociateRouteTableWithContext implements EC2API.
+func (f *EC2) AssociateRouteTableWithContext(_ aws.Context, in *ec2.AssociateRouteTableInput, _ ...request.Option) (*ec2.AssociateRouteTableOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.findRouteTable(aws.StringValue(in.RouteTableId))
+	if i < 0 {
+		return nil, notFound("InvalidRouteTableID.NotFound", aws.StringValue(in.RouteTableId))
+	}
+
+	if f.findSubnet(aws.StringValue(in.SubnetId)) < 0 {
+		return nil, notFound("InvalidSubnetID.NotFound", aws.StringValue(in.SubnetId))
+	}
+
+	// A subnet can only be associated with a single route table.
+	for _, rt := range f.routeTables {
+		for _, as := range rt.Associations {
+			if aws.StringValue(as.SubnetId) == aws.StringValue(in.SubnetId) {
+				return nil, awserr.New("Resource.AlreadyAssociated", fmt.Sprintf("the specified association for route table %s conflicts with an existing association", *rt.RouteTableId), nil)
+			}
+		}
+	}
+
+	as := &ec2.RouteTableAssociation{
+		RouteTableAssociationId: aws.String(f.newID("rtbassoc")),
+		RouteTableId:            in.RouteTableId,
+		SubnetId:                in.SubnetId,
+		Main:                    aws.Bool(false),
+	}
+	f.routeTables[i].Associations = append(f.routeTables[i].Associations, as)
+
+	return &ec2.AssociateRouteTableOutput{AssociationId: as.RouteTableAssociationId}, nil
+}
+
+// DisassociateRouteTableWithContext implements EC2API.
+func (f *EC2) DisassociateRouteTableWithContext(_ aws.Context, in *ec2.DisassociateRouteTableInput, _ ...request.Option) (*ec2.DisassociateRouteTableOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, rt := range f.routeTables {
+		for i, as := range rt.Associations {
+			if aws.StringValue(as.RouteTableAssociationId) == aws.StringValue(in.AssociationId) {
+				rt.Associations = append(rt.Associations[:i], rt.Associations[i+1:]...)
+				return &ec2.DisassociateRouteTableOutput{}, nil
+			}
+		}
+	}
+
+	return nil, notFound("InvalidAssociationID.NotFound", aws.StringValue(in.AssociationId))
+}
+
+// DescribeRouteTablesWithContext implements EC2API.
+func (f *EC2) DescribeRouteTablesWithContext(_ aws.Context, in *ec2.DescribeRouteTablesInput, _ ...request.Option) (*ec2.DescribeRouteTablesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := &ec2.DescribeRouteTablesOutput{RouteTables: []*ec2.RouteTable{}}
+	for _, id := range in.RouteTableIds {
+		if f.findRouteTable(aws.StringValue(id)) < 0 {
+			return nil, notFound("InvalidRouteTableID.NotFound", aws.StringValue(id))
+		}
+	}
+
+	for _, rt := range f.routeTables {
+		if !containsID(in.RouteTableIds, rt.RouteTableId) {
+			continue
+		}
+
+		attrs := map[string][]string{
+			"route-table-id":               {aws.StringValue(rt.RouteTableId)},
+			"vpc-id":                       {aws.StringValue(rt.VpcId)},
+			"association.subnet-id":        {},
+			"route.gateway-id":             {},
+			"route.nat-gateway-id":         {},
+			"route.destination-cidr-block": {},
+		}
+		for _, as := range rt.Associations {
+			attrs["association.subnet-id"] = append(attrs["association.subnet-id"], aws.StringValue(as.SubnetId))
+		}
+		for _, r := range rt.Routes {
+			attrs["route.gateway-id"] = append(attrs["route.gateway-id"], aws.StringValue(r.GatewayId))
+			attrs["route.nat-gateway-id"] = append(attrs["route.nat-gateway-id"], aws.StringValue(r.NatGatewayId))
+			attrs["route.destination-cidr-block"] = append(attrs["route.destination-cidr-block"], aws.StringValue(r.DestinationCidrBlock))
+		}
+
+		ok, err := f.match(*rt.RouteTableId, in.Filters, attrs)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out.RouteTables = append(out.RouteTables, f.copyRouteTable(rt))
+		}
+	}
+
+	return out, nil
+}
+
+// CreateVpcEndpoint implements ec2iface.EC2API, so that tests can set up endpoints.
+// Endpoints are reported as pending once, and available afterwards.
+func (f *EC2) CreateVpcEndpoint(in *ec2.CreateVpcEndpointInput) (*ec2.CreateVpcEndpointOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.findVpc(aws.StringValue(in.VpcId)) < 0 {
+		return nil, notFound("InvalidVpcID.NotFound", aws.StringValue(in.VpcId))
+	}
+	if in.ServiceName == nil {
+		return nil, missingParameter("ServiceName")
+	}
+
+	ep := &ec2.VpcEndpoint{
+		VpcEndpointId:   aws.String(f.newID("vpce")),
+		VpcEndpointType: in.VpcEndpointType,
+		VpcId:           in.VpcId,
+		ServiceName:     in.ServiceName,
+		RouteTableIds:   in.RouteTableIds,
+		SubnetIds:       in.SubnetIds,
+		State:           aws.String(ec2.StatePending),
+	}
+	f.vpcEndpoints = append(f.vpcEndpoints, ep)
+
+	out := awsutil.CopyOf(ep).(*ec2.VpcEndpoint)
+	ep.State = aws.String(ec2.StateAvailable)
+	return &ec2.CreateVpcEndpointOutput{VpcEndpoint: out}, nil
+}
+
+// DescribeVpcEndpointsWithContext implements EC2API.
+func (f *EC2) DescribeVpcEndpointsWithContext(_ aws.Context, in *ec2.DescribeVpcEndpointsInput, _ ...request.Option) (*ec2.DescribeVpcEndpointsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := &ec2.DescribeVpcEndpointsOutput{VpcEndpoints: []*ec2.VpcEndpoint{}}
+	for _, ep := range f.vpcEndpoints {
+		if !containsID(in.VpcEndpointIds, ep.VpcEndpointId) {
+			continue
+		}
+
+		ok, err := f.match(*ep.VpcEndpointId, in.Filters, map[string][]string{
+			"vpc-endpoint-id":    {aws.StringValue(ep.VpcEndpointId)},
+			"vpc-id":             {aws.StringValue(ep.VpcId)},
+			"service-name":       {aws.StringValue(ep.ServiceName)},
+			"vpc-endpoint-state": {aws.StringValue(ep.State)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out.VpcEndpoints = append(out.VpcEndpoints, awsutil.CopyOf(ep).(*ec2.VpcEndpoint))
+		}
+	}
+
+	return out, nil
+}
+
+// RunInstancesWithContext implements EC2API.
+// Requests with the client token of an earlier request return its reservation.
+func (f *EC2) RunInstancesWithContext(_ aws.Context, in *ec2.RunInstancesInput, _ ...request.Option) (*ec2.Reservation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if res, ok := f.clientTokens[aws.StringValue(in.ClientToken)].(*ec2.Reservation); ok {
+		return res, nil
+	}
+
+	if in.SubnetId != nil && f.findSubnet(*in.SubnetId) < 0 {
+		return nil, notFound("InvalidSubnetID.NotFound", *in.SubnetId)
+	}
+
+	imageID, instanceType := in.ImageId, in.InstanceType
+	// Like the images of DescribeImages, instances have the root device /dev/xvda, a gp2 volume
+	// of 8 GiB.
+	rootDeviceSize, rootVolumeType, rootIOPS := int64(8), aws.String(ec2.VolumeTypeGp2), (*int64)(nil)
+	for _, bdm := range in.BlockDeviceMappings {
+		if aws.StringValue(bdm.DeviceName) == "/dev/xvda" && bdm.Ebs != nil {
+			if bdm.Ebs.VolumeSize != nil {
+				rootDeviceSize = *bdm.Ebs.VolumeSize
+			}
+			if bdm.Ebs.VolumeType != nil {
+				rootVolumeType = bdm.Ebs.VolumeType
+			}
+			rootIOPS = bdm.Ebs.Iops
+		}
+	}
+	var version *ec2.LaunchTemplateVersion
+	if in.LaunchTemplate != nil {
+		var err error
+		version, err = f.findLaunchTemplateVersion(in.LaunchTemplate.LaunchTemplateId, in.LaunchTemplate.LaunchTemplateName, aws.StringValue(in.LaunchTemplate.Version))
+		if err != nil {
+			return nil, err
+		}
+
+		// Parameters of the request override the ones of the launch template.
+		if imageID == nil {
+			imageID = version.LaunchTemplateData.ImageId
+		}
+		if instanceType == nil {
+			instanceType = version.LaunchTemplateData.InstanceType
+		}
+		for _, bdm := range version.LaunchTemplateData.BlockDeviceMappings {
+			if aws.StringValue(bdm.DeviceName) == "/dev/xvda" && bdm.Ebs != nil && len(in.BlockDeviceMappings) == 0 {
+				if bdm.Ebs.VolumeSize != nil {
+					rootDeviceSize = *bdm.Ebs.VolumeSize
+				}
+				if bdm.Ebs.VolumeType != nil {
+					rootVolumeType = bdm.Ebs.VolumeType
+				}
+				rootIOPS = bdm.Ebs.Iops
+			}
+		}
+	}
+
+	count := int(aws.Int64Value(in.MaxCount))
+	if count < 1 {
+		count = 1
+	}
+
+	res := &ec2.Reservation{
+		ReservationId: aws.String(f.newID("r")),
+	}
+	for i := 0; i < count; i++ {
+		instance := &ec2.Instance{
+			InstanceId:   aws.String(f.newID("i")),
+			ImageId:      imageID,
+			InstanceType: instanceType,
+			KeyName:      in.KeyName,
+			SubnetId:     in.SubnetId,
+			LaunchTime:   aws.Time(time.Now()),
+			State: &ec2.InstanceState{
+				Name: aws.String(ec2.InstanceStateNamePending),
+			},
+		}
+		if in.SubnetId != nil {
+			instance.VpcId = f.subnets[f.findSubnet(*in.SubnetId)].VpcId
+		}
+		f.instances = append(f.instances, instance)
+
+		volume := &ec2.Volume{
+			VolumeId:   aws.String(f.newID("vol")),
+			Size:       aws.Int64(rootDeviceSize),
+			VolumeType: rootVolumeType,
+			Iops:       rootIOPS,
+			State:      aws.String(ec2.VolumeStateInUse),
+			Attachments: []*ec2.VolumeAttachment{{
+				InstanceId:          instance.InstanceId,
+				Device:              aws.String("/dev/xvda"),
+				State:               aws.String(ec2.VolumeAttachmentStateAttached),
+				DeleteOnTermination: aws.Bool(true),
+			}},
+		}
+		f.volumes = append(f.volumes, volume)
+		instance.RootDeviceName = aws.String("/dev/xvda")
+		instance.BlockDeviceMappings = []*ec2.InstanceBlockDeviceMapping{{
+			DeviceName: aws.String("/dev/xvda"),
+			Ebs: &ec2.EbsInstanceBlockDevice{
+				VolumeId:            volume.VolumeId,
+				Status:              aws.String(ec2.AttachmentStatusAttached),
+				DeleteOnTermination: aws.Bool(true),
+			},
+		}}
+
+		for _, spec := range in.TagSpecifications {
+			id := *instance.InstanceId
+			switch aws.StringValue(spec.ResourceType) {
+			case ec2.ResourceTypeInstance:
+			case ec2.ResourceTypeVolume:
+				id = *volume.VolumeId
+			default:
+				continue
+			}
+
+			tags := make(map[string]string)
+			for _, tag := range spec.Tags {
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+			f.tags[id] = tags
+		}
+
+		// Like AWS, instances run from a launch template are tagged with its id and version.
+		if version != nil {
+			tags, ok := f.tags[*instance.InstanceId]
+			if !ok {
+				tags = make(map[string]string)
+				f.tags[*instance.InstanceId] = tags
+			}
+			tags["aws:ec2launchtemplate:id"] = aws.StringValue(version.LaunchTemplateId)
+			tags["aws:ec2launchtemplate:version"] = strconv.FormatInt(aws.Int64Value(version.VersionNumber), 10)
+		}
+
+		res.Instances = append(res.Instances, f.copyInstance(instance))
+
+		// Instances are reported as pending once, and running afterwards.
+		instance.State = &ec2.InstanceState{
+			Name: aws.String(ec2.InstanceStateNameRunning),
+		}
+	}
+
+	if token := aws.StringValue(in.ClientToken); token != "" {
+		f.clientTokens[token] = res
+	}
+	return res, nil
+}
+
+// DescribeInstancesWithContext implements EC2API.
+func (f *EC2) DescribeInstancesWithContext(_ aws.Context, in *ec2.DescribeInstancesInput, _ ...request.Option) (*ec2.DescribeInstancesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, id := range in.InstanceIds {
+		if f.findInstance(aws.StringValue(id)) < 0 {
+			return nil, notFound("InvalidInstanceID.NotFound", aws.StringValue(id))
+		}
+	}
+
+	res := &ec2.Reservation{}
+	for _, instance := range f.instances {
+		if !containsID(in.InstanceIds, instance.InstanceId) {
+			continue
+		}
+
+		ok, err := f.match(*instance.InstanceId, in.Filters, map[string][]string{
+			"instance-id":         {aws.StringValue(instance.InstanceId)},
+			"instance-state-name": {aws.StringValue(instance.State.Name)},
+			"subnet-id":           {aws.StringValue(instance.SubnetId)},
+			"vpc-id":              {aws.StringValue(instance.VpcId)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res.Instances = append(res.Instances, f.copyInstance(instance))
+		}
+	}
+
+	out := &ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{}}
+	if len(res.Instances) > 0 {
+		out.Reservations = append(out.Reservations, res)
+	}
+
+	return out, nil
+}
+
+// GetConsoleOutputWithContext implements EC2API.
+func (f *EC2) GetConsoleOutputWithContext(_ aws.Context, in *ec2.GetConsoleOutputInput, _ ...request.Option) (*ec2.GetConsoleOutputOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.findInstance(aws.StringValue(in.InstanceId)) < 0 {
+		return nil, notFound("InvalidInstanceID.NotFound", aws.StringValue(in.InstanceId))
+	}
+
+	out := &ec2.GetConsoleOutputOutput{InstanceId: in.InstanceId}
+	if output, ok := f.consoleOutputs[*in.InstanceId]; ok {
+		out.Output = aws.String(base64.StdEncoding.EncodeToString([]byte(output)))
+	}
+	return out, nil
+}
+
+// SetConsoleOutput sets the console output reported for the instance.
+func (f *EC2) SetConsoleOutput(instanceID, output string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.consoleOutputs[instanceID] = output
+}
+
+// DescribeInstanceStatusWithContext implements EC2API.
+// Only running instances have a status, their checks are ok unless set otherwise.
+func (f *EC2) DescribeInstanceStatusWithContext(_ aws.Context, in *ec2.DescribeInstanceStatusInput, _ ...request.Option) (*ec2.DescribeInstanceStatusOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := &ec2.DescribeInstanceStatusOutput{}
+	for _, id := range in.InstanceIds {
+		i := f.findInstance(aws.StringValue(id))
+		if i < 0 {
+			return nil, notFound("InvalidInstanceID.NotFound", aws.StringValue(id))
+		}
+		if aws.StringValue(f.instances[i].State.Name) != ec2.InstanceStateNameRunning {
+			continue
+		}
+
+		checks, ok := f.statusChecks[*id]
+		if !ok {
+			checks = [2]string{ec2.SummaryStatusOk, ec2.SummaryStatusOk}
+		}
+		out.InstanceStatuses = append(out.InstanceStatuses, &ec2.InstanceStatus{
+			InstanceId:     id,
+			InstanceState:  f.instances[i].State,
+			SystemStatus:   &ec2.InstanceStatusSummary{Status: aws.String(checks[0])},
+			InstanceStatus: &ec2.InstanceStatusSummary{Status: aws.String(checks[1])},
+		})
+	}
+	return out, nil
+}
+
+// SetStatusChecks sets the system and instance status checks reported for the instance.
+func (f *EC2) SetStatusChecks(instanceID, system, instance string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.statusChecks[instanceID] = [2]string{system, instance}
+}
+
+// StartInstancesWithContext implements EC2API.
+// Started instances are reported as pending once, and running afterwards.
+func (f *EC2) StartInstancesWithContext(_ aws.Context, in *ec2.StartInstancesInput, _ ...request.Option) (*ec2.StartInstancesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := &ec2.StartInstancesOutput{}
+	for _, id := range in.InstanceIds {
+		i := f.findInstance(aws.StringValue(id))
+		if i < 0 {
+			return nil, notFound("InvalidInstanceID.NotFound", aws.StringValue(id))
+		}
+
+		instance := f.instances[i]
+		switch aws.StringValue(instance.State.Name) {
+		case ec2.InstanceStateNameStopped, ec2.InstanceStateNamePending, ec2.InstanceStateNameRunning:
+		default:
+			return nil, awserr.New("IncorrectInstanceState", fmt.Sprintf("The instance '%s' is not in a state from which it can be started", *id), nil)
+		}
+
+		out.StartingInstances = append(out.StartingInstances, &ec2.InstanceStateChange{
+			InstanceId:    instance.InstanceId,
+			PreviousState: instance.State,
+			CurrentState: &ec2.InstanceState{
+				Name: aws.String(ec2.InstanceStateNamePending),
+			},
+		})
+		instance.State = &ec2.InstanceState{
+			Name: aws.String(ec2.InstanceStateNameRunning),
+		}
+		instance.LaunchTime = aws.Time(time.Now())
+	}
+
+	return out, nil
+}
+
+// StopInstancesWithContext implements EC2API.
+// Stopped instances are reported as stopping once, and stopped afterwards.
+func (f *EC2) StopInstancesWithContext(_ aws.Context, in *ec2.StopInstancesInput, _ ...request.Option) (*ec2.StopInstancesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := &ec2.StopInstancesOutput{}
+	for _, id := range in.InstanceIds {
+		i := f.findInstance(aws.StringValue(id))
+		if i < 0 {
+			return nil, notFound("InvalidInstanceID.NotFound", aws.StringValue(id))
+		}
+
+		instance := f.instances[i]
+		switch aws.StringValue(instance.State.Name) {
+		case ec2.InstanceStateNamePending, ec2.InstanceStateNameRunning, ec2.InstanceStateNameStopped:
+		default:
+			return nil, awserr.New("IncorrectInstanceState", fmt.Sprintf("The instance '%s' is not in a state from which it can be stopped", *id), nil)
+		}
+
+		out.StoppingInstances = append(out.StoppingInstances, &ec2.InstanceStateChange{
+			InstanceId:    instance.InstanceId,
+			PreviousState: instance.State,
+			CurrentState: &ec2.InstanceState{
+				Name: aws.String(ec2.InstanceStateNameStopping),
+			},
+		})
+		instance.State = &ec2.InstanceState{
+			Name: aws.String(ec2.InstanceStateNameStopped),
+		}
+	}
+
+	return out, nil
+}
+
+// TerminateInstancesWithContext implements EC2API.
+func (f *EC2) TerminateInstancesWithContext(_ aws.Context, in *ec2.TerminateInstancesInput, _ ...request.Option) (*ec2.TerminateInstancesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := &ec2.TerminateInstancesOutput{}
+	for _, id := range in.InstanceIds {
+		i := f.findInstance(aws.StringValue(id))
+		if i < 0 {
+			return nil, notFound("InvalidInstanceID.NotFound", aws.StringValue(id))
+		}
+
+		instance := f.instances[i]
+		out.TerminatingInstances = append(out.TerminatingInstances, &ec2.InstanceStateChange{
+			InstanceId:    instance.InstanceId,
+			PreviousState: instance.State,
+			CurrentState: &ec2.InstanceState{
+				Name: aws.String(ec2.InstanceStateNameShuttingDown),
+			},
+		})
+		instance.State = &ec2.InstanceState{
+			Name: aws.String(ec2.InstanceStateNameTerminated),
+		}
+
+		// Root volumes are deleted on termination.
+		var volumes []*ec2.Volume
+		for _, v := range f.volumes {
+			if aws.StringValue(v.Attachments[0].InstanceId) != aws.StringValue(id) {
+				volumes = append(volumes, v)
+				continue
+			}
+			delete(f.tags, *v.VolumeId)
+			delete(f.modifications, *v.VolumeId)
+		}
+		f.volumes = volumes
+	}
+
+	return out, nil
+}
+
+// CreateLaunchTemplateWithContext implements EC2API.
+func (f *EC2) CreateLaunchTemplateWithContext(_ aws.Context, in *ec2.CreateLaunchTemplateInput, _ ...request.Option) (*ec2.CreateLaunchTemplateOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if in.LaunchTemplateName == nil {
+		return nil, missingParameter("LaunchTemplateName")
+	}
+	if in.LaunchTemplateData == nil {
+		return nil, missingParameter("LaunchTemplateData")
+	}
+	if f.findLaunchTemplate(nil, in.LaunchTemplateName) >= 0 {
+		return nil, awserr.New("InvalidLaunchTemplateName.AlreadyExistsException",
+			fmt.Sprintf("Launch template name already in use: %s", *in.LaunchTemplateName), nil)
+	}
+
+	lt := &ec2.LaunchTemplate{
+		LaunchTemplateId:     aws.String(f.newID("lt")),
+		LaunchTemplateName:   in.LaunchTemplateName,
+		DefaultVersionNumber: aws.Int64(1),
+		LatestVersionNumber:  aws.Int64(1),
+	}
+	f.launchTemplates = append(f.launchTemplates, lt)
+	f.addLaunchTemplateVersion(lt, in.VersionDescription, in.LaunchTemplateData)
+
+	return &ec2.CreateLaunchTemplateOutput{LaunchTemplate: f.copyLaunchTemplate(lt)}, nil
+}
+
+// CreateLaunchTemplateVersionWithContext implements EC2API.
+func (f *EC2) CreateLaunchTemplateVersionWithContext(_ aws.Context, in *ec2.CreateLaunchTemplateVersionInput, _ ...request.Option) (*ec2.CreateLaunchTemplateVersionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if in.LaunchTemplateData == nil {
+		return nil, missingParameter("LaunchTemplateData")
+	}
+
+	i := f.findLaunchTemplate(in.LaunchTemplateId, in.LaunchTemplateName)
+	if i < 0 {
+		return nil, notFound("InvalidLaunchTemplateId.NotFound", aws.StringValue(in.LaunchTemplateId))
+	}
+
+	lt := f.launchTemplates[i]
+	lt.LatestVersionNumber = aws.Int64(aws.Int64Value(lt.LatestVersionNumber) + 1)
+	version := f.addLaunchTemplateVersion(lt, in.VersionDescription, in.LaunchTemplateData)
+
+	return &ec2.CreateLaunchTemplateVersionOutput{LaunchTemplateVersion: awsutil.CopyOf(version).(*ec2.LaunchTemplateVersion)}, nil
+}
+
+// DeleteLaunchTemplateWithContext implements EC2API.
+func (f *EC2) DeleteLaunchTemplateWithContext(_ aws.Context, in *ec2.DeleteLaunchTemplateInput, _ ...request.Option) (*ec2.DeleteLaunchTemplateOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.findLaunchTemplate(in.LaunchTemplateId, in.LaunchTemplateName)
+	if i < 0 {
+		return nil, notFound("InvalidLaunchTemplateId.NotFound", aws.StringValue(in.LaunchTemplateId))
+	}
+
+	lt := f.launchTemplates[i]
+	f.launchTemplates = append(f.launchTemplates[:i], f.launchTemplates[i+1:]...)
+	delete(f.ltVersions, *lt.LaunchTemplateId)
+	delete(f.tags, *lt.LaunchTemplateId)
+
+	return &ec2.DeleteLaunchTemplateOutput{LaunchTemplate: awsutil.CopyOf(lt).(*ec2.LaunchTemplate)}, nil
+}
+
+// DescribeLaunchTemplatesWithContext implements EC2API.
+func (f *EC2) DescribeLaunchTemplatesWithContext(_ aws.Context, in *ec2.DescribeLaunchTemplatesInput, _ ...request.Option) (*ec2.DescribeLaunchTemplatesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := &ec2.DescribeLaunchTemplatesOutput{}
+	for _, lt := range f.launchTemplates {
+		if !containsID(in.LaunchTemplateIds, lt.LaunchTemplateId) || !containsID(in.LaunchTemplateNames, lt.LaunchTemplateName) {
+			continue
+		}
+
+		ok, err := f.match(*lt.LaunchTemplateId, in.Filters, map[string][]string{
+			"launch-template-name": {aws.StringValue(lt.LaunchTemplateName)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out.LaunchTemplates = append(out.LaunchTemplates, f.copyLaunchTemplate(lt))
+		}
+	}
+
+	return out, nil
+}
+
+// DescribeLaunchTemplateVersionsWithContext implements EC2API.
+// Versions are selected by number, $Latest or $Default.
+func (f *EC2) DescribeLaunchTemplateVersionsWithContext(_ aws.Context, in *ec2.DescribeLaunchTemplateVersionsInput, _ ...request.Option) (*ec2.DescribeLaunchTemplateVersionsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.findLaunchTemplate(in.LaunchTemplateId, in.LaunchTemplateName)
+	if i < 0 {
+		return nil, notFound("InvalidLaunchTemplateId.NotFound", aws.StringValue(in.LaunchTemplateId))
+	}
+	lt := f.launchTemplates[i]
+
+	out := &ec2.DescribeLaunchTemplateVersionsOutput{}
+	if len(in.Versions) == 0 {
+		for _, v := range f.ltVersions[*lt.LaunchTemplateId] {
+			out.LaunchTemplateVersions = append(out.LaunchTemplateVersions, awsutil.CopyOf(v).(*ec2.LaunchTemplateVersion))
+		}
+		return out, nil
+	}
+
+	for _, v := range in.Versions {
+		version, err := f.findLaunchTemplateVersion(lt.LaunchTemplateId, nil, aws.StringValue(v))
+		if err != nil {
+			return nil, err
+		}
+		out.LaunchTemplateVersions = append(out.LaunchTemplateVersions, awsutil.CopyOf(version).(*ec2.LaunchTemplateVersion))
+	}
+
+	return out, nil
+}
+
+// DescribeImagesWithContext implements EC2API.
+// Images are not modelled, every requested image exists and has the root device /dev/xvda.
+func (f *EC2) DescribeImagesWithContext(_ aws.Context, in *ec2.DescribeImagesInput, _ ...request.Option) (*ec2.DescribeImagesOutput, error) {
+	out := &ec2.DescribeImagesOutput{Images: []*ec2.Image{}}
+	for _, id := range in.ImageIds {
+		out.Images = append(out.Images, &ec2.Image{
+			ImageId:        aws.String(aws.StringValue(id)),
+			RootDeviceName: aws.String("/dev/xvda"),
+			RootDeviceType: aws.String(ec2.DeviceTypeEbs),
+		})
+	}
+	return out, nil
+}
+
+// DescribeVolumesWithContext implements EC2API.
+// Only the root volumes of instances are modelled.
+func (f *EC2) DescribeVolumesWithContext(_ aws.Context, in *ec2.DescribeVolumesInput, _ ...request.Option) (*ec2.DescribeVolumesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, id := range in.VolumeIds {
+		if f.findVolume(aws.StringValue(id)) < 0 {
+			return nil, notFound("InvalidVolume.NotFound", aws.StringValue(id))
+		}
+	}
+
+	out := &ec2.DescribeVolumesOutput{Volumes: []*ec2.Volume{}}
+	for _, v := range f.volumes {
+		if !containsID(in.VolumeIds, v.VolumeId) {
+			continue
+		}
+
+		ok, err := f.match(*v.VolumeId, in.Filters, map[string][]string{
+			"volume-id":              {aws.StringValue(v.VolumeId)},
+			"attachment.instance-id": {aws.StringValue(v.Attachments[0].InstanceId)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out.Volumes = append(out.Volumes, f.copyVolume(v))
+		}
+	}
+
+	return out, nil
+}
+
+// DescribeVolumesPagesWithContext implements EC2API.
+// All volumes are returned in a single page.
+func (f *EC2) DescribeVolumesPagesWithContext(ctx aws.Context, in *ec2.DescribeVolumesInput, fn func(*ec2.DescribeVolumesOutput, bool) bool, _ ...request.Option) error {
+	out, err := f.DescribeVolumesWithContext(ctx, in)
+	if err != nil {
+		return err
+	}
+	fn(out, true)
+	return nil
+}
+
+// DeleteVolumeWithContext implements EC2API.
+// The modelled root volumes are always attached to their instance and can't be deleted.
+func (f *EC2) DeleteVolumeWithContext(_ aws.Context, in *ec2.DeleteVolumeInput, _ ...request.Option) (*ec2.DeleteVolumeOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.findVolume(aws.StringValue(in.VolumeId)) < 0 {
+		return nil, notFound("InvalidVolume.NotFound", aws.StringValue(in.VolumeId))
+	}
+	return nil, awserr.New("VolumeInUse", fmt.Sprintf("Volume %s is currently attached", aws.StringValue(in.VolumeId)), nil)
+}
+
+// ModifyVolumeWithContext implements EC2API.
+// Only the size can be modified, and only one modification can be in progress per volume.
+func (f *EC2) ModifyVolumeWithContext(_ aws.Context, in *ec2.ModifyVolumeInput, _ ...request.Option) (*ec2.ModifyVolumeOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.findVolume(aws.StringValue(in.VolumeId))
+	if i < 0 {
+		return nil, notFound("InvalidVolume.NotFound", aws.StringValue(in.VolumeId))
+	}
+
+	v := f.volumes[i]
+	if m, ok := f.modifications[*v.VolumeId]; ok && aws.StringValue(m.ModificationState) == ec2.VolumeModificationStateModifying {
+		return nil, awserr.New("IncorrectModificationState",
+			fmt.Sprintf("Cannot modify volume %s while it is being modified", *v.VolumeId), nil)
+	}
+	if aws.Int64Value(in.Size) < aws.Int64Value(v.Size) {
+		return nil, awserr.New("InvalidParameterValue",
+			fmt.Sprintf("New size cannot be smaller than existing size of %d GiB", aws.Int64Value(v.Size)), nil)
+	}
+
+	m := &ec2.VolumeModification{
+		VolumeId:          v.VolumeId,
+		ModificationState: aws.String(ec2.VolumeModificationStateModifying),
+		OriginalSize:      v.Size,
+		TargetSize:        in.Size,
+		StartTime:         aws.Time(time.Now()),
+	}
+	f.modifications[*v.VolumeId] = m
+	v.Size = in.Size
+
+	return &ec2.ModifyVolumeOutput{VolumeModification: awsutil.CopyOf(m).(*ec2.VolumeModification)}, nil
+}
+
+// DescribeVolumesModificationsWithContext implements EC2API.
+// Modifications are reported as modifying once, and completed afterwards.
+func (f *EC2) DescribeVolumesModificationsWithContext(_ aws.Context, in *ec2.DescribeVolumesModificationsInput, _ ...request.Option) (*ec2.DescribeVolumesModificationsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := &ec2.DescribeVolumesModificationsOutput{}
+	for _, id := range in.VolumeIds {
+		m, ok := f.modifications[aws.StringValue(id)]
+		if !ok {
+			return nil, awserr.New("InvalidVolumeModification.NotFound",
+				fmt.Sprintf("Modification for volume '%s' does not exist", aws.StringValue(id)), nil)
+		}
+
+		out.VolumesModifications = append(out.VolumesModifications, awsutil.CopyOf(m).(*ec2.VolumeModification))
+		m.ModificationState = aws.String(ec2.VolumeModificationStateCompleted)
+	}
+
+	return out, nil
+}
+
+// CreateSecurityGroupWithContext implements EC2API.
+// Unlike AWS, no default security group is created with a vpc.
+func (f *EC2) CreateSecurityGroupWithContext(_ aws.Context, in *ec2.CreateSecurityGroupInput, _ ...request.Option) (*ec2.CreateSecurityGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if in.GroupName == nil {
+		return nil, missingParameter("GroupName")
+	}
+	if in.Description == nil {
+		return nil, missingParameter("GroupDescription")
+	}
+	if f.findVpc(aws.StringValue(in.VpcId)) < 0 {
+		return nil, notFound("InvalidVpcID.NotFound", aws.StringValue(in.VpcId))
+	}
+	for _, sg := range f.securityGroups {
+		if aws.StringValue(sg.VpcId) == *in.VpcId && aws.StringValue(sg.GroupName) == *in.GroupName {
+			return nil, awserr.New("InvalidGroup.Duplicate",
+				fmt.Sprintf("The security group '%s' already exists for VPC '%s'", *in.GroupName, *in.VpcId), nil)
+		}
+	}
+
+	sg := &ec2.SecurityGroup{
+		GroupId:     aws.String(f.newID("sg")),
+		GroupName:   in.GroupName,
+		Description: in.Description,
+		VpcId:       in.VpcId,
+	}
+	f.securityGroups = append(f.securityGroups, sg)
+
+	return &ec2.CreateSecurityGroupOutput{GroupId: sg.GroupId}, nil
+}
+
+// DeleteSecurityGroupWithContext implements EC2API.
+func (f *EC2) DeleteSecurityGroupWithContext(_ aws.Context, in *ec2.DeleteSecurityGroupInput, _ ...request.Option) (*ec2.DeleteSecurityGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.findSecurityGroup(aws.StringValue(in.GroupId))
+	if i < 0 {
+		return nil, notFound("InvalidGroup.NotFound", aws.StringValue(in.GroupId))
+	}
+
+	f.securityGroups = append(f.securityGroups[:i], f.securityGroups[i+1:]...)
+	delete(f.tags, aws.StringValue(in.GroupId))
+	return &ec2.DeleteSecurityGroupOutput{}, nil
+}
+
+// DescribeSecurityGroupsWithContext implements EC2API.
+func (f *EC2) DescribeSecurityGroupsWithContext(_ aws.Context, in *ec2.DescribeSecurityGroupsInput, _ ...request.Option) (*ec2.DescribeSecurityGroupsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, id := range in.GroupIds {
+		if f.findSecurityGroup(aws.StringValue(id)) < 0 {
+			return nil, notFound("InvalidGroup.NotFound", aws.StringValue(id))
+		}
+	}
+
+	out := &ec2.DescribeSecurityGroupsOutput{SecurityGroups: []*ec2.SecurityGroup{}}
+	for _, sg := range f.securityGroups {
+		if !containsID(in.GroupIds, sg.GroupId) {
+			continue
+		}
+
+		ok, err := f.match(*sg.GroupId, in.Filters, map[string][]string{
+			"group-id":   {aws.StringValue(sg.GroupId)},
+			"group-name": {aws.StringValue(sg.GroupName)},
+			"vpc-id":     {aws.StringValue(sg.VpcId)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out.SecurityGroups = append(out.SecurityGroups, f.copySecurityGroup(sg))
+		}
+	}
+
+	return out, nil
+}
+
+// AuthorizeSecurityGroupIngressWithContext implements EC2API.
+// Only permissions with IPv4 ranges are supported.
+func (f *EC2) AuthorizeSecurityGroupIngressWithContext(_ aws.Context, in *ec2.AuthorizeSecurityGroupIngressInput, _ ...request.Option) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.findSecurityGroup(aws.StringValue(in.GroupId))
+	if i < 0 {
+		return nil, notFound("InvalidGroup.NotFound", aws.StringValue(in.GroupId))
+	}
+	if len(in.IpPermissions) == 0 {
+		return nil, missingParameter("IpPermissions")
+	}
+
+	sg := f.securityGroups[i]
+	for _, perm := range in.IpPermissions {
+		var existing *ec2.IpPermission
+		for _, p := range sg.IpPermissions {
+			if aws.StringValue(p.IpProtocol) == aws.StringValue(perm.IpProtocol) &&
+				aws.Int64Value(p.FromPort) == aws.Int64Value(perm.FromPort) &&
+				aws.Int64Value(p.ToPort) == aws.Int64Value(perm.ToPort) {
+				existing = p
+			}
+		}
+		if existing == nil {
+			existing = &ec2.IpPermission{IpProtocol: perm.IpProtocol, FromPort: perm.FromPort, ToPort: perm.ToPort}
+			sg.IpPermissions = append(sg.IpPermissions, existing)
+		}
+
+		for _, r := range perm.IpRanges {
+			for _, er := range existing.IpRanges {
+				if aws.StringValue(er.CidrIp) == aws.StringValue(r.CidrIp) {
+					return nil, awserr.New("InvalidPermission.Duplicate",
+						fmt.Sprintf("the specified rule \"peer: %s, %s, from port: %d, to port: %d, ALLOW\" already exists",
+							aws.StringValue(r.CidrIp), strings.ToUpper(aws.StringValue(perm.IpProtocol)), aws.Int64Value(perm.FromPort), aws.Int64Value(perm.ToPort)), nil)
+				}
+			}
+			existing.IpRanges = append(existing.IpRanges, &ec2.IpRange{CidrIp: r.CidrIp, Description: r.Description})
+		}
+	}
+
+	return &ec2.AuthorizeSecurityGroupIngressOutput{}, nil
+}
+
+// RevokeSecurityGroupIngressWithContext implements EC2API.
+// Only rules allowing cidr blocks and security groups are modelled.
+func (f *EC2) RevokeSecurityGroupIngressWithContext(_ aws.Context, in *ec2.RevokeSecurityGroupIngressInput, _ ...request.Option) (*ec2.RevokeSecurityGroupIngressOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.findSecurityGroup(aws.StringValue(in.GroupId))
+	if i < 0 {
+		return nil, notFound("InvalidGroup.NotFound", aws.StringValue(in.GroupId))
+	}
+	if len(in.IpPermissions) == 0 {
+		return nil, missingParameter("IpPermissions")
+	}
+
+	sg := f.securityGroups[i]
+	for _, perm := range in.IpPermissions {
+		for _, p := range sg.IpPermissions {
+			if aws.StringValue(p.IpProtocol) != aws.StringValue(perm.IpProtocol) ||
+				aws.Int64Value(p.FromPort) != aws.Int64Value(perm.FromPort) ||
+				aws.Int64Value(p.ToPort) != aws.Int64Value(perm.ToPort) {
+				continue
+			}
+
+			var ranges []*ec2.IpRange
+			for _, r := range p.IpRanges {
+				revoked := false
+				for _, rr := range perm.IpRanges {
+					revoked = revoked || aws.StringValue(r.CidrIp) == aws.StringValue(rr.CidrIp)
+				}
+				if !revoked {
+					ranges = append(ranges, r)
+				}
+			}
+			p.IpRanges = ranges
+
+			var pairs []*ec2.UserIdGroupPair
+			for _, pair := range p.UserIdGroupPairs {
+				revoked := false
+				for _, rp := range perm.UserIdGroupPairs {
+					revoked = revoked || aws.StringValue(pair.GroupId) == aws.StringValue(rp.GroupId)
+				}
+				if !revoked {
+					pairs = append(pairs, pair)
+				}
+			}
+			p.UserIdGroupPairs = pairs
+		}
+	}
+
+	return &ec2.RevokeSecurityGroupIngressOutput{}, nil
+}
+
+// DeleteNetworkInterfaceWithContext implements EC2API.
+// Network interfaces aren't modelled.
+func (f *EC2) DeleteNetworkInterfaceWithContext(_ aws.Context, in *ec2.DeleteNetworkInterfaceInput, _ ...request.Option) (*ec2.DeleteNetworkInterfaceOutput, error) {
+	return nil, notFound("InvalidNetworkInterfaceID.NotFound", aws.StringValue(in.NetworkInterfaceId))
+}
+
+// DescribeNetworkInterfacesPagesWithContext implements EC2API.
+// Network interfaces aren't modelled, a single empty page is returned.
+func (f *EC2) DescribeNetworkInterfacesPagesWithContext(_ aws.Context, _ *ec2.DescribeNetworkInterfacesInput, fn func(*ec2.DescribeNetworkInterfacesOutput, bool) bool, _ ...request.Option) error {
+	fn(&ec2.DescribeNetworkInterfacesOutput{}, true)
+	return nil
+}
+
+// CreateTagsWithContext implements EC2API.
+func (f *EC2) CreateTagsWithContext(_ aws.Context, in *ec2.CreateTagsInput, _ ...request.Option) (*ec2.CreateTagsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, id := range in.Resources {
+		tags, ok := f.tags[*id]
+		if !ok {
+			tags = make(map[string]string)
+			f.tags[*id] = tags
+		}
+
+		for _, tag := range in.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+	}
+
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+// DeleteTagsWithContext implements EC2API.
+// Tags with a value are only deleted if the value matches.
+func (f *EC2) DeleteTagsWithContext(_ aws.Context, in *ec2.DeleteTagsInput, _ ...request.Option) (*ec2.DeleteTagsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, id := range in.Resources {
+		tags := f.tags[*id]
+		for _, tag := range in.Tags {
+			k := aws.StringValue(tag.Key)
+			if tag.Value != nil && tags[k] != *tag.Value {
+				continue
+			}
+			delete(tags, k)
+		}
+	}
+
+	return &ec2.DeleteTagsOutput{}, nil
+}
+
+// newID returns a new unique resource id with the given prefix.
+func (f *EC2) newID(prefix string) string {
+	f.ids++
+	return fmt.Sprintf("%s-%08x", prefix, f.ids)
+}
+
+// match returns true if the resource matches all filters.
+// attrs maps the supported filter names to the values of the resource.
+func (f *EC2) match(id string, filters []*ec2.Filter, attrs map[string][]string) (bool, error) {
+	for _, filter := range filters {
+		name := aws.StringValue(filter.Name)
+
+		var values []string
+		switch {
+		case name == "tag-key":
+			for k := range f.tags[id] {
+				values = append(values, k)
+			}
+		case strings.HasPrefix(name, "tag:"):
+			if v, ok := f.tags[id][strings.TrimPrefix(name, "tag:")]; ok {
+				values = []string{v}
+			}
+		default:
+			v, ok := attrs[name]
+			if !ok {
+				return false, awserr.New("InvalidParameterValue", fmt.Sprintf("The filter '%s' is invalid", name), nil)
+			}
+			values = v
+		}
+
+		if !containsAny(values, aws.StringValueSlice(filter.Values)) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// ec2Tags returns the tags of a resource sorted by key.
+func (f *EC2) ec2Tags(id string) []*ec2.Tag {
+	tags := f.tags[id]
+	if len(tags) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	res := make([]*ec2.Tag, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, &ec2.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	return res
+}
+
+func (f *EC2) copyVpc(in *ec2.Vpc) *ec2.Vpc {
+	out := awsutil.CopyOf(in).(*ec2.Vpc)
+	out.Tags = f.ec2Tags(*in.VpcId)
+	return out
+}
+
+func (f *EC2) copySubnet(in *ec2.Subnet) *ec2.Subnet {
+	out := awsutil.CopyOf(in).(*ec2.Subnet)
+	out.Tags = f.ec2Tags(*in.SubnetId)
+	return out
+}
+
+func (f *EC2) copyInternetGateway(in *ec2.InternetGateway) *ec2.InternetGateway {
+	out := awsutil.CopyOf(in).(*ec2.InternetGateway)
+	out.Tags = f.ec2Tags(*in.InternetGatewayId)
+	return out
+}
+
+func (f *EC2) copyNatGateway(in *ec2.NatGateway) *ec2.NatGateway {
+	out := awsutil.CopyOf(in).(*ec2.NatGateway)
+	out.Tags = f.ec2Tags(*in.NatGatewayId)
+	return out
+}
+
+func (f *EC2) copyRouteTable(in *ec2.RouteTable) *ec2.RouteTable {
+	out := awsutil.CopyOf(in).(*ec2.RouteTable)
+	out.Tags = f.ec2Tags(*in.RouteTableId)
+	return out
+}
+
+func (f *EC2) copyAddress(in *ec2.Address) *ec2.Address {
+	out := awsutil.CopyOf(in).(*ec2.Address)
+	out.Tags = f.ec2Tags(*in.AllocationId)
+	return out
+}
+
+func (f *EC2) copyInstance(in *ec2.Instance) *ec2.Instance {
+	out := awsutil.CopyOf(in).(*ec2.Instance)
+	out.Tags = f.ec2Tags(*in.InstanceId)
+	return out
+}
+
+func (f *EC2) copySecurityGroup(in *ec2.SecurityGroup) *ec2.SecurityGroup {
+	out := awsutil.CopyOf(in).(*ec2.SecurityGroup)
+	out.Tags = f.ec2Tags(*in.GroupId)
+	return out
+}
+
+func (f *EC2) copyVolume(in *ec2.Volume) *ec2.Volume {
+	out := awsutil.CopyOf(in).(*ec2.Volume)
+	out.Tags = f.ec2Tags(*in.VolumeId)
+	return out
+}
+
+func (f *EC2) copyLaunchTemplate(in *ec2.LaunchTemplate) *ec2.LaunchTemplate {
+	out := awsutil.CopyOf(in).(*ec2.LaunchTemplate)
+	out.Tags = f.ec2Tags(*in.LaunchTemplateId)
+	return out
+}
+
+// addLaunchTemplateVersion adds the latest version of the launch template.
+// Only the image, instance type, instance profile and block devices of the launch template data
+// are modelled.
+func (f *EC2) addLaunchTemplateVersion(lt *ec2.LaunchTemplate, description *string, data *ec2.RequestLaunchTemplateData) *ec2.LaunchTemplateVersion {
+	version := &ec2.LaunchTemplateVersion{
+		LaunchTemplateId:   lt.LaunchTemplateId,
+		LaunchTemplateName: lt.LaunchTemplateName,
+		VersionNumber:      lt.LatestVersionNumber,
+		VersionDescription: description,
+		DefaultVersion:     aws.Bool(aws.Int64Value(lt.LatestVersionNumber) == aws.Int64Value(lt.DefaultVersionNumber)),
+		LaunchTemplateData: &ec2.ResponseLaunchTemplateData{
+			ImageId:      data.ImageId,
+			InstanceType: data.InstanceType,
+		},
+	}
+	if profile := data.IamInstanceProfile; profile != nil {
+		version.LaunchTemplateData.IamInstanceProfile = &ec2.LaunchTemplateIamInstanceProfileSpecification{Arn: profile.Arn, Name: profile.Name}
+	}
+	for _, bdm := range data.BlockDeviceMappings {
+		mapping := &ec2.LaunchTemplateBlockDeviceMapping{DeviceName: bdm.DeviceName}
+		if bdm.Ebs != nil {
+			mapping.Ebs = &ec2.LaunchTemplateEbsBlockDevice{VolumeSize: bdm.Ebs.VolumeSize, VolumeType: bdm.Ebs.VolumeType, Iops: bdm.Ebs.Iops, Encrypted: bdm.Ebs.Encrypted}
+		}
+		version.LaunchTemplateData.BlockDeviceMappings = append(version.LaunchTemplateData.BlockDeviceMappings, mapping)
+	}
+	f.ltVersions[*lt.LaunchTemplateId] = append(f.ltVersions[*lt.LaunchTemplateId], version)
+	return version
+}
+
+// findLaunchTemplate returns the index of the launch template with the id or, if no id is given, the name.
+func (f *EC2) findLaunchTemplate(id *string, name *string) int {
+	for i, lt := range f.launchTemplates {
+		if id != nil && aws.StringValue(lt.LaunchTemplateId) == *id {
+			return i
+		}
+		if id == nil && name != nil && aws.StringValue(lt.LaunchTemplateName) == *name {
+			return i
+		}
+	}
+	return -1
+}
+
+// findLaunchTemplateVersion returns the version of a launch template, given by number, $Latest or $Default.
+// An empty version selects the default one.
+func (f *EC2) findLaunchTemplateVersion(id *string, name *string, version string) (*ec2.LaunchTemplateVersion, error) {
+	i := f.findLaunchTemplate(id, name)
+	if i < 0 {
+		if id == nil {
+			return nil, notFound("InvalidLaunchTemplateName.NotFoundException", aws.StringValue(name))
+		}
+		return nil, notFound("InvalidLaunchTemplateId.NotFound", *id)
+	}
+	lt := f.launchTemplates[i]
+
+	var number int64
+	switch version {
+	case "$Latest":
+		number = aws.Int64Value(lt.LatestVersionNumber)
+	case "", "$Default":
+		number = aws.Int64Value(lt.DefaultVersionNumber)
+	default:
+		n, err := strconv.ParseInt(version, 10, 64)
+		if err != nil {
+			return nil, awserr.New("InvalidLaunchTemplateId.VersionNotFound", fmt.Sprintf("Could not find launch template version %s", version), nil)
+		}
+		number = n
+	}
+
+	for _, v := range f.ltVersions[*lt.LaunchTemplateId] {
+		if aws.Int64Value(v.VersionNumber) == number {
+			return v, nil
+		}
+	}
+	return nil, awserr.New("InvalidLaunchTemplateId.VersionNotFound", fmt.Sprintf("Could not find launch template version %s", version), nil)
+}
+
+func (f *EC2) findVpc(id string) int {
+	for i, vpc := range f.vpcs {
+		if aws.StringValue(vpc.VpcId) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *EC2) findSubnet(id string) int {
+	for i, sn := range f.subnets {
+		if aws.StringValue(sn.SubnetId) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *EC2) findInternetGateway(id string) int {
+	for i, ig := range f.internetGateways {
+		if aws.StringValue(ig.InternetGatewayId) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *EC2) findAddress(id string) int {
+	for i, addr := range f.addresses {
+		if aws.StringValue(addr.AllocationId) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *EC2) findRouteTable(id string) int {
+	for i, rt := range f.routeTables {
+		if aws.StringValue(rt.RouteTableId) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *EC2) findSecurityGroup(id string) int {
+	for i, sg := range f.securityGroups {
+		if aws.StringValue(sg.GroupId) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *EC2) findVolume(id string) int {
+	for i, v := range f.volumes {
+		if aws.StringValue(v.VolumeId) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *EC2) findInstance(id string) int {
+	for i, instance := range f.instances {
+		if aws.StringValue(instance.InstanceId) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// containsID returns true if ids is empty or contains id.
+func containsID(ids []*string, id *string) bool {
+	if len(ids) == 0 {
+		return true
+	}
+
+	for _, i := range ids {
+		if aws.StringValue(i) == aws.StringValue(id) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(have []string, want []string) bool {
+	for _, h := range have {
+		for _, w := range want {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func notFound(code string, ids ...string) error {
+	return awserr.New(code, fmt.Sprintf("The ID '%s' does not exist", strings.Join(ids, ", ")), nil)
+}
+
+func dependencyViolation(msg string) error {
+	return awserr.New("DependencyViolation", msg, nil)
+}
+
+func missingParameter(name string) error {
+	return awserr.New("MissingParameter", fmt.Sprintf("The request must contain the parameter %s", name), nil)
+}
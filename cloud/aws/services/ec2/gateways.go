@@ -15,65 +15,190 @@ package ec2
 
 import (
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/golang/glog"
 	"github.com/pkg/errors"
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
 )
 
-func (s *Service) reconcileInternetGateways(in *v1alpha1.Network) error {
-	glog.V(2).Infof("Reconciling internet gateways")
+func (s *Service) reconcileInternetGateways(clusterName string, spec *v1alpha1.NetworkSpec, in *v1alpha1.Network) error {
+	s.log.V(2).Info("Reconciling internet gateways", "vpc-id", in.VPC.ID)
 
-	igs, err := s.describeVpcInternetGateways(&in.VPC)
+	if spec.InternetGatewayID != "" {
+		return s.reconcileExistingInternetGateway(clusterName, spec.InternetGatewayID, in)
+	}
+
+	igs, err := s.describeVpcInternetGateways(clusterName, &in.VPC)
 	if IsNotFound(err) {
-		ig, err := s.createInternetGateway(&in.VPC)
+		ig, err := s.createInternetGateway(clusterName, &in.VPC)
 		if err != nil {
-			return nil
+			return err
 		}
 		igs = []*ec2.InternetGateway{ig}
 	} else if err != nil {
 		return err
+	} else if _, err := s.reconcileResourceTags(clusterName, *igs[0].InternetGatewayId, tagsToMap(igs[0].Tags)); err != nil {
+		return errors.Wrapf(err, "failed to update tags of internet gateway %q", *igs[0].InternetGatewayId)
 	}
 
 	in.InternetGatewayID = igs[0].InternetGatewayId
+	s.log.V(2).Info("Working on internet gateway", "internet-gateway-id", in.InternetGatewayID)
 	return nil
 }
 
-func (s *Service) createInternetGateway(vpc *v1alpha1.VPC) (*ec2.InternetGateway, error) {
-	ig, err := s.EC2.CreateInternetGateway(&ec2.CreateInternetGatewayInput{})
+// reconcileExistingInternetGateway uses the given internet gateway, which must be attached to the vpc.
+// Gateways that aren't tagged for the cluster yet are tagged as shared, so that they're never deleted.
+func (s *Service) reconcileExistingInternetGateway(clusterName string, id string, in *v1alpha1.Network) error {
+	out, err := s.EC2.DescribeInternetGatewaysWithContext(s.ctx, &ec2.DescribeInternetGatewaysInput{
+		InternetGatewayIds: []*string{aws.String(id)},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe internet gateway %q", id)
+	}
+	if len(out.InternetGateways) == 0 {
+		return NewNotFound(errors.Errorf("internet gateway %q not found", id))
+	}
+
+	ig := out.InternetGateways[0]
+	attached := false
+	for _, att := range ig.Attachments {
+		if aws.StringValue(att.VpcId) == in.VPC.ID {
+			attached = true
+		}
+	}
+	if !attached {
+		return errors.Errorf("internet gateway %q is not attached to vpc %q", id, in.VPC.ID)
+	}
+
+	if _, err := s.reconcileResourceTags(clusterName, id, tagsToMap(ig.Tags)); err != nil {
+		return errors.Wrapf(err, "failed to update tags of internet gateway %q", id)
+	}
+
+	in.InternetGatewayID = ig.InternetGatewayId
+	s.log.V(2).Info("Using existing internet gateway", "internet-gateway-id", id)
+	return nil
+}
+
+func (s *Service) createInternetGateway(clusterName string, vpc *v1alpha1.VPC) (*ec2.InternetGateway, error) {
+	ig, err := s.EC2.CreateInternetGatewayWithContext(s.ctx, &ec2.CreateInternetGatewayInput{})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create internet gateway")
 	}
 
-	_, err = s.EC2.AttachInternetGateway(&ec2.AttachInternetGatewayInput{
+	if err := s.createTags(clusterName, *ig.InternetGateway.InternetGatewayId, ResourceLifecycleOwned, nil); err != nil {
+		return nil, errors.Wrapf(err, "failed to tag internet gateway %q", *ig.InternetGateway.InternetGatewayId)
+	}
+
+	_, err = s.EC2.AttachInternetGatewayWithContext(s.ctx, &ec2.AttachInternetGatewayInput{
 		InternetGatewayId: ig.InternetGateway.InternetGatewayId,
 		VpcId:             aws.String(vpc.ID),
 	})
 
 	if err != nil {
+		// A detached gateway would no longer be found in the vpc, so it's deleted again.
+		if _, derr := s.EC2.DeleteInternetGatewayWithContext(s.ctx, &ec2.DeleteInternetGatewayInput{InternetGatewayId: ig.InternetGateway.InternetGatewayId}); derr != nil {
+			s.log.Error(derr, "Failed to delete internet gateway that couldn't be attached", "internet-gateway-id", ig.InternetGateway.InternetGatewayId)
+		}
 		return nil, errors.Wrapf(err, "failed to attach internet gateway %q to vpc %q", *ig.InternetGateway.InternetGatewayId, vpc.ID)
 	}
 
+	s.log.V(2).Info("Created new internet gateway", "internet-gateway-id", ig.InternetGateway.InternetGatewayId, "vpc-id", vpc.ID)
 	return ig.InternetGateway, nil
 }
 
-func (s *Service) describeVpcInternetGateways(vpc *v1alpha1.VPC) ([]*ec2.InternetGateway, error) {
-	out, err := s.EC2.DescribeInternetGateways(&ec2.DescribeInternetGatewaysInput{
-		Filters: []*ec2.Filter{
+func (s *Service) describeVpcInternetGateways(clusterName string, vpc *v1alpha1.VPC) ([]*ec2.InternetGateway, error) {
+	out, err := s.EC2.DescribeInternetGatewaysWithContext(s.ctx, &ec2.DescribeInternetGatewaysInput{
+		Filters: s.addNetworkTagFilters(clusterName, vpc, []*ec2.Filter{
 			{
 				Name:   aws.String("attachment.vpc-id"),
 				Values: []*string{aws.String(vpc.ID)},
 			},
-		},
+		}),
 	})
 
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to describe nat gateways in vpc %q", vpc.ID)
+		return nil, errors.Wrapf(err, "failed to describe internet gateways in vpc %q", vpc.ID)
 	}
 
 	if len(out.InternetGateways) == 0 {
-		return nil, NewNotFound(errors.Errorf("no nat gateways found in vpc %q", vpc.ID))
+		return nil, NewNotFound(errors.Errorf("no internet gateways found in vpc %q", vpc.ID))
 	}
 
 	return out.InternetGateways, nil
 }
+
+func (s *Service) deleteInternetGateways(clusterName string, vpc *v1alpha1.VPC) error {
+	igs, err := s.describeVpcInternetGateways(clusterName, vpc)
+	if err != nil && !IsNotFound(err) {
+		return err
+	}
+
+	// Gateways that an earlier attempt detached but failed to delete are no longer found in the vpc.
+	detached, err := s.describeDetachedInternetGateways(clusterName)
+	if err != nil {
+		return err
+	}
+	igs = append(igs, detached...)
+
+	for _, ig := range igs {
+		deleted, err := s.releaseResource(clusterName, *ig.InternetGatewayId, tagsToMap(ig.Tags), func() error {
+			if len(ig.Attachments) > 0 {
+				_, err := s.EC2.DetachInternetGatewayWithContext(s.ctx, &ec2.DetachInternetGatewayInput{
+					InternetGatewayId: ig.InternetGatewayId,
+					VpcId:             aws.String(vpc.ID),
+				})
+				if isDependencyViolation(err) {
+					// Public addresses in the vpc, e.g. of NAT gateways and instances that are going
+					// away, stay mapped for a while after they are released.
+					s.log.V(2).Info("Internet gateway can't be detached while public addresses are mapped", "internet-gateway-id", ig.InternetGatewayId)
+					return NewNotReady(errors.Wrapf(err, "internet gateway %q can't be detached from vpc %q yet", *ig.InternetGatewayId, vpc.ID))
+				}
+				if err != nil {
+					return errors.Wrapf(err, "failed to detach internet gateway %q from vpc %q", *ig.InternetGatewayId, vpc.ID)
+				}
+			}
+
+			_, err := s.EC2.DeleteInternetGatewayWithContext(s.ctx, &ec2.DeleteInternetGatewayInput{
+				InternetGatewayId: ig.InternetGatewayId,
+			})
+			return errors.Wrapf(err, "failed to delete internet gateway %q", *ig.InternetGatewayId)
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if deleted {
+			s.log.V(2).Info("Deleted internet gateway", "internet-gateway-id", ig.InternetGatewayId, "vpc-id", vpc.ID)
+		}
+	}
+
+	return nil
+}
+
+// describeDetachedInternetGateways returns the internet gateways tagged for the cluster that
+// aren't attached to any vpc.
+func (s *Service) describeDetachedInternetGateways(clusterName string) ([]*ec2.InternetGateway, error) {
+	out, err := s.EC2.DescribeInternetGatewaysWithContext(s.ctx, &ec2.DescribeInternetGatewaysInput{
+		Filters: s.addTagFilters(clusterName, nil),
+	})
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe internet gateways")
+	}
+
+	var res []*ec2.InternetGateway
+	for _, ig := range out.InternetGateways {
+		if len(ig.Attachments) == 0 {
+			res = append(res, ig)
+		}
+	}
+	return res, nil
+}
+
+func isDependencyViolation(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code() == "DependencyViolation"
+	}
+	return false
+}
@@ -41,11 +41,23 @@ func TestReconcileInternetGateways(t *testing.T) {
 			},
 			expect: func(m *mock_ec2iface.MockEC2API) {
 				m.EXPECT().
-					DescribeInternetGateways(gomock.AssignableToTypeOf(&ec2.DescribeInternetGatewaysInput{})).
+					DescribeInternetGatewaysWithContext(gomock.Any(), gomock.Eq(&ec2.DescribeInternetGatewaysInput{
+						Filters: []*ec2.Filter{
+							{
+								Name:   aws.String("attachment.vpc-id"),
+								Values: []*string{aws.String("vpc-gateways")},
+							},
+							{
+								Name:   aws.String("tag-key"),
+								Values: []*string{aws.String("kubernetes.io/cluster/test-cluster")},
+							},
+						},
+					})).
 					Return(&ec2.DescribeInternetGatewaysOutput{
 						InternetGateways: []*ec2.InternetGateway{
 							{
 								InternetGatewayId: aws.String("igw-0"),
+								Tags:              []*ec2.Tag{{Key: aws.String("kubernetes.io/cluster/test-cluster"), Value: aws.String("owned")}},
 								Attachments: []*ec2.InternetGatewayAttachment{
 									{
 										State: aws.String(ec2.AttachmentStatusAttached),
@@ -66,17 +78,27 @@ func TestReconcileInternetGateways(t *testing.T) {
 			},
 			expect: func(m *mock_ec2iface.MockEC2API) {
 				m.EXPECT().
-					DescribeInternetGateways(gomock.AssignableToTypeOf(&ec2.DescribeInternetGatewaysInput{})).
+					DescribeInternetGatewaysWithContext(gomock.Any(), gomock.AssignableToTypeOf(&ec2.DescribeInternetGatewaysInput{})).
 					Return(&ec2.DescribeInternetGatewaysOutput{}, nil)
 
 				m.EXPECT().
-					CreateInternetGateway(gomock.AssignableToTypeOf(&ec2.CreateInternetGatewayInput{})).
+					CreateInternetGatewayWithContext(gomock.Any(), gomock.AssignableToTypeOf(&ec2.CreateInternetGatewayInput{})).
 					Return(&ec2.CreateInternetGatewayOutput{
 						InternetGateway: &ec2.InternetGateway{InternetGatewayId: aws.String("igw-1")},
 					}, nil)
 
 				m.EXPECT().
-					AttachInternetGateway(gomock.Eq(&ec2.AttachInternetGatewayInput{
+					CreateTagsWithContext(gomock.Any(), gomock.Eq(&ec2.CreateTagsInput{
+						Resources: aws.StringSlice([]string{"igw-1"}),
+						Tags: []*ec2.Tag{{
+							Key:   aws.String("kubernetes.io/cluster/test-cluster"),
+							Value: aws.String("owned"),
+						}},
+					})).
+					Return(nil, nil)
+
+				m.EXPECT().
+					AttachInternetGatewayWithContext(gomock.Any(), gomock.Eq(&ec2.AttachInternetGatewayInput{
 						InternetGatewayId: aws.String("igw-1"),
 						VpcId:             aws.String("vpc-gateways"),
 					})).
@@ -92,7 +114,7 @@ func TestReconcileInternetGateways(t *testing.T) {
 			tc.expect(ec2Mock)
 
 			s := NewService(ec2Mock)
-			if err := s.reconcileInternetGateways(tc.input); err != nil {
+			if err := s.reconcileInternetGateways("test-cluster", &v1alpha1.NetworkSpec{}, tc.input); err != nil {
 				t.Fatalf("got an unexpected error: %v", err)
 			}
 		})
@@ -0,0 +1,122 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+)
+
+// InstancesToHibernate returns the ids of the pending and running instances of the machines of
+// the cluster, which HibernateInstances stops. The instances tagged with the control plane role
+// are only included if controlPlane is true.
+func (s *Service) InstancesToHibernate(ctx context.Context, clusterName string, controlPlane bool) ([]string, error) {
+	s = s.withContext(ctx)
+
+	instances, err := s.describeMachineInstances(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, i := range instances {
+		if !controlPlane && tagsToMap(i.Tags)[TagNameRole] == RoleControlPlane {
+			continue
+		}
+		switch aws.StringValue(i.State.Name) {
+		case ec2.InstanceStateNamePending, ec2.InstanceStateNameRunning:
+			ids = append(ids, aws.StringValue(i.InstanceId))
+		}
+	}
+	return ids, nil
+}
+
+// HibernateInstances stops the given instances of the machines of the cluster.
+// Their volumes are kept, so that ResumeInstances can start them again.
+func (s *Service) HibernateInstances(ctx context.Context, clusterName string, instanceIDs []string) error {
+	if len(instanceIDs) == 0 {
+		return nil
+	}
+	s = s.withContext(ctx)
+
+	if _, err := s.EC2.StopInstancesWithContext(s.ctx, &ec2.StopInstancesInput{InstanceIds: aws.StringSlice(instanceIDs)}); err != nil {
+		return errors.Wrapf(err, "failed to stop instances of cluster %q", clusterName)
+	}
+
+	s.log.V(2).Info("Stopped instances for hibernation", "cluster", clusterName, "instance-ids", instanceIDs)
+	return nil
+}
+
+// ResumeInstances starts the stopped instances of the machines of the cluster.
+// Instances that are still stopping can't be started yet, they and the instances that are still
+// starting are reported as not ready, so that the cluster is only resumed once they're running.
+func (s *Service) ResumeInstances(ctx context.Context, clusterName string) error {
+	s = s.withContext(ctx)
+
+	instances, err := s.describeMachineInstances(clusterName)
+	if err != nil {
+		return err
+	}
+
+	var ids, waiting []*string
+	for _, i := range instances {
+		switch aws.StringValue(i.State.Name) {
+		case ec2.InstanceStateNameStopped:
+			ids = append(ids, i.InstanceId)
+		case ec2.InstanceStateNameStopping, ec2.InstanceStateNamePending:
+			waiting = append(waiting, i.InstanceId)
+		}
+	}
+
+	if len(ids) > 0 {
+		if _, err := s.EC2.StartInstancesWithContext(s.ctx, &ec2.StartInstancesInput{InstanceIds: ids}); err != nil {
+			return errors.Wrapf(err, "failed to start instances of cluster %q", clusterName)
+		}
+
+		s.log.V(2).Info("Started instances after hibernation", "cluster", clusterName, "instance-ids", aws.StringValueSlice(ids))
+		waiting = append(waiting, ids...)
+	}
+
+	if len(waiting) > 0 {
+		return NewNotReady(errors.Errorf("instances %v of cluster %q are still stopping or starting", aws.StringValueSlice(waiting), clusterName))
+	}
+
+	return nil
+}
+
+// describeMachineInstances returns the instances owned by the cluster that aren't going away,
+// except for the ones of warm pools.
+func (s *Service) describeMachineInstances(clusterName string) ([]*ec2.Instance, error) {
+	instances, err := s.describeInstances([]*ec2.Filter{
+		{
+			Name:   aws.String(fmt.Sprintf("tag:%s", s.clusterTagKey(clusterName))),
+			Values: aws.StringSlice([]string{string(ResourceLifecycleOwned)}),
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe instances of cluster %q", clusterName)
+	}
+
+	res := make([]*ec2.Instance, 0, len(instances))
+	for _, i := range instances {
+		if _, ok := tagsToMap(i.Tags)[TagNameWarmPool]; !ok {
+			res = append(res, i)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,135 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/fake"
+	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
+)
+
+func TestHibernateInstances(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	config := &v1alpha1.AWSMachineProviderConfig{AMI: v1alpha1.AWSResourceReference{ID: aws.String("ami-1")}}
+	var ids []string
+	for _, name := range []string{"controlplane-0", "node-0"} {
+		instance, err := s.CreateInstance(context.TODO(), "test-cluster", "", nil, &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: name}}, config)
+		if err != nil {
+			t.Fatalf("failed to create instance: %v", err)
+		}
+		ids = append(ids, instance.ID)
+	}
+	other, err := s.CreateInstance(context.TODO(), "other-cluster", "", nil, &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "node-0"}}, config)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+
+	// Warm pools stay as they are.
+	pooled := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{
+		Name:            "workers-a",
+		OwnerReferences: []metav1.OwnerReference{{Kind: "MachineSet", Name: "workers"}},
+	}}
+	warm := config.DeepCopy()
+	warm.WarmPoolSize = 1
+	if err := s.ReconcileWarmPool(context.TODO(), "test-cluster", pooled, warm); err != nil {
+		t.Fatalf("failed to reconcile warm pool: %v", err)
+	}
+
+	checkStates := func(state string) {
+		for _, id := range ids {
+			i, err := s.InstanceIfExists(context.TODO(), aws.String(id))
+			if err != nil {
+				t.Fatalf("failed to describe instance: %v", err)
+			}
+			if i.State != state {
+				t.Fatalf("expected instance %q to be %s, got: %s", id, state, i.State)
+			}
+		}
+		if i, err := s.InstanceIfExists(context.TODO(), &other.ID); err != nil || i.State != InstanceStateRunning {
+			t.Fatalf("expected the instance of the other cluster to keep running, got: %+v, %v", i, err)
+		}
+		if states := warmPoolStates(t, s, "test-cluster-workers"); states[InstanceStateStopped]+states[InstanceStateRunning] != 1 {
+			t.Fatalf("expected the warm pool to be left alone, got: %v", states)
+		}
+	}
+
+	hibernate := func() {
+		toStop, err := s.InstancesToHibernate(context.TODO(), "test-cluster", true)
+		if err != nil {
+			t.Fatalf("failed to describe instances to hibernate: %v", err)
+		}
+		if err := s.HibernateInstances(context.TODO(), "test-cluster", toStop); err != nil {
+			t.Fatalf("failed to hibernate instances: %v", err)
+		}
+	}
+
+	hibernate()
+	checkStates(InstanceStateStopped)
+
+	// Hibernating again doesn't change anything.
+	if toStop, err := s.InstancesToHibernate(context.TODO(), "test-cluster", true); err != nil || len(toStop) != 0 {
+		t.Fatalf("expected no instances left to hibernate, got: %v, %v", toStop, err)
+	}
+	hibernate()
+	checkStates(InstanceStateStopped)
+
+	// The cluster is resumed once the started instances are running.
+	if err := s.ResumeInstances(context.TODO(), "test-cluster"); !IsNotReady(err) {
+		t.Fatalf("expected the instances to be starting, got: %v", err)
+	}
+	checkStates(InstanceStateRunning)
+	if err := s.ResumeInstances(context.TODO(), "test-cluster"); err != nil {
+		t.Fatalf("failed to resume instances: %v", err)
+	}
+}
+
+func TestHibernateInstancesKeepsControlPlane(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	config := &v1alpha1.AWSMachineProviderConfig{AMI: v1alpha1.AWSResourceReference{ID: aws.String("ami-1")}}
+	controlPlane, err := s.CreateInstance(context.TODO(), "test-cluster", "", map[string]string{TagNameRole: RoleControlPlane}, &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "controlplane-0"}}, config)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	node, err := s.CreateInstance(context.TODO(), "test-cluster", "", map[string]string{TagNameRole: RoleNode}, &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "node-0"}}, config)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+
+	toStop, err := s.InstancesToHibernate(context.TODO(), "test-cluster", false)
+	if err != nil {
+		t.Fatalf("failed to describe instances to hibernate: %v", err)
+	}
+	if len(toStop) != 1 || toStop[0] != node.ID {
+		t.Fatalf("expected only the node to be hibernated, got: %v", toStop)
+	}
+	if err := s.HibernateInstances(context.TODO(), "test-cluster", toStop); err != nil {
+		t.Fatalf("failed to hibernate instances: %v", err)
+	}
+
+	if i, err := s.InstanceIfExists(context.TODO(), &controlPlane.ID); err != nil || i.State != InstanceStateRunning {
+		t.Fatalf("expected the control plane to keep running, got: %+v, %v", i, err)
+	}
+	if i, err := s.InstanceIfExists(context.TODO(), &node.ID); err != nil || i.State != InstanceStateStopped {
+		t.Fatalf("expected the node to be stopped, got: %+v, %v", i, err)
+	}
+}
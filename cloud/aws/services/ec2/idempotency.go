@@ -0,0 +1,30 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// ClientToken returns the idempotency token of a create request, derived from the parts
+// identifying what is created. A request that is retried after it timed out gets the same token,
+// so that AWS returns the resource of the first request instead of creating another one.
+// Tokens have to be unique per resource: parts should change once a resource is replaced.
+func ClientToken(parts ...string) string {
+	// AWS accepts up to 64 ASCII characters, which is the length of a hex encoded sha256 sum.
+	sum := sha256.Sum256([]byte(strings.Join(parts, "/")))
+	return hex.EncodeToString(sum[:])
+}
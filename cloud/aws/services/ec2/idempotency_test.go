@@ -0,0 +1,79 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/fake"
+	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
+)
+
+func TestClientToken(t *testing.T) {
+	token := ClientToken("uid-1", "instance", "")
+	if len(token) > 64 {
+		t.Fatalf("expected a token of at most 64 characters, got: %q", token)
+	}
+	if token != ClientToken("uid-1", "instance", "") {
+		t.Fatalf("expected the token to be deterministic")
+	}
+	if token == ClientToken("uid-1", "instance", "i-1") || token == ClientToken("uid-2", "instance", "") {
+		t.Fatalf("expected different parts to result in different tokens")
+	}
+}
+
+func TestCreateInstanceClientToken(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	machine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-1", UID: "uid-1"}}
+	config := &v1alpha1.AWSMachineProviderConfig{AMI: v1alpha1.AWSResourceReference{ID: aws.String("ami-1")}}
+
+	create := func(token string) *Instance {
+		instance, err := s.CreateInstance(context.TODO(), "test-cluster", token, nil, machine, config)
+		if err != nil {
+			t.Fatalf("failed to create instance: %v", err)
+		}
+		return instance
+	}
+
+	first := create(ClientToken("uid-1", "instance", ""))
+
+	// A retried request returns the instance of the first one.
+	if retried := create(ClientToken("uid-1", "instance", "")); retried.ID != first.ID {
+		t.Fatalf("expected instance %q to be returned, got: %q", first.ID, retried.ID)
+	}
+
+	// A replacement runs a new instance.
+	if replacement := create(ClientToken("uid-1", "instance", first.ID)); replacement.ID == first.ID {
+		t.Fatalf("expected a new instance to replace %q", first.ID)
+	}
+
+	out, err := f.DescribeInstancesWithContext(context.TODO(), &ec2.DescribeInstancesInput{})
+	if err != nil {
+		t.Fatalf("failed to describe instances: %v", err)
+	}
+	var count int
+	for _, r := range out.Reservations {
+		count += len(r.Instances)
+	}
+	if count != 2 {
+		t.Fatalf("expected 2 instances, got: %d", count)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+)
+
+const (
+	architectureX8664 = "x86_64"
+	architectureArm64 = "arm64"
+)
+
+// instanceFeatures are the features the instances of an instance family need from their AMI.
+type instanceFeatures struct {
+	// architecture is the architecture of the processors of the instances.
+	architecture string
+	// ena is whether the instances require the Elastic Network Adapter driver.
+	ena bool
+	// nitro is whether the instances are built on the Nitro system, which exposes volumes as
+	// NVMe devices and only runs HVM AMIs.
+	nitro bool
+}
+
+var (
+	nitroX8664 = instanceFeatures{architecture: architectureX8664, ena: true, nitro: true}
+	nitroArm64 = instanceFeatures{architecture: architectureArm64, ena: true, nitro: true}
+	enaX8664   = instanceFeatures{architecture: architectureX8664, ena: true}
+)
+
+// familyFeatures are the features of the instance families whose AMIs need more than the x86_64
+// architecture. The DescribeInstanceTypes API isn't available to look them up.
+var familyFeatures = map[string]instanceFeatures{
+	"a1":   nitroArm64,
+	"c6g":  nitroArm64,
+	"c6gd": nitroArm64,
+	"c6gn": nitroArm64,
+	"m6g":  nitroArm64,
+	"m6gd": nitroArm64,
+	"r6g":  nitroArm64,
+	"r6gd": nitroArm64,
+	"t4g":  nitroArm64,
+	"x2gd": nitroArm64,
+
+	"c5":   nitroX8664,
+	"c5a":  nitroX8664,
+	"c5ad": nitroX8664,
+	"c5d":  nitroX8664,
+	"c5n":  nitroX8664,
+	"c6i":  nitroX8664,
+	"g4dn": nitroX8664,
+	"i3en": nitroX8664,
+	"m5":   nitroX8664,
+	"m5a":  nitroX8664,
+	"m5ad": nitroX8664,
+	"m5d":  nitroX8664,
+	"m5dn": nitroX8664,
+	"m5n":  nitroX8664,
+	"m6i":  nitroX8664,
+	"p3dn": nitroX8664,
+	"r5":   nitroX8664,
+	"r5a":  nitroX8664,
+	"r5ad": nitroX8664,
+	"r5d":  nitroX8664,
+	"r5dn": nitroX8664,
+	"r5n":  nitroX8664,
+	"r6i":  nitroX8664,
+	"t3":   nitroX8664,
+	"t3a":  nitroX8664,
+	"z1d":  nitroX8664,
+
+	"f1":  enaX8664,
+	"g3":  enaX8664,
+	"h1":  enaX8664,
+	"i3":  enaX8664,
+	"p2":  enaX8664,
+	"p3":  enaX8664,
+	"x1":  enaX8664,
+	"x1e": enaX8664,
+}
+
+// featuresOf returns the features of the instance type. Instance types of other families run
+// x86_64 AMIs without further requirements.
+func featuresOf(instanceType string) instanceFeatures {
+	family := strings.SplitN(instanceType, ".", 2)[0]
+	if features, ok := familyFeatures[family]; ok {
+		return features
+	}
+	// The largest size of m4 is the only one requiring ENA.
+	if instanceType == "m4.16xlarge" {
+		return enaX8664
+	}
+	return instanceFeatures{architecture: architectureX8664}
+}
+
+// validateInstanceFeatures returns an invalid configuration error if instances of the type can't
+// boot or be reached when launched from the AMI, which EC2 only partly checks on launch.
+// Attributes the AMI doesn't have aren't checked.
+func validateInstanceFeatures(instanceType string, image *ec2.Image) error {
+	if instanceType == "" {
+		return nil
+	}
+	features := featuresOf(instanceType)
+	id := aws.StringValue(image.ImageId)
+
+	if arch := aws.StringValue(image.Architecture); arch != "" && arch != features.architecture {
+		return NewInvalidConfiguration(errors.Errorf("ami %q has the %s architecture, instance type %q requires %s", id, arch, instanceType, features.architecture))
+	}
+	if features.ena && image.EnaSupport != nil && !*image.EnaSupport {
+		return NewInvalidConfiguration(errors.Errorf("ami %q doesn't support ENA, which instance type %q requires", id, instanceType))
+	}
+	// NVMe drivers aren't an attribute of AMIs, but paravirtual AMIs never have them.
+	if features.nitro && aws.StringValue(image.VirtualizationType) == ec2.VirtualizationTypeParavirtual {
+		return NewInvalidConfiguration(errors.Errorf("ami %q is paravirtual, instance type %q only runs HVM AMIs with NVMe drivers", id, instanceType))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestValidateInstanceFeatures(t *testing.T) {
+	hvm := func(arch string, ena bool) *ec2.Image {
+		return &ec2.Image{
+			ImageId:            aws.String("ami-1"),
+			Architecture:       aws.String(arch),
+			EnaSupport:         aws.Bool(ena),
+			VirtualizationType: aws.String(ec2.VirtualizationTypeHvm),
+		}
+	}
+
+	testCases := []struct {
+		name         string
+		instanceType string
+		image        *ec2.Image
+		valid        bool
+	}{
+		{name: "nitro", instanceType: "m5.large", image: hvm("x86_64", true), valid: true},
+		{name: "nitro without ena", instanceType: "m5.large", image: hvm("x86_64", false)},
+		{name: "arm", instanceType: "a1.large", image: hvm("arm64", true), valid: true},
+		{name: "x86 ami on arm", instanceType: "m6g.large", image: hvm("x86_64", true)},
+		{name: "arm ami on x86", instanceType: "c4.large", image: hvm("arm64", true)},
+		{name: "older family without ena", instanceType: "c4.large", image: hvm("x86_64", false), valid: true},
+		{name: "largest m4 without ena", instanceType: "m4.16xlarge", image: hvm("x86_64", false)},
+		{
+			name:         "paravirtual on nitro",
+			instanceType: "t3.micro",
+			image:        &ec2.Image{ImageId: aws.String("ami-1"), VirtualizationType: aws.String(ec2.VirtualizationTypeParavirtual)},
+		},
+		{name: "unknown attributes", instanceType: "t3.micro", image: &ec2.Image{ImageId: aws.String("ami-1")}, valid: true},
+	}
+
+	for _, tc := range testCases {
+		err := validateInstanceFeatures(tc.instanceType, tc.image)
+		if tc.valid && err != nil {
+			t.Fatalf("%s: expected the ami to support the instance type, got: %v", tc.name, err)
+		}
+		if !tc.valid && !IsInvalidConfiguration(err) {
+			t.Fatalf("%s: expected an invalid configuration error, got: %v", tc.name, err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+)
+
+// instanceProfileWaitTimeout is how long instances are launched again while EC2 rejects their
+// instance profile. Instance profiles can only be used by EC2 a few seconds after IAM created them.
+var instanceProfileWaitTimeout = time.Minute
+
+// instanceProfileRetryInterval is how long to wait before launching the instance again.
+var instanceProfileRetryInterval = 5 * time.Second
+
+// isInstanceProfileError returns true if EC2 rejected the instance profile of an instance, as it
+// doesn't exist or isn't known to EC2 yet.
+func isInstanceProfileError(err error) bool {
+	if aerr, ok := errors.Cause(err).(awserr.Error); ok {
+		return aerr.Code() == "InvalidParameterValue" && strings.Contains(strings.ToLower(aerr.Message()), "iam instance profile")
+	}
+	return false
+}
+
+// runInstances runs the instances, launching them again while EC2 rejects their instance profile,
+// up to instanceProfileWaitTimeout. The client token is kept, as AWS only remembers it for
+// requests that succeeded.
+func (s *Service) runInstances(input *ec2.RunInstancesInput) (*ec2.Reservation, error) {
+	deadline := time.Now().Add(instanceProfileWaitTimeout)
+	for {
+		reservation, err := s.EC2.RunInstancesWithContext(s.ctx, input)
+		if !isInstanceProfileError(err) {
+			return reservation, err
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.Wrapf(err, "instance profile still rejected after %s", instanceProfileWaitTimeout)
+		}
+
+		s.log.Info("Instance profile isn't available to EC2 yet, launching instance again", "reason", err, "retry-after", instanceProfileRetryInterval)
+		select {
+		case <-s.ctx.Done():
+			return nil, errors.Wrap(s.ctx.Err(), "failed to wait for instance profile")
+		case <-time.After(instanceProfileRetryInterval):
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/fake"
+	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
+)
+
+// newProfileEC2 rejects the instance profile of the given number of instances, like EC2 right
+// after the instance profile was created.
+type newProfileEC2 struct {
+	*fake.EC2
+
+	rejections int
+}
+
+func (f *newProfileEC2) RunInstancesWithContext(ctx aws.Context, in *ec2.RunInstancesInput, opts ...request.Option) (*ec2.Reservation, error) {
+	if f.rejections > 0 {
+		f.rejections--
+		return nil, awserr.New("InvalidParameterValue", "Value (test-nodes) for parameter iamInstanceProfile.name is invalid. Invalid IAM Instance Profile name", nil)
+	}
+	return f.EC2.RunInstancesWithContext(ctx, in, opts...)
+}
+
+func TestCreateInstanceWaitsForInstanceProfile(t *testing.T) {
+	defer func(timeout, interval time.Duration) {
+		instanceProfileWaitTimeout, instanceProfileRetryInterval = timeout, interval
+	}(instanceProfileWaitTimeout, instanceProfileRetryInterval)
+	instanceProfileWaitTimeout, instanceProfileRetryInterval = 50*time.Millisecond, time.Millisecond
+
+	f := &newProfileEC2{EC2: fake.New(), rejections: 2}
+	s := NewService(f)
+
+	machine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "node-0"}}
+	config := &v1alpha1.AWSMachineProviderConfig{
+		AMI:                v1alpha1.AWSResourceReference{ID: aws.String("ami-1")},
+		IAMInstanceProfile: &v1alpha1.AWSResourceReference{ID: aws.String("test-nodes")},
+	}
+
+	if _, err := s.CreateInstance(context.TODO(), "test-cluster", "token", nil, machine, config); err != nil {
+		t.Fatalf("expected the instance to be launched once its instance profile is available, got: %v", err)
+	}
+	if f.rejections != 0 {
+		t.Fatalf("expected the instance to be launched again, %d rejections left", f.rejections)
+	}
+
+	// Instance profiles that are still rejected after the timeout fail the launch.
+	f.rejections = 1 << 20
+	if _, err := s.CreateInstance(context.TODO(), "test-cluster", "other-token", nil, machine, config); !isInstanceProfileError(err) {
+		t.Fatalf("expected the instance profile to be rejected, got: %v", err)
+	}
+}
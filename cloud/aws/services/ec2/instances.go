@@ -14,10 +14,15 @@
 package ec2
 
 import (
+	"context"
+	"encoding/base64"
 	"fmt"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
 	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
 )
 
@@ -33,6 +38,12 @@ const (
 
 	// InstanceStatePending indicates the instance is pending
 	InstanceStatePending = ec2.InstanceStateNamePending
+
+	// InstanceStateStopping indicates the instance is stopping
+	InstanceStateStopping = ec2.InstanceStateNameStopping
+
+	// InstanceStateStopped indicates the instance is stopped
+	InstanceStateStopped = ec2.InstanceStateNameStopped
 )
 
 // Instance is an internal representation of an AWS instance.
@@ -42,14 +53,48 @@ type Instance struct {
 	State string
 	// ID is the AWS InstanceID.
 	ID string
+	// Tags are the tags of the instance.
+	Tags map[string]string
+	// LaunchTemplate is the launch template version the instance was run from, if known.
+	LaunchTemplate *LaunchTemplate
+	// LaunchTime is when the instance was last started, if known.
+	LaunchTime time.Time
+	// SubnetID is the subnet the instance was launched in, if known.
+	SubnetID string
+	// AvailabilityZone is the availability zone the instance was launched in, if known.
+	AvailabilityZone string
 }
 
-// InstanceIfExists returns the existing instance or nothing if it doesn't exist.
-func (s *Service) InstanceIfExists(instanceID *string) (*Instance, error) {
+// StatusChecks are the summaries of the status checks AWS runs on a running instance,
+// like "ok", "impaired" or "initializing".
+type StatusChecks struct {
+	// System is the status of the checks of the AWS systems the instance runs on.
+	System string
+	// Instance is the status of the reachability checks of the instance.
+	Instance string
+}
+
+// Impaired returns whether any of the status checks failed.
+func (c *StatusChecks) Impaired() bool {
+	return c.System == ec2.SummaryStatusImpaired || c.Instance == ec2.SummaryStatusImpaired
+}
+
+// OK returns whether all status checks passed.
+func (c *StatusChecks) OK() bool {
+	return c.System == ec2.SummaryStatusOk && c.Instance == ec2.SummaryStatusOk
+}
+
+// InstanceIfExists returns the existing instance or nothing if it doesn't exist, or no id is given.
+func (s *Service) InstanceIfExists(ctx context.Context, instanceID *string) (*Instance, error) {
+	if instanceID == nil {
+		return nil, nil
+	}
+	s = s.withContext(ctx)
+
 	input := &ec2.DescribeInstancesInput{
 		InstanceIds: []*string{instanceID},
 	}
-	out, err := s.EC2.DescribeInstances(input)
+	out, err := s.EC2.DescribeInstancesWithContext(s.ctx, input)
 
 	switch {
 	case IsNotFound(err):
@@ -59,20 +104,67 @@ func (s *Service) InstanceIfExists(instanceID *string) (*Instance, error) {
 	}
 
 	if len(out.Reservations) > 0 && len(out.Reservations[0].Instances) > 0 {
+		i := out.Reservations[0].Instances[0]
 		return &Instance{
-			State: *out.Reservations[0].Instances[0].State.Name,
-			ID:    *out.Reservations[0].Instances[0].InstanceId,
+			State:      *i.State.Name,
+			ID:         *i.InstanceId,
+			Tags:       tagsToMap(i.Tags),
+			LaunchTime: aws.TimeValue(i.LaunchTime),
 		}, nil
 	}
 
 	return nil, nil
 }
 
-// CreateInstance runs an ec2 instance.
-func (s *Service) CreateInstance(machine *clusterv1.Machine) (*Instance, error) {
-	input := &ec2.RunInstancesInput{}
+// CreateInstance runs an ec2 instance from the launch template of the machine class of the machine.
+// The launch template is created, or gets a new version, when the machine provider config changed.
+// Machines of a machine set with a warm pool start a stopped instance of the pool instead, if there is one.
+// The instance and its volumes are tagged with the cluster tag and the additional tags.
+// Instances with a subnet reference are launched in the next availability zone of its subnets
+// when a zone lacks the capacity for them.
+// Requests with the same client token run a single instance, see ClientToken.
+func (s *Service) CreateInstance(ctx context.Context, clusterName string, clientToken string, additionalTags map[string]string, machine *clusterv1.Machine, config *v1alpha1.AWSMachineProviderConfig) (*Instance, error) {
+	s = s.withContext(ctx)
+
+	data, err := s.machineLaunchTemplateData(config)
+	if err != nil {
+		return nil, err
+	}
+
+	lt, err := s.reconcileLaunchTemplate(clusterName, launchTemplateName(clusterName, machine), data)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.WarmPoolSize > 0 && machineSetName(machine) != "" {
+		instance, err := s.claimWarmInstance(clusterName, lt, additionalTags)
+		if err != nil {
+			return nil, err
+		}
+		if instance != nil {
+			s.log.V(2).Info("Claimed instance of warm pool", "machine", machine.Name, "instance-id", instance.ID,
+				"launch-template-id", lt.ID, "launch-template-version", lt.Version)
+			return instance, nil
+		}
+	}
 
-	reservation, err := s.EC2.RunInstances(input)
+	subnets, err := s.instanceSubnets(config.Subnet)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := mapToTags(s.buildTags(clusterName, ResourceLifecycleOwned, additionalTags))
+	input := &ec2.RunInstancesInput{
+		LaunchTemplate: lt.specification(),
+		MinCount:       aws.Int64(1),
+		MaxCount:       aws.Int64(1),
+		TagSpecifications: []*ec2.TagSpecification{
+			{ResourceType: aws.String(ec2.ResourceTypeInstance), Tags: tags},
+			{ResourceType: aws.String(ec2.ResourceTypeVolume), Tags: tags},
+		},
+	}
+
+	reservation, subnet, err := s.runInstance(input, clientToken, subnets)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to run instances")
 	}
@@ -81,25 +173,131 @@ func (s *Service) CreateInstance(machine *clusterv1.Machine) (*Instance, error)
 		return nil, errors.New("no instance was created after run was called")
 	}
 
-	return &Instance{
-		State: *reservation.Instances[0].State.Name,
-		ID:    *reservation.Instances[0].InstanceId,
-	}, nil
+	s.log.V(2).Info("Created new instance", "machine", machine.Name, "instance-id", reservation.Instances[0].InstanceId,
+		"launch-template-id", lt.ID, "launch-template-version", lt.Version)
+
+	instance := &Instance{
+		State:          *reservation.Instances[0].State.Name,
+		ID:             *reservation.Instances[0].InstanceId,
+		Tags:           tagsToMap(tags),
+		LaunchTemplate: lt,
+	}
+	if subnet != nil {
+		instance.SubnetID = aws.StringValue(subnet.SubnetId)
+		instance.AvailabilityZone = aws.StringValue(subnet.AvailabilityZone)
+	}
+	return instance, nil
+}
+
+// AdoptInstance brings an existing instance under the management of the cluster, by tagging it
+// and its additional tags as owned by the cluster. Instances that are going away or are already
+// used by another cluster can't be adopted.
+func (s *Service) AdoptInstance(ctx context.Context, clusterName string, instanceID string, additionalTags map[string]string) (*Instance, error) {
+	s = s.withContext(ctx)
+
+	instance, err := s.InstanceIfExists(s.ctx, aws.String(instanceID))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find instance %q to adopt", instanceID)
+	} else if instance == nil {
+		return nil, NewNotFound(errors.Errorf("could not find instance %q to adopt", instanceID))
+	}
+
+	switch instance.State {
+	case InstanceStateShuttingDown, InstanceStateTerminated:
+		return nil, errors.Errorf("failed to adopt instance %q: it is %s", instanceID, instance.State)
+	}
+
+	if err := s.checkAdoptable(clusterName, instance.ID, instance.Tags); err != nil {
+		return nil, err
+	}
+
+	s = s.withAdditionalTags(additionalTags)
+	if _, tagged := s.clusterLifecycle(clusterName, instance.Tags); !tagged {
+		if err := s.adoptResource(clusterName, instance.ID); err != nil {
+			return nil, err
+		}
+
+		for k, v := range s.buildTags(clusterName, ResourceLifecycleOwned, nil) {
+			instance.Tags[k] = v
+		}
+	}
+
+	return instance, nil
+}
+
+// ReconcileInstanceTags adds the additional tags that are missing on an instance and removes
+// the ones that were previously added but are no longer part of the additional tags.
+// Volumes are only tagged when the instance is created.
+func (s *Service) ReconcileInstanceTags(ctx context.Context, instance *Instance, additionalTags map[string]string) error {
+	s = s.withContext(ctx)
+
+	tags, err := s.withAdditionalTags(additionalTags).reconcileTags(instance.ID, instance.Tags)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update tags of instance %q", instance.ID)
+	}
+
+	instance.Tags = tags
+	return nil
 }
 
 // TerminateInstance terminates an EC2 instance.
 // Returns nil on success, error in all other cases.
-func (s *Service) TerminateInstance(instanceID *string) error {
+func (s *Service) TerminateInstance(ctx context.Context, instanceID *string) error {
+	s = s.withContext(ctx)
+
 	input := &ec2.TerminateInstancesInput{
 		InstanceIds: []*string{
 			instanceID,
 		},
 	}
 
-	_, err := s.EC2.TerminateInstances(input)
+	_, err := s.EC2.TerminateInstancesWithContext(s.ctx, input)
 	if err != nil {
 		return err
 	}
 
+	s.log.V(2).Info("Terminated instance", "instance-id", instanceID)
 	return nil
 }
+
+// ConsoleOutput returns the console output of the instance, which AWS keeps for a while after
+// the instance was terminated. It's empty until the instance wrote to its console.
+func (s *Service) ConsoleOutput(ctx context.Context, instanceID string) (string, error) {
+	s = s.withContext(ctx)
+
+	out, err := s.EC2.GetConsoleOutputWithContext(s.ctx, &ec2.GetConsoleOutputInput{InstanceId: aws.String(instanceID)})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get console output of instance %q", instanceID)
+	}
+
+	output, err := base64.StdEncoding.DecodeString(aws.StringValue(out.Output))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to decode console output of instance %q", instanceID)
+	}
+	return string(output), nil
+}
+
+// InstanceStatusChecks returns the status checks of the instance, or nothing if the instance
+// isn't running and so isn't checked.
+func (s *Service) InstanceStatusChecks(ctx context.Context, instanceID string) (*StatusChecks, error) {
+	s = s.withContext(ctx)
+
+	out, err := s.EC2.DescribeInstanceStatusWithContext(s.ctx, &ec2.DescribeInstanceStatusInput{InstanceIds: []*string{aws.String(instanceID)}})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe status of instance %q", instanceID)
+	}
+
+	if len(out.InstanceStatuses) == 0 {
+		return nil, nil
+	}
+
+	status := out.InstanceStatuses[0]
+	checks := &StatusChecks{}
+	if status.SystemStatus != nil {
+		checks.System = aws.StringValue(status.SystemStatus.Status)
+	}
+	if status.InstanceStatus != nil {
+		checks.Instance = aws.StringValue(status.InstanceStatus.Status)
+	}
+	return checks, nil
+}
@@ -14,14 +14,17 @@
 package ec2_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/golang/mock/gomock"
 	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
 	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/mock_ec2iface"
 	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
@@ -42,7 +45,7 @@ func TestInstanceIfExists(t *testing.T) {
 			instanceID: "hello",
 			expect: func(m *mock_ec2iface.MockEC2API) {
 				m.EXPECT().
-					DescribeInstances(gomock.Eq(&ec2.DescribeInstancesInput{
+					DescribeInstancesWithContext(gomock.Any(), gomock.Eq(&ec2.DescribeInstancesInput{
 						InstanceIds: []*string{aws.String("hello")},
 					})).
 					Return(nil, ec2svc.NewNotFound(errors.New("not found")))
@@ -62,7 +65,7 @@ func TestInstanceIfExists(t *testing.T) {
 			instanceID: "id-1",
 			expect: func(m *mock_ec2iface.MockEC2API) {
 				m.EXPECT().
-					DescribeInstances(gomock.Eq(&ec2.DescribeInstancesInput{
+					DescribeInstancesWithContext(gomock.Any(), gomock.Eq(&ec2.DescribeInstancesInput{
 						InstanceIds: []*string{aws.String("id-1")},
 					})).
 					Return(&ec2.DescribeInstancesOutput{
@@ -100,7 +103,7 @@ func TestInstanceIfExists(t *testing.T) {
 			instanceID: "one",
 			expect: func(m *mock_ec2iface.MockEC2API) {
 				m.EXPECT().
-					DescribeInstances(&ec2.DescribeInstancesInput{InstanceIds: []*string{aws.String("one")}}).
+					DescribeInstancesWithContext(gomock.Any(), &ec2.DescribeInstancesInput{InstanceIds: []*string{aws.String("one")}}).
 					Return(nil, errors.New("some unknown error"))
 			},
 			check: func(i *ec2svc.Instance, err error) {
@@ -116,7 +119,7 @@ func TestInstanceIfExists(t *testing.T) {
 			ec2Mock := mock_ec2iface.NewMockEC2API(mockCtrl)
 			tc.expect(ec2Mock)
 			s := ec2svc.NewService(ec2Mock)
-			instance, err := s.InstanceIfExists(&tc.instanceID)
+			instance, err := s.InstanceIfExists(context.TODO(), &tc.instanceID)
 			tc.check(instance, err)
 		})
 	}
@@ -139,7 +142,7 @@ func TestTerminateInstance(t *testing.T) {
 			instanceID: "i-exist",
 			expect: func(m *mock_ec2iface.MockEC2API) {
 				m.EXPECT().
-					TerminateInstances(gomock.Eq(&ec2.TerminateInstancesInput{
+					TerminateInstancesWithContext(gomock.Any(), gomock.Eq(&ec2.TerminateInstancesInput{
 						InstanceIds: []*string{aws.String("i-exist")},
 					})).
 					Return(&ec2.TerminateInstancesOutput{}, nil)
@@ -155,7 +158,7 @@ func TestTerminateInstance(t *testing.T) {
 			instanceID: "i-donotexist",
 			expect: func(m *mock_ec2iface.MockEC2API) {
 				m.EXPECT().
-					TerminateInstances(gomock.Eq(&ec2.TerminateInstancesInput{
+					TerminateInstancesWithContext(gomock.Any(), gomock.Eq(&ec2.TerminateInstancesInput{
 						InstanceIds: []*string{aws.String("i-donotexist")},
 					})).
 					Return(&ec2.TerminateInstancesOutput{}, instanceNotFoundError)
@@ -173,7 +176,7 @@ func TestTerminateInstance(t *testing.T) {
 			ec2Mock := mock_ec2iface.NewMockEC2API(mockCtrl)
 			tc.expect(ec2Mock)
 			s := ec2svc.NewService(ec2Mock)
-			err := s.TerminateInstance(&tc.instanceID)
+			err := s.TerminateInstance(context.TODO(), &tc.instanceID)
 			tc.check(err)
 		})
 	}
@@ -192,6 +195,7 @@ func TestCreateInstance(t *testing.T) {
 		{
 			name: "simple",
 			machine: clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Name: "aws-controlplane-0"},
 				Spec: clusterv1.MachineSpec{
 					ProviderConfig: clusterv1.ProviderConfig{
 						Value: &runtime.RawExtension{
@@ -211,7 +215,70 @@ spec:
 			},
 			expect: func(m *mock_ec2iface.MockEC2API) {
 				m.EXPECT().
-					RunInstances(&ec2.RunInstancesInput{}).
+					DescribeImagesWithContext(gomock.Any(), &ec2.DescribeImagesInput{
+						ImageIds: aws.StringSlice([]string{"ami-1"}),
+					}).
+					Return(&ec2.DescribeImagesOutput{
+						Images: []*ec2.Image{{ImageId: aws.String("ami-1"), RootDeviceName: aws.String("/dev/xvda")}},
+					}, nil)
+				m.EXPECT().
+					DescribeLaunchTemplatesWithContext(gomock.Any(), &ec2.DescribeLaunchTemplatesInput{
+						Filters: []*ec2.Filter{
+							{Name: aws.String("launch-template-name"), Values: aws.StringSlice([]string{"test-cluster-aws-controlplane-0"})},
+						},
+					}).
+					Return(&ec2.DescribeLaunchTemplatesOutput{}, nil)
+				m.EXPECT().
+					CreateLaunchTemplateWithContext(gomock.Any(), gomock.AssignableToTypeOf(&ec2.CreateLaunchTemplateInput{})).
+					Do(func(_ aws.Context, in *ec2.CreateLaunchTemplateInput) {
+						if aws.StringValue(in.LaunchTemplateName) != "test-cluster-aws-controlplane-0" {
+							t.Fatalf("unexpected launch template name: %v", aws.StringValue(in.LaunchTemplateName))
+						}
+						if aws.StringValue(in.LaunchTemplateData.ImageId) != "ami-1" || aws.StringValue(in.LaunchTemplateData.InstanceType) != "m4.xlarge" {
+							t.Fatalf("unexpected launch template data: %v", in.LaunchTemplateData)
+						}
+					}).
+					Return(&ec2.CreateLaunchTemplateOutput{
+						LaunchTemplate: &ec2.LaunchTemplate{
+							LaunchTemplateId:    aws.String("lt-1"),
+							LatestVersionNumber: aws.Int64(1),
+						},
+					}, nil)
+				m.EXPECT().
+					CreateTagsWithContext(gomock.Any(), &ec2.CreateTagsInput{
+						Resources: aws.StringSlice([]string{"lt-1"}),
+						Tags: []*ec2.Tag{
+							{Key: aws.String("kubernetes.io/cluster/test-cluster"), Value: aws.String("owned")},
+						},
+					}).
+					Return(&ec2.CreateTagsOutput{}, nil)
+				m.EXPECT().
+					RunInstancesWithContext(gomock.Any(), &ec2.RunInstancesInput{
+						LaunchTemplate: &ec2.LaunchTemplateSpecification{
+							LaunchTemplateId: aws.String("lt-1"),
+							Version:          aws.String("1"),
+						},
+						MinCount: aws.Int64(1),
+						MaxCount: aws.Int64(1),
+						TagSpecifications: []*ec2.TagSpecification{
+							{
+								ResourceType: aws.String("instance"),
+								Tags: []*ec2.Tag{
+									{Key: aws.String("cost-center"), Value: aws.String("platform")},
+									{Key: aws.String("kubernetes.io/cluster/test-cluster"), Value: aws.String("owned")},
+									{Key: aws.String("sigs.k8s.io/cluster-api-provider-aws/managed-tags"), Value: aws.String("cost-center")},
+								},
+							},
+							{
+								ResourceType: aws.String("volume"),
+								Tags: []*ec2.Tag{
+									{Key: aws.String("cost-center"), Value: aws.String("platform")},
+									{Key: aws.String("kubernetes.io/cluster/test-cluster"), Value: aws.String("owned")},
+									{Key: aws.String("sigs.k8s.io/cluster-api-provider-aws/managed-tags"), Value: aws.String("cost-center")},
+								},
+							},
+						},
+					}).
 					Return(&ec2.Reservation{
 						Instances: []*ec2.Instance{
 							&ec2.Instance{
@@ -227,6 +294,10 @@ spec:
 				if err != nil {
 					t.Fatalf("did not expect error: %v", err)
 				}
+
+				if instance.LaunchTemplate == nil || instance.LaunchTemplate.ID != "lt-1" || instance.LaunchTemplate.Version != 1 {
+					t.Fatalf("expected launch template lt-1 version 1, got: %+v", instance.LaunchTemplate)
+				}
 			},
 		},
 	}
@@ -236,7 +307,8 @@ spec:
 			ec2Mock := mock_ec2iface.NewMockEC2API(mockCtrl)
 			tc.expect(ec2Mock)
 			s := ec2svc.NewService(ec2Mock)
-			instance, err := s.CreateInstance(&tc.machine)
+			config := &v1alpha1.AWSMachineProviderConfig{AMI: v1alpha1.AWSResourceReference{ID: aws.String("ami-1")}, InstanceType: "m4.xlarge"}
+			instance, err := s.CreateInstance(context.TODO(), "test-cluster", "", map[string]string{"cost-center": "platform"}, &tc.machine, config)
 			tc.check(instance, err)
 		})
 	}
@@ -0,0 +1,131 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+)
+
+// ClusterResources are the resources tagged for a cluster, as described by the EC2 API.
+type ClusterResources struct {
+	VPCs             []*ec2.Vpc
+	Subnets          []*ec2.Subnet
+	InternetGateways []*ec2.InternetGateway
+	NatGateways      []*ec2.NatGateway
+	Addresses        []*ec2.Address
+	RouteTables      []*ec2.RouteTable
+	Instances        []*ec2.Instance
+	Volumes          []*ec2.Volume
+}
+
+// DescribeClusterResources returns all resources tagged for the cluster, whether they are owned
+// by the cluster or shared with other clusters. Resources that are going away are left out.
+func (s *Service) DescribeClusterResources(ctx context.Context, clusterName string) (*ClusterResources, error) {
+	s = s.withContext(ctx)
+
+	res := &ClusterResources{}
+
+	vpcs, err := s.EC2.DescribeVpcsWithContext(s.ctx, &ec2.DescribeVpcsInput{Filters: s.addTagFilters(clusterName, nil)})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe vpcs")
+	}
+	res.VPCs = vpcs.Vpcs
+
+	subnets, err := s.EC2.DescribeSubnetsWithContext(s.ctx, &ec2.DescribeSubnetsInput{Filters: s.addTagFilters(clusterName, nil)})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe subnets")
+	}
+	res.Subnets = subnets.Subnets
+
+	igws, err := s.EC2.DescribeInternetGatewaysWithContext(s.ctx, &ec2.DescribeInternetGatewaysInput{Filters: s.addTagFilters(clusterName, nil)})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe internet gateways")
+	}
+	res.InternetGateways = igws.InternetGateways
+
+	err = s.EC2.DescribeNatGatewaysPagesWithContext(s.ctx, &ec2.DescribeNatGatewaysInput{
+		Filter: s.addTagFilters(clusterName, []*ec2.Filter{
+			{
+				Name:   aws.String("state"),
+				Values: aws.StringSlice([]string{ec2.NatGatewayStatePending, ec2.NatGatewayStateAvailable}),
+			},
+		}),
+	}, func(page *ec2.DescribeNatGatewaysOutput, lastPage bool) bool {
+		res.NatGateways = append(res.NatGateways, page.NatGateways...)
+		return !lastPage
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe nat gateways")
+	}
+
+	addrs, err := s.EC2.DescribeAddressesWithContext(s.ctx, &ec2.DescribeAddressesInput{Filters: s.addTagFilters(clusterName, nil)})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe addresses")
+	}
+	res.Addresses = addrs.Addresses
+
+	rts, err := s.EC2.DescribeRouteTablesWithContext(s.ctx, &ec2.DescribeRouteTablesInput{Filters: s.addTagFilters(clusterName, nil)})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe route tables")
+	}
+	res.RouteTables = rts.RouteTables
+
+	instances, err := s.EC2.DescribeInstancesWithContext(s.ctx, &ec2.DescribeInstancesInput{
+		Filters: s.addTagFilters(clusterName, []*ec2.Filter{
+			{
+				Name: aws.String("instance-state-name"),
+				Values: aws.StringSlice([]string{
+					ec2.InstanceStateNamePending,
+					ec2.InstanceStateNameRunning,
+					ec2.InstanceStateNameStopping,
+					ec2.InstanceStateNameStopped,
+				}),
+			},
+		}),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe instances")
+	}
+	var instanceIDs []*string
+	for _, r := range instances.Reservations {
+		res.Instances = append(res.Instances, r.Instances...)
+		for _, i := range r.Instances {
+			instanceIDs = append(instanceIDs, i.InstanceId)
+		}
+	}
+
+	// Volumes aren't tagged, they belong to the cluster through the instances they are attached to.
+	if len(instanceIDs) > 0 {
+		err = s.EC2.DescribeVolumesPagesWithContext(s.ctx, &ec2.DescribeVolumesInput{
+			Filters: []*ec2.Filter{
+				{
+					Name:   aws.String("attachment.instance-id"),
+					Values: instanceIDs,
+				},
+			},
+		}, func(page *ec2.DescribeVolumesOutput, lastPage bool) bool {
+			res.Volumes = append(res.Volumes, page.Volumes...)
+			return !lastPage
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to describe volumes")
+		}
+	}
+
+	return res, nil
+}
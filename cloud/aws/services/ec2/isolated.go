@@ -0,0 +1,165 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+)
+
+// validateIsolatedNetwork returns an error if the network given in the spec can reach the
+// internet, see NetworkSpec.Isolated, or if a required vpc endpoint is missing.
+// Endpoints that are still pending are reported as not ready.
+func (s *Service) validateIsolatedNetwork(clusterName string, spec *v1alpha1.NetworkSpec, network *v1alpha1.Network) error {
+	vpc := &network.VPC
+
+	if public := network.Subnets.FilterPublic(); len(public) > 0 {
+		return errors.Errorf("isolated network can't have public subnets, got: %v", public)
+	}
+
+	igws, err := s.EC2.DescribeInternetGatewaysWithContext(s.ctx, &ec2.DescribeInternetGatewaysInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("attachment.vpc-id"),
+				Values: []*string{aws.String(vpc.ID)},
+			},
+		},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe internet gateways in vpc %q", vpc.ID)
+	}
+	if len(igws.InternetGateways) > 0 {
+		return errors.Errorf("isolated vpc %q is attached to internet gateway %q", vpc.ID, *igws.InternetGateways[0].InternetGatewayId)
+	}
+
+	if _, err := s.describeVpcEgressOnlyInternetGateways(vpc); err == nil {
+		return errors.Errorf("isolated vpc %q is attached to an egress-only internet gateway", vpc.ID)
+	} else if !IsNotFound(err) {
+		return err
+	}
+
+	var natGatewayIDs []string
+	err = s.EC2.DescribeNatGatewaysPagesWithContext(s.ctx, &ec2.DescribeNatGatewaysInput{
+		Filter: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(vpc.ID)},
+			},
+			{
+				Name:   aws.String("state"),
+				Values: aws.StringSlice([]string{ec2.NatGatewayStatePending, ec2.NatGatewayStateAvailable}),
+			},
+		},
+	}, func(page *ec2.DescribeNatGatewaysOutput, lastPage bool) bool {
+		for _, ng := range page.NatGateways {
+			natGatewayIDs = append(natGatewayIDs, *ng.NatGatewayId)
+		}
+		return !lastPage
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe nat gateways in vpc %q", vpc.ID)
+	}
+	if len(natGatewayIDs) > 0 {
+		return errors.Errorf("isolated vpc %q has nat gateways %v", vpc.ID, natGatewayIDs)
+	}
+
+	return s.checkVpcEndpoints(vpc, spec.RequiredVPCEndpoints)
+}
+
+// checkVpcEndpoints returns an error if an endpoint for one of the service names doesn't exist in the vpc.
+func (s *Service) checkVpcEndpoints(vpc *v1alpha1.VPC, serviceNames []string) error {
+	if len(serviceNames) == 0 {
+		return nil
+	}
+
+	states := make(map[string]string)
+	input := &ec2.DescribeVpcEndpointsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(vpc.ID)},
+			},
+		},
+	}
+	for {
+		out, err := s.EC2.DescribeVpcEndpointsWithContext(s.ctx, input)
+		if err != nil {
+			return errors.Wrapf(err, "failed to describe vpc endpoints in vpc %q", vpc.ID)
+		}
+
+		for _, ep := range out.VpcEndpoints {
+			// An available endpoint takes precedence over others for the same service.
+			if states[*ep.ServiceName] != ec2.StateAvailable {
+				states[*ep.ServiceName] = aws.StringValue(ep.State)
+			}
+		}
+
+		if aws.StringValue(out.NextToken) == "" {
+			break
+		}
+		input.NextToken = out.NextToken
+	}
+
+	var missing, pending []string
+	for _, name := range serviceNames {
+		switch states[name] {
+		case ec2.StateAvailable:
+		case ec2.StatePending, ec2.StatePendingAcceptance:
+			pending = append(pending, name)
+		default:
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return errors.Errorf("isolated vpc %q is missing vpc endpoints for %v", vpc.ID, missing)
+	} else if len(pending) > 0 {
+		return NewNotReady(errors.Errorf("vpc endpoints for %v in vpc %q are not available yet", pending, vpc.ID))
+	}
+	return nil
+}
+
+// verifyIsolatedSubnets returns an error if the network has public subnets, e.g. existing ones
+// found in the vpc, or if a route table of its subnets has a route to an internet, egress-only
+// internet or NAT gateway.
+func (s *Service) verifyIsolatedSubnets(clusterName string, network *v1alpha1.Network) error {
+	if public := network.Subnets.FilterPublic(); len(public) > 0 {
+		return errors.Errorf("isolated network can't have public subnets, got: %v", public)
+	}
+
+	subnetRouteMap, err := s.describeVpcRouteTablesBySubnet(clusterName, &network.VPC)
+	if err != nil {
+		return err
+	}
+
+	for _, sn := range network.Subnets {
+		rt, ok := subnetRouteMap[sn.ID]
+		if !ok {
+			continue
+		}
+
+		for _, r := range rt.Routes {
+			// Routes through virtual private gateways and gateway endpoints stay private.
+			if r.NatGatewayId != nil || r.EgressOnlyInternetGatewayId != nil || strings.HasPrefix(aws.StringValue(r.GatewayId), "igw-") {
+				return errors.Errorf("route table %q of isolated subnet %q has a route to the internet: %s", *rt.RouteTableId, sn.ID, r.GoString())
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/fake"
+)
+
+func TestReconcileIsolatedNetwork(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	spec := &v1alpha1.NetworkSpec{Isolated: true}
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "test-cluster", spec, nil, network)
+
+	if len(network.Subnets) != 1 || network.Subnets[0].IsPublic {
+		t.Fatalf("expected a single private subnet, got: %v", network.Subnets)
+	}
+	if c := countResources(t, f, network.VPC.ID); c.internetGateways != 0 || c.natGateways != 0 || c.routeTables != 1 {
+		t.Fatalf("expected no gateways and a single route table, got: %+v", c)
+	}
+
+	rts, err := s.describeVpcRouteTablesBySubnet("test-cluster", &network.VPC)
+	if err != nil {
+		t.Fatalf("failed to describe route tables: %v", err)
+	}
+	if routes := rts[network.Subnets[0].ID].Routes; len(routes) != 1 || aws.StringValue(routes[0].GatewayId) != "local" {
+		t.Fatalf("expected only the local route, got: %v", routes)
+	}
+
+	// A route to the internet added outside of the provider is detected.
+	ig, err := f.CreateInternetGatewayWithContext(context.TODO(), &ec2.CreateInternetGatewayInput{})
+	if err != nil {
+		t.Fatalf("failed to create internet gateway: %v", err)
+	}
+	_, err = f.CreateRouteWithContext(context.TODO(), &ec2.CreateRouteInput{
+		RouteTableId:         network.Subnets[0].RouteTableID,
+		DestinationCidrBlock: aws.String("0.0.0.0/0"),
+		GatewayId:            ig.InternetGateway.InternetGatewayId,
+	})
+	if err != nil {
+		t.Fatalf("failed to create route: %v", err)
+	}
+	if err := s.ReconcileNetwork(context.TODO(), "test-cluster", spec, nil, network); err == nil || IsNotReady(err) {
+		t.Fatalf("expected a route to the internet to be rejected, got: %v", err)
+	}
+
+	deleteNetworkUntilDone(t, s, "test-cluster", network)
+}
+
+func TestReconcileIsolatedNetworkInvalid(t *testing.T) {
+	testCases := []struct {
+		name  string
+		setup func(t *testing.T, f *fake.EC2, vpcID *string) (*v1alpha1.NetworkSpec, *v1alpha1.Network)
+	}{
+		{
+			name: "public subnet",
+			setup: func(t *testing.T, f *fake.EC2, vpcID *string) (*v1alpha1.NetworkSpec, *v1alpha1.Network) {
+				return &v1alpha1.NetworkSpec{VPCID: *vpcID, Isolated: true},
+					&v1alpha1.Network{Subnets: v1alpha1.Subnets{{CidrBlock: "10.0.1.0/24", IsPublic: true}}}
+			},
+		},
+		{
+			name: "internet gateway attached",
+			setup: func(t *testing.T, f *fake.EC2, vpcID *string) (*v1alpha1.NetworkSpec, *v1alpha1.Network) {
+				ig, err := f.CreateInternetGatewayWithContext(context.TODO(), &ec2.CreateInternetGatewayInput{})
+				if err != nil {
+					t.Fatalf("failed to create internet gateway: %v", err)
+				}
+				if _, err := f.AttachInternetGatewayWithContext(context.TODO(), &ec2.AttachInternetGatewayInput{InternetGatewayId: ig.InternetGateway.InternetGatewayId, VpcId: vpcID}); err != nil {
+					t.Fatalf("failed to attach internet gateway: %v", err)
+				}
+				return &v1alpha1.NetworkSpec{VPCID: *vpcID, Isolated: true}, &v1alpha1.Network{}
+			},
+		},
+		{
+			name: "nat gateway",
+			setup: func(t *testing.T, f *fake.EC2, vpcID *string) (*v1alpha1.NetworkSpec, *v1alpha1.Network) {
+				sn, err := f.CreateSubnetWithContext(context.TODO(), &ec2.CreateSubnetInput{VpcId: vpcID, CidrBlock: aws.String("10.0.5.0/24"), AvailabilityZone: aws.String("us-east-1a")})
+				if err != nil {
+					t.Fatalf("failed to create subnet: %v", err)
+				}
+				addr, err := f.AllocateAddressWithContext(context.TODO(), &ec2.AllocateAddressInput{Domain: aws.String("vpc")})
+				if err != nil {
+					t.Fatalf("failed to allocate address: %v", err)
+				}
+				if _, err := f.CreateNatGatewayWithContext(context.TODO(), &ec2.CreateNatGatewayInput{AllocationId: addr.AllocationId, SubnetId: sn.Subnet.SubnetId}); err != nil {
+					t.Fatalf("failed to create nat gateway: %v", err)
+				}
+				return &v1alpha1.NetworkSpec{VPCID: *vpcID, Isolated: true}, &v1alpha1.Network{}
+			},
+		},
+		{
+			name: "missing vpc endpoint",
+			setup: func(t *testing.T, f *fake.EC2, vpcID *string) (*v1alpha1.NetworkSpec, *v1alpha1.Network) {
+				if _, err := f.CreateVpcEndpoint(&ec2.CreateVpcEndpointInput{VpcId: vpcID, ServiceName: aws.String("com.amazonaws.us-east-1.s3")}); err != nil {
+					t.Fatalf("failed to create vpc endpoint: %v", err)
+				}
+				return &v1alpha1.NetworkSpec{
+					VPCID:                *vpcID,
+					Isolated:             true,
+					RequiredVPCEndpoints: []string{"com.amazonaws.us-east-1.s3", "com.amazonaws.us-east-1.ecr.dkr"},
+				}, &v1alpha1.Network{}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := fake.New()
+			s := NewService(f)
+
+			vpc, err := f.CreateVpcWithContext(context.TODO(), &ec2.CreateVpcInput{CidrBlock: aws.String(defaultVpcCidr)})
+			if err != nil {
+				t.Fatalf("failed to create vpc: %v", err)
+			}
+
+			spec, network := tc.setup(t, f, vpc.Vpc.VpcId)
+			before := countResources(t, f, *vpc.Vpc.VpcId)
+
+			if err := s.ReconcileNetwork(context.TODO(), "test-cluster", spec, nil, network); err == nil || IsNotReady(err) {
+				t.Fatalf("expected the network to be rejected, got: %v", err)
+			}
+
+			// Nothing is created when the validation fails.
+			if after := countResources(t, f, *vpc.Vpc.VpcId); after != before {
+				t.Fatalf("expected no resources to be created, before: %v, after: %v", before, after)
+			}
+		})
+	}
+}
+
+func TestReconcileIsolatedNetworkWithEndpoints(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	vpc, err := f.CreateVpcWithContext(context.TODO(), &ec2.CreateVpcInput{CidrBlock: aws.String(defaultVpcCidr)})
+	if err != nil {
+		t.Fatalf("failed to create vpc: %v", err)
+	}
+	for _, name := range []string{"com.amazonaws.us-east-1.s3", "com.amazonaws.us-east-1.ecr.dkr"} {
+		if _, err := f.CreateVpcEndpoint(&ec2.CreateVpcEndpointInput{VpcId: vpc.Vpc.VpcId, ServiceName: aws.String(name)}); err != nil {
+			t.Fatalf("failed to create vpc endpoint: %v", err)
+		}
+	}
+
+	spec := &v1alpha1.NetworkSpec{
+		VPCID:                *vpc.Vpc.VpcId,
+		Isolated:             true,
+		RequiredVPCEndpoints: []string{"com.amazonaws.us-east-1.s3", "com.amazonaws.us-east-1.ecr.dkr"},
+	}
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "test-cluster", spec, nil, network)
+
+	if network.VPC.ID != *vpc.Vpc.VpcId || network.InternetGatewayID != nil {
+		t.Fatalf("expected the existing vpc without an internet gateway, got: %+v", network)
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
+)
+
+const (
+	// launchTemplateHashPrefix prefixes the hash of the launch template data in the version description.
+	launchTemplateHashPrefix = "sha256:"
+)
+
+// LaunchTemplate is a version of the launch template that the instances of a machine class are run from.
+type LaunchTemplate struct {
+	// ID is the AWS launch template id.
+	ID string
+	// Name is the name of the launch template.
+	Name string
+	// Version is the version number of the launch template.
+	Version int64
+}
+
+// launchTemplateName returns the name of the launch template of the machine class of a machine.
+// Machines of a machine set share the launch template, other machines each get their own.
+func launchTemplateName(clusterName string, machine *clusterv1.Machine) string {
+	class := machineSetName(machine)
+	if class == "" {
+		class = machine.Name
+	}
+	return fmt.Sprintf("%s-%s", clusterName, class)
+}
+
+// machineSetName returns the name of the machine set owning a machine, or an empty string.
+func machineSetName(machine *clusterv1.Machine) string {
+	for _, ref := range machine.OwnerReferences {
+		if ref.Kind == "MachineSet" {
+			return ref.Name
+		}
+	}
+	return ""
+}
+
+// launchTemplateData returns the launch template data for a machine provider config.
+// Only resources referenced by id or, for the instance profile, by arn are set.
+func launchTemplateData(config *v1alpha1.AWSMachineProviderConfig) *ec2.RequestLaunchTemplateData {
+	data := &ec2.RequestLaunchTemplateData{
+		ImageId: config.AMI.ID,
+	}
+
+	if config.InstanceType != "" {
+		data.InstanceType = aws.String(config.InstanceType)
+	}
+
+	if config.InstanceInitiatedShutdownBehavior != "" {
+		data.InstanceInitiatedShutdownBehavior = aws.String(config.InstanceInitiatedShutdownBehavior)
+	}
+
+	if profile := config.IAMInstanceProfile; profile != nil && (profile.ARN != nil || profile.ID != nil) {
+		data.IamInstanceProfile = &ec2.LaunchTemplateIamInstanceProfileSpecificationRequest{
+			Arn:  profile.ARN,
+			Name: profile.ID,
+		}
+	}
+
+	for _, sg := range config.AdditionalSecurityGroups {
+		if sg.ID != nil {
+			data.SecurityGroupIds = append(data.SecurityGroupIds, sg.ID)
+		}
+	}
+
+	return data
+}
+
+// machineLaunchTemplateData returns the launch template data of the machine provider config.
+// The root volume is configured by the block device mapping of the root device of the AMI, which
+// is only known for AMIs referenced by id. The root volume, and whether the AMI supports the
+// instance type, are validated before anything is launched.
+func (s *Service) machineLaunchTemplateData(config *v1alpha1.AWSMachineProviderConfig) (*ec2.RequestLaunchTemplateData, error) {
+	if err := config.ValidateRootVolume(); err != nil {
+		return nil, errors.Wrap(err, "invalid root volume")
+	}
+
+	data := launchTemplateData(config)
+	if config.AMI.ID == nil {
+		if config.RootDeviceSize != 0 || config.RootVolumeType != "" || config.RootVolumeIOPS != 0 || config.RootVolumeEncrypted {
+			return nil, errors.New("failed to configure root device: the ami has no id")
+		}
+		return data, nil
+	}
+
+	out, err := s.EC2.DescribeImagesWithContext(s.ctx, &ec2.DescribeImagesInput{
+		ImageIds: []*string{config.AMI.ID},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe ami %q", *config.AMI.ID)
+	}
+	if len(out.Images) == 0 || out.Images[0].RootDeviceName == nil {
+		return nil, errors.Errorf("failed to find root device of ami %q", *config.AMI.ID)
+	}
+	if err := validateInstanceFeatures(config.InstanceType, out.Images[0]); err != nil {
+		return nil, err
+	}
+
+	ebs := &ec2.LaunchTemplateEbsBlockDeviceRequest{
+		VolumeType: aws.String(config.RootVolumeTypeOrDefault()),
+	}
+	if config.RootDeviceSize != 0 {
+		ebs.VolumeSize = aws.Int64(config.RootDeviceSize)
+	}
+	if config.RootVolumeIOPS != 0 {
+		ebs.Iops = aws.Int64(config.RootVolumeIOPS)
+	}
+	if config.RootVolumeEncrypted {
+		ebs.Encrypted = aws.Bool(true)
+	}
+
+	data.BlockDeviceMappings = []*ec2.LaunchTemplateBlockDeviceMappingRequest{
+		{
+			DeviceName: out.Images[0].RootDeviceName,
+			Ebs:        ebs,
+		},
+	}
+	return data, nil
+}
+
+// launchTemplateDataHash returns the version description identifying the launch template data.
+func launchTemplateDataHash(data *ec2.RequestLaunchTemplateData) (string, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode launch template data")
+	}
+
+	sum := sha256.Sum256(raw)
+	return launchTemplateHashPrefix + hex.EncodeToString(sum[:]), nil
+}
+
+// reconcileLaunchTemplate creates the launch template of a machine class or, if the data changed
+// since its latest version, a new version of it. It returns the version matching the data.
+func (s *Service) reconcileLaunchTemplate(clusterName string, name string, data *ec2.RequestLaunchTemplateData) (*LaunchTemplate, error) {
+	hash, err := launchTemplateDataHash(data)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.describeLaunchTemplate(name)
+	if IsNotFound(err) {
+		return s.createLaunchTemplate(clusterName, name, hash, data)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if lifecycle, _ := s.clusterLifecycle(clusterName, tagsToMap(existing.Tags)); lifecycle != ResourceLifecycleOwned {
+		return nil, NewConflict(errors.Errorf("launch template %q is not owned by cluster %q", name, clusterName))
+	}
+	if err := s.checkManager(name, tagsToMap(existing.Tags)); err != nil {
+		return nil, err
+	}
+
+	latest, err := s.EC2.DescribeLaunchTemplateVersionsWithContext(s.ctx, &ec2.DescribeLaunchTemplateVersionsInput{
+		LaunchTemplateId: existing.LaunchTemplateId,
+		Versions:         aws.StringSlice([]string{"$Latest"}),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe latest version of launch template %q", name)
+	}
+
+	if len(latest.LaunchTemplateVersions) > 0 && aws.StringValue(latest.LaunchTemplateVersions[0].VersionDescription) == hash {
+		return &LaunchTemplate{
+			ID:      *existing.LaunchTemplateId,
+			Name:    name,
+			Version: aws.Int64Value(latest.LaunchTemplateVersions[0].VersionNumber),
+		}, nil
+	}
+
+	// Names of launch templates are unique, but versions aren't: a retried request would add
+	// another version of the same data without the token.
+	var base string
+	if len(latest.LaunchTemplateVersions) > 0 {
+		base = strconv.FormatInt(aws.Int64Value(latest.LaunchTemplateVersions[0].VersionNumber), 10)
+	}
+
+	out, err := s.EC2.CreateLaunchTemplateVersionWithContext(s.ctx, &ec2.CreateLaunchTemplateVersionInput{
+		ClientToken:        aws.String(ClientToken(*existing.LaunchTemplateId, "version", base, hash)),
+		LaunchTemplateId:   existing.LaunchTemplateId,
+		VersionDescription: aws.String(hash),
+		LaunchTemplateData: data,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create new version of launch template %q", name)
+	}
+
+	version := aws.Int64Value(out.LaunchTemplateVersion.VersionNumber)
+	s.log.V(2).Info("Created new launch template version", "launch-template-id", *existing.LaunchTemplateId, "version", version)
+
+	return &LaunchTemplate{
+		ID:      *existing.LaunchTemplateId,
+		Name:    name,
+		Version: version,
+	}, nil
+}
+
+func (s *Service) createLaunchTemplate(clusterName string, name string, hash string, data *ec2.RequestLaunchTemplateData) (*LaunchTemplate, error) {
+	out, err := s.EC2.CreateLaunchTemplateWithContext(s.ctx, &ec2.CreateLaunchTemplateInput{
+		LaunchTemplateName: aws.String(name),
+		VersionDescription: aws.String(hash),
+		LaunchTemplateData: data,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create launch template %q", name)
+	}
+
+	id := *out.LaunchTemplate.LaunchTemplateId
+	if err := s.createTags(clusterName, id, ResourceLifecycleOwned, nil); err != nil {
+		return nil, err
+	}
+
+	s.log.V(2).Info("Created new launch template", "launch-template-id", id, "name", name)
+
+	return &LaunchTemplate{
+		ID:      id,
+		Name:    name,
+		Version: aws.Int64Value(out.LaunchTemplate.LatestVersionNumber),
+	}, nil
+}
+
+// describeLaunchTemplate returns the launch template with the given name.
+func (s *Service) describeLaunchTemplate(name string) (*ec2.LaunchTemplate, error) {
+	out, err := s.EC2.DescribeLaunchTemplatesWithContext(s.ctx, &ec2.DescribeLaunchTemplatesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("launch-template-name"),
+				Values: aws.StringSlice([]string{name}),
+			},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe launch template %q", name)
+	}
+
+	if len(out.LaunchTemplates) == 0 {
+		return nil, NewNotFound(errors.Errorf("could not find launch template %q", name))
+	}
+
+	return out.LaunchTemplates[0], nil
+}
+
+// DeleteLaunchTemplates deletes the launch templates owned by the cluster.
+func (s *Service) DeleteLaunchTemplates(ctx context.Context, clusterName string) error {
+	s = s.withContext(ctx)
+
+	input := &ec2.DescribeLaunchTemplatesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String(fmt.Sprintf("tag:%s", s.clusterTagKey(clusterName))),
+				Values: aws.StringSlice([]string{string(ResourceLifecycleOwned)}),
+			},
+		},
+	}
+
+	var ids []*string
+	for {
+		out, err := s.EC2.DescribeLaunchTemplatesWithContext(s.ctx, input)
+		if err != nil {
+			return errors.Wrapf(err, "failed to describe launch templates of cluster %q", clusterName)
+		}
+
+		for _, lt := range out.LaunchTemplates {
+			if err := s.checkManager(*lt.LaunchTemplateId, tagsToMap(lt.Tags)); err != nil {
+				return err
+			}
+			ids = append(ids, lt.LaunchTemplateId)
+		}
+
+		if aws.StringValue(out.NextToken) == "" {
+			break
+		}
+		input.NextToken = out.NextToken
+	}
+
+	for _, id := range ids {
+		if _, err := s.EC2.DeleteLaunchTemplateWithContext(s.ctx, &ec2.DeleteLaunchTemplateInput{LaunchTemplateId: id}); err != nil {
+			return errors.Wrapf(err, "failed to delete launch template %q", *id)
+		}
+
+		s.log.V(2).Info("Deleted launch template", "launch-template-id", *id)
+	}
+
+	return nil
+}
+
+// specification returns the specification to run instances from the launch template version.
+func (lt *LaunchTemplate) specification() *ec2.LaunchTemplateSpecification {
+	return &ec2.LaunchTemplateSpecification{
+		LaunchTemplateId: aws.String(lt.ID),
+		Version:          aws.String(strconv.FormatInt(lt.Version, 10)),
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/fake"
+	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
+)
+
+func TestCreateInstanceLaunchTemplate(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	machineInSet := func(name, set string) *clusterv1.Machine {
+		return &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			OwnerReferences: []metav1.OwnerReference{{Kind: "MachineSet", Name: set}},
+		}}
+	}
+	config := &v1alpha1.AWSMachineProviderConfig{
+		AMI:          v1alpha1.AWSResourceReference{ID: aws.String("ami-1")},
+		InstanceType: "m4.large",
+	}
+
+	create := func(machine *clusterv1.Machine, config *v1alpha1.AWSMachineProviderConfig) *Instance {
+		instance, err := s.CreateInstance(context.TODO(), "test-cluster", "", nil, machine, config)
+		if err != nil {
+			t.Fatalf("failed to create instance: %v", err)
+		}
+		return instance
+	}
+
+	// Machines of a machine set share the launch template.
+	first := create(machineInSet("workers-a", "workers"), config)
+	second := create(machineInSet("workers-b", "workers"), config)
+	if first.LaunchTemplate.Name != "test-cluster-workers" || *second.LaunchTemplate != *first.LaunchTemplate || first.LaunchTemplate.Version != 1 {
+		t.Fatalf("expected both instances to use version 1 of the same launch template, got: %+v, %+v", first.LaunchTemplate, second.LaunchTemplate)
+	}
+
+	out, err := f.DescribeInstancesWithContext(context.TODO(), &ec2.DescribeInstancesInput{InstanceIds: aws.StringSlice([]string{first.ID})})
+	if err != nil {
+		t.Fatalf("failed to describe instance: %v", err)
+	}
+	if i := out.Reservations[0].Instances[0]; aws.StringValue(i.ImageId) != "ami-1" || aws.StringValue(i.InstanceType) != "m4.large" {
+		t.Fatalf("expected the instance to be run from the launch template, got: %v", i)
+	}
+
+	// A changed config creates a new version.
+	changed := config.DeepCopy()
+	changed.InstanceType = "m4.xlarge"
+	third := create(machineInSet("workers-c", "workers"), changed)
+	if third.LaunchTemplate.ID != first.LaunchTemplate.ID || third.LaunchTemplate.Version != 2 {
+		t.Fatalf("expected version 2 of launch template %q, got: %+v", first.LaunchTemplate.ID, third.LaunchTemplate)
+	}
+	if again := create(machineInSet("workers-d", "workers"), changed); again.LaunchTemplate.Version != 2 {
+		t.Fatalf("expected version 2 to be reused, got: %+v", again.LaunchTemplate)
+	}
+
+	// Other machines get their own launch template.
+	single := create(&clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "controlplane-0"}}, config)
+	if single.LaunchTemplate.ID == first.LaunchTemplate.ID || single.LaunchTemplate.Name != "test-cluster-controlplane-0" {
+		t.Fatalf("expected a separate launch template, got: %+v", single.LaunchTemplate)
+	}
+
+	// Launch templates of other clusters are not used, even with the same machine set name.
+	if _, err := s.CreateInstance(context.TODO(), "other-cluster", "", nil, machineInSet("workers-a", "workers"), config); err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	lt, err := s.describeLaunchTemplate("test-cluster-workers")
+	if err != nil {
+		t.Fatalf("failed to describe launch template: %v", err)
+	}
+	if _, err := s.reconcileLaunchTemplate("other-cluster", *lt.LaunchTemplateName, launchTemplateData(config)); !IsConflict(err) {
+		t.Fatalf("expected a conflict for a launch template of another cluster, got: %v", err)
+	}
+
+	if err := s.DeleteLaunchTemplates(context.TODO(), "test-cluster"); err != nil {
+		t.Fatalf("failed to delete launch templates: %v", err)
+	}
+	remaining, err := f.DescribeLaunchTemplatesWithContext(context.TODO(), &ec2.DescribeLaunchTemplatesInput{})
+	if err != nil {
+		t.Fatalf("failed to describe launch templates: %v", err)
+	}
+	if len(remaining.LaunchTemplates) != 1 || aws.StringValue(remaining.LaunchTemplates[0].LaunchTemplateName) != "other-cluster-workers" {
+		t.Fatalf("expected only the launch template of the other cluster to remain, got: %v", remaining.LaunchTemplates)
+	}
+}
+
+func TestCreateInstanceRootDeviceSize(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	machine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "controlplane-0"}}
+	config := &v1alpha1.AWSMachineProviderConfig{
+		AMI:            v1alpha1.AWSResourceReference{ID: aws.String("ami-1")},
+		RootDeviceSize: 50,
+	}
+
+	instance, err := s.CreateInstance(context.TODO(), "test-cluster", "", nil, machine, config)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+
+	out, err := f.DescribeLaunchTemplateVersionsWithContext(context.TODO(), &ec2.DescribeLaunchTemplateVersionsInput{
+		LaunchTemplateId: aws.String(instance.LaunchTemplate.ID),
+		Versions:         aws.StringSlice([]string{"$Latest"}),
+	})
+	if err != nil {
+		t.Fatalf("failed to describe launch template versions: %v", err)
+	}
+	bdms := out.LaunchTemplateVersions[0].LaunchTemplateData.BlockDeviceMappings
+	if len(bdms) != 1 || aws.StringValue(bdms[0].DeviceName) != "/dev/xvda" || aws.Int64Value(bdms[0].Ebs.VolumeSize) != 50 {
+		t.Fatalf("expected the root device of the ami to be sized to 50 GiB, got: %v", bdms)
+	}
+	if aws.StringValue(bdms[0].Ebs.VolumeType) != v1alpha1.VolumeTypeGP3 || bdms[0].Ebs.Iops != nil {
+		t.Fatalf("expected the root device to default to gp3, got: %v", bdms)
+	}
+
+	// Impossible volumes are rejected before anything is launched.
+	config.RootVolumeType = v1alpha1.VolumeTypeIO1
+	if _, err := s.CreateInstance(context.TODO(), "test-cluster", "", nil, machine, config); err == nil {
+		t.Fatalf("expected an error for an io1 root volume without iops")
+	}
+	instances, err := f.DescribeInstancesWithContext(context.TODO(), &ec2.DescribeInstancesInput{})
+	if err != nil {
+		t.Fatalf("failed to describe instances: %v", err)
+	}
+	if len(instances.Reservations) != 1 {
+		t.Fatalf("expected no instance to be launched for an invalid root volume, got: %v", instances.Reservations)
+	}
+
+	// The root device is only known for AMIs referenced by id.
+	config.AMI = v1alpha1.AWSResourceReference{}
+	if _, err := s.CreateInstance(context.TODO(), "test-cluster", "", nil, machine, config); err == nil {
+		t.Fatalf("expected an error for a root device size without ami id")
+	}
+}
+
+func TestCreateInstanceRootVolumeEncrypted(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	machine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "controlplane-0"}}
+	config := &v1alpha1.AWSMachineProviderConfig{
+		AMI:                 v1alpha1.AWSResourceReference{ID: aws.String("ami-1")},
+		RootVolumeEncrypted: true,
+	}
+
+	instance, err := s.CreateInstance(context.TODO(), "test-cluster", "", nil, machine, config)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+
+	out, err := f.DescribeLaunchTemplateVersionsWithContext(context.TODO(), &ec2.DescribeLaunchTemplateVersionsInput{
+		LaunchTemplateId: aws.String(instance.LaunchTemplate.ID),
+		Versions:         aws.StringSlice([]string{"$Latest"}),
+	})
+	if err != nil {
+		t.Fatalf("failed to describe launch template versions: %v", err)
+	}
+	bdms := out.LaunchTemplateVersions[0].LaunchTemplateData.BlockDeviceMappings
+	if len(bdms) != 1 || !aws.BoolValue(bdms[0].Ebs.Encrypted) {
+		t.Fatalf("expected the root device to be encrypted, got: %v", bdms)
+	}
+
+	// The root device is only known for AMIs referenced by id.
+	config.AMI = v1alpha1.AWSResourceReference{}
+	if _, err := s.CreateInstance(context.TODO(), "test-cluster", "", nil, machine, config); err == nil {
+		t.Fatalf("expected an error for an encrypted root volume without ami id")
+	}
+}
@@ -14,96 +14,466 @@
 package ec2
 
 import (
+	"sort"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/golang/glog"
 	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
 )
 
-func (s *Service) reconcileNatGateways(subnets v1alpha1.Subnets, vpc *v1alpha1.VPC) error {
-	glog.V(2).Infof("Reconciling NAT gateways")
+func (s *Service) reconcileNatGateways(clusterName string, spec *v1alpha1.NetworkSpec, subnets v1alpha1.Subnets, vpc *v1alpha1.VPC) error {
+	s.log.V(2).Info("Reconciling NAT gateways", "vpc-id", vpc.ID)
 
 	if len(subnets.FilterPrivate()) == 0 {
-		glog.V(2).Infof("No private subnets available, skipping NAT gateways")
+		s.log.V(2).Info("No private subnets available, skipping NAT gateways")
 		return nil
 	}
 
-	existing, err := s.describeNatGatewaysBySubnet(vpc.ID)
+	existing, gone, err := s.describeNatGatewaysBySubnet(clusterName, vpc)
 	if err != nil {
 		return err
 	}
 
+	var missing v1alpha1.Subnets
 	for _, sn := range subnets.FilterPublic() {
 		if sn.ID == "" {
 			continue
 		}
 
-		if _, ok := existing[sn.ID]; ok {
+		if isLocalZone(sn.AvailabilityZone) {
+			s.log.V(2).Info("NAT gateways aren't supported in Local Zones, skipping subnet", "subnet-id", sn.ID, "zone", sn.AvailabilityZone)
+			continue
+		}
+
+		if ng, ok := existing[sn.ID]; ok {
+			tags := tagsToMap(ng.Tags)
+			if _, tagged := s.clusterLifecycle(clusterName, tags); !tagged {
+				// The addresses of a shared NAT gateway are shared as well, so that they are kept as long as the gateway.
+				for _, addr := range ng.NatGatewayAddresses {
+					if _, err := s.reconcileResourceTags(clusterName, *addr.AllocationId, nil); err != nil {
+						return errors.Wrapf(err, "failed to update tags of address %q", *addr.AllocationId)
+					}
+				}
+			}
+
+			if _, err := s.reconcileResourceTags(clusterName, *ng.NatGatewayId, tags); err != nil {
+				return errors.Wrapf(err, "failed to update tags of nat gateway %q", *ng.NatGatewayId)
+			}
+
+			sn.NatGatewayID = ng.NatGatewayId
+			sn.NatGatewayState = ng.State
+			sn.NatGatewayCreateTime = natGatewayCreateTime(ng)
+			sn.NatGatewayAllocationID = natGatewayAllocationID(ng)
+			continue
+		}
+
+		// Gateways of removed zones aren't replaced.
+		if isRemovedZone(spec, sn.AvailabilityZone) {
 			continue
 		}
 
-		ng, err := s.createNatGateway(sn.ID)
+		missing = append(missing, sn)
+	}
+
+	// Gateways that failed or were deleted are replaced with the address they had, so that the
+	// egress IP of the subnet stays the same.
+	allocations, err := s.previousAddresses(clusterName, missing, gone)
+	if err != nil {
+		return err
+	}
+
+	addrs, err := s.reserveAddresses(clusterName, spec.NatGatewayAllocationIDs, len(missing)-len(allocations))
+	if err != nil {
+		return err
+	}
+
+	// The other subnets get the reserved addresses, or new ones once they run out.
+	for _, sn := range missing {
+		if _, ok := allocations[sn.ID]; !ok && len(addrs) > 0 {
+			allocations[sn.ID], addrs = addrs[0], addrs[1:]
+		}
+	}
+
+	// Addresses that were allocated before creating a gateway failed are used before
+	// allocating new ones.
+	if err := s.unusedAddresses(clusterName, missing, allocations); err != nil {
+		return err
+	}
+
+	err = s.parallelize(len(missing), func(i int) error {
+		allocationID := allocations[missing[i].ID]
+		if allocationID == "" {
+			var err error
+			allocationID, err = s.allocateAddress(clusterName)
+			if err != nil {
+				// An address that couldn't be tagged is recorded, so that the next reconcile reuses it.
+				if allocationID != "" {
+					missing[i].NatGatewayAllocationID = aws.String(allocationID)
+				}
+				return errors.Wrapf(err, "failed to create IP address for NAT gateway for subnet ID %q", missing[i].ID)
+			}
+		}
+		// Recorded before creating the gateway, so that the next reconcile reuses the address if it fails.
+		missing[i].NatGatewayAllocationID = aws.String(allocationID)
+
+		// Gateways that failed or were deleted are replaced under a new token.
+		var goneIDs []string
+		for _, ng := range gone[missing[i].ID] {
+			goneIDs = append(goneIDs, *ng.NatGatewayId)
+		}
+		token := ClientToken(append([]string{clusterName, "nat-gateway", missing[i].ID}, goneIDs...)...)
+		ng, err := s.createNatGateway(clusterName, token, missing[i].ID, allocationID)
 		if err != nil {
 			return err
 		}
 
-		sn.NatGatewayID = ng.NatGatewayId
+		missing[i].NatGatewayID = ng.NatGatewayId
+		missing[i].NatGatewayState = ng.State
+		missing[i].NatGatewayCreateTime = natGatewayCreateTime(ng)
+		missing[i].NatGatewayAllocationID = natGatewayAllocationID(ng)
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// NAT gateways take minutes to become available. Instead of blocking, report them as
+	// not ready so that the caller can store the pending state and retry later.
+	var pending []string
+	for _, sn := range subnets.FilterPublic() {
+		if aws.StringValue(sn.NatGatewayState) == ec2.NatGatewayStatePending {
+			pending = append(pending, aws.StringValue(sn.NatGatewayID))
+		}
+	}
+
+	if len(pending) > 0 {
+		s.log.V(2).Info("NAT gateways are not available yet", "nat-gateway-ids", pending)
+		return NewNotReady(errors.Errorf("nat gateways %v are not available yet", pending))
 	}
 
 	return nil
 }
 
-func (s *Service) describeNatGatewaysBySubnet(vpcID string) (map[string]*ec2.NatGateway, error) {
+// describeNatGatewaysBySubnet returns the usable NAT gateways by subnet, and the ones that are
+// going away by subnet, sorted by id.
+func (s *Service) describeNatGatewaysBySubnet(clusterName string, vpc *v1alpha1.VPC) (map[string]*ec2.NatGateway, map[string][]*ec2.NatGateway, error) {
+	ngs, err := s.describeVpcNatGateways(clusterName, vpc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gateways := make(map[string]*ec2.NatGateway)
+	gone := make(map[string][]*ec2.NatGateway)
+	for _, r := range ngs {
+		switch aws.StringValue(r.State) {
+		case ec2.NatGatewayStateDeleting, ec2.NatGatewayStateDeleted, ec2.NatGatewayStateFailed:
+			// Gateways that are going away can't be used for routing.
+			gone[*r.SubnetId] = append(gone[*r.SubnetId], r)
+			continue
+		}
+		gateways[*r.SubnetId] = r
+	}
+
+	for _, ngs := range gone {
+		sort.Slice(ngs, func(i, j int) bool { return *ngs[i].NatGatewayId < *ngs[j].NatGatewayId })
+	}
+
+	return gateways, gone, nil
+}
+
+// previousAddresses returns the allocation ids of the Elastic IP addresses to reuse for the
+// replacement NAT gateways of the subnets, by subnet. Only addresses of the cluster that are
+// no longer associated are reused. Gateways that are still being deleted hold on to their
+// addresses, so the subnets wait for them instead of getting a new address.
+func (s *Service) previousAddresses(clusterName string, subnets v1alpha1.Subnets, gone map[string][]*ec2.NatGateway) (map[string]string, error) {
+	var ids []string
+	var deleting []string
+	for _, sn := range subnets {
+		for _, ng := range gone[sn.ID] {
+			if aws.StringValue(ng.State) == ec2.NatGatewayStateDeleting {
+				deleting = append(deleting, *ng.NatGatewayId)
+			}
+			for _, addr := range ng.NatGatewayAddresses {
+				if addr.AllocationId != nil {
+					ids = append(ids, *addr.AllocationId)
+				}
+			}
+		}
+	}
+
+	if len(deleting) > 0 {
+		s.log.V(2).Info("Waiting for NAT gateways to be deleted before replacing them", "nat-gateway-ids", deleting)
+		return nil, NewNotReady(errors.Errorf("nat gateways %v are still being deleted", deleting))
+	}
+
+	res := make(map[string]string)
+	if len(ids) == 0 {
+		return res, nil
+	}
+
+	out, err := s.EC2.DescribeAddressesWithContext(s.ctx, &ec2.DescribeAddressesInput{
+		Filters: s.addTagFilters(clusterName, []*ec2.Filter{
+			{
+				Name:   aws.String("allocation-id"),
+				Values: aws.StringSlice(ids),
+			},
+		}),
+	})
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe Elastic IP addresses %v", ids)
+	}
+
+	free := make(map[string]bool)
+	for _, addr := range out.Addresses {
+		if addr.AssociationId == nil {
+			free[*addr.AllocationId] = true
+		}
+	}
+
+	for _, sn := range subnets {
+		for _, ng := range gone[sn.ID] {
+			for _, addr := range ng.NatGatewayAddresses {
+				id := aws.StringValue(addr.AllocationId)
+				if _, ok := res[sn.ID]; !ok && free[id] {
+					res[sn.ID] = id
+					delete(free, id)
+				}
+			}
+		}
+	}
+
+	if len(res) > 0 {
+		s.log.V(2).Info("Reusing Elastic IP addresses of replaced NAT gateways", "allocation-ids", res)
+	}
+	return res, nil
+}
+
+// unusedAddresses assigns Elastic IP addresses that were allocated for NAT gateways that failed
+// to be created to the subnets without an address. The address recorded in the status of a
+// subnet is reused first, then addresses owned by the cluster that aren't associated, in case the
+// status couldn't be stored. Recorded addresses that couldn't be tagged are tagged as owned.
+func (s *Service) unusedAddresses(clusterName string, subnets v1alpha1.Subnets, allocations map[string]string) error {
+	var needed v1alpha1.Subnets
+	for _, sn := range subnets {
+		if _, ok := allocations[sn.ID]; !ok {
+			needed = append(needed, sn)
+		}
+	}
+	if len(needed) == 0 {
+		return nil
+	}
+
+	used := make(map[string]bool, len(allocations))
+	for _, id := range allocations {
+		used[id] = true
+	}
+
+	var recorded []string
+	for _, sn := range needed {
+		if sn.NatGatewayAllocationID != nil && !used[*sn.NatGatewayAllocationID] {
+			recorded = append(recorded, *sn.NatGatewayAllocationID)
+		}
+	}
+
+	free := make(map[string]*ec2.Address)
+	if len(recorded) > 0 {
+		out, err := s.EC2.DescribeAddressesWithContext(s.ctx, &ec2.DescribeAddressesInput{
+			Filters: []*ec2.Filter{
+				{
+					Name:   aws.String("allocation-id"),
+					Values: aws.StringSlice(recorded),
+				},
+			},
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to describe Elastic IP addresses %v", recorded)
+		}
+		for _, addr := range out.Addresses {
+			if addr.AssociationId == nil && len(s.otherClusterTags(clusterName, tagsToMap(addr.Tags))) == 0 {
+				free[*addr.AllocationId] = addr
+			}
+		}
+	}
+
+	var rest v1alpha1.Subnets
+	for _, sn := range needed {
+		addr, ok := free[aws.StringValue(sn.NatGatewayAllocationID)]
+		if !ok {
+			rest = append(rest, sn)
+			continue
+		}
+
+		if _, tagged := s.clusterLifecycle(clusterName, tagsToMap(addr.Tags)); !tagged {
+			if err := s.createTags(clusterName, *addr.AllocationId, ResourceLifecycleOwned, nil); err != nil {
+				return errors.Wrapf(err, "failed to tag Elastic IP address %q", *addr.AllocationId)
+			}
+		}
+
+		allocations[sn.ID] = *addr.AllocationId
+		used[*addr.AllocationId] = true
+		delete(free, *addr.AllocationId)
+		s.log.V(2).Info("Reusing recorded Elastic IP address", "subnet-id", sn.ID, "allocation-id", addr.AllocationId)
+	}
+	if len(rest) == 0 {
+		return nil
+	}
+
+	out, err := s.EC2.DescribeAddressesWithContext(s.ctx, &ec2.DescribeAddressesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("domain"),
+				Values: []*string{aws.String("vpc")},
+			},
+			{
+				Name:   aws.String("tag:" + s.clusterTagKey(clusterName)),
+				Values: []*string{aws.String(ResourceLifecycleOwned)},
+			},
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to describe Elastic IP addresses of the cluster")
+	}
+
+	var unassociated []string
+	for _, addr := range out.Addresses {
+		if addr.AssociationId == nil && !used[*addr.AllocationId] {
+			unassociated = append(unassociated, *addr.AllocationId)
+		}
+	}
+	sort.Strings(unassociated)
+
+	for _, sn := range rest {
+		if len(unassociated) == 0 {
+			break
+		}
+		allocations[sn.ID], unassociated = unassociated[0], unassociated[1:]
+		s.log.V(2).Info("Reusing unassociated Elastic IP address", "subnet-id", sn.ID, "allocation-id", allocations[sn.ID])
+	}
+	return nil
+}
+
+func (s *Service) describeVpcNatGateways(clusterName string, vpc *v1alpha1.VPC) ([]*ec2.NatGateway, error) {
 	describeNatGatewayInput := &ec2.DescribeNatGatewaysInput{
-		Filter: []*ec2.Filter{
+		Filter: s.addNetworkTagFilters(clusterName, vpc, []*ec2.Filter{
 			{
 				Name:   aws.String("vpc-id"),
-				Values: []*string{aws.String(vpcID)},
+				Values: []*string{aws.String(vpc.ID)},
 			},
-		},
+		}),
 	}
 
-	gateways := make(map[string]*ec2.NatGateway)
+	var gateways []*ec2.NatGateway
 
-	err := s.EC2.DescribeNatGatewaysPages(describeNatGatewayInput,
+	err := s.EC2.DescribeNatGatewaysPagesWithContext(s.ctx, describeNatGatewayInput,
 		func(page *ec2.DescribeNatGatewaysOutput, lastPage bool) bool {
-			for _, r := range page.NatGateways {
-				gateways[*r.SubnetId] = r
-			}
+			gateways = append(gateways, page.NatGateways...)
 			return !lastPage
 		})
 
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to describe NAT gateways with VPC ID %q", vpcID)
+		return nil, errors.Wrapf(err, "failed to describe NAT gateways with VPC ID %q", vpc.ID)
 	}
 
 	return gateways, nil
 }
 
-func (s *Service) createNatGateway(subnetID string) (*ec2.NatGateway, error) {
-	ip, err := s.allocateAddress()
+func (s *Service) deleteNatGateways(clusterName string, vpc *v1alpha1.VPC) error {
+	ngs, err := s.describeVpcNatGateways(clusterName, vpc)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create IP address for NAT gateway for subnet ID %q", subnetID)
+		return err
+	}
+
+	return s.deleteGateways(clusterName, ngs)
+}
+
+// deleteGateways deletes the NAT gateways owned by the cluster and releases the shared ones.
+func (s *Service) deleteGateways(clusterName string, ngs []*ec2.NatGateway) error {
+	var pending []string
+	for _, ng := range ngs {
+		switch aws.StringValue(ng.State) {
+		case ec2.NatGatewayStateDeleted:
+			continue
+		case ec2.NatGatewayStateDeleting:
+			if _, tagged := s.clusterLifecycle(clusterName, tagsToMap(ng.Tags)); tagged {
+				pending = append(pending, *ng.NatGatewayId)
+			}
+			continue
+		}
+
+		deleted, err := s.releaseResource(clusterName, *ng.NatGatewayId, tagsToMap(ng.Tags), func() error {
+			_, err := s.EC2.DeleteNatGatewayWithContext(s.ctx, &ec2.DeleteNatGatewayInput{
+				NatGatewayId: ng.NatGatewayId,
+			})
+			return errors.Wrapf(err, "failed to delete NAT gateway %q", *ng.NatGatewayId)
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if deleted {
+			s.log.V(2).Info("Deleted NAT gateway", "nat-gateway-id", ng.NatGatewayId, "subnet-id", ng.SubnetId)
+			pending = append(pending, *ng.NatGatewayId)
+		}
 	}
 
-	out, err := s.EC2.CreateNatGateway(&ec2.CreateNatGatewayInput{
+	// Like their creation, the deletion of NAT gateways takes minutes. Their addresses and subnets
+	// can only be deleted afterwards, so report them as not ready instead of blocking.
+	if len(pending) > 0 {
+		s.log.V(2).Info("NAT gateways are still being deleted", "nat-gateway-ids", pending)
+		return NewNotReady(errors.Errorf("nat gateways %v are still being deleted", pending))
+	}
+
+	return nil
+}
+
+// createNatGateway creates a NAT gateway in the subnet with the Elastic IP address of the allocation id.
+func (s *Service) createNatGateway(clusterName string, clientToken string, subnetID string, allocationID string) (*ec2.NatGateway, error) {
+	out, err := s.EC2.CreateNatGatewayWithContext(s.ctx, &ec2.CreateNatGatewayInput{
+		ClientToken:  aws.String(clientToken),
 		SubnetId:     aws.String(subnetID),
-		AllocationId: aws.String(ip),
+		AllocationId: aws.String(allocationID),
 	})
 
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to create NAT gateway for subnet ID %q", subnetID)
 	}
 
-	wReq := &ec2.DescribeNatGatewaysInput{NatGatewayIds: []*string{out.NatGateway.NatGatewayId}}
-	if err := s.EC2.WaitUntilNatGatewayAvailable(wReq); err != nil {
-		return nil, errors.Wrapf(err, "failed to wait for nat gateway %q in subnet %q", *out.NatGateway.NatGatewayId, subnetID)
+	if err := s.createTags(clusterName, *out.NatGateway.NatGatewayId, ResourceLifecycleOwned, nil); err != nil {
+		return nil, errors.Wrapf(err, "failed to tag nat gateway %q", *out.NatGateway.NatGatewayId)
 	}
 
+	s.log.V(2).Info("Created new NAT gateway", "nat-gateway-id", out.NatGateway.NatGatewayId, "subnet-id", subnetID, "allocation-id", allocationID)
 	return out.NatGateway, nil
 }
 
+// natGatewayAllocationID returns the allocation id of the Elastic IP address of the NAT gateway.
+func natGatewayAllocationID(ng *ec2.NatGateway) *string {
+	for _, addr := range ng.NatGatewayAddresses {
+		if addr.AllocationId != nil {
+			return addr.AllocationId
+		}
+	}
+	return nil
+}
+
+// natGatewayCreateTime returns when the NAT gateway was created, or nil if it's not known.
+func natGatewayCreateTime(ng *ec2.NatGateway) *metav1.Time {
+	if ng.CreateTime == nil {
+		return nil
+	}
+	t := metav1.NewTime(*ng.CreateTime)
+	return &t
+}
+
+// getNatGatewayForSubnet returns the NAT gateway the private subnet routes through: the one in its
+// own availability zone, as traffic across zones is charged. Subnets in a zone without a NAT gateway,
+// like Local Zones, all route through the NAT gateway of the first zone that has one. The gateway is
+// the same on every call for the same subnets.
 func (s *Service) getNatGatewayForSubnet(subnets v1alpha1.Subnets, sn *v1alpha1.Subnet) (string, error) {
 	if sn.IsPublic {
 		return "", errors.Errorf("cannot get NAT gateway for public subnet %q", sn.ID)
@@ -117,10 +487,22 @@ func (s *Service) getNatGatewayForSubnet(subnets v1alpha1.Subnets, sn *v1alpha1.
 
 		azGateways[psn.AvailabilityZone] = append(azGateways[psn.AvailabilityZone], *psn.NatGatewayID)
 	}
+	for _, gws := range azGateways {
+		sort.Strings(gws)
+	}
 
 	if gws, ok := azGateways[sn.AvailabilityZone]; ok && len(gws) > 0 {
 		return gws[0], nil
 	}
 
+	if len(azGateways) > 0 {
+		zones := make([]string, 0, len(azGateways))
+		for zone := range azGateways {
+			zones = append(zones, zone)
+		}
+		sort.Strings(zones)
+		return azGateways[zones[0]][0], nil
+	}
+
 	return "", errors.Errorf("no nat gateways are available in availability zone %q for subnet %q", sn.AvailabilityZone, sn.ID)
 }
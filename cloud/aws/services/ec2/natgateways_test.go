@@ -52,19 +52,23 @@ func TestReconcileNatGateways(t *testing.T) {
 			expect: func(m *mock_ec2iface.MockEC2API) {
 
 				m.EXPECT().
-					DescribeNatGatewaysPages(
+					DescribeNatGatewaysPagesWithContext(gomock.Any(),
 						gomock.Eq(&ec2.DescribeNatGatewaysInput{
 							Filter: []*ec2.Filter{
 								{
 									Name:   aws.String("vpc-id"),
 									Values: []*string{aws.String(subnetsVPCID)},
 								},
+								{
+									Name:   aws.String("tag-key"),
+									Values: []*string{aws.String("kubernetes.io/cluster/test-cluster")},
+								},
 							},
 						}),
 						gomock.Any()).
 					Return(nil)
 
-				m.EXPECT().CreateNatGateway(gomock.Any()).Times(0)
+				m.EXPECT().CreateNatGatewayWithContext(gomock.Any(), gomock.Any()).Times(0)
 			},
 		},
 		{
@@ -81,9 +85,9 @@ func TestReconcileNatGateways(t *testing.T) {
 			expect: func(m *mock_ec2iface.MockEC2API) {
 
 				m.EXPECT().
-					DescribeNatGatewaysPages(gomock.Any(), gomock.Any()).Times(0)
+					DescribeNatGatewaysPagesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
 
-				m.EXPECT().CreateNatGateway(gomock.Any()).Times(0)
+				m.EXPECT().CreateNatGatewayWithContext(gomock.Any(), gomock.Any()).Times(0)
 			},
 		},
 		{
@@ -107,25 +111,44 @@ func TestReconcileNatGateways(t *testing.T) {
 			expect: func(m *mock_ec2iface.MockEC2API) {
 
 				m.EXPECT().
-					DescribeNatGatewaysPages(
+					DescribeNatGatewaysPagesWithContext(gomock.Any(),
 						gomock.Eq(&ec2.DescribeNatGatewaysInput{
 							Filter: []*ec2.Filter{
 								{
 									Name:   aws.String("vpc-id"),
 									Values: []*string{aws.String(subnetsVPCID)},
 								},
+								{
+									Name:   aws.String("tag-key"),
+									Values: []*string{aws.String("kubernetes.io/cluster/test-cluster")},
+								},
 							},
 						}),
 						gomock.Any()).Return(nil)
 
 				m.EXPECT().
-					AllocateAddress(&ec2.AllocateAddressInput{Domain: aws.String("vpc")}).
+					DescribeAddressesWithContext(gomock.Any(), gomock.AssignableToTypeOf(&ec2.DescribeAddressesInput{})).
+					Return(&ec2.DescribeAddressesOutput{}, nil)
+
+				m.EXPECT().
+					AllocateAddressWithContext(gomock.Any(), &ec2.AllocateAddressInput{Domain: aws.String("vpc")}).
 					Return(&ec2.AllocateAddressOutput{
 						AllocationId: aws.String(ElasticIPAllocationID),
 					}, nil)
 
 				m.EXPECT().
-					CreateNatGateway(&ec2.CreateNatGatewayInput{
+					CreateTagsWithContext(gomock.Any(), gomock.Eq(&ec2.CreateTagsInput{
+						Resources: aws.StringSlice([]string{ElasticIPAllocationID}),
+						Tags: []*ec2.Tag{{
+							Key:   aws.String("kubernetes.io/cluster/test-cluster"),
+							Value: aws.String("owned"),
+						}},
+					})).
+					Return(nil, nil)
+
+				m.EXPECT().
+					CreateNatGatewayWithContext(gomock.Any(), &ec2.CreateNatGatewayInput{
+						ClientToken:  aws.String(ClientToken("test-cluster", "nat-gateway", "subnet-1")),
 						AllocationId: aws.String(ElasticIPAllocationID),
 						SubnetId:     aws.String("subnet-1"),
 					}).Return(&ec2.CreateNatGatewayOutput{
@@ -135,9 +158,14 @@ func TestReconcileNatGateways(t *testing.T) {
 				}, nil)
 
 				m.EXPECT().
-					WaitUntilNatGatewayAvailable(&ec2.DescribeNatGatewaysInput{
-						NatGatewayIds: []*string{aws.String("natgateway")},
-					}).Return(nil)
+					CreateTagsWithContext(gomock.Any(), gomock.Eq(&ec2.CreateTagsInput{
+						Resources: aws.StringSlice([]string{"natgateway"}),
+						Tags: []*ec2.Tag{{
+							Key:   aws.String("kubernetes.io/cluster/test-cluster"),
+							Value: aws.String("owned"),
+						}},
+					})).
+					Return(nil, nil)
 
 			},
 		},
@@ -169,31 +197,51 @@ func TestReconcileNatGateways(t *testing.T) {
 			expect: func(m *mock_ec2iface.MockEC2API) {
 
 				m.EXPECT().
-					DescribeNatGatewaysPages(
+					DescribeNatGatewaysPagesWithContext(gomock.Any(),
 						gomock.Eq(&ec2.DescribeNatGatewaysInput{
 							Filter: []*ec2.Filter{
 								{
 									Name:   aws.String("vpc-id"),
 									Values: []*string{aws.String(subnetsVPCID)},
 								},
+								{
+									Name:   aws.String("tag-key"),
+									Values: []*string{aws.String("kubernetes.io/cluster/test-cluster")},
+								},
 							},
 						}),
-						gomock.Any()).Do(func(_, y interface{}) {
+						gomock.Any()).Do(func(_, _, y interface{}) {
 					funct := y.(func(page *ec2.DescribeNatGatewaysOutput, lastPage bool) bool)
 					funct(&ec2.DescribeNatGatewaysOutput{NatGateways: []*ec2.NatGateway{&ec2.NatGateway{
 						NatGatewayId: aws.String("gateway"),
 						SubnetId:     aws.String("subnet-1"),
+						Tags:         []*ec2.Tag{{Key: aws.String("kubernetes.io/cluster/test-cluster"), Value: aws.String("owned")}},
 					}}}, true)
 				}).Return(nil)
 
 				m.EXPECT().
-					AllocateAddress(&ec2.AllocateAddressInput{Domain: aws.String("vpc")}).
+					DescribeAddressesWithContext(gomock.Any(), gomock.AssignableToTypeOf(&ec2.DescribeAddressesInput{})).
+					Return(&ec2.DescribeAddressesOutput{}, nil)
+
+				m.EXPECT().
+					AllocateAddressWithContext(gomock.Any(), &ec2.AllocateAddressInput{Domain: aws.String("vpc")}).
 					Return(&ec2.AllocateAddressOutput{
 						AllocationId: aws.String(ElasticIPAllocationID),
 					}, nil)
 
 				m.EXPECT().
-					CreateNatGateway(&ec2.CreateNatGatewayInput{
+					CreateTagsWithContext(gomock.Any(), gomock.Eq(&ec2.CreateTagsInput{
+						Resources: aws.StringSlice([]string{ElasticIPAllocationID}),
+						Tags: []*ec2.Tag{{
+							Key:   aws.String("kubernetes.io/cluster/test-cluster"),
+							Value: aws.String("owned"),
+						}},
+					})).
+					Return(nil, nil)
+
+				m.EXPECT().
+					CreateNatGatewayWithContext(gomock.Any(), &ec2.CreateNatGatewayInput{
+						ClientToken:  aws.String(ClientToken("test-cluster", "nat-gateway", "subnet-3")),
 						AllocationId: aws.String(ElasticIPAllocationID),
 						SubnetId:     aws.String("subnet-3"),
 					}).Return(&ec2.CreateNatGatewayOutput{
@@ -203,9 +251,14 @@ func TestReconcileNatGateways(t *testing.T) {
 				}, nil)
 
 				m.EXPECT().
-					WaitUntilNatGatewayAvailable(&ec2.DescribeNatGatewaysInput{
-						NatGatewayIds: []*string{aws.String("natgateway")},
-					}).Return(nil)
+					CreateTagsWithContext(gomock.Any(), gomock.Eq(&ec2.CreateTagsInput{
+						Resources: aws.StringSlice([]string{"natgateway"}),
+						Tags: []*ec2.Tag{{
+							Key:   aws.String("kubernetes.io/cluster/test-cluster"),
+							Value: aws.String("owned"),
+						}},
+					})).
+					Return(nil, nil)
 
 			},
 		},
@@ -230,26 +283,31 @@ func TestReconcileNatGateways(t *testing.T) {
 			expect: func(m *mock_ec2iface.MockEC2API) {
 
 				m.EXPECT().
-					DescribeNatGatewaysPages(
+					DescribeNatGatewaysPagesWithContext(gomock.Any(),
 						gomock.Eq(&ec2.DescribeNatGatewaysInput{
 							Filter: []*ec2.Filter{
 								{
 									Name:   aws.String("vpc-id"),
 									Values: []*string{aws.String(subnetsVPCID)},
 								},
+								{
+									Name:   aws.String("tag-key"),
+									Values: []*string{aws.String("kubernetes.io/cluster/test-cluster")},
+								},
 							},
 						}),
-						gomock.Any()).Do(func(_, y interface{}) {
+						gomock.Any()).Do(func(_, _, y interface{}) {
 					funct := y.(func(page *ec2.DescribeNatGatewaysOutput, lastPage bool) bool)
 					funct(&ec2.DescribeNatGatewaysOutput{NatGateways: []*ec2.NatGateway{&ec2.NatGateway{
 						NatGatewayId: aws.String("gateway"),
 						SubnetId:     aws.String("subnet-1"),
+						Tags:         []*ec2.Tag{{Key: aws.String("kubernetes.io/cluster/test-cluster"), Value: aws.String("owned")}},
 					}}}, true)
 				}).Return(nil)
 
-				m.EXPECT().AllocateAddress(gomock.Any()).Times(0)
+				m.EXPECT().AllocateAddressWithContext(gomock.Any(), gomock.Any()).Times(0)
 
-				m.EXPECT().CreateNatGateway(gomock.Any()).Times(0)
+				m.EXPECT().CreateNatGatewayWithContext(gomock.Any(), gomock.Any()).Times(0)
 			},
 		},
 		{
@@ -273,11 +331,11 @@ func TestReconcileNatGateways(t *testing.T) {
 			expect: func(m *mock_ec2iface.MockEC2API) {
 
 				m.EXPECT().
-					DescribeNatGatewaysPages(gomock.Any(), gomock.Any()).Times(1)
+					DescribeNatGatewaysPagesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Times(1)
 
-				m.EXPECT().AllocateAddress(gomock.Any()).Times(0)
+				m.EXPECT().AllocateAddressWithContext(gomock.Any(), gomock.Any()).Times(0)
 
-				m.EXPECT().CreateNatGateway(gomock.Any()).Times(0)
+				m.EXPECT().CreateNatGatewayWithContext(gomock.Any(), gomock.Any()).Times(0)
 			},
 		},
 	}
@@ -288,9 +346,86 @@ func TestReconcileNatGateways(t *testing.T) {
 			tc.expect(ec2Mock)
 
 			s := NewService(ec2Mock)
-			if err := s.reconcileNatGateways(tc.input, &v1alpha1.VPC{ID: subnetsVPCID}); err != nil {
+			if err := s.reconcileNatGateways("test-cluster", &v1alpha1.NetworkSpec{}, tc.input, &v1alpha1.VPC{ID: subnetsVPCID}); err != nil {
 				t.Fatalf("got an unexpected error: %v", err)
 			}
 		})
 	}
 }
+
+func TestReconcileNatGatewaysPending(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	subnets := v1alpha1.Subnets{
+		{
+			ID:               "subnet-1",
+			VpcID:            subnetsVPCID,
+			AvailabilityZone: "us-east-1a",
+			CidrBlock:        "10.0.10.0/24",
+			IsPublic:         true,
+		},
+		{
+			ID:               "subnet-2",
+			VpcID:            subnetsVPCID,
+			AvailabilityZone: "us-east-1a",
+			CidrBlock:        "10.0.12.0/24",
+			IsPublic:         false,
+		},
+	}
+
+	m := mock_ec2iface.NewMockEC2API(mockCtrl)
+	m.EXPECT().
+		DescribeNatGatewaysPagesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).
+		Do(func(_, _, y interface{}) {
+			funct := y.(func(page *ec2.DescribeNatGatewaysOutput, lastPage bool) bool)
+			funct(&ec2.DescribeNatGatewaysOutput{NatGateways: []*ec2.NatGateway{&ec2.NatGateway{
+				NatGatewayId: aws.String("gateway"),
+				SubnetId:     aws.String("subnet-1"),
+				Tags:         []*ec2.Tag{{Key: aws.String("kubernetes.io/cluster/test-cluster"), Value: aws.String("owned")}},
+				State:        aws.String(ec2.NatGatewayStatePending),
+			}}}, true)
+		}).Return(nil)
+
+	m.EXPECT().CreateNatGatewayWithContext(gomock.Any(), gomock.Any()).Times(0)
+
+	s := NewService(m)
+	if err := s.reconcileNatGateways("test-cluster", &v1alpha1.NetworkSpec{}, subnets, &v1alpha1.VPC{ID: subnetsVPCID}); !IsNotReady(err) {
+		t.Fatalf("expected a not ready error, got: %v", err)
+	}
+
+	if aws.StringValue(subnets[0].NatGatewayID) != "gateway" || aws.StringValue(subnets[0].NatGatewayState) != ec2.NatGatewayStatePending {
+		t.Fatalf("expected the pending nat gateway to be recorded, got: %+v", subnets[0])
+	}
+}
+
+func TestGetNatGatewayForSubnet(t *testing.T) {
+	subnets := v1alpha1.Subnets{
+		{ID: "subnet-public-b2", AvailabilityZone: "us-east-1b", IsPublic: true, NatGatewayID: aws.String("nat-b2")},
+		{ID: "subnet-public-b1", AvailabilityZone: "us-east-1b", IsPublic: true, NatGatewayID: aws.String("nat-b1")},
+		{ID: "subnet-public-c", AvailabilityZone: "us-east-1c", IsPublic: true, NatGatewayID: aws.String("nat-c")},
+	}
+
+	testCases := []struct {
+		zone string
+		nat  string
+	}{
+		// Subnets route through the first NAT gateway of their own zone.
+		{zone: "us-east-1b", nat: "nat-b1"},
+		{zone: "us-east-1c", nat: "nat-c"},
+		// Zones without a NAT gateway share the one of the first zone.
+		{zone: "us-east-1a", nat: "nat-b1"},
+		{zone: "us-west-2-lax-1a", nat: "nat-b1"},
+	}
+
+	s := NewService(nil)
+	for _, tc := range testCases {
+		nat, err := s.getNatGatewayForSubnet(subnets, &v1alpha1.Subnet{ID: "subnet-private", AvailabilityZone: tc.zone})
+		if err != nil {
+			t.Fatalf("failed to get nat gateway for subnet in %s: %v", tc.zone, err)
+		}
+		if nat != tc.nat {
+			t.Fatalf("expected subnet in %s to route through %s, got: %s", tc.zone, tc.nat, nat)
+		}
+	}
+}
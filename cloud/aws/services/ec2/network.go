@@ -14,38 +14,147 @@
 package ec2
 
 import (
-	"github.com/golang/glog"
+	"context"
+
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
 )
 
-func (s *Service) ReconcileNetwork(clusterName string, network *v1alpha1.Network) (err error) {
-	glog.V(2).Info("Reconciling network")
+// ReconcileNetwork creates the network of a cluster or brings it up to date.
+// The additional tags are applied to every network resource owned by the cluster.
+func (s *Service) ReconcileNetwork(ctx context.Context, clusterName string, spec *v1alpha1.NetworkSpec, additionalTags map[string]string, network *v1alpha1.Network) (err error) {
+	// Several steps look up the same resources, share their results for this reconcile.
+	s = s.withContext(ctx).withValues("cluster", clusterName).withDescribeCache().withAdditionalTags(additionalTags)
+	s.log.V(2).Info("Reconciling network")
+	timer := NewStepTimer(&network.ProvisioningSteps)
+
+	// Nothing is created in ranges that are reserved for other networks.
+	if err := validateReservedCIDRs(spec, network, s.defaultVPCCIDR); err != nil {
+		return err
+	}
+
+	// Existing network.
+	if spec.Adopt {
+		if err := s.adoptNetwork(clusterName, spec, network); err != nil {
+			return err
+		}
+	}
 
 	// VPC.
-	if err := s.reconcileVPC(clusterName, &network.VPC); err != nil {
+	if err := timer.Time("vpc", func() error { return s.reconcileVPC(clusterName, spec, &network.VPC) }); err != nil {
 		return err
 	}
 
-	// Subnets.
-	if err := s.reconcileSubnets(network); err != nil {
+	// The cidr of an existing vpc is known now.
+	if err := validateReservedCIDRs(spec, network, s.defaultVPCCIDR); err != nil {
 		return err
 	}
 
-	// Internet Gateways.
-	if err := s.reconcileInternetGateways(network); err != nil {
+	// An isolated network must not have a path to the internet to begin with.
+	if spec.Isolated {
+		if err := s.validateIsolatedNetwork(clusterName, spec, network); err != nil {
+			return err
+		}
+	}
+
+	// Subnets and Internet Gateways only depend on the VPC.
+	steps := []func() error{
+		func() error {
+			return timer.Time("subnets", func() error { return s.reconcileSubnets(clusterName, spec, network) })
+		},
+	}
+	if !spec.Isolated {
+		steps = append(steps,
+			func() error {
+				return timer.Time("internetGateways", func() error { return s.reconcileInternetGateways(clusterName, spec, network) })
+			},
+			func() error {
+				return timer.Time("egressOnlyInternetGateways", func() error { return s.reconcileEgressOnlyInternetGateways(clusterName, network) })
+			},
+		)
+	}
+	if err := s.parallelize(len(steps), func(i int) error { return steps[i]() }); err != nil {
 		return err
 	}
 
 	// NAT Gateways.
-	if err := s.reconcileNatGateways(network.Subnets, &network.VPC); err != nil {
+	if !spec.Isolated {
+		err := timer.Time("natGateways", func() error {
+			return s.reconcileNatGateways(clusterName, spec, network.Subnets, &network.VPC)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Routing tables.
+	if err := timer.Time("routeTables", func() error { return s.reconcileRouteTables(clusterName, spec, network) }); err != nil {
+		return err
+	}
+
+	// Existing subnets and routes added outside of the provider could still lead to the internet.
+	if spec.Isolated {
+		if err := s.verifyIsolatedSubnets(clusterName, network); err != nil {
+			return err
+		}
+	}
+
+	// Zones are only taken out once the rest of the network is in place.
+	if err := s.removeAvailabilityZones(clusterName, spec, network); err != nil {
+		return err
+	}
+
+	s.log.V(2).Info("Reconcile network completed successfully")
+	return nil
+}
+
+// DeleteNetwork deletes the network resources of a cluster that aren't used by other clusters.
+// Resources shared with other clusters are only released by removing the cluster tag.
+func (s *Service) DeleteNetwork(ctx context.Context, clusterName string, network *v1alpha1.Network) error {
+	s = s.withContext(ctx).withValues("cluster", clusterName)
+	s.log.V(2).Info("Deleting network")
+
+	vpc, err := s.describeVPC(clusterName, network.VPC.ID)
+	if IsNotFound(err) {
+		s.log.V(2).Info("VPC is already gone", "vpc-id", network.VPC.ID)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Security groups, e.g. of file system mount targets.
+	if err := s.deleteSecurityGroups(clusterName, vpc); err != nil {
 		return err
 	}
 
 	// Routing tables.
-	if err := s.reconcileRouteTables(network); err != nil {
+	if err := s.deleteRouteTables(clusterName, vpc); err != nil {
+		return err
+	}
+
+	// NAT Gateways and their Elastic IPs.
+	if err := s.deleteNatGateways(clusterName, vpc); err != nil {
+		return err
+	}
+
+	if err := s.releaseAddresses(clusterName); err != nil {
+		return err
+	}
+
+	// Internet Gateways.
+	if err := s.deleteInternetGateways(clusterName, vpc); err != nil {
+		return err
+	}
+
+	// Subnets.
+	if err := s.deleteSubnets(clusterName, vpc); err != nil {
+		return err
+	}
+
+	// VPC.
+	if err := s.deleteVPC(clusterName, vpc); err != nil {
 		return err
 	}
 
-	glog.V(2).Info("Renconcile network completed successfully")
+	s.log.V(2).Info("Delete network completed successfully")
 	return nil
 }
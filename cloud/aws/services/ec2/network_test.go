@@ -0,0 +1,1195 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/fake"
+)
+
+var _ EC2API = &fake.EC2{}
+
+func TestReconcileNetwork(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+
+	if network.VPC.ID == "" || network.VPC.CidrBlock != defaultVpcCidr {
+		t.Fatalf("unexpected vpc: %+v", network.VPC)
+	}
+
+	if network.InternetGatewayID == nil {
+		t.Fatalf("expected internet gateway to be set")
+	}
+
+	if len(network.Subnets.FilterPrivate()) != 1 || len(network.Subnets.FilterPublic()) != 1 {
+		t.Fatalf("expected one private and one public subnet, got: %v", network.Subnets)
+	}
+
+	for _, sn := range network.Subnets {
+		if sn.RouteTableID == nil {
+			t.Fatalf("expected subnet %q to be associated with a route table", sn.ID)
+		}
+	}
+
+	// A second reconcile of the same network must not create any new resources.
+	before := countResources(t, f, network.VPC.ID)
+	reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+	if after := countResources(t, f, network.VPC.ID); after != before {
+		t.Fatalf("expected reconcile to be idempotent, resources before: %v, after: %v", before, after)
+	}
+
+	// The ids of all resources are kept in the status once they exist.
+	for _, sn := range network.Subnets {
+		if sn.RouteTableID == nil {
+			t.Fatalf("expected the route table of subnet %q to be kept, got: %+v", sn.ID, sn)
+		}
+		if sn.IsPublic && (sn.NatGatewayID == nil || sn.NatGatewayAllocationID == nil) {
+			t.Fatalf("expected the nat gateway and address of subnet %q to be kept, got: %+v", sn.ID, sn)
+		}
+	}
+
+	// The vpc is discovered through its cluster tag when the status has been lost.
+	vpc, err := s.describeVPC("test-cluster", "")
+	if err != nil {
+		t.Fatalf("failed to describe vpc by tag: %v", err)
+	}
+	if vpc.ID != network.VPC.ID {
+		t.Fatalf("expected vpc %q, got %q", network.VPC.ID, vpc.ID)
+	}
+}
+
+func TestReconcileNetworkDefaultVPCCIDR(t *testing.T) {
+	f := fake.New()
+	s := NewService(f).WithDefaultVPCCIDR("172.20.0.0/20")
+
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+
+	if network.VPC.CidrBlock != "172.20.0.0/20" {
+		t.Fatalf("expected vpc with the default cidr, got: %+v", network.VPC)
+	}
+
+	private, public := network.Subnets.FilterPrivate(), network.Subnets.FilterPublic()
+	if len(private) != 1 || private[0].CidrBlock != "172.20.0.0/24" || len(public) != 1 || public[0].CidrBlock != "172.20.1.0/24" {
+		t.Fatalf("expected default subnets in the vpc cidr, got: %v", network.Subnets)
+	}
+
+	// Subnets of smaller vpcs are their halves.
+	privateCidrs, publicCidrs, err := defaultSubnetCidrs("10.1.2.0/24", 1)
+	if err != nil || privateCidrs[0] != "10.1.2.0/25" || publicCidrs[0] != "10.1.2.128/25" {
+		t.Fatalf("expected the halves of the vpc cidr, got: %q, %q, %v", privateCidrs, publicCidrs, err)
+	}
+	if _, _, err := defaultSubnetCidrs("10.1.2.0/28", 1); err == nil {
+		t.Fatalf("expected an error for a vpc without room for the default subnets")
+	}
+}
+
+func TestReconcileNetworkProvisioningSteps(t *testing.T) {
+	s := NewService(fake.New())
+
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+
+	want := []string{"vpc", "subnets", "internetGateways", "egressOnlyInternetGateways", "natGateways", "routeTables"}
+	if len(network.ProvisioningSteps) != len(want) {
+		t.Fatalf("expected steps %q, got: %+v", want, network.ProvisioningSteps)
+	}
+	completed := map[string]v1alpha1.ProvisioningStep{}
+	for _, step := range network.ProvisioningSteps {
+		if step.CompletionTime == nil || step.CompletionTime.Before(&step.StartTime) || step.Reconciles < 1 {
+			t.Fatalf("expected step %q to be completed, got: %+v", step.Name, step)
+		}
+		completed[step.Name] = step
+	}
+	for _, name := range want {
+		if _, ok := completed[name]; !ok {
+			t.Fatalf("expected step %q, got: %+v", name, network.ProvisioningSteps)
+		}
+	}
+
+	// The steps are kept as they were by later reconciles.
+	reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+	for _, step := range network.ProvisioningSteps {
+		if before := completed[step.Name]; !step.CompletionTime.Equal(before.CompletionTime) || step.Reconciles != before.Reconciles {
+			t.Fatalf("expected step %q to be kept, got: %+v, before: %+v", step.Name, step, before)
+		}
+	}
+}
+
+func TestReconcileNetworkAllZonesRemoved(t *testing.T) {
+	f := fake.New()
+	f.AvailabilityZones = []string{"us-east-1a"}
+	s := NewService(f)
+
+	// The subnets are never created in a removed zone, even if it's the only one.
+	spec := &v1alpha1.NetworkSpec{RemovedAvailabilityZones: []string{"us-east-1a"}}
+	network := &v1alpha1.Network{}
+	err := s.ReconcileNetwork(context.TODO(), "test-cluster", spec, nil, network)
+	for i := 0; i < 5 && IsNotReady(errors.Cause(err)); i++ {
+		err = s.ReconcileNetwork(context.TODO(), "test-cluster", spec, nil, network)
+	}
+	if !IsInvalidConfiguration(errors.Cause(err)) {
+		t.Fatalf("expected an invalid configuration error, got: %v", err)
+	}
+	if len(network.Subnets) != 0 {
+		t.Fatalf("expected no subnets, got: %v", network.Subnets)
+	}
+}
+
+func TestReconcileNetworkAvailabilityZoneCount(t *testing.T) {
+	f := fake.New()
+	f.AvailabilityZones = []string{"us-east-1a", "us-east-1b", "us-east-1c"}
+	s := NewService(f)
+
+	spec := &v1alpha1.NetworkSpec{AvailabilityZoneCount: 3, RemovedAvailabilityZones: []string{"us-east-1b"}}
+	network := &v1alpha1.Network{}
+	err := s.ReconcileNetwork(context.TODO(), "test-cluster", spec, nil, network)
+	for i := 0; i < 5 && IsNotReady(errors.Cause(err)); i++ {
+		err = s.ReconcileNetwork(context.TODO(), "test-cluster", spec, nil, network)
+	}
+	if err == nil || IsNotReady(errors.Cause(err)) {
+		t.Fatalf("expected an error for more zones than are available, got: %v", err)
+	}
+
+	spec.AvailabilityZoneCount = 2
+	reconcileNetworkUntilReady(t, s, "test-cluster", spec, nil, network)
+
+	// The subnets of zone c follow the ones of zone a.
+	expected := map[string]string{
+		"10.0.0.0/24": "us-east-1a",
+		"10.0.1.0/24": "us-east-1a",
+		"10.0.2.0/24": "us-east-1c",
+		"10.0.3.0/24": "us-east-1c",
+	}
+	if len(network.Subnets) != len(expected) || len(network.Subnets.FilterPublic()) != 2 {
+		t.Fatalf("expected a private and a public subnet in zones a and c, got: %v", network.Subnets)
+	}
+	for _, sn := range network.Subnets {
+		if expected[sn.CidrBlock] != sn.AvailabilityZone {
+			t.Fatalf("unexpected subnet %q with cidr %q in zone %q", sn.ID, sn.CidrBlock, sn.AvailabilityZone)
+		}
+	}
+
+	ngs, err := s.describeVpcNatGateways("test-cluster", &network.VPC)
+	if err != nil {
+		t.Fatalf("failed to describe nat gateways: %v", err)
+	}
+	if len(ngs) != 2 {
+		t.Fatalf("expected a nat gateway per zone, got: %v", ngs)
+	}
+}
+
+func TestReconcileNetworkConcurrently(t *testing.T) {
+	f := fake.New()
+	f.AvailabilityZones = []string{"us-east-1a", "us-east-1b", "us-east-1c"}
+	s := NewService(f).WithConcurrency(3)
+
+	network := &v1alpha1.Network{}
+	for i, zone := range f.AvailabilityZones {
+		network.Subnets = append(network.Subnets,
+			&v1alpha1.Subnet{AvailabilityZone: zone, CidrBlock: fmt.Sprintf("10.0.%d.0/24", 2*i)},
+			&v1alpha1.Subnet{AvailabilityZone: zone, CidrBlock: fmt.Sprintf("10.0.%d.0/24", 2*i+1), IsPublic: true},
+		)
+	}
+
+	reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+
+	if c := countResources(t, f, network.VPC.ID); c.subnets != 6 || c.natGateways != 3 || c.routeTables != 6 {
+		t.Fatalf("expected 6 subnets, 3 nat gateways and 6 route tables, got: %+v", c)
+	}
+
+	for _, sn := range network.Subnets {
+		if sn.ID == "" || sn.RouteTableID == nil {
+			t.Fatalf("expected subnet to be created and associated with a route table: %+v", sn)
+		}
+	}
+}
+
+func TestReconcileNetworkAdditionalTags(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, map[string]string{"cost-center": "platform"}, network)
+	checkNetworkTags(t, f, network.VPC.ID, map[string]string{"cost-center": "platform"})
+
+	// Changed and new tags are applied to the existing resources.
+	tags := map[string]string{"cost-center": "infra", "owner": "team-a"}
+	reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, tags, network)
+	checkNetworkTags(t, f, network.VPC.ID, tags)
+
+	if network.VPC.Tags["owner"] != "team-a" {
+		t.Fatalf("expected the vpc status to reflect the updated tags, got: %v", network.VPC.Tags)
+	}
+
+	// Tags that are no longer configured are removed.
+	reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, map[string]string{"cost-center": "infra"}, network)
+	checkNetworkTags(t, f, network.VPC.ID, map[string]string{"cost-center": "infra", "owner": ""})
+}
+
+func TestDeleteNetwork(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+	deleteNetworkUntilDone(t, s, "test-cluster", network)
+
+	if _, err := s.describeVPC("test-cluster", network.VPC.ID); !IsNotFound(err) {
+		t.Fatalf("expected vpc %q to be deleted, got: %v", network.VPC.ID, err)
+	}
+
+	addrs, err := f.DescribeAddressesWithContext(context.TODO(), &ec2.DescribeAddressesInput{})
+	if err != nil {
+		t.Fatalf("failed to describe addresses: %v", err)
+	}
+	if len(addrs.Addresses) != 0 {
+		t.Fatalf("expected all addresses to be released, got: %v", addrs.Addresses)
+	}
+
+	// Deleting a network that is already gone succeeds.
+	deleteNetworkUntilDone(t, s, "test-cluster", network)
+}
+
+func TestDeleteInternetGateways(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+
+	// The gateway can't be detached while the NAT gateway maps its public address.
+	if err := s.deleteInternetGateways("test-cluster", &network.VPC); !IsNotReady(err) {
+		t.Fatalf("expected detaching the internet gateway to be retried, got: %v", err)
+	}
+
+	// A gateway detached by an earlier attempt is still deleted.
+	if _, err := f.DeleteNatGatewayWithContext(context.TODO(), &ec2.DeleteNatGatewayInput{NatGatewayId: network.Subnets.FilterPublic()[0].NatGatewayID}); err != nil {
+		t.Fatalf("failed to delete nat gateway: %v", err)
+	}
+	if _, err := f.DetachInternetGatewayWithContext(context.TODO(), &ec2.DetachInternetGatewayInput{InternetGatewayId: network.InternetGatewayID, VpcId: aws.String(network.VPC.ID)}); err != nil {
+		t.Fatalf("failed to detach internet gateway: %v", err)
+	}
+	deleteNetworkUntilDone(t, s, "test-cluster", network)
+
+	igws, err := f.DescribeInternetGatewaysWithContext(context.TODO(), &ec2.DescribeInternetGatewaysInput{})
+	if err != nil {
+		t.Fatalf("failed to describe internet gateways: %v", err)
+	}
+	if len(igws.InternetGateways) != 0 {
+		t.Fatalf("expected the internet gateway to be deleted, got: %v", igws.InternetGateways)
+	}
+}
+
+func TestReconcileNetworkExistingInternetGateway(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	vpc, err := f.CreateVpcWithContext(context.TODO(), &ec2.CreateVpcInput{CidrBlock: aws.String("10.0.0.0/16")})
+	if err != nil {
+		t.Fatalf("failed to create vpc: %v", err)
+	}
+	igw, err := f.CreateInternetGatewayWithContext(context.TODO(), &ec2.CreateInternetGatewayInput{})
+	if err != nil {
+		t.Fatalf("failed to create internet gateway: %v", err)
+	}
+	id := igw.InternetGateway.InternetGatewayId
+
+	// A gateway that isn't attached to the vpc can't be used.
+	spec := &v1alpha1.NetworkSpec{VPCID: *vpc.Vpc.VpcId, InternetGatewayID: *id}
+	network := &v1alpha1.Network{}
+	err = s.ReconcileNetwork(context.TODO(), "test-cluster", spec, nil, network)
+	for i := 0; IsNotReady(err) && i < 5; i++ {
+		err = s.ReconcileNetwork(context.TODO(), "test-cluster", spec, nil, network)
+	}
+	if err == nil {
+		t.Fatalf("expected an error for an internet gateway that isn't attached")
+	}
+
+	if _, err := f.AttachInternetGatewayWithContext(context.TODO(), &ec2.AttachInternetGatewayInput{InternetGatewayId: id, VpcId: vpc.Vpc.VpcId}); err != nil {
+		t.Fatalf("failed to attach internet gateway: %v", err)
+	}
+	reconcileNetworkUntilReady(t, s, "test-cluster", spec, nil, network)
+	if aws.StringValue(network.InternetGatewayID) != *id {
+		t.Fatalf("expected internet gateway %q to be used, got: %q", *id, aws.StringValue(network.InternetGatewayID))
+	}
+
+	// The gateway is left attached when the cluster is deleted.
+	deleteNetworkUntilDone(t, s, "test-cluster", network)
+	igws, err := f.DescribeInternetGatewaysWithContext(context.TODO(), &ec2.DescribeInternetGatewaysInput{InternetGatewayIds: []*string{id}})
+	if err != nil {
+		t.Fatalf("failed to describe internet gateway: %v", err)
+	}
+	if len(igws.InternetGateways[0].Attachments) != 1 {
+		t.Fatalf("expected internet gateway %q to stay attached", *id)
+	}
+}
+
+func TestSharedNetwork(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	owner := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "cluster-a", &v1alpha1.NetworkSpec{}, map[string]string{"owner": "team-a"}, owner)
+	before := countResources(t, f, owner.VPC.ID)
+
+	// A second cluster joins the vpc and uses the existing network resources.
+	joined := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "cluster-b", &v1alpha1.NetworkSpec{VPCID: owner.VPC.ID}, map[string]string{"owner": "team-b"}, joined)
+
+	if joined.VPC.ID != owner.VPC.ID {
+		t.Fatalf("expected cluster-b to join vpc %q, got %q", owner.VPC.ID, joined.VPC.ID)
+	}
+	if after := countResources(t, f, owner.VPC.ID); after != before {
+		t.Fatalf("expected the joining cluster not to create resources, before: %v, after: %v", before, after)
+	}
+
+	// Additional tags are only applied by the owner of the resources.
+	checkNetworkTags(t, f, owner.VPC.ID, map[string]string{
+		"kubernetes.io/cluster/cluster-a": "owned",
+		"kubernetes.io/cluster/cluster-b": "shared",
+		"owner":                           "team-a",
+	})
+
+	// Deleting the owner hands the resources over to the remaining cluster.
+	deleteNetworkUntilDone(t, s, "cluster-a", owner)
+	if after := countResources(t, f, owner.VPC.ID); after != before {
+		t.Fatalf("expected the shared resources to be kept, before: %v, after: %v", before, after)
+	}
+	checkNetworkTags(t, f, owner.VPC.ID, map[string]string{
+		"kubernetes.io/cluster/cluster-a": "",
+		"kubernetes.io/cluster/cluster-b": "owned",
+	})
+
+	// The last cluster using the resources deletes them.
+	deleteNetworkUntilDone(t, s, "cluster-b", joined)
+	if c := countResources(t, f, owner.VPC.ID); c.subnets != 0 || c.routeTables != 0 {
+		t.Fatalf("expected all resources to be deleted, got: %+v", c)
+	}
+	if _, err := s.describeVPC("cluster-b", owner.VPC.ID); !IsNotFound(err) {
+		t.Fatalf("expected vpc %q to be deleted, got: %v", owner.VPC.ID, err)
+	}
+}
+
+func TestReconcileNetworkWithAllocationIDs(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	used, err := f.AllocateAddressWithContext(context.TODO(), &ec2.AllocateAddressInput{Domain: aws.String("vpc")})
+	if err != nil {
+		t.Fatalf("failed to allocate address: %v", err)
+	}
+	free, err := f.AllocateAddressWithContext(context.TODO(), &ec2.AllocateAddressInput{Domain: aws.String("vpc")})
+	if err != nil {
+		t.Fatalf("failed to allocate address: %v", err)
+	}
+
+	// Associated addresses are skipped.
+	vpc, err := f.CreateVpcWithContext(context.TODO(), &ec2.CreateVpcInput{CidrBlock: aws.String("10.10.0.0/16")})
+	if err != nil {
+		t.Fatalf("failed to create vpc: %v", err)
+	}
+	sn, err := f.CreateSubnetWithContext(context.TODO(), &ec2.CreateSubnetInput{VpcId: vpc.Vpc.VpcId, CidrBlock: aws.String("10.10.0.0/24")})
+	if err != nil {
+		t.Fatalf("failed to create subnet: %v", err)
+	}
+	if _, err := f.CreateNatGatewayWithContext(context.TODO(), &ec2.CreateNatGatewayInput{AllocationId: used.AllocationId, SubnetId: sn.Subnet.SubnetId}); err != nil {
+		t.Fatalf("failed to create nat gateway: %v", err)
+	}
+
+	spec := &v1alpha1.NetworkSpec{NatGatewayAllocationIDs: []string{*used.AllocationId, *free.AllocationId}}
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "test-cluster", spec, nil, network)
+
+	ng, _, err := s.describeNatGatewaysBySubnet("test-cluster", &network.VPC)
+	if err != nil {
+		t.Fatalf("failed to describe nat gateways: %v", err)
+	}
+	public := network.Subnets.FilterPublic()[0]
+	if addrs := ng[public.ID].NatGatewayAddresses; len(addrs) != 1 || *addrs[0].AllocationId != *free.AllocationId {
+		t.Fatalf("expected the nat gateway to use address %q, got: %v", *free.AllocationId, addrs)
+	}
+
+	// The given addresses are kept when the cluster is deleted.
+	deleteNetworkUntilDone(t, s, "test-cluster", network)
+
+	out, err := f.DescribeAddressesWithContext(context.TODO(), &ec2.DescribeAddressesInput{AllocationIds: []*string{free.AllocationId}})
+	if err != nil {
+		t.Fatalf("expected address %q to be kept, got: %v", *free.AllocationId, err)
+	}
+	if tags := tagsToMap(out.Addresses[0].Tags); len(tags) != 0 {
+		t.Fatalf("expected the cluster tag to be removed from address %q, got: %v", *free.AllocationId, tags)
+	}
+}
+
+func TestReconcileNetworkMissingVPC(t *testing.T) {
+	s := NewService(fake.New())
+
+	err := s.ReconcileNetwork(context.TODO(), "test-cluster", &v1alpha1.NetworkSpec{VPCID: "vpc-missing"}, nil, &v1alpha1.Network{})
+	if err == nil || IsNotReady(err) {
+		t.Fatalf("expected an error for a missing vpc, got: %v", err)
+	}
+}
+
+func TestReconcileNetworkIPv6(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	spec := &v1alpha1.NetworkSpec{EnableIPv6: true}
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "test-cluster", spec, nil, network)
+
+	if network.VPC.IPv6CidrBlock == "" || network.EgressOnlyInternetGatewayID == nil {
+		t.Fatalf("expected an ipv6 cidr block and an egress-only internet gateway, got: %+v", network)
+	}
+
+	rts, err := s.describeVpcRouteTablesBySubnet("test-cluster", &network.VPC)
+	if err != nil {
+		t.Fatalf("failed to describe route tables: %v", err)
+	}
+	for _, sn := range network.Subnets {
+		var target *string
+		for _, r := range rts[sn.ID].Routes {
+			if aws.StringValue(r.DestinationIpv6CidrBlock) == "::/0" {
+				target = r.EgressOnlyInternetGatewayId
+			}
+		}
+
+		if sn.IsPublic && target != nil {
+			t.Fatalf("expected public subnet %q to have no ipv6 route, got %q", sn.ID, *target)
+		} else if !sn.IsPublic && aws.StringValue(target) != *network.EgressOnlyInternetGatewayID {
+			t.Fatalf("expected ipv6 route of subnet %q to target %q, got %q", sn.ID, *network.EgressOnlyInternetGatewayID, aws.StringValue(target))
+		}
+	}
+
+	reconcileNetworkUntilReady(t, s, "test-cluster", spec, nil, network)
+	if eigws := countEgressOnlyInternetGateways(t, f); eigws != 1 {
+		t.Fatalf("expected reconcile to be idempotent, got %d egress-only internet gateways", eigws)
+	}
+
+	deleteNetworkUntilDone(t, s, "test-cluster", network)
+	if eigws := countEgressOnlyInternetGateways(t, f); eigws != 0 {
+		t.Fatalf("expected the egress-only internet gateway to be deleted with the vpc, got %d", eigws)
+	}
+}
+
+func TestReconcileNetworkRouteTableStrategy(t *testing.T) {
+	testCases := []struct {
+		strategy    v1alpha1.RouteTableStrategy
+		routeTables int
+	}{
+		{strategy: "", routeTables: 6},
+		{strategy: v1alpha1.RouteTableStrategySubnet, routeTables: 6},
+		{strategy: v1alpha1.RouteTableStrategyZone, routeTables: 4},
+		{strategy: v1alpha1.RouteTableStrategyTier, routeTables: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.strategy), func(t *testing.T) {
+			f := fake.New()
+			f.AvailabilityZones = []string{"us-east-1a", "us-east-1b"}
+			s := NewService(f)
+
+			// Two private subnets and a public one in every zone.
+			network := &v1alpha1.Network{}
+			for i, zone := range f.AvailabilityZones {
+				network.Subnets = append(network.Subnets,
+					&v1alpha1.Subnet{AvailabilityZone: zone, CidrBlock: fmt.Sprintf("10.0.%d.0/24", 3*i)},
+					&v1alpha1.Subnet{AvailabilityZone: zone, CidrBlock: fmt.Sprintf("10.0.%d.0/24", 3*i+1)},
+					&v1alpha1.Subnet{AvailabilityZone: zone, CidrBlock: fmt.Sprintf("10.0.%d.0/24", 3*i+2), IsPublic: true},
+				)
+			}
+
+			spec := &v1alpha1.NetworkSpec{RouteTableStrategy: tc.strategy}
+			reconcileNetworkUntilReady(t, s, "test-cluster", spec, nil, network)
+			if c := countResources(t, f, network.VPC.ID); c.routeTables != tc.routeTables {
+				t.Fatalf("expected %d route tables, got: %+v", tc.routeTables, c)
+			}
+
+			// A new subnet uses an existing route table if the strategy shares them.
+			network.Subnets = append(network.Subnets, &v1alpha1.Subnet{AvailabilityZone: "us-east-1a", CidrBlock: "10.0.10.0/24"})
+			reconcileNetworkUntilReady(t, s, "test-cluster", spec, nil, network)
+
+			expected := tc.routeTables
+			if tc.strategy == "" || tc.strategy == v1alpha1.RouteTableStrategySubnet {
+				expected++
+			}
+			if c := countResources(t, f, network.VPC.ID); c.routeTables != expected {
+				t.Fatalf("expected %d route tables after adding a subnet, got: %+v", expected, c)
+			}
+
+			deleteNetworkUntilDone(t, s, "test-cluster", network)
+		})
+	}
+
+	s := NewService(fake.New())
+	err := s.ReconcileNetwork(context.TODO(), "test-cluster", &v1alpha1.NetworkSpec{RouteTableStrategy: "unknown"}, nil, &v1alpha1.Network{})
+	for i := 0; IsNotReady(err) && i < 5; i++ {
+		err = s.ReconcileNetwork(context.TODO(), "test-cluster", &v1alpha1.NetworkSpec{RouteTableStrategy: "unknown"}, nil, &v1alpha1.Network{})
+	}
+	if err == nil {
+		t.Fatalf("expected an error for an unknown route table strategy")
+	}
+}
+
+func TestReconcileNetworkLocalZone(t *testing.T) {
+	f := fake.New()
+	f.AvailabilityZones = []string{"us-east-1-bos-1a", "us-east-1a"}
+	s := NewService(f)
+
+	// The default subnets are placed in the region.
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+	for _, sn := range network.Subnets {
+		if sn.AvailabilityZone != "us-east-1a" {
+			t.Fatalf("expected default subnet %q in us-east-1a, got %q", sn.ID, sn.AvailabilityZone)
+		}
+	}
+
+	// Subnets in the Local Zone don't get a NAT gateway, and route through the one of the region.
+	network.Subnets = append(network.Subnets,
+		&v1alpha1.Subnet{AvailabilityZone: "us-east-1-bos-1a", CidrBlock: "10.0.2.0/24"},
+		&v1alpha1.Subnet{AvailabilityZone: "us-east-1-bos-1a", CidrBlock: "10.0.3.0/24", IsPublic: true},
+	)
+	reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+
+	if c := countResources(t, f, network.VPC.ID); c.subnets != 4 || c.natGateways != 1 {
+		t.Fatalf("expected 4 subnets and a single nat gateway, got: %+v", c)
+	}
+
+	rts, err := s.describeVpcRouteTablesBySubnet("test-cluster", &network.VPC)
+	if err != nil {
+		t.Fatalf("failed to describe route tables: %v", err)
+	}
+	regional := network.Subnets.FilterPublic()[0].NatGatewayID
+	for _, sn := range network.Subnets.FilterPrivate() {
+		var target *string
+		for _, r := range rts[sn.ID].Routes {
+			if aws.StringValue(r.DestinationCidrBlock) == "0.0.0.0/0" {
+				target = r.NatGatewayId
+			}
+		}
+		if aws.StringValue(target) != aws.StringValue(regional) {
+			t.Fatalf("expected subnet %q to route through nat gateway %q, got %q", sn.ID, aws.StringValue(regional), aws.StringValue(target))
+		}
+	}
+
+	for zone, expected := range map[string]bool{"us-east-1a": false, "us-gov-west-1b": false, "us-west-2-lax-1a": true, "us-east-1-wl1-bos-wlz-1": true} {
+		if isLocalZone(zone) != expected {
+			t.Errorf("expected isLocalZone(%q) to be %v", zone, expected)
+		}
+	}
+}
+
+func TestReconcileNetworkReplacesNatGateway(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+
+	public := network.Subnets.FilterPublic()[0]
+	old := aws.StringValue(public.NatGatewayID)
+	ngs, _, err := s.describeNatGatewaysBySubnet("test-cluster", &network.VPC)
+	if err != nil {
+		t.Fatalf("failed to describe nat gateways: %v", err)
+	}
+	allocationID := aws.StringValue(ngs[public.ID].NatGatewayAddresses[0].AllocationId)
+
+	// A gateway deleted by hand is replaced with the same address, and the routes follow.
+	if _, err := f.DeleteNatGatewayWithContext(context.TODO(), &ec2.DeleteNatGatewayInput{NatGatewayId: aws.String(old)}); err != nil {
+		t.Fatalf("failed to delete nat gateway: %v", err)
+	}
+	reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+
+	replacement := aws.StringValue(public.NatGatewayID)
+	if replacement == "" || replacement == old {
+		t.Fatalf("expected nat gateway %q to be replaced, got: %q", old, replacement)
+	}
+
+	ngs, _, err = s.describeNatGatewaysBySubnet("test-cluster", &network.VPC)
+	if err != nil {
+		t.Fatalf("failed to describe nat gateways: %v", err)
+	}
+	if id := aws.StringValue(ngs[public.ID].NatGatewayAddresses[0].AllocationId); id != allocationID {
+		t.Fatalf("expected the replacement to use address %q, got: %q", allocationID, id)
+	}
+
+	addrs, err := f.DescribeAddressesWithContext(context.TODO(), &ec2.DescribeAddressesInput{})
+	if err != nil {
+		t.Fatalf("failed to describe addresses: %v", err)
+	}
+	if len(addrs.Addresses) != 1 {
+		t.Fatalf("expected no new address to be allocated, got: %v", addrs.Addresses)
+	}
+
+	rts, err := s.describeVpcRouteTablesBySubnet("test-cluster", &network.VPC)
+	if err != nil {
+		t.Fatalf("failed to describe route tables: %v", err)
+	}
+	private := network.Subnets.FilterPrivate()[0]
+	for _, r := range rts[private.ID].Routes {
+		if aws.StringValue(r.DestinationCidrBlock) == "0.0.0.0/0" && aws.StringValue(r.NatGatewayId) != replacement {
+			t.Fatalf("expected the default route to target nat gateway %q, got: %q", replacement, aws.StringValue(r.NatGatewayId))
+		}
+	}
+}
+
+func TestReconcileNetworkRepairsCrossZoneRoutes(t *testing.T) {
+	f := fake.New()
+	f.AvailabilityZones = []string{"us-east-1a", "us-east-1b"}
+	s := NewService(f)
+
+	spec := &v1alpha1.NetworkSpec{AvailabilityZoneCount: 2}
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "test-cluster", spec, nil, network)
+
+	nats := make(map[string]string)
+	for _, sn := range network.Subnets.FilterPublic() {
+		nats[sn.AvailabilityZone] = aws.StringValue(sn.NatGatewayID)
+	}
+	var private *v1alpha1.Subnet
+	for _, sn := range network.Subnets.FilterPrivate() {
+		if sn.AvailabilityZone == "us-east-1a" {
+			private = sn
+		}
+	}
+
+	// A route pointed at the NAT gateway of another zone by hand is repaired.
+	if _, err := f.ReplaceRouteWithContext(context.TODO(), &ec2.ReplaceRouteInput{
+		RouteTableId:         private.RouteTableID,
+		DestinationCidrBlock: aws.String("0.0.0.0/0"),
+		NatGatewayId:         aws.String(nats["us-east-1b"]),
+	}); err != nil {
+		t.Fatalf("failed to replace route: %v", err)
+	}
+	reconcileNetworkUntilReady(t, s, "test-cluster", spec, nil, network)
+
+	rts, err := s.describeVpcRouteTablesBySubnet("test-cluster", &network.VPC)
+	if err != nil {
+		t.Fatalf("failed to describe route tables: %v", err)
+	}
+	for _, r := range rts[private.ID].Routes {
+		if aws.StringValue(r.DestinationCidrBlock) == "0.0.0.0/0" && aws.StringValue(r.NatGatewayId) != nats["us-east-1a"] {
+			t.Fatalf("expected subnet %q to route through nat gateway %q of its zone, got %q", private.ID, nats["us-east-1a"], aws.StringValue(r.NatGatewayId))
+		}
+	}
+}
+
+func TestReconcileNetworkSharedRouteTable(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+
+	// A centrally managed route table replaces the one of the provider.
+	central, err := f.CreateRouteTableWithContext(context.TODO(), &ec2.CreateRouteTableInput{VpcId: aws.String(network.VPC.ID)})
+	if err != nil {
+		t.Fatalf("failed to create route table: %v", err)
+	}
+	private := network.Subnets.FilterPrivate()[0]
+	private.SharedRouteTableID = central.RouteTable.RouteTableId
+	reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+	reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+
+	out, err := f.DescribeRouteTablesWithContext(context.TODO(), &ec2.DescribeRouteTablesInput{RouteTableIds: []*string{central.RouteTable.RouteTableId}})
+	if err != nil {
+		t.Fatalf("failed to describe route table: %v", err)
+	}
+	rt := out.RouteTables[0]
+	if len(rt.Associations) != 1 || aws.StringValue(rt.Associations[0].SubnetId) != private.ID {
+		t.Fatalf("expected the shared route table to be associated with subnet %q, got: %v", private.ID, rt.Associations)
+	}
+	if len(rt.Routes) != 1 || len(rt.Tags) != 0 {
+		t.Fatalf("expected the shared route table not to be changed, got: %v", rt)
+	}
+	if aws.StringValue(private.RouteTableID) != *central.RouteTable.RouteTableId {
+		t.Fatalf("expected the subnet status to record the shared route table, got: %v", aws.StringValue(private.RouteTableID))
+	}
+
+	// Route tables that don't exist are rejected.
+	private.SharedRouteTableID = aws.String("rtb-missing")
+	if err := s.ReconcileNetwork(context.TODO(), "test-cluster", &v1alpha1.NetworkSpec{}, nil, network); err == nil {
+		t.Fatalf("expected an error for a shared route table that doesn't exist")
+	}
+}
+
+// failingNatGatewayEC2 fails to create the given number of NAT gateways.
+type failingNatGatewayEC2 struct {
+	*fake.EC2
+
+	failures int
+}
+
+func (f *failingNatGatewayEC2) CreateNatGatewayWithContext(ctx aws.Context, in *ec2.CreateNatGatewayInput, opts ...request.Option) (*ec2.CreateNatGatewayOutput, error) {
+	if f.failures > 0 {
+		f.failures--
+		return nil, awserr.New("InternalError", "An internal error has occurred", nil)
+	}
+	return f.EC2.CreateNatGatewayWithContext(ctx, in, opts...)
+}
+
+func TestReconcileNetworkResumesNatGateway(t *testing.T) {
+	for _, lost := range []bool{false, true} {
+		f := &failingNatGatewayEC2{EC2: fake.New(), failures: 1}
+		s := NewService(f)
+
+		// The address of the gateway is allocated before creating the gateway fails.
+		network := &v1alpha1.Network{}
+		err := s.ReconcileNetwork(context.TODO(), "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+		for i := 0; i < 5 && IsNotReady(err); i++ {
+			err = s.ReconcileNetwork(context.TODO(), "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+		}
+		if err == nil {
+			t.Fatalf("expected creating the nat gateway to fail")
+		}
+		recorded := network.Subnets.FilterPublic()[0].NatGatewayAllocationID
+		if recorded == nil {
+			t.Fatalf("expected the address of the nat gateway to be recorded, got: %v", network.Subnets)
+		}
+
+		// The address is reused from the status, or found by its tags if the status was lost.
+		if lost {
+			network = &v1alpha1.Network{}
+		}
+		reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+
+		addrs, err := f.DescribeAddressesWithContext(context.TODO(), &ec2.DescribeAddressesInput{})
+		if err != nil {
+			t.Fatalf("failed to describe addresses: %v", err)
+		}
+		if len(addrs.Addresses) != 1 || *addrs.Addresses[0].AllocationId != *recorded {
+			t.Fatalf("expected address %q to be reused, got: %v", *recorded, addrs.Addresses)
+		}
+		if public := network.Subnets.FilterPublic()[0]; aws.StringValue(public.NatGatewayAllocationID) != *recorded {
+			t.Fatalf("expected the nat gateway to use address %q, got: %+v", *recorded, public)
+		}
+	}
+}
+
+// failingTagsEC2 fails to tag the first resource of each of the given kinds.
+type failingTagsEC2 struct {
+	*fake.EC2
+
+	kinds map[string]bool
+}
+
+func (f *failingTagsEC2) CreateTagsWithContext(ctx aws.Context, in *ec2.CreateTagsInput, opts ...request.Option) (*ec2.CreateTagsOutput, error) {
+	for _, id := range in.Resources {
+		kind := strings.SplitN(aws.StringValue(id), "-", 2)[0]
+		if f.kinds[kind] {
+			delete(f.kinds, kind)
+			return nil, awserr.New("RequestLimitExceeded", "Request limit exceeded.", nil)
+		}
+	}
+	return f.EC2.CreateTagsWithContext(ctx, in, opts...)
+}
+
+func TestReconcileNetworkResumesTagging(t *testing.T) {
+	f := &failingTagsEC2{EC2: fake.New(), kinds: map[string]bool{"vpc": true, "subnet": true}}
+	s := NewService(f)
+
+	// Resources that failed to be tagged are recorded in the status and tagged by the next
+	// reconciles, instead of being created once more.
+	network := &v1alpha1.Network{}
+	for i := 0; i < 10; i++ {
+		if err := s.ReconcileNetwork(context.TODO(), "test-cluster", &v1alpha1.NetworkSpec{}, nil, network); err == nil {
+			break
+		}
+		if i == 9 {
+			t.Fatalf("network is still not ready after 10 reconciles")
+		}
+	}
+
+	vpcs, err := f.DescribeVpcsWithContext(context.TODO(), &ec2.DescribeVpcsInput{})
+	if err != nil {
+		t.Fatalf("failed to describe vpcs: %v", err)
+	}
+	if len(vpcs.Vpcs) != 1 || aws.StringValue(vpcs.Vpcs[0].VpcId) != network.VPC.ID {
+		t.Fatalf("expected a single vpc %q, got: %v", network.VPC.ID, vpcs.Vpcs)
+	}
+	if c := countResources(t, f.EC2, network.VPC.ID); c.subnets != 2 {
+		t.Fatalf("expected 2 subnets, got: %+v", c)
+	}
+	checkNetworkTags(t, f.EC2, network.VPC.ID, map[string]string{"kubernetes.io/cluster/test-cluster": "owned"})
+}
+
+func TestReconcileNetworkAvailabilityZones(t *testing.T) {
+	f := fake.New()
+	f.AvailabilityZones = []string{"us-east-1a", "us-east-1b"}
+	s := NewService(f)
+
+	// Private subnets of both zones share a route table through the NAT gateway of the first zone.
+	spec := &v1alpha1.NetworkSpec{RouteTableStrategy: v1alpha1.RouteTableStrategyTier}
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "test-cluster", spec, nil, network)
+
+	// A zone is added with its subnets.
+	network.Subnets = append(network.Subnets,
+		&v1alpha1.Subnet{AvailabilityZone: "us-east-1b", CidrBlock: "10.0.2.0/24"},
+		&v1alpha1.Subnet{AvailabilityZone: "us-east-1b", CidrBlock: "10.0.3.0/24", IsPublic: true},
+	)
+	reconcileNetworkUntilReady(t, s, "test-cluster", spec, nil, network)
+	if c := countResources(t, f, network.VPC.ID); c.subnets != 4 || c.natGateways != 2 || c.routeTables != 2 {
+		t.Fatalf("expected 4 subnets, 2 nat gateways and 2 route tables, got: %+v", c)
+	}
+
+	var first *v1alpha1.Subnet
+	for _, sn := range network.Subnets.FilterPrivate() {
+		if sn.AvailabilityZone == "us-east-1a" {
+			first = sn
+		}
+	}
+	run, err := f.RunInstancesWithContext(context.TODO(), &ec2.RunInstancesInput{SubnetId: aws.String(first.ID)})
+	if err != nil {
+		t.Fatalf("failed to run instance: %v", err)
+	}
+
+	// The first zone is only removed once its instances are gone.
+	spec.RemovedAvailabilityZones = []string{"us-east-1a"}
+	if err := s.ReconcileNetwork(context.TODO(), "test-cluster", spec, nil, network); !IsNotReady(err) {
+		t.Fatalf("expected the network not to be ready while instances are left in the zone, got: %v", err)
+	}
+	if c := countResources(t, f, network.VPC.ID); c.subnets != 4 {
+		t.Fatalf("expected the subnets to be kept, got: %+v", c)
+	}
+
+	if _, err := f.TerminateInstancesWithContext(context.TODO(), &ec2.TerminateInstancesInput{InstanceIds: []*string{run.Instances[0].InstanceId}}); err != nil {
+		t.Fatalf("failed to terminate instance: %v", err)
+	}
+	reconcileNetworkUntilReady(t, s, "test-cluster", spec, nil, network)
+
+	for _, sn := range network.Subnets {
+		if sn.AvailabilityZone != "us-east-1b" {
+			t.Fatalf("expected only subnets in us-east-1b to be left, got: %v", network.Subnets)
+		}
+	}
+	if c := countResources(t, f, network.VPC.ID); c.subnets != 2 || c.routeTables != 2 {
+		t.Fatalf("expected 2 subnets and 2 route tables, got: %+v", c)
+	}
+
+	addrs, err := f.DescribeAddressesWithContext(context.TODO(), &ec2.DescribeAddressesInput{})
+	if err != nil {
+		t.Fatalf("failed to describe addresses: %v", err)
+	}
+	if len(addrs.Addresses) != 1 {
+		t.Fatalf("expected the address of the removed nat gateway to be released, got: %v", addrs.Addresses)
+	}
+
+	// The remaining private subnet routes through the NAT gateway of its own zone.
+	rts, err := s.describeVpcRouteTablesBySubnet("test-cluster", &network.VPC)
+	if err != nil {
+		t.Fatalf("failed to describe route tables: %v", err)
+	}
+	private := network.Subnets.FilterPrivate()[0]
+	expected := aws.StringValue(network.Subnets.FilterPublic()[0].NatGatewayID)
+	for _, r := range rts[private.ID].Routes {
+		if aws.StringValue(r.DestinationCidrBlock) == "0.0.0.0/0" && aws.StringValue(r.NatGatewayId) != expected {
+			t.Fatalf("expected subnet %q to route through nat gateway %q, got %q", private.ID, expected, aws.StringValue(r.NatGatewayId))
+		}
+	}
+
+	// The last zone can't be removed.
+	spec.RemovedAvailabilityZones = append(spec.RemovedAvailabilityZones, "us-east-1b")
+	if err := s.ReconcileNetwork(context.TODO(), "test-cluster", spec, nil, network); err == nil || IsNotReady(err) {
+		t.Fatalf("expected an error when removing all zones, got: %v", err)
+	}
+}
+
+func TestReconcileRouteTablesWithFake(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	network := &v1alpha1.Network{}
+	if err := s.reconcileVPC("test-cluster", &v1alpha1.NetworkSpec{}, &network.VPC); !IsNotReady(err) {
+		t.Fatalf("expected a new vpc to be pending, got: %v", err)
+	}
+	if err := s.reconcileVPC("test-cluster", &v1alpha1.NetworkSpec{}, &network.VPC); err != nil {
+		t.Fatalf("failed to reconcile vpc: %v", err)
+	}
+	if err := s.reconcileSubnets("test-cluster", &v1alpha1.NetworkSpec{}, network); err != nil {
+		t.Fatalf("failed to reconcile subnets: %v", err)
+	}
+
+	// Without internet and NAT gateways no route table can be created.
+	if err := s.reconcileRouteTables("test-cluster", &v1alpha1.NetworkSpec{}, network); err == nil {
+		t.Fatalf("expected an error reconciling route tables without gateways")
+	}
+
+	if err := s.reconcileInternetGateways("test-cluster", &v1alpha1.NetworkSpec{}, network); err != nil {
+		t.Fatalf("failed to reconcile internet gateways: %v", err)
+	}
+	if err := s.reconcileNatGateways("test-cluster", &v1alpha1.NetworkSpec{}, network.Subnets, &network.VPC); !IsNotReady(err) {
+		t.Fatalf("expected new nat gateways to be pending, got: %v", err)
+	}
+	if err := s.reconcileNatGateways("test-cluster", &v1alpha1.NetworkSpec{}, network.Subnets, &network.VPC); err != nil {
+		t.Fatalf("failed to reconcile nat gateways: %v", err)
+	}
+	if err := s.reconcileRouteTables("test-cluster", &v1alpha1.NetworkSpec{}, network); err != nil {
+		t.Fatalf("failed to reconcile route tables: %v", err)
+	}
+
+	rts, err := s.describeVpcRouteTablesBySubnet("test-cluster", &network.VPC)
+	if err != nil {
+		t.Fatalf("failed to describe route tables: %v", err)
+	}
+
+	for _, sn := range network.Subnets {
+		rt, ok := rts[sn.ID]
+		if !ok {
+			t.Fatalf("subnet %q has no route table", sn.ID)
+		}
+
+		var target *string
+		for _, r := range rt.Routes {
+			if aws.StringValue(r.DestinationCidrBlock) == "0.0.0.0/0" {
+				target = r.GatewayId
+				if r.NatGatewayId != nil {
+					target = r.NatGatewayId
+				}
+			}
+		}
+
+		expected := network.InternetGatewayID
+		if !sn.IsPublic {
+			expected = network.Subnets.FilterPublic()[0].NatGatewayID
+		}
+		if aws.StringValue(target) != aws.StringValue(expected) {
+			t.Fatalf("expected default route of subnet %q to target %q, got %q", sn.ID, aws.StringValue(expected), aws.StringValue(target))
+		}
+	}
+}
+
+func TestReconcileNetworkVPCChanges(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	spec := &v1alpha1.NetworkSpec{}
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "test-cluster", spec, nil, network)
+
+	// Changes of the spec of a running cluster are applied to its vpc.
+	spec.EnableDNSHostnames = aws.Bool(true)
+	spec.SecondaryCIDRBlocks = []string{"10.1.0.0/16"}
+	if err := s.ReconcileNetwork(context.TODO(), "test-cluster", spec, nil, network); !IsNotReady(err) {
+		t.Fatalf("expected the network not to be ready while the cidr block is associated, got: %v", err)
+	}
+	reconcileNetworkUntilReady(t, s, "test-cluster", spec, nil, network)
+
+	if len(network.VPC.SecondaryCidrBlocks) != 1 || network.VPC.SecondaryCidrBlocks[0] != "10.1.0.0/16" {
+		t.Fatalf("expected the secondary cidr block to be associated, got: %v", network.VPC.SecondaryCidrBlocks)
+	}
+	attr, err := f.DescribeVpcAttributeWithContext(context.TODO(), &ec2.DescribeVpcAttributeInput{
+		VpcId:     aws.String(network.VPC.ID),
+		Attribute: aws.String(ec2.VpcAttributeNameEnableDnsHostnames),
+	})
+	if err != nil {
+		t.Fatalf("failed to describe vpc attribute: %v", err)
+	}
+	if !aws.BoolValue(attr.EnableDnsHostnames.Value) {
+		t.Fatalf("expected dns hostnames to be enabled")
+	}
+
+	// The vpc isn't changed by clusters that share it.
+	other := &v1alpha1.Network{}
+	otherSpec := &v1alpha1.NetworkSpec{VPCID: network.VPC.ID, EnableDNSHostnames: aws.Bool(false), SecondaryCIDRBlocks: []string{"10.2.0.0/16"}}
+	reconcileNetworkUntilReady(t, s, "other-cluster", otherSpec, nil, other)
+	if len(other.VPC.SecondaryCidrBlocks) != 1 {
+		t.Fatalf("expected the shared vpc to be left alone, got: %v", other.VPC.SecondaryCidrBlocks)
+	}
+}
+
+func TestReconcileNetworkManagerConflict(t *testing.T) {
+	f := fake.New()
+
+	// Resources created before a manager name was configured are taken over.
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, NewService(f), "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+
+	a := NewService(f).WithManager("management-a")
+	reconcileNetworkUntilReady(t, a, "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+	vpc, err := a.describeVPC("test-cluster", network.VPC.ID)
+	if err != nil {
+		t.Fatalf("failed to describe vpc: %v", err)
+	}
+	if vpc.Tags[TagNameManager] != "management-a" {
+		t.Fatalf("expected the vpc to be tagged for its manager, got: %v", vpc.Tags)
+	}
+
+	// Another management cluster with a cluster of the same name neither changes nor deletes them.
+	b := NewService(f).WithManager("management-b")
+	other := network.DeepCopy()
+	if err := b.ReconcileNetwork(context.TODO(), "test-cluster", &v1alpha1.NetworkSpec{}, map[string]string{"owner": "team-b"}, other); !IsConflict(errors.Cause(err)) {
+		t.Fatalf("expected a conflict, got: %v", err)
+	}
+	if err := b.DeleteNetwork(context.TODO(), "test-cluster", other); !IsConflict(errors.Cause(err)) {
+		t.Fatalf("expected a conflict, got: %v", err)
+	}
+	if vpc, err := a.describeVPC("test-cluster", network.VPC.ID); err != nil || vpc.Tags["owner"] != "" {
+		t.Fatalf("expected the vpc to be left alone, got: %v, %v", vpc, err)
+	}
+
+	deleteNetworkUntilDone(t, a, "test-cluster", network)
+}
+
+// reconcileNetworkUntilReady reconciles the network until no resources are pending anymore.
+func reconcileNetworkUntilReady(t *testing.T, s *Service, clusterName string, spec *v1alpha1.NetworkSpec, additionalTags map[string]string, network *v1alpha1.Network) {
+	for i := 0; i < 5; i++ {
+		err := s.ReconcileNetwork(context.TODO(), clusterName, spec, additionalTags, network)
+		if err == nil {
+			return
+		}
+
+		if !IsNotReady(err) {
+			t.Fatalf("failed to reconcile network: %v", err)
+		}
+	}
+
+	t.Fatalf("network is still not ready after 5 reconciles")
+}
+
+// deleteNetworkUntilDone deletes the network until no resources are pending anymore.
+func deleteNetworkUntilDone(t *testing.T, s *Service, clusterName string, network *v1alpha1.Network) {
+	for i := 0; i < 5; i++ {
+		err := s.DeleteNetwork(context.TODO(), clusterName, network)
+		if err == nil {
+			return
+		}
+
+		if !IsNotReady(err) {
+			t.Fatalf("failed to delete network: %v", err)
+		}
+	}
+
+	t.Fatalf("network is still not deleted after 5 attempts")
+}
+
+type resourceCount struct {
+	subnets, internetGateways, natGateways, routeTables int
+}
+
+func countResources(t *testing.T, f *fake.EC2, vpcID string) resourceCount {
+	filters := []*ec2.Filter{{Name: aws.String("vpc-id"), Values: aws.StringSlice([]string{vpcID})}}
+
+	sns, err := f.DescribeSubnetsWithContext(context.TODO(), &ec2.DescribeSubnetsInput{Filters: filters})
+	if err != nil {
+		t.Fatalf("failed to describe subnets: %v", err)
+	}
+
+	igws, err := f.DescribeInternetGatewaysWithContext(context.TODO(), &ec2.DescribeInternetGatewaysInput{})
+	if err != nil {
+		t.Fatalf("failed to describe internet gateways: %v", err)
+	}
+
+	var ngws int
+	err = f.DescribeNatGatewaysPagesWithContext(context.TODO(), &ec2.DescribeNatGatewaysInput{Filter: filters}, func(out *ec2.DescribeNatGatewaysOutput, _ bool) bool {
+		ngws += len(out.NatGateways)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("failed to describe nat gateways: %v", err)
+	}
+
+	rts, err := f.DescribeRouteTablesWithContext(context.TODO(), &ec2.DescribeRouteTablesInput{Filters: filters})
+	if err != nil {
+		t.Fatalf("failed to describe route tables: %v", err)
+	}
+
+	return resourceCount{
+		subnets:          len(sns.Subnets),
+		internetGateways: len(igws.InternetGateways),
+		natGateways:      ngws,
+		routeTables:      len(rts.RouteTables),
+	}
+}
+
+func countEgressOnlyInternetGateways(t *testing.T, f *fake.EC2) int {
+	out, err := f.DescribeEgressOnlyInternetGatewaysWithContext(context.TODO(), &ec2.DescribeEgressOnlyInternetGatewaysInput{})
+	if err != nil {
+		t.Fatalf("failed to describe egress-only internet gateways: %v", err)
+	}
+	return len(out.EgressOnlyInternetGateways)
+}
+
+// checkNetworkTags verifies that all network resources in the vpc carry the tags.
+func checkNetworkTags(t *testing.T, f *fake.EC2, vpcID string, tags map[string]string) {
+	filters := []*ec2.Filter{{Name: aws.String("vpc-id"), Values: aws.StringSlice([]string{vpcID})}}
+	resources := make(map[string][]*ec2.Tag)
+
+	vpcs, err := f.DescribeVpcsWithContext(context.TODO(), &ec2.DescribeVpcsInput{VpcIds: aws.StringSlice([]string{vpcID})})
+	if err != nil {
+		t.Fatalf("failed to describe vpcs: %v", err)
+	}
+	for _, v := range vpcs.Vpcs {
+		resources[*v.VpcId] = v.Tags
+	}
+
+	sns, err := f.DescribeSubnetsWithContext(context.TODO(), &ec2.DescribeSubnetsInput{Filters: filters})
+	if err != nil {
+		t.Fatalf("failed to describe subnets: %v", err)
+	}
+	for _, sn := range sns.Subnets {
+		resources[*sn.SubnetId] = sn.Tags
+	}
+
+	igws, err := f.DescribeInternetGatewaysWithContext(context.TODO(), &ec2.DescribeInternetGatewaysInput{})
+	if err != nil {
+		t.Fatalf("failed to describe internet gateways: %v", err)
+	}
+	for _, igw := range igws.InternetGateways {
+		resources[*igw.InternetGatewayId] = igw.Tags
+	}
+
+	err = f.DescribeNatGatewaysPagesWithContext(context.TODO(), &ec2.DescribeNatGatewaysInput{Filter: filters}, func(out *ec2.DescribeNatGatewaysOutput, _ bool) bool {
+		for _, ng := range out.NatGateways {
+			resources[*ng.NatGatewayId] = ng.Tags
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatalf("failed to describe nat gateways: %v", err)
+	}
+
+	rts, err := f.DescribeRouteTablesWithContext(context.TODO(), &ec2.DescribeRouteTablesInput{Filters: filters})
+	if err != nil {
+		t.Fatalf("failed to describe route tables: %v", err)
+	}
+	for _, rt := range rts.RouteTables {
+		resources[*rt.RouteTableId] = rt.Tags
+	}
+
+	for id, rtags := range resources {
+		actual := tagsToMap(rtags)
+		for k, v := range tags {
+			// An empty value means that the tag must not be present.
+			if cv, ok := actual[k]; cv != v || (v == "" && ok) {
+				t.Fatalf("expected resource %q to have tag %q=%q, got: %v", id, k, v, actual)
+			}
+		}
+	}
+}
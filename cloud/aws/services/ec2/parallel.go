@@ -0,0 +1,69 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"sync"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// parallelize calls fn for every index in [0, n), running up to s.concurrency calls at once.
+// With a concurrency of one the calls are made in order and the first error is returned
+// immediately, otherwise all calls are made and their errors are aggregated.
+func (s *Service) parallelize(n int, fn func(i int) error) error {
+	if s.concurrency <= 1 || n <= 1 {
+		for i := 0; i < n; i++ {
+			if err := fn(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, s.concurrency)
+		errs = make([]error, n)
+	)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+
+	var res []error
+	for _, err := range errs {
+		if err != nil {
+			res = append(res, err)
+		}
+	}
+
+	switch len(res) {
+	case 0:
+		return nil
+	case 1:
+		// Keep the original error so that callers can still inspect it.
+		return res[0]
+	}
+	return utilerrors.NewAggregate(res)
+}
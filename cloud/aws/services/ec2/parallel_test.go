@@ -0,0 +1,98 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestParallelize(t *testing.T) {
+	testCases := []struct {
+		name        string
+		concurrency int
+		n           int
+		failing     map[int]bool
+		expectCalls int
+		expectErr   bool
+	}{
+		{
+			name:        "sequential, stops at the first error",
+			concurrency: 1,
+			n:           5,
+			failing:     map[int]bool{1: true, 3: true},
+			expectCalls: 2,
+			expectErr:   true,
+		},
+		{
+			name:        "concurrent, calls everything",
+			concurrency: 3,
+			n:           10,
+			expectCalls: 10,
+		},
+		{
+			name:        "concurrent, aggregates errors",
+			concurrency: 3,
+			n:           10,
+			failing:     map[int]bool{1: true, 3: true},
+			expectCalls: 10,
+			expectErr:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewService(nil).WithConcurrency(tc.concurrency)
+
+			var (
+				mu              sync.Mutex
+				calls, inflight int
+				maxInflight     int
+			)
+			err := s.parallelize(tc.n, func(i int) error {
+				mu.Lock()
+				calls++
+				inflight++
+				if inflight > maxInflight {
+					maxInflight = inflight
+				}
+				mu.Unlock()
+
+				defer func() {
+					mu.Lock()
+					inflight--
+					mu.Unlock()
+				}()
+
+				if tc.failing[i] {
+					return fmt.Errorf("call %d failed", i)
+				}
+				return nil
+			})
+
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("expected error: %v, got: %v", tc.expectErr, err)
+			}
+
+			if calls != tc.expectCalls {
+				t.Fatalf("expected %d calls, got %d", tc.expectCalls, calls)
+			}
+
+			if maxInflight > tc.concurrency {
+				t.Fatalf("expected at most %d concurrent calls, got %d", tc.concurrency, maxInflight)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+)
+
+// placementErrors are the error codes of AWS that are specific to the availability zone or the
+// subnet an instance is launched in. Limits of the account apply to all zones.
+var placementErrors = map[string]bool{
+	"InsufficientFreeAddressesInSubnet": true,
+	"InsufficientInstanceCapacity":      true,
+	"Unsupported":                       true,
+}
+
+// isPlacementError returns true if the instance may launch in another availability zone.
+func isPlacementError(err error) bool {
+	if aerr, ok := errors.Cause(err).(awserr.Error); ok {
+		return placementErrors[aerr.Code()]
+	}
+	return false
+}
+
+// instanceSubnets returns the subnets an instance may be launched in, sorted by availability zone.
+// A subnet referenced by id pins the instance to it, filters allow all subnets they match.
+// Instances without a subnet reference are launched in the default subnet.
+func (s *Service) instanceSubnets(ref *v1alpha1.AWSResourceReference) ([]*ec2.Subnet, error) {
+	if ref == nil {
+		return nil, nil
+	}
+
+	input := &ec2.DescribeSubnetsInput{}
+	switch {
+	case ref.ID != nil:
+		input.SubnetIds = []*string{ref.ID}
+	case len(ref.Filters) > 0:
+		for _, f := range ref.Filters {
+			input.Filters = append(input.Filters, &ec2.Filter{Name: aws.String(f.Name), Values: aws.StringSlice(f.Values)})
+		}
+	default:
+		return nil, nil
+	}
+
+	out, err := s.EC2.DescribeSubnetsWithContext(s.ctx, input)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe subnets of instance")
+	}
+	if len(out.Subnets) == 0 {
+		return nil, NewNotFound(errors.New("no subnets match the subnet of the instance"))
+	}
+
+	// Subnets in zones instances may not be launched in are skipped.
+	var subnets []*ec2.Subnet
+	var violation error
+	for _, sn := range out.Subnets {
+		if err := s.locations.CheckZone(aws.StringValue(sn.AvailabilityZone)); err != nil {
+			violation = err
+			continue
+		}
+		subnets = append(subnets, sn)
+	}
+	if len(subnets) == 0 {
+		return nil, NewInvalidConfiguration(errors.Wrap(violation, "no subnet of the instance is in an allowed availability zone"))
+	}
+	out.Subnets = subnets
+
+	sort.Slice(out.Subnets, func(i, j int) bool {
+		if zi, zj := aws.StringValue(out.Subnets[i].AvailabilityZone), aws.StringValue(out.Subnets[j].AvailabilityZone); zi != zj {
+			return zi < zj
+		}
+		return aws.StringValue(out.Subnets[i].SubnetId) < aws.StringValue(out.Subnets[j].SubnetId)
+	})
+	return out.Subnets, nil
+}
+
+// runInstance runs the instance in the first of the subnets AWS has capacity in. Once an
+// availability zone lacks the capacity for the instance, or doesn't support its instance type,
+// the other subnets of the zone are skipped. Every subnet gets its own client token derived from
+// the given one, as AWS rejects a token that is reused with other parameters.
+func (s *Service) runInstance(input *ec2.RunInstancesInput, clientToken string, subnets []*ec2.Subnet) (*ec2.Reservation, *ec2.Subnet, error) {
+	if len(subnets) == 0 {
+		if clientToken != "" {
+			input.ClientToken = aws.String(clientToken)
+		}
+		reservation, err := s.runInstances(input)
+		return reservation, nil, err
+	}
+
+	var lastErr error
+	failedZones := make(map[string]bool)
+	for _, subnet := range subnets {
+		zone := aws.StringValue(subnet.AvailabilityZone)
+		if failedZones[zone] {
+			continue
+		}
+
+		input.SubnetId = subnet.SubnetId
+		if clientToken != "" {
+			input.ClientToken = aws.String(ClientToken(clientToken, aws.StringValue(subnet.SubnetId)))
+		}
+
+		reservation, err := s.runInstances(input)
+		if err == nil {
+			return reservation, subnet, nil
+		}
+		if !isPlacementError(err) {
+			return nil, nil, err
+		}
+
+		s.log.Info("Failed to launch instance in availability zone, trying the next one", "availability-zone", zone,
+			"subnet-id", aws.StringValue(subnet.SubnetId), "reason", err)
+		failedZones[zone] = true
+		lastErr = err
+	}
+
+	return nil, nil, errors.Wrapf(lastErr, "failed to launch instance in any of the availability zones of its subnets")
+}
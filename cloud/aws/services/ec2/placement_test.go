@@ -0,0 +1,100 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/fake"
+	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
+)
+
+// capacityEC2 lacks the capacity to run instances in the subnets of a zone.
+type capacityEC2 struct {
+	*fake.EC2
+
+	zone string
+}
+
+func (c *capacityEC2) RunInstancesWithContext(ctx aws.Context, in *ec2.RunInstancesInput, opts ...request.Option) (*ec2.Reservation, error) {
+	out, err := c.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{SubnetIds: []*string{in.SubnetId}})
+	if err != nil {
+		return nil, err
+	}
+	if aws.StringValue(out.Subnets[0].AvailabilityZone) == c.zone {
+		return nil, awserr.New("InsufficientInstanceCapacity", "We currently do not have sufficient capacity in the Availability Zone you requested", nil)
+	}
+	return c.EC2.RunInstancesWithContext(ctx, in, opts...)
+}
+
+func TestCreateInstanceZoneFailover(t *testing.T) {
+	f := &capacityEC2{EC2: fake.New(), zone: "us-east-1a"}
+	s := NewService(f)
+
+	vpc, err := f.CreateVpcWithContext(context.TODO(), &ec2.CreateVpcInput{CidrBlock: aws.String("10.0.0.0/16")})
+	if err != nil {
+		t.Fatalf("failed to create vpc: %v", err)
+	}
+	for _, sn := range []struct{ cidr, zone string }{
+		{"10.0.0.0/24", "us-east-1a"},
+		{"10.0.1.0/24", "us-east-1a"},
+		{"10.0.2.0/24", "us-east-1b"},
+	} {
+		if _, err := f.CreateSubnetWithContext(context.TODO(), &ec2.CreateSubnetInput{
+			VpcId:            vpc.Vpc.VpcId,
+			CidrBlock:        aws.String(sn.cidr),
+			AvailabilityZone: aws.String(sn.zone),
+		}); err != nil {
+			t.Fatalf("failed to create subnet: %v", err)
+		}
+	}
+
+	machine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "node-0"}}
+	config := &v1alpha1.AWSMachineProviderConfig{
+		AMI: v1alpha1.AWSResourceReference{ID: aws.String("ami-1")},
+		Subnet: &v1alpha1.AWSResourceReference{
+			Filters: []v1alpha1.Filter{{Name: "vpc-id", Values: []string{aws.StringValue(vpc.Vpc.VpcId)}}},
+		},
+	}
+
+	instance, err := s.CreateInstance(context.TODO(), "test-cluster", "token", nil, machine, config)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	if instance.AvailabilityZone != "us-east-1b" || instance.SubnetID == "" {
+		t.Fatalf("expected the instance to be launched in the zone with capacity, got: %+v", instance)
+	}
+
+	// Subnets referenced by id pin the instance to their zone.
+	out, err := f.DescribeSubnetsWithContext(context.TODO(), &ec2.DescribeSubnetsInput{})
+	if err != nil {
+		t.Fatalf("failed to describe subnets: %v", err)
+	}
+	for _, sn := range out.Subnets {
+		if aws.StringValue(sn.AvailabilityZone) == "us-east-1a" {
+			config.Subnet = &v1alpha1.AWSResourceReference{ID: sn.SubnetId}
+		}
+	}
+	_, err = s.CreateInstance(context.TODO(), "test-cluster", "other-token", nil, machine, config)
+	if ClassifyError(err) != ErrorClassCapacity {
+		t.Fatalf("expected a capacity error, got: %v", err)
+	}
+}
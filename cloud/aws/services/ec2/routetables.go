@@ -16,63 +16,210 @@ package ec2
 import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/golang/glog"
 	"github.com/pkg/errors"
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
 )
 
-func (s *Service) reconcileRouteTables(in *v1alpha1.Network) error {
-	glog.V(2).Infof("Reconciling routing tables")
+const (
+	defaultIPv6Route = "::/0"
+)
+
+func (s *Service) reconcileRouteTables(clusterName string, spec *v1alpha1.NetworkSpec, in *v1alpha1.Network) error {
+	s.log.V(2).Info("Reconciling routing tables", "vpc-id", in.VPC.ID, "strategy", spec.RouteTableStrategy)
 
-	subnetRouteMap, err := s.describeVpcRouteTablesBySubnet(in.VPC.ID)
+	subnetRouteMap, err := s.describeVpcRouteTablesBySubnet(clusterName, &in.VPC)
 	if err != nil {
 		return err
 	}
 
+	// Owned route tables that subnets without a route table can share, by their sharing key.
+	shared := make(map[string]*ec2.RouteTable)
+	reconciled := make(map[string]bool)
+
+	var keys []string
+	missing := make(map[string]v1alpha1.Subnets)
 	for _, sn := range in.Subnets {
-		if igw, ok := subnetRouteMap[sn.ID]; ok {
-			glog.V(2).Infof("Subnet %q is already associated with route table %q", sn.ID, *igw.RouteTableId)
+		if sn.SharedRouteTableID != nil {
+			if isRemovedZone(spec, sn.AvailabilityZone) {
+				continue
+			}
+			if err := s.reconcileSharedRouteTable(&in.VPC, sn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		key, err := routeTableKey(spec.RouteTableStrategy, sn)
+		if err != nil {
+			return err
+		}
+
+		if rt, ok := subnetRouteMap[sn.ID]; ok {
+			s.log.V(2).Info("Subnet is already associated with route table", "subnet-id", sn.ID, "route-table-id", rt.RouteTableId)
+			sn.RouteTableID = rt.RouteTableId
+			if reconciled[*rt.RouteTableId] {
+				continue
+			}
+			reconciled[*rt.RouteTableId] = true
+
+			tags, err := s.reconcileResourceTags(clusterName, *rt.RouteTableId, tagsToMap(rt.Tags))
+			if err != nil {
+				return errors.Wrapf(err, "failed to update tags of route table %q", *rt.RouteTableId)
+			}
+			if lifecycle, _ := s.clusterLifecycle(clusterName, tags); lifecycle == ResourceLifecycleOwned {
+				shared[key] = rt
+				if !sn.IsPublic {
+					if err := s.reconcileIPv6Route(rt, in.EgressOnlyInternetGatewayID); err != nil {
+						return err
+					}
+					zonal := spec.RouteTableStrategy != v1alpha1.RouteTableStrategyTier
+					if err := s.reconcileNatGatewayRoutes(rt, in.Subnets, sn, zonal); err != nil {
+						return err
+					}
+				}
+			}
 			// TODO(vincepri): if the route table ids are both non-empty and they don't match, replace the association.
 			// TODO(vincepri): check that everything is in order, e.g. routes match the subnet type.
 			continue
 		}
 
-		// For each subnet that doesn't have a routing table associated with it,
-		// create a new table with the appropriate default routes and associate it to the subnet.
+		// Subnets that are left in removed zones are about to be deleted.
+		if isRemovedZone(spec, sn.AvailabilityZone) {
+			continue
+		}
+
+		if _, ok := missing[key]; !ok {
+			keys = append(keys, key)
+		}
+		missing[key] = append(missing[key], sn)
+	}
+
+	// For each group of subnets that don't have a routing table associated with them,
+	// reuse the table of the group or create a new one with the appropriate default routes,
+	// and associate it to the subnets.
+	return s.parallelize(len(keys), func(i int) error {
+		return s.reconcileSubnetRouteTable(clusterName, spec, in, shared[keys[i]], missing[keys[i]])
+	})
+}
+
+// routeTableKey returns the key of the subnet's route table for the strategy,
+// subnets with the same key share a route table.
+func routeTableKey(strategy v1alpha1.RouteTableStrategy, sn *v1alpha1.Subnet) (string, error) {
+	tier := "private"
+	if sn.IsPublic {
+		tier = "public"
+	}
+
+	switch strategy {
+	case "", v1alpha1.RouteTableStrategySubnet:
+		return sn.ID, nil
+	case v1alpha1.RouteTableStrategyZone:
+		return tier + "/" + sn.AvailabilityZone, nil
+	case v1alpha1.RouteTableStrategyTier:
+		return tier, nil
+	default:
+		return "", errors.Errorf("unknown route table strategy %q", strategy)
+	}
+}
+
+// reconcileSubnetRouteTable associates the subnets with the route table, which is created
+// first if it's nil. All subnets must be of the same tier.
+func (s *Service) reconcileSubnetRouteTable(clusterName string, spec *v1alpha1.NetworkSpec, in *v1alpha1.Network, existing *ec2.RouteTable, subnets v1alpha1.Subnets) error {
+	var rt *v1alpha1.RouteTable
+	if existing != nil {
+		rt = &v1alpha1.RouteTable{ID: *existing.RouteTableId}
+	} else {
 		var routes []*ec2.Route
-		if sn.IsPublic {
+		switch sn := subnets[0]; {
+		case spec.Isolated:
+			// Only the local route.
+		case sn.IsPublic:
 			if in.InternetGatewayID == nil {
 				return errors.Errorf("failed to create routing tables: internet gateway for %q is nil", in.VPC.ID)
 			}
 
 			routes = s.getDefaultPublicRoutes(*in.InternetGatewayID)
-		} else {
+		default:
 			natGatewayId, err := s.getNatGatewayForSubnet(in.Subnets, sn)
 			if err != nil {
 				return err
 			}
 
-			routes = s.getDefaultPrivateRoutes(natGatewayId)
+			routes = s.getDefaultPrivateRoutes(natGatewayId, in.EgressOnlyInternetGatewayID)
 		}
 
-		rt, err := s.createRouteTableWithRoutes(&in.VPC, routes)
+		var err error
+		rt, err = s.createRouteTableWithRoutes(clusterName, &in.VPC, routes)
 		if err != nil {
 			return err
 		}
+	}
 
+	for _, sn := range subnets {
 		if err := s.associateRouteTable(rt, sn.ID); err != nil {
 			return err
 		}
 
-		glog.V(2).Infof("Subnet %q has been associated with route table %q", sn.ID, rt.ID)
+		s.log.V(2).Info("Subnet has been associated with route table", "subnet-id", sn.ID, "route-table-id", rt.ID)
 		sn.RouteTableID = aws.String(rt.ID)
 	}
+	return nil
+}
+
+// reconcileSharedRouteTable associates the subnet with its shared route table, replacing the
+// association with another route table, if any. The shared route table itself isn't changed.
+func (s *Service) reconcileSharedRouteTable(vpc *v1alpha1.VPC, sn *v1alpha1.Subnet) error {
+	out, err := s.EC2.DescribeRouteTablesWithContext(s.ctx, &ec2.DescribeRouteTablesInput{
+		RouteTableIds: []*string{sn.SharedRouteTableID},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe shared route table %q of subnet %q", *sn.SharedRouteTableID, sn.ID)
+	}
+	if len(out.RouteTables) == 0 || aws.StringValue(out.RouteTables[0].VpcId) != vpc.ID {
+		return errors.Errorf("shared route table %q of subnet %q doesn't exist in vpc %q", *sn.SharedRouteTableID, sn.ID, vpc.ID)
+	}
+
+	current, err := s.EC2.DescribeRouteTablesWithContext(s.ctx, &ec2.DescribeRouteTablesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("association.subnet-id"),
+				Values: []*string{aws.String(sn.ID)},
+			},
+		},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe route table of subnet %q", sn.ID)
+	}
+
+	for _, rt := range current.RouteTables {
+		if *rt.RouteTableId == *sn.SharedRouteTableID {
+			sn.RouteTableID = sn.SharedRouteTableID
+			return nil
+		}
 
+		for _, as := range rt.Associations {
+			if aws.StringValue(as.SubnetId) != sn.ID {
+				continue
+			}
+			if err := s.disassociateRouteTable(rt, []*ec2.RouteTableAssociation{as}); err != nil {
+				return err
+			}
+
+			s.log.V(2).Info("Subnet has been disassociated from route table", "subnet-id", sn.ID, "route-table-id", rt.RouteTableId)
+		}
+	}
+
+	if err := s.associateRouteTable(&v1alpha1.RouteTable{ID: *sn.SharedRouteTableID}, sn.ID); err != nil {
+		return err
+	}
+
+	s.log.V(2).Info("Subnet has been associated with shared route table", "subnet-id", sn.ID, "route-table-id", *sn.SharedRouteTableID)
+	sn.RouteTableID = sn.SharedRouteTableID
 	return nil
 }
 
-func (s *Service) describeVpcRouteTablesBySubnet(vpcID string) (map[string]*ec2.RouteTable, error) {
-	rts, err := s.describeVpcRouteTables(vpcID)
+func (s *Service) describeVpcRouteTablesBySubnet(clusterName string, vpc *v1alpha1.VPC) (map[string]*ec2.RouteTable, error) {
+	rts, err := s.describeVpcRouteTables(clusterName, vpc)
 	if err != nil {
 		return nil, err
 	}
@@ -93,25 +240,68 @@ func (s *Service) describeVpcRouteTablesBySubnet(vpcID string) (map[string]*ec2.
 	return res, nil
 }
 
-func (s *Service) describeVpcRouteTables(vpcID string) ([]*ec2.RouteTable, error) {
-	out, err := s.EC2.DescribeRouteTables(&ec2.DescribeRouteTablesInput{
-		Filters: []*ec2.Filter{
+func (s *Service) describeVpcRouteTables(clusterName string, vpc *v1alpha1.VPC) ([]*ec2.RouteTable, error) {
+	out, err := s.EC2.DescribeRouteTablesWithContext(s.ctx, &ec2.DescribeRouteTablesInput{
+		Filters: s.addNetworkTagFilters(clusterName, vpc, []*ec2.Filter{
 			{
 				Name:   aws.String("vpc-id"),
-				Values: []*string{aws.String(vpcID)},
+				Values: []*string{aws.String(vpc.ID)},
 			},
-		},
+		}),
 	})
 
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to describe route tables in vpc %q", vpcID)
+		return nil, errors.Wrapf(err, "failed to describe route tables in vpc %q", vpc.ID)
 	}
 
 	return out.RouteTables, nil
 }
 
-func (s *Service) createRouteTableWithRoutes(vpc *v1alpha1.VPC, routes []*ec2.Route) (*v1alpha1.RouteTable, error) {
-	out, err := s.EC2.CreateRouteTable(&ec2.CreateRouteTableInput{
+func (s *Service) deleteRouteTables(clusterName string, vpc *v1alpha1.VPC) error {
+	rts, err := s.describeVpcRouteTables(clusterName, vpc)
+	if err != nil {
+		return err
+	}
+
+	return s.parallelize(len(rts), func(i int) error {
+		rt := rts[i]
+		deleted, err := s.releaseResource(clusterName, *rt.RouteTableId, tagsToMap(rt.Tags), func() error {
+			if err := s.disassociateRouteTable(rt, rt.Associations); err != nil {
+				return err
+			}
+
+			_, err := s.EC2.DeleteRouteTableWithContext(s.ctx, &ec2.DeleteRouteTableInput{RouteTableId: rt.RouteTableId})
+			return errors.Wrapf(err, "failed to delete route table %q", *rt.RouteTableId)
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if deleted {
+			s.log.V(2).Info("Deleted route table", "route-table-id", rt.RouteTableId)
+		}
+		return nil
+	})
+}
+
+// disassociateRouteTable removes the associations of the route table with subnets,
+// the main association is kept.
+func (s *Service) disassociateRouteTable(rt *ec2.RouteTable, associations []*ec2.RouteTableAssociation) error {
+	for _, as := range associations {
+		if aws.BoolValue(as.Main) {
+			continue
+		}
+
+		if _, err := s.EC2.DisassociateRouteTableWithContext(s.ctx, &ec2.DisassociateRouteTableInput{AssociationId: as.RouteTableAssociationId}); err != nil {
+			return errors.Wrapf(err, "failed to disassociate route table %q from subnet %q", *rt.RouteTableId, aws.StringValue(as.SubnetId))
+		}
+	}
+	return nil
+}
+
+func (s *Service) createRouteTableWithRoutes(clusterName string, vpc *v1alpha1.VPC, routes []*ec2.Route) (*v1alpha1.RouteTable, error) {
+	out, err := s.EC2.CreateRouteTableWithContext(s.ctx, &ec2.CreateRouteTableInput{
 		VpcId: aws.String(vpc.ID),
 	})
 
@@ -119,8 +309,23 @@ func (s *Service) createRouteTableWithRoutes(vpc *v1alpha1.VPC, routes []*ec2.Ro
 		return nil, errors.Wrapf(err, "failed to create route table in vpc %q", vpc.ID)
 	}
 
+	// Routes are added and subnets associated right away.
+	err = s.waitUntilVisible(*out.RouteTable.RouteTableId, func() error {
+		_, err := s.EC2.DescribeRouteTablesWithContext(s.ctx, &ec2.DescribeRouteTablesInput{
+			RouteTableIds: []*string{out.RouteTable.RouteTableId},
+		})
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to wait for route table %q", *out.RouteTable.RouteTableId)
+	}
+
+	if err := s.createTags(clusterName, *out.RouteTable.RouteTableId, ResourceLifecycleOwned, nil); err != nil {
+		return nil, errors.Wrapf(err, "failed to tag route table %q", *out.RouteTable.RouteTableId)
+	}
+
 	for _, route := range routes {
-		_, err := s.EC2.CreateRoute(&ec2.CreateRouteInput{
+		_, err := s.EC2.CreateRouteWithContext(s.ctx, &ec2.CreateRouteInput{
 			RouteTableId:                out.RouteTable.RouteTableId,
 			DestinationCidrBlock:        route.DestinationCidrBlock,
 			DestinationIpv6CidrBlock:    route.DestinationIpv6CidrBlock,
@@ -144,7 +349,7 @@ func (s *Service) createRouteTableWithRoutes(vpc *v1alpha1.VPC, routes []*ec2.Ro
 }
 
 func (s *Service) associateRouteTable(rt *v1alpha1.RouteTable, subnetID string) error {
-	_, err := s.EC2.AssociateRouteTable(&ec2.AssociateRouteTableInput{
+	_, err := s.EC2.AssociateRouteTableWithContext(s.ctx, &ec2.AssociateRouteTableInput{
 		RouteTableId: aws.String(rt.ID),
 		SubnetId:     aws.String(subnetID),
 	})
@@ -156,13 +361,98 @@ func (s *Service) associateRouteTable(rt *v1alpha1.RouteTable, subnetID string)
 	return nil
 }
 
-func (s *Service) getDefaultPrivateRoutes(natGatewayId string) []*ec2.Route {
-	return []*ec2.Route{
+// reconcileIPv6Route adds the default IPv6 route through the egress-only internet gateway to
+// an existing private route table, e.g. after an IPv6 CIDR block became available in the vpc.
+func (s *Service) reconcileIPv6Route(rt *ec2.RouteTable, egressOnlyInternetGatewayID *string) error {
+	if egressOnlyInternetGatewayID == nil {
+		return nil
+	}
+
+	for _, route := range rt.Routes {
+		if aws.StringValue(route.DestinationIpv6CidrBlock) == defaultIPv6Route {
+			return nil
+		}
+	}
+
+	_, err := s.EC2.CreateRouteWithContext(s.ctx, &ec2.CreateRouteInput{
+		RouteTableId:                rt.RouteTableId,
+		DestinationIpv6CidrBlock:    aws.String(defaultIPv6Route),
+		EgressOnlyInternetGatewayId: egressOnlyInternetGatewayID,
+	})
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to create ipv6 route in route table %q", *rt.RouteTableId)
+	}
+
+	s.log.V(2).Info("Added IPv6 route to route table", "route-table-id", rt.RouteTableId, "egress-only-internet-gateway-id", egressOnlyInternetGatewayID)
+	return nil
+}
+
+// reconcileNatGatewayRoutes points the routes of a private route table that target a NAT gateway
+// which is gone, e.g. because it failed or was deleted by hand, to the current NAT gateway of the subnet.
+// Route tables of a single zone also get their routes to the NAT gateway of another zone repointed
+// to the NAT gateway of their own zone, e.g. once the zone got its NAT gateway back.
+func (s *Service) reconcileNatGatewayRoutes(rt *ec2.RouteTable, subnets v1alpha1.Subnets, sn *v1alpha1.Subnet, zonal bool) error {
+	usable := make(map[string]bool)
+	for _, psn := range subnets.FilterPublic() {
+		if psn.NatGatewayID != nil {
+			usable[*psn.NatGatewayID] = true
+		}
+	}
+
+	for _, route := range rt.Routes {
+		if route.NatGatewayId == nil {
+			continue
+		}
+		gone := !usable[*route.NatGatewayId]
+		if !gone && !zonal {
+			continue
+		}
+
+		natGatewayID, err := s.getNatGatewayForSubnet(subnets, sn)
+		if err != nil {
+			return err
+		}
+		if natGatewayID == *route.NatGatewayId {
+			continue
+		}
+
+		if _, err := s.EC2.ReplaceRouteWithContext(s.ctx, &ec2.ReplaceRouteInput{
+			RouteTableId:         rt.RouteTableId,
+			DestinationCidrBlock: route.DestinationCidrBlock,
+			NatGatewayId:         aws.String(natGatewayID),
+		}); err != nil {
+			return errors.Wrapf(err, "failed to replace route to nat gateway %q in route table %q", *route.NatGatewayId, *rt.RouteTableId)
+		}
+
+		if gone {
+			s.log.V(2).Info("Replaced route to NAT gateway that is gone", "route-table-id", rt.RouteTableId,
+				"old-nat-gateway-id", route.NatGatewayId, "nat-gateway-id", natGatewayID)
+		} else {
+			s.log.V(2).Info("Replaced route to NAT gateway of another zone", "route-table-id", rt.RouteTableId,
+				"old-nat-gateway-id", route.NatGatewayId, "nat-gateway-id", natGatewayID, "availability-zone", sn.AvailabilityZone)
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) getDefaultPrivateRoutes(natGatewayId string, egressOnlyInternetGatewayID *string) []*ec2.Route {
+	routes := []*ec2.Route{
 		{
 			DestinationCidrBlock: aws.String("0.0.0.0/0"),
 			NatGatewayId:         aws.String(natGatewayId),
 		},
 	}
+
+	if egressOnlyInternetGatewayID != nil {
+		routes = append(routes, &ec2.Route{
+			DestinationIpv6CidrBlock:    aws.String(defaultIPv6Route),
+			EgressOnlyInternetGatewayId: egressOnlyInternetGatewayID,
+		})
+	}
+
+	return routes
 }
 
 func (s *Service) getDefaultPublicRoutes(internetGatewayId string) []*ec2.Route {
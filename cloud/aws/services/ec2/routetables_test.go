@@ -60,15 +60,31 @@ func TestReconcileRouteTables(t *testing.T) {
 			},
 			expect: func(m *mock_ec2iface.MockEC2API) {
 				m.EXPECT().
-					DescribeRouteTables(gomock.AssignableToTypeOf(&ec2.DescribeRouteTablesInput{})).
+					DescribeRouteTablesWithContext(gomock.Any(), gomock.AssignableToTypeOf(&ec2.DescribeRouteTablesInput{})).
 					Return(&ec2.DescribeRouteTablesOutput{}, nil)
 
 				privateRouteTable := m.EXPECT().
-					CreateRouteTable(gomock.Eq(&ec2.CreateRouteTableInput{VpcId: aws.String("vpc-routetables")})).
+					CreateRouteTableWithContext(gomock.Any(), gomock.Eq(&ec2.CreateRouteTableInput{VpcId: aws.String("vpc-routetables")})).
 					Return(&ec2.CreateRouteTableOutput{RouteTable: &ec2.RouteTable{RouteTableId: aws.String("rt-1")}}, nil)
 
 				m.EXPECT().
-					CreateRoute(gomock.Eq(&ec2.CreateRouteInput{
+					DescribeRouteTablesWithContext(gomock.Any(), gomock.Eq(&ec2.DescribeRouteTablesInput{RouteTableIds: aws.StringSlice([]string{"rt-1"})})).
+					Return(&ec2.DescribeRouteTablesOutput{RouteTables: []*ec2.RouteTable{{RouteTableId: aws.String("rt-1")}}}, nil).
+					After(privateRouteTable)
+
+				m.EXPECT().
+					CreateTagsWithContext(gomock.Any(), gomock.Eq(&ec2.CreateTagsInput{
+						Resources: aws.StringSlice([]string{"rt-1"}),
+						Tags: []*ec2.Tag{{
+							Key:   aws.String("kubernetes.io/cluster/test-cluster"),
+							Value: aws.String("owned"),
+						}},
+					})).
+					Return(nil, nil).
+					After(privateRouteTable)
+
+				m.EXPECT().
+					CreateRouteWithContext(gomock.Any(), gomock.Eq(&ec2.CreateRouteInput{
 						NatGatewayId:         aws.String("nat-01"),
 						DestinationCidrBlock: aws.String("0.0.0.0/0"),
 						RouteTableId:         aws.String("rt-1"),
@@ -76,7 +92,7 @@ func TestReconcileRouteTables(t *testing.T) {
 					After(privateRouteTable)
 
 				m.EXPECT().
-					AssociateRouteTable(gomock.Eq(&ec2.AssociateRouteTableInput{
+					AssociateRouteTableWithContext(gomock.Any(), gomock.Eq(&ec2.AssociateRouteTableInput{
 						RouteTableId: aws.String("rt-1"),
 						SubnetId:     aws.String("subnet-routetables-private"),
 					})).
@@ -84,11 +100,27 @@ func TestReconcileRouteTables(t *testing.T) {
 					After(privateRouteTable)
 
 				publicRouteTable := m.EXPECT().
-					CreateRouteTable(gomock.Eq(&ec2.CreateRouteTableInput{VpcId: aws.String("vpc-routetables")})).
+					CreateRouteTableWithContext(gomock.Any(), gomock.Eq(&ec2.CreateRouteTableInput{VpcId: aws.String("vpc-routetables")})).
 					Return(&ec2.CreateRouteTableOutput{RouteTable: &ec2.RouteTable{RouteTableId: aws.String("rt-2")}}, nil)
 
 				m.EXPECT().
-					CreateRoute(gomock.Eq(&ec2.CreateRouteInput{
+					DescribeRouteTablesWithContext(gomock.Any(), gomock.Eq(&ec2.DescribeRouteTablesInput{RouteTableIds: aws.StringSlice([]string{"rt-2"})})).
+					Return(&ec2.DescribeRouteTablesOutput{RouteTables: []*ec2.RouteTable{{RouteTableId: aws.String("rt-2")}}}, nil).
+					After(publicRouteTable)
+
+				m.EXPECT().
+					CreateTagsWithContext(gomock.Any(), gomock.Eq(&ec2.CreateTagsInput{
+						Resources: aws.StringSlice([]string{"rt-2"}),
+						Tags: []*ec2.Tag{{
+							Key:   aws.String("kubernetes.io/cluster/test-cluster"),
+							Value: aws.String("owned"),
+						}},
+					})).
+					Return(nil, nil).
+					After(publicRouteTable)
+
+				m.EXPECT().
+					CreateRouteWithContext(gomock.Any(), gomock.Eq(&ec2.CreateRouteInput{
 						GatewayId:            aws.String("igw-01"),
 						DestinationCidrBlock: aws.String("0.0.0.0/0"),
 						RouteTableId:         aws.String("rt-2"),
@@ -96,7 +128,7 @@ func TestReconcileRouteTables(t *testing.T) {
 					After(publicRouteTable)
 
 				m.EXPECT().
-					AssociateRouteTable(gomock.Eq(&ec2.AssociateRouteTableInput{
+					AssociateRouteTableWithContext(gomock.Any(), gomock.Eq(&ec2.AssociateRouteTableInput{
 						RouteTableId: aws.String("rt-2"),
 						SubnetId:     aws.String("subnet-routetables-public"),
 					})).
@@ -105,7 +137,7 @@ func TestReconcileRouteTables(t *testing.T) {
 			},
 		},
 		{
-			name: "subnets in different availability zones, returns error",
+			name: "no nat gateways, returns error",
 			input: &v1alpha1.Network{
 				InternetGatewayID: aws.String("igw-01"),
 				VPC: v1alpha1.VPC{
@@ -122,14 +154,13 @@ func TestReconcileRouteTables(t *testing.T) {
 						VpcID:            "vpc-routetables",
 						ID:               "subnet-routetables-public",
 						IsPublic:         true,
-						NatGatewayID:     aws.String("nat-01"),
 						AvailabilityZone: "us-east-1b",
 					},
 				},
 			},
 			expect: func(m *mock_ec2iface.MockEC2API) {
 				m.EXPECT().
-					DescribeRouteTables(gomock.AssignableToTypeOf(&ec2.DescribeRouteTablesInput{})).
+					DescribeRouteTablesWithContext(gomock.Any(), gomock.AssignableToTypeOf(&ec2.DescribeRouteTablesInput{})).
 					Return(&ec2.DescribeRouteTablesOutput{}, nil)
 			},
 			err: errors.New(`no nat gateways are available in availability zone "us-east-1a"`),
@@ -142,7 +173,7 @@ func TestReconcileRouteTables(t *testing.T) {
 			tc.expect(ec2Mock)
 
 			s := NewService(ec2Mock)
-			if err := s.reconcileRouteTables(tc.input); err != nil && tc.err != nil {
+			if err := s.reconcileRouteTables("test-cluster", &v1alpha1.NetworkSpec{}, tc.input); err != nil && tc.err != nil {
 				if !strings.Contains(err.Error(), tc.err.Error()) {
 					t.Fatalf("was expecting error to look like '%v', but got '%v'", tc.err, err)
 				}
@@ -0,0 +1,205 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+)
+
+// nfsPort is the port EFS mount targets serve NFS on.
+const nfsPort = 2049
+
+// fileSystemSecurityGroupName returns the name of the security group of the file system mount
+// targets of the cluster.
+func fileSystemSecurityGroupName(clusterName string) string {
+	return clusterName + "-efs"
+}
+
+// ReconcileFileSystemSecurityGroup makes sure that the vpc of the network has a security group for
+// the mount targets of the file system of the cluster, which allows NFS traffic from the CIDR blocks
+// of the vpc. It returns the id of the security group.
+// The security group is deleted together with the network.
+func (s *Service) ReconcileFileSystemSecurityGroup(ctx context.Context, clusterName string, additionalTags map[string]string, network *v1alpha1.Network) (string, error) {
+	s = s.withContext(ctx).withValues("cluster", clusterName).withAdditionalTags(additionalTags)
+	name := fileSystemSecurityGroupName(clusterName)
+
+	sg, err := s.describeSecurityGroup(&network.VPC, name)
+	if IsNotFound(err) {
+		sg, err = s.createSecurityGroup(clusterName, &network.VPC, name, "NFS access to the EFS file system of cluster "+clusterName)
+		if err != nil {
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	} else if err := s.reconcileSecurityGroupTags(clusterName, sg); err != nil {
+		return "", err
+	}
+
+	cidrs := append([]string{network.VPC.CidrBlock}, network.VPC.SecondaryCidrBlocks...)
+	if err := s.authorizeIngress(sg, "tcp", nfsPort, cidrs); err != nil {
+		return "", err
+	}
+
+	return *sg.GroupId, nil
+}
+
+// reconcileSecurityGroupTags reconciles the tags of a security group found by its name.
+// Groups of the same name that weren't created for the cluster are left alone.
+func (s *Service) reconcileSecurityGroupTags(clusterName string, sg *ec2.SecurityGroup) error {
+	tags := tagsToMap(sg.Tags)
+	if lifecycle, _ := s.clusterLifecycle(clusterName, tags); lifecycle != ResourceLifecycleOwned {
+		return NewConflict(errors.Errorf("security group %q is not owned by the cluster", *sg.GroupId))
+	}
+
+	_, err := s.reconcileTags(*sg.GroupId, tags)
+	return errors.Wrapf(err, "failed to update tags of security group %q", *sg.GroupId)
+}
+
+func (s *Service) createSecurityGroup(clusterName string, vpc *v1alpha1.VPC, name string, description string) (*ec2.SecurityGroup, error) {
+	out, err := s.EC2.CreateSecurityGroupWithContext(s.ctx, &ec2.CreateSecurityGroupInput{
+		GroupName:   aws.String(name),
+		Description: aws.String(description),
+		VpcId:       aws.String(vpc.ID),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create security group %q in vpc %q", name, vpc.ID)
+	}
+
+	// Ingress is authorized right away.
+	err = s.waitUntilVisible(*out.GroupId, func() error {
+		_, err := s.EC2.DescribeSecurityGroupsWithContext(s.ctx, &ec2.DescribeSecurityGroupsInput{
+			GroupIds: []*string{out.GroupId},
+		})
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to wait for security group %q", *out.GroupId)
+	}
+
+	if err := s.createTags(clusterName, *out.GroupId, ResourceLifecycleOwned, nil); err != nil {
+		return nil, errors.Wrapf(err, "failed to tag security group %q", *out.GroupId)
+	}
+
+	s.log.V(2).Info("Created new security group", "security-group-id", out.GroupId, "vpc-id", vpc.ID)
+	return &ec2.SecurityGroup{
+		GroupId:   out.GroupId,
+		GroupName: aws.String(name),
+		VpcId:     aws.String(vpc.ID),
+	}, nil
+}
+
+// describeSecurityGroup returns the security group of the given name in the vpc.
+func (s *Service) describeSecurityGroup(vpc *v1alpha1.VPC, name string) (*ec2.SecurityGroup, error) {
+	out, err := s.EC2.DescribeSecurityGroupsWithContext(s.ctx, &ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(vpc.ID)},
+			},
+			{
+				Name:   aws.String("group-name"),
+				Values: []*string{aws.String(name)},
+			},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe security group %q in vpc %q", name, vpc.ID)
+	}
+
+	if len(out.SecurityGroups) == 0 {
+		return nil, NewNotFound(errors.Errorf("no security group %q found in vpc %q", name, vpc.ID))
+	}
+	return out.SecurityGroups[0], nil
+}
+
+// authorizeIngress allows traffic to the port from the CIDR blocks that aren't allowed yet.
+// Rules that are no longer desired are left in place.
+func (s *Service) authorizeIngress(sg *ec2.SecurityGroup, protocol string, port int64, cidrs []string) error {
+	allowed := make(map[string]bool)
+	for _, perm := range sg.IpPermissions {
+		if aws.StringValue(perm.IpProtocol) != protocol || aws.Int64Value(perm.FromPort) != port || aws.Int64Value(perm.ToPort) != port {
+			continue
+		}
+		for _, r := range perm.IpRanges {
+			allowed[aws.StringValue(r.CidrIp)] = true
+		}
+	}
+
+	var ranges []*ec2.IpRange
+	for _, cidr := range cidrs {
+		if cidr != "" && !allowed[cidr] {
+			ranges = append(ranges, &ec2.IpRange{CidrIp: aws.String(cidr)})
+			allowed[cidr] = true
+		}
+	}
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	if _, err := s.EC2.AuthorizeSecurityGroupIngressWithContext(s.ctx, &ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId: sg.GroupId,
+		IpPermissions: []*ec2.IpPermission{{
+			IpProtocol: aws.String(protocol),
+			FromPort:   aws.Int64(port),
+			ToPort:     aws.Int64(port),
+			IpRanges:   ranges,
+		}},
+	}); err != nil {
+		return errors.Wrapf(err, "failed to authorize ingress to security group %q", *sg.GroupId)
+	}
+
+	s.log.V(2).Info("Authorized ingress to security group", "security-group-id", sg.GroupId, "port", port)
+	return nil
+}
+
+// deleteSecurityGroups deletes the security groups of the vpc owned by the cluster.
+// Security groups that are still in use by network interfaces, e.g. of mount targets that are
+// going away, can't be deleted yet and a not ready error is returned.
+func (s *Service) deleteSecurityGroups(clusterName string, vpc *v1alpha1.VPC) error {
+	out, err := s.EC2.DescribeSecurityGroupsWithContext(s.ctx, &ec2.DescribeSecurityGroupsInput{
+		Filters: s.addTagFilters(clusterName, []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(vpc.ID)},
+			},
+		}),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe security groups in vpc %q", vpc.ID)
+	}
+
+	for _, sg := range out.SecurityGroups {
+		deleted, err := s.releaseResource(clusterName, *sg.GroupId, tagsToMap(sg.Tags), func() error {
+			_, err := s.EC2.DeleteSecurityGroupWithContext(s.ctx, &ec2.DeleteSecurityGroupInput{GroupId: sg.GroupId})
+			if isDependencyViolation(err) {
+				return NewNotReady(errors.Wrapf(err, "security group %q is still in use", *sg.GroupId))
+			}
+			return errors.Wrapf(err, "failed to delete security group %q", *sg.GroupId)
+		})
+		if err != nil {
+			return err
+		}
+
+		if deleted {
+			s.log.V(2).Info("Deleted security group", "security-group-id", sg.GroupId, "vpc-id", vpc.ID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/fake"
+)
+
+func TestReconcileFileSystemSecurityGroup(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+
+	id, err := s.ReconcileFileSystemSecurityGroup(context.TODO(), "test-cluster", map[string]string{"team": "ml"}, network)
+	if err != nil {
+		t.Fatalf("failed to reconcile security group: %v", err)
+	}
+
+	// A second reconcile finds the same group and doesn't authorize the vpc again.
+	again, err := s.ReconcileFileSystemSecurityGroup(context.TODO(), "test-cluster", map[string]string{"team": "ml"}, network)
+	if err != nil {
+		t.Fatalf("failed to reconcile security group again: %v", err)
+	}
+	if again != id {
+		t.Fatalf("expected security group %q, got %q", id, again)
+	}
+
+	out, err := f.DescribeSecurityGroupsWithContext(context.TODO(), &ec2.DescribeSecurityGroupsInput{GroupIds: aws.StringSlice([]string{id})})
+	if err != nil {
+		t.Fatalf("failed to describe security group: %v", err)
+	}
+	sg := out.SecurityGroups[0]
+
+	tags := tagsToMap(sg.Tags)
+	if tags[TagNameKubernetesClusterPrefix+"test-cluster"] != ResourceLifecycleOwned || tags["team"] != "ml" {
+		t.Fatalf("unexpected tags: %v", tags)
+	}
+
+	if len(sg.IpPermissions) != 1 {
+		t.Fatalf("expected a single permission, got: %v", sg.IpPermissions)
+	}
+	perm := sg.IpPermissions[0]
+	if aws.Int64Value(perm.FromPort) != nfsPort || len(perm.IpRanges) != 1 || aws.StringValue(perm.IpRanges[0].CidrIp) != network.VPC.CidrBlock {
+		t.Fatalf("expected nfs to be allowed from %q, got: %v", network.VPC.CidrBlock, perm)
+	}
+
+	// The group is deleted with the network, which it would otherwise keep from being deleted.
+	deleteNetworkUntilDone(t, s, "test-cluster", network)
+	if _, err := f.DescribeSecurityGroupsWithContext(context.TODO(), &ec2.DescribeSecurityGroupsInput{GroupIds: aws.StringSlice([]string{id})}); err == nil {
+		t.Fatalf("expected security group %q to be deleted", id)
+	}
+}
+
+func TestReconcileFileSystemSecurityGroupNotOwned(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	network := &v1alpha1.Network{}
+	reconcileNetworkUntilReady(t, s, "test-cluster", &v1alpha1.NetworkSpec{}, nil, network)
+
+	if _, err := f.CreateSecurityGroupWithContext(context.TODO(), &ec2.CreateSecurityGroupInput{
+		GroupName:   aws.String("test-cluster-efs"),
+		Description: aws.String("created by hand"),
+		VpcId:       aws.String(network.VPC.ID),
+	}); err != nil {
+		t.Fatalf("failed to create security group: %v", err)
+	}
+
+	if _, err := s.ReconcileFileSystemSecurityGroup(context.TODO(), "test-cluster", nil, network); !IsConflict(err) {
+		t.Fatalf("expected a conflict error, got: %v", err)
+	}
+}
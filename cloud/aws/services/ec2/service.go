@@ -14,19 +14,113 @@
 package ec2
 
 import (
-	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/logger"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/policy"
 )
 
 // Service holds a collection of interfaces.
 // The interfaces are broken down like this to group functions together.
 // One alternative is to have a large list of functions from the ec2 client.
 type Service struct {
-	EC2 ec2iface.EC2API
+	EC2 EC2API
+
+	log logr.Logger
+
+	// ctx is the context of the call the service is used for, which every AWS call is made with.
+	ctx aws.Context
+
+	// concurrency is the maximum number of independent resources reconciled at once.
+	concurrency int
+
+	// additionalTags are applied to every resource created or reconciled by the service.
+	additionalTags map[string]string
+
+	// manager is the name of the management cluster the service is used by, if any.
+	manager string
+
+	// defaultVPCCIDR is the cidr block of the VPCs created for clusters that don't set one.
+	defaultVPCCIDR string
+
+	// locations restricts the availability zones subnets are created and instances launched in.
+	locations *policy.Locations
 }
 
 // NewService returns a new service given the ec2 api client.
-func NewService(i ec2iface.EC2API) *Service {
+func NewService(i EC2API) *Service {
 	return &Service{
-		EC2: i,
+		EC2:            i,
+		log:            logger.Default(),
+		ctx:            aws.BackgroundContext(),
+		concurrency:    1,
+		defaultVPCCIDR: defaultVpcCidr,
 	}
 }
+
+// WithLogger returns a copy of the service that logs to the given logger.
+func (s *Service) WithLogger(log logr.Logger) *Service {
+	c := *s
+	c.log = log
+	return &c
+}
+
+// WithConcurrency returns a copy of the service that reconciles up to n independent
+// resources, like the subnets or NAT gateways of different availability zones, concurrently.
+func (s *Service) WithConcurrency(n int) *Service {
+	c := *s
+	c.concurrency = n
+	return &c
+}
+
+// WithManager returns a copy of the service that tags the resources it creates with the name of
+// the management cluster it is used by, and refuses to modify resources owned by the cluster that
+// are tagged for another management cluster. That way two management clusters managing clusters
+// of the same name in the same account don't overwrite each other's changes.
+func (s *Service) WithManager(name string) *Service {
+	c := *s
+	c.manager = name
+	return &c
+}
+
+// WithDefaultVPCCIDR returns a copy of the service that creates VPCs with the given cidr block,
+// instead of 10.0.0.0/16, for clusters that don't set one. The default subnets are carved out
+// of the cidr block of the VPC.
+func (s *Service) WithDefaultVPCCIDR(cidr string) *Service {
+	c := *s
+	c.defaultVPCCIDR = cidr
+	return &c
+}
+
+// WithLocations returns a copy of the service that only creates subnets, and launches
+// instances, in the availability zones the locations allow. The default subnets are spread
+// over the allowed zones only.
+func (s *Service) WithLocations(l *policy.Locations) *Service {
+	c := *s
+	c.locations = l
+	return &c
+}
+
+// withValues returns a copy of the service whose logger carries the given
+// key/value pairs as context on every message.
+func (s *Service) withValues(keysAndValues ...interface{}) *Service {
+	return s.WithLogger(s.log.WithValues(keysAndValues...))
+}
+
+// withAdditionalTags returns a copy of the service that applies the given tags to the
+// resources it creates and reconciles.
+func (s *Service) withAdditionalTags(tags map[string]string) *Service {
+	c := *s
+	c.additionalTags = tags
+	return &c
+}
+
+// withContext returns a copy of the service whose AWS calls are made with the given context,
+// so that they are cancelled with it.
+func (s *Service) withContext(ctx context.Context) *Service {
+	c := *s
+	c.ctx = ctx
+	return &c
+}
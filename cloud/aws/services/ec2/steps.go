@@ -0,0 +1,85 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+)
+
+// reconcileStepDuration is the duration of every reconcile of a provisioning step, the status
+// only keeps when the steps first completed.
+var reconcileStepDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "aws_reconcile_step_duration_seconds",
+	Help:    "Duration of the reconciles of provisioning steps of clusters in seconds.",
+	Buckets: prometheus.ExponentialBuckets(0.1, 2, 12),
+}, []string{"step"})
+
+func init() {
+	prometheus.MustRegister(reconcileStepDuration)
+}
+
+// StepTimer records the provisioning steps of a cluster into its status.
+// It's safe to use from the goroutines of steps running in parallel.
+type StepTimer struct {
+	mu    sync.Mutex
+	steps *[]v1alpha1.ProvisioningStep
+}
+
+// NewStepTimer returns a step timer recording into the steps.
+func NewStepTimer(steps *[]v1alpha1.ProvisioningStep) *StepTimer {
+	return &StepTimer{steps: steps}
+}
+
+// Time runs the step and records it. The start time of the step is set on its first run, and
+// its completion time once it first succeeds. Steps that aren't ready yet are still in progress.
+// The status doesn't change once a step completed, its later reconciles are only observed by
+// the reconcile step duration metric.
+func (t *StepTimer) Time(name string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	reconcileStepDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	step := t.find(name)
+	if step == nil {
+		*t.steps = append(*t.steps, v1alpha1.ProvisioningStep{Name: name, StartTime: metav1.NewTime(start)})
+		step = &(*t.steps)[len(*t.steps)-1]
+	}
+	if step.CompletionTime != nil {
+		return err
+	}
+	step.Reconciles++
+	if err == nil {
+		now := metav1.Now()
+		step.CompletionTime = &now
+	}
+	return err
+}
+
+// find returns the recorded step with the name, or nil.
+func (t *StepTimer) find(name string) *v1alpha1.ProvisioningStep {
+	for i := range *t.steps {
+		if (*t.steps)[i].Name == name {
+			return &(*t.steps)[i]
+		}
+	}
+	return nil
+}
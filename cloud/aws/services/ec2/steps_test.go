@@ -0,0 +1,59 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+)
+
+func TestStepTimer(t *testing.T) {
+	var steps []v1alpha1.ProvisioningStep
+
+	// A step that isn't ready is in progress.
+	pending := NewNotReady(errors.New("nat gateway pending"))
+	if err := NewStepTimer(&steps).Time("natGateways", func() error { return pending }); err != pending {
+		t.Fatalf("expected the error of the step, got: %v", err)
+	}
+	if len(steps) != 1 || steps[0].Name != "natGateways" || steps[0].StartTime.IsZero() || steps[0].CompletionTime != nil || steps[0].Reconciles != 1 {
+		t.Fatalf("expected a step in progress, got: %+v", steps)
+	}
+	start := steps[0].StartTime
+
+	// The next reconcile of the step completes it.
+	if err := NewStepTimer(&steps).Time("natGateways", func() error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(steps) != 1 || !steps[0].StartTime.Equal(&start) || steps[0].CompletionTime == nil || steps[0].Reconciles != 2 {
+		t.Fatalf("expected a completed step started on the first reconcile, got: %+v", steps)
+	}
+	completion := *steps[0].CompletionTime
+
+	// Later reconciles don't change the status, even if they fail.
+	timer := NewStepTimer(&steps)
+	if err := timer.Time("natGateways", func() error { return errors.New("throttled") }); err == nil {
+		t.Fatalf("expected the error of the step")
+	}
+	if err := timer.Time("routeTables", func() error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(steps) != 2 || !steps[0].CompletionTime.Equal(&completion) || steps[0].Reconciles != 2 {
+		t.Fatalf("expected the completed step to be kept, got: %+v", steps)
+	}
+	if steps[1].Name != "routeTables" || steps[1].CompletionTime == nil || steps[1].Reconciles != 1 {
+		t.Fatalf("expected the next step to be recorded, got: %+v", steps[1])
+	}
+}
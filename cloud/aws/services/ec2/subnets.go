@@ -16,18 +16,12 @@ package ec2
 import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/golang/glog"
 	"github.com/pkg/errors"
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
 )
 
-const (
-	defaultPrivateSubnetCidr = "10.0.0.0/24"
-	defaultPublicSubnetCidr  = "10.0.1.0/24"
-)
-
-func (s *Service) reconcileSubnets(network *v1alpha1.Network) error {
-	glog.V(2).Infof("Reconciling subnets")
+func (s *Service) reconcileSubnets(clusterName string, spec *v1alpha1.NetworkSpec, network *v1alpha1.Network) error {
+	s.log.V(2).Info("Reconciling subnets", "vpc-id", network.VPC.ID)
 
 	// Make sure all subnets have a vpc id.
 	for _, sn := range network.Subnets {
@@ -36,40 +30,95 @@ func (s *Service) reconcileSubnets(network *v1alpha1.Network) error {
 		}
 	}
 
+	// Subnets that exist are kept, new ones are only created in the allowed zones.
+	for _, sn := range network.Subnets {
+		if sn.ID != "" {
+			continue
+		}
+		if err := s.locations.CheckZone(sn.AvailabilityZone); err != nil {
+			return NewInvalidConfiguration(errors.Wrapf(err, "failed to create subnet %s", sn.CidrBlock))
+		}
+	}
+
 	// Describe subnets in the vpc.
-	existing, err := s.describeVpcSubnets(network.VPC.ID)
+	existing, err := s.describeVpcSubnets(clusterName, &network.VPC)
 	if err != nil {
 		return err
 	}
 
 	// If the subnets are empty, populate the slice with the default configuration.
-	// Adds a single private and public subnet in the first available zone.
+	// Adds a private and public subnet in each of the first available zones,
+	// isolated networks only get the private subnets.
 	if len(network.Subnets) < 2 {
 		zones, err := s.getAvailableZones()
 		if err != nil {
 			return err
 		}
 
-		if len(network.Subnets.FilterPrivate()) == 0 {
-			network.Subnets = append(network.Subnets, &v1alpha1.Subnet{
-				VpcID:            network.VPC.ID,
-				CidrBlock:        defaultPrivateSubnetCidr,
-				AvailabilityZone: zones[0],
-				IsPublic:         false,
-			})
+		count := availabilityZoneCount(spec)
+		var defaultZones []string
+		for _, z := range zones {
+			if !isRemovedZone(spec, z) && len(defaultZones) < count {
+				defaultZones = append(defaultZones, z)
+			}
+		}
+		if count > 1 && len(defaultZones) < count {
+			return errors.Errorf("failed to spread subnets over %d availability zones: only %d are available", count, len(defaultZones))
+		}
+		if len(defaultZones) == 0 {
+			return NewInvalidConfiguration(errors.Errorf("failed to create subnets: every available zone of %v is removed", zones))
 		}
 
-		if len(network.Subnets.FilterPublic()) == 0 {
-			network.Subnets = append(network.Subnets, &v1alpha1.Subnet{
-				VpcID:            network.VPC.ID,
-				CidrBlock:        defaultPublicSubnetCidr,
-				AvailabilityZone: zones[0],
-				IsPublic:         true,
-			})
+		vpcCidr := network.VPC.CidrBlock
+		if vpcCidr == "" {
+			vpcCidr = s.defaultVPCCIDR
 		}
+		privateCidrs, publicCidrs, err := subnetCidrs(spec, vpcCidr, len(defaultZones))
+		if err != nil {
+			return err
+		}
+
+		var added v1alpha1.Subnets
+		private, public := len(network.Subnets.FilterPrivate()), len(network.Subnets.FilterPublic())
+		for i, zone := range defaultZones {
+			if private <= i {
+				added = append(added, &v1alpha1.Subnet{
+					VpcID:            network.VPC.ID,
+					CidrBlock:        privateCidrs[i],
+					AvailabilityZone: zone,
+					IsPublic:         false,
+				})
+			}
 
+			if public <= i && !spec.Isolated {
+				added = append(added, &v1alpha1.Subnet{
+					VpcID:            network.VPC.ID,
+					CidrBlock:        publicCidrs[i],
+					AvailabilityZone: zone,
+					IsPublic:         true,
+				})
+			}
+		}
+
+		cidrs := make([]string, 0, len(added))
+		for _, sn := range added {
+			cidrs = append(cidrs, sn.CidrBlock)
+		}
+		if err := validateSubnetLayout(network, cidrs); err != nil {
+			return err
+		}
+		network.Subnets = append(network.Subnets, added...)
 	}
 
+	// Make sure the additional tags of the existing subnets are up to date.
+	for _, exsn := range existing {
+		if exsn.Tags, err = s.reconcileResourceTags(clusterName, exsn.ID, exsn.Tags); err != nil {
+			return errors.Wrapf(err, "failed to update tags of subnet %q", exsn.ID)
+		}
+	}
+
+	found := existing.ToMap()
+
 LoopExisting:
 	for _, exsn := range existing {
 		// Check if the subnet already exists in the state, in that case reconcile it.
@@ -78,7 +127,10 @@ LoopExisting:
 			// or if they are in the same vpc and the cidr block is the same.
 			if (sn.ID != "" && exsn.ID == sn.ID) || (sn.VpcID == exsn.VpcID && sn.CidrBlock == exsn.CidrBlock) {
 				// TODO(vincepri): check if subnet needs to be updated.
+				// The shared route table is set by the user, AWS doesn't know it.
+				shared := sn.SharedRouteTableID
 				exsn.DeepCopyInto(sn)
+				sn.SharedRouteTableID = shared
 				continue LoopExisting
 			}
 		}
@@ -87,54 +139,79 @@ LoopExisting:
 		network.Subnets = append(network.Subnets, exsn)
 	}
 
+	var unfinished v1alpha1.Subnets
+	for _, sn := range network.Subnets {
+		if _, ok := found[sn.ID]; sn.ID != "" && !ok {
+			unfinished = append(unfinished, sn)
+		}
+	}
+	if err := s.finishSubnets(clusterName, unfinished); err != nil {
+		return err
+	}
+
 	// Proceed to create the rest of the subnets that don't have an ID.
+	var missing v1alpha1.Subnets
 	for _, subnet := range network.Subnets {
-		if subnet.ID != "" {
-			continue
+		if subnet.ID == "" && !isRemovedZone(spec, subnet.AvailabilityZone) {
+			missing = append(missing, subnet)
 		}
+	}
 
-		nsn, err := s.createSubnet(subnet)
+	err = s.parallelize(len(missing), func(i int) error {
+		nsn, err := s.createSubnet(clusterName, missing[i])
 		if err != nil {
 			return err
 		}
 
-		nsn.DeepCopyInto(subnet)
+		shared := missing[i].SharedRouteTableID
+		nsn.DeepCopyInto(missing[i])
+		missing[i].SharedRouteTableID = shared
+		return nil
+	})
+
+	if err != nil {
+		return err
 	}
 
-	glog.V(2).Infof("Subnets available: %v", network.Subnets)
+	s.log.V(2).Info("Subnets available", "subnets", network.Subnets)
 	return nil
 }
 
-func (s *Service) describeVpcSubnets(vpcID string) (v1alpha1.Subnets, error) {
-	out, err := s.EC2.DescribeSubnets(&ec2.DescribeSubnetsInput{
-		Filters: []*ec2.Filter{
+func (s *Service) describeVpcSubnets(clusterName string, vpc *v1alpha1.VPC) (v1alpha1.Subnets, error) {
+	out, err := s.EC2.DescribeSubnetsWithContext(s.ctx, &ec2.DescribeSubnetsInput{
+		Filters: s.addNetworkTagFilters(clusterName, vpc, []*ec2.Filter{
 			{
 				Name:   aws.String("vpc-id"),
-				Values: []*string{aws.String(vpcID)},
+				Values: []*string{aws.String(vpc.ID)},
 			},
-		},
+		}),
 	})
 
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to describe subnets in vpc %q", vpcID)
+		return nil, errors.Wrapf(err, "failed to describe subnets in vpc %q", vpc.ID)
 	}
 
 	subnets := make([]*v1alpha1.Subnet, 0, len(out.Subnets))
 	for _, ec2sn := range out.Subnets {
-		subnets = append(subnets, &v1alpha1.Subnet{
-			ID:               *ec2sn.SubnetId,
-			VpcID:            *ec2sn.VpcId,
-			CidrBlock:        *ec2sn.CidrBlock,
-			AvailabilityZone: *ec2sn.AvailabilityZone,
-			IsPublic:         *ec2sn.MapPublicIpOnLaunch,
-		})
+		subnets = append(subnets, subnetFromEC2(ec2sn))
 	}
 
 	return subnets, nil
 }
 
-func (s *Service) createSubnet(sn *v1alpha1.Subnet) (*v1alpha1.Subnet, error) {
-	out, err := s.EC2.CreateSubnet(&ec2.CreateSubnetInput{
+func subnetFromEC2(ec2sn *ec2.Subnet) *v1alpha1.Subnet {
+	return &v1alpha1.Subnet{
+		ID:               *ec2sn.SubnetId,
+		VpcID:            *ec2sn.VpcId,
+		CidrBlock:        *ec2sn.CidrBlock,
+		AvailabilityZone: *ec2sn.AvailabilityZone,
+		IsPublic:         *ec2sn.MapPublicIpOnLaunch,
+		Tags:             tagsToMap(ec2sn.Tags),
+	}
+}
+
+func (s *Service) createSubnet(clusterName string, sn *v1alpha1.Subnet) (*v1alpha1.Subnet, error) {
+	out, err := s.EC2.CreateSubnetWithContext(s.ctx, &ec2.CreateSubnetInput{
 		VpcId:            aws.String(sn.VpcID),
 		CidrBlock:        aws.String(sn.CidrBlock),
 		AvailabilityZone: aws.String(sn.AvailabilityZone),
@@ -144,38 +221,119 @@ func (s *Service) createSubnet(sn *v1alpha1.Subnet) (*v1alpha1.Subnet, error) {
 		return nil, errors.Wrap(err, "failed to create subnet")
 	}
 
-	wReq := &ec2.DescribeSubnetsInput{SubnetIds: []*string{out.Subnet.SubnetId}}
-	if err := s.EC2.WaitUntilSubnetAvailable(wReq); err != nil {
-		return nil, errors.Wrapf(err, "failed to wait for subnet %q", *out.Subnet.SubnetId)
+	// The subnet is recorded as owned right away, so that a subnet that couldn't be tagged is
+	// finished by the next reconcile instead of being created again.
+	sn.ID = *out.Subnet.SubnetId
+	sn.Tags = s.buildTags(clusterName, ResourceLifecycleOwned, nil)
+
+	nsn, err := s.finishSubnet(clusterName, out.Subnet, sn.IsPublic)
+	if err != nil {
+		return nil, err
 	}
 
-	if sn.IsPublic {
+	s.log.V(2).Info("Created new subnet", "subnet-id", out.Subnet.SubnetId, "vpc-id", out.Subnet.VpcId,
+		"cidr-block", out.Subnet.CidrBlock, "availability-zone", out.Subnet.AvailabilityZone)
+	return nsn, nil
+}
+
+// finishSubnet waits for a new subnet to be available, tags it as owned by the cluster and makes
+// public subnets assign public IPs.
+func (s *Service) finishSubnet(clusterName string, ec2sn *ec2.Subnet, public bool) (*v1alpha1.Subnet, error) {
+	wReq := &ec2.DescribeSubnetsInput{SubnetIds: []*string{ec2sn.SubnetId}}
+	if err := s.EC2.WaitUntilSubnetAvailableWithContext(s.ctx, wReq); err != nil {
+		return nil, errors.Wrapf(err, "failed to wait for subnet %q", *ec2sn.SubnetId)
+	}
+
+	if err := s.createTags(clusterName, *ec2sn.SubnetId, ResourceLifecycleOwned, nil); err != nil {
+		return nil, errors.Wrapf(err, "failed to tag subnet %q", *ec2sn.SubnetId)
+	}
+
+	if public {
 		attReq := &ec2.ModifySubnetAttributeInput{
 			MapPublicIpOnLaunch: &ec2.AttributeBooleanValue{
 				Value: aws.Bool(true),
 			},
-			SubnetId: out.Subnet.SubnetId,
+			SubnetId: ec2sn.SubnetId,
 		}
 
-		if _, err := s.EC2.ModifySubnetAttribute(attReq); err != nil {
-			return nil, errors.Wrapf(err, "failed to set subnet %q attributes", *out.Subnet.SubnetId)
+		if _, err := s.EC2.ModifySubnetAttributeWithContext(s.ctx, attReq); err != nil {
+			return nil, errors.Wrapf(err, "failed to set subnet %q attributes", *ec2sn.SubnetId)
 		}
 	}
 
-	glog.V(2).Infof("Created new subnet %q in VPC %q with cidr %q and availability zone %q",
-		*out.Subnet.SubnetId, *out.Subnet.VpcId, *out.Subnet.CidrBlock, *out.Subnet.AvailabilityZone)
-
 	return &v1alpha1.Subnet{
-		ID:               *out.Subnet.SubnetId,
-		VpcID:            *out.Subnet.VpcId,
-		AvailabilityZone: *out.Subnet.AvailabilityZone,
-		CidrBlock:        *out.Subnet.CidrBlock,
-		IsPublic:         *out.Subnet.MapPublicIpOnLaunch,
+		ID:               *ec2sn.SubnetId,
+		VpcID:            *ec2sn.VpcId,
+		AvailabilityZone: *ec2sn.AvailabilityZone,
+		CidrBlock:        *ec2sn.CidrBlock,
+		// The subnet may reflect the attributes before they were modified above.
+		IsPublic: public,
+		Tags:     s.buildTags(clusterName, ResourceLifecycleOwned, nil),
 	}, nil
 }
 
+// finishSubnets finishes the creation of the subnets of the status that were created, but not
+// tagged for the cluster before the reconcile failed.
+func (s *Service) finishSubnets(clusterName string, subnets v1alpha1.Subnets) error {
+	if len(subnets) == 0 {
+		return nil
+	}
+
+	byID := subnets.ToMap()
+	ids := make([]string, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+
+	// Subnets that are gone are left in the status.
+	out, err := s.EC2.DescribeSubnetsWithContext(s.ctx, &ec2.DescribeSubnetsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("subnet-id"),
+				Values: aws.StringSlice(ids),
+			},
+		},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe subnets %v", ids)
+	}
+
+	for _, ec2sn := range out.Subnets {
+		sn := byID[*ec2sn.SubnetId]
+		if !s.createdUntagged(clusterName, sn.Tags, tagsToMap(ec2sn.Tags)) {
+			continue
+		}
+
+		nsn, err := s.finishSubnet(clusterName, ec2sn, sn.IsPublic)
+		if err != nil {
+			return err
+		}
+
+		shared := sn.SharedRouteTableID
+		nsn.DeepCopyInto(sn)
+		sn.SharedRouteTableID = shared
+		s.log.V(2).Info("Finished creating subnet", "subnet-id", sn.ID, "vpc-id", sn.VpcID)
+	}
+
+	return nil
+}
+
+func (s *Service) deleteSubnets(clusterName string, vpc *v1alpha1.VPC) error {
+	subnets, err := s.describeVpcSubnets(clusterName, vpc)
+	if err != nil {
+		return err
+	}
+
+	return s.parallelize(len(subnets), func(i int) error {
+		_, err := s.releaseResource(clusterName, subnets[i].ID, subnets[i].Tags, func() error {
+			return s.deleteSubnet(subnets[i])
+		})
+		return err
+	})
+}
+
 func (s *Service) deleteSubnet(sn *v1alpha1.Subnet) error {
-	_, err := s.EC2.DeleteSubnet(&ec2.DeleteSubnetInput{
+	_, err := s.EC2.DeleteSubnetWithContext(s.ctx, &ec2.DeleteSubnetInput{
 		SubnetId: aws.String(sn.ID),
 	})
 
@@ -183,6 +341,6 @@ func (s *Service) deleteSubnet(sn *v1alpha1.Subnet) error {
 		return errors.Wrapf(err, "failed to delete subnet %q", sn.ID)
 	}
 
-	glog.V(2).Infof("Deleted subnet %q", sn.ID)
+	s.log.V(2).Info("Deleted subnet", "subnet-id", sn.ID)
 	return nil
 }
@@ -19,6 +19,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/golang/mock/gomock"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/policy"
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/mock_ec2iface"
 )
@@ -51,7 +52,7 @@ func TestReconcileSubnets(t *testing.T) {
 			},
 			expect: func(m *mock_ec2iface.MockEC2API) {
 				m.EXPECT().
-					DescribeAvailabilityZones(gomock.AssignableToTypeOf(&ec2.DescribeAvailabilityZonesInput{})).
+					DescribeAvailabilityZonesWithContext(gomock.Any(), gomock.AssignableToTypeOf(&ec2.DescribeAvailabilityZonesInput{})).
 					Return(&ec2.DescribeAvailabilityZonesOutput{
 						AvailabilityZones: []*ec2.AvailabilityZone{
 							{
@@ -62,12 +63,16 @@ func TestReconcileSubnets(t *testing.T) {
 					}, nil)
 
 				m.EXPECT().
-					DescribeSubnets(gomock.Eq(&ec2.DescribeSubnetsInput{
+					DescribeSubnetsWithContext(gomock.Any(), gomock.Eq(&ec2.DescribeSubnetsInput{
 						Filters: []*ec2.Filter{
 							{
 								Name:   aws.String("vpc-id"),
 								Values: []*string{aws.String(subnetsVPCID)},
 							},
+							{
+								Name:   aws.String("tag-key"),
+								Values: []*string{aws.String("kubernetes.io/cluster/test-cluster")},
+							},
 						},
 					})).
 					Return(&ec2.DescribeSubnetsOutput{
@@ -78,14 +83,15 @@ func TestReconcileSubnets(t *testing.T) {
 								AvailabilityZone:    aws.String("us-east-1a"),
 								CidrBlock:           aws.String("10.0.10.0/24"),
 								MapPublicIpOnLaunch: aws.Bool(false),
+								Tags:                []*ec2.Tag{{Key: aws.String("kubernetes.io/cluster/test-cluster"), Value: aws.String("owned")}},
 							},
 						},
 					}, nil)
 
 				m.EXPECT().
-					CreateSubnet(gomock.Eq(&ec2.CreateSubnetInput{
+					CreateSubnetWithContext(gomock.Any(), gomock.Eq(&ec2.CreateSubnetInput{
 						VpcId:            aws.String(subnetsVPCID),
-						CidrBlock:        aws.String(defaultPublicSubnetCidr),
+						CidrBlock:        aws.String("10.0.1.0/24"),
 						AvailabilityZone: aws.String("us-east-1a"),
 					})).
 					Return(&ec2.CreateSubnetOutput{
@@ -99,10 +105,20 @@ func TestReconcileSubnets(t *testing.T) {
 					}, nil)
 
 				m.EXPECT().
-					WaitUntilSubnetAvailable(gomock.Any())
+					WaitUntilSubnetAvailableWithContext(gomock.Any(), gomock.Any())
+
+				m.EXPECT().
+					CreateTagsWithContext(gomock.Any(), gomock.Eq(&ec2.CreateTagsInput{
+						Resources: aws.StringSlice([]string{"subnet-2"}),
+						Tags: []*ec2.Tag{{
+							Key:   aws.String("kubernetes.io/cluster/test-cluster"),
+							Value: aws.String("owned"),
+						}},
+					})).
+					Return(nil, nil)
 
 				m.EXPECT().
-					ModifySubnetAttribute(&ec2.ModifySubnetAttributeInput{
+					ModifySubnetAttributeWithContext(gomock.Any(), &ec2.ModifySubnetAttributeInput{
 						MapPublicIpOnLaunch: &ec2.AttributeBooleanValue{
 							Value: aws.Bool(true),
 						},
@@ -131,18 +147,22 @@ func TestReconcileSubnets(t *testing.T) {
 			},
 			expect: func(m *mock_ec2iface.MockEC2API) {
 				describeCall := m.EXPECT().
-					DescribeSubnets(gomock.Eq(&ec2.DescribeSubnetsInput{
+					DescribeSubnetsWithContext(gomock.Any(), gomock.Eq(&ec2.DescribeSubnetsInput{
 						Filters: []*ec2.Filter{
 							{
 								Name:   aws.String("vpc-id"),
 								Values: []*string{aws.String(subnetsVPCID)},
 							},
+							{
+								Name:   aws.String("tag-key"),
+								Values: []*string{aws.String("kubernetes.io/cluster/test-cluster")},
+							},
 						},
 					})).
 					Return(&ec2.DescribeSubnetsOutput{}, nil)
 
 				firstSubnet := m.EXPECT().
-					CreateSubnet(gomock.Eq(&ec2.CreateSubnetInput{
+					CreateSubnetWithContext(gomock.Any(), gomock.Eq(&ec2.CreateSubnetInput{
 						VpcId:            aws.String(subnetsVPCID),
 						CidrBlock:        aws.String("10.1.0.0/16"),
 						AvailabilityZone: aws.String("us-east-1a"),
@@ -159,11 +179,22 @@ func TestReconcileSubnets(t *testing.T) {
 					After(describeCall)
 
 				m.EXPECT().
-					WaitUntilSubnetAvailable(gomock.Any()).
+					WaitUntilSubnetAvailableWithContext(gomock.Any(), gomock.Any()).
+					After(firstSubnet)
+
+				m.EXPECT().
+					CreateTagsWithContext(gomock.Any(), gomock.Eq(&ec2.CreateTagsInput{
+						Resources: aws.StringSlice([]string{"subnet-1"}),
+						Tags: []*ec2.Tag{{
+							Key:   aws.String("kubernetes.io/cluster/test-cluster"),
+							Value: aws.String("owned"),
+						}},
+					})).
+					Return(nil, nil).
 					After(firstSubnet)
 
 				secondSubnet := m.EXPECT().
-					CreateSubnet(gomock.Eq(&ec2.CreateSubnetInput{
+					CreateSubnetWithContext(gomock.Any(), gomock.Eq(&ec2.CreateSubnetInput{
 						VpcId:            aws.String(subnetsVPCID),
 						CidrBlock:        aws.String("10.2.0.0/16"),
 						AvailabilityZone: aws.String("us-east-1b"),
@@ -180,11 +211,22 @@ func TestReconcileSubnets(t *testing.T) {
 					After(firstSubnet)
 
 				m.EXPECT().
-					WaitUntilSubnetAvailable(gomock.Any()).
+					WaitUntilSubnetAvailableWithContext(gomock.Any(), gomock.Any()).
+					After(secondSubnet)
+
+				m.EXPECT().
+					CreateTagsWithContext(gomock.Any(), gomock.Eq(&ec2.CreateTagsInput{
+						Resources: aws.StringSlice([]string{"subnet-2"}),
+						Tags: []*ec2.Tag{{
+							Key:   aws.String("kubernetes.io/cluster/test-cluster"),
+							Value: aws.String("owned"),
+						}},
+					})).
+					Return(nil, nil).
 					After(secondSubnet)
 
 				m.EXPECT().
-					ModifySubnetAttribute(&ec2.ModifySubnetAttributeInput{
+					ModifySubnetAttributeWithContext(gomock.Any(), &ec2.ModifySubnetAttributeInput{
 						MapPublicIpOnLaunch: &ec2.AttributeBooleanValue{
 							Value: aws.Bool(true),
 						},
@@ -203,9 +245,54 @@ func TestReconcileSubnets(t *testing.T) {
 			tc.expect(ec2Mock)
 
 			s := NewService(ec2Mock)
-			if err := s.reconcileSubnets(tc.input); err != nil {
+			if err := s.reconcileSubnets("test-cluster", &v1alpha1.NetworkSpec{}, tc.input); err != nil {
 				t.Fatalf("got an unexpected error: %v", err)
 			}
 		})
 	}
 }
+
+func TestReconcileSubnetsLocations(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ec2Mock := mock_ec2iface.NewMockEC2API(mockCtrl)
+	ec2Mock.EXPECT().
+		DescribeAvailabilityZonesWithContext(gomock.Any(), gomock.Any()).
+		Return(&ec2.DescribeAvailabilityZonesOutput{
+			AvailabilityZones: []*ec2.AvailabilityZone{
+				{ZoneName: aws.String("eu-central-1a")},
+				{ZoneName: aws.String("eu-central-1b")},
+				{ZoneName: aws.String("eu-central-1c")},
+			},
+		}, nil).
+		Times(2)
+
+	s := NewService(ec2Mock).WithLocations(&policy.Locations{
+		AllowedZones: []string{"eu-central-1b", "eu-central-1c"},
+		DeniedZones:  []string{"eu-central-1c"},
+	})
+
+	// The default subnets are only spread over the allowed zones.
+	zones, err := s.getAvailableZones()
+	if err != nil {
+		t.Fatalf("failed to get available zones: %v", err)
+	}
+	if len(zones) != 1 || zones[0] != "eu-central-1b" {
+		t.Fatalf("expected only the allowed zone, got: %v", zones)
+	}
+
+	s = s.WithLocations(&policy.Locations{AllowedZones: []string{"us-east-1a"}})
+	if _, err := s.getAvailableZones(); !IsInvalidConfiguration(err) {
+		t.Fatalf("expected an invalid configuration error without allowed zones, got: %v", err)
+	}
+
+	// Subnets are never created in a zone that isn't allowed.
+	network := &v1alpha1.Network{
+		VPC:     v1alpha1.VPC{ID: subnetsVPCID},
+		Subnets: v1alpha1.Subnets{{AvailabilityZone: "us-west-2a", CidrBlock: "10.0.0.0/24"}},
+	}
+	if err := s.reconcileSubnets("test-cluster", &v1alpha1.NetworkSpec{}, network); !IsInvalidConfiguration(err) {
+		t.Fatalf("expected an invalid configuration error, got: %v", err)
+	}
+}
@@ -14,9 +14,15 @@ limitations under the License.
 package ec2
 
 import (
+	"bytes"
+	"sort"
+	"strings"
+	"text/template"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
 )
 
 // TagNameKubernetesClusterPrefix is the tag name we use to differentiate multiple
@@ -25,6 +31,36 @@ import (
 // The tag value is an ownership value
 const TagNameKubernetesClusterPrefix = "kubernetes.io/cluster/"
 
+// TagNameManagedTags is the tag name we use to record the keys of the additional tags
+// set by the provider, so that they can be removed once they are no longer configured.
+// The tag value is a comma separated list of tag keys.
+const TagNameManagedTags = "sigs.k8s.io/cluster-api-provider-aws/managed-tags"
+
+// TagNameWarmPool is the tag name we use to mark the instances of a warm pool, which are kept
+// stopped until they are claimed by a machine of the machine set.
+// The tag value is the name of the launch template of the machine set.
+const TagNameWarmPool = "sigs.k8s.io/cluster-api-provider-aws/warm-pool"
+
+// TagNameManager is the tag name we use to record the management cluster managing a resource
+// owned by a cluster, see Service.WithManager.
+// The tag value is the name of the management cluster.
+const TagNameManager = "sigs.k8s.io/cluster-api-provider-aws/manager"
+
+// TagNameRole is the tag name we use to record the role of the machine of an instance, so that
+// the instances of the control plane can be told apart from the ones of the other nodes.
+// The tag value is RoleControlPlane or RoleNode.
+const TagNameRole = "sigs.k8s.io/cluster-api-provider-aws/role"
+
+const (
+	// RoleControlPlane is the role of the machines running the control plane.
+	RoleControlPlane = "control-plane"
+	// RoleNode is the role of the other machines.
+	RoleNode = "node"
+)
+
+// maxTagValueLength is the maximum length of a tag value accepted by AWS.
+const maxTagValueLength = 256
+
 // ResourceLifecycle configures the lifecycle of a resource
 type ResourceLifecycle string
 
@@ -43,27 +79,221 @@ func (s *Service) clusterTagKey(clusterName string) string {
 	return TagNameKubernetesClusterPrefix + clusterName
 }
 
-// createTags tags a resource with tags including the cluster tag
+// createTags tags a resource with tags including the cluster tag. It's called right after
+// creating the resource, so it's tagged again while EC2 doesn't find the resource yet.
 func (s *Service) createTags(clusterName string, resourceID string, lifecycle ResourceLifecycle, additionalTags map[string]string) error {
 	tags := s.buildTags(clusterName, lifecycle, additionalTags)
+	err := s.waitUntilVisible(resourceID, func() error { return s.tagResource(resourceID, tags) })
+	return errors.Wrapf(err, "failed to tag resource %q in cluster %q", resourceID, clusterName)
+}
 
-	awsTags := make([]*ec2.Tag, 0, len(tags))
-	for k, v := range tags {
-		tag := &ec2.Tag{
-			Key:   aws.String(k),
-			Value: aws.String(v),
+// reconcileTags brings the additional tags of a resource, given its current tags, in line with
+// the additional tags of the service. Missing or changed tags are set and tags that were
+// previously added by the service but are no longer desired are removed. Tags that aren't
+// managed by the service are left untouched.
+// Resources managed by another management cluster are left untouched, a conflict error is
+// returned instead.
+// It returns the tags of the resource after the update.
+func (s *Service) reconcileTags(resourceID string, current map[string]string) (map[string]string, error) {
+	if err := s.checkManager(resourceID, current); err != nil {
+		return nil, err
+	}
+
+	desired := make(map[string]string, len(s.additionalTags)+2)
+	for k, v := range s.additionalTags {
+		desired[k] = v
+	}
+	if len(s.additionalTags) > 0 {
+		desired[TagNameManagedTags] = managedTagsValue(s.additionalTags)
+	}
+	if s.manager != "" {
+		desired[TagNameManager] = s.manager
+	}
+
+	changed := make(map[string]string)
+	for k, v := range desired {
+		if cv, ok := current[k]; !ok || cv != v {
+			changed[k] = v
+		}
+	}
+
+	var stale []string
+	managed := parseManagedTags(current[TagNameManagedTags])
+	if _, ok := current[TagNameManagedTags]; ok {
+		managed = append(managed, TagNameManagedTags)
+	}
+	for _, k := range managed {
+		if _, ok := desired[k]; ok {
+			continue
+		}
+		if _, ok := current[k]; ok {
+			stale = append(stale, k)
 		}
-		awsTags = append(awsTags, tag)
 	}
 
+	if len(changed) == 0 && len(stale) == 0 {
+		return current, nil
+	}
+
+	if len(stale) > 0 {
+		if err := s.untagResource(resourceID, stale); err != nil {
+			return nil, errors.Wrapf(err, "failed to remove stale tags of resource %q", resourceID)
+		}
+	}
+
+	if len(changed) > 0 {
+		if err := s.tagResource(resourceID, changed); err != nil {
+			return nil, errors.Wrapf(err, "failed to update tags of resource %q", resourceID)
+		}
+	}
+
+	s.log.V(2).Info("Updated resource tags", "resource-id", resourceID, "tags", changed, "removed", stale)
+
+	res := make(map[string]string, len(current)+len(changed))
+	for k, v := range current {
+		res[k] = v
+	}
+	for _, k := range stale {
+		delete(res, k)
+	}
+	for k, v := range changed {
+		res[k] = v
+	}
+	return res, nil
+}
+
+// checkManager returns a conflict error if the resource, given its tags, is managed by another
+// management cluster. Resources without manager tag are taken over by the service.
+func (s *Service) checkManager(resourceID string, tags map[string]string) error {
+	if manager, ok := tags[TagNameManager]; ok && s.manager != "" && manager != s.manager {
+		return NewConflict(errors.Errorf("resource %q is managed by management cluster %q", resourceID, manager))
+	}
+	return nil
+}
+
+// tagResource sets the given tags on a resource.
+func (s *Service) tagResource(resourceID string, tags map[string]string) error {
 	createTagsInput := &ec2.CreateTagsInput{
 		Resources: aws.StringSlice([]string{resourceID}),
-		Tags:      awsTags,
+		Tags:      mapToTags(tags),
 	}
 
-	_, err := s.EC2.CreateTags(createTagsInput)
+	_, err := s.EC2.CreateTagsWithContext(s.ctx, createTagsInput)
+	return err
+}
 
-	return errors.Wrapf(err, "failed to tag resource %q in cluster %q", resourceID, clusterName)
+// untagResource removes the tags with the given keys from a resource.
+func (s *Service) untagResource(resourceID string, keys []string) error {
+	sort.Strings(keys)
+
+	tags := make([]*ec2.Tag, 0, len(keys))
+	for _, k := range keys {
+		tags = append(tags, &ec2.Tag{Key: aws.String(k)})
+	}
+
+	_, err := s.EC2.DeleteTagsWithContext(s.ctx, &ec2.DeleteTagsInput{
+		Resources: aws.StringSlice([]string{resourceID}),
+		Tags:      tags,
+	})
+	return err
+}
+
+// clusterLifecycle returns the lifecycle of a resource for the cluster, given its tags,
+// and whether the resource is tagged for the cluster at all.
+func (s *Service) clusterLifecycle(clusterName string, tags map[string]string) (ResourceLifecycle, bool) {
+	v, ok := tags[s.clusterTagKey(clusterName)]
+	return ResourceLifecycle(v), ok
+}
+
+// otherClusterTags returns the sorted cluster tag keys of the other clusters using a resource.
+func (s *Service) otherClusterTags(clusterName string, tags map[string]string) []string {
+	var res []string
+	for k := range tags {
+		if strings.HasPrefix(k, TagNameKubernetesClusterPrefix) && k != s.clusterTagKey(clusterName) {
+			res = append(res, k)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// reconcileResourceTags reconciles the tags of a network resource found for the cluster.
+// Resources that aren't tagged for the cluster yet belong to other clusters or were created outside
+// of the provider, they are tagged as shared. Additional tags are only reconciled on resources owned
+// by the cluster, so that clusters sharing a resource don't compete over its tags.
+// It returns the tags of the resource after the update.
+func (s *Service) reconcileResourceTags(clusterName string, resourceID string, tags map[string]string) (map[string]string, error) {
+	lifecycle, ok := s.clusterLifecycle(clusterName, tags)
+	switch {
+	case !ok:
+		if err := s.tagResource(resourceID, map[string]string{s.clusterTagKey(clusterName): ResourceLifecycleShared}); err != nil {
+			return nil, errors.Wrapf(err, "failed to tag shared resource %q", resourceID)
+		}
+
+		s.log.V(2).Info("Sharing resource with other clusters", "resource-id", resourceID)
+
+		res := make(map[string]string, len(tags)+1)
+		for k, v := range tags {
+			res[k] = v
+		}
+		res[s.clusterTagKey(clusterName)] = ResourceLifecycleShared
+		return res, nil
+	case lifecycle == ResourceLifecycleOwned:
+		return s.reconcileTags(resourceID, tags)
+	}
+
+	return tags, nil
+}
+
+// createdUntagged returns true if a resource is recorded as owned by the cluster in the status,
+// given its recorded tags, but isn't tagged for any cluster in AWS, given its current tags. The
+// resource was created for the cluster, but tagging it failed.
+func (s *Service) createdUntagged(clusterName string, recorded map[string]string, current map[string]string) bool {
+	if lifecycle, _ := s.clusterLifecycle(clusterName, recorded); lifecycle != ResourceLifecycleOwned {
+		return false
+	}
+	_, tagged := s.clusterLifecycle(clusterName, current)
+	return !tagged && len(s.otherClusterTags(clusterName, current)) == 0
+}
+
+// releaseResource removes the cluster from the clusters using a resource, given its tags.
+// The resource is deleted with the delete function if the cluster owns it and no other cluster
+// uses it. If other clusters still use a resource owned by the cluster, the ownership is handed
+// over to one of them, so that the resource is deleted together with the last cluster using it.
+// Resources that aren't tagged for the cluster are left untouched. Resources owned by the cluster
+// but managed by another management cluster aren't released, a conflict error is returned instead.
+// It returns true if the resource has been deleted.
+func (s *Service) releaseResource(clusterName string, resourceID string, tags map[string]string, deleteFn func() error) (bool, error) {
+	lifecycle, ok := s.clusterLifecycle(clusterName, tags)
+	if !ok {
+		return false, nil
+	}
+
+	if lifecycle == ResourceLifecycleOwned {
+		if err := s.checkManager(resourceID, tags); err != nil {
+			return false, err
+		}
+	}
+
+	others := s.otherClusterTags(clusterName, tags)
+	if lifecycle == ResourceLifecycleOwned && len(others) == 0 {
+		return true, deleteFn()
+	}
+
+	if lifecycle == ResourceLifecycleOwned {
+		if err := s.tagResource(resourceID, map[string]string{others[0]: ResourceLifecycleOwned}); err != nil {
+			return false, errors.Wrapf(err, "failed to hand over ownership of resource %q", resourceID)
+		}
+
+		s.log.V(2).Info("Handed over ownership of resource", "resource-id", resourceID, "owner", strings.TrimPrefix(others[0], TagNameKubernetesClusterPrefix))
+	}
+
+	if err := s.untagResource(resourceID, []string{s.clusterTagKey(clusterName)}); err != nil {
+		return false, errors.Wrapf(err, "failed to remove cluster tag of resource %q", resourceID)
+	}
+
+	s.log.V(2).Info("Released shared resource", "resource-id", resourceID)
+	return false, nil
 }
 
 // Add additional cluster tag filters, to match on our tags
@@ -75,14 +305,120 @@ func (s *Service) addTagFilters(clusterName string, filters []*ec2.Filter) []*ec
 	return filters
 }
 
-// buildTags builds tags including the cluster tag
+// addNetworkTagFilters adds the cluster tag filters for resources in the vpc, unless the cluster
+// shares the vpc with other clusters. In that case all resources in the vpc are used by the cluster.
+func (s *Service) addNetworkTagFilters(clusterName string, vpc *v1alpha1.VPC, filters []*ec2.Filter) []*ec2.Filter {
+	if lifecycle, _ := s.clusterLifecycle(clusterName, vpc.Tags); lifecycle == ResourceLifecycleShared {
+		return filters
+	}
+	return s.addTagFilters(clusterName, filters)
+}
+
+// buildTags builds tags including the cluster tag.
+// The additional tags of the service are applied first and can be overridden by the given ones.
 func (s *Service) buildTags(clusterName string, lifecycle ResourceLifecycle, additionalTags map[string]string) map[string]string {
 	tags := make(map[string]string)
+	for k, v := range s.additionalTags {
+		tags[k] = v
+	}
 	for k, v := range additionalTags {
 		tags[k] = v
 	}
 
+	if len(tags) > 0 {
+		tags[TagNameManagedTags] = managedTagsValue(tags)
+	}
+
 	tags[s.clusterTagKey(clusterName)] = string(lifecycle)
+	if lifecycle == ResourceLifecycleOwned && s.manager != "" {
+		tags[TagNameManager] = s.manager
+	}
 
 	return tags
 }
+
+// managedTagsValue returns the value of the managed tags tag for the given tags.
+func managedTagsValue(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// parseManagedTags returns the tag keys recorded in the value of the managed tags tag.
+func parseManagedTags(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// tagsToMap converts EC2 tags to a map.
+func tagsToMap(src []*ec2.Tag) map[string]string {
+	tags := make(map[string]string, len(src))
+	for _, t := range src {
+		tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	return tags
+}
+
+// mapToTags converts a map to EC2 tags, in key order.
+func mapToTags(src map[string]string) []*ec2.Tag {
+	keys := make([]string, 0, len(src))
+	for k := range src {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tags := make([]*ec2.Tag, 0, len(src))
+	for _, k := range keys {
+		tags = append(tags, &ec2.Tag{
+			Key:   aws.String(k),
+			Value: aws.String(src[k]),
+		})
+	}
+	return tags
+}
+
+// TagTemplateData is the data available to the templates in additional tag values.
+type TagTemplateData struct {
+	// ClusterName is the name of the cluster the resource belongs to.
+	ClusterName string
+
+	// MachineName is the name of the machine for instances and volumes, empty otherwise.
+	MachineName string
+}
+
+// RenderTags returns the tags with all values rendered as text templates using the given data,
+// e.g. a value of "{{ .ClusterName }}-nodes" is rendered as "test-nodes" in cluster "test".
+func RenderTags(tags map[string]string, data TagTemplateData) (map[string]string, error) {
+	res := make(map[string]string, len(tags))
+	for k, v := range tags {
+		if strings.Contains(k, ",") {
+			return nil, errors.Errorf("tag key %q must not contain a comma", k)
+		}
+		if strings.HasPrefix(k, TagNameKubernetesClusterPrefix) || k == TagNameManagedTags || k == TagNameRole {
+			return nil, errors.Errorf("tag key %q is reserved for the provider", k)
+		}
+
+		tmpl, err := template.New(k).Parse(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse value of tag %q", k)
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, errors.Wrapf(err, "failed to render value of tag %q", k)
+		}
+
+		res[k] = buf.String()
+	}
+
+	if len(managedTagsValue(res)) > maxTagValueLength {
+		return nil, errors.Errorf("the keys of the additional tags exceed %d characters in total", maxTagValueLength)
+	}
+
+	return res, nil
+}
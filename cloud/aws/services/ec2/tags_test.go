@@ -0,0 +1,175 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/mock/gomock"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/mock_ec2iface"
+)
+
+func TestRenderTags(t *testing.T) {
+	testCases := []struct {
+		name     string
+		tags     map[string]string
+		expected map[string]string
+		err      bool
+	}{
+		{
+			name:     "plain values",
+			tags:     map[string]string{"cost-center": "platform"},
+			expected: map[string]string{"cost-center": "platform"},
+		},
+		{
+			name:     "templated values",
+			tags:     map[string]string{"Name": "{{ .ClusterName }}-{{ .MachineName }}"},
+			expected: map[string]string{"Name": "test-cluster-node-1"},
+		},
+		{
+			name: "unknown field",
+			tags: map[string]string{"Name": "{{ .Region }}"},
+			err:  true,
+		},
+		{
+			name: "reserved key",
+			tags: map[string]string{"kubernetes.io/cluster/other": "owned"},
+			err:  true,
+		},
+		{
+			name: "key with a comma",
+			tags: map[string]string{"a,b": "c"},
+			err:  true,
+		},
+		{
+			name: "invalid template",
+			tags: map[string]string{"Name": "{{ .ClusterName"},
+			err:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := RenderTags(tc.tags, TagTemplateData{ClusterName: "test-cluster", MachineName: "node-1"})
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error, got: %v", res)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("did not expect error: %v", err)
+			}
+
+			if !reflect.DeepEqual(res, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, res)
+			}
+		})
+	}
+}
+
+func TestReconcileTags(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	additionalTags := map[string]string{"cost-center": "platform", "owner": "team-a"}
+
+	testCases := []struct {
+		name           string
+		additionalTags map[string]string
+		current        map[string]string
+		expect         func(m *mock_ec2iface.MockEC2API)
+		expected       map[string]string
+	}{
+		{
+			name:           "all tags present",
+			additionalTags: additionalTags,
+			current:        map[string]string{"cost-center": "platform", "owner": "team-a", TagNameManagedTags: "cost-center,owner", "other": "x"},
+			expect:         func(m *mock_ec2iface.MockEC2API) {},
+			expected:       map[string]string{"cost-center": "platform", "owner": "team-a", TagNameManagedTags: "cost-center,owner", "other": "x"},
+		},
+		{
+			name:           "missing and changed tags",
+			additionalTags: additionalTags,
+			current:        map[string]string{"cost-center": "infra", "other": "x"},
+			expect: func(m *mock_ec2iface.MockEC2API) {
+				m.EXPECT().
+					CreateTagsWithContext(gomock.Any(), gomock.Eq(&ec2.CreateTagsInput{
+						Resources: aws.StringSlice([]string{"vpc-1"}),
+						Tags: []*ec2.Tag{
+							{Key: aws.String("cost-center"), Value: aws.String("platform")},
+							{Key: aws.String("owner"), Value: aws.String("team-a")},
+							{Key: aws.String(TagNameManagedTags), Value: aws.String("cost-center,owner")},
+						},
+					})).
+					Return(nil, nil)
+			},
+			expected: map[string]string{"cost-center": "platform", "owner": "team-a", TagNameManagedTags: "cost-center,owner", "other": "x"},
+		},
+		{
+			name:           "stale tags",
+			additionalTags: additionalTags,
+			current:        map[string]string{"cost-center": "platform", "owner": "team-a", "team": "a", TagNameManagedTags: "cost-center,owner,team", "other": "x"},
+			expect: func(m *mock_ec2iface.MockEC2API) {
+				m.EXPECT().
+					DeleteTagsWithContext(gomock.Any(), gomock.Eq(&ec2.DeleteTagsInput{
+						Resources: aws.StringSlice([]string{"vpc-1"}),
+						Tags:      []*ec2.Tag{{Key: aws.String("team")}},
+					})).
+					Return(nil, nil)
+				m.EXPECT().
+					CreateTagsWithContext(gomock.Any(), gomock.Eq(&ec2.CreateTagsInput{
+						Resources: aws.StringSlice([]string{"vpc-1"}),
+						Tags:      []*ec2.Tag{{Key: aws.String(TagNameManagedTags), Value: aws.String("cost-center,owner")}},
+					})).
+					Return(nil, nil)
+			},
+			expected: map[string]string{"cost-center": "platform", "owner": "team-a", TagNameManagedTags: "cost-center,owner", "other": "x"},
+		},
+		{
+			name:    "no additional tags anymore",
+			current: map[string]string{"cost-center": "platform", TagNameManagedTags: "cost-center", "other": "x"},
+			expect: func(m *mock_ec2iface.MockEC2API) {
+				m.EXPECT().
+					DeleteTagsWithContext(gomock.Any(), gomock.Eq(&ec2.DeleteTagsInput{
+						Resources: aws.StringSlice([]string{"vpc-1"}),
+						Tags:      []*ec2.Tag{{Key: aws.String("cost-center")}, {Key: aws.String(TagNameManagedTags)}},
+					})).
+					Return(nil, nil)
+			},
+			expected: map[string]string{"other": "x"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ec2Mock := mock_ec2iface.NewMockEC2API(mockCtrl)
+			tc.expect(ec2Mock)
+
+			s := NewService(ec2Mock).withAdditionalTags(tc.additionalTags)
+			res, err := s.reconcileTags("vpc-1", tc.current)
+			if err != nil {
+				t.Fatalf("did not expect error: %v", err)
+			}
+
+			if !reflect.DeepEqual(res, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, res)
+			}
+		})
+	}
+}
@@ -14,6 +14,8 @@
 package ec2
 
 import (
+	"regexp"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/pkg/errors"
@@ -23,8 +25,22 @@ const (
 	defaultRegion = "us-east-1"
 )
 
+// localZonePattern matches the names of Local Zones, which extend a region to another location,
+// e.g. us-west-2-lax-1a, unlike the availability zones of the region, e.g. us-west-2a.
+var localZonePattern = regexp.MustCompile(`^[a-z]{2}(-gov)?-[a-z]+-[0-9]+-[a-z0-9-]+$`)
+
+// isLocalZone returns true if the zone is a Local Zone. Local Zones don't support NAT gateways.
+func isLocalZone(zone string) bool {
+	return localZonePattern.MatchString(zone)
+}
+
 func (s *Service) getRegion() string {
-	switch x := s.EC2.(type) {
+	api := s.EC2
+	if c, ok := api.(*describeCache); ok {
+		api = c.EC2API
+	}
+
+	switch x := api.(type) {
 	case *ec2.EC2:
 		return *x.Config.Region
 	default:
@@ -33,7 +49,7 @@ func (s *Service) getRegion() string {
 }
 
 func (s *Service) getAvailableZones() ([]string, error) {
-	out, err := s.EC2.DescribeAvailabilityZones(&ec2.DescribeAvailabilityZonesInput{
+	out, err := s.EC2.DescribeAvailabilityZonesWithContext(s.ctx, &ec2.DescribeAvailabilityZonesInput{
 		Filters: []*ec2.Filter{
 			{
 				Name:   aws.String("state"),
@@ -46,10 +62,25 @@ func (s *Service) getAvailableZones() ([]string, error) {
 		return nil, errors.Wrap(err, "failed to describe availability zones")
 	}
 
+	// Local Zones the account opted in to are left out, the default subnets need a NAT gateway.
 	zones := make([]string, 0, len(out.AvailabilityZones))
+	denied := 0
 	for _, zone := range out.AvailabilityZones {
+		if isLocalZone(*zone.ZoneName) {
+			continue
+		}
+		if s.locations.CheckZone(*zone.ZoneName) != nil {
+			denied++
+			continue
+		}
 		zones = append(zones, *zone.ZoneName)
 	}
+	if len(zones) == 0 && denied > 0 {
+		return nil, NewInvalidConfiguration(errors.Errorf("none of the %d available availability zones is allowed", denied))
+	}
+	if len(zones) == 0 {
+		return nil, errors.New("no availability zones are available")
+	}
 
 	return zones, nil
 }
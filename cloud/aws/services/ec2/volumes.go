@@ -0,0 +1,119 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+)
+
+// ReconcileRootVolume grows the root volume of the instance to the given size in GiB, if it is
+// smaller, without replacing the instance. Volumes can't shrink, larger volumes are left as they are.
+// Only the volume is grown: its partition and file system are grown by the instance, e.g. by the
+// growpart and resizefs modules of cloud-init on the next boot.
+func (s *Service) ReconcileRootVolume(ctx context.Context, instanceID string, size int64) error {
+	s = s.withContext(ctx)
+	if size == 0 {
+		return nil
+	}
+
+	volume, err := s.describeRootVolume(instanceID)
+	if err != nil {
+		return err
+	}
+	if aws.Int64Value(volume.Size) >= size {
+		return nil
+	}
+
+	// A volume can only be modified once the previous modification is done, a modification in
+	// progress to the desired size is left to finish.
+	modification, err := s.describeVolumeModification(*volume.VolumeId)
+	if err != nil {
+		return err
+	}
+	if modification != nil && aws.StringValue(modification.ModificationState) == ec2.VolumeModificationStateModifying {
+		if aws.Int64Value(modification.TargetSize) >= size {
+			return nil
+		}
+		return NewNotReady(errors.Errorf("volume %q is still being modified", *volume.VolumeId))
+	}
+
+	if _, err := s.EC2.ModifyVolumeWithContext(s.ctx, &ec2.ModifyVolumeInput{
+		VolumeId: volume.VolumeId,
+		Size:     aws.Int64(size),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to modify volume %q", *volume.VolumeId)
+	}
+
+	s.log.V(2).Info("Growing root volume", "instance-id", instanceID, "volume-id", volume.VolumeId, "from", aws.Int64Value(volume.Size), "to", size)
+	return nil
+}
+
+// describeRootVolume returns the EBS volume attached as the root device of the instance.
+func (s *Service) describeRootVolume(instanceID string) (*ec2.Volume, error) {
+	out, err := s.EC2.DescribeInstancesWithContext(s.ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: aws.StringSlice([]string{instanceID}),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe instance %q", instanceID)
+	}
+	if len(out.Reservations) == 0 || len(out.Reservations[0].Instances) == 0 {
+		return nil, NewNotFound(errors.Errorf("instance %q not found", instanceID))
+	}
+
+	instance := out.Reservations[0].Instances[0]
+	var volumeID *string
+	for _, bdm := range instance.BlockDeviceMappings {
+		if aws.StringValue(bdm.DeviceName) == aws.StringValue(instance.RootDeviceName) && bdm.Ebs != nil {
+			volumeID = bdm.Ebs.VolumeId
+		}
+	}
+	if volumeID == nil {
+		return nil, NewNotFound(errors.Errorf("instance %q has no ebs root volume", instanceID))
+	}
+
+	volumes, err := s.EC2.DescribeVolumesWithContext(s.ctx, &ec2.DescribeVolumesInput{
+		VolumeIds: []*string{volumeID},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe volume %q", *volumeID)
+	}
+	if len(volumes.Volumes) == 0 {
+		return nil, NewNotFound(errors.Errorf("volume %q not found", *volumeID))
+	}
+	return volumes.Volumes[0], nil
+}
+
+// describeVolumeModification returns the latest modification of the volume, or nil if it was
+// never modified.
+func (s *Service) describeVolumeModification(volumeID string) (*ec2.VolumeModification, error) {
+	out, err := s.EC2.DescribeVolumesModificationsWithContext(s.ctx, &ec2.DescribeVolumesModificationsInput{
+		VolumeIds: aws.StringSlice([]string{volumeID}),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "InvalidVolumeModification.NotFound" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe modifications of volume %q", volumeID)
+	}
+
+	if len(out.VolumesModifications) == 0 {
+		return nil, nil
+	}
+	return out.VolumesModifications[0], nil
+}
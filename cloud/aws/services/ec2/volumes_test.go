@@ -0,0 +1,74 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/fake"
+	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
+)
+
+func TestReconcileRootVolume(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	machine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "worker-0"}}
+	config := &v1alpha1.AWSMachineProviderConfig{
+		AMI:            v1alpha1.AWSResourceReference{ID: aws.String("ami-1")},
+		RootDeviceSize: 20,
+	}
+	instance, err := s.CreateInstance(context.TODO(), "test-cluster", "", nil, machine, config)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+
+	rootVolumeSize := func() int64 {
+		volume, err := s.withContext(context.TODO()).describeRootVolume(instance.ID)
+		if err != nil {
+			t.Fatalf("failed to describe root volume: %v", err)
+		}
+		return aws.Int64Value(volume.Size)
+	}
+	if size := rootVolumeSize(); size != 20 {
+		t.Fatalf("expected a root volume of 20 GiB, got: %d", size)
+	}
+
+	steps := []struct {
+		name     string
+		size     int64
+		notReady bool
+		expected int64
+	}{
+		{name: "grown", size: 50, expected: 50},
+		{name: "unchanged", size: 50, expected: 50},
+		{name: "grown again while modifying", size: 100, notReady: true, expected: 50},
+		{name: "grown again once modified", size: 100, expected: 100},
+		{name: "not shrunk", size: 10, expected: 100},
+		{name: "no size", expected: 100},
+	}
+	for _, step := range steps {
+		err := s.ReconcileRootVolume(context.TODO(), instance.ID, step.size)
+		if step.notReady != IsNotReady(err) || (!step.notReady && err != nil) {
+			t.Fatalf("%s: unexpected error: %v", step.name, err)
+		}
+		if size := rootVolumeSize(); size != step.expected {
+			t.Fatalf("%s: expected a root volume of %d GiB, got: %d", step.name, step.expected, size)
+		}
+	}
+}
@@ -15,8 +15,8 @@ package ec2
 
 import (
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/golang/glog"
 	"github.com/pkg/errors"
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
 )
@@ -25,69 +25,245 @@ const (
 	defaultVpcCidr = "10.0.0.0/16"
 )
 
-func (s *Service) reconcileVPC(clusterName string, in *v1alpha1.VPC) error {
-	glog.V(2).Infof("Reconciling VPC")
+func (s *Service) reconcileVPC(clusterName string, spec *v1alpha1.NetworkSpec, in *v1alpha1.VPC) error {
+	s.log.V(2).Info("Reconciling VPC", "vpc-id", in.ID)
 
-	vpc, err := s.describeVPC(clusterName, in.ID)
-	if IsNotFound(err) {
+	id := in.ID
+	if id == "" {
+		id = spec.VPCID
+	}
+
+	vpc, err := s.describeVPC(clusterName, id)
+	if IsNotFound(err) && spec.VPCID != "" {
+		return errors.Wrapf(err, "failed to find existing vpc %q", spec.VPCID)
+	} else if IsNotFound(err) {
 		// Create a new vpc.
-		vpc, err = s.createVPC(clusterName, in)
+		vpc, err = s.createVPC(clusterName, spec, in)
 		if err != nil {
 			return err
 		}
 
 	} else if err != nil {
 		return err
+	} else if _, tagged := s.clusterLifecycle(clusterName, vpc.Tags); !tagged && s.createdUntagged(clusterName, in.Tags, vpc.Tags) {
+		// The vpc of the status was created for the cluster, but not tagged before the
+		// reconcile failed.
+		if err := s.createTags(clusterName, vpc.ID, ResourceLifecycleOwned, nil); err != nil {
+			return errors.Wrapf(err, "failed to tag vpc %q", vpc.ID)
+		}
+		vpc.Tags = s.buildTags(clusterName, ResourceLifecycleOwned, nil)
+		s.log.V(2).Info("Finished creating VPC", "vpc-id", vpc.ID)
+	} else if tagged || spec.VPCID != "" {
+		// An existing vpc given in the spec is shared with the clusters already using it.
+		if vpc.Tags, err = s.reconcileResourceTags(clusterName, vpc.ID, vpc.Tags); err != nil {
+			return errors.Wrapf(err, "failed to update tags of vpc %q", vpc.ID)
+		}
 	}
 
 	vpc.DeepCopyInto(in)
-	glog.V(2).Infof("Working on VPC %q", in.ID)
+	if in.State == ec2.VpcStatePending {
+		s.log.V(2).Info("VPC is not available yet", "vpc-id", in.ID)
+		return NewNotReady(errors.Errorf("vpc %q is not available yet", in.ID))
+	}
+
+	// Only the vpc of the cluster follows changes of the spec, shared ones are left as they are.
+	if lifecycle, _ := s.clusterLifecycle(clusterName, in.Tags); lifecycle == ResourceLifecycleOwned {
+		if err := s.reconcileVPCAttributes(spec, in); err != nil {
+			return err
+		}
+		if err := s.reconcileSecondaryCidrBlocks(spec, in); err != nil {
+			return err
+		}
+	}
+
+	s.log.V(2).Info("Working on VPC", "vpc-id", in.ID)
+	return nil
+}
+
+// reconcileVPCAttributes sets the DNS attributes of the vpc that are given in the spec.
+func (s *Service) reconcileVPCAttributes(spec *v1alpha1.NetworkSpec, vpc *v1alpha1.VPC) error {
+	attrs := []struct {
+		name    string
+		desired *bool
+		value   func(*ec2.DescribeVpcAttributeOutput) *ec2.AttributeBooleanValue
+		modify  func(*ec2.ModifyVpcAttributeInput, *ec2.AttributeBooleanValue)
+	}{
+		{
+			name:    ec2.VpcAttributeNameEnableDnsSupport,
+			desired: spec.EnableDNSSupport,
+			value:   func(out *ec2.DescribeVpcAttributeOutput) *ec2.AttributeBooleanValue { return out.EnableDnsSupport },
+			modify:  func(in *ec2.ModifyVpcAttributeInput, v *ec2.AttributeBooleanValue) { in.EnableDnsSupport = v },
+		},
+		{
+			name:    ec2.VpcAttributeNameEnableDnsHostnames,
+			desired: spec.EnableDNSHostnames,
+			value:   func(out *ec2.DescribeVpcAttributeOutput) *ec2.AttributeBooleanValue { return out.EnableDnsHostnames },
+			modify:  func(in *ec2.ModifyVpcAttributeInput, v *ec2.AttributeBooleanValue) { in.EnableDnsHostnames = v },
+		},
+	}
+
+	for _, attr := range attrs {
+		if attr.desired == nil {
+			continue
+		}
+
+		out, err := s.EC2.DescribeVpcAttributeWithContext(s.ctx, &ec2.DescribeVpcAttributeInput{
+			VpcId:     aws.String(vpc.ID),
+			Attribute: aws.String(attr.name),
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to describe attribute %q of vpc %q", attr.name, vpc.ID)
+		}
+		if v := attr.value(out); v != nil && aws.BoolValue(v.Value) == *attr.desired {
+			continue
+		}
+
+		// Attributes can only be modified one at a time.
+		in := &ec2.ModifyVpcAttributeInput{VpcId: aws.String(vpc.ID)}
+		attr.modify(in, &ec2.AttributeBooleanValue{Value: attr.desired})
+		if _, err := s.EC2.ModifyVpcAttributeWithContext(s.ctx, in); err != nil {
+			return errors.Wrapf(err, "failed to modify attribute %q of vpc %q", attr.name, vpc.ID)
+		}
+
+		s.log.V(2).Info("Modified VPC attribute", "vpc-id", vpc.ID, "attribute", attr.name, "value", *attr.desired)
+	}
+
 	return nil
 }
 
-func (s *Service) createVPC(clusterName string, v *v1alpha1.VPC) (*v1alpha1.VPC, error) {
+// reconcileSecondaryCidrBlocks associates the secondary CIDR blocks of the spec that the vpc
+// doesn't have yet. Blocks that are still being associated are reported as not ready, since
+// subnets can only be created in them afterwards.
+func (s *Service) reconcileSecondaryCidrBlocks(spec *v1alpha1.NetworkSpec, vpc *v1alpha1.VPC) error {
+	associated := map[string]bool{vpc.CidrBlock: true}
+	for _, cidr := range vpc.SecondaryCidrBlocks {
+		associated[cidr] = true
+	}
+
+	var missing []string
+	for _, cidr := range spec.SecondaryCIDRBlocks {
+		if !associated[cidr] {
+			missing = append(missing, cidr)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	out, err := s.EC2.DescribeVpcsWithContext(s.ctx, &ec2.DescribeVpcsInput{VpcIds: []*string{aws.String(vpc.ID)}})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe vpc %q", vpc.ID)
+	}
+	states := make(map[string]string)
+	for _, v := range out.Vpcs {
+		for _, as := range v.CidrBlockAssociationSet {
+			if as.CidrBlockState != nil {
+				states[aws.StringValue(as.CidrBlock)] = aws.StringValue(as.CidrBlockState.State)
+			}
+		}
+	}
+
+	var pending []string
+	for _, cidr := range missing {
+		if states[cidr] == ec2.VpcCidrBlockStateCodeAssociating {
+			pending = append(pending, cidr)
+			continue
+		}
+
+		out, err := s.EC2.AssociateVpcCidrBlockWithContext(s.ctx, &ec2.AssociateVpcCidrBlockInput{
+			VpcId:     aws.String(vpc.ID),
+			CidrBlock: aws.String(cidr),
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to associate cidr block %q with vpc %q", cidr, vpc.ID)
+		}
+
+		s.log.V(2).Info("Associated CIDR block with VPC", "vpc-id", vpc.ID, "cidr-block", cidr)
+		if as := out.CidrBlockAssociation; as != nil && as.CidrBlockState != nil &&
+			aws.StringValue(as.CidrBlockState.State) == ec2.VpcCidrBlockStateCodeAssociated {
+			vpc.SecondaryCidrBlocks = append(vpc.SecondaryCidrBlocks, cidr)
+			continue
+		}
+		pending = append(pending, cidr)
+	}
+
+	if len(pending) > 0 {
+		s.log.V(2).Info("CIDR blocks are not associated with the VPC yet", "vpc-id", vpc.ID, "cidr-blocks", pending)
+		return NewNotReady(errors.Errorf("cidr blocks %v are not associated with vpc %q yet", pending, vpc.ID))
+	}
+	return nil
+}
+
+func (s *Service) createVPC(clusterName string, spec *v1alpha1.NetworkSpec, v *v1alpha1.VPC) (*v1alpha1.VPC, error) {
 	if v.CidrBlock == "" {
-		v.CidrBlock = defaultVpcCidr
+		v.CidrBlock = s.defaultVPCCIDR
 	}
 
 	input := &ec2.CreateVpcInput{
 		CidrBlock: aws.String(v.CidrBlock),
 	}
+	if spec.EnableIPv6 {
+		input.AmazonProvidedIpv6CidrBlock = aws.Bool(true)
+	}
 
-	out, err := s.EC2.CreateVpc(input)
+	out, err := s.EC2.CreateVpcWithContext(s.ctx, input)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create vpc")
 	}
 
-	wReq := &ec2.DescribeVpcsInput{VpcIds: []*string{out.Vpc.VpcId}}
-	if err := s.EC2.WaitUntilVpcAvailable(wReq); err != nil {
+	// Subnets and gateways are created in the vpc right away.
+	err = s.waitUntilVisible(*out.Vpc.VpcId, func() error {
+		_, err := s.describeVPC(clusterName, *out.Vpc.VpcId)
+		return err
+	})
+	if err != nil {
+		// The vpc is recorded, so that the next reconcile finds it instead of creating another one.
+		v.ID = *out.Vpc.VpcId
+		v.Tags = s.buildTags(clusterName, ResourceLifecycleOwned, nil)
 		return nil, errors.Wrapf(err, "failed to wait for vpc %q", *out.Vpc.VpcId)
 	}
 
 	if err := s.createTags(clusterName, *out.Vpc.VpcId, ResourceLifecycleOwned, nil); err != nil {
+		// The vpc is recorded as owned, so that the next reconcile finishes it instead of
+		// creating another one.
+		v.ID = *out.Vpc.VpcId
+		v.Tags = s.buildTags(clusterName, ResourceLifecycleOwned, nil)
 		return nil, errors.Wrapf(err, "failed to tag vpc %q", *out.Vpc.VpcId)
 	}
 
-	glog.V(2).Infof("Created new VPC %q with cidr %q", *out.Vpc.VpcId, *out.Vpc.CidrBlock)
+	s.log.V(2).Info("Created new VPC", "vpc-id", out.Vpc.VpcId, "cidr-block", out.Vpc.CidrBlock)
 
 	return &v1alpha1.VPC{
-		ID:        *out.Vpc.VpcId,
-		CidrBlock: *out.Vpc.CidrBlock,
+		ID:            *out.Vpc.VpcId,
+		CidrBlock:     *out.Vpc.CidrBlock,
+		IPv6CidrBlock: ipv6CidrBlock(out.Vpc),
+		State:         aws.StringValue(out.Vpc.State),
+		Tags:          s.buildTags(clusterName, ResourceLifecycleOwned, nil),
 	}, nil
 }
 
-func (s *Service) deleteVPC(v *v1alpha1.VPC) error {
-	// TODO(johanneswuerbach): ensure that the VPC is owned by this cluster before deleting
-	input := &ec2.DeleteVpcInput{
-		VpcId: aws.String(v.ID),
-	}
+func (s *Service) deleteVPC(clusterName string, v *v1alpha1.VPC) error {
+	deleted, err := s.releaseResource(clusterName, v.ID, v.Tags, func() error {
+		// Egress-only internet gateways can't be tagged, they go away together with their vpc.
+		if err := s.deleteEgressOnlyInternetGateways(v); err != nil {
+			return err
+		}
 
-	_, err := s.EC2.DeleteVpc(input)
-	if err != nil {
+		input := &ec2.DeleteVpcInput{
+			VpcId: aws.String(v.ID),
+		}
+
+		_, err := s.EC2.DeleteVpcWithContext(s.ctx, input)
 		return errors.Wrapf(err, "failed to delete vpc %q", v.ID)
+	})
+
+	if err != nil {
+		return err
 	}
 
-	glog.V(2).Infof("Deleted VPC %q", v.ID)
+	if deleted {
+		s.log.V(2).Info("Deleted VPC", "vpc-id", v.ID)
+	}
 	return nil
 }
 
@@ -101,8 +277,10 @@ func (s *Service) describeVPC(clusterName string, id string) (*v1alpha1.VPC, err
 		input.VpcIds = []*string{aws.String(id)}
 	}
 
-	out, err := s.EC2.DescribeVpcs(input)
-	if err != nil {
+	out, err := s.EC2.DescribeVpcsWithContext(s.ctx, input)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "InvalidVpcID.NotFound" {
+		return nil, NewNotFound(errors.Wrapf(err, "could not find vpc %q", id))
+	} else if err != nil {
 		return nil, err
 	}
 
@@ -113,7 +291,37 @@ func (s *Service) describeVPC(clusterName string, id string) (*v1alpha1.VPC, err
 	}
 
 	return &v1alpha1.VPC{
-		ID:        *out.Vpcs[0].VpcId,
-		CidrBlock: *out.Vpcs[0].CidrBlock,
+		ID:                  *out.Vpcs[0].VpcId,
+		CidrBlock:           *out.Vpcs[0].CidrBlock,
+		IPv6CidrBlock:       ipv6CidrBlock(out.Vpcs[0]),
+		SecondaryCidrBlocks: secondaryCidrBlocks(out.Vpcs[0]),
+		State:               aws.StringValue(out.Vpcs[0].State),
+		Tags:                tagsToMap(out.Vpcs[0].Tags),
 	}, nil
 }
+
+// secondaryCidrBlocks returns the IPv4 CIDR blocks associated with the vpc besides its primary one.
+// Blocks that are still being associated are left out until subnets can be created in them.
+func secondaryCidrBlocks(vpc *ec2.Vpc) []string {
+	var res []string
+	for _, as := range vpc.CidrBlockAssociationSet {
+		if aws.StringValue(as.CidrBlock) == aws.StringValue(vpc.CidrBlock) {
+			continue
+		}
+		if as.CidrBlockState != nil && aws.StringValue(as.CidrBlockState.State) == ec2.VpcCidrBlockStateCodeAssociated {
+			res = append(res, aws.StringValue(as.CidrBlock))
+		}
+	}
+	return res
+}
+
+// ipv6CidrBlock returns the IPv6 CIDR block associated with the vpc, or an empty string.
+// Blocks that are still being associated are left out until they can be routed.
+func ipv6CidrBlock(vpc *ec2.Vpc) string {
+	for _, as := range vpc.Ipv6CidrBlockAssociationSet {
+		if as.Ipv6CidrBlockState != nil && aws.StringValue(as.Ipv6CidrBlockState.State) == ec2.VpcCidrBlockStateCodeAssociated {
+			return aws.StringValue(as.Ipv6CidrBlock)
+		}
+	}
+	return ""
+}
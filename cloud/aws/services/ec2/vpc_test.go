@@ -40,7 +40,7 @@ func TestReconcileVPC(t *testing.T) {
 			output: &v1alpha1.VPC{ID: "vpc-exists", CidrBlock: "10.0.0.0/8"},
 			expect: func(m *mock_ec2iface.MockEC2API) {
 				m.EXPECT().
-					DescribeVpcs(gomock.Eq(&ec2.DescribeVpcsInput{
+					DescribeVpcsWithContext(gomock.Any(), gomock.Eq(&ec2.DescribeVpcsInput{
 						VpcIds: []*string{
 							aws.String("vpc-exists"),
 						},
@@ -61,7 +61,7 @@ func TestReconcileVPC(t *testing.T) {
 			output: &v1alpha1.VPC{ID: "vpc-new", CidrBlock: "10.1.0.0/16"},
 			expect: func(m *mock_ec2iface.MockEC2API) {
 				m.EXPECT().
-					DescribeVpcs(gomock.Eq(&ec2.DescribeVpcsInput{
+					DescribeVpcsWithContext(gomock.Any(), gomock.Eq(&ec2.DescribeVpcsInput{
 						VpcIds: []*string{
 							aws.String("vpc-new"),
 						},
@@ -69,7 +69,7 @@ func TestReconcileVPC(t *testing.T) {
 					Return(&ec2.DescribeVpcsOutput{}, nil)
 
 				m.EXPECT().
-					CreateVpc(gomock.AssignableToTypeOf(&ec2.CreateVpcInput{})).
+					CreateVpcWithContext(gomock.Any(), gomock.AssignableToTypeOf(&ec2.CreateVpcInput{})).
 					Return(&ec2.CreateVpcOutput{
 						Vpc: &ec2.Vpc{
 							VpcId:     aws.String("vpc-new"),
@@ -77,14 +77,24 @@ func TestReconcileVPC(t *testing.T) {
 						},
 					}, nil)
 
+				// The vpc is described until it's visible.
 				m.EXPECT().
-					WaitUntilVpcAvailable(gomock.Eq(&ec2.DescribeVpcsInput{
-						VpcIds: []*string{aws.String("vpc-new")},
+					DescribeVpcsWithContext(gomock.Any(), gomock.Eq(&ec2.DescribeVpcsInput{
+						VpcIds: []*string{
+							aws.String("vpc-new"),
+						},
 					})).
-					Return(nil)
+					Return(&ec2.DescribeVpcsOutput{
+						Vpcs: []*ec2.Vpc{
+							{
+								VpcId:     aws.String("vpc-new"),
+								CidrBlock: aws.String("10.1.0.0/16"),
+							},
+						},
+					}, nil)
 
 				m.EXPECT().
-					CreateTags(gomock.Eq(&ec2.CreateTagsInput{
+					CreateTagsWithContext(gomock.Any(), gomock.Eq(&ec2.CreateTagsInput{
 						Resources: []*string{aws.String("vpc-new")},
 						Tags: []*ec2.Tag{&ec2.Tag{
 							Key:   aws.String("kubernetes.io/cluster/test-cluster"),
@@ -102,7 +112,7 @@ func TestReconcileVPC(t *testing.T) {
 			tc.expect(ec2Mock)
 
 			s := NewService(ec2Mock)
-			if err := s.reconcileVPC("test-cluster", tc.input); err != nil {
+			if err := s.reconcileVPC("test-cluster", &v1alpha1.NetworkSpec{}, tc.input); err != nil {
 				t.Fatalf("got an unexpected error: %v", err)
 			}
 
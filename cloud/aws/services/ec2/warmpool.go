@@ -0,0 +1,281 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
+)
+
+// tagNameLaunchTemplateVersion is the tag AWS adds to instances run from a launch template.
+const tagNameLaunchTemplateVersion = "aws:ec2launchtemplate:version"
+
+// ReconcileWarmPool brings the warm pool of the machine set of a machine to the size given in
+// the machine provider config. Missing instances are run from the launch template of the machine
+// set and stopped once they are running. Instances run from an outdated version of the launch
+// template and instances exceeding the size are terminated.
+func (s *Service) ReconcileWarmPool(ctx context.Context, clusterName string, machine *clusterv1.Machine, config *v1alpha1.AWSMachineProviderConfig) error {
+	s = s.withContext(ctx)
+
+	if machineSetName(machine) == "" {
+		return nil
+	}
+
+	name := launchTemplateName(clusterName, machine)
+	size := int(config.WarmPoolSize)
+
+	var lt *LaunchTemplate
+	if size > 0 {
+		data, err := s.machineLaunchTemplateData(config)
+		if err != nil {
+			return err
+		}
+
+		lt, err = s.reconcileLaunchTemplate(clusterName, name, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	instances, err := s.describeWarmPool(clusterName, name)
+	if err != nil {
+		return err
+	}
+
+	// Stopped instances are kept over ones that are still starting.
+	sort.SliceStable(instances, func(i, j int) bool {
+		return aws.StringValue(instances[i].State.Name) == ec2.InstanceStateNameStopped &&
+			aws.StringValue(instances[j].State.Name) != ec2.InstanceStateNameStopped
+	})
+
+	var kept int
+	var stop, terminate []*string
+	for _, i := range instances {
+		if lt == nil || kept >= size || instanceLaunchTemplateVersion(tagsToMap(i.Tags)) != lt.Version {
+			terminate = append(terminate, i.InstanceId)
+			continue
+		}
+
+		kept++
+		// Pending instances are stopped by a later reconcile, once they are running.
+		if aws.StringValue(i.State.Name) == ec2.InstanceStateNameRunning {
+			stop = append(stop, i.InstanceId)
+		}
+	}
+
+	if len(terminate) > 0 {
+		if _, err := s.EC2.TerminateInstancesWithContext(s.ctx, &ec2.TerminateInstancesInput{InstanceIds: terminate}); err != nil {
+			return errors.Wrapf(err, "failed to terminate instances of warm pool %q", name)
+		}
+		s.log.V(2).Info("Terminated instances of warm pool", "warm-pool", name, "instance-ids", aws.StringValueSlice(terminate))
+	}
+
+	if len(stop) > 0 {
+		if _, err := s.EC2.StopInstancesWithContext(s.ctx, &ec2.StopInstancesInput{InstanceIds: stop}); err != nil {
+			return errors.Wrapf(err, "failed to stop instances of warm pool %q", name)
+		}
+		s.log.V(2).Info("Stopped instances of warm pool", "warm-pool", name, "instance-ids", aws.StringValueSlice(stop))
+	}
+
+	if missing := size - kept; missing > 0 {
+		// Additional tags are rendered for a machine, instances get them once they are claimed.
+		poolTags := s.buildTags(clusterName, ResourceLifecycleOwned, nil)
+		poolTags[TagNameWarmPool] = name
+		tags := mapToTags(poolTags)
+
+		// The pool changes whenever instances are run, claimed or terminated, and so does the token.
+		ids := make([]string, 0, len(instances))
+		for _, i := range instances {
+			ids = append(ids, *i.InstanceId)
+		}
+		sort.Strings(ids)
+		parts := append([]string{name, "warm-pool", strconv.FormatInt(lt.Version, 10), strconv.Itoa(missing)}, ids...)
+
+		reservation, err := s.runInstances(&ec2.RunInstancesInput{
+			ClientToken:    aws.String(ClientToken(parts...)),
+			LaunchTemplate: lt.specification(),
+			MinCount:       aws.Int64(int64(missing)),
+			MaxCount:       aws.Int64(int64(missing)),
+			TagSpecifications: []*ec2.TagSpecification{
+				{ResourceType: aws.String(ec2.ResourceTypeInstance), Tags: tags},
+				{ResourceType: aws.String(ec2.ResourceTypeVolume), Tags: tags},
+			},
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to run instances for warm pool %q", name)
+		}
+
+		for _, i := range reservation.Instances {
+			s.log.V(2).Info("Created new instance for warm pool", "warm-pool", name, "instance-id", *i.InstanceId)
+		}
+	}
+
+	return nil
+}
+
+// claimWarmInstance starts a stopped instance of the warm pool of the launch template that was run
+// from its current version and hands it over to a machine, by removing it from the pool and tagging
+// it like a newly created instance. It returns nil if no such instance is available.
+func (s *Service) claimWarmInstance(clusterName string, lt *LaunchTemplate, additionalTags map[string]string) (*Instance, error) {
+	instances, err := s.describeWarmPool(clusterName, lt.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, i := range instances {
+		tags := tagsToMap(i.Tags)
+		if aws.StringValue(i.State.Name) != ec2.InstanceStateNameStopped || instanceLaunchTemplateVersion(tags) != lt.Version {
+			continue
+		}
+
+		// The instance leaves the pool first, so that it isn't replaced or claimed again.
+		if err := s.untagResource(*i.InstanceId, []string{TagNameWarmPool}); err != nil {
+			return nil, errors.Wrapf(err, "failed to remove instance %q from warm pool %q", *i.InstanceId, lt.Name)
+		}
+		delete(tags, TagNameWarmPool)
+
+		out, err := s.EC2.StartInstancesWithContext(s.ctx, &ec2.StartInstancesInput{InstanceIds: []*string{i.InstanceId}})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to start instance %q of warm pool %q", *i.InstanceId, lt.Name)
+		}
+
+		instanceTags := s.buildTags(clusterName, ResourceLifecycleOwned, additionalTags)
+		if err := s.tagResource(*i.InstanceId, instanceTags); err != nil {
+			return nil, errors.Wrapf(err, "failed to tag instance %q", *i.InstanceId)
+		}
+		for k, v := range instanceTags {
+			tags[k] = v
+		}
+
+		state := ec2.InstanceStateNamePending
+		if len(out.StartingInstances) > 0 && out.StartingInstances[0].CurrentState != nil {
+			state = aws.StringValue(out.StartingInstances[0].CurrentState.Name)
+		}
+
+		return &Instance{
+			State:          state,
+			ID:             *i.InstanceId,
+			Tags:           tags,
+			LaunchTemplate: lt,
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// DeleteWarmPools terminates the instances of all warm pools of the cluster.
+func (s *Service) DeleteWarmPools(ctx context.Context, clusterName string) error {
+	s = s.withContext(ctx)
+
+	instances, err := s.describeInstances([]*ec2.Filter{
+		{
+			Name:   aws.String(fmt.Sprintf("tag:%s", s.clusterTagKey(clusterName))),
+			Values: aws.StringSlice([]string{string(ResourceLifecycleOwned)}),
+		},
+		{
+			Name:   aws.String("tag-key"),
+			Values: aws.StringSlice([]string{TagNameWarmPool}),
+		},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe warm pools of cluster %q", clusterName)
+	}
+
+	if len(instances) == 0 {
+		return nil
+	}
+
+	ids := make([]*string, 0, len(instances))
+	for _, i := range instances {
+		ids = append(ids, i.InstanceId)
+	}
+
+	if _, err := s.EC2.TerminateInstancesWithContext(s.ctx, &ec2.TerminateInstancesInput{InstanceIds: ids}); err != nil {
+		return errors.Wrapf(err, "failed to terminate warm pool instances of cluster %q", clusterName)
+	}
+
+	s.log.V(2).Info("Terminated warm pool instances", "instance-ids", aws.StringValueSlice(ids))
+	return nil
+}
+
+// describeWarmPool returns the instances of the warm pool of a launch template that aren't going away.
+func (s *Service) describeWarmPool(clusterName string, name string) ([]*ec2.Instance, error) {
+	instances, err := s.describeInstances([]*ec2.Filter{
+		{
+			Name:   aws.String(fmt.Sprintf("tag:%s", s.clusterTagKey(clusterName))),
+			Values: aws.StringSlice([]string{string(ResourceLifecycleOwned)}),
+		},
+		{
+			Name:   aws.String(fmt.Sprintf("tag:%s", TagNameWarmPool)),
+			Values: aws.StringSlice([]string{name}),
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe warm pool %q", name)
+	}
+	return instances, nil
+}
+
+// describeInstances returns the instances matching the filters that are pending, running,
+// stopping or stopped.
+func (s *Service) describeInstances(filters []*ec2.Filter) ([]*ec2.Instance, error) {
+	input := &ec2.DescribeInstancesInput{
+		Filters: append(filters, &ec2.Filter{
+			Name: aws.String("instance-state-name"),
+			Values: aws.StringSlice([]string{
+				ec2.InstanceStateNamePending,
+				ec2.InstanceStateNameRunning,
+				ec2.InstanceStateNameStopping,
+				ec2.InstanceStateNameStopped,
+			}),
+		}),
+	}
+
+	var instances []*ec2.Instance
+	for {
+		out, err := s.EC2.DescribeInstancesWithContext(s.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range out.Reservations {
+			instances = append(instances, r.Instances...)
+		}
+
+		if aws.StringValue(out.NextToken) == "" {
+			break
+		}
+		input.NextToken = out.NextToken
+	}
+
+	return instances, nil
+}
+
+// instanceLaunchTemplateVersion returns the launch template version an instance was run from,
+// given its tags, or zero if it isn't known.
+func instanceLaunchTemplateVersion(tags map[string]string) int64 {
+	v, err := strconv.ParseInt(tags[tagNameLaunchTemplateVersion], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
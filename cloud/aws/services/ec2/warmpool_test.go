@@ -0,0 +1,128 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/fake"
+	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
+)
+
+// warmPoolStates returns the number of instances of the warm pool by state.
+func warmPoolStates(t *testing.T, s *Service, name string) map[string]int {
+	instances, err := s.describeWarmPool("test-cluster", name)
+	if err != nil {
+		t.Fatalf("failed to describe warm pool: %v", err)
+	}
+
+	states := make(map[string]int)
+	for _, i := range instances {
+		states[aws.StringValue(i.State.Name)]++
+	}
+	return states
+}
+
+func TestWarmPool(t *testing.T) {
+	f := fake.New()
+	s := NewService(f)
+
+	machine := func(name string) *clusterv1.Machine {
+		return &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			OwnerReferences: []metav1.OwnerReference{{Kind: "MachineSet", Name: "workers"}},
+		}}
+	}
+	config := &v1alpha1.AWSMachineProviderConfig{
+		AMI:          v1alpha1.AWSResourceReference{ID: aws.String("ami-1")},
+		WarmPoolSize: 2,
+	}
+	reconcile := func(config *v1alpha1.AWSMachineProviderConfig) {
+		if err := s.ReconcileWarmPool(context.TODO(), "test-cluster", machine("workers-a"), config); err != nil {
+			t.Fatalf("failed to reconcile warm pool: %v", err)
+		}
+	}
+
+	// Instances are run and stopped once they are running.
+	reconcile(config)
+	reconcile(config)
+	reconcile(config)
+	if states := warmPoolStates(t, s, "test-cluster-workers"); states[ec2.InstanceStateNameStopped] != 2 || len(states) != 1 {
+		t.Fatalf("expected two stopped instances, got: %v", states)
+	}
+
+	// A new machine starts an instance of the pool.
+	instance, err := s.CreateInstance(context.TODO(), "test-cluster", "", map[string]string{"owner": "team-a"}, machine("workers-b"), config)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	if instance.State != ec2.InstanceStateNamePending || instance.Tags["owner"] != "team-a" {
+		t.Fatalf("expected a started instance with the additional tags, got: %+v", instance)
+	}
+	if _, ok := instance.Tags[TagNameWarmPool]; ok {
+		t.Fatalf("expected the instance to leave the warm pool, got tags: %v", instance.Tags)
+	}
+	if states := warmPoolStates(t, s, "test-cluster-workers"); states[ec2.InstanceStateNameStopped] != 1 {
+		t.Fatalf("expected a single stopped instance left, got: %v", states)
+	}
+
+	// The claimed instance is replaced.
+	reconcile(config)
+	if states := warmPoolStates(t, s, "test-cluster-workers"); states[ec2.InstanceStateNameStopped] != 1 || states[ec2.InstanceStateNamePending]+states[ec2.InstanceStateNameRunning] != 1 {
+		t.Fatalf("expected a stopped and a new instance, got: %v", states)
+	}
+
+	// Instances of an outdated launch template version are replaced.
+	changed := config.DeepCopy()
+	changed.AMI.ID = aws.String("ami-2")
+	reconcile(changed)
+	instances, err := s.describeWarmPool("test-cluster", "test-cluster-workers")
+	if err != nil {
+		t.Fatalf("failed to describe warm pool: %v", err)
+	}
+	if len(instances) != 2 {
+		t.Fatalf("expected two instances, got: %v", instances)
+	}
+	for _, i := range instances {
+		if aws.StringValue(i.ImageId) != "ami-2" {
+			t.Fatalf("expected instances of the new launch template version, got: %v", i)
+		}
+	}
+
+	// A size of zero empties the warm pool.
+	none := changed.DeepCopy()
+	none.WarmPoolSize = 0
+	reconcile(none)
+	if states := warmPoolStates(t, s, "test-cluster-workers"); len(states) != 0 {
+		t.Fatalf("expected the warm pool to be empty, got: %v", states)
+	}
+
+	reconcile(changed)
+	if err := s.DeleteWarmPools(context.TODO(), "test-cluster"); err != nil {
+		t.Fatalf("failed to delete warm pools: %v", err)
+	}
+	if states := warmPoolStates(t, s, "test-cluster-workers"); len(states) != 0 {
+		t.Fatalf("expected the warm pool to be deleted, got: %v", states)
+	}
+
+	// The claimed instance belongs to its machine.
+	if i, err := s.InstanceIfExists(context.TODO(), &instance.ID); err != nil || i.State != ec2.InstanceStateNameRunning {
+		t.Fatalf("expected the claimed instance to keep running, got: %+v, %v", i, err)
+	}
+}
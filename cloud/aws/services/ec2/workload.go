@@ -0,0 +1,214 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+)
+
+// DeleteWorkloadResources deletes the resources created from within the cluster, like by the
+// Kubernetes AWS cloud provider or the EBS CSI driver, that would otherwise be left behind:
+// the detached volumes tagged for the cluster, including the ones of retained persistent
+// volumes, and the detached network interfaces tagged for it in its vpc. The ingress rules
+// referencing the security groups of the cluster, like the ones the cloud provider adds for load
+// balancers, are revoked so that the groups can be deleted with the network.
+// A not ready error is returned while volumes are still attached to instances that are going away.
+func (s *Service) DeleteWorkloadResources(ctx context.Context, clusterName string, network *v1alpha1.Network) error {
+	s = s.withContext(ctx).withValues("cluster", clusterName)
+
+	if err := s.deleteWorkloadVolumes(clusterName); err != nil {
+		return err
+	}
+
+	if network.VPC.ID == "" {
+		return nil
+	}
+	vpc, err := s.describeVPC(clusterName, network.VPC.ID)
+	if IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if err := s.revokeSecurityGroupReferences(clusterName, vpc); err != nil {
+		return err
+	}
+	return s.deleteNetworkInterfaces(clusterName, vpc)
+}
+
+// deleteWorkloadVolumes deletes the detached volumes tagged for the cluster.
+func (s *Service) deleteWorkloadVolumes(clusterName string) error {
+	var volumes []*ec2.Volume
+	err := s.EC2.DescribeVolumesPagesWithContext(s.ctx, &ec2.DescribeVolumesInput{
+		Filters: s.addTagFilters(clusterName, nil),
+	}, func(page *ec2.DescribeVolumesOutput, lastPage bool) bool {
+		volumes = append(volumes, page.Volumes...)
+		return true
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to describe volumes")
+	}
+
+	attached := 0
+	for _, v := range volumes {
+		switch aws.StringValue(v.State) {
+		case ec2.VolumeStateAvailable:
+		case ec2.VolumeStateDeleting, ec2.VolumeStateDeleted:
+			continue
+		default:
+			attached++
+			continue
+		}
+
+		deleted, err := s.releaseResource(clusterName, *v.VolumeId, tagsToMap(v.Tags), func() error {
+			_, err := s.EC2.DeleteVolumeWithContext(s.ctx, &ec2.DeleteVolumeInput{VolumeId: v.VolumeId})
+			return errors.Wrapf(err, "failed to delete volume %q", *v.VolumeId)
+		})
+		if err != nil {
+			return err
+		}
+
+		if deleted {
+			s.log.V(2).Info("Deleted volume", "volume-id", v.VolumeId)
+		}
+	}
+
+	if attached > 0 {
+		return NewNotReady(errors.Errorf("%d volumes of the cluster are still attached", attached))
+	}
+	return nil
+}
+
+// revokeSecurityGroupReferences revokes the ingress rules of the security groups in the vpc that
+// allow traffic from the security groups owned by the cluster.
+func (s *Service) revokeSecurityGroupReferences(clusterName string, vpc *v1alpha1.VPC) error {
+	vpcFilter := &ec2.Filter{
+		Name:   aws.String("vpc-id"),
+		Values: aws.StringSlice([]string{vpc.ID}),
+	}
+
+	out, err := s.EC2.DescribeSecurityGroupsWithContext(s.ctx, &ec2.DescribeSecurityGroupsInput{
+		Filters: s.addTagFilters(clusterName, []*ec2.Filter{vpcFilter}),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe security groups in vpc %q", vpc.ID)
+	}
+
+	owned := make(map[string]bool)
+	var ids []string
+	for _, sg := range out.SecurityGroups {
+		if lifecycle, _ := s.clusterLifecycle(clusterName, tagsToMap(sg.Tags)); lifecycle == ResourceLifecycleOwned {
+			owned[*sg.GroupId] = true
+			ids = append(ids, *sg.GroupId)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	out, err = s.EC2.DescribeSecurityGroupsWithContext(s.ctx, &ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{
+			vpcFilter,
+			{
+				Name:   aws.String("ip-permission.group-id"),
+				Values: aws.StringSlice(ids),
+			},
+		},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe security groups referencing the security groups of the cluster in vpc %q", vpc.ID)
+	}
+
+	for _, sg := range out.SecurityGroups {
+		var revoke []*ec2.IpPermission
+		for _, perm := range sg.IpPermissions {
+			var pairs []*ec2.UserIdGroupPair
+			for _, pair := range perm.UserIdGroupPairs {
+				if owned[aws.StringValue(pair.GroupId)] {
+					pairs = append(pairs, &ec2.UserIdGroupPair{GroupId: pair.GroupId})
+				}
+			}
+			if len(pairs) > 0 {
+				revoke = append(revoke, &ec2.IpPermission{
+					IpProtocol:       perm.IpProtocol,
+					FromPort:         perm.FromPort,
+					ToPort:           perm.ToPort,
+					UserIdGroupPairs: pairs,
+				})
+			}
+		}
+		if len(revoke) == 0 {
+			continue
+		}
+
+		if _, err := s.EC2.RevokeSecurityGroupIngressWithContext(s.ctx, &ec2.RevokeSecurityGroupIngressInput{
+			GroupId:       sg.GroupId,
+			IpPermissions: revoke,
+		}); err != nil {
+			return errors.Wrapf(err, "failed to revoke ingress from the security groups of the cluster to security group %q", *sg.GroupId)
+		}
+
+		s.log.V(2).Info("Revoked ingress from the security groups of the cluster", "security-group-id", sg.GroupId)
+	}
+	return nil
+}
+
+// deleteNetworkInterfaces deletes the detached network interfaces in the vpc that are tagged for
+// the cluster. Network interfaces managed by AWS services, like the ones of load balancers, go
+// away with their service.
+func (s *Service) deleteNetworkInterfaces(clusterName string, vpc *v1alpha1.VPC) error {
+	var enis []*ec2.NetworkInterface
+	err := s.EC2.DescribeNetworkInterfacesPagesWithContext(s.ctx, &ec2.DescribeNetworkInterfacesInput{
+		Filters: s.addTagFilters(clusterName, []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: aws.StringSlice([]string{vpc.ID}),
+			},
+			{
+				Name:   aws.String("status"),
+				Values: aws.StringSlice([]string{ec2.NetworkInterfaceStatusAvailable}),
+			},
+		}),
+	}, func(page *ec2.DescribeNetworkInterfacesOutput, lastPage bool) bool {
+		enis = append(enis, page.NetworkInterfaces...)
+		return true
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe network interfaces in vpc %q", vpc.ID)
+	}
+
+	for _, eni := range enis {
+		if aws.BoolValue(eni.RequesterManaged) {
+			continue
+		}
+
+		deleted, err := s.releaseResource(clusterName, *eni.NetworkInterfaceId, tagsToMap(eni.TagSet), func() error {
+			_, err := s.EC2.DeleteNetworkInterfaceWithContext(s.ctx, &ec2.DeleteNetworkInterfaceInput{NetworkInterfaceId: eni.NetworkInterfaceId})
+			return errors.Wrapf(err, "failed to delete network interface %q", *eni.NetworkInterfaceId)
+		})
+		if err != nil {
+			return err
+		}
+
+		if deleted {
+			s.log.V(2).Info("Deleted network interface", "network-interface-id", eni.NetworkInterfaceId, "vpc-id", vpc.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/mock/gomock"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2/mock_ec2iface"
+)
+
+func TestDeleteWorkloadResources(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	owned := []*ec2.Tag{{Key: aws.String("kubernetes.io/cluster/test-cluster"), Value: aws.String("owned")}}
+	shared := []*ec2.Tag{{Key: aws.String("kubernetes.io/cluster/test-cluster"), Value: aws.String("shared")}}
+
+	m := mock_ec2iface.NewMockEC2API(mockCtrl)
+
+	m.EXPECT().
+		DescribeVolumesPagesWithContext(gomock.Any(), gomock.Eq(&ec2.DescribeVolumesInput{
+			Filters: []*ec2.Filter{{Name: aws.String("tag-key"), Values: aws.StringSlice([]string{"kubernetes.io/cluster/test-cluster"})}},
+		}), gomock.Any()).
+		Do(func(_, _, y interface{}) {
+			y.(func(*ec2.DescribeVolumesOutput, bool) bool)(&ec2.DescribeVolumesOutput{Volumes: []*ec2.Volume{
+				{VolumeId: aws.String("vol-pv"), State: aws.String("available"), Tags: owned},
+				{VolumeId: aws.String("vol-deleting"), State: aws.String("deleting"), Tags: owned},
+			}}, true)
+		}).
+		Return(nil)
+	m.EXPECT().
+		DeleteVolumeWithContext(gomock.Any(), gomock.Eq(&ec2.DeleteVolumeInput{VolumeId: aws.String("vol-pv")})).
+		Return(&ec2.DeleteVolumeOutput{}, nil)
+
+	m.EXPECT().
+		DescribeVpcsWithContext(gomock.Any(), gomock.Eq(&ec2.DescribeVpcsInput{VpcIds: aws.StringSlice([]string{"vpc-1"})})).
+		Return(&ec2.DescribeVpcsOutput{Vpcs: []*ec2.Vpc{{VpcId: aws.String("vpc-1"), CidrBlock: aws.String("10.0.0.0/16"), Tags: owned}}}, nil)
+
+	// The node security group allows traffic from the security group of a service load balancer.
+	m.EXPECT().
+		DescribeSecurityGroupsWithContext(gomock.Any(), gomock.Eq(&ec2.DescribeSecurityGroupsInput{
+			Filters: []*ec2.Filter{
+				{Name: aws.String("vpc-id"), Values: aws.StringSlice([]string{"vpc-1"})},
+				{Name: aws.String("tag-key"), Values: aws.StringSlice([]string{"kubernetes.io/cluster/test-cluster"})},
+			},
+		})).
+		Return(&ec2.DescribeSecurityGroupsOutput{SecurityGroups: []*ec2.SecurityGroup{
+			{GroupId: aws.String("sg-elb"), Tags: owned},
+			{GroupId: aws.String("sg-nodes"), Tags: shared},
+		}}, nil)
+	m.EXPECT().
+		DescribeSecurityGroupsWithContext(gomock.Any(), gomock.Eq(&ec2.DescribeSecurityGroupsInput{
+			Filters: []*ec2.Filter{
+				{Name: aws.String("vpc-id"), Values: aws.StringSlice([]string{"vpc-1"})},
+				{Name: aws.String("ip-permission.group-id"), Values: aws.StringSlice([]string{"sg-elb"})},
+			},
+		})).
+		Return(&ec2.DescribeSecurityGroupsOutput{SecurityGroups: []*ec2.SecurityGroup{{
+			GroupId: aws.String("sg-nodes"),
+			IpPermissions: []*ec2.IpPermission{
+				{IpProtocol: aws.String("tcp"), FromPort: aws.Int64(22), ToPort: aws.Int64(22), IpRanges: []*ec2.IpRange{{CidrIp: aws.String("10.0.0.0/16")}}},
+				{IpProtocol: aws.String("-1"), UserIdGroupPairs: []*ec2.UserIdGroupPair{{GroupId: aws.String("sg-elb")}, {GroupId: aws.String("sg-nodes")}}},
+			},
+		}}}, nil)
+	m.EXPECT().
+		RevokeSecurityGroupIngressWithContext(gomock.Any(), gomock.Eq(&ec2.RevokeSecurityGroupIngressInput{
+			GroupId:       aws.String("sg-nodes"),
+			IpPermissions: []*ec2.IpPermission{{IpProtocol: aws.String("-1"), UserIdGroupPairs: []*ec2.UserIdGroupPair{{GroupId: aws.String("sg-elb")}}}},
+		})).
+		Return(&ec2.RevokeSecurityGroupIngressOutput{}, nil)
+
+	m.EXPECT().
+		DescribeNetworkInterfacesPagesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).
+		Do(func(_, _, y interface{}) {
+			y.(func(*ec2.DescribeNetworkInterfacesOutput, bool) bool)(&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []*ec2.NetworkInterface{
+				{NetworkInterfaceId: aws.String("eni-leaked"), TagSet: owned},
+				{NetworkInterfaceId: aws.String("eni-elb"), TagSet: owned, RequesterManaged: aws.Bool(true)},
+			}}, true)
+		}).
+		Return(nil)
+	m.EXPECT().
+		DeleteNetworkInterfaceWithContext(gomock.Any(), gomock.Eq(&ec2.DeleteNetworkInterfaceInput{NetworkInterfaceId: aws.String("eni-leaked")})).
+		Return(&ec2.DeleteNetworkInterfaceOutput{}, nil)
+
+	s := NewService(m)
+	if err := s.DeleteWorkloadResources(context.TODO(), "test-cluster", &v1alpha1.Network{VPC: v1alpha1.VPC{ID: "vpc-1"}}); err != nil {
+		t.Fatalf("failed to delete workload resources: %v", err)
+	}
+}
+
+func TestDeleteWorkloadResourcesAttachedVolume(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	m := mock_ec2iface.NewMockEC2API(mockCtrl)
+	m.EXPECT().
+		DescribeVolumesPagesWithContext(gomock.Any(), gomock.Any(), gomock.Any()).
+		Do(func(_, _, y interface{}) {
+			y.(func(*ec2.DescribeVolumesOutput, bool) bool)(&ec2.DescribeVolumesOutput{Volumes: []*ec2.Volume{
+				{VolumeId: aws.String("vol-pv"), State: aws.String("in-use")},
+			}}, true)
+		}).
+		Return(nil)
+	m.EXPECT().DeleteVolumeWithContext(gomock.Any(), gomock.Any()).Times(0)
+
+	s := NewService(m)
+	if err := s.DeleteWorkloadResources(context.TODO(), "test-cluster", &v1alpha1.Network{}); !IsNotReady(err) {
+		t.Fatalf("expected a not ready error while volumes are attached, got: %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ec2
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+)
+
+// isRemovedZone returns whether the zone is removed from the network.
+func isRemovedZone(spec *v1alpha1.NetworkSpec, zone string) bool {
+	for _, z := range spec.RemovedAvailabilityZones {
+		if z == zone {
+			return true
+		}
+	}
+	return false
+}
+
+// removeAvailabilityZones takes the network out of the removed zones. The subnets of the zones are
+// deleted once no instances are left in them, together with their NAT gateways and route tables.
+// Resources shared with other clusters are released instead.
+func (s *Service) removeAvailabilityZones(clusterName string, spec *v1alpha1.NetworkSpec, network *v1alpha1.Network) error {
+	if len(spec.RemovedAvailabilityZones) == 0 {
+		return nil
+	}
+
+	var keep, removed v1alpha1.Subnets
+	removedIDs := make(map[string]bool)
+	for _, sn := range network.Subnets {
+		if !isRemovedZone(spec, sn.AvailabilityZone) {
+			keep = append(keep, sn)
+		} else if sn.ID != "" {
+			removed = append(removed, sn)
+			removedIDs[sn.ID] = true
+		}
+	}
+
+	if len(removed) == 0 {
+		network.Subnets = keep
+		return nil
+	}
+
+	s.log.V(2).Info("Removing availability zones", "zones", spec.RemovedAvailabilityZones, "subnets", removed)
+
+	if len(keep.FilterPrivate()) == 0 || (len(keep.FilterPublic()) == 0 && !spec.Isolated) {
+		return errors.Errorf("failed to remove availability zones %v: the network needs subnets in other zones", spec.RemovedAvailabilityZones)
+	}
+
+	// Subnets can only be deleted once the instances in them are gone.
+	instances, err := s.describeSubnetInstances(removed)
+	if err != nil {
+		return err
+	}
+	if len(instances) > 0 {
+		s.log.V(2).Info("Waiting for instances to leave removed availability zones", "instance-ids", instances)
+		return NewNotReady(errors.Errorf("instances %v are still running in availability zones %v", instances, spec.RemovedAvailabilityZones))
+	}
+
+	ngs, err := s.describeVpcNatGateways(clusterName, &network.VPC)
+	if err != nil {
+		return err
+	}
+
+	gateways := make(map[string]bool)
+	var removedGateways []*ec2.NatGateway
+	var allocationIDs []string
+	for _, ng := range ngs {
+		if !removedIDs[aws.StringValue(ng.SubnetId)] {
+			continue
+		}
+
+		gateways[*ng.NatGatewayId] = true
+		removedGateways = append(removedGateways, ng)
+		for _, addr := range ng.NatGatewayAddresses {
+			allocationIDs = append(allocationIDs, aws.StringValue(addr.AllocationId))
+		}
+	}
+
+	if err := s.removeZoneRouteTables(clusterName, network, keep, removedIDs, gateways); err != nil {
+		return err
+	}
+
+	// Like on deletion of the network, the subnets and addresses are only released after the
+	// NAT gateways are gone.
+	if err := s.deleteGateways(clusterName, removedGateways); err != nil {
+		return err
+	}
+
+	if len(allocationIDs) > 0 {
+		if err := s.releaseMatchingAddresses(clusterName, []*ec2.Filter{
+			{
+				Name:   aws.String("allocation-id"),
+				Values: aws.StringSlice(allocationIDs),
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
+	err = s.parallelize(len(removed), func(i int) error {
+		_, err := s.releaseResource(clusterName, removed[i].ID, removed[i].Tags, func() error {
+			return s.deleteSubnet(removed[i])
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	network.Subnets = keep
+	s.log.V(2).Info("Removed availability zones", "zones", spec.RemovedAvailabilityZones)
+	return nil
+}
+
+// describeSubnetInstances returns the ids of the instances in the subnets that aren't terminated.
+func (s *Service) describeSubnetInstances(subnets v1alpha1.Subnets) ([]string, error) {
+	ids := make([]*string, 0, len(subnets))
+	for _, sn := range subnets {
+		ids = append(ids, aws.String(sn.ID))
+	}
+
+	out, err := s.EC2.DescribeInstancesWithContext(s.ctx, &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("subnet-id"),
+				Values: ids,
+			},
+			{
+				Name: aws.String("instance-state-name"),
+				Values: aws.StringSlice([]string{
+					ec2.InstanceStateNamePending,
+					ec2.InstanceStateNameRunning,
+					ec2.InstanceStateNameShuttingDown,
+					ec2.InstanceStateNameStopping,
+					ec2.InstanceStateNameStopped,
+				}),
+			},
+		},
+	})
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe instances in subnets")
+	}
+
+	var res []string
+	for _, r := range out.Reservations {
+		for _, i := range r.Instances {
+			res = append(res, aws.StringValue(i.InstanceId))
+		}
+	}
+	return res, nil
+}
+
+// removeZoneRouteTables routes the remaining subnets around the removed NAT gateways, and
+// disassociates the route tables of the removed subnets. Route tables without other subnets
+// are deleted.
+func (s *Service) removeZoneRouteTables(clusterName string, network *v1alpha1.Network, keep v1alpha1.Subnets, removedIDs map[string]bool, gateways map[string]bool) error {
+	subnetRouteMap, err := s.describeVpcRouteTablesBySubnet(clusterName, &network.VPC)
+	if err != nil {
+		return err
+	}
+
+	rerouted := make(map[string]bool)
+	for _, sn := range keep.FilterPrivate() {
+		rt, ok := subnetRouteMap[sn.ID]
+		if !ok || rerouted[*rt.RouteTableId] || sn.SharedRouteTableID != nil {
+			continue
+		}
+		rerouted[*rt.RouteTableId] = true
+
+		for _, route := range rt.Routes {
+			if !gateways[aws.StringValue(route.NatGatewayId)] {
+				continue
+			}
+
+			natGatewayID, err := s.getNatGatewayForSubnet(keep, sn)
+			if err != nil {
+				return err
+			}
+
+			if _, err := s.EC2.ReplaceRouteWithContext(s.ctx, &ec2.ReplaceRouteInput{
+				RouteTableId:         rt.RouteTableId,
+				DestinationCidrBlock: route.DestinationCidrBlock,
+				NatGatewayId:         aws.String(natGatewayID),
+			}); err != nil {
+				return errors.Wrapf(err, "failed to replace route to nat gateway %q in route table %q", *route.NatGatewayId, *rt.RouteTableId)
+			}
+
+			s.log.V(2).Info("Replaced route to removed NAT gateway", "route-table-id", rt.RouteTableId,
+				"old-nat-gateway-id", route.NatGatewayId, "nat-gateway-id", natGatewayID)
+		}
+	}
+
+	rts, err := s.describeVpcRouteTables(clusterName, &network.VPC)
+	if err != nil {
+		return err
+	}
+
+	for _, rt := range rts {
+		var associations []*ec2.RouteTableAssociation
+		others := 0
+		for _, as := range rt.Associations {
+			switch {
+			case aws.BoolValue(as.Main):
+			case removedIDs[aws.StringValue(as.SubnetId)]:
+				associations = append(associations, as)
+			default:
+				others++
+			}
+		}
+
+		if len(associations) == 0 {
+			continue
+		}
+
+		// Tables shared with subnets of the remaining zones stay.
+		if others > 0 {
+			if err := s.disassociateRouteTable(rt, associations); err != nil {
+				return err
+			}
+			continue
+		}
+
+		deleted, err := s.releaseResource(clusterName, *rt.RouteTableId, tagsToMap(rt.Tags), func() error {
+			if err := s.disassociateRouteTable(rt, associations); err != nil {
+				return err
+			}
+
+			_, err := s.EC2.DeleteRouteTableWithContext(s.ctx, &ec2.DeleteRouteTableInput{RouteTableId: rt.RouteTableId})
+			return errors.Wrapf(err, "failed to delete route table %q", *rt.RouteTableId)
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if deleted {
+			s.log.V(2).Info("Deleted route table", "route-table-id", rt.RouteTableId)
+		}
+	}
+
+	return nil
+}
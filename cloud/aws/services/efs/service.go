@@ -0,0 +1,285 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package efs manages an EFS file system per cluster, which is mounted through a mount target
+// in each zone of the private subnets of the cluster.
+package efs
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/efs"
+	"github.com/aws/aws-sdk-go/service/efs/efsiface"
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/logger"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+)
+
+// Service manages the file systems of clusters.
+type Service struct {
+	EFS efsiface.EFSAPI
+
+	log logr.Logger
+}
+
+// NewService returns a new service given the efs api client.
+func NewService(api efsiface.EFSAPI) *Service {
+	return &Service{
+		EFS: api,
+		log: logger.Default(),
+	}
+}
+
+// WithLogger returns a copy of the service that logs to the given logger.
+func (s *Service) WithLogger(log logr.Logger) *Service {
+	c := *s
+	c.log = log
+	return &c
+}
+
+// creationToken returns the creation token of the file system of the cluster, which it's found by.
+func creationToken(clusterName string) string {
+	return ec2svc.ClientToken(clusterName, "file-system")
+}
+
+// ReconcileFileSystem creates the file system of the cluster if it doesn't exist, and a mount
+// target with the given security group in each zone of the private subnets of the network.
+// The id of the file system is recorded in the status, a not ready error is returned while the
+// file system or its mount targets are still being created.
+func (s *Service) ReconcileFileSystem(ctx context.Context, clusterName string, spec *v1alpha1.FileSystemSpec, additionalTags map[string]string, network *v1alpha1.Network, securityGroupID string, status *v1alpha1.FileSystem) error {
+	fs, err := s.describeFileSystem(ctx, clusterName)
+	if err != nil {
+		return err
+	}
+	if fs == nil {
+		if fs, err = s.createFileSystem(ctx, clusterName, spec); err != nil {
+			return err
+		}
+	}
+
+	status.ID = *fs.FileSystemId
+	status.SecurityGroupID = securityGroupID
+
+	if err := s.reconcileTags(ctx, clusterName, *fs.FileSystemId, additionalTags); err != nil {
+		return err
+	}
+
+	switch aws.StringValue(fs.LifeCycleState) {
+	case efs.LifeCycleStateAvailable, efs.LifeCycleStateUpdating:
+	case efs.LifeCycleStateCreating:
+		return ec2svc.NewNotReady(errors.Errorf("file system %q is still being created", *fs.FileSystemId))
+	default:
+		return errors.Errorf("file system %q is %s", *fs.FileSystemId, aws.StringValue(fs.LifeCycleState))
+	}
+
+	return s.reconcileMountTargets(ctx, *fs.FileSystemId, network, securityGroupID)
+}
+
+func (s *Service) createFileSystem(ctx context.Context, clusterName string, spec *v1alpha1.FileSystemSpec) (*efs.FileSystemDescription, error) {
+	mode := spec.PerformanceMode
+	if mode == "" {
+		mode = efs.PerformanceModeGeneralPurpose
+	}
+
+	fs, err := s.EFS.CreateFileSystemWithContext(ctx, &efs.CreateFileSystemInput{
+		CreationToken:   aws.String(creationToken(clusterName)),
+		PerformanceMode: aws.String(mode),
+		Encrypted:       aws.Bool(spec.Encrypted),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create file system of cluster %q", clusterName)
+	}
+
+	s.log.V(2).Info("Created file system", "cluster", clusterName, "file-system-id", fs.FileSystemId)
+	return fs, nil
+}
+
+// describeFileSystem returns the file system of the cluster, or nil if it doesn't exist.
+func (s *Service) describeFileSystem(ctx context.Context, clusterName string) (*efs.FileSystemDescription, error) {
+	out, err := s.EFS.DescribeFileSystemsWithContext(ctx, &efs.DescribeFileSystemsInput{
+		CreationToken: aws.String(creationToken(clusterName)),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe file system of cluster %q", clusterName)
+	}
+
+	if len(out.FileSystems) == 0 {
+		return nil, nil
+	}
+	return out.FileSystems[0], nil
+}
+
+// reconcileTags sets the cluster tag, the name tag and the additional tags on the file system.
+// Tags that are no longer desired are left in place.
+func (s *Service) reconcileTags(ctx context.Context, clusterName string, id string, additionalTags map[string]string) error {
+	desired := map[string]string{
+		ec2svc.TagNameKubernetesClusterPrefix + clusterName: ec2svc.ResourceLifecycleOwned,
+		"Name": clusterName,
+	}
+	for k, v := range additionalTags {
+		desired[k] = v
+	}
+
+	out, err := s.EFS.DescribeTagsWithContext(ctx, &efs.DescribeTagsInput{FileSystemId: aws.String(id)})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe tags of file system %q", id)
+	}
+	for _, tag := range out.Tags {
+		if v, ok := desired[aws.StringValue(tag.Key)]; ok && v == aws.StringValue(tag.Value) {
+			delete(desired, aws.StringValue(tag.Key))
+		}
+	}
+	if len(desired) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(desired))
+	for k := range desired {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tags := make([]*efs.Tag, 0, len(keys))
+	for _, k := range keys {
+		tags = append(tags, &efs.Tag{Key: aws.String(k), Value: aws.String(desired[k])})
+	}
+
+	if _, err := s.EFS.CreateTagsWithContext(ctx, &efs.CreateTagsInput{FileSystemId: aws.String(id), Tags: tags}); err != nil {
+		return errors.Wrapf(err, "failed to tag file system %q", id)
+	}
+	return nil
+}
+
+// reconcileMountTargets creates a mount target in the first private subnet of each zone of the
+// network that doesn't have one yet. A file system can only have one mount target per zone.
+func (s *Service) reconcileMountTargets(ctx context.Context, id string, network *v1alpha1.Network, securityGroupID string) error {
+	targets, err := s.describeMountTargets(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	zones := make(map[string]string)
+	for _, sn := range network.Subnets {
+		zones[sn.ID] = sn.AvailabilityZone
+	}
+
+	covered := make(map[string]bool)
+	pending := 0
+	for _, mt := range targets {
+		covered[zones[aws.StringValue(mt.SubnetId)]] = true
+		if aws.StringValue(mt.LifeCycleState) != efs.LifeCycleStateAvailable {
+			pending++
+		}
+	}
+
+	for _, sn := range network.Subnets.FilterPrivate() {
+		if covered[sn.AvailabilityZone] {
+			continue
+		}
+
+		mt, err := s.EFS.CreateMountTargetWithContext(ctx, &efs.CreateMountTargetInput{
+			FileSystemId:   aws.String(id),
+			SubnetId:       aws.String(sn.ID),
+			SecurityGroups: aws.StringSlice([]string{securityGroupID}),
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to create mount target of file system %q in subnet %q", id, sn.ID)
+		}
+
+		s.log.V(2).Info("Created mount target", "file-system-id", id, "mount-target-id", mt.MountTargetId, "subnet-id", sn.ID)
+		covered[sn.AvailabilityZone] = true
+		pending++
+	}
+
+	if pending > 0 {
+		return ec2svc.NewNotReady(errors.Errorf("%d mount targets of file system %q are not available yet", pending, id))
+	}
+	return nil
+}
+
+func (s *Service) describeMountTargets(ctx context.Context, id string) ([]*efs.MountTargetDescription, error) {
+	var res []*efs.MountTargetDescription
+
+	input := &efs.DescribeMountTargetsInput{FileSystemId: aws.String(id)}
+	for {
+		out, err := s.EFS.DescribeMountTargetsWithContext(ctx, input)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to describe mount targets of file system %q", id)
+		}
+		res = append(res, out.MountTargets...)
+
+		if aws.StringValue(out.NextMarker) == "" {
+			return res, nil
+		}
+		input = &efs.DescribeMountTargetsInput{FileSystemId: aws.String(id), Marker: out.NextMarker}
+	}
+}
+
+// DeleteFileSystem deletes the mount targets of the file system of the cluster and then the file
+// system itself, if it exists. A not ready error is returned while mount targets are being deleted.
+func (s *Service) DeleteFileSystem(ctx context.Context, clusterName string) error {
+	fs, err := s.describeFileSystem(ctx, clusterName)
+	if err != nil || fs == nil {
+		return err
+	}
+
+	id := *fs.FileSystemId
+	switch aws.StringValue(fs.LifeCycleState) {
+	case efs.LifeCycleStateDeleting, efs.LifeCycleStateDeleted:
+		return nil
+	}
+
+	targets, err := s.describeMountTargets(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	for _, mt := range targets {
+		if aws.StringValue(mt.LifeCycleState) == efs.LifeCycleStateDeleting {
+			continue
+		}
+
+		_, err := s.EFS.DeleteMountTargetWithContext(ctx, &efs.DeleteMountTargetInput{MountTargetId: mt.MountTargetId})
+		if err != nil && !isNotFound(err) {
+			return errors.Wrapf(err, "failed to delete mount target %q of file system %q", *mt.MountTargetId, id)
+		}
+
+		s.log.V(2).Info("Deleted mount target", "cluster", clusterName, "file-system-id", id, "mount-target-id", mt.MountTargetId)
+	}
+	if len(targets) > 0 {
+		return ec2svc.NewNotReady(errors.Errorf("%d mount targets of file system %q are still being deleted", len(targets), id))
+	}
+
+	_, err = s.EFS.DeleteFileSystemWithContext(ctx, &efs.DeleteFileSystemInput{FileSystemId: aws.String(id)})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == efs.ErrCodeFileSystemInUse {
+		return ec2svc.NewNotReady(errors.Wrapf(err, "file system %q is still in use", id))
+	}
+	if err != nil && !isNotFound(err) {
+		return errors.Wrapf(err, "failed to delete file system %q", id)
+	}
+
+	s.log.V(2).Info("Deleted file system", "cluster", clusterName, "file-system-id", id)
+	return nil
+}
+
+func isNotFound(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code() == efs.ErrCodeFileSystemNotFound || aerr.Code() == efs.ErrCodeMountTargetNotFound
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package efs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/efs"
+	"github.com/aws/aws-sdk-go/service/efs/efsiface"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+)
+
+// fakeEFS keeps file systems in memory. Like AWS, file systems and mount targets are reported as
+// creating or deleting once, and are available or gone afterwards.
+type fakeEFS struct {
+	efsiface.EFSAPI
+
+	ids          int
+	fileSystems  []*efs.FileSystemDescription
+	mountTargets []*efs.MountTargetDescription
+	tags         map[string]map[string]string
+}
+
+func newFakeEFS() *fakeEFS {
+	return &fakeEFS{tags: make(map[string]map[string]string)}
+}
+
+func (f *fakeEFS) newID(prefix string) string {
+	f.ids++
+	return fmt.Sprintf("%s-%08x", prefix, f.ids)
+}
+
+func (f *fakeEFS) CreateFileSystemWithContext(_ aws.Context, in *efs.CreateFileSystemInput, _ ...request.Option) (*efs.FileSystemDescription, error) {
+	for _, fs := range f.fileSystems {
+		if aws.StringValue(fs.CreationToken) == aws.StringValue(in.CreationToken) {
+			return nil, awserr.New(efs.ErrCodeFileSystemAlreadyExists, "file system exists", nil)
+		}
+	}
+
+	fs := &efs.FileSystemDescription{
+		FileSystemId:    aws.String(f.newID("fs")),
+		CreationToken:   in.CreationToken,
+		PerformanceMode: in.PerformanceMode,
+		Encrypted:       in.Encrypted,
+		LifeCycleState:  aws.String(efs.LifeCycleStateCreating),
+	}
+	f.fileSystems = append(f.fileSystems, fs)
+	f.tags[*fs.FileSystemId] = make(map[string]string)
+	return fs, nil
+}
+
+func (f *fakeEFS) DescribeFileSystemsWithContext(_ aws.Context, in *efs.DescribeFileSystemsInput, _ ...request.Option) (*efs.DescribeFileSystemsOutput, error) {
+	out := &efs.DescribeFileSystemsOutput{}
+	for _, fs := range f.fileSystems {
+		if aws.StringValue(fs.CreationToken) == aws.StringValue(in.CreationToken) {
+			c := *fs
+			out.FileSystems = append(out.FileSystems, &c)
+			fs.LifeCycleState = aws.String(efs.LifeCycleStateAvailable)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeEFS) DeleteFileSystemWithContext(_ aws.Context, in *efs.DeleteFileSystemInput, _ ...request.Option) (*efs.DeleteFileSystemOutput, error) {
+	for _, mt := range f.mountTargets {
+		if aws.StringValue(mt.FileSystemId) == aws.StringValue(in.FileSystemId) {
+			return nil, awserr.New(efs.ErrCodeFileSystemInUse, "file system has mount targets", nil)
+		}
+	}
+	for i, fs := range f.fileSystems {
+		if aws.StringValue(fs.FileSystemId) == aws.StringValue(in.FileSystemId) {
+			f.fileSystems = append(f.fileSystems[:i], f.fileSystems[i+1:]...)
+			return &efs.DeleteFileSystemOutput{}, nil
+		}
+	}
+	return nil, awserr.New(efs.ErrCodeFileSystemNotFound, "file system not found", nil)
+}
+
+func (f *fakeEFS) DescribeTagsWithContext(_ aws.Context, in *efs.DescribeTagsInput, _ ...request.Option) (*efs.DescribeTagsOutput, error) {
+	out := &efs.DescribeTagsOutput{}
+	for k, v := range f.tags[aws.StringValue(in.FileSystemId)] {
+		out.Tags = append(out.Tags, &efs.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return out, nil
+}
+
+func (f *fakeEFS) CreateTagsWithContext(_ aws.Context, in *efs.CreateTagsInput, _ ...request.Option) (*efs.CreateTagsOutput, error) {
+	for _, tag := range in.Tags {
+		f.tags[aws.StringValue(in.FileSystemId)][aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return &efs.CreateTagsOutput{}, nil
+}
+
+func (f *fakeEFS) CreateMountTargetWithContext(_ aws.Context, in *efs.CreateMountTargetInput, _ ...request.Option) (*efs.MountTargetDescription, error) {
+	mt := &efs.MountTargetDescription{
+		MountTargetId:  aws.String(f.newID("fsmt")),
+		FileSystemId:   in.FileSystemId,
+		SubnetId:       in.SubnetId,
+		LifeCycleState: aws.String(efs.LifeCycleStateCreating),
+	}
+	f.mountTargets = append(f.mountTargets, mt)
+	return mt, nil
+}
+
+func (f *fakeEFS) DescribeMountTargetsWithContext(_ aws.Context, in *efs.DescribeMountTargetsInput, _ ...request.Option) (*efs.DescribeMountTargetsOutput, error) {
+	out := &efs.DescribeMountTargetsOutput{}
+	var remaining []*efs.MountTargetDescription
+	for _, mt := range f.mountTargets {
+		if aws.StringValue(mt.FileSystemId) != aws.StringValue(in.FileSystemId) {
+			remaining = append(remaining, mt)
+			continue
+		}
+
+		c := *mt
+		out.MountTargets = append(out.MountTargets, &c)
+		if aws.StringValue(mt.LifeCycleState) != efs.LifeCycleStateDeleting {
+			mt.LifeCycleState = aws.String(efs.LifeCycleStateAvailable)
+			remaining = append(remaining, mt)
+		}
+	}
+	f.mountTargets = remaining
+	return out, nil
+}
+
+func (f *fakeEFS) DeleteMountTargetWithContext(_ aws.Context, in *efs.DeleteMountTargetInput, _ ...request.Option) (*efs.DeleteMountTargetOutput, error) {
+	for _, mt := range f.mountTargets {
+		if aws.StringValue(mt.MountTargetId) == aws.StringValue(in.MountTargetId) {
+			mt.LifeCycleState = aws.String(efs.LifeCycleStateDeleting)
+			return &efs.DeleteMountTargetOutput{}, nil
+		}
+	}
+	return nil, awserr.New(efs.ErrCodeMountTargetNotFound, "mount target not found", nil)
+}
+
+func testNetwork() *v1alpha1.Network {
+	return &v1alpha1.Network{
+		Subnets: v1alpha1.Subnets{
+			{ID: "subnet-private-a", AvailabilityZone: "us-east-1a"},
+			{ID: "subnet-private-a2", AvailabilityZone: "us-east-1a"},
+			{ID: "subnet-public-a", AvailabilityZone: "us-east-1a", IsPublic: true},
+			{ID: "subnet-private-b", AvailabilityZone: "us-east-1b"},
+		},
+	}
+}
+
+func TestReconcileFileSystem(t *testing.T) {
+	f := newFakeEFS()
+	s := NewService(f)
+
+	spec := &v1alpha1.FileSystemSpec{Encrypted: true}
+	status := &v1alpha1.FileSystem{}
+
+	var err error
+	for i := 0; i < 5; i++ {
+		err = s.ReconcileFileSystem(context.TODO(), "test-cluster", spec, map[string]string{"team": "ml"}, testNetwork(), "sg-1", status)
+		if !ec2svc.IsNotReady(err) {
+			break
+		}
+		if status.ID == "" {
+			t.Fatalf("expected the file system to be recorded while it is created")
+		}
+	}
+	if err != nil {
+		t.Fatalf("failed to reconcile file system: %v", err)
+	}
+
+	if len(f.fileSystems) != 1 {
+		t.Fatalf("expected a single file system, got: %v", f.fileSystems)
+	}
+	fs := f.fileSystems[0]
+	if status.ID != *fs.FileSystemId || status.SecurityGroupID != "sg-1" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if aws.StringValue(fs.PerformanceMode) != efs.PerformanceModeGeneralPurpose || !aws.BoolValue(fs.Encrypted) {
+		t.Fatalf("unexpected file system: %v", fs)
+	}
+
+	tags := f.tags[*fs.FileSystemId]
+	if tags["kubernetes.io/cluster/test-cluster"] != ec2svc.ResourceLifecycleOwned || tags["team"] != "ml" || tags["Name"] != "test-cluster" {
+		t.Fatalf("unexpected tags: %v", tags)
+	}
+
+	// One mount target per zone of the private subnets.
+	subnets := map[string]bool{}
+	for _, mt := range f.mountTargets {
+		subnets[aws.StringValue(mt.SubnetId)] = true
+	}
+	if len(f.mountTargets) != 2 || !subnets["subnet-private-a"] || !subnets["subnet-private-b"] {
+		t.Fatalf("expected mount targets in the first private subnet of each zone, got: %v", f.mountTargets)
+	}
+
+	for i := 0; i < 5; i++ {
+		err = s.DeleteFileSystem(context.TODO(), "test-cluster")
+		if !ec2svc.IsNotReady(err) {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("failed to delete file system: %v", err)
+	}
+	if len(f.fileSystems) != 0 || len(f.mountTargets) != 0 {
+		t.Fatalf("expected file system and mount targets to be deleted, got: %v, %v", f.fileSystems, f.mountTargets)
+	}
+
+	// Deleting a file system that is already gone succeeds.
+	if err := s.DeleteFileSystem(context.TODO(), "test-cluster"); err != nil {
+		t.Fatalf("failed to delete file system again: %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elb
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+)
+
+// DeregisterInstances deregisters the given instances from the classic load balancers and the
+// target groups in the vpc that are owned by the cluster, so that no traffic is sent to them while
+// they're stopped. The removed registrations are returned for RegisterInstances to restore them,
+// also along with an error for the ones removed before it.
+func (s *Service) DeregisterInstances(ctx context.Context, clusterName string, vpc *v1alpha1.VPC, instanceIDs []string) ([]v1alpha1.LoadBalancerRegistration, error) {
+	if vpc.ID == "" || len(instanceIDs) == 0 {
+		return nil, nil
+	}
+
+	ids := make(map[string]bool, len(instanceIDs))
+	for _, id := range instanceIDs {
+		ids[id] = true
+	}
+
+	lbs, err := s.describeClassicLoadBalancers(ctx, vpc.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	key := ec2svc.TagNameKubernetesClusterPrefix + clusterName
+	var res []v1alpha1.LoadBalancerRegistration
+	for _, lb := range lbs {
+		if lb.tags[key] != ec2svc.ResourceLifecycleOwned {
+			continue
+		}
+
+		var instances []*elb.Instance
+		for _, id := range lb.instances {
+			if ids[id] {
+				instances = append(instances, &elb.Instance{InstanceId: aws.String(id)})
+			}
+		}
+		if len(instances) == 0 {
+			continue
+		}
+
+		_, err := s.ELB.DeregisterInstancesFromLoadBalancerWithContext(ctx, &elb.DeregisterInstancesFromLoadBalancerInput{
+			LoadBalancerName: aws.String(lb.name),
+			Instances:        instances,
+		})
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return res, errors.Wrapf(err, "failed to deregister instances from load balancer %q", lb.name)
+		}
+
+		for _, i := range instances {
+			res = append(res, v1alpha1.LoadBalancerRegistration{InstanceID: aws.StringValue(i.InstanceId), LoadBalancerName: lb.name})
+		}
+		s.log.V(2).Info("Deregistered instances from load balancer", "cluster", clusterName, "load-balancer", lb.name, "instance-count", len(instances))
+	}
+
+	arns, err := s.describeTargetGroups(ctx, clusterName, vpc.ID, func(tg *elbv2.TargetGroup) bool {
+		return aws.StringValue(tg.TargetType) != elbv2.TargetTypeEnumIp
+	})
+	if err != nil {
+		return res, err
+	}
+
+	for _, arn := range arns {
+		out, err := s.ELBV2.DescribeTargetHealthWithContext(ctx, &elbv2.DescribeTargetHealthInput{TargetGroupArn: aws.String(arn)})
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return res, errors.Wrapf(err, "failed to describe targets of target group %q", arn)
+		}
+
+		var targets []*elbv2.TargetDescription
+		for _, h := range out.TargetHealthDescriptions {
+			if ids[aws.StringValue(h.Target.Id)] {
+				targets = append(targets, h.Target)
+			}
+		}
+		if len(targets) == 0 {
+			continue
+		}
+
+		_, err = s.ELBV2.DeregisterTargetsWithContext(ctx, &elbv2.DeregisterTargetsInput{
+			TargetGroupArn: aws.String(arn),
+			Targets:        targets,
+		})
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return res, errors.Wrapf(err, "failed to deregister instances from target group %q", arn)
+		}
+
+		for _, t := range targets {
+			res = append(res, v1alpha1.LoadBalancerRegistration{InstanceID: aws.StringValue(t.Id), TargetGroupARN: arn, Port: aws.Int64Value(t.Port)})
+		}
+		s.log.V(2).Info("Deregistered instances from target group", "cluster", clusterName, "target-group-arn", arn, "instance-count", len(targets))
+	}
+
+	return res, nil
+}
+
+// RegisterInstances restores the registrations removed by DeregisterInstances once the instances
+// are running again. Load balancers and target groups that were deleted in the meantime are
+// skipped, as are instances that were terminated.
+func (s *Service) RegisterInstances(ctx context.Context, clusterName string, registrations []v1alpha1.LoadBalancerRegistration) error {
+	var names, arns []string
+	instances := make(map[string][]*elb.Instance)
+	targets := make(map[string][]*elbv2.TargetDescription)
+	for _, r := range registrations {
+		if r.TargetGroupARN != "" {
+			if _, ok := targets[r.TargetGroupARN]; !ok {
+				arns = append(arns, r.TargetGroupARN)
+			}
+			targets[r.TargetGroupARN] = append(targets[r.TargetGroupARN], &elbv2.TargetDescription{Id: aws.String(r.InstanceID), Port: aws.Int64(r.Port)})
+			continue
+		}
+
+		if _, ok := instances[r.LoadBalancerName]; !ok {
+			names = append(names, r.LoadBalancerName)
+		}
+		instances[r.LoadBalancerName] = append(instances[r.LoadBalancerName], &elb.Instance{InstanceId: aws.String(r.InstanceID)})
+	}
+
+	for _, name := range names {
+		var registered int
+		for _, i := range instances[name] {
+			// Registering a terminated instance fails the whole call, the instances are
+			// registered one by one so that only it is skipped.
+			_, err := s.ELB.RegisterInstancesWithLoadBalancerWithContext(ctx, &elb.RegisterInstancesWithLoadBalancerInput{
+				LoadBalancerName: aws.String(name),
+				Instances:        []*elb.Instance{i},
+			})
+			if isNotFound(err) || isInvalidInstance(err) {
+				s.log.V(2).Info("Skipped registration with load balancer", "cluster", clusterName, "load-balancer", name, "instance-id", aws.StringValue(i.InstanceId), "reason", err)
+				continue
+			}
+			if err != nil {
+				return errors.Wrapf(err, "failed to register instance %q with load balancer %q", aws.StringValue(i.InstanceId), name)
+			}
+			registered++
+		}
+		s.log.V(2).Info("Registered instances with load balancer", "cluster", clusterName, "load-balancer", name, "instance-count", registered)
+	}
+
+	for _, arn := range arns {
+		var registered int
+		for _, t := range targets[arn] {
+			_, err := s.ELBV2.RegisterTargetsWithContext(ctx, &elbv2.RegisterTargetsInput{
+				TargetGroupArn: aws.String(arn),
+				Targets:        []*elbv2.TargetDescription{t},
+			})
+			if isNotFound(err) || isInvalidInstance(err) {
+				s.log.V(2).Info("Skipped registration with target group", "cluster", clusterName, "target-group-arn", arn, "instance-id", aws.StringValue(t.Id), "reason", err)
+				continue
+			}
+			if err != nil {
+				return errors.Wrapf(err, "failed to register instance %q with target group %q", aws.StringValue(t.Id), arn)
+			}
+			registered++
+		}
+		s.log.V(2).Info("Registered instances with target group", "cluster", clusterName, "target-group-arn", arn, "instance-count", registered)
+	}
+
+	return nil
+}
+
+// isInvalidInstance returns true if the instance of a registration no longer exists.
+func isInvalidInstance(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case elb.ErrCodeInvalidEndPointException, elbv2.ErrCodeInvalidTargetException:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+)
+
+func TestDeregisterInstances(t *testing.T) {
+	owned := map[string]string{"kubernetes.io/cluster/test": "owned"}
+
+	classic := &fakeELB{tags: make(map[string]map[string]string)}
+	classic.add("service-a", "vpc-1", owned)
+	classic.add("manual", "vpc-1", nil)
+	for _, id := range []string{"i-1", "i-2", "i-3"} {
+		classic.lbs[0].Instances = append(classic.lbs[0].Instances, &elb.Instance{InstanceId: aws.String(id)})
+	}
+	classic.lbs[1].Instances = []*elb.Instance{{InstanceId: aws.String("i-1")}}
+
+	v2 := &fakeELBV2{tags: make(map[string]map[string]string), targets: make(map[string][]*elbv2.TargetDescription)}
+	v2.add("nlb", "vpc-1", owned)
+	tgARN := aws.StringValue(v2.tgs[0].TargetGroupArn)
+	v2.targets[tgARN] = []*elbv2.TargetDescription{
+		{Id: aws.String("i-1"), Port: aws.Int64(30080)},
+		{Id: aws.String("i-3"), Port: aws.Int64(30080)},
+	}
+
+	s := NewService(classic, v2)
+
+	registrations, err := s.DeregisterInstances(context.TODO(), "test", &v1alpha1.VPC{ID: "vpc-1"}, []string{"i-1", "i-2"})
+	if err != nil {
+		t.Fatalf("failed to deregister instances: %v", err)
+	}
+	expected := []v1alpha1.LoadBalancerRegistration{
+		{InstanceID: "i-1", LoadBalancerName: "service-a"},
+		{InstanceID: "i-2", LoadBalancerName: "service-a"},
+		{InstanceID: "i-1", TargetGroupARN: tgARN, Port: 30080},
+	}
+	if !reflect.DeepEqual(registrations, expected) {
+		t.Fatalf("expected registrations %+v, got %+v", expected, registrations)
+	}
+
+	instanceIDs := func(instances []*elb.Instance) []string {
+		var res []string
+		for _, i := range instances {
+			res = append(res, aws.StringValue(i.InstanceId))
+		}
+		return res
+	}
+	targetIDs := func(targets []*elbv2.TargetDescription) []string {
+		var res []string
+		for _, t := range targets {
+			res = append(res, aws.StringValue(t.Id))
+		}
+		return res
+	}
+
+	if ids := instanceIDs(classic.lbs[0].Instances); !reflect.DeepEqual(ids, []string{"i-3"}) {
+		t.Fatalf("expected only the other instance to stay registered, got: %v", ids)
+	}
+	if ids := instanceIDs(classic.lbs[1].Instances); !reflect.DeepEqual(ids, []string{"i-1"}) {
+		t.Fatalf("expected the load balancer that isn't owned to be left alone, got: %v", ids)
+	}
+	if ids := targetIDs(v2.targets[tgARN]); !reflect.DeepEqual(ids, []string{"i-3"}) {
+		t.Fatalf("expected only the other target to stay registered, got: %v", ids)
+	}
+
+	// An instance terminated while hibernated is skipped.
+	classic.terminated = map[string]bool{"i-2": true}
+	if err := s.RegisterInstances(context.TODO(), "test", registrations); err != nil {
+		t.Fatalf("failed to register instances: %v", err)
+	}
+
+	if ids := instanceIDs(classic.lbs[0].Instances); !reflect.DeepEqual(ids, []string{"i-3", "i-1"}) {
+		t.Fatalf("expected the instance to be registered again, got: %v", ids)
+	}
+	if ids := targetIDs(v2.targets[tgARN]); !reflect.DeepEqual(ids, []string{"i-3", "i-1"}) {
+		t.Fatalf("expected the target to be registered again, got: %v", ids)
+	}
+	if port := aws.Int64Value(v2.targets[tgARN][1].Port); port != 30080 {
+		t.Fatalf("expected the target to be registered on its port, got: %d", port)
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package elb deletes the load balancers left behind in the vpc of a cluster, like the classic
+// ELBs and NLBs the Kubernetes AWS cloud provider creates for services of type LoadBalancer.
+// Their network interfaces and security groups keep the subnets and the vpc in use. It also takes
+// the instances of a hibernated cluster out of its load balancers while they're stopped.
+package elb
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/logger"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+)
+
+// maxDescribeTags is the maximum number of load balancers whose tags can be described at once.
+const maxDescribeTags = 20
+
+// Service deletes the load balancers of clusters and manages the registrations of their
+// hibernated instances.
+type Service struct {
+	ELB   elbiface.ELBAPI
+	ELBV2 elbv2iface.ELBV2API
+
+	log logr.Logger
+}
+
+// NewService returns a new service given the api clients of classic and of application and
+// network load balancers.
+func NewService(elbAPI elbiface.ELBAPI, elbv2API elbv2iface.ELBV2API) *Service {
+	return &Service{
+		ELB:   elbAPI,
+		ELBV2: elbv2API,
+		log:   logger.Default(),
+	}
+}
+
+// WithLogger returns a copy of the service that logs to the given logger.
+func (s *Service) WithLogger(log logr.Logger) *Service {
+	c := *s
+	c.log = log
+	return &c
+}
+
+// loadBalancer is a classic load balancer, which has no arn, or an application or network one.
+type loadBalancer struct {
+	name string
+	arn  string
+	tags map[string]string
+
+	// instances are the ids of the instances registered with a classic load balancer.
+	instances []string
+}
+
+// DeleteLoadBalancers deletes the load balancers in the vpc that are owned by the cluster, and
+// then the target groups of network load balancers owned by it. A not ready error is returned
+// while load balancers are being deleted. If the vpc is owned by the cluster, any other load
+// balancer in it would keep it from being deleted and is reported in the returned error.
+func (s *Service) DeleteLoadBalancers(ctx context.Context, clusterName string, vpc *v1alpha1.VPC) error {
+	if vpc.ID == "" {
+		return nil
+	}
+
+	lbs, err := s.describeClassicLoadBalancers(ctx, vpc.ID)
+	if err != nil {
+		return err
+	}
+	v2, err := s.describeLoadBalancers(ctx, vpc.ID)
+	if err != nil {
+		return err
+	}
+	lbs = append(lbs, v2...)
+
+	key := ec2svc.TagNameKubernetesClusterPrefix + clusterName
+	var deleted int
+	var others []string
+	for _, lb := range lbs {
+		if lb.tags[key] != ec2svc.ResourceLifecycleOwned {
+			others = append(others, lb.name)
+			continue
+		}
+
+		if err := s.deleteLoadBalancer(ctx, lb); err != nil {
+			return err
+		}
+		s.log.V(2).Info("Deleted load balancer", "cluster", clusterName, "load-balancer", lb.name, "vpc-id", vpc.ID)
+		deleted++
+	}
+	if deleted > 0 {
+		return ec2svc.NewNotReady(errors.Errorf("%d load balancers in vpc %q are still being deleted", deleted, vpc.ID))
+	}
+
+	if err := s.deleteTargetGroups(ctx, clusterName, vpc.ID); err != nil {
+		return err
+	}
+
+	if len(others) > 0 && vpc.Tags[key] == ec2svc.ResourceLifecycleOwned {
+		sort.Strings(others)
+		return errors.Errorf("vpc %q can't be deleted while it has load balancers that aren't owned by cluster %q: %s", vpc.ID, clusterName, strings.Join(others, ", "))
+	}
+	return nil
+}
+
+func (s *Service) deleteLoadBalancer(ctx context.Context, lb loadBalancer) error {
+	var err error
+	if lb.arn == "" {
+		_, err = s.ELB.DeleteLoadBalancerWithContext(ctx, &elb.DeleteLoadBalancerInput{LoadBalancerName: aws.String(lb.name)})
+	} else {
+		_, err = s.ELBV2.DeleteLoadBalancerWithContext(ctx, &elbv2.DeleteLoadBalancerInput{LoadBalancerArn: aws.String(lb.arn)})
+	}
+	if err != nil && !isNotFound(err) {
+		return errors.Wrapf(err, "failed to delete load balancer %q", lb.name)
+	}
+	return nil
+}
+
+// describeClassicLoadBalancers returns the classic load balancers in the vpc with their tags.
+func (s *Service) describeClassicLoadBalancers(ctx context.Context, vpcID string) ([]loadBalancer, error) {
+	var names []string
+	instances := make(map[string][]string)
+	err := s.ELB.DescribeLoadBalancersPagesWithContext(ctx, &elb.DescribeLoadBalancersInput{}, func(out *elb.DescribeLoadBalancersOutput, _ bool) bool {
+		for _, lb := range out.LoadBalancerDescriptions {
+			if aws.StringValue(lb.VPCId) != vpcID {
+				continue
+			}
+			name := aws.StringValue(lb.LoadBalancerName)
+			names = append(names, name)
+			for _, i := range lb.Instances {
+				instances[name] = append(instances[name], aws.StringValue(i.InstanceId))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe classic load balancers")
+	}
+
+	res := make([]loadBalancer, 0, len(names))
+	for i := 0; i < len(names); i += maxDescribeTags {
+		out, err := s.ELB.DescribeTagsWithContext(ctx, &elb.DescribeTagsInput{
+			LoadBalancerNames: aws.StringSlice(names[i:min(i+maxDescribeTags, len(names))]),
+		})
+		if err != nil && !isNotFound(err) {
+			return nil, errors.Wrap(err, "failed to describe tags of classic load balancers")
+		}
+		if err != nil {
+			// One of them is already gone, the others are described again on the next attempt.
+			return nil, ec2svc.NewNotReady(errors.Wrap(err, "classic load balancers are being deleted"))
+		}
+
+		for _, d := range out.TagDescriptions {
+			tags := make(map[string]string, len(d.Tags))
+			for _, t := range d.Tags {
+				tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+			}
+			name := aws.StringValue(d.LoadBalancerName)
+			res = append(res, loadBalancer{name: name, tags: tags, instances: instances[name]})
+		}
+	}
+	return res, nil
+}
+
+// describeLoadBalancers returns the application and network load balancers in the vpc with
+// their tags.
+func (s *Service) describeLoadBalancers(ctx context.Context, vpcID string) ([]loadBalancer, error) {
+	var lbs []loadBalancer
+	err := s.ELBV2.DescribeLoadBalancersPagesWithContext(ctx, &elbv2.DescribeLoadBalancersInput{}, func(out *elbv2.DescribeLoadBalancersOutput, _ bool) bool {
+		for _, lb := range out.LoadBalancers {
+			if aws.StringValue(lb.VpcId) == vpcID {
+				lbs = append(lbs, loadBalancer{name: aws.StringValue(lb.LoadBalancerName), arn: aws.StringValue(lb.LoadBalancerArn)})
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe load balancers")
+	}
+
+	arns := make([]string, 0, len(lbs))
+	for _, lb := range lbs {
+		arns = append(arns, lb.arn)
+	}
+	tags, err := s.describeTags(ctx, arns)
+	if err != nil {
+		return nil, err
+	}
+	for i := range lbs {
+		lbs[i].tags = tags[lbs[i].arn]
+	}
+	return lbs, nil
+}
+
+// deleteTargetGroups deletes the target groups in the vpc owned by the cluster that are no
+// longer used by a load balancer.
+func (s *Service) deleteTargetGroups(ctx context.Context, clusterName string, vpcID string) error {
+	arns, err := s.describeTargetGroups(ctx, clusterName, vpcID, func(tg *elbv2.TargetGroup) bool {
+		return len(tg.LoadBalancerArns) == 0
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, arn := range arns {
+		_, err := s.ELBV2.DeleteTargetGroupWithContext(ctx, &elbv2.DeleteTargetGroupInput{TargetGroupArn: aws.String(arn)})
+		if err != nil && !isNotFound(err) {
+			return errors.Wrapf(err, "failed to delete target group %q", arn)
+		}
+		s.log.V(2).Info("Deleted target group", "cluster", clusterName, "target-group-arn", arn, "vpc-id", vpcID)
+	}
+	return nil
+}
+
+// describeTargetGroups returns the arns of the target groups in the vpc owned by the cluster
+// that match the filter.
+func (s *Service) describeTargetGroups(ctx context.Context, clusterName string, vpcID string, filter func(*elbv2.TargetGroup) bool) ([]string, error) {
+	var arns []string
+	err := s.ELBV2.DescribeTargetGroupsPagesWithContext(ctx, &elbv2.DescribeTargetGroupsInput{}, func(out *elbv2.DescribeTargetGroupsOutput, _ bool) bool {
+		for _, tg := range out.TargetGroups {
+			if aws.StringValue(tg.VpcId) == vpcID && filter(tg) {
+				arns = append(arns, aws.StringValue(tg.TargetGroupArn))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe target groups")
+	}
+
+	tags, err := s.describeTags(ctx, arns)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(arns))
+	for _, arn := range arns {
+		if tags[arn][ec2svc.TagNameKubernetesClusterPrefix+clusterName] == ec2svc.ResourceLifecycleOwned {
+			res = append(res, arn)
+		}
+	}
+	return res, nil
+}
+
+// describeTags returns the tags of the given load balancers or target groups by arn.
+func (s *Service) describeTags(ctx context.Context, arns []string) (map[string]map[string]string, error) {
+	res := make(map[string]map[string]string, len(arns))
+	for i := 0; i < len(arns); i += maxDescribeTags {
+		out, err := s.ELBV2.DescribeTagsWithContext(ctx, &elbv2.DescribeTagsInput{
+			ResourceArns: aws.StringSlice(arns[i:min(i+maxDescribeTags, len(arns))]),
+		})
+		if err != nil && !isNotFound(err) {
+			return nil, errors.Wrap(err, "failed to describe tags of load balancers")
+		}
+		if err != nil {
+			return nil, ec2svc.NewNotReady(errors.Wrap(err, "load balancers are being deleted"))
+		}
+
+		for _, d := range out.TagDescriptions {
+			tags := make(map[string]string, len(d.Tags))
+			for _, t := range d.Tags {
+				tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+			}
+			res[aws.StringValue(d.ResourceArn)] = tags
+		}
+	}
+	return res, nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func isNotFound(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		// elbv2.ErrCodeLoadBalancerNotFoundException is LoadBalancerNotFound as well.
+		case elb.ErrCodeAccessPointNotFoundException, elbv2.ErrCodeTargetGroupNotFoundException:
+			return true
+		}
+	}
+	return false
+}
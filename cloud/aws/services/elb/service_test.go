@@ -0,0 +1,271 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elb
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+)
+
+// fakeELB keeps classic load balancers in memory.
+type fakeELB struct {
+	elbiface.ELBAPI
+
+	lbs  []*elb.LoadBalancerDescription
+	tags map[string]map[string]string
+
+	// terminated are the ids of instances that can't be registered.
+	terminated map[string]bool
+}
+
+func (f *fakeELB) add(name, vpcID string, tags map[string]string) {
+	f.lbs = append(f.lbs, &elb.LoadBalancerDescription{LoadBalancerName: aws.String(name), VPCId: aws.String(vpcID)})
+	f.tags[name] = tags
+}
+
+func (f *fakeELB) DescribeLoadBalancersPagesWithContext(_ aws.Context, _ *elb.DescribeLoadBalancersInput, fn func(*elb.DescribeLoadBalancersOutput, bool) bool, _ ...request.Option) error {
+	fn(&elb.DescribeLoadBalancersOutput{LoadBalancerDescriptions: f.lbs}, true)
+	return nil
+}
+
+func (f *fakeELB) DescribeTagsWithContext(_ aws.Context, in *elb.DescribeTagsInput, _ ...request.Option) (*elb.DescribeTagsOutput, error) {
+	if len(in.LoadBalancerNames) > maxDescribeTags {
+		return nil, awserr.New("ValidationError", "too many load balancers", nil)
+	}
+	out := &elb.DescribeTagsOutput{}
+	for _, name := range in.LoadBalancerNames {
+		d := &elb.TagDescription{LoadBalancerName: name}
+		for k, v := range f.tags[*name] {
+			d.Tags = append(d.Tags, &elb.Tag{Key: aws.String(k), Value: aws.String(v)})
+		}
+		out.TagDescriptions = append(out.TagDescriptions, d)
+	}
+	return out, nil
+}
+
+func (f *fakeELB) DeleteLoadBalancerWithContext(_ aws.Context, in *elb.DeleteLoadBalancerInput, _ ...request.Option) (*elb.DeleteLoadBalancerOutput, error) {
+	for i, lb := range f.lbs {
+		if *lb.LoadBalancerName == *in.LoadBalancerName {
+			f.lbs = append(f.lbs[:i], f.lbs[i+1:]...)
+			break
+		}
+	}
+	return &elb.DeleteLoadBalancerOutput{}, nil
+}
+
+func (f *fakeELB) find(name string) *elb.LoadBalancerDescription {
+	for _, lb := range f.lbs {
+		if *lb.LoadBalancerName == name {
+			return lb
+		}
+	}
+	return nil
+}
+
+func (f *fakeELB) DeregisterInstancesFromLoadBalancerWithContext(_ aws.Context, in *elb.DeregisterInstancesFromLoadBalancerInput, _ ...request.Option) (*elb.DeregisterInstancesFromLoadBalancerOutput, error) {
+	lb := f.find(*in.LoadBalancerName)
+	if lb == nil {
+		return nil, awserr.New(elb.ErrCodeAccessPointNotFoundException, "load balancer not found", nil)
+	}
+	for _, i := range in.Instances {
+		for j, r := range lb.Instances {
+			if *r.InstanceId == *i.InstanceId {
+				lb.Instances = append(lb.Instances[:j], lb.Instances[j+1:]...)
+				break
+			}
+		}
+	}
+	return &elb.DeregisterInstancesFromLoadBalancerOutput{Instances: lb.Instances}, nil
+}
+
+func (f *fakeELB) RegisterInstancesWithLoadBalancerWithContext(_ aws.Context, in *elb.RegisterInstancesWithLoadBalancerInput, _ ...request.Option) (*elb.RegisterInstancesWithLoadBalancerOutput, error) {
+	lb := f.find(*in.LoadBalancerName)
+	if lb == nil {
+		return nil, awserr.New(elb.ErrCodeAccessPointNotFoundException, "load balancer not found", nil)
+	}
+	for _, i := range in.Instances {
+		if f.terminated[*i.InstanceId] {
+			return nil, awserr.New(elb.ErrCodeInvalidEndPointException, "invalid instance", nil)
+		}
+	}
+	lb.Instances = append(lb.Instances, in.Instances...)
+	return &elb.RegisterInstancesWithLoadBalancerOutput{Instances: lb.Instances}, nil
+}
+
+// fakeELBV2 keeps network load balancers and their target groups in memory.
+type fakeELBV2 struct {
+	elbv2iface.ELBV2API
+
+	lbs     []*elbv2.LoadBalancer
+	tgs     []*elbv2.TargetGroup
+	tags    map[string]map[string]string
+	targets map[string][]*elbv2.TargetDescription
+}
+
+func (f *fakeELBV2) add(name, vpcID string, tags map[string]string) {
+	arn := "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/net/" + name
+	f.lbs = append(f.lbs, &elbv2.LoadBalancer{LoadBalancerName: aws.String(name), LoadBalancerArn: aws.String(arn), VpcId: aws.String(vpcID)})
+	f.tags[arn] = tags
+
+	tgARN := "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/" + name
+	f.tgs = append(f.tgs, &elbv2.TargetGroup{TargetGroupArn: aws.String(tgARN), VpcId: aws.String(vpcID), LoadBalancerArns: aws.StringSlice([]string{arn})})
+	f.tags[tgARN] = tags
+}
+
+func (f *fakeELBV2) DescribeLoadBalancersPagesWithContext(_ aws.Context, _ *elbv2.DescribeLoadBalancersInput, fn func(*elbv2.DescribeLoadBalancersOutput, bool) bool, _ ...request.Option) error {
+	fn(&elbv2.DescribeLoadBalancersOutput{LoadBalancers: f.lbs}, true)
+	return nil
+}
+
+func (f *fakeELBV2) DescribeTargetGroupsPagesWithContext(_ aws.Context, _ *elbv2.DescribeTargetGroupsInput, fn func(*elbv2.DescribeTargetGroupsOutput, bool) bool, _ ...request.Option) error {
+	fn(&elbv2.DescribeTargetGroupsOutput{TargetGroups: f.tgs}, true)
+	return nil
+}
+
+func (f *fakeELBV2) DescribeTagsWithContext(_ aws.Context, in *elbv2.DescribeTagsInput, _ ...request.Option) (*elbv2.DescribeTagsOutput, error) {
+	if len(in.ResourceArns) > maxDescribeTags {
+		return nil, awserr.New("ValidationError", "too many resources", nil)
+	}
+	out := &elbv2.DescribeTagsOutput{}
+	for _, arn := range in.ResourceArns {
+		d := &elbv2.TagDescription{ResourceArn: arn}
+		for k, v := range f.tags[*arn] {
+			d.Tags = append(d.Tags, &elbv2.Tag{Key: aws.String(k), Value: aws.String(v)})
+		}
+		out.TagDescriptions = append(out.TagDescriptions, d)
+	}
+	return out, nil
+}
+
+func (f *fakeELBV2) DeleteLoadBalancerWithContext(_ aws.Context, in *elbv2.DeleteLoadBalancerInput, _ ...request.Option) (*elbv2.DeleteLoadBalancerOutput, error) {
+	for i, lb := range f.lbs {
+		if *lb.LoadBalancerArn == *in.LoadBalancerArn {
+			f.lbs = append(f.lbs[:i], f.lbs[i+1:]...)
+			break
+		}
+	}
+	for _, tg := range f.tgs {
+		for i, arn := range tg.LoadBalancerArns {
+			if *arn == *in.LoadBalancerArn {
+				tg.LoadBalancerArns = append(tg.LoadBalancerArns[:i], tg.LoadBalancerArns[i+1:]...)
+				break
+			}
+		}
+	}
+	return &elbv2.DeleteLoadBalancerOutput{}, nil
+}
+
+func (f *fakeELBV2) DescribeTargetHealthWithContext(_ aws.Context, in *elbv2.DescribeTargetHealthInput, _ ...request.Option) (*elbv2.DescribeTargetHealthOutput, error) {
+	out := &elbv2.DescribeTargetHealthOutput{}
+	for _, t := range f.targets[*in.TargetGroupArn] {
+		out.TargetHealthDescriptions = append(out.TargetHealthDescriptions, &elbv2.TargetHealthDescription{Target: t})
+	}
+	return out, nil
+}
+
+func (f *fakeELBV2) DeregisterTargetsWithContext(_ aws.Context, in *elbv2.DeregisterTargetsInput, _ ...request.Option) (*elbv2.DeregisterTargetsOutput, error) {
+	for _, t := range in.Targets {
+		targets := f.targets[*in.TargetGroupArn]
+		for i, r := range targets {
+			if *r.Id == *t.Id && aws.Int64Value(r.Port) == aws.Int64Value(t.Port) {
+				f.targets[*in.TargetGroupArn] = append(targets[:i], targets[i+1:]...)
+				break
+			}
+		}
+	}
+	return &elbv2.DeregisterTargetsOutput{}, nil
+}
+
+func (f *fakeELBV2) RegisterTargetsWithContext(_ aws.Context, in *elbv2.RegisterTargetsInput, _ ...request.Option) (*elbv2.RegisterTargetsOutput, error) {
+	f.targets[*in.TargetGroupArn] = append(f.targets[*in.TargetGroupArn], in.Targets...)
+	return &elbv2.RegisterTargetsOutput{}, nil
+}
+
+func (f *fakeELBV2) DeleteTargetGroupWithContext(_ aws.Context, in *elbv2.DeleteTargetGroupInput, _ ...request.Option) (*elbv2.DeleteTargetGroupOutput, error) {
+	for i, tg := range f.tgs {
+		if *tg.TargetGroupArn == *in.TargetGroupArn {
+			if len(tg.LoadBalancerArns) > 0 {
+				return nil, awserr.New(elbv2.ErrCodeResourceInUseException, "target group in use", nil)
+			}
+			f.tgs = append(f.tgs[:i], f.tgs[i+1:]...)
+			break
+		}
+	}
+	return &elbv2.DeleteTargetGroupOutput{}, nil
+}
+
+func TestDeleteLoadBalancers(t *testing.T) {
+	owned := map[string]string{"kubernetes.io/cluster/test": "owned"}
+
+	classic := &fakeELB{tags: make(map[string]map[string]string)}
+	v2 := &fakeELBV2{tags: make(map[string]map[string]string)}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n", "o", "p", "q", "r", "s", "t", "u"} {
+		classic.add("service-"+name, "vpc-1", owned)
+	}
+	classic.add("other-vpc", "vpc-2", owned)
+	v2.add("nlb", "vpc-1", owned)
+	v2.add("other-nlb", "vpc-2", owned)
+
+	s := NewService(classic, v2)
+	vpc := &v1alpha1.VPC{ID: "vpc-1", Tags: owned}
+
+	if err := s.DeleteLoadBalancers(context.TODO(), "test", vpc); !ec2svc.IsNotReady(err) {
+		t.Fatalf("expected load balancers to be deleted, got: %v", err)
+	}
+	if err := s.DeleteLoadBalancers(context.TODO(), "test", vpc); err != nil {
+		t.Fatalf("failed to delete load balancers: %v", err)
+	}
+
+	if len(classic.lbs) != 1 || *classic.lbs[0].LoadBalancerName != "other-vpc" {
+		t.Fatalf("expected only the classic load balancer of the other vpc to be kept, got: %v", classic.lbs)
+	}
+	if len(v2.lbs) != 1 || *v2.lbs[0].LoadBalancerName != "other-nlb" {
+		t.Fatalf("expected only the network load balancer of the other vpc to be kept, got: %v", v2.lbs)
+	}
+	if len(v2.tgs) != 1 || *v2.tgs[0].VpcId != "vpc-2" {
+		t.Fatalf("expected only the target group of the other vpc to be kept, got: %v", v2.tgs)
+	}
+}
+
+func TestDeleteLoadBalancersNotOwned(t *testing.T) {
+	classic := &fakeELB{tags: make(map[string]map[string]string)}
+	classic.add("manual", "vpc-1", nil)
+	v2 := &fakeELBV2{tags: make(map[string]map[string]string)}
+
+	s := NewService(classic, v2)
+
+	err := s.DeleteLoadBalancers(context.TODO(), "test", &v1alpha1.VPC{ID: "vpc-1", Tags: map[string]string{"kubernetes.io/cluster/test": "owned"}})
+	if err == nil || ec2svc.IsNotReady(err) || !strings.Contains(err.Error(), "manual") {
+		t.Fatalf("expected the load balancer that isn't owned to be reported, got: %v", err)
+	}
+
+	// The load balancers of a vpc shared with other clusters don't keep the cluster from being deleted.
+	if err := s.DeleteLoadBalancers(context.TODO(), "test", &v1alpha1.VPC{ID: "vpc-1", Tags: map[string]string{"kubernetes.io/cluster/test": "shared"}}); err != nil {
+		t.Fatalf("failed to delete load balancers: %v", err)
+	}
+	if len(classic.lbs) != 1 {
+		t.Fatalf("expected the load balancer that isn't owned to be kept, got: %v", classic.lbs)
+	}
+}
@@ -0,0 +1,430 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package iam manages the IAM roles of the nodes of clusters: one for the control plane, which
+// runs the Kubernetes AWS cloud provider, and one with fewer permissions for the other nodes.
+// Each role is assigned to instances through an instance profile of the same name.
+package iam
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/iampolicy"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/logger"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+)
+
+// policyName is the name of the inline policy of the roles.
+const policyName = "cluster-api-provider-aws"
+
+// pathPrefix is the path of the roles and instance profiles managed by the service, which tells
+// them apart from the ones created by others.
+const pathPrefix = "/cluster-api-provider-aws/"
+
+// maxNameLength is the maximum length of the name of a role or an instance profile.
+const maxNameLength = 64
+
+// instanceProfileWaitTimeout is how long WaitForInstanceProfile waits for an instance profile
+// that doesn't exist yet, as a new one takes a few seconds until IAM returns it everywhere.
+var instanceProfileWaitTimeout = 30 * time.Second
+
+// instanceProfilePollInterval is how often WaitForInstanceProfile gets the instance profile.
+var instanceProfilePollInterval = 2 * time.Second
+
+// assumeRolePolicy lets EC2 instances assume the roles.
+var assumeRolePolicy = &iampolicy.Document{
+	Version: iampolicy.Version,
+	Statement: []iampolicy.Statement{{
+		Effect:    "Allow",
+		Principal: map[string]string{"Service": "ec2.amazonaws.com"},
+		Action:    []string{"sts:AssumeRole"},
+	}},
+}
+
+// InstanceProfileName returns the name of the instance profile of the control plane or of the
+// other nodes of the cluster. Its role has the same name.
+func InstanceProfileName(clusterName string, controlPlane bool) string {
+	if controlPlane {
+		return clusterName + "-control-plane"
+	}
+	return clusterName + "-nodes"
+}
+
+// Service manages the node roles of clusters.
+type Service struct {
+	IAM iamiface.IAMAPI
+
+	log     logr.Logger
+	manager string
+}
+
+// NewService returns a new service given the iam api client.
+func NewService(api iamiface.IAMAPI) *Service {
+	return &Service{
+		IAM: api,
+		log: logger.Default(),
+	}
+}
+
+// WithLogger returns a copy of the service that logs to the given logger.
+func (s *Service) WithLogger(log logr.Logger) *Service {
+	c := *s
+	c.log = log
+	return &c
+}
+
+// WithManager returns a copy of the service that keeps the roles it creates under a path of the
+// management cluster, see ec2.Service.WithManager. Roles under another path aren't modified.
+func (s *Service) WithManager(name string) *Service {
+	c := *s
+	c.manager = name
+	return &c
+}
+
+// path returns the path of the roles and instance profiles of the service.
+func (s *Service) path() string {
+	if s.manager == "" {
+		return pathPrefix
+	}
+	return pathPrefix + s.manager + "/"
+}
+
+// nodeRole is a role of the nodes of a cluster.
+type nodeRole struct {
+	name   string
+	policy *iampolicy.Document
+}
+
+func nodeRoles(clusterName string, ecrRepositories []string) []nodeRole {
+	return []nodeRole{
+		{name: InstanceProfileName(clusterName, true), policy: iampolicy.ControlPlane(clusterName)},
+		{name: InstanceProfileName(clusterName, false), policy: iampolicy.Node(ecrRepositories)},
+	}
+}
+
+// ReconcileNodeRoles creates the roles and instance profiles of the nodes of the cluster if they
+// don't exist. Their policies are brought back in line with the generated ones, and inline
+// policies added by others are removed, so that the nodes keep the least privileges. The other
+// nodes may only pull images from the given ECR repositories, or from every one if none are given.
+func (s *Service) ReconcileNodeRoles(ctx context.Context, clusterName string, ecrRepositories []string) error {
+	for _, r := range nodeRoles(clusterName, ecrRepositories) {
+		if len(r.name) > maxNameLength {
+			return errors.Errorf("failed to reconcile role %q: names of roles can't be longer than %d characters", r.name, maxNameLength)
+		}
+		if err := s.reconcileRole(ctx, r); err != nil {
+			return err
+		}
+		if err := s.reconcilePolicies(ctx, r); err != nil {
+			return err
+		}
+		if err := s.reconcileInstanceProfile(ctx, r.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) reconcileRole(ctx context.Context, r nodeRole) error {
+	out, err := s.IAM.GetRoleWithContext(ctx, &iam.GetRoleInput{RoleName: aws.String(r.name)})
+	switch {
+	case isNotFound(err):
+	case err != nil:
+		return errors.Wrapf(err, "failed to get role %q", r.name)
+	case aws.StringValue(out.Role.Path) != s.path():
+		return ec2svc.NewConflict(errors.Errorf("role %q exists but isn't managed by this controller, its path is %q", r.name, aws.StringValue(out.Role.Path)))
+	default:
+		return nil
+	}
+
+	doc, err := json.Marshal(assumeRolePolicy)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal assume role policy")
+	}
+	if _, err := s.IAM.CreateRoleWithContext(ctx, &iam.CreateRoleInput{
+		RoleName:                 aws.String(r.name),
+		Path:                     aws.String(s.path()),
+		AssumeRolePolicyDocument: aws.String(string(doc)),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to create role %q", r.name)
+	}
+
+	s.log.V(2).Info("Created role", "role", r.name)
+	return nil
+}
+
+// reconcilePolicies puts the policy of the role if it's missing or was changed, and deletes any
+// other inline policy.
+func (s *Service) reconcilePolicies(ctx context.Context, r nodeRole) error {
+	names, err := s.listRolePolicies(ctx, r.name)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, name := range names {
+		if name == policyName {
+			found = true
+			continue
+		}
+		if _, err := s.IAM.DeleteRolePolicyWithContext(ctx, &iam.DeleteRolePolicyInput{RoleName: aws.String(r.name), PolicyName: aws.String(name)}); err != nil && !isNotFound(err) {
+			return errors.Wrapf(err, "failed to delete policy %q of role %q", name, r.name)
+		}
+		s.log.V(2).Info("Deleted policy added to role", "role", r.name, "policy", name)
+	}
+
+	if found {
+		out, err := s.IAM.GetRolePolicyWithContext(ctx, &iam.GetRolePolicyInput{RoleName: aws.String(r.name), PolicyName: aws.String(policyName)})
+		if err != nil {
+			return errors.Wrapf(err, "failed to get policy of role %q", r.name)
+		}
+		equal, err := policyEqual(aws.StringValue(out.PolicyDocument), r.policy)
+		if err != nil {
+			return errors.Wrapf(err, "failed to compare policy of role %q", r.name)
+		}
+		if equal {
+			return nil
+		}
+	}
+
+	doc, err := json.Marshal(r.policy)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal policy of role %q", r.name)
+	}
+	if _, err := s.IAM.PutRolePolicyWithContext(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String(r.name),
+		PolicyName:     aws.String(policyName),
+		PolicyDocument: aws.String(string(doc)),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to put policy of role %q", r.name)
+	}
+
+	s.log.V(2).Info("Put policy of role", "role", r.name, "existed", found)
+	return nil
+}
+
+func (s *Service) listRolePolicies(ctx context.Context, roleName string) ([]string, error) {
+	var names []string
+	err := s.IAM.ListRolePoliciesPagesWithContext(ctx, &iam.ListRolePoliciesInput{RoleName: aws.String(roleName)}, func(out *iam.ListRolePoliciesOutput, _ bool) bool {
+		names = append(names, aws.StringValueSlice(out.PolicyNames)...)
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list policies of role %q", roleName)
+	}
+	return names, nil
+}
+
+// policyEqual returns whether the URL encoded policy document returned by IAM is the policy.
+func policyEqual(encoded string, policy *iampolicy.Document) (bool, error) {
+	decoded, err := url.QueryUnescape(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	var actual, desired interface{}
+	if err := json.Unmarshal([]byte(decoded), &actual); err != nil {
+		return false, err
+	}
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, &desired); err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(actual, desired), nil
+}
+
+// reconcileInstanceProfile creates the instance profile of the role if it doesn't exist and
+// makes the role its only role.
+func (s *Service) reconcileInstanceProfile(ctx context.Context, name string) error {
+	out, err := s.IAM.GetInstanceProfileWithContext(ctx, &iam.GetInstanceProfileInput{InstanceProfileName: aws.String(name)})
+	var profile *iam.InstanceProfile
+	switch {
+	case isNotFound(err):
+		created, err := s.IAM.CreateInstanceProfileWithContext(ctx, &iam.CreateInstanceProfileInput{
+			InstanceProfileName: aws.String(name),
+			Path:                aws.String(s.path()),
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to create instance profile %q", name)
+		}
+		s.log.V(2).Info("Created instance profile", "instance-profile", name)
+		profile = created.InstanceProfile
+	case err != nil:
+		return errors.Wrapf(err, "failed to get instance profile %q", name)
+	case aws.StringValue(out.InstanceProfile.Path) != s.path():
+		return ec2svc.NewConflict(errors.Errorf("instance profile %q exists but isn't managed by this controller, its path is %q", name, aws.StringValue(out.InstanceProfile.Path)))
+	default:
+		profile = out.InstanceProfile
+	}
+
+	found := false
+	for _, role := range profile.Roles {
+		if aws.StringValue(role.RoleName) == name {
+			found = true
+			continue
+		}
+		// An instance profile can only have one role.
+		if _, err := s.IAM.RemoveRoleFromInstanceProfileWithContext(ctx, &iam.RemoveRoleFromInstanceProfileInput{
+			InstanceProfileName: aws.String(name),
+			RoleName:            role.RoleName,
+		}); err != nil && !isNotFound(err) {
+			return errors.Wrapf(err, "failed to remove role %q from instance profile %q", aws.StringValue(role.RoleName), name)
+		}
+	}
+	if found {
+		return nil
+	}
+
+	if _, err := s.IAM.AddRoleToInstanceProfileWithContext(ctx, &iam.AddRoleToInstanceProfileInput{
+		InstanceProfileName: aws.String(name),
+		RoleName:            aws.String(name),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to add role to instance profile %q", name)
+	}
+	return nil
+}
+
+// DeleteNodeRoles deletes the instance profiles and the roles of the nodes of the cluster, if
+// they exist and are managed by the service.
+func (s *Service) DeleteNodeRoles(ctx context.Context, clusterName string) error {
+	for _, r := range nodeRoles(clusterName, nil) {
+		if err := s.deleteInstanceProfile(ctx, r.name); err != nil {
+			return err
+		}
+		if err := s.deleteRole(ctx, r.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) deleteInstanceProfile(ctx context.Context, name string) error {
+	out, err := s.IAM.GetInstanceProfileWithContext(ctx, &iam.GetInstanceProfileInput{InstanceProfileName: aws.String(name)})
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get instance profile %q", name)
+	}
+	if aws.StringValue(out.InstanceProfile.Path) != s.path() {
+		return nil
+	}
+
+	for _, role := range out.InstanceProfile.Roles {
+		if _, err := s.IAM.RemoveRoleFromInstanceProfileWithContext(ctx, &iam.RemoveRoleFromInstanceProfileInput{
+			InstanceProfileName: aws.String(name),
+			RoleName:            role.RoleName,
+		}); err != nil && !isNotFound(err) {
+			return errors.Wrapf(err, "failed to remove role %q from instance profile %q", aws.StringValue(role.RoleName), name)
+		}
+	}
+
+	if _, err := s.IAM.DeleteInstanceProfileWithContext(ctx, &iam.DeleteInstanceProfileInput{InstanceProfileName: aws.String(name)}); err != nil && !isNotFound(err) {
+		return errors.Wrapf(err, "failed to delete instance profile %q", name)
+	}
+
+	s.log.V(2).Info("Deleted instance profile", "instance-profile", name)
+	return nil
+}
+
+func (s *Service) deleteRole(ctx context.Context, name string) error {
+	out, err := s.IAM.GetRoleWithContext(ctx, &iam.GetRoleInput{RoleName: aws.String(name)})
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get role %q", name)
+	}
+	if aws.StringValue(out.Role.Path) != s.path() {
+		return nil
+	}
+
+	// Roles can only be deleted once they have no inline policies.
+	policies, err := s.listRolePolicies(ctx, name)
+	if err != nil {
+		return err
+	}
+	for _, policy := range policies {
+		if _, err := s.IAM.DeleteRolePolicyWithContext(ctx, &iam.DeleteRolePolicyInput{RoleName: aws.String(name), PolicyName: aws.String(policy)}); err != nil && !isNotFound(err) {
+			return errors.Wrapf(err, "failed to delete policy %q of role %q", policy, name)
+		}
+	}
+
+	if _, err := s.IAM.DeleteRoleWithContext(ctx, &iam.DeleteRoleInput{RoleName: aws.String(name)}); err != nil && !isNotFound(err) {
+		return errors.Wrapf(err, "failed to delete role %q", name)
+	}
+
+	s.log.V(2).Info("Deleted role", "role", name)
+	return nil
+}
+
+// WaitForInstanceProfile waits until the instance profile, given by its name or ARN, exists, up
+// to instanceProfileWaitTimeout. A NotFound error is returned if it still doesn't exist then.
+// Instance profiles the controller isn't allowed to get are assumed to exist, RunInstances
+// checks them.
+func (s *Service) WaitForInstanceProfile(ctx context.Context, ref string) error {
+	name := ref
+	if i := strings.LastIndex(ref, "/"); strings.HasPrefix(ref, "arn:") && i >= 0 {
+		name = ref[i+1:]
+	}
+
+	deadline := time.Now().Add(instanceProfileWaitTimeout)
+	for {
+		_, err := s.IAM.GetInstanceProfileWithContext(ctx, &iam.GetInstanceProfileInput{InstanceProfileName: aws.String(name)})
+		switch {
+		case err == nil:
+			return nil
+		case isAccessDenied(err):
+			s.log.V(2).Info("Not allowed to get instance profile, skipping check", "instance-profile", name)
+			return nil
+		case !isNotFound(err):
+			return errors.Wrapf(err, "failed to get instance profile %q", name)
+		case time.Now().After(deadline):
+			return ec2svc.NewNotFound(errors.Errorf("instance profile %q doesn't exist", name))
+		}
+
+		s.log.V(2).Info("Waiting for instance profile", "instance-profile", name)
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "failed to wait for instance profile %q", name)
+		case <-time.After(instanceProfilePollInterval):
+		}
+	}
+}
+
+func isNotFound(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code() == iam.ErrCodeNoSuchEntityException
+	}
+	return false
+}
+
+func isAccessDenied(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code() == "AccessDenied"
+	}
+	return false
+}
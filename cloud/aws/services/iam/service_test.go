@@ -0,0 +1,281 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iam
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+)
+
+// fakeIAM keeps roles and instance profiles in memory. Like IAM, policy documents are returned
+// URL encoded.
+type fakeIAM struct {
+	iamiface.IAMAPI
+
+	roles    map[string]*iam.Role
+	policies map[string]map[string]string
+	profiles map[string]*iam.InstanceProfile
+}
+
+func newFakeIAM() *fakeIAM {
+	return &fakeIAM{
+		roles:    make(map[string]*iam.Role),
+		policies: make(map[string]map[string]string),
+		profiles: make(map[string]*iam.InstanceProfile),
+	}
+}
+
+func noSuchEntity(name string) error {
+	return awserr.New(iam.ErrCodeNoSuchEntityException, name+" not found", nil)
+}
+
+func (f *fakeIAM) GetRoleWithContext(_ aws.Context, in *iam.GetRoleInput, _ ...request.Option) (*iam.GetRoleOutput, error) {
+	role, ok := f.roles[*in.RoleName]
+	if !ok {
+		return nil, noSuchEntity(*in.RoleName)
+	}
+	return &iam.GetRoleOutput{Role: role}, nil
+}
+
+func (f *fakeIAM) CreateRoleWithContext(_ aws.Context, in *iam.CreateRoleInput, _ ...request.Option) (*iam.CreateRoleOutput, error) {
+	if _, ok := f.roles[*in.RoleName]; ok {
+		return nil, awserr.New(iam.ErrCodeEntityAlreadyExistsException, "role exists", nil)
+	}
+	role := &iam.Role{RoleName: in.RoleName, Path: in.Path, AssumeRolePolicyDocument: in.AssumeRolePolicyDocument}
+	f.roles[*in.RoleName] = role
+	f.policies[*in.RoleName] = make(map[string]string)
+	return &iam.CreateRoleOutput{Role: role}, nil
+}
+
+func (f *fakeIAM) DeleteRoleWithContext(_ aws.Context, in *iam.DeleteRoleInput, _ ...request.Option) (*iam.DeleteRoleOutput, error) {
+	if _, ok := f.roles[*in.RoleName]; !ok {
+		return nil, noSuchEntity(*in.RoleName)
+	}
+	if len(f.policies[*in.RoleName]) > 0 {
+		return nil, awserr.New(iam.ErrCodeDeleteConflictException, "role has policies", nil)
+	}
+	delete(f.roles, *in.RoleName)
+	return &iam.DeleteRoleOutput{}, nil
+}
+
+func (f *fakeIAM) ListRolePoliciesPagesWithContext(_ aws.Context, in *iam.ListRolePoliciesInput, fn func(*iam.ListRolePoliciesOutput, bool) bool, _ ...request.Option) error {
+	policies, ok := f.policies[*in.RoleName]
+	if !ok {
+		return noSuchEntity(*in.RoleName)
+	}
+	names := make([]string, 0, len(policies))
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fn(&iam.ListRolePoliciesOutput{PolicyNames: aws.StringSlice(names)}, true)
+	return nil
+}
+
+func (f *fakeIAM) GetRolePolicyWithContext(_ aws.Context, in *iam.GetRolePolicyInput, _ ...request.Option) (*iam.GetRolePolicyOutput, error) {
+	doc, ok := f.policies[*in.RoleName][*in.PolicyName]
+	if !ok {
+		return nil, noSuchEntity(*in.PolicyName)
+	}
+	return &iam.GetRolePolicyOutput{RoleName: in.RoleName, PolicyName: in.PolicyName, PolicyDocument: aws.String(url.QueryEscape(doc))}, nil
+}
+
+func (f *fakeIAM) PutRolePolicyWithContext(_ aws.Context, in *iam.PutRolePolicyInput, _ ...request.Option) (*iam.PutRolePolicyOutput, error) {
+	if _, ok := f.policies[*in.RoleName]; !ok {
+		return nil, noSuchEntity(*in.RoleName)
+	}
+	f.policies[*in.RoleName][*in.PolicyName] = *in.PolicyDocument
+	return &iam.PutRolePolicyOutput{}, nil
+}
+
+func (f *fakeIAM) DeleteRolePolicyWithContext(_ aws.Context, in *iam.DeleteRolePolicyInput, _ ...request.Option) (*iam.DeleteRolePolicyOutput, error) {
+	if _, ok := f.policies[*in.RoleName][*in.PolicyName]; !ok {
+		return nil, noSuchEntity(*in.PolicyName)
+	}
+	delete(f.policies[*in.RoleName], *in.PolicyName)
+	return &iam.DeleteRolePolicyOutput{}, nil
+}
+
+func (f *fakeIAM) GetInstanceProfileWithContext(_ aws.Context, in *iam.GetInstanceProfileInput, _ ...request.Option) (*iam.GetInstanceProfileOutput, error) {
+	profile, ok := f.profiles[*in.InstanceProfileName]
+	if !ok {
+		return nil, noSuchEntity(*in.InstanceProfileName)
+	}
+	return &iam.GetInstanceProfileOutput{InstanceProfile: profile}, nil
+}
+
+func (f *fakeIAM) CreateInstanceProfileWithContext(_ aws.Context, in *iam.CreateInstanceProfileInput, _ ...request.Option) (*iam.CreateInstanceProfileOutput, error) {
+	if _, ok := f.profiles[*in.InstanceProfileName]; ok {
+		return nil, awserr.New(iam.ErrCodeEntityAlreadyExistsException, "instance profile exists", nil)
+	}
+	profile := &iam.InstanceProfile{InstanceProfileName: in.InstanceProfileName, Path: in.Path}
+	f.profiles[*in.InstanceProfileName] = profile
+	return &iam.CreateInstanceProfileOutput{InstanceProfile: profile}, nil
+}
+
+func (f *fakeIAM) DeleteInstanceProfileWithContext(_ aws.Context, in *iam.DeleteInstanceProfileInput, _ ...request.Option) (*iam.DeleteInstanceProfileOutput, error) {
+	profile, ok := f.profiles[*in.InstanceProfileName]
+	if !ok {
+		return nil, noSuchEntity(*in.InstanceProfileName)
+	}
+	if len(profile.Roles) > 0 {
+		return nil, awserr.New(iam.ErrCodeDeleteConflictException, "instance profile has roles", nil)
+	}
+	delete(f.profiles, *in.InstanceProfileName)
+	return &iam.DeleteInstanceProfileOutput{}, nil
+}
+
+func (f *fakeIAM) AddRoleToInstanceProfileWithContext(_ aws.Context, in *iam.AddRoleToInstanceProfileInput, _ ...request.Option) (*iam.AddRoleToInstanceProfileOutput, error) {
+	profile, ok := f.profiles[*in.InstanceProfileName]
+	if !ok {
+		return nil, noSuchEntity(*in.InstanceProfileName)
+	}
+	role, ok := f.roles[*in.RoleName]
+	if !ok {
+		return nil, noSuchEntity(*in.RoleName)
+	}
+	if len(profile.Roles) > 0 {
+		return nil, awserr.New(iam.ErrCodeLimitExceededException, "instance profile has a role", nil)
+	}
+	profile.Roles = []*iam.Role{role}
+	return &iam.AddRoleToInstanceProfileOutput{}, nil
+}
+
+func (f *fakeIAM) RemoveRoleFromInstanceProfileWithContext(_ aws.Context, in *iam.RemoveRoleFromInstanceProfileInput, _ ...request.Option) (*iam.RemoveRoleFromInstanceProfileOutput, error) {
+	profile, ok := f.profiles[*in.InstanceProfileName]
+	if !ok {
+		return nil, noSuchEntity(*in.InstanceProfileName)
+	}
+	for i, role := range profile.Roles {
+		if *role.RoleName == *in.RoleName {
+			profile.Roles = append(profile.Roles[:i], profile.Roles[i+1:]...)
+			return &iam.RemoveRoleFromInstanceProfileOutput{}, nil
+		}
+	}
+	return nil, noSuchEntity(*in.RoleName)
+}
+
+func TestReconcileNodeRoles(t *testing.T) {
+	f := newFakeIAM()
+	s := NewService(f).WithManager("mgmt")
+
+	if err := s.ReconcileNodeRoles(context.TODO(), "test", nil); err != nil {
+		t.Fatalf("failed to reconcile node roles: %v", err)
+	}
+
+	for _, name := range []string{"test-control-plane", "test-nodes"} {
+		role, ok := f.roles[name]
+		if !ok || aws.StringValue(role.Path) != "/cluster-api-provider-aws/mgmt/" {
+			t.Fatalf("expected role %q under the path of the manager, got: %v", name, role)
+		}
+		if _, ok := f.policies[name][policyName]; !ok || len(f.policies[name]) != 1 {
+			t.Fatalf("expected role %q to only have the generated policy, got: %v", name, f.policies[name])
+		}
+		profile := f.profiles[name]
+		if profile == nil || len(profile.Roles) != 1 || *profile.Roles[0].RoleName != name {
+			t.Fatalf("expected instance profile %q with its role, got: %v", name, profile)
+		}
+	}
+
+	// Policies changed or added by others are reverted.
+	f.policies["test-nodes"][policyName] = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["*"],"Resource":["*"]}]}`
+	f.policies["test-nodes"]["admin"] = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["*"],"Resource":["*"]}]}`
+	expected := f.policies["test-control-plane"][policyName]
+
+	if err := s.ReconcileNodeRoles(context.TODO(), "test", nil); err != nil {
+		t.Fatalf("failed to reconcile node roles: %v", err)
+	}
+	if _, ok := f.policies["test-nodes"]["admin"]; ok {
+		t.Fatalf("expected the added policy to be deleted")
+	}
+	if equal, err := policyEqual(url.QueryEscape(f.policies["test-nodes"][policyName]), nodeRoles("test", nil)[1].policy); err != nil || !equal {
+		t.Fatalf("expected the changed policy to be reverted, got: %s, %v", f.policies["test-nodes"][policyName], err)
+	}
+	if f.policies["test-control-plane"][policyName] != expected {
+		t.Fatalf("expected the unchanged policy to be kept")
+	}
+
+	if err := s.DeleteNodeRoles(context.TODO(), "test"); err != nil {
+		t.Fatalf("failed to delete node roles: %v", err)
+	}
+	if len(f.roles) != 0 || len(f.profiles) != 0 {
+		t.Fatalf("expected the roles and instance profiles to be deleted, got: %v, %v", f.roles, f.profiles)
+	}
+}
+
+func TestReconcileNodeRolesConflict(t *testing.T) {
+	f := newFakeIAM()
+	f.roles["test-control-plane"] = &iam.Role{RoleName: aws.String("test-control-plane"), Path: aws.String("/")}
+
+	s := NewService(f)
+	if err := s.ReconcileNodeRoles(context.TODO(), "test", nil); !ec2svc.IsConflict(err) {
+		t.Fatalf("expected a conflict for a role that isn't managed, got: %v", err)
+	}
+
+	// Roles that aren't managed are kept.
+	if err := s.DeleteNodeRoles(context.TODO(), "test"); err != nil {
+		t.Fatalf("failed to delete node roles: %v", err)
+	}
+	if _, ok := f.roles["test-control-plane"]; !ok {
+		t.Fatalf("expected the role that isn't managed to be kept")
+	}
+}
+
+// delayedIAM doesn't return instance profiles for the given number of calls, like IAM right
+// after they were created.
+type delayedIAM struct {
+	*fakeIAM
+
+	misses int
+}
+
+func (f *delayedIAM) GetInstanceProfileWithContext(ctx aws.Context, in *iam.GetInstanceProfileInput, opts ...request.Option) (*iam.GetInstanceProfileOutput, error) {
+	if f.misses > 0 {
+		f.misses--
+		return nil, noSuchEntity(*in.InstanceProfileName)
+	}
+	return f.fakeIAM.GetInstanceProfileWithContext(ctx, in, opts...)
+}
+
+func TestWaitForInstanceProfile(t *testing.T) {
+	defer func(timeout, interval time.Duration) {
+		instanceProfileWaitTimeout, instanceProfilePollInterval = timeout, interval
+	}(instanceProfileWaitTimeout, instanceProfilePollInterval)
+	instanceProfileWaitTimeout, instanceProfilePollInterval = time.Second, time.Millisecond
+
+	f := &delayedIAM{fakeIAM: newFakeIAM(), misses: 3}
+	f.profiles["test-nodes"] = &iam.InstanceProfile{InstanceProfileName: aws.String("test-nodes"), Path: aws.String("/")}
+	s := NewService(f)
+
+	if err := s.WaitForInstanceProfile(context.TODO(), "arn:aws:iam::123456789012:instance-profile/team/test-nodes"); err != nil {
+		t.Fatalf("expected to wait for the instance profile, got: %v", err)
+	}
+	if f.misses != 0 {
+		t.Fatalf("expected the instance profile to be got until it exists, %d misses left", f.misses)
+	}
+
+	if err := s.WaitForInstanceProfile(context.TODO(), "missing"); !ec2svc.IsNotFound(err) {
+		t.Fatalf("expected a not found error for a missing instance profile, got: %v", err)
+	}
+}
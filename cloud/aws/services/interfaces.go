@@ -0,0 +1,126 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package services defines the interfaces the actuators use to talk to AWS.
+package services
+
+import (
+	"context"
+
+	providerconfigv1 "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+	efssvc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/efs"
+	iamsvc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/iam"
+	pricingsvc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/pricing"
+	resourcegroupssvc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/resourcegroups"
+	route53svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/route53"
+	ssmsvc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ssm"
+	clusterv1 "sigs.k8s.io/cluster-api/pkg/apis/cluster/v1alpha1"
+)
+
+var _ EC2Interface = &ec2svc.Service{}
+var _ PricingInterface = &pricingsvc.Service{}
+var _ ResourceGroupsInterface = &resourcegroupssvc.Service{}
+var _ FileSystemInterface = &efssvc.Service{}
+var _ PrivateHostedZoneInterface = &route53svc.Service{}
+var _ NodeRolesInterface = &iamsvc.Service{}
+var _ InstanceProfilesInterface = &iamsvc.Service{}
+var _ InstanceShutdownInterface = &ssmsvc.Service{}
+
+// EC2Interface encapsulates the methods exposed by the ec2 service.
+type EC2Interface interface {
+	NetworkInterface
+	InstanceInterface
+	InventoryInterface
+}
+
+// NetworkInterface encapsulates the methods that reconcile the cluster network.
+type NetworkInterface interface {
+	ReconcileNetwork(ctx context.Context, clusterName string, spec *providerconfigv1.NetworkSpec, additionalTags map[string]string, network *providerconfigv1.Network) error
+	DeleteNetwork(ctx context.Context, clusterName string, network *providerconfigv1.Network) error
+	DeleteWorkloadResources(ctx context.Context, clusterName string, network *providerconfigv1.Network) error
+	ValidateClusterNetwork(spec *providerconfigv1.NetworkSpec, network *providerconfigv1.Network, clusterNetwork *clusterv1.ClusterNetworkingConfig) error
+	ReconcileFileSystemSecurityGroup(ctx context.Context, clusterName string, additionalTags map[string]string, network *providerconfigv1.Network) (string, error)
+}
+
+// InstanceInterface encapsulates the methods that manage ec2 instances.
+type InstanceInterface interface {
+	InstanceIfExists(ctx context.Context, instanceID *string) (*ec2svc.Instance, error)
+	CreateInstance(ctx context.Context, clusterName string, clientToken string, additionalTags map[string]string, machine *clusterv1.Machine, config *providerconfigv1.AWSMachineProviderConfig) (*ec2svc.Instance, error)
+	AdoptInstance(ctx context.Context, clusterName string, instanceID string, additionalTags map[string]string) (*ec2svc.Instance, error)
+	ReconcileInstanceTags(ctx context.Context, instance *ec2svc.Instance, additionalTags map[string]string) error
+	ReconcileRootVolume(ctx context.Context, instanceID string, size int64) error
+	TerminateInstance(ctx context.Context, instanceID *string) error
+	DeleteLaunchTemplates(ctx context.Context, clusterName string) error
+	ReconcileWarmPool(ctx context.Context, clusterName string, machine *clusterv1.Machine, config *providerconfigv1.AWSMachineProviderConfig) error
+	DeleteWarmPools(ctx context.Context, clusterName string) error
+	InstancesToHibernate(ctx context.Context, clusterName string, controlPlane bool) ([]string, error)
+	HibernateInstances(ctx context.Context, clusterName string, instanceIDs []string) error
+	ResumeInstances(ctx context.Context, clusterName string) error
+	ConsoleOutput(ctx context.Context, instanceID string) (string, error)
+	InstanceStatusChecks(ctx context.Context, instanceID string) (*ec2svc.StatusChecks, error)
+}
+
+// InventoryInterface encapsulates the methods that describe the resources of a cluster.
+type InventoryInterface interface {
+	DescribeClusterResources(ctx context.Context, clusterName string) (*ec2svc.ClusterResources, error)
+}
+
+// PricingInterface encapsulates the methods that estimate the cost of AWS resources.
+type PricingInterface interface {
+	EstimateHourlyCost(ctx context.Context, resources *ec2svc.ClusterResources) (float64, error)
+}
+
+// ResourceGroupsInterface encapsulates the methods that manage the resource group of a cluster.
+type ResourceGroupsInterface interface {
+	ReconcileResourceGroup(ctx context.Context, clusterName string, additionalTags map[string]string) error
+	DeleteResourceGroup(ctx context.Context, clusterName string) error
+}
+
+// FileSystemInterface encapsulates the methods that manage the file system of a cluster.
+type FileSystemInterface interface {
+	ReconcileFileSystem(ctx context.Context, clusterName string, spec *providerconfigv1.FileSystemSpec, additionalTags map[string]string, network *providerconfigv1.Network, securityGroupID string, status *providerconfigv1.FileSystem) error
+	DeleteFileSystem(ctx context.Context, clusterName string) error
+}
+
+// PrivateHostedZoneInterface encapsulates the methods that manage the private hosted zone of a cluster.
+type PrivateHostedZoneInterface interface {
+	ReconcilePrivateHostedZone(ctx context.Context, clusterName string, spec *providerconfigv1.PrivateHostedZoneSpec, additionalTags map[string]string, vpc *providerconfigv1.VPC, status *providerconfigv1.HostedZone) error
+	DeletePrivateHostedZone(ctx context.Context, clusterName string, spec *providerconfigv1.PrivateHostedZoneSpec, vpc *providerconfigv1.VPC, status *providerconfigv1.HostedZone) error
+}
+
+// NodeRolesInterface encapsulates the methods that manage the IAM roles of the nodes of a cluster.
+type NodeRolesInterface interface {
+	ReconcileNodeRoles(ctx context.Context, clusterName string, ecrRepositories []string) error
+	DeleteNodeRoles(ctx context.Context, clusterName string) error
+}
+
+// InstanceProfilesInterface encapsulates the methods that check the instance profiles of
+// instances before they are launched.
+type InstanceProfilesInterface interface {
+	WaitForInstanceProfile(ctx context.Context, ref string) error
+}
+
+// InstanceShutdownInterface encapsulates the methods that shut down the operating system of
+// instances before they are terminated.
+type InstanceShutdownInterface interface {
+	ShutdownInstance(ctx context.Context, instanceID string) error
+}
+
+// LoadBalancersInterface encapsulates the methods that delete the load balancers left in the
+// vpc of a cluster and manage the registrations of its hibernated instances.
+type LoadBalancersInterface interface {
+	DeleteLoadBalancers(ctx context.Context, clusterName string, vpc *providerconfigv1.VPC) error
+	DeregisterInstances(ctx context.Context, clusterName string, vpc *providerconfigv1.VPC, instanceIDs []string) ([]providerconfigv1.LoadBalancerRegistration, error)
+	RegisterInstances(ctx context.Context, clusterName string, registrations []providerconfigv1.LoadBalancerRegistration) error
+}
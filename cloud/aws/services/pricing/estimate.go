@@ -0,0 +1,86 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pricing
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+)
+
+// HoursPerMonth is the number of hours in a month that AWS uses for monthly prices.
+const HoursPerMonth = 730
+
+// EstimateHourlyCost returns the estimated on-demand cost per hour of the resources in US dollars.
+// Pending and running instances, NAT gateways, Elastic IP addresses that aren't associated and
+// the storage of EBS volumes are counted. Stopped instances only cost their volumes.
+func (s *Service) EstimateHourlyCost(ctx context.Context, resources *ec2svc.ClusterResources) (float64, error) {
+	var cost float64
+
+	for _, i := range resources.Instances {
+		switch aws.StringValue(i.State.Name) {
+		case ec2.InstanceStateNamePending, ec2.InstanceStateNameRunning:
+		default:
+			continue
+		}
+
+		price, err := s.price(ctx, "Hrs", termMatch(
+			"instanceType", aws.StringValue(i.InstanceType),
+			"operatingSystem", "Linux",
+			"tenancy", "Shared",
+			"preInstalledSw", "NA",
+			"licenseModel", "No License required",
+			"capacitystatus", "Used",
+		))
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to get price of instance type %q", aws.StringValue(i.InstanceType))
+		}
+		cost += price
+	}
+
+	if len(resources.NatGateways) > 0 {
+		price, err := s.price(ctx, "Hrs", termMatch("productFamily", "NAT Gateway"))
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to get price of nat gateways")
+		}
+		cost += price * float64(len(resources.NatGateways))
+	}
+
+	var idle int
+	for _, addr := range resources.Addresses {
+		if addr.AssociationId == nil {
+			idle++
+		}
+	}
+	if idle > 0 {
+		price, err := s.price(ctx, "Hrs", termMatch("productFamily", "IP Address", "group", "ElasticIP:Address"))
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to get price of elastic ip addresses")
+		}
+		cost += price * float64(idle)
+	}
+
+	for _, v := range resources.Volumes {
+		price, err := s.price(ctx, "GB-Mo", termMatch("productFamily", "Storage", "volumeApiName", aws.StringValue(v.VolumeType)))
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to get price of volume type %q", aws.StringValue(v.VolumeType))
+		}
+		cost += price * float64(aws.Int64Value(v.Size)) / HoursPerMonth
+	}
+
+	return cost, nil
+}
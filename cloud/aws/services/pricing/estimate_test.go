@@ -0,0 +1,133 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pricing
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/aws/aws-sdk-go/service/pricing/pricingiface"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+)
+
+// fakePricing serves a price list of a single product per filtered attribute value.
+type fakePricing struct {
+	pricingiface.PricingAPI
+
+	// prices are keyed by the value of the instanceType, volumeApiName or productFamily filter.
+	prices map[string]aws.JSONValue
+	calls  int
+}
+
+func onDemand(unit, usd string) aws.JSONValue {
+	return aws.JSONValue{
+		"terms": map[string]interface{}{
+			"OnDemand": map[string]interface{}{
+				"SKU.TERM": map[string]interface{}{
+					"priceDimensions": map[string]interface{}{
+						"SKU.TERM.RATE": map[string]interface{}{
+							"unit":         unit,
+							"pricePerUnit": map[string]interface{}{"USD": usd},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (f *fakePricing) GetProductsPagesWithContext(_ aws.Context, in *pricing.GetProductsInput, fn func(*pricing.GetProductsOutput, bool) bool, _ ...request.Option) error {
+	f.calls++
+
+	values := make(map[string]string)
+	for _, filter := range in.Filters {
+		values[aws.StringValue(filter.Field)] = aws.StringValue(filter.Value)
+	}
+	if values["location"] != "EU (Ireland)" {
+		fn(&pricing.GetProductsOutput{}, true)
+		return nil
+	}
+
+	out := &pricing.GetProductsOutput{}
+	for _, key := range []string{values["instanceType"], values["volumeApiName"], values["productFamily"]} {
+		if p, ok := f.prices[key]; ok {
+			out.PriceList = append(out.PriceList, p)
+			break
+		}
+	}
+	fn(out, true)
+	return nil
+}
+
+func TestEstimateHourlyCost(t *testing.T) {
+	f := &fakePricing{prices: map[string]aws.JSONValue{
+		"m5.large":    onDemand("Hrs", "0.1070000000"),
+		"gp2":         onDemand("GB-Mo", "0.1100000000"),
+		"NAT Gateway": onDemand("Hrs", "0.0480000000"),
+		"IP Address":  onDemand("Hrs", "0.0050000000"),
+	}}
+	s, err := NewService(f, "eu-west-1")
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+
+	resources := &ec2svc.ClusterResources{
+		Instances: []*ec2.Instance{
+			{InstanceType: aws.String("m5.large"), State: &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)}},
+			{InstanceType: aws.String("m5.large"), State: &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNamePending)}},
+			// Stopped instances only cost their volumes.
+			{InstanceType: aws.String("m5.large"), State: &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameStopped)}},
+		},
+		NatGateways: []*ec2.NatGateway{{}},
+		Addresses: []*ec2.Address{
+			{AssociationId: aws.String("eipassoc-1")},
+			{},
+		},
+		Volumes: []*ec2.Volume{
+			{VolumeType: aws.String("gp2"), Size: aws.Int64(73)},
+		},
+	}
+
+	cost, err := s.EstimateHourlyCost(context.TODO(), resources)
+	if err != nil {
+		t.Fatalf("failed to estimate cost: %v", err)
+	}
+	if expected := 2*0.107 + 0.048 + 0.005 + 73*0.11/HoursPerMonth; math.Abs(cost-expected) > 1e-9 {
+		t.Fatalf("expected a cost of %v, got: %v", expected, cost)
+	}
+
+	// Prices are cached.
+	calls := f.calls
+	if _, err := s.EstimateHourlyCost(context.TODO(), resources); err != nil {
+		t.Fatalf("failed to estimate cost: %v", err)
+	}
+	if f.calls != calls {
+		t.Fatalf("expected cached prices to be used, got %d more calls", f.calls-calls)
+	}
+
+	// Unknown products can't be estimated.
+	resources.Instances[0].InstanceType = aws.String("x9.huge")
+	if _, err := s.EstimateHourlyCost(context.TODO(), resources); err == nil {
+		t.Fatalf("expected an error for an instance type without price")
+	}
+
+	if _, err := NewService(f, "moon-east-1"); err == nil {
+		t.Fatalf("expected an error for an unknown region")
+	}
+}
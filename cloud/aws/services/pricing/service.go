@@ -0,0 +1,172 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pricing estimates the cost of AWS resources with the prices of the AWS Pricing API.
+package pricing
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/aws/aws-sdk-go/service/pricing/pricingiface"
+	"github.com/pkg/errors"
+)
+
+// priceTTL is how long prices are cached. They rarely change.
+const priceTTL = 24 * time.Hour
+
+// Service looks up the on-demand prices of EC2 products in a region.
+type Service struct {
+	Pricing pricingiface.PricingAPI
+
+	// location is the name of the region in the Pricing API, e.g. "US East (N. Virginia)".
+	location string
+
+	now    func() time.Time
+	mu     sync.Mutex
+	prices map[string]cachedPrice
+}
+
+type cachedPrice struct {
+	price   float64
+	expires time.Time
+}
+
+// NewService returns a new service for the prices of the given region. The Pricing API is only
+// served from a few regions, so the client usually is configured for a different one.
+func NewService(api pricingiface.PricingAPI, region string) (*Service, error) {
+	p, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region)
+	if !ok {
+		return nil, errors.Errorf("unknown region %q", region)
+	}
+	r, ok := p.Regions()[region]
+	if !ok {
+		return nil, errors.Errorf("unknown region %q", region)
+	}
+
+	return &Service{
+		Pricing:  api,
+		location: r.Description(),
+		now:      time.Now,
+		prices:   make(map[string]cachedPrice),
+	}, nil
+}
+
+// product is the part of a product of the price list that holds the on-demand prices.
+type product struct {
+	Terms struct {
+		OnDemand map[string]struct {
+			PriceDimensions map[string]struct {
+				Unit         string            `json:"unit"`
+				PricePerUnit map[string]string `json:"pricePerUnit"`
+			} `json:"priceDimensions"`
+		} `json:"OnDemand"`
+	} `json:"terms"`
+}
+
+// termMatch returns the filters that match the given attribute and value pairs exactly.
+func termMatch(attributesAndValues ...string) []*pricing.Filter {
+	var filters []*pricing.Filter
+	for i := 0; i+1 < len(attributesAndValues); i += 2 {
+		filters = append(filters, &pricing.Filter{
+			Type:  aws.String(pricing.FilterTypeTermMatch),
+			Field: aws.String(attributesAndValues[i]),
+			Value: aws.String(attributesAndValues[i+1]),
+		})
+	}
+	return filters
+}
+
+// price returns the highest on-demand price in US dollars per unit of the EC2 products in the
+// region that match the filters.
+func (s *Service) price(ctx context.Context, unit string, filters []*pricing.Filter) (float64, error) {
+	filters = append(termMatch("location", s.location), filters...)
+	key := unit + awsutil.Prettify(filters)
+
+	s.mu.Lock()
+	cached, ok := s.prices[key]
+	s.mu.Unlock()
+	if ok && s.now().Before(cached.expires) {
+		return cached.price, nil
+	}
+
+	var price float64
+	var found bool
+	var perr error
+	err := s.Pricing.GetProductsPagesWithContext(ctx, &pricing.GetProductsInput{
+		ServiceCode:   aws.String("AmazonEC2"),
+		FormatVersion: aws.String("aws_v1"),
+		Filters:       filters,
+	}, func(page *pricing.GetProductsOutput, lastPage bool) bool {
+		for _, item := range page.PriceList {
+			p, err := parseProduct(item)
+			if err != nil {
+				perr = err
+				return false
+			}
+
+			for _, term := range p.Terms.OnDemand {
+				for _, dim := range term.PriceDimensions {
+					usd, ok := dim.PricePerUnit["USD"]
+					if dim.Unit != unit || !ok {
+						continue
+					}
+					v, err := strconv.ParseFloat(usd, 64)
+					if err != nil {
+						perr = errors.Wrapf(err, "invalid price %q", usd)
+						return false
+					}
+					if !found || v > price {
+						price, found = v, true
+					}
+				}
+			}
+		}
+		return !lastPage
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get products")
+	}
+	if perr != nil {
+		return 0, perr
+	}
+	if !found {
+		return 0, errors.Errorf("no price per %s found for %s", unit, awsutil.Prettify(filters))
+	}
+
+	s.mu.Lock()
+	s.prices[key] = cachedPrice{price: price, expires: s.now().Add(priceTTL)}
+	s.mu.Unlock()
+
+	return price, nil
+}
+
+func parseProduct(item aws.JSONValue) (*product, error) {
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode product")
+	}
+
+	p := &product{}
+	if err := json.Unmarshal(raw, p); err != nil {
+		return nil, errors.Wrap(err, "failed to decode product")
+	}
+	return p, nil
+}
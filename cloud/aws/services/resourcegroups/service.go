@@ -0,0 +1,195 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package resourcegroups manages an AWS Resource Group per cluster, which lists the resources
+// tagged for the cluster in the AWS console.
+package resourcegroups
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/resourcegroups"
+	"github.com/aws/aws-sdk-go/service/resourcegroups/resourcegroupsiface"
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/logger"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+)
+
+// Service manages the resource groups of clusters.
+type Service struct {
+	ResourceGroups resourcegroupsiface.ResourceGroupsAPI
+
+	log logr.Logger
+}
+
+// NewService returns a new service given the resource groups api client.
+func NewService(api resourcegroupsiface.ResourceGroupsAPI) *Service {
+	return &Service{
+		ResourceGroups: api,
+		log:            logger.Default(),
+	}
+}
+
+// WithLogger returns a copy of the service that logs to the given logger.
+func (s *Service) WithLogger(log logr.Logger) *Service {
+	c := *s
+	c.log = log
+	return &c
+}
+
+// tagFilter is a tag filter of a resource query.
+type tagFilter struct {
+	Key    string   `json:"Key"`
+	Values []string `json:"Values"`
+}
+
+// tagQuery is the query of a resource group of type TAG_FILTERS_1_0.
+type tagQuery struct {
+	ResourceTypeFilters []string    `json:"ResourceTypeFilters"`
+	TagFilters          []tagFilter `json:"TagFilters"`
+}
+
+// groupName returns the name of the resource group of the cluster.
+func groupName(clusterName string) string {
+	return clusterName
+}
+
+// clusterQuery returns the query of all resources tagged for the cluster.
+func clusterQuery(clusterName string) (*resourcegroups.ResourceQuery, error) {
+	query, err := json.Marshal(tagQuery{
+		ResourceTypeFilters: []string{"AWS::AllSupported"},
+		TagFilters: []tagFilter{
+			{
+				Key:    ec2svc.TagNameKubernetesClusterPrefix + clusterName,
+				Values: []string{ec2svc.ResourceLifecycleOwned, ec2svc.ResourceLifecycleShared},
+			},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode resource query")
+	}
+
+	return &resourcegroups.ResourceQuery{
+		Type:  aws.String(resourcegroups.QueryTypeTagFilters10),
+		Query: aws.String(string(query)),
+	}, nil
+}
+
+// ReconcileResourceGroup creates the resource group of the cluster if it doesn't exist, and
+// updates its query if it was changed.
+func (s *Service) ReconcileResourceGroup(ctx context.Context, clusterName string, additionalTags map[string]string) error {
+	name := groupName(clusterName)
+	query, err := clusterQuery(clusterName)
+	if err != nil {
+		return err
+	}
+
+	out, err := s.ResourceGroups.GetGroupQueryWithContext(ctx, &resourcegroups.GetGroupQueryInput{GroupName: aws.String(name)})
+	if isNotFound(err) {
+		tags := map[string]*string{
+			ec2svc.TagNameKubernetesClusterPrefix + clusterName: aws.String(ec2svc.ResourceLifecycleOwned),
+		}
+		for k, v := range additionalTags {
+			tags[k] = aws.String(v)
+		}
+
+		if _, err := s.ResourceGroups.CreateGroupWithContext(ctx, &resourcegroups.CreateGroupInput{
+			Name:          aws.String(name),
+			Description:   aws.String("Resources of the Kubernetes cluster " + clusterName),
+			ResourceQuery: query,
+			Tags:          tags,
+		}); err != nil {
+			return errors.Wrapf(err, "failed to create resource group %q", name)
+		}
+
+		s.log.V(2).Info("Created resource group", "cluster", clusterName, "resource-group", name)
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get query of resource group %q", name)
+	}
+
+	if err := s.checkOwned(ctx, clusterName, name); err != nil {
+		return err
+	}
+
+	if out.GroupQuery != nil && out.GroupQuery.ResourceQuery != nil &&
+		aws.StringValue(out.GroupQuery.ResourceQuery.Type) == aws.StringValue(query.Type) &&
+		aws.StringValue(out.GroupQuery.ResourceQuery.Query) == aws.StringValue(query.Query) {
+		return nil
+	}
+
+	if _, err := s.ResourceGroups.UpdateGroupQueryWithContext(ctx, &resourcegroups.UpdateGroupQueryInput{
+		GroupName:     aws.String(name),
+		ResourceQuery: query,
+	}); err != nil {
+		return errors.Wrapf(err, "failed to update query of resource group %q", name)
+	}
+
+	s.log.V(2).Info("Updated resource group query", "cluster", clusterName, "resource-group", name)
+	return nil
+}
+
+// DeleteResourceGroup deletes the resource group of the cluster, if it exists.
+func (s *Service) DeleteResourceGroup(ctx context.Context, clusterName string) error {
+	name := groupName(clusterName)
+
+	err := s.checkOwned(ctx, clusterName, name)
+	if isNotFound(errors.Cause(err)) {
+		return nil
+	}
+	if ec2svc.IsConflict(err) {
+		s.log.Info("Leaving resource group that is not owned by the cluster", "cluster", clusterName, "resource-group", name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.ResourceGroups.DeleteGroupWithContext(ctx, &resourcegroups.DeleteGroupInput{GroupName: aws.String(name)}); err != nil && !isNotFound(err) {
+		return errors.Wrapf(err, "failed to delete resource group %q", name)
+	}
+
+	s.log.V(2).Info("Deleted resource group", "cluster", clusterName, "resource-group", name)
+	return nil
+}
+
+// checkOwned returns a conflict error if the resource group isn't owned by the cluster.
+// Groups of the same name that were created by someone else are left alone.
+func (s *Service) checkOwned(ctx context.Context, clusterName, name string) error {
+	group, err := s.ResourceGroups.GetGroupWithContext(ctx, &resourcegroups.GetGroupInput{GroupName: aws.String(name)})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get resource group %q", name)
+	}
+
+	tags, err := s.ResourceGroups.GetTagsWithContext(ctx, &resourcegroups.GetTagsInput{Arn: group.Group.GroupArn})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get tags of resource group %q", name)
+	}
+
+	if aws.StringValue(tags.Tags[ec2svc.TagNameKubernetesClusterPrefix+clusterName]) != ec2svc.ResourceLifecycleOwned {
+		return ec2svc.NewConflict(errors.Errorf("resource group %q is not owned by cluster %q", name, clusterName))
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code() == resourcegroups.ErrCodeNotFoundException
+	}
+	return false
+}
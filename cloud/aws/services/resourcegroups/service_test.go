@@ -0,0 +1,164 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resourcegroups
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/resourcegroups"
+	"github.com/aws/aws-sdk-go/service/resourcegroups/resourcegroupsiface"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+)
+
+type fakeGroup struct {
+	query *resourcegroups.ResourceQuery
+	tags  map[string]*string
+}
+
+// fakeResourceGroups keeps resource groups in memory, their ARN is their name.
+type fakeResourceGroups struct {
+	resourcegroupsiface.ResourceGroupsAPI
+
+	groups  map[string]*fakeGroup
+	updates int
+}
+
+func notFound(name string) error {
+	return awserr.New(resourcegroups.ErrCodeNotFoundException, "group "+name+" not found", nil)
+}
+
+func (f *fakeResourceGroups) CreateGroupWithContext(_ aws.Context, in *resourcegroups.CreateGroupInput, _ ...request.Option) (*resourcegroups.CreateGroupOutput, error) {
+	name := aws.StringValue(in.Name)
+	if _, ok := f.groups[name]; ok {
+		return nil, awserr.New(resourcegroups.ErrCodeBadRequestException, "group "+name+" exists", nil)
+	}
+	f.groups[name] = &fakeGroup{query: in.ResourceQuery, tags: in.Tags}
+	return &resourcegroups.CreateGroupOutput{}, nil
+}
+
+func (f *fakeResourceGroups) GetGroupWithContext(_ aws.Context, in *resourcegroups.GetGroupInput, _ ...request.Option) (*resourcegroups.GetGroupOutput, error) {
+	if _, ok := f.groups[aws.StringValue(in.GroupName)]; !ok {
+		return nil, notFound(aws.StringValue(in.GroupName))
+	}
+	return &resourcegroups.GetGroupOutput{Group: &resourcegroups.Group{Name: in.GroupName, GroupArn: in.GroupName}}, nil
+}
+
+func (f *fakeResourceGroups) GetGroupQueryWithContext(_ aws.Context, in *resourcegroups.GetGroupQueryInput, _ ...request.Option) (*resourcegroups.GetGroupQueryOutput, error) {
+	g, ok := f.groups[aws.StringValue(in.GroupName)]
+	if !ok {
+		return nil, notFound(aws.StringValue(in.GroupName))
+	}
+	return &resourcegroups.GetGroupQueryOutput{GroupQuery: &resourcegroups.GroupQuery{GroupName: in.GroupName, ResourceQuery: g.query}}, nil
+}
+
+func (f *fakeResourceGroups) GetTagsWithContext(_ aws.Context, in *resourcegroups.GetTagsInput, _ ...request.Option) (*resourcegroups.GetTagsOutput, error) {
+	g, ok := f.groups[aws.StringValue(in.Arn)]
+	if !ok {
+		return nil, notFound(aws.StringValue(in.Arn))
+	}
+	return &resourcegroups.GetTagsOutput{Arn: in.Arn, Tags: g.tags}, nil
+}
+
+func (f *fakeResourceGroups) UpdateGroupQueryWithContext(_ aws.Context, in *resourcegroups.UpdateGroupQueryInput, _ ...request.Option) (*resourcegroups.UpdateGroupQueryOutput, error) {
+	g, ok := f.groups[aws.StringValue(in.GroupName)]
+	if !ok {
+		return nil, notFound(aws.StringValue(in.GroupName))
+	}
+	g.query = in.ResourceQuery
+	f.updates++
+	return &resourcegroups.UpdateGroupQueryOutput{}, nil
+}
+
+func (f *fakeResourceGroups) DeleteGroupWithContext(_ aws.Context, in *resourcegroups.DeleteGroupInput, _ ...request.Option) (*resourcegroups.DeleteGroupOutput, error) {
+	if _, ok := f.groups[aws.StringValue(in.GroupName)]; !ok {
+		return nil, notFound(aws.StringValue(in.GroupName))
+	}
+	delete(f.groups, aws.StringValue(in.GroupName))
+	return &resourcegroups.DeleteGroupOutput{}, nil
+}
+
+func TestResourceGroup(t *testing.T) {
+	f := &fakeResourceGroups{groups: make(map[string]*fakeGroup)}
+	s := NewService(f)
+
+	if err := s.ReconcileResourceGroup(context.TODO(), "test-cluster", map[string]string{"owner": "team-a"}); err != nil {
+		t.Fatalf("failed to reconcile resource group: %v", err)
+	}
+	g, ok := f.groups["test-cluster"]
+	if !ok {
+		t.Fatalf("expected a resource group to be created, got: %v", f.groups)
+	}
+	if aws.StringValue(g.tags["owner"]) != "team-a" || aws.StringValue(g.tags["kubernetes.io/cluster/test-cluster"]) != ec2svc.ResourceLifecycleOwned {
+		t.Fatalf("expected the group to be tagged as owned with the additional tags, got: %v", aws.StringValueMap(g.tags))
+	}
+	if !strings.Contains(aws.StringValue(g.query.Query), `"Key":"kubernetes.io/cluster/test-cluster"`) {
+		t.Fatalf("expected a query of the cluster tag, got: %s", aws.StringValue(g.query.Query))
+	}
+
+	// An up to date group isn't changed.
+	if err := s.ReconcileResourceGroup(context.TODO(), "test-cluster", nil); err != nil {
+		t.Fatalf("failed to reconcile resource group: %v", err)
+	}
+	if f.updates != 0 {
+		t.Fatalf("expected no updates, got: %d", f.updates)
+	}
+
+	// A changed query is restored.
+	g.query = &resourcegroups.ResourceQuery{Type: aws.String(resourcegroups.QueryTypeTagFilters10), Query: aws.String("{}")}
+	if err := s.ReconcileResourceGroup(context.TODO(), "test-cluster", nil); err != nil {
+		t.Fatalf("failed to reconcile resource group: %v", err)
+	}
+	if f.updates != 1 || aws.StringValue(g.query.Query) == "{}" {
+		t.Fatalf("expected the query to be updated, got: %s", aws.StringValue(g.query.Query))
+	}
+
+	if err := s.DeleteResourceGroup(context.TODO(), "test-cluster"); err != nil {
+		t.Fatalf("failed to delete resource group: %v", err)
+	}
+	if _, ok := f.groups["test-cluster"]; ok {
+		t.Fatalf("expected the resource group to be deleted")
+	}
+	if err := s.DeleteResourceGroup(context.TODO(), "test-cluster"); err != nil {
+		t.Fatalf("expected deleting a missing resource group to succeed, got: %v", err)
+	}
+}
+
+func TestResourceGroupNotOwned(t *testing.T) {
+	f := &fakeResourceGroups{groups: map[string]*fakeGroup{
+		"test-cluster": {
+			query: &resourcegroups.ResourceQuery{Type: aws.String(resourcegroups.QueryTypeTagFilters10), Query: aws.String("{}")},
+			tags:  map[string]*string{"owner": aws.String("someone-else")},
+		},
+	}}
+	s := NewService(f)
+
+	if err := s.ReconcileResourceGroup(context.TODO(), "test-cluster", nil); !ec2svc.IsConflict(err) {
+		t.Fatalf("expected a conflict, got: %v", err)
+	}
+	if f.updates != 0 {
+		t.Fatalf("expected the group not to be updated")
+	}
+
+	if err := s.DeleteResourceGroup(context.TODO(), "test-cluster"); err != nil {
+		t.Fatalf("failed to delete resource group: %v", err)
+	}
+	if _, ok := f.groups["test-cluster"]; !ok {
+		t.Fatalf("expected a group not owned by the cluster to be left alone")
+	}
+}
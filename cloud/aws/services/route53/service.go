@@ -0,0 +1,315 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package route53 manages a Route 53 private hosted zone per cluster, which is attached to the
+// vpc of the cluster for its internal service discovery.
+package route53
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/logger"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+)
+
+// hostedZoneIDPrefix is the prefix of the ids of hosted zones returned by Route 53, which the
+// tagging calls don't accept.
+const hostedZoneIDPrefix = "/hostedzone/"
+
+// Service manages the private hosted zones of clusters.
+type Service struct {
+	Route53 route53iface.Route53API
+
+	region string
+	log    logr.Logger
+}
+
+// NewService returns a new service given the route53 api client and the region of the vpcs
+// the zones are attached to.
+func NewService(api route53iface.Route53API, region string) *Service {
+	return &Service{
+		Route53: api,
+		region:  region,
+		log:     logger.Default(),
+	}
+}
+
+// WithLogger returns a copy of the service that logs to the given logger.
+func (s *Service) WithLogger(log logr.Logger) *Service {
+	c := *s
+	c.log = log
+	return &c
+}
+
+// zoneName returns the domain name of the private hosted zone of the cluster, without the
+// trailing dot.
+func zoneName(clusterName string, spec *v1alpha1.PrivateHostedZoneSpec) string {
+	if spec != nil && spec.Name != "" {
+		return strings.TrimSuffix(spec.Name, ".")
+	}
+	return clusterName + ".internal"
+}
+
+// callerReference returns the caller reference of the private hosted zone of the cluster, which
+// it's found by. Route 53 doesn't accept a caller reference again once its zone was deleted,
+// the vpc of a cluster that is created again is another one.
+func callerReference(clusterName string, vpc *v1alpha1.VPC) string {
+	return ec2svc.ClientToken(clusterName, "private-hosted-zone", vpc.ID)
+}
+
+// ReconcilePrivateHostedZone creates the private hosted zone of the cluster, attached to its vpc,
+// if it doesn't exist, and records its id and name in the status.
+func (s *Service) ReconcilePrivateHostedZone(ctx context.Context, clusterName string, spec *v1alpha1.PrivateHostedZoneSpec, additionalTags map[string]string, vpc *v1alpha1.VPC, status *v1alpha1.HostedZone) error {
+	name := zoneName(clusterName, spec)
+
+	zone, err := s.describeHostedZone(ctx, clusterName, name, vpc, status)
+	if err != nil {
+		return err
+	}
+	if zone == nil {
+		if zone, err = s.createHostedZone(ctx, clusterName, name, vpc); err != nil {
+			return err
+		}
+	} else if zone.name != name {
+		return ec2svc.NewInvalidConfiguration(errors.Errorf("private hosted zone %q of cluster %q can't be renamed to %q", zone.name, clusterName, name))
+	}
+
+	status.ID = zone.id
+	status.Name = zone.name
+
+	if !zone.vpcs[vpc.ID] {
+		_, err := s.Route53.AssociateVPCWithHostedZoneWithContext(ctx, &route53.AssociateVPCWithHostedZoneInput{
+			HostedZoneId: aws.String(zone.id),
+			VPC:          &route53.VPC{VPCId: aws.String(vpc.ID), VPCRegion: aws.String(s.region)},
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to associate vpc %q with private hosted zone %q", vpc.ID, zone.id)
+		}
+		s.log.V(2).Info("Associated vpc with private hosted zone", "cluster", clusterName, "hosted-zone-id", zone.id, "vpc-id", vpc.ID)
+	}
+
+	return s.reconcileTags(ctx, clusterName, zone.id, additionalTags)
+}
+
+// hostedZone is a private hosted zone and the vpcs it's attached to.
+type hostedZone struct {
+	id   string
+	name string
+	vpcs map[string]bool
+}
+
+func (s *Service) createHostedZone(ctx context.Context, clusterName string, name string, vpc *v1alpha1.VPC) (*hostedZone, error) {
+	out, err := s.Route53.CreateHostedZoneWithContext(ctx, &route53.CreateHostedZoneInput{
+		Name:            aws.String(name),
+		CallerReference: aws.String(callerReference(clusterName, vpc)),
+		HostedZoneConfig: &route53.HostedZoneConfig{
+			PrivateZone: aws.Bool(true),
+			Comment:     aws.String("Private hosted zone of cluster " + clusterName),
+		},
+		VPC: &route53.VPC{VPCId: aws.String(vpc.ID), VPCRegion: aws.String(s.region)},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create private hosted zone %q of cluster %q", name, clusterName)
+	}
+
+	id := strings.TrimPrefix(aws.StringValue(out.HostedZone.Id), hostedZoneIDPrefix)
+	s.log.V(2).Info("Created private hosted zone", "cluster", clusterName, "hosted-zone-id", id, "name", name)
+	return &hostedZone{id: id, name: name, vpcs: map[string]bool{vpc.ID: true}}, nil
+}
+
+// describeHostedZone returns the private hosted zone of the cluster, or nil if it doesn't exist.
+// The zone recorded in the status is looked up by its id, otherwise it's found by its name and
+// caller reference, in case the status couldn't be stored.
+func (s *Service) describeHostedZone(ctx context.Context, clusterName string, name string, vpc *v1alpha1.VPC, status *v1alpha1.HostedZone) (*hostedZone, error) {
+	id := ""
+	if status != nil {
+		id = status.ID
+	}
+	if id == "" {
+		var err error
+		if id, err = s.findHostedZone(ctx, clusterName, name, vpc); err != nil || id == "" {
+			return nil, err
+		}
+	}
+
+	out, err := s.Route53.GetHostedZoneWithContext(ctx, &route53.GetHostedZoneInput{Id: aws.String(id)})
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get private hosted zone %q of cluster %q", id, clusterName)
+	}
+
+	zone := &hostedZone{
+		id:   strings.TrimPrefix(aws.StringValue(out.HostedZone.Id), hostedZoneIDPrefix),
+		name: strings.TrimSuffix(aws.StringValue(out.HostedZone.Name), "."),
+		vpcs: make(map[string]bool),
+	}
+	for _, v := range out.VPCs {
+		zone.vpcs[aws.StringValue(v.VPCId)] = true
+	}
+	return zone, nil
+}
+
+// findHostedZone returns the id of the zone of the given name with the caller reference of the
+// cluster, or an empty string if there's none. Zones are listed by name, several zones may have
+// the same name.
+func (s *Service) findHostedZone(ctx context.Context, clusterName string, name string, vpc *v1alpha1.VPC) (string, error) {
+	ref := callerReference(clusterName, vpc)
+	input := &route53.ListHostedZonesByNameInput{DNSName: aws.String(name)}
+	for {
+		out, err := s.Route53.ListHostedZonesByNameWithContext(ctx, input)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to list hosted zones named %q", name)
+		}
+
+		for _, zone := range out.HostedZones {
+			if strings.TrimSuffix(aws.StringValue(zone.Name), ".") != name {
+				return "", nil
+			}
+			if aws.StringValue(zone.CallerReference) == ref {
+				return strings.TrimPrefix(aws.StringValue(zone.Id), hostedZoneIDPrefix), nil
+			}
+		}
+
+		if !aws.BoolValue(out.IsTruncated) {
+			return "", nil
+		}
+		input = &route53.ListHostedZonesByNameInput{DNSName: out.NextDNSName, HostedZoneId: out.NextHostedZoneId}
+	}
+}
+
+// reconcileTags sets the cluster tag, the name tag and the additional tags on the zone.
+// Tags that are no longer desired are left in place.
+func (s *Service) reconcileTags(ctx context.Context, clusterName string, id string, additionalTags map[string]string) error {
+	desired := map[string]string{
+		ec2svc.TagNameKubernetesClusterPrefix + clusterName: ec2svc.ResourceLifecycleOwned,
+		"Name": clusterName,
+	}
+	for k, v := range additionalTags {
+		desired[k] = v
+	}
+
+	out, err := s.Route53.ListTagsForResourceWithContext(ctx, &route53.ListTagsForResourceInput{
+		ResourceId:   aws.String(id),
+		ResourceType: aws.String(route53.TagResourceTypeHostedzone),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to list tags of private hosted zone %q", id)
+	}
+	if out.ResourceTagSet != nil {
+		for _, tag := range out.ResourceTagSet.Tags {
+			if v, ok := desired[aws.StringValue(tag.Key)]; ok && v == aws.StringValue(tag.Value) {
+				delete(desired, aws.StringValue(tag.Key))
+			}
+		}
+	}
+	if len(desired) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(desired))
+	for k := range desired {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tags := make([]*route53.Tag, 0, len(keys))
+	for _, k := range keys {
+		tags = append(tags, &route53.Tag{Key: aws.String(k), Value: aws.String(desired[k])})
+	}
+
+	_, err = s.Route53.ChangeTagsForResourceWithContext(ctx, &route53.ChangeTagsForResourceInput{
+		ResourceId:   aws.String(id),
+		ResourceType: aws.String(route53.TagResourceTypeHostedzone),
+		AddTags:      tags,
+	})
+	return errors.Wrapf(err, "failed to tag private hosted zone %q", id)
+}
+
+// DeletePrivateHostedZone deletes the records of the private hosted zone of the cluster and then
+// the zone itself, if it exists. The zone is found like by ReconcilePrivateHostedZone.
+func (s *Service) DeletePrivateHostedZone(ctx context.Context, clusterName string, spec *v1alpha1.PrivateHostedZoneSpec, vpc *v1alpha1.VPC, status *v1alpha1.HostedZone) error {
+	if spec == nil && (status == nil || status.ID == "") {
+		return nil
+	}
+
+	zone, err := s.describeHostedZone(ctx, clusterName, zoneName(clusterName, spec), vpc, status)
+	if err != nil || zone == nil {
+		return err
+	}
+
+	if err := s.deleteRecords(ctx, zone); err != nil {
+		return err
+	}
+
+	_, err = s.Route53.DeleteHostedZoneWithContext(ctx, &route53.DeleteHostedZoneInput{Id: aws.String(zone.id)})
+	if err != nil && !isNotFound(err) {
+		return errors.Wrapf(err, "failed to delete private hosted zone %q of cluster %q", zone.id, clusterName)
+	}
+
+	s.log.V(2).Info("Deleted private hosted zone", "cluster", clusterName, "hosted-zone-id", zone.id, "name", zone.name)
+	return nil
+}
+
+// deleteRecords deletes the records of the zone but the SOA and NS records of its apex, which
+// Route 53 deletes together with the zone.
+func (s *Service) deleteRecords(ctx context.Context, zone *hostedZone) error {
+	var changes []*route53.Change
+	err := s.Route53.ListResourceRecordSetsPagesWithContext(ctx, &route53.ListResourceRecordSetsInput{HostedZoneId: aws.String(zone.id)},
+		func(out *route53.ListResourceRecordSetsOutput, lastPage bool) bool {
+			for _, rs := range out.ResourceRecordSets {
+				switch aws.StringValue(rs.Type) {
+				case route53.RRTypeSoa, route53.RRTypeNs:
+					if strings.TrimSuffix(aws.StringValue(rs.Name), ".") == zone.name {
+						continue
+					}
+				}
+				changes = append(changes, &route53.Change{Action: aws.String(route53.ChangeActionDelete), ResourceRecordSet: rs})
+			}
+			return true
+		})
+	if err != nil {
+		return errors.Wrapf(err, "failed to list records of private hosted zone %q", zone.id)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	_, err = s.Route53.ChangeResourceRecordSetsWithContext(ctx, &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(zone.id),
+		ChangeBatch:  &route53.ChangeBatch{Changes: changes},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete records of private hosted zone %q", zone.id)
+	}
+
+	s.log.V(2).Info("Deleted records of private hosted zone", "hosted-zone-id", zone.id, "records", len(changes))
+	return nil
+}
+
+func isNotFound(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code() == route53.ErrCodeNoSuchHostedZone
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package route53
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+)
+
+// fakeRoute53 keeps hosted zones in memory. Like Route 53, ids are returned with the
+// /hostedzone/ prefix and names with a trailing dot, and zones with records other than
+// the SOA and NS records of their apex can't be deleted.
+type fakeRoute53 struct {
+	route53iface.Route53API
+
+	ids     int
+	zones   []*route53.GetHostedZoneOutput
+	refs    map[string]string
+	records map[string][]*route53.ResourceRecordSet
+	tags    map[string]map[string]string
+}
+
+func newFakeRoute53() *fakeRoute53 {
+	return &fakeRoute53{
+		refs:    make(map[string]string),
+		records: make(map[string][]*route53.ResourceRecordSet),
+		tags:    make(map[string]map[string]string),
+	}
+}
+
+func (f *fakeRoute53) zone(id string) *route53.GetHostedZoneOutput {
+	id = strings.TrimPrefix(id, hostedZoneIDPrefix)
+	for _, z := range f.zones {
+		if strings.TrimPrefix(aws.StringValue(z.HostedZone.Id), hostedZoneIDPrefix) == id {
+			return z
+		}
+	}
+	return nil
+}
+
+func (f *fakeRoute53) CreateHostedZoneWithContext(_ aws.Context, in *route53.CreateHostedZoneInput, _ ...request.Option) (*route53.CreateHostedZoneOutput, error) {
+	for _, ref := range f.refs {
+		if ref == aws.StringValue(in.CallerReference) {
+			return nil, awserr.New(route53.ErrCodeHostedZoneAlreadyExists, "caller reference used", nil)
+		}
+	}
+
+	f.ids++
+	id := fmt.Sprintf("Z%08X", f.ids)
+	name := aws.StringValue(in.Name) + "."
+	zone := &route53.HostedZone{
+		Id:              aws.String(hostedZoneIDPrefix + id),
+		Name:            aws.String(name),
+		CallerReference: in.CallerReference,
+		Config:          in.HostedZoneConfig,
+	}
+	f.zones = append(f.zones, &route53.GetHostedZoneOutput{HostedZone: zone, VPCs: []*route53.VPC{in.VPC}})
+	f.refs[id] = aws.StringValue(in.CallerReference)
+	f.records[id] = []*route53.ResourceRecordSet{
+		{Name: aws.String(name), Type: aws.String(route53.RRTypeSoa)},
+		{Name: aws.String(name), Type: aws.String(route53.RRTypeNs)},
+	}
+	f.tags[id] = make(map[string]string)
+	return &route53.CreateHostedZoneOutput{HostedZone: zone, VPC: in.VPC}, nil
+}
+
+func (f *fakeRoute53) GetHostedZoneWithContext(_ aws.Context, in *route53.GetHostedZoneInput, _ ...request.Option) (*route53.GetHostedZoneOutput, error) {
+	z := f.zone(aws.StringValue(in.Id))
+	if z == nil {
+		return nil, awserr.New(route53.ErrCodeNoSuchHostedZone, "no such hosted zone", nil)
+	}
+	c := *z
+	return &c, nil
+}
+
+func (f *fakeRoute53) ListHostedZonesByNameWithContext(_ aws.Context, in *route53.ListHostedZonesByNameInput, _ ...request.Option) (*route53.ListHostedZonesByNameOutput, error) {
+	out := &route53.ListHostedZonesByNameOutput{}
+	for _, z := range f.zones {
+		if aws.StringValue(z.HostedZone.Name) >= aws.StringValue(in.DNSName) {
+			out.HostedZones = append(out.HostedZones, z.HostedZone)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeRoute53) AssociateVPCWithHostedZoneWithContext(_ aws.Context, in *route53.AssociateVPCWithHostedZoneInput, _ ...request.Option) (*route53.AssociateVPCWithHostedZoneOutput, error) {
+	z := f.zone(aws.StringValue(in.HostedZoneId))
+	if z == nil {
+		return nil, awserr.New(route53.ErrCodeNoSuchHostedZone, "no such hosted zone", nil)
+	}
+	z.VPCs = append(z.VPCs, in.VPC)
+	return &route53.AssociateVPCWithHostedZoneOutput{}, nil
+}
+
+func (f *fakeRoute53) ListTagsForResourceWithContext(_ aws.Context, in *route53.ListTagsForResourceInput, _ ...request.Option) (*route53.ListTagsForResourceOutput, error) {
+	set := &route53.ResourceTagSet{ResourceId: in.ResourceId, ResourceType: in.ResourceType}
+	for k, v := range f.tags[aws.StringValue(in.ResourceId)] {
+		set.Tags = append(set.Tags, &route53.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return &route53.ListTagsForResourceOutput{ResourceTagSet: set}, nil
+}
+
+func (f *fakeRoute53) ChangeTagsForResourceWithContext(_ aws.Context, in *route53.ChangeTagsForResourceInput, _ ...request.Option) (*route53.ChangeTagsForResourceOutput, error) {
+	tags, ok := f.tags[aws.StringValue(in.ResourceId)]
+	if !ok {
+		return nil, awserr.New(route53.ErrCodeNoSuchHostedZone, "no such hosted zone", nil)
+	}
+	for _, tag := range in.AddTags {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return &route53.ChangeTagsForResourceOutput{}, nil
+}
+
+func (f *fakeRoute53) ListResourceRecordSetsPagesWithContext(_ aws.Context, in *route53.ListResourceRecordSetsInput, fn func(*route53.ListResourceRecordSetsOutput, bool) bool, _ ...request.Option) error {
+	fn(&route53.ListResourceRecordSetsOutput{ResourceRecordSets: f.records[aws.StringValue(in.HostedZoneId)]}, true)
+	return nil
+}
+
+func (f *fakeRoute53) ChangeResourceRecordSetsWithContext(_ aws.Context, in *route53.ChangeResourceRecordSetsInput, _ ...request.Option) (*route53.ChangeResourceRecordSetsOutput, error) {
+	id := aws.StringValue(in.HostedZoneId)
+	for _, change := range in.ChangeBatch.Changes {
+		var remaining []*route53.ResourceRecordSet
+		for _, rs := range f.records[id] {
+			if rs != change.ResourceRecordSet {
+				remaining = append(remaining, rs)
+			}
+		}
+		f.records[id] = remaining
+	}
+	return &route53.ChangeResourceRecordSetsOutput{}, nil
+}
+
+func (f *fakeRoute53) DeleteHostedZoneWithContext(_ aws.Context, in *route53.DeleteHostedZoneInput, _ ...request.Option) (*route53.DeleteHostedZoneOutput, error) {
+	id := aws.StringValue(in.Id)
+	if len(f.records[id]) > 2 {
+		return nil, awserr.New(route53.ErrCodeHostedZoneNotEmpty, "hosted zone has records", nil)
+	}
+	for i, z := range f.zones {
+		if z == f.zone(id) {
+			f.zones = append(f.zones[:i], f.zones[i+1:]...)
+			return &route53.DeleteHostedZoneOutput{}, nil
+		}
+	}
+	return nil, awserr.New(route53.ErrCodeNoSuchHostedZone, "no such hosted zone", nil)
+}
+
+func TestReconcilePrivateHostedZone(t *testing.T) {
+	f := newFakeRoute53()
+	s := NewService(f, "us-east-1")
+
+	spec := &v1alpha1.PrivateHostedZoneSpec{}
+	vpc := &v1alpha1.VPC{ID: "vpc-1"}
+	status := &v1alpha1.HostedZone{}
+
+	for i := 0; i < 2; i++ {
+		if err := s.ReconcilePrivateHostedZone(context.TODO(), "test-cluster", spec, map[string]string{"team": "ml"}, vpc, status); err != nil {
+			t.Fatalf("failed to reconcile private hosted zone: %v", err)
+		}
+	}
+
+	if len(f.zones) != 1 {
+		t.Fatalf("expected a single hosted zone, got: %v", f.zones)
+	}
+	zone := f.zones[0]
+	if status.Name != "test-cluster.internal" || hostedZoneIDPrefix+status.ID != aws.StringValue(zone.HostedZone.Id) {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if !aws.BoolValue(zone.HostedZone.Config.PrivateZone) || len(zone.VPCs) != 1 || aws.StringValue(zone.VPCs[0].VPCRegion) != "us-east-1" {
+		t.Fatalf("expected a private zone attached to the vpc, got: %v", zone)
+	}
+
+	tags := f.tags[status.ID]
+	if tags["kubernetes.io/cluster/test-cluster"] != ec2svc.ResourceLifecycleOwned || tags["team"] != "ml" || tags["Name"] != "test-cluster" {
+		t.Fatalf("unexpected tags: %v", tags)
+	}
+
+	// A zone whose id wasn't recorded is found by its caller reference.
+	lost := &v1alpha1.HostedZone{}
+	if err := s.ReconcilePrivateHostedZone(context.TODO(), "test-cluster", spec, nil, vpc, lost); err != nil {
+		t.Fatalf("failed to reconcile private hosted zone: %v", err)
+	}
+	if len(f.zones) != 1 || lost.ID != status.ID {
+		t.Fatalf("expected the zone to be found again, got: %+v", lost)
+	}
+
+	// The name can't be changed once the zone exists.
+	renamed := &v1alpha1.PrivateHostedZoneSpec{Name: "example.internal"}
+	if err := s.ReconcilePrivateHostedZone(context.TODO(), "test-cluster", renamed, nil, vpc, status); !ec2svc.IsInvalidConfiguration(err) {
+		t.Fatalf("expected an invalid configuration error, got: %v", err)
+	}
+
+	f.records[status.ID] = append(f.records[status.ID], &route53.ResourceRecordSet{Name: aws.String("db.test-cluster.internal."), Type: aws.String(route53.RRTypeA)})
+
+	if err := s.DeletePrivateHostedZone(context.TODO(), "test-cluster", spec, vpc, status); err != nil {
+		t.Fatalf("failed to delete private hosted zone: %v", err)
+	}
+	if len(f.zones) != 0 {
+		t.Fatalf("expected the hosted zone to be deleted, got: %v", f.zones)
+	}
+
+	// Deleting a zone that is already gone succeeds.
+	if err := s.DeletePrivateHostedZone(context.TODO(), "test-cluster", spec, vpc, status); err != nil {
+		t.Fatalf("failed to delete private hosted zone again: %v", err)
+	}
+}
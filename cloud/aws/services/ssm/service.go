@@ -0,0 +1,88 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ssm shuts down the operating system of instances through SSM Run Command before they
+// are terminated, which needs the SSM agent on the instances and an instance profile allowing it.
+package ssm
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/logger"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+)
+
+// shutdownDocument is the document the shutdown command is run with.
+const shutdownDocument = "AWS-RunShellScript"
+
+// shutdownCommand shuts down the operating system, so that services are stopped in order and
+// the journal is flushed.
+const shutdownCommand = "shutdown -h now"
+
+// Service shuts down the instances of machines.
+type Service struct {
+	SSM ssmiface.SSMAPI
+
+	log logr.Logger
+}
+
+// NewService returns a new service given the ssm api client.
+func NewService(api ssmiface.SSMAPI) *Service {
+	return &Service{
+		SSM: api,
+		log: logger.Default(),
+	}
+}
+
+// WithLogger returns a copy of the service that logs to the given logger.
+func (s *Service) WithLogger(log logr.Logger) *Service {
+	c := *s
+	c.log = log
+	return &c
+}
+
+// ShutdownInstance sends the command shutting down the operating system of the instance, without
+// waiting for it to run. A not found error is returned if the instance isn't managed by SSM, like
+// when its agent isn't running.
+func (s *Service) ShutdownInstance(ctx context.Context, instanceID string) error {
+	out, err := s.SSM.SendCommandWithContext(ctx, &ssm.SendCommandInput{
+		DocumentName: aws.String(shutdownDocument),
+		InstanceIds:  []*string{aws.String(instanceID)},
+		Comment:      aws.String("Shut down before termination by cluster-api-provider-aws"),
+		Parameters: map[string][]*string{
+			"commands": {aws.String(shutdownCommand)},
+		},
+	})
+	switch {
+	case isNotManaged(err):
+		return ec2svc.NewNotFound(errors.Wrapf(err, "instance %q isn't managed by SSM", instanceID))
+	case err != nil:
+		return errors.Wrapf(err, "failed to send shutdown command to instance %q", instanceID)
+	}
+
+	s.log.V(2).Info("Sent shutdown command", "instance-id", instanceID, "command-id", aws.StringValue(out.Command.CommandId))
+	return nil
+}
+
+func isNotManaged(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code() == ssm.ErrCodeInvalidInstanceId
+	}
+	return false
+}
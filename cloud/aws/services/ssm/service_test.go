@@ -0,0 +1,68 @@
+// Copyright © 2018 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+)
+
+// fakeSSM records the commands sent to the instances it manages.
+type fakeSSM struct {
+	ssmiface.SSMAPI
+
+	managed  map[string]bool
+	commands []*ssm.SendCommandInput
+}
+
+func (f *fakeSSM) SendCommandWithContext(_ aws.Context, in *ssm.SendCommandInput, _ ...request.Option) (*ssm.SendCommandOutput, error) {
+	for _, id := range in.InstanceIds {
+		if !f.managed[aws.StringValue(id)] {
+			return nil, awserr.New(ssm.ErrCodeInvalidInstanceId, "instances not in a valid state for account", nil)
+		}
+	}
+	f.commands = append(f.commands, in)
+	return &ssm.SendCommandOutput{Command: &ssm.Command{CommandId: aws.String("cmd-1")}}, nil
+}
+
+func TestShutdownInstance(t *testing.T) {
+	fake := &fakeSSM{managed: map[string]bool{"i-1": true}}
+	s := NewService(fake)
+
+	if err := s.ShutdownInstance(context.Background(), "i-1"); err != nil {
+		t.Fatalf("failed to shut down instance: %v", err)
+	}
+	if len(fake.commands) != 1 {
+		t.Fatalf("expected 1 command, got %d", len(fake.commands))
+	}
+	in := fake.commands[0]
+	if aws.StringValue(in.DocumentName) != shutdownDocument {
+		t.Errorf("expected document %q, got %q", shutdownDocument, aws.StringValue(in.DocumentName))
+	}
+	if commands := aws.StringValueSlice(in.Parameters["commands"]); len(commands) != 1 || commands[0] != shutdownCommand {
+		t.Errorf("expected commands [%q], got %q", shutdownCommand, commands)
+	}
+
+	err := s.ShutdownInstance(context.Background(), "i-2")
+	if !ec2svc.IsNotFound(err) {
+		t.Errorf("expected a not found error for an instance not managed by SSM, got %v", err)
+	}
+}
@@ -28,8 +28,11 @@ import (
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/controllers/cluster/options"
 )
 
+var server = options.NewServer()
+
 func init() {
 	config.ControllerConfig.AddFlags(pflag.CommandLine)
+	server.AddFlags(pflag.CommandLine)
 }
 
 func main() {
@@ -41,8 +44,7 @@ func main() {
 	logs.InitLogs()
 	defer logs.FlushLogs()
 
-	clusterServer := options.NewServer()
-	if err := cluster.Run(clusterServer); err != nil {
+	if err := cluster.Run(server); err != nil {
 		glog.Errorf("Failed to start cluster controller. Err: %v", err)
 	}
 }
@@ -0,0 +1,54 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// cluster-export prints the AWS resources managed for a cluster as a CloudFormation template.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/golang/glog"
+	"github.com/spf13/pflag"
+
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/export"
+	ec2svc "sigs.k8s.io/cluster-api-provider-aws/cloud/aws/services/ec2"
+)
+
+func main() {
+	clusterName := pflag.String("cluster-name", "", "Name of the cluster to export the AWS resources of")
+	pflag.Parse()
+
+	if *clusterName == "" {
+		glog.Exit("--cluster-name is required")
+	}
+
+	// Requires the same AWS environment variables as the controllers.
+	sess := session.Must(session.NewSession())
+	resources, err := ec2svc.NewService(ec2.New(sess)).DescribeClusterResources(context.Background(), *clusterName)
+	if err != nil {
+		glog.Exitf("Failed to describe the resources of cluster %q: %v", *clusterName, err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(export.CloudFormation(*clusterName, resources)); err != nil {
+		glog.Exitf("Failed to write the template: %v", err)
+	}
+}
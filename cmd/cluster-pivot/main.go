@@ -0,0 +1,67 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// cluster-pivot moves the clusters and machines from one management cluster to another, like
+// from a bootstrap cluster to the cluster it created, keeping their AWS resources. The provider
+// controllers have to run in the target cluster, they adopt the resources.
+package main
+
+import (
+	"github.com/golang/glog"
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/cluster-api/pkg/client/clientset_generated/clientset"
+
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/backup"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+)
+
+func main() {
+	from := pflag.String("from-kubeconfig", "", "Kubeconfig of the management cluster to move the clusters and machines from")
+	to := pflag.String("to-kubeconfig", "", "Kubeconfig of the management cluster to move the clusters and machines to")
+	namespace := pflag.String("namespace", "", "Namespace to move the clusters and machines of. All namespaces are moved if empty")
+	pflag.Parse()
+
+	if *from == "" || *to == "" {
+		glog.Exit("--from-kubeconfig and --to-kubeconfig are required")
+	}
+
+	fromClients, err := newClientset(*from)
+	if err != nil {
+		glog.Exitf("Failed to create client for %s: %v", *from, err)
+	}
+	toClients, err := newClientset(*to)
+	if err != nil {
+		glog.Exitf("Failed to create client for %s: %v", *to, err)
+	}
+	codec, err := v1alpha1.NewCodec()
+	if err != nil {
+		glog.Exitf("Failed to create codec: %v", err)
+	}
+
+	if err := backup.Pivot(fromClients.ClusterV1alpha1(), toClients.ClusterV1alpha1(), codec, *namespace); err != nil {
+		glog.Exitf("Failed to pivot, run again to continue: %v", err)
+	}
+	glog.Info("Pivoted the clusters and machines")
+}
+
+func newClientset(kubeconfig string) (*clientset.Clientset, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	return clientset.NewForConfig(config)
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// cluster-restore restores the clusters and machines backed up by the cluster controller into
+// a new management cluster, whose controllers then adopt their AWS resources.
+package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/golang/glog"
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/cluster-api/pkg/client/clientset_generated/clientset"
+
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/backup"
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/providerconfig/v1alpha1"
+)
+
+func main() {
+	kubeconfig := pflag.String("kubeconfig", "", "Kubeconfig of the management cluster to restore the backup into. The in-cluster config is used if empty")
+	bucket := pflag.String("backup-bucket", "", "S3 bucket the backup was stored in")
+	key := pflag.String("backup-key", "cluster-api-provider-aws/backup.json", "Key of the backup in the bucket")
+	pflag.Parse()
+
+	if *bucket == "" {
+		glog.Exit("--backup-bucket is required")
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	if err != nil {
+		glog.Exitf("Failed to load kubeconfig: %v", err)
+	}
+	clients, err := clientset.NewForConfig(config)
+	if err != nil {
+		glog.Exitf("Failed to create client: %v", err)
+	}
+	codec, err := v1alpha1.NewCodec()
+	if err != nil {
+		glog.Exitf("Failed to create codec: %v", err)
+	}
+
+	// Requires the same AWS environment variables as the controllers.
+	sess := session.Must(session.NewSession())
+	b, err := backup.NewStore(s3.New(sess), *bucket, *key).Get(context.Background())
+	if err != nil {
+		glog.Exitf("Failed to get the backup: %v", err)
+	}
+
+	if err := backup.Restore(clients.ClusterV1alpha1(), codec, b); err != nil {
+		glog.Exitf("Failed to restore the backup taken at %s: %v", b.Time, err)
+	}
+	glog.Infof("Restored %d clusters and %d machines backed up at %s", len(b.Clusters), len(b.Machines), b.Time)
+}
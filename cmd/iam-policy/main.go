@@ -0,0 +1,60 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// iam-policy prints the IAM policy of the controllers or of the nodes of a cluster, scoped to
+// the resources tagged for the clusters.
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/golang/glog"
+	"github.com/spf13/pflag"
+
+	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/iampolicy"
+)
+
+func main() {
+	role := pflag.String("role", "controller", "Role to print the policy of: controller, control-plane or node")
+	clusterNames := pflag.StringSlice("cluster-name", nil, "Names of the clusters the policy is scoped to. The control plane policy takes a single one")
+	ecrRepositories := pflag.StringSlice("ecr-repository", nil, "ARNs of the ECR repositories the node policy may pull images from. If unset, it may pull from every repository")
+	pflag.Parse()
+
+	var doc *iampolicy.Document
+	switch *role {
+	case "controller":
+		if len(*clusterNames) == 0 {
+			glog.Exit("--cluster-name is required")
+		}
+		doc = iampolicy.Controller(*clusterNames)
+	case "control-plane":
+		if len(*clusterNames) != 1 {
+			glog.Exit("a single --cluster-name is required")
+		}
+		doc = iampolicy.ControlPlane((*clusterNames)[0])
+	case "node":
+		doc = iampolicy.Node(*ecrRepositories)
+	default:
+		glog.Exitf("Unknown role %q", *role)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		glog.Exitf("Failed to write the policy: %v", err)
+	}
+}
@@ -28,8 +28,11 @@ import (
 	"sigs.k8s.io/cluster-api-provider-aws/cloud/aws/controllers/machine/options"
 )
 
+var server = options.NewServer()
+
 func init() {
 	config.ControllerConfig.AddFlags(pflag.CommandLine)
+	server.AddFlags(pflag.CommandLine)
 }
 
 func main() {
@@ -41,8 +44,7 @@ func main() {
 	logs.InitLogs()
 	defer logs.FlushLogs()
 
-	machineServer := options.NewServer()
-	if err := machine.Run(machineServer); err != nil {
+	if err := machine.Run(server); err != nil {
 		glog.Errorf("Failed to start the machine controller. Err: %v", err)
 	}
 }